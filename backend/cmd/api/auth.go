@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/delphi-platform/delphi/backend/internal/apierror"
+	"github.com/delphi-platform/delphi/backend/internal/config"
+	"github.com/delphi-platform/delphi/backend/internal/handlers"
+	internalmiddleware "github.com/delphi-platform/delphi/backend/internal/middleware"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/internal/security"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/pkg/auth"
+	"github.com/delphi-platform/delphi/backend/pkg/crypto"
+	pkglogger "github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/go-chi/chi/v5"
+)
+
+// ============================================================================
+// Authentication
+// ============================================================================
+
+// authService verifies credentials and tokens. It is nil when no database is
+// configured, in which case the auth endpoints are unavailable and the other
+// routes are served without authentication.
+var authService *services.AuthService
+
+// newAuthService creates the auth service from DATABASE_URL and JWT_SECRET.
+// The returned function releases its database connection.
+func newAuthService() (*services.AuthService, func(), error) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		return nil, func() {}, nil
+	}
+
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return nil, nil, fmt.Errorf("JWT_SECRET is required when DATABASE_URL is set")
+	}
+	accessTTL, err := envInt("JWT_ACCESS_TTL_MINUTES", 60)
+	if err != nil {
+		return nil, nil, err
+	}
+	refreshTTL, err := envInt("JWT_REFRESH_TTL_DAYS", 7)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// MFA secrets are encrypted, as in the main services
+	encryptor, err := newEncryptor()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db, err := repository.NewPostgresDB(databaseURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg := &config.Config{
+		Environment:         environment(),
+		JWTSecret:           secret,
+		JWTAccessTTLMinutes: accessTTL,
+		JWTRefreshTTLDays:   refreshTTL,
+	}
+
+	log := pkglogger.New()
+	repos := repository.NewRepositories(db)
+	jwtManager := auth.NewJWTManager(secret, accessTTL, refreshTTL)
+	audit := services.NewAuditService(repos, log)
+
+	// Registration creates tenants as the tenant service does. Scheduled
+	// deletions are left to the main services, which cancel the tenant's
+	// Stripe subscriptions and remove its vectors first, so the deletion
+	// loop is stopped straight away.
+	tenants := services.NewTenantService(cfg, repos, nil, nil, audit, log)
+	tenants.Stop()
+
+	svc := services.NewAuthService(cfg, repos, jwtManager, tenants, encryptor, audit, log)
+	return svc, db.Close, nil
+}
+
+// environment returns ENVIRONMENT, which defaults to development
+func environment() string {
+	if env := os.Getenv("ENVIRONMENT"); env != "" {
+		return env
+	}
+	return "development"
+}
+
+// newEncryptor creates the secrets encryptor from ENCRYPTION_KEY and
+// ENCRYPTION_KEY_VERSION. Secrets are never stored in plaintext, so the key is
+// required outside development; without one, storing a secret fails.
+func newEncryptor() (*crypto.Encryptor, error) {
+	key := os.Getenv("ENCRYPTION_KEY")
+	if key == "" {
+		if environment() != "development" {
+			return nil, fmt.Errorf("ENCRYPTION_KEY is required outside development")
+		}
+		return nil, nil
+	}
+
+	version, err := envInt("ENCRYPTION_KEY_VERSION", 1)
+	if err != nil || version > 255 {
+		return nil, fmt.Errorf("ENCRYPTION_KEY_VERSION must be between 1 and 255")
+	}
+	encryptor, err := crypto.NewVersionedEncryptor(key, byte(version))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ENCRYPTION_KEY: %w", err)
+	}
+	return encryptor, nil
+}
+
+// envInt reads a positive integer from the environment, or returns def when
+// the variable is unset
+func envInt(name string, def int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%s must be a positive integer", name)
+	}
+	return n, nil
+}
+
+// authResponse is returned by login and registration. Token is the access
+// token sent as a Bearer token on other requests.
+func authResponse(tokens *auth.TokenPair, user interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_at":    tokens.ExpiresAt,
+		"user":          user,
+	}
+}
+
+// requireAuthService writes a 503 and returns false when authentication is
+// not configured
+func requireAuthService(w http.ResponseWriter) bool {
+	if authService == nil {
+		jsonError(w, http.StatusServiceUnavailable, "Authentication is not configured")
+		return false
+	}
+	return true
+}
+
+func handleLogin(w http.ResponseWriter, r *http.Request) {
+	if !requireAuthService(w) {
+		return
+	}
+
+	var req services.LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Email == "" || req.Password == "" {
+		jsonError(w, http.StatusBadRequest, "Email and password are required")
+		return
+	}
+
+	tokens, user, err := authService.Login(r.Context(), &req)
+	if err != nil {
+		if errors.Is(err, services.ErrMFARequired) || errors.Is(err, services.ErrInvalidMFACode) {
+			apierror.Write(w, http.StatusUnauthorized, apierror.WithCode(apierror.CodeMFARequired, err.Error(),
+				map[string]bool{"mfa_required": true}))
+			return
+		}
+		if strings.HasPrefix(err.Error(), "failed to") {
+			logger.Errorw("login failed", "error", err)
+			jsonError(w, http.StatusInternalServerError, "Login failed")
+			return
+		}
+		jsonError(w, http.StatusUnauthorized, "Invalid email or password")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, authResponse(tokens, user))
+}
+
+func handleRegister(w http.ResponseWriter, r *http.Request) {
+	if !requireAuthService(w) {
+		return
+	}
+
+	var req services.RegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Email == "" || req.Password == "" || req.Name == "" {
+		jsonError(w, http.StatusBadRequest, "Name, email and password are required")
+		return
+	}
+
+	tokens, user, err := authService.Register(r.Context(), &req)
+	if err != nil {
+		var taken *services.SlugTakenError
+		switch {
+		case errors.As(err, &taken):
+			var details map[string]string
+			if taken.Suggestion != "" {
+				details = map[string]string{"suggested_slug": taken.Suggestion}
+			}
+			apierror.Write(w, http.StatusConflict, apierror.WithCode(apierror.CodeSlugTaken, taken.Error(), details))
+		case strings.HasPrefix(err.Error(), "failed to"):
+			logger.Errorw("registration failed", "error", err)
+			jsonError(w, http.StatusInternalServerError, "Registration failed")
+		case strings.Contains(err.Error(), "already"):
+			jsonError(w, http.StatusConflict, err.Error())
+		default:
+			jsonError(w, http.StatusBadRequest, err.Error())
+		}
+		return
+	}
+
+	jsonResponse(w, http.StatusCreated, authResponse(tokens, user))
+}
+
+func handleRefresh(w http.ResponseWriter, r *http.Request) {
+	if !requireAuthService(w) {
+		return
+	}
+
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.RefreshToken == "" {
+		jsonError(w, http.StatusBadRequest, "refresh_token is required")
+		return
+	}
+
+	tokens, err := authService.RefreshToken(r.Context(), req.RefreshToken)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "failed to") {
+			logger.Errorw("token refresh failed", "error", err)
+			jsonError(w, http.StatusInternalServerError, "Token refresh failed")
+			return
+		}
+		jsonError(w, http.StatusUnauthorized, "Invalid refresh token")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_at":    tokens.ExpiresAt,
+	})
+}
+
+// mountMFARoutes adds the MFA enrollment endpoints, which act on the
+// authenticated user
+func mountMFARoutes(r chi.Router) {
+	h := handlers.NewAuthHandler(authService, pkglogger.New())
+	r.Post("/auth/mfa/enroll", h.EnrollMFA)
+	r.Post("/auth/mfa/verify", h.VerifyMFA)
+	r.Post("/auth/mfa/disable", h.DisableMFA)
+}
+
+// rbac maps authenticated users' roles to the routes they may call
+var rbac = security.NewRBAC(pkglogger.New())
+
+// permit guards a route with the permission it needs. Without authentication
+// there are no roles to check, so the route is open.
+func permit(permission security.Permission) func(http.Handler) http.Handler {
+	if authService == nil {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return internalmiddleware.RequirePermission(rbac, permission)
+}
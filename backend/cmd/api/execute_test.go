@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	agentexec "github.com/delphi-platform/delphi/backend/internal/execution"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	aiproviders "github.com/delphi-platform/delphi/backend/internal/providers"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Execute Tests
+// =============================================================================
+
+// overBudgetStore refuses every execution as over the tenant's cost limit
+type overBudgetStore struct {
+	memoryExecutionStore
+}
+
+func (s *overBudgetStore) Create(ctx context.Context, agent *Agent, exec *Execution) error {
+	return &repository.BudgetExceededError{Limit: &models.CostLimit{LimitType: "monthly", Amount: 10}, Spent: 10}
+}
+
+func TestExecuteOverBudget(t *testing.T) {
+	agent := &Agent{ID: "agent-budget", Name: "Budget", Status: "ready", ModelProvider: "openai", Model: "gpt-4o", CreatedAt: time.Now()}
+	testAPI(t, uuid.New(), agent)
+	// Authentication is disabled, so requests act for the default organization
+	authService = nil
+	agent.OrgID = defaultOrgID
+
+	prevStore, prevProviders, prevStream := execStore, providers, streamProviders
+	execStore = &overBudgetStore{memoryExecutionStore{executions: make(map[string]*Execution)}}
+	providers = map[string]AIProvider{"openai": NewOpenAIProvider("key", "gpt-4o", aiproviders.Endpoint{})}
+	streamProviders = map[string]aiproviders.Provider{"openai": aiproviders.NewOpenAIProvider("key")}
+	t.Cleanup(func() { execStore, providers, streamProviders = prevStore, prevProviders, prevStream })
+
+	router := newRouter()
+	for _, path := range []string{"/api/v1/execute", "/api/v1/execute/stream"} {
+		rec := httptest.NewRecorder()
+		body := `{"agent_id":"agent-budget","prompt":"hello"}`
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		assert.Equal(t, http.StatusPaymentRequired, rec.Code, path)
+		assert.Contains(t, rec.Header().Get("Content-Type"), "application/json", path)
+		assert.Contains(t, rec.Body.String(), "Budget exceeded", path)
+	}
+}
+
+func TestExecuteOverConcurrencyLimit(t *testing.T) {
+	agent := &Agent{ID: "agent-busy", Name: "Busy", Status: "ready", ModelProvider: "openai", Model: "gpt-4o", CreatedAt: time.Now()}
+	testAPI(t, uuid.New(), agent)
+	authService = nil
+	agent.OrgID = defaultOrgID
+
+	prevStore, prevProviders, prevStream, prevSlots := execStore, providers, streamProviders, runSlots
+	execStore = &overBudgetStore{memoryExecutionStore{executions: make(map[string]*Execution)}}
+	providers = map[string]AIProvider{"openai": NewOpenAIProvider("key", "gpt-4o", aiproviders.Endpoint{})}
+	streamProviders = map[string]aiproviders.Provider{"openai": aiproviders.NewOpenAIProvider("key")}
+	runSlots = agentexec.NewConcurrencyLimiter(nil, agentexec.ConcurrencyConfig{Max: 1})
+	t.Cleanup(func() {
+		execStore, providers, streamProviders, runSlots = prevStore, prevProviders, prevStream, prevSlots
+	})
+
+	// Without authentication, every request runs for the same tenant
+	running, err := runSlots.Acquire(context.Background(), uuid.Nil)
+	require.NoError(t, err)
+
+	router := newRouter()
+	execute := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		body := `{"agent_id":"agent-busy","prompt":"hello"}`
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return rec
+	}
+	for _, path := range []string{"/api/v1/execute", "/api/v1/execute/stream"} {
+		rec := execute(path)
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code, path)
+		assert.Contains(t, rec.Body.String(), "Too many concurrent executions", path)
+	}
+
+	// Once the running execution ends, the next one gets as far as the budget
+	running.Release()
+	assert.Equal(t, http.StatusPaymentRequired, execute("/api/v1/execute").Code)
+	assert.Zero(t, runSlots.Stats(context.Background(), uuid.Nil).Active, "refused executions give their slot back")
+}
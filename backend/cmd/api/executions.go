@@ -0,0 +1,616 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/config"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/payload"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	pkglogger "github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// ============================================================================
+// Execution Store
+// ============================================================================
+
+// executionStore persists executions. Postgres is used whenever a database is
+// configured so executions survive restarts and are shared between instances;
+// the in-memory store is a fallback for running without one. Reads are scoped
+// to an organization: another organization's executions are not found.
+type executionStore interface {
+	Create(ctx context.Context, agent *Agent, exec *Execution) error
+	Complete(ctx context.Context, exec *Execution) error
+	Fail(ctx context.Context, exec *Execution) error
+	Get(ctx context.Context, org, id string) (*Execution, error)
+	List(ctx context.Context, org string) ([]*Execution, error)
+	Page(ctx context.Context, org, cursor string, limit int) (*executionPage, error)
+}
+
+// executionPage is one page of executions, newest first. NextCursor is empty
+// on the last page.
+type executionPage struct {
+	Items      []*Execution `json:"items"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+	Total      int          `json:"total"`
+}
+
+// newExecutionStore selects the store from EXECUTION_STORE ("postgres" or
+// "memory"). It defaults to postgres when DATABASE_URL is set. The returned
+// function releases the store's resources.
+func newExecutionStore() (executionStore, func(), error) {
+	databaseURL := os.Getenv("DATABASE_URL")
+
+	mode := os.Getenv("EXECUTION_STORE")
+	if mode == "" {
+		mode = "memory"
+		if databaseURL != "" {
+			mode = "postgres"
+		}
+	}
+
+	switch mode {
+	case "memory":
+		return &memoryExecutionStore{executions: make(map[string]*Execution)}, func() {}, nil
+	case "postgres":
+		if databaseURL == "" {
+			return nil, nil, fmt.Errorf("EXECUTION_STORE=postgres requires DATABASE_URL")
+		}
+		db, err := repository.NewPostgresDB(databaseURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		repos := repository.NewRepositories(db)
+		alerts, closeAlerts, err := newBudgetAlerts(repos)
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		store := &postgresExecutionStore{
+			repos:        repos,
+			budgetAlerts: alerts,
+			ensured:      make(map[string]bool),
+		}
+		return store, func() {
+			closeAlerts()
+			db.Close()
+		}, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown EXECUTION_STORE %q: use postgres or memory", mode)
+	}
+}
+
+// newBudgetAlerts creates the alerts sent as executions' costs are recorded,
+// through the notification channels configured as for the main services.
+// Forecast alerts are deduplicated in Redis, so they need REDIS_URL.
+func newBudgetAlerts(repos *repository.Repositories) (*services.BudgetAlertService, func(), error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var redis *repository.RedisClient
+	closeRedis := func() {}
+	if os.Getenv("REDIS_URL") != "" {
+		if redis, err = repository.NewRedisClient(cfg.RedisURL); err != nil {
+			return nil, nil, err
+		}
+		closeRedis = func() { redis.Close() }
+	}
+
+	log := pkglogger.New()
+	notification := services.NewNotificationService(cfg, repos, log)
+	return services.NewBudgetAlertService(cfg, repos, redis, notification, log), closeRedis, nil
+}
+
+// Default stored payload limits, in bytes
+const (
+	defaultMaxStoredPromptBytes   = 64 * 1024
+	defaultMaxStoredResponseBytes = 256 * 1024
+)
+
+// payloads truncates stored prompts and responses over the size limits
+var payloads *payload.Limiter
+
+// newPayloadLimiter creates the payload limiter from MAX_STORED_PROMPT_BYTES
+// and MAX_STORED_RESPONSE_BYTES (0 stores payloads whole). Full payloads are
+// kept under PAYLOAD_STORE_DIR, or in memory when it is not set.
+func newPayloadLimiter() (*payload.Limiter, error) {
+	limits := payload.Limits{
+		MaxPromptBytes:   defaultMaxStoredPromptBytes,
+		MaxResponseBytes: defaultMaxStoredResponseBytes,
+	}
+	for env, limit := range map[string]*int{
+		"MAX_STORED_PROMPT_BYTES":   &limits.MaxPromptBytes,
+		"MAX_STORED_RESPONSE_BYTES": &limits.MaxResponseBytes,
+	} {
+		value := os.Getenv(env)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%s must be a non-negative integer", env)
+		}
+		*limit = n
+	}
+
+	dir := os.Getenv("PAYLOAD_STORE_DIR")
+	if dir == "" {
+		return payload.NewLimiter(payload.NewMemoryStore(), limits), nil
+	}
+	store, err := payload.NewFileStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	return payload.NewLimiter(store, limits), nil
+}
+
+// memoryExecutionStore keeps executions in a map; they are lost on restart
+type memoryExecutionStore struct {
+	mu         sync.RWMutex
+	executions map[string]*Execution
+}
+
+func (s *memoryExecutionStore) Create(ctx context.Context, agent *Agent, exec *Execution) error {
+	exec.ID = fmt.Sprintf("exec-%d", time.Now().UnixNano())
+	exec.orgID = agent.OrgID
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.executions[exec.ID] = exec
+	return nil
+}
+
+// Complete is a no-op: the stored execution is the one the handler updates
+func (s *memoryExecutionStore) Complete(ctx context.Context, exec *Execution) error {
+	return nil
+}
+
+// Fail is a no-op: the stored execution is the one the handler updates
+func (s *memoryExecutionStore) Fail(ctx context.Context, exec *Execution) error {
+	return nil
+}
+
+func (s *memoryExecutionStore) Get(ctx context.Context, org, id string) (*Execution, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	exec, ok := s.executions[id]
+	if !ok || exec.orgID != org {
+		return nil, nil
+	}
+	return exec, nil
+}
+
+func (s *memoryExecutionStore) List(ctx context.Context, org string) ([]*Execution, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	execList := make([]*Execution, 0, len(s.executions))
+	for _, exec := range s.executions {
+		if exec.orgID == org {
+			execList = append(execList, exec)
+		}
+	}
+	sortExecutions(execList)
+	return execList, nil
+}
+
+// Page returns the executions after cursor. The cursor encodes the start time
+// and ID of the last execution on the previous page.
+func (s *memoryExecutionStore) Page(ctx context.Context, org, cursor string, limit int) (*executionPage, error) {
+	var after *Execution
+	if cursor != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return nil, repository.ErrInvalidCursor
+		}
+		nanos, id, ok := strings.Cut(string(raw), ":")
+		n, err := strconv.ParseInt(nanos, 10, 64)
+		if !ok || err != nil || id == "" {
+			return nil, repository.ErrInvalidCursor
+		}
+		after = &Execution{ID: id, StartTime: time.Unix(0, n)}
+	}
+
+	execList, _ := s.List(ctx, org)
+	page := &executionPage{Items: []*Execution{}, Total: len(execList)}
+	for _, exec := range execList {
+		if after != nil && !executionBefore(exec, after) {
+			continue
+		}
+		if len(page.Items) == limit {
+			last := page.Items[limit-1]
+			page.NextCursor = base64.RawURLEncoding.EncodeToString(
+				[]byte(fmt.Sprintf("%d:%s", last.StartTime.UnixNano(), last.ID)))
+			break
+		}
+		page.Items = append(page.Items, exec)
+	}
+	return page, nil
+}
+
+// executionListLimit caps how many of an organization's newest runs List reads back
+const executionListLimit = 1000
+
+// recordNamespace derives stable database IDs for agents and organizations
+// that this API identifies by plain strings such as "agent-1"
+var recordNamespace = uuid.MustParse("6f1c9a52-3b7e-4d0a-9c2f-8e5d4b1a7c30")
+
+// recordID maps an API identifier to the UUID of its database row
+func recordID(kind, id string) uuid.UUID {
+	if parsed, err := uuid.Parse(id); err == nil {
+		return parsed
+	}
+	return uuid.NewSHA1(recordNamespace, []byte(kind+":"+id))
+}
+
+// executionResult is the run result stored for a completed execution
+type executionResult struct {
+	Response     string `json:"response"`
+	Provider     string `json:"provider"`
+	Model        string `json:"model"`
+	MessageCount int    `json:"message_count,omitempty"`
+	ModelWarning string `json:"model_warning,omitempty"`
+	InputTokens  int    `json:"input_tokens"`
+	OutputTokens int    `json:"output_tokens"`
+
+	// Attempts lists the models tried when the agent has a fallback chain
+	Attempts []ChainAttempt `json:"attempts,omitempty"`
+}
+
+// postgresExecutionStore stores executions as agent runs through the
+// repository layer. Agents and organizations live in memory in this API, so
+// their rows are created on first use to satisfy the runs' foreign keys.
+type postgresExecutionStore struct {
+	repos        *repository.Repositories
+	budgetAlerts *services.BudgetAlertService
+
+	mu      sync.Mutex
+	ensured map[string]bool // agent IDs whose rows are known to exist
+}
+
+func (s *postgresExecutionStore) Create(ctx context.Context, agent *Agent, exec *Execution) error {
+	if err := s.ensureAgent(ctx, agent); err != nil {
+		return err
+	}
+
+	run := &models.AgentRun{
+		ID:        uuid.New(),
+		AgentID:   recordID("agent", agent.ID),
+		TenantID:  recordID("org", agent.OrgID),
+		Prompt:    exec.Prompt,
+		PromptRef: exec.PromptRef,
+		Status:    models.RunStatusRunning,
+		StartedAt: exec.StartTime,
+	}
+	windows, err := s.repos.Costs.BudgetWindows(ctx, run.TenantID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to get cost limits: %w", err)
+	}
+	if err := s.repos.AgentRuns.CreateWithinBudget(ctx, run, windows); err != nil {
+		var exceeded *repository.BudgetExceededError
+		if errors.As(err, &exceeded) {
+			return err
+		}
+		return fmt.Errorf("failed to create agent run: %w", err)
+	}
+
+	exec.ID = run.ID.String()
+	return nil
+}
+
+func (s *postgresExecutionStore) Complete(ctx context.Context, exec *Execution) error {
+	id, err := uuid.Parse(exec.ID)
+	if err != nil {
+		return fmt.Errorf("invalid execution id: %w", err)
+	}
+
+	result, err := json.Marshal(executionResult{
+		Response:     exec.Response,
+		Provider:     exec.Provider,
+		Model:        exec.Model,
+		MessageCount: exec.MessageCount,
+		ModelWarning: exec.ModelWarning,
+		InputTokens:  exec.InputTokens,
+		OutputTokens: exec.OutputTokens,
+		Attempts:     exec.Attempts,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal result: %w", err)
+	}
+
+	if err := s.repos.AgentRuns.Complete(ctx, id, result, exec.TokensUsed, exec.CostUSD); err != nil {
+		return fmt.Errorf("failed to complete agent run: %w", err)
+	}
+
+	// Cost limits are measured against cost records
+	run, err := s.repos.AgentRuns.GetByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get agent run: %w", err)
+	}
+	if run == nil {
+		return fmt.Errorf("agent run %s not found", id)
+	}
+	err = s.repos.Costs.RecordCost(ctx, &models.CostRecord{
+		ID:           uuid.New(),
+		TenantID:     run.TenantID,
+		AgentID:      &run.AgentID,
+		RunID:        &id,
+		Provider:     models.AIProvider(exec.Provider),
+		Model:        exec.Model,
+		InputTokens:  exec.InputTokens,
+		OutputTokens: exec.OutputTokens,
+		Cost:         exec.CostUSD,
+		CreatedAt:    exec.EndTime,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record cost: %w", err)
+	}
+	if s.budgetAlerts != nil {
+		s.budgetAlerts.Notify(ctx, run.TenantID, exec.CostUSD)
+	}
+	if exec.RequestID != "" {
+		if err := s.repos.AgentRuns.SetProviderRequestID(ctx, id, exec.RequestID); err != nil {
+			return fmt.Errorf("failed to store provider request id: %w", err)
+		}
+	}
+	if exec.ResponseRef != "" {
+		if err := s.repos.AgentRuns.SetResponseRef(ctx, id, exec.ResponseRef); err != nil {
+			return fmt.Errorf("failed to store response reference: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *postgresExecutionStore) Fail(ctx context.Context, exec *Execution) error {
+	id, err := uuid.Parse(exec.ID)
+	if err != nil {
+		return fmt.Errorf("invalid execution id: %w", err)
+	}
+
+	if err := s.repos.AgentRuns.Fail(ctx, id, exec.ErrorMessage); err != nil {
+		return fmt.Errorf("failed to fail agent run: %w", err)
+	}
+	return nil
+}
+
+func (s *postgresExecutionStore) Get(ctx context.Context, org, id string) (*Execution, error) {
+	runID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, nil
+	}
+
+	run, err := s.repos.AgentRuns.GetByID(ctx, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get agent run: %w", err)
+	}
+	if run == nil || run.TenantID != recordID("org", org) {
+		return nil, nil
+	}
+
+	agents, err := s.runAgents(ctx, org, []*models.AgentRun{run})
+	if err != nil {
+		return nil, err
+	}
+	return runToExecution(run, agents[run.AgentID]), nil
+}
+
+func (s *postgresExecutionStore) List(ctx context.Context, org string) ([]*Execution, error) {
+	page, err := s.Page(ctx, org, "", executionListLimit)
+	if err != nil {
+		return nil, err
+	}
+	return page.Items, nil
+}
+
+// Page returns the runs of org's agents after cursor, using the run
+// repository's cursor format
+func (s *postgresExecutionStore) Page(ctx context.Context, org, cursor string, limit int) (*executionPage, error) {
+	tenantID := recordID("org", org)
+	runs, next, err := s.repos.AgentRuns.PageByTenant(ctx, tenantID, cursor, limit)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to list agent runs: %w", err)
+	}
+	total, err := s.repos.AgentRuns.CountByTenant(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count agent runs: %w", err)
+	}
+	agents, err := s.runAgents(ctx, org, runs)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &executionPage{Items: make([]*Execution, 0, len(runs)), NextCursor: next, Total: total}
+	for _, run := range runs {
+		page.Items = append(page.Items, runToExecution(run, agents[run.AgentID]))
+	}
+	return page, nil
+}
+
+// runAgents resolves the agents of runs by database ID. Agents this instance
+// holds are used as they are; the others, created on another instance or
+// before a restart, are rebuilt from their database rows.
+func (s *postgresExecutionStore) runAgents(ctx context.Context, org string, runs []*models.AgentRun) (map[uuid.UUID]*Agent, error) {
+	byID := make(map[uuid.UUID]*Agent)
+	for _, agent := range orgAgents(org) {
+		byID[recordID("agent", agent.ID)] = agent
+	}
+
+	var records map[uuid.UUID]*models.Agent
+	for _, run := range runs {
+		if _, ok := byID[run.AgentID]; ok {
+			continue
+		}
+		if records == nil {
+			list, err := s.repos.Agents.ListByTenant(ctx, recordID("org", org))
+			if err != nil {
+				return nil, fmt.Errorf("failed to list agents: %w", err)
+			}
+			records = make(map[uuid.UUID]*models.Agent, len(list))
+			for _, record := range list {
+				records[record.ID] = record
+			}
+		}
+
+		agent := &Agent{ID: run.AgentID.String()}
+		if record := records[run.AgentID]; record != nil {
+			agent.Name = record.Name
+			agent.ModelProvider = string(record.Provider)
+			agent.Model = record.Model
+		}
+		byID[run.AgentID] = agent
+	}
+	return byID, nil
+}
+
+// ensureAgent creates the tenant and agent rows an agent's runs reference
+func (s *postgresExecutionStore) ensureAgent(ctx context.Context, agent *Agent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ensured[agent.ID] {
+		return nil
+	}
+
+	now := time.Now()
+	tenantID := recordID("org", agent.OrgID)
+	tenant, err := s.repos.Tenants.GetByID(ctx, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to get tenant: %w", err)
+	}
+	if tenant == nil {
+		err := s.repos.Tenants.Create(ctx, &models.Tenant{
+			ID:        tenantID,
+			Name:      agent.OrgID,
+			Slug:      agent.OrgID,
+			Plan:      models.PlanFree,
+			Settings:  json.RawMessage("{}"),
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+		// Another instance may have created it first
+		if err != nil {
+			if tenant, _ = s.repos.Tenants.GetByID(ctx, tenantID); tenant == nil {
+				return fmt.Errorf("failed to create tenant: %w", err)
+			}
+		}
+	}
+
+	agentID := recordID("agent", agent.ID)
+	record, err := s.repos.Agents.GetByID(ctx, agentID)
+	if err != nil {
+		return fmt.Errorf("failed to get agent: %w", err)
+	}
+	if record == nil {
+		err := s.repos.Agents.Create(ctx, &models.Agent{
+			ID:             agentID,
+			TenantID:       tenantID,
+			Name:           agent.Name,
+			Description:    agent.Description,
+			Type:           agentType(agent.Purpose),
+			Provider:       models.AIProvider(agent.ModelProvider),
+			Model:          agent.Model,
+			SystemPrompt:   agent.SystemPrompt,
+			Tools:          json.RawMessage("[]"),
+			KnowledgeBases: []uuid.UUID{},
+			Status:         models.AgentStatusReady,
+			CreatedAt:      agent.CreatedAt,
+			UpdatedAt:      now,
+		})
+		if err != nil {
+			if record, _ = s.repos.Agents.GetByID(ctx, agentID); record == nil {
+				return fmt.Errorf("failed to create agent: %w", err)
+			}
+		}
+	}
+
+	s.ensured[agent.ID] = true
+	return nil
+}
+
+// agentType maps an agent's purpose to the closest platform agent type
+func agentType(purpose string) models.AgentType {
+	switch purpose {
+	case "coding", "devops":
+		return models.AgentTypeCoding
+	case "content", "marketing":
+		return models.AgentTypeMarketing
+	case "analysis", "accounting":
+		return models.AgentTypeAccounting
+	case "product":
+		return models.AgentTypeProduct
+	case "business":
+		return models.AgentTypeBusiness
+	default:
+		return models.AgentTypeAssistant
+	}
+}
+
+// runToExecution converts a stored agent run back to an execution
+func runToExecution(run *models.AgentRun, agent *Agent) *Execution {
+	exec := &Execution{
+		ID:           run.ID.String(),
+		AgentID:      agent.ID,
+		AgentName:    agent.Name,
+		Prompt:       run.Prompt,
+		PromptRef:    run.PromptRef,
+		ResponseRef:  run.ResponseRef,
+		Status:       string(run.Status),
+		Provider:     agent.ModelProvider,
+		Model:        agent.Model,
+		RequestID:    run.ProviderRequestID,
+		TokensUsed:   run.TokensUsed,
+		CostUSD:      run.Cost,
+		StartTime:    run.StartedAt,
+		ErrorMessage: run.Error,
+	}
+	if run.CompletedAt != nil {
+		exec.EndTime = *run.CompletedAt
+	}
+
+	var result executionResult
+	if len(run.Result) > 0 && json.Unmarshal(run.Result, &result) == nil {
+		exec.Response = result.Response
+		exec.MessageCount = result.MessageCount
+		exec.ModelWarning = result.ModelWarning
+		exec.InputTokens = result.InputTokens
+		exec.OutputTokens = result.OutputTokens
+		exec.Attempts = result.Attempts
+		if result.Provider != "" {
+			exec.Provider = result.Provider
+			exec.Model = result.Model
+		}
+	}
+	return exec
+}
+
+// sortExecutions orders executions newest first
+func sortExecutions(execList []*Execution) {
+	sort.Slice(execList, func(i, j int) bool {
+		return executionBefore(execList[i], execList[j])
+	})
+}
+
+// executionBefore reports whether a sorts before b: newer first, then by
+// descending ID so executions started at the same instant keep a stable order
+func executionBefore(a, b *Execution) bool {
+	if !a.StartTime.Equal(b.StartTime) {
+		return a.StartTime.After(b.StartTime)
+	}
+	return a.ID > b.ID
+}
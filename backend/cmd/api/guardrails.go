@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/delphi-platform/delphi/backend/internal/metrics"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/internal/security"
+	pkglogger "github.com/delphi-platform/delphi/backend/pkg/logger"
+)
+
+// ============================================================================
+// Guardrails
+// ============================================================================
+
+// guardrail checks prompts before they reach a provider and responses before
+// they reach the client. Blocked and redacted content is recorded in
+// guardrailAudit.
+var (
+	guardrail      *security.DefaultGuardrail
+	guardrailAudit *security.AuditService
+)
+
+// newGuardrail creates the guardrail from GUARDRAIL_BLOCKED_PATTERNS, a JSON
+// array of regular expressions applied to every agent. Agents asking for
+// moderation are checked with the OpenAI moderation API when OPENAI_API_KEY is
+// set. Audit entries are stored in Postgres when DATABASE_URL is set, and
+// otherwise only logged; entries that can't be stored are appended to
+// AUDIT_OVERFLOW_FILE when it is set. The returned function writes the audit
+// entries still buffered, then releases the database connection.
+func newGuardrail() (*security.DefaultGuardrail, *security.AuditService, func(), error) {
+	var patterns []string
+	if value := os.Getenv("GUARDRAIL_BLOCKED_PATTERNS"); value != "" {
+		if err := json.Unmarshal([]byte(value), &patterns); err != nil {
+			return nil, nil, nil, fmt.Errorf("GUARDRAIL_BLOCKED_PATTERNS must be a JSON array of strings: %w", err)
+		}
+	}
+
+	var moderator security.Moderator
+	if key := os.Getenv("OPENAI_API_KEY"); key != "" {
+		moderator = security.NewOpenAIModerator(key)
+	}
+
+	log := pkglogger.New()
+	g, err := security.NewGuardrail(patterns, moderator, log)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		audit := security.NewAuditService(log, nil)
+		metrics.RegisterQueue("audit", audit.QueueDepth)
+		metrics.RegisterAuditOverflow(audit.OverflowCounts)
+		return g, audit, audit.Stop, nil
+	}
+	db, err := repository.NewPostgresDB(databaseURL)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	audit := security.NewAuditService(log, security.NewPostgresAuditStorage(repository.NewRepositories(db).Audit))
+	metrics.RegisterQueue("audit", audit.QueueDepth)
+	metrics.RegisterAuditOverflow(audit.OverflowCounts)
+	if path := os.Getenv("AUDIT_OVERFLOW_FILE"); path != "" {
+		if err := audit.SetOverflowFile(path); err != nil {
+			audit.Stop()
+			db.Close()
+			return nil, nil, nil, err
+		}
+	}
+	return g, audit, func() {
+		audit.Stop()
+		db.Close()
+	}, nil
+}
+
+// checkExecutionInput rejects a conversation whose user turns break the
+// agent's guardrails. Earlier turns are checked too, as clients send the
+// whole history.
+func checkExecutionInput(ctx context.Context, agent *Agent, messages []ChatMessage) error {
+	var input []string
+	for _, msg := range messages {
+		if msg.Role == "user" {
+			input = append(input, msg.Content)
+		}
+	}
+	err := security.EnforceInput(ctx, guardrail, guardrailAudit,
+		recordID("org", agent.OrgID), recordID("agent", agent.ID), strings.Join(input, "\n\n"), agent.Guardrails)
+	if err != nil {
+		logger.Warnw("execution rejected by guardrails", "agent", agent.Name, "error", err)
+	}
+	return err
+}
+
+// checkExecutionOutput returns the response to deliver, redacted if the agent
+// is configured to, or an error if it is blocked
+func checkExecutionOutput(ctx context.Context, agent *Agent, response string) (string, error) {
+	checked, err := security.EnforceOutput(ctx, guardrail, guardrailAudit,
+		recordID("org", agent.OrgID), recordID("agent", agent.ID), response, agent.Guardrails)
+	if err != nil {
+		logger.Warnw("response rejected by guardrails", "agent", agent.Name, "error", err)
+	}
+	return checked, err
+}
+
+// guardrailStatus returns the HTTP status for a failed guardrail check:
+// blocked content is unprocessable, while a failed moderation call is the
+// moderation service's fault
+func guardrailStatus(err error) int {
+	var blocked *security.GuardrailError
+	if errors.As(err, &blocked) {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusBadGateway
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	pkglogger "github.com/delphi-platform/delphi/backend/pkg/logger"
+)
+
+// ============================================================================
+// Health
+// ============================================================================
+
+// health checks the dependencies for the readiness probe
+var health *services.HealthService
+
+// newHealthService creates the readiness checks: Postgres when DATABASE_URL
+// is set, Redis when REDIS_URL is set, and the configured providers'
+// reachability. The returned function releases the connections.
+func newHealthService() (*services.HealthService, func(), error) {
+	var repos *repository.Repositories
+	var closers []func()
+	closeAll := func() {
+		for _, close := range closers {
+			close()
+		}
+	}
+
+	if databaseURL := os.Getenv("DATABASE_URL"); databaseURL != "" {
+		db, err := repository.NewPostgresDB(databaseURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		closers = append(closers, db.Close)
+		repos = repository.NewRepositories(db)
+	}
+
+	var redis *repository.RedisClient
+	if redisURL := os.Getenv("REDIS_URL"); redisURL != "" {
+		client, err := repository.NewRedisClient(redisURL)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		closers = append(closers, func() { client.Close() })
+		redis = client
+	}
+
+	svc := services.NewHealthService(repos, redis, pkglogger.New())
+	for name := range providers {
+		svc.AddProvider(name, "")
+	}
+	return svc, closeAll, nil
+}
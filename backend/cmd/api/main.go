@@ -4,70 +4,395 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/delphi-platform/delphi/backend/internal/apierror"
+	"github.com/delphi-platform/delphi/backend/internal/config"
+	internalmiddleware "github.com/delphi-platform/delphi/backend/internal/middleware"
+	agentexec "github.com/delphi-platform/delphi/backend/internal/execution"
+	"github.com/delphi-platform/delphi/backend/internal/metrics"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/openapi"
+	"github.com/delphi-platform/delphi/backend/internal/payload"
+	aiproviders "github.com/delphi-platform/delphi/backend/internal/providers"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/internal/security"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/internal/tracing"
+	pkglogger "github.com/delphi-platform/delphi/backend/pkg/logger"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
 // AI Provider interfaces and implementations
 type AIProvider interface {
-	Complete(ctx context.Context, systemPrompt, userPrompt string) (string, error)
+	// Complete sends the conversation to model, or the provider's default
+	// model if it is empty
+	Complete(ctx context.Context, model, systemPrompt string, messages []ChatMessage, retry RetryPolicy) (*CompletionResult, error)
 	Name() string
 }
 
+// ProviderError is an unsuccessful response from a provider's API, returned
+// once any retries are exhausted
+type ProviderError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("%s API error: %d - %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// CompletionResult is a provider response along with its request metadata
+type CompletionResult struct {
+	Content      string
+	RequestID    string
+	Model        string
+	InputTokens  int
+	OutputTokens int
+}
+
+// costCalculator prices token usage with the shared per-model pricing
+var costCalculator = newCostCalculator()
+
+func newCostCalculator() *aiproviders.CostCalculator {
+	calc := aiproviders.NewCostCalculator()
+	for model, info := range aiproviders.DefaultPricing() {
+		calc.SetPricing(model, info)
+	}
+	return calc
+}
+
+// ChatMessage is a single turn in a conversation sent to a provider
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// maxOutputTokens is the completion budget requested from every provider
+const maxOutputTokens = 4096
+
+// contextWindows lists the context window, in tokens, of the models used by the simple API
+var contextWindows = map[string]int{
+	"gpt-4o":                     128000,
+	"gpt-4o-mini":                128000,
+	"gpt-4-turbo":                128000,
+	"claude-sonnet-4-20250514":   200000,
+	"claude-3-5-sonnet-20241022": 200000,
+	"claude-3-5-haiku-20241022":  200000,
+}
+
+// ModelStatus describes a model and where it is in its provider's lifecycle
+type ModelStatus struct {
+	ID            string     `json:"id"`
+	Provider      string     `json:"provider"`
+	ContextWindow int        `json:"context_window"`
+	Deprecated    bool       `json:"deprecated"`
+	Retired       bool       `json:"retired"`
+	DeprecatedAt  *time.Time `json:"deprecated_at,omitempty"`
+	RetiresAt     *time.Time `json:"retires_at,omitempty"`
+	Successor     string     `json:"successor,omitempty"`
+	Capabilities  []string   `json:"capabilities"`
+}
+
+// modelProvider infers the provider of a known model from its name
+func modelProvider(model string) string {
+	if strings.HasPrefix(model, "claude") {
+		return "anthropic"
+	}
+	return "openai"
+}
+
+func getModelStatus(model string) ModelStatus {
+	status := ModelStatus{
+		ID:            model,
+		Provider:      modelProvider(model),
+		ContextWindow: contextWindows[model],
+		Capabilities:  aiproviders.DefaultPricing()[model].Capabilities,
+	}
+
+	lifecycle, ok := aiproviders.GetModelLifecycle(model)
+	if !ok {
+		return status
+	}
+
+	now := time.Now()
+	if !lifecycle.DeprecatedAt.IsZero() {
+		status.DeprecatedAt = &lifecycle.DeprecatedAt
+		status.Deprecated = !now.Before(lifecycle.DeprecatedAt)
+	}
+	if !lifecycle.RetiresAt.IsZero() {
+		status.RetiresAt = &lifecycle.RetiresAt
+		status.Retired = !now.Before(lifecycle.RetiresAt)
+	}
+	status.Successor = lifecycle.Successor
+	return status
+}
+
+// validateMessages checks roles and content of a conversation history
+func validateMessages(messages []ChatMessage) error {
+	for i, msg := range messages {
+		if msg.Role != "user" && msg.Role != "assistant" {
+			return fmt.Errorf("messages[%d]: role must be 'user' or 'assistant'", i)
+		}
+		if strings.TrimSpace(msg.Content) == "" {
+			return fmt.Errorf("messages[%d]: content is required", i)
+		}
+	}
+	if len(messages) > 0 && messages[len(messages)-1].Role != "user" {
+		return fmt.Errorf("last message must have role 'user'")
+	}
+	return nil
+}
+
+// trimToContextWindow drops the oldest turns until the conversation fits the model's
+// context window. The latest message is always kept, and the history never starts
+// with an assistant turn.
+func trimToContextWindow(model, systemPrompt string, messages []ChatMessage) []ChatMessage {
+	window, ok := contextWindows[model]
+	if !ok {
+		window = 8192
+	}
+	budget := window - maxOutputTokens - aiproviders.CountTokens(model, systemPrompt)
+
+	tokens := make([]int, len(messages))
+	total := 0
+	for i, msg := range messages {
+		tokens[i] = aiproviders.CountTokens(model, msg.Content)
+		total += tokens[i]
+	}
+
+	start := 0
+	for total > budget && start < len(messages)-1 {
+		total -= tokens[start]
+		start++
+	}
+	for start < len(messages)-1 && messages[start].Role != "user" {
+		start++
+	}
+
+	return messages[start:]
+}
+
+// maxThrottleWait is the longest a request waits for a provider quota reset before failing
+const maxThrottleWait = 30 * time.Second
+
+// RetryPolicy controls how transient provider failures are retried. It mirrors
+// AgentConfig.RetryPolicy of the internal models. MaxRetries is nil when unset,
+// so that 0 turns retries off.
+type RetryPolicy struct {
+	MaxRetries   *int `json:"max_retries,omitempty"`
+	BackoffMs    int  `json:"backoff_ms"`
+	MaxBackoffMs int  `json:"max_backoff_ms"`
+}
+
+// defaultMaxRetries is how many times a failed request is retried when the
+// agent doesn't say
+const defaultMaxRetries = 3
+
+// defaultRetryPolicy applies to agents that do not configure their own
+var defaultRetryPolicy = RetryPolicy{BackoffMs: 1000, MaxBackoffMs: 30000}
+
+// retryMinRemaining is the time an attempt needs before the context deadline to be worth making
+const retryMinRemaining = 5 * time.Second
+
+// withDefaults fills unset fields from the default retry policy
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxRetries == nil {
+		maxRetries := defaultMaxRetries
+		p.MaxRetries = &maxRetries
+	}
+	if p.BackoffMs <= 0 {
+		p.BackoffMs = defaultRetryPolicy.BackoffMs
+	}
+	if p.MaxBackoffMs <= 0 {
+		p.MaxBackoffMs = defaultRetryPolicy.MaxBackoffMs
+	}
+	if p.MaxBackoffMs < p.BackoffMs {
+		p.MaxBackoffMs = p.BackoffMs
+	}
+	return p
+}
+
+// retries returns how many times a failed request is retried, none when unset
+func (p RetryPolicy) retries() int {
+	if p.MaxRetries == nil {
+		return 0
+	}
+	return *p.MaxRetries
+}
+
+// isRetryableStatus reports whether a provider response is worth retrying
+func isRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || (code >= 500 && code <= 599)
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(h http.Header) time.Duration {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// newProviderClient returns the client that sends provider requests to
+// endpoint, tracing each one and passing the trace on to the provider
+func newProviderClient(endpoint aiproviders.Endpoint) *http.Client {
+	return &http.Client{Transport: tracing.Transport(endpoint.HTTPClient(0).Transport)}
+}
+
+// sendWithRetry sends the request built by newReq and reads the response body,
+// retrying HTTP 429 and 5xx responses with exponential backoff. Retry-After is
+// honored when present, and retrying stops once the context deadline is too close
+// for another attempt. The last response is returned when retries are exhausted.
+func sendWithRetry(ctx context.Context, client *http.Client, provider string, policy RetryPolicy, newReq func() (*http.Request, error)) (*http.Response, []byte, error) {
+	backoff := time.Duration(policy.BackoffMs) * time.Millisecond
+	maxBackoff := time.Duration(policy.MaxBackoffMs) * time.Millisecond
+
+	for attempt := 0; ; attempt++ {
+		if err := rateLimits.Wait(ctx, provider, maxOutputTokens); err != nil {
+			return nil, nil, err
+		}
+
+		req, err := newReq()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if limit := aiproviders.ParseRateLimitHeaders(resp.Header); limit != nil {
+			rateLimits.Update(provider, limit)
+			if limit.RemainingRequests <= 1 || limit.RemainingTokens < maxOutputTokens {
+				logger.Warnw("provider rate limit nearly exhausted",
+					"provider", provider,
+					"remaining_requests", limit.RemainingRequests,
+					"remaining_tokens", limit.RemainingTokens,
+				)
+			}
+		}
+
+		if !isRetryableStatus(resp.StatusCode) || attempt >= policy.retries() {
+			return resp, body, nil
+		}
+
+		wait := backoff
+		if retryAfter := parseRetryAfter(resp.Header); retryAfter > 0 {
+			wait = retryAfter
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait+retryMinRemaining {
+			return resp, body, nil
+		}
+
+		logger.Warnw("retrying provider request",
+			"provider", provider,
+			"status", resp.StatusCode,
+			"attempt", attempt+1,
+			"max_retries", policy.retries(),
+			"wait", wait,
+		)
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(wait):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
 // OpenAI Provider
 type OpenAIProvider struct {
-	apiKey string
-	model  string
+	apiKey   string
+	model    string
+	endpoint aiproviders.Endpoint
+	client   *http.Client
 }
 
-func NewOpenAIProvider(apiKey, model string) *OpenAIProvider {
+// NewOpenAIProvider creates an OpenAI provider whose requests go to endpoint,
+// or to the public API when endpoint is zero
+func NewOpenAIProvider(apiKey, model string, endpoint aiproviders.Endpoint) *OpenAIProvider {
 	if model == "" {
 		model = "gpt-4o"
 	}
-	return &OpenAIProvider{apiKey: apiKey, model: model}
+	return &OpenAIProvider{apiKey: apiKey, model: model, endpoint: endpoint, client: newProviderClient(endpoint)}
 }
 
 func (p *OpenAIProvider) Name() string { return "openai" }
 
-func (p *OpenAIProvider) Complete(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
-	reqBody := map[string]interface{}{
-		"model": p.model,
-		"messages": []map[string]string{
-			{"role": "system", "content": systemPrompt},
-			{"role": "user", "content": userPrompt},
-		},
-		"max_tokens": 4096,
+func (p *OpenAIProvider) Complete(ctx context.Context, model, systemPrompt string, messages []ChatMessage, retry RetryPolicy) (*CompletionResult, error) {
+	if model == "" {
+		model = p.model
 	}
 
-	jsonBody, _ := json.Marshal(reqBody)
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return "", err
-	}
+	reqMessages := make([]ChatMessage, 0, len(messages)+1)
+	reqMessages = append(reqMessages, ChatMessage{Role: "system", Content: systemPrompt})
+	reqMessages = append(reqMessages, messages...)
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	reqBody := map[string]interface{}{
+		"model":      model,
+		"messages":   reqMessages,
+		"max_tokens": maxOutputTokens,
+	}
 
-	resp, err := http.DefaultClient.Do(req)
+	jsonBody, _ := json.Marshal(reqBody)
+	resp, body, err := sendWithRetry(ctx, p.client, p.Name(), retry, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", p.endpoint.OpenAIChatURL(model), bytes.NewReader(jsonBody))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if p.endpoint.Azure() {
+			req.Header.Set("api-key", p.apiKey)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+p.apiKey)
+		}
+		return req, nil
+	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("OpenAI API error: %s", string(body))
+		return nil, &ProviderError{Provider: p.Name(), StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	var result struct {
@@ -76,92 +401,271 @@ func (p *OpenAIProvider) Complete(ctx context.Context, systemPrompt, userPrompt
 				Content string `json:"content"`
 			} `json:"message"`
 		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
 	}
 	if err := json.Unmarshal(body, &result); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if len(result.Choices) > 0 {
-		return result.Choices[0].Message.Content, nil
+		return &CompletionResult{
+			Content:      result.Choices[0].Message.Content,
+			RequestID:    resp.Header.Get("x-request-id"),
+			Model:        model,
+			InputTokens:  result.Usage.PromptTokens,
+			OutputTokens: result.Usage.CompletionTokens,
+		}, nil
 	}
-	return "", fmt.Errorf("no response from OpenAI")
+	return nil, fmt.Errorf("no response from OpenAI")
 }
 
 // Anthropic Provider
 type AnthropicProvider struct {
-	apiKey string
-	model  string
+	apiKey  string
+	model   string
+	baseURL string
+	client  *http.Client
 }
 
-func NewAnthropicProvider(apiKey, model string) *AnthropicProvider {
+// NewAnthropicProvider creates an Anthropic provider whose requests go to
+// endpoint, or to the public API when endpoint is zero
+func NewAnthropicProvider(apiKey, model string, endpoint aiproviders.Endpoint) *AnthropicProvider {
 	if model == "" {
 		model = "claude-sonnet-4-20250514"
 	}
-	return &AnthropicProvider{apiKey: apiKey, model: model}
+	return &AnthropicProvider{
+		apiKey:  apiKey,
+		model:   model,
+		baseURL: endpoint.URL("https://api.anthropic.com/v1"),
+		client:  newProviderClient(endpoint),
+	}
 }
 
 func (p *AnthropicProvider) Name() string { return "anthropic" }
 
-func (p *AnthropicProvider) Complete(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+func (p *AnthropicProvider) Complete(ctx context.Context, model, systemPrompt string, messages []ChatMessage, retry RetryPolicy) (*CompletionResult, error) {
+	if model == "" {
+		model = p.model
+	}
+
 	reqBody := map[string]interface{}{
-		"model":      p.model,
-		"max_tokens": 4096,
+		"model":      model,
+		"max_tokens": maxOutputTokens,
 		"system":     systemPrompt,
-		"messages": []map[string]string{
-			{"role": "user", "content": userPrompt},
-		},
+		"messages":   messages,
 	}
 
 	jsonBody, _ := json.Marshal(reqBody)
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.anthropic.com/v1/messages", bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return "", err
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", p.apiKey)
-	req.Header.Set("anthropic-version", "2023-06-01")
-
-	resp, err := http.DefaultClient.Do(req)
+	resp, body, err := sendWithRetry(ctx, p.client, p.Name(), retry, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/messages", bytes.NewReader(jsonBody))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-api-key", p.apiKey)
+		req.Header.Set("anthropic-version", "2023-06-01")
+		return req, nil
+	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("Anthropic API error: %s", string(body))
+		return nil, &ProviderError{Provider: p.Name(), StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	var result struct {
 		Content []struct {
 			Text string `json:"text"`
 		} `json:"content"`
+		Usage struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
 	}
 	if err := json.Unmarshal(body, &result); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if len(result.Content) > 0 {
-		return result.Content[0].Text, nil
+		return &CompletionResult{
+			Content:      result.Content[0].Text,
+			RequestID:    resp.Header.Get("request-id"),
+			Model:        model,
+			InputTokens:  result.Usage.InputTokens,
+			OutputTokens: result.Usage.OutputTokens,
+		}, nil
+	}
+	return nil, fmt.Errorf("no response from Anthropic")
+}
+
+// ModelChoice is a provider and one of its models. It mirrors
+// models.ModelChoice of the internal models.
+type ModelChoice struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+}
+
+// ChainAttempt records one model tried while serving an execution
+type ChainAttempt struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Error    string `json:"error,omitempty"`
+}
+
+// modelChain returns the models an agent's executions try, in order: the
+// agent's own model, then its fallback chain
+func modelChain(agent *Agent) []ModelChoice {
+	chain := make([]ModelChoice, 0, len(agent.FallbackChain)+1)
+	chain = append(chain, ModelChoice{Provider: agent.ModelProvider, Model: agent.Model})
+	return append(chain, agent.FallbackChain...)
+}
+
+// validateFallbackChain checks that every entry names a supported provider and
+// a model that has not been retired, returning warnings for deprecated ones
+func validateFallbackChain(chain []ModelChoice) ([]string, error) {
+	var warnings []string
+	for i, choice := range chain {
+		if choice.Provider != "openai" && choice.Provider != "anthropic" {
+			return nil, fmt.Errorf("fallback_chain[%d]: provider must be 'openai' or 'anthropic'", i)
+		}
+		if choice.Model == "" {
+			return nil, fmt.Errorf("fallback_chain[%d]: model is required", i)
+		}
+		warning, err := aiproviders.CheckModel(choice.Model)
+		if err != nil {
+			return nil, fmt.Errorf("fallback_chain[%d]: %w", i, err)
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+	return warnings, nil
+}
+
+// isFallbackError reports whether a failed attempt should move on to the next
+// model in the chain: the provider was rate limited, overloaded or
+// unreachable. Cancellation and errors in the request itself are final.
+func isFallbackError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
 	}
-	return "", fmt.Errorf("no response from Anthropic")
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return isRetryableStatus(providerErr.StatusCode)
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// completeTraced completes with one model of a chain, traced and counted in the provider metrics
+func completeTraced(ctx context.Context, provider AIProvider, choice ModelChoice, agent *Agent, messages []ChatMessage, retry RetryPolicy) (*CompletionResult, error) {
+	ctx, span := tracing.Start(ctx, "provider.complete",
+		attribute.String("delphi.provider", choice.Provider),
+		attribute.String("delphi.model", choice.Model),
+	)
+	start := time.Now()
+	result, err := provider.Complete(ctx, choice.Model, agent.SystemPrompt, trimToContextWindow(choice.Model, agent.SystemPrompt, messages), retry)
+	metrics.ObserveProviderRequest(choice.Provider, "complete", start, err)
+	if result != nil {
+		tracing.RecordUsage(span, result.InputTokens, result.OutputTokens, costCalculator.Calculate(result.Model, aiproviders.TokenUsage{
+			PromptTokens:     result.InputTokens,
+			CompletionTokens: result.OutputTokens,
+			TotalTokens:      result.InputTokens + result.OutputTokens,
+		}))
+	}
+	tracing.End(span, err)
+	return result, err
+}
+
+// completeWithFallback sends the conversation to each model of the agent's
+// chain in turn until one succeeds or fails with an error that falling back
+// would not help. It returns every model tried; the last one without an error
+// served the request.
+func completeWithFallback(ctx context.Context, agent *Agent, messages []ChatMessage) (*CompletionResult, []ChainAttempt, error) {
+	chain := modelChain(agent)
+	attempts := make([]ChainAttempt, 0, len(chain))
+	retry := agent.RetryPolicy.withDefaults()
+
+	var lastErr error
+	for i, choice := range chain {
+		attempt := ChainAttempt{Provider: choice.Provider, Model: choice.Model}
+
+		provider, ok := providers[choice.Provider]
+		if !ok {
+			attempt.Error = "provider not configured"
+			attempts = append(attempts, attempt)
+			lastErr = fmt.Errorf("provider '%s' not configured", choice.Provider)
+			continue
+		}
+		if _, err := aiproviders.CheckModel(choice.Model); err != nil {
+			attempt.Error = err.Error()
+			attempts = append(attempts, attempt)
+			lastErr = err
+			continue
+		}
+
+		result, err := completeTraced(ctx, provider, choice, agent, messages, retry)
+		if err == nil {
+			attempts = append(attempts, attempt)
+			return result, attempts, nil
+		}
+
+		attempt.Error = err.Error()
+		attempts = append(attempts, attempt)
+		lastErr = err
+		if !isFallbackError(ctx, err) {
+			return nil, attempts, err
+		}
+		if i < len(chain)-1 {
+			next := chain[i+1]
+			logger.Warnw("falling back to next model",
+				"agent", agent.Name,
+				"provider", choice.Provider,
+				"model", choice.Model,
+				"next_provider", next.Provider,
+				"next_model", next.Model,
+				"error", err,
+			)
+		}
+	}
+
+	if len(chain) > 1 {
+		return nil, attempts, fmt.Errorf("all %d models in the fallback chain failed: %w", len(chain), lastErr)
+	}
+	return nil, attempts, lastErr
 }
 
 // Agent store (in-memory for now, would be database in production)
 type Agent struct {
-	ID            string    `json:"id"`
-	Name          string    `json:"name"`
-	Description   string    `json:"description"`
-	Purpose       string    `json:"purpose"`
-	Goal          string    `json:"goal"`
-	ModelProvider string    `json:"model_provider"`
-	Model         string    `json:"model"`
-	Status        string    `json:"status"`
-	SystemPrompt  string    `json:"system_prompt"`
-	OrgID         string    `json:"organization_id"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID            string      `json:"id"`
+	Name          string      `json:"name"`
+	Description   string      `json:"description"`
+	Purpose       string      `json:"purpose"`
+	Goal          string      `json:"goal"`
+	ModelProvider string      `json:"model_provider"`
+	Model         string      `json:"model"`
+	Status        string      `json:"status"`
+	SystemPrompt  string      `json:"system_prompt"`
+	OrgID         string      `json:"organization_id"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+	RetryPolicy   RetryPolicy `json:"retry_policy"`
+	Warnings      []string    `json:"warnings,omitempty"`
+
+	// TimeoutSeconds bounds each execution; 0 uses the server default
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+
+	// FallbackChain lists the models tried, in order, when the agent's own
+	// model fails with a retryable error
+	FallbackChain []ModelChoice `json:"fallback_chain,omitempty"`
+
+	// Guardrails block or redact prompts and responses on top of the
+	// platform-wide rules
+	Guardrails *models.GuardrailConfig `json:"guardrails,omitempty"`
 }
 
 type Execution struct {
@@ -169,38 +673,129 @@ type Execution struct {
 	AgentID      string    `json:"agent_id"`
 	AgentName    string    `json:"agent_name"`
 	Prompt       string    `json:"prompt"`
+	MessageCount int       `json:"message_count,omitempty"`
 	Response     string    `json:"response"`
 	Status       string    `json:"status"`
 	Provider     string    `json:"provider"`
 	Model        string    `json:"model"`
+	RequestID    string    `json:"provider_request_id,omitempty"`
+	ModelWarning string    `json:"model_warning,omitempty"`
+	InputTokens  int       `json:"input_tokens"`
+	OutputTokens int       `json:"output_tokens"`
 	TokensUsed   int       `json:"tokens_used"`
 	CostUSD      float64   `json:"cost_usd"`
 	StartTime    time.Time `json:"start_time"`
 	EndTime      time.Time `json:"end_time"`
 	ErrorMessage string    `json:"error_message,omitempty"`
+
+	// Attempts lists the models tried, in order, when the agent has a
+	// fallback chain; Provider and Model are the ones that served the request
+	Attempts []ChainAttempt `json:"attempts,omitempty"`
+
+	// PromptRef and ResponseRef are set when the prompt or response was over
+	// the stored size limit and truncated; the full text is fetched from
+	// /executions/{id}/payload/{prompt|response}
+	PromptRef   string `json:"prompt_ref,omitempty"`
+	ResponseRef string `json:"response_ref,omitempty"`
+
+	// orgID is the organization of the execution's agent
+	orgID string
 }
 
 var (
 	agents     = make(map[string]*Agent)
-	executions = make(map[string]*Execution)
+	agentsMu   sync.RWMutex
+	execStore  executionStore
 	providers  = make(map[string]AIProvider)
+	rateLimits = aiproviders.NewRateLimitTracker(maxThrottleWait)
 	logger     *zap.SugaredLogger
+
+	// streamProviders are the providers that can stream completions
+	streamProviders = make(map[string]aiproviders.Provider)
 )
 
-func initProviders() {
+// defaultOrgID is the organization of the built-in agents, and of every
+// request while authentication is disabled
+const defaultOrgID = "org-1"
+
+// requestOrg returns the organization a request acts for: the authenticated
+// tenant, or the default organization when authentication is disabled
+func requestOrg(r *http.Request) string {
+	if tenantID, ok := internalmiddleware.GetTenantID(r.Context()); ok {
+		return tenantID.String()
+	}
+	return defaultOrgID
+}
+
+// orgAgent returns one of org's agents. Another organization's agent is
+// reported as missing, so its IDs can't be probed.
+func orgAgent(org, id string) (*Agent, bool) {
+	agentsMu.RLock()
+	defer agentsMu.RUnlock()
+	agent, ok := agents[id]
+	if !ok || agent.OrgID != org {
+		return nil, false
+	}
+	return agent, true
+}
+
+// orgAgents returns org's agents
+func orgAgents(org string) []*Agent {
+	agentsMu.RLock()
+	defer agentsMu.RUnlock()
+	list := make([]*Agent, 0, len(agents))
+	for _, agent := range agents {
+		if agent.OrgID == org {
+			list = append(list, agent)
+		}
+	}
+	return list
+}
+
+// initProviders creates the providers whose API keys are set. Their requests
+// go to the endpoints set by OPENAI_BASE_URL, ANTHROPIC_BASE_URL and
+// PROVIDER_PROXY_URL, as in the main services.
+func initProviders() error {
 	openaiKey := os.Getenv("OPENAI_API_KEY")
 	anthropicKey := os.Getenv("ANTHROPIC_API_KEY")
+	endpoints := services.ServerProviderEndpoints(&config.Config{
+		OpenAIBaseURL:     os.Getenv("OPENAI_BASE_URL"),
+		OpenAIAPIVersion:  os.Getenv("OPENAI_API_VERSION"),
+		OpenAIDeployments: os.Getenv("OPENAI_DEPLOYMENTS"),
+		AnthropicBaseURL:  os.Getenv("ANTHROPIC_BASE_URL"),
+		ProviderProxyURL:  os.Getenv("PROVIDER_PROXY_URL"),
+	}, pkglogger.New())
 
 	if openaiKey != "" {
-		providers["openai"] = NewOpenAIProvider(openaiKey, "gpt-4o")
+		endpoint := endpoints[models.ProviderOpenAI]
+		stream, err := aiproviders.NewOpenAIProviderWithEndpoint(openaiKey, endpoint)
+		if err != nil {
+			return fmt.Errorf("openai: %w", err)
+		}
+		providers["openai"] = NewOpenAIProvider(openaiKey, "gpt-4o", endpoint)
+		streamProviders["openai"] = aiproviders.Instrument(stream)
+		providerHealth.addKeyCheck("openai", streamProviders["openai"], openaiKey)
 		logger.Info("OpenAI provider initialized")
 	}
 
 	if anthropicKey != "" {
-		providers["anthropic"] = NewAnthropicProvider(anthropicKey, "claude-sonnet-4-20250514")
+		endpoint := endpoints[models.ProviderAnthropic]
+		stream, err := aiproviders.NewAnthropicProviderWithEndpoint(anthropicKey, endpoint)
+		if err != nil {
+			return fmt.Errorf("anthropic: %w", err)
+		}
+		providers["anthropic"] = NewAnthropicProvider(anthropicKey, "claude-sonnet-4-20250514", endpoint)
+		streamProviders["anthropic"] = aiproviders.Instrument(stream)
+		providerHealth.addKeyCheck("anthropic", streamProviders["anthropic"], anthropicKey)
 		logger.Info("Anthropic provider initialized")
 	}
 
+	// Ollama isn't used for completions here, but its server and local
+	// models are reported with the providers' status
+	if ollamaURL := os.Getenv("OLLAMA_BASE_URL"); ollamaURL != "" {
+		providerHealth.addOllamaCheck(aiproviders.NewOllamaProvider(ollamaURL))
+	}
+
 	// Initialize default agents
 	defaultAgents := []*Agent{
 		{
@@ -213,7 +808,7 @@ func initProviders() {
 			Model:         "gpt-4o",
 			Status:        "ready",
 			SystemPrompt:  "You are an expert software engineer specializing in Go, TypeScript, React, and Python. You write clean, efficient, well-documented code. Always explain your reasoning and provide complete, working solutions.",
-			OrgID:         "org-1",
+			OrgID:         defaultOrgID,
 			CreatedAt:     time.Now(),
 			UpdatedAt:     time.Now(),
 		},
@@ -227,7 +822,7 @@ func initProviders() {
 			Model:         "claude-sonnet-4-20250514",
 			Status:        "ready",
 			SystemPrompt:  "You are a creative marketing specialist with expertise in viral content, social media strategies, and brand storytelling. Create engaging, memorable content that resonates with audiences.",
-			OrgID:         "org-1",
+			OrgID:         defaultOrgID,
 			CreatedAt:     time.Now(),
 			UpdatedAt:     time.Now(),
 		},
@@ -241,7 +836,7 @@ func initProviders() {
 			Model:         "gpt-4o",
 			Status:        "ready",
 			SystemPrompt:  "You are a meticulous financial analyst with expertise in startups, SaaS metrics, and gaming industry economics. Provide data-driven insights and actionable recommendations.",
-			OrgID:         "org-1",
+			OrgID:         defaultOrgID,
 			CreatedAt:     time.Now(),
 			UpdatedAt:     time.Now(),
 		},
@@ -255,7 +850,7 @@ func initProviders() {
 			Model:         "claude-sonnet-4-20250514",
 			Status:        "ready",
 			SystemPrompt:  "You are an expert DevOps engineer with deep knowledge of Kubernetes, Docker, Terraform, GitHub Actions, and cloud platforms (AWS, GCP, Fly.io). Provide production-ready configurations and best practices.",
-			OrgID:         "org-1",
+			OrgID:         defaultOrgID,
 			CreatedAt:     time.Now(),
 			UpdatedAt:     time.Now(),
 		},
@@ -269,7 +864,7 @@ func initProviders() {
 			Model:         "gpt-4o",
 			Status:        "ready",
 			SystemPrompt:  "You are a visionary product manager with experience in mobile games, SaaS, and consumer apps. You think strategically about user needs, market trends, and competitive positioning.",
-			OrgID:         "org-1",
+			OrgID:         defaultOrgID,
 			CreatedAt:     time.Now(),
 			UpdatedAt:     time.Now(),
 		},
@@ -278,6 +873,7 @@ func initProviders() {
 	for _, agent := range defaultAgents {
 		agents[agent.ID] = agent
 	}
+	return nil
 }
 
 func main() {
@@ -292,71 +888,91 @@ func main() {
 		port = "8080"
 	}
 
+	// Initialize tracing
+	shutdownTracing, err := tracing.Init(context.Background(), "delphi-api")
+	if err != nil {
+		logger.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Errorf("Failed to flush traces: %v", err)
+		}
+	}()
+
 	// Initialize AI providers
-	initProviders()
+	if err := initProviders(); err != nil {
+		logger.Fatalf("Failed to initialize providers: %v", err)
+	}
 
-	// Setup router
-	r := chi.NewRouter()
+	// Initialize execution timeouts
+	if err := initRunTimeouts(); err != nil {
+		logger.Fatalf("Failed to initialize execution timeouts: %v", err)
+	}
 
-	// Global middleware
-	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-	r.Use(middleware.Compress(5))
+	// Initialize execution storage
+	store, closeStore, err := newExecutionStore()
+	if err != nil {
+		logger.Fatalf("Failed to initialize execution store: %v", err)
+	}
+	defer closeStore()
+	execStore = store
 
-	// CORS configuration - allow all origins for now
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-Tenant-ID"},
-		ExposedHeaders:   []string{"Link", "X-Request-ID"},
-		AllowCredentials: false,
-		MaxAge:           300,
-	}))
+	// Initialize stored payload limits
+	limiter, err := newPayloadLimiter()
+	if err != nil {
+		logger.Fatalf("Failed to initialize payload storage: %v", err)
+	}
+	payloads = limiter
 
-	// Health check endpoints
-	r.Get("/health", handleHealth)
-	r.Get("/ready", handleReady)
+	// Initialize authentication
+	authSvc, closeAuth, err := newAuthService()
+	if err != nil {
+		logger.Fatalf("Failed to initialize authentication: %v", err)
+	}
+	defer closeAuth()
+	authService = authSvc
+	if authService == nil {
+		logger.Warn("DATABASE_URL not set: authentication is disabled and API routes are open")
+	}
 
-	// API v1 routes
-	r.Route("/api/v1", func(r chi.Router) {
-		// Auth endpoints
-		r.Post("/auth/login", handleLogin)
-		r.Post("/auth/register", handleRegister)
-
-		// Agents
-		r.Get("/agents", handleListAgents)
-		r.Post("/agents", handleCreateAgent)
-		r.Get("/agents/{agentID}", handleGetAgent)
-		r.Patch("/agents/{agentID}", handleUpdateAgent)
-		r.Delete("/agents/{agentID}", handleDeleteAgent)
-		r.Post("/agents/{agentID}/launch", handleLaunchAgent)
-		r.Post("/agents/{agentID}/pause", handlePauseAgent)
-		r.Post("/agents/{agentID}/terminate", handleTerminateAgent)
-
-		// Executions - the main AI interaction endpoint
-		r.Post("/execute", handleExecute)
-		r.Get("/executions", handleListExecutions)
-		r.Get("/executions/{executionID}", handleGetExecution)
-
-		// Dashboard
-		r.Get("/dashboard/overview", handleDashboardOverview)
-
-		// Other endpoints
-		r.Get("/repositories", handleListRepositories)
-		r.Get("/knowledge", handleListKnowledgeBases)
-		r.Get("/businesses", handleListBusinesses)
-		r.Get("/costs/summary", handleCostsSummary)
-
-		// Provider status
-		r.Get("/providers/status", handleProviderStatus)
-	})
+	// Initialize guardrails
+	g, audit, closeGuardrail, err := newGuardrail()
+	if err != nil {
+		logger.Fatalf("Failed to initialize guardrails: %v", err)
+	}
+	defer closeGuardrail()
+	guardrail, guardrailAudit = g, audit
+
+	// Initialize readiness checks
+	healthSvc, closeHealth, err := newHealthService()
+	if err != nil {
+		logger.Fatalf("Failed to initialize health checks: %v", err)
+	}
+	defer closeHealth()
+	health = healthSvc
+
+	// Initialize webhook delivery
+	webhookSvc, runs, closeWebhooks, err := newWebhooks()
+	if err != nil {
+		logger.Fatalf("Failed to initialize webhooks: %v", err)
+	}
+	defer closeWebhooks()
+	webhooks, webhookRuns = webhookSvc, runs
+
+	// Initialize plan rate and concurrency limits
+	limits, slots, closeLimits, err := newRequestLimits()
+	if err != nil {
+		logger.Fatalf("Failed to initialize rate limits: %v", err)
+	}
+	defer closeLimits()
+	requestLimits, runSlots = limits, slots
 
 	// Create server
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%s", port),
-		Handler:      r,
+		Handler:      newRouter(),
 		ReadTimeout:  60 * time.Second,
 		WriteTimeout: 120 * time.Second, // Longer for AI responses
 		IdleTimeout:  120 * time.Second,
@@ -381,13 +997,114 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	// Deferred closes run after this returns, so background work such as
+	// buffered audit entries is flushed even if requests were cut off
 	if err := server.Shutdown(ctx); err != nil {
-		logger.Fatalf("Server forced to shutdown: %v", err)
+		logger.Errorf("Server forced to shutdown: %v", err)
 	}
 
 	logger.Info("Server stopped")
 }
 
+// newRouter creates the API's router. Authentication and permission checks
+// are mounted when authService is set, and rate limits when requestLimits is.
+func newRouter() http.Handler {
+	r := chi.NewRouter()
+
+	// Global middleware
+	r.Use(middleware.RequestID)
+	r.Use(middleware.RealIP)
+	r.Use(tracing.Middleware)
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+	r.Use(middleware.Compress(5))
+
+	// CORS configuration - allow all origins for now
+	r.Use(cors.Handler(cors.Options{
+		AllowedOrigins:   []string{"*"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-Tenant-ID"},
+		ExposedHeaders:   []string{"Link", "X-Request-ID"},
+		AllowCredentials: false,
+		MaxAge:           300,
+	}))
+
+	// Health check endpoints
+	r.Get("/health", handleHealth)
+	r.Get("/ready", handleReady)
+
+	// Prometheus metrics
+	r.Handle("/metrics", metrics.Handler())
+
+	// API v1 routes, described in internal/openapi/openapi.json
+	r.Route("/api/v1", func(r chi.Router) {
+		r.Get("/openapi.json", openapi.Handler)
+
+		// Auth endpoints, limited by client IP
+		r.Group(func(r chi.Router) {
+			if requestLimits != nil {
+				r.Use(internalmiddleware.RateLimit(requestLimits))
+			}
+			r.Post("/auth/login", handleLogin)
+			r.Post("/auth/register", handleRegister)
+			r.Post("/auth/refresh", handleRefresh)
+		})
+
+		r.Group(func(r chi.Router) {
+			if authService != nil {
+				r.Use(internalmiddleware.Authenticate(authService))
+			}
+			// Limits follow authentication so requests count against the tenant
+			if requestLimits != nil {
+				r.Use(internalmiddleware.RateLimit(requestLimits))
+			}
+			if authService != nil {
+				mountMFARoutes(r)
+			}
+
+			read := permit(security.PermAgentRead)
+			create := permit(security.PermAgentCreate)
+			update := permit(security.PermAgentUpdate)
+			remove := permit(security.PermAgentDelete)
+			execute := permit(security.PermAgentExecute)
+
+			// Agents
+			r.With(read).Get("/agents", handleListAgents)
+			r.With(create).Post("/agents", handleCreateAgent)
+			r.With(read).Get("/agents/{agentID}", handleGetAgent)
+			r.With(update).Patch("/agents/{agentID}", handleUpdateAgent)
+			r.With(remove).Delete("/agents/{agentID}", handleDeleteAgent)
+			r.With(execute).Post("/agents/{agentID}/launch", handleLaunchAgent)
+			r.With(execute).Post("/agents/{agentID}/pause", handlePauseAgent)
+			r.With(execute).Post("/agents/{agentID}/terminate", handleTerminateAgent)
+
+			// Executions - the main AI interaction endpoint
+			r.With(execute).Post("/execute", handleExecute)
+			r.With(execute).Post("/execute/stream", handleExecuteStream)
+			r.With(read).Post("/execute/estimate", handleEstimateExecution)
+			r.With(read).Get("/executions", handleListExecutions)
+			r.With(read).Get("/executions/{executionID}", handleGetExecution)
+			r.With(read).Get("/executions/{executionID}/payload/{kind}", handleGetExecutionPayload)
+
+			// Dashboard
+			r.With(read).Get("/dashboard/overview", handleDashboardOverview)
+
+			// Other endpoints
+			r.With(permit(security.PermRepoRead)).Get("/repositories", handleListRepositories)
+			r.With(read).Get("/knowledge", handleListKnowledgeBases)
+			r.With(read).Get("/businesses", handleListBusinesses)
+			r.With(permit(security.PermBillingRead)).Get("/costs/summary", handleCostsSummary)
+
+			// Provider status
+			r.With(permit(security.PermSettingsRead)).Get("/providers/status", handleProviderStatus)
+			r.With(read).Get("/providers/models", handleListModels)
+			r.With(read).Get("/providers/{name}/models", handleListProviderModels)
+		})
+	})
+
+	return r
+}
+
 // ============================================================================
 // Handlers
 // ============================================================================
@@ -400,8 +1117,10 @@ func jsonResponse(w http.ResponseWriter, status int, data interface{}) {
 	}
 }
 
+// jsonError sends an error response, with the code apierror maps the status
+// and message to
 func jsonError(w http.ResponseWriter, status int, message string) {
-	jsonResponse(w, status, map[string]string{"error": message})
+	apierror.Write(w, status, apierror.New(status, message))
 }
 
 func handleHealth(w http.ResponseWriter, r *http.Request) {
@@ -413,66 +1132,84 @@ func handleHealth(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleReady reports 503 unless Postgres and Redis, where configured, are
+// reachable. With ?providers=true the providers' reachability is included,
+// without affecting the status.
 func handleReady(w http.ResponseWriter, r *http.Request) {
-	jsonResponse(w, http.StatusOK, map[string]string{"status": "ready"})
+	readiness := health.Check(r.Context(), r.URL.Query().Get("providers") == "true")
+	status := http.StatusOK
+	if !readiness.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	jsonResponse(w, status, readiness)
 }
 
-func handleLogin(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+func handleListAgents(w http.ResponseWriter, r *http.Request) {
+	jsonResponse(w, http.StatusOK, orgAgents(requestOrg(r)))
+}
+
+// validateAgent checks a new agent's provider, models, system prompt and
+// guardrails, filling in the provider of a known model. Problems that would
+// stop the agent from running are errors; the rest are returned as warnings.
+func validateAgent(agent *Agent) ([]string, error) {
+	if strings.TrimSpace(agent.Name) == "" {
+		return nil, fmt.Errorf("name is required")
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		jsonError(w, http.StatusBadRequest, "Invalid request body")
-		return
+	if agent.Model == "" {
+		return nil, fmt.Errorf("model is required")
 	}
 
-	// For now, accept any credentials (would validate against DB in production)
-	jsonResponse(w, http.StatusOK, map[string]interface{}{
-		"token": "delphi-jwt-token-" + time.Now().Format("20060102150405"),
-		"user": map[string]interface{}{
-			"id":         "user-1",
-			"email":      req.Email,
-			"name":       strings.Split(req.Email, "@")[0],
-			"tenant_id":  "tenant-1",
-			"role":       "admin",
-			"created_at": time.Now().Format(time.RFC3339),
-		},
-	})
-}
-
-func handleRegister(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Name     string `json:"name"`
-		Email    string `json:"email"`
-		Password string `json:"password"`
+	var warnings []string
+	_, known := aiproviders.DefaultPricing()[agent.Model]
+	if agent.ModelProvider == "" {
+		agent.ModelProvider = modelProvider(agent.Model)
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		jsonError(w, http.StatusBadRequest, "Invalid request body")
-		return
+	if agent.ModelProvider != "openai" && agent.ModelProvider != "anthropic" {
+		return nil, fmt.Errorf("model_provider must be 'openai' or 'anthropic'")
+	}
+	if !known {
+		warnings = append(warnings, fmt.Sprintf("model %s is not a known %s model; its limits and cost can't be checked", agent.Model, agent.ModelProvider))
+	} else if provider := modelProvider(agent.Model); provider != agent.ModelProvider {
+		return nil, fmt.Errorf("model %s is served by %s, not %s", agent.Model, provider, agent.ModelProvider)
+	}
+	if _, ok := providers[agent.ModelProvider]; !ok {
+		warnings = append(warnings, fmt.Sprintf("provider %s is not configured; executions will fail until its API key is set", agent.ModelProvider))
 	}
 
-	jsonResponse(w, http.StatusCreated, map[string]interface{}{
-		"token": "delphi-jwt-token-" + time.Now().Format("20060102150405"),
-		"user": map[string]interface{}{
-			"id":         "user-" + time.Now().Format("150405"),
-			"email":      req.Email,
-			"name":       req.Name,
-			"tenant_id":  "tenant-1",
-			"role":       "admin",
-			"created_at": time.Now().Format(time.RFC3339),
-		},
-	})
-}
+	warning, err := aiproviders.CheckModel(agent.Model)
+	if err != nil {
+		return nil, err
+	}
+	if warning != "" {
+		warnings = append(warnings, warning)
+	}
+	chainWarnings, err := validateFallbackChain(agent.FallbackChain)
+	if err != nil {
+		return nil, err
+	}
+	warnings = append(warnings, chainWarnings...)
+	if err := security.ValidateGuardrailConfig(agent.Guardrails); err != nil {
+		return nil, err
+	}
+	if agent.TimeoutSeconds < 0 {
+		return nil, fmt.Errorf("timeout_seconds must not be negative")
+	}
+	if timeout := time.Duration(agent.TimeoutSeconds) * time.Second; runTimeouts.max > 0 && timeout > runTimeouts.max {
+		warnings = append(warnings, fmt.Sprintf("timeout_seconds is over the server's maximum; executions stop after %s", runTimeouts.max))
+	}
 
-func handleListAgents(w http.ResponseWriter, r *http.Request) {
-	agentList := make([]*Agent, 0, len(agents))
-	for _, agent := range agents {
-		agentList = append(agentList, agent)
+	if window := contextWindows[agent.Model]; window > 0 {
+		room := window - maxOutputTokens
+		if tokens := aiproviders.CountTokens(agent.Model, agent.SystemPrompt); tokens > room {
+			return nil, fmt.Errorf("system prompt is %d tokens, over the %d the %s context window has room for", tokens, room, agent.Model)
+		}
 	}
-	jsonResponse(w, http.StatusOK, agentList)
+	return warnings, nil
 }
 
+// handleCreateAgent creates an agent. With ?dry_run=true the agent is only
+// validated: the response is the normalized agent and its warnings, and
+// nothing is saved.
 func handleCreateAgent(w http.ResponseWriter, r *http.Request) {
 	var req Agent
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -480,19 +1217,38 @@ func handleCreateAgent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	warnings, err := validateAgent(&req)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.Warnings = warnings
+
+	if r.URL.Query().Get("dry_run") == "true" {
+		if warnings == nil {
+			warnings = []string{}
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"agent":    req,
+			"warnings": warnings,
+		})
+		return
+	}
+
 	req.ID = fmt.Sprintf("agent-%d", time.Now().UnixNano())
 	req.Status = "configured"
-	req.OrgID = "org-1"
+	req.OrgID = requestOrg(r)
 	req.CreatedAt = time.Now()
 	req.UpdatedAt = time.Now()
 
+	agentsMu.Lock()
 	agents[req.ID] = &req
+	agentsMu.Unlock()
 	jsonResponse(w, http.StatusCreated, req)
 }
 
 func handleGetAgent(w http.ResponseWriter, r *http.Request) {
-	agentID := chi.URLParam(r, "agentID")
-	agent, ok := agents[agentID]
+	agent, ok := orgAgent(requestOrg(r), chi.URLParam(r, "agentID"))
 	if !ok {
 		jsonError(w, http.StatusNotFound, "Agent not found")
 		return
@@ -501,8 +1257,7 @@ func handleGetAgent(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleUpdateAgent(w http.ResponseWriter, r *http.Request) {
-	agentID := chi.URLParam(r, "agentID")
-	agent, ok := agents[agentID]
+	agent, ok := orgAgent(requestOrg(r), chi.URLParam(r, "agentID"))
 	if !ok {
 		jsonError(w, http.StatusNotFound, "Agent not found")
 		return
@@ -530,30 +1285,83 @@ func handleUpdateAgent(w http.ResponseWriter, r *http.Request) {
 	if provider, ok := updates["model_provider"].(string); ok {
 		agent.ModelProvider = provider
 	}
-	if model, ok := updates["model"].(string); ok {
+	if model, ok := updates["model"].(string); ok && model != agent.Model {
+		warning, err := aiproviders.CheckModel(model)
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		agent.Model = model
+		agent.Warnings = nil
+		if warning != "" {
+			agent.Warnings = []string{warning}
+		}
 	}
 	if prompt, ok := updates["system_prompt"].(string); ok {
 		agent.SystemPrompt = prompt
 	}
+	if timeout, ok := updates["timeout_seconds"].(float64); ok {
+		if timeout < 0 {
+			jsonError(w, http.StatusBadRequest, "timeout_seconds must not be negative")
+			return
+		}
+		agent.TimeoutSeconds = int(timeout)
+	}
+	if retry, ok := updates["retry_policy"].(map[string]interface{}); ok {
+		retryJSON, _ := json.Marshal(retry)
+		json.Unmarshal(retryJSON, &agent.RetryPolicy)
+	}
+	if chain, ok := updates["fallback_chain"].([]interface{}); ok {
+		chainJSON, _ := json.Marshal(chain)
+		var fallbackChain []ModelChoice
+		if err := json.Unmarshal(chainJSON, &fallbackChain); err != nil {
+			jsonError(w, http.StatusBadRequest, "fallback_chain must be a list of {provider, model}")
+			return
+		}
+		chainWarnings, err := validateFallbackChain(fallbackChain)
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		agent.FallbackChain = fallbackChain
+		agent.Warnings = nil
+		if warning, _ := aiproviders.CheckModel(agent.Model); warning != "" {
+			agent.Warnings = []string{warning}
+		}
+		agent.Warnings = append(agent.Warnings, chainWarnings...)
+	}
+	if _, ok := updates["guardrails"]; ok {
+		guardrailsJSON, _ := json.Marshal(updates["guardrails"])
+		var guardrails *models.GuardrailConfig
+		if err := json.Unmarshal(guardrailsJSON, &guardrails); err != nil {
+			jsonError(w, http.StatusBadRequest, "guardrails must be an object")
+			return
+		}
+		if err := security.ValidateGuardrailConfig(guardrails); err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		agent.Guardrails = guardrails
+	}
 
 	agent.UpdatedAt = time.Now()
 	jsonResponse(w, http.StatusOK, agent)
 }
 
 func handleDeleteAgent(w http.ResponseWriter, r *http.Request) {
-	agentID := chi.URLParam(r, "agentID")
-	if _, ok := agents[agentID]; !ok {
+	agent, ok := orgAgent(requestOrg(r), chi.URLParam(r, "agentID"))
+	if !ok {
 		jsonError(w, http.StatusNotFound, "Agent not found")
 		return
 	}
-	delete(agents, agentID)
+	agentsMu.Lock()
+	delete(agents, agent.ID)
+	agentsMu.Unlock()
 	jsonResponse(w, http.StatusOK, map[string]string{"message": "Agent deleted"})
 }
 
 func handleLaunchAgent(w http.ResponseWriter, r *http.Request) {
-	agentID := chi.URLParam(r, "agentID")
-	agent, ok := agents[agentID]
+	agent, ok := orgAgent(requestOrg(r), chi.URLParam(r, "agentID"))
 	if !ok {
 		jsonError(w, http.StatusNotFound, "Agent not found")
 		return
@@ -564,8 +1372,7 @@ func handleLaunchAgent(w http.ResponseWriter, r *http.Request) {
 }
 
 func handlePauseAgent(w http.ResponseWriter, r *http.Request) {
-	agentID := chi.URLParam(r, "agentID")
-	agent, ok := agents[agentID]
+	agent, ok := orgAgent(requestOrg(r), chi.URLParam(r, "agentID"))
 	if !ok {
 		jsonError(w, http.StatusNotFound, "Agent not found")
 		return
@@ -576,8 +1383,7 @@ func handlePauseAgent(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleTerminateAgent(w http.ResponseWriter, r *http.Request) {
-	agentID := chi.URLParam(r, "agentID")
-	agent, ok := agents[agentID]
+	agent, ok := orgAgent(requestOrg(r), chi.URLParam(r, "agentID"))
 	if !ok {
 		jsonError(w, http.StatusNotFound, "Agent not found")
 		return
@@ -587,104 +1393,697 @@ func handleTerminateAgent(w http.ResponseWriter, r *http.Request) {
 	jsonResponse(w, http.StatusOK, map[string]string{"message": "Agent terminated", "status": "terminated"})
 }
 
-// handleExecute - The main AI execution endpoint
-func handleExecute(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		AgentID string `json:"agent_id"`
-		Prompt  string `json:"prompt"`
+// executeRequest is the body of the execute endpoints
+type executeRequest struct {
+	AgentID  string        `json:"agent_id"`
+	Prompt   string        `json:"prompt"`
+	Messages []ChatMessage `json:"messages,omitempty"`
+}
+
+// prepareExecution validates an execute request and resolves its agent, which
+// must belong to org. It returns the conversation to send, trimmed to the model's context window, and
+// any deprecation warning for the agent's model. On failure it returns the
+// HTTP status to respond with.
+func prepareExecution(org string, req executeRequest) (*Agent, []ChatMessage, string, int, error) {
+	if req.AgentID == "" || (req.Prompt == "" && len(req.Messages) == 0) {
+		return nil, nil, "", http.StatusBadRequest, fmt.Errorf("agent_id and prompt or messages are required")
+	}
+
+	// The prompt, when given, is the newest user turn of the conversation
+	messages := req.Messages
+	if req.Prompt != "" {
+		messages = append(messages, ChatMessage{Role: "user", Content: req.Prompt})
+	}
+	if err := validateMessages(messages); err != nil {
+		return nil, nil, "", http.StatusBadRequest, err
+	}
+
+	agent, ok := orgAgent(org, req.AgentID)
+	if !ok {
+		return nil, nil, "", http.StatusNotFound, fmt.Errorf("Agent not found")
+	}
+
+	if _, ok := providers[agent.ModelProvider]; !ok {
+		return nil, nil, "", http.StatusBadRequest, fmt.Errorf("Provider '%s' not configured. Please set %s_API_KEY environment variable.", agent.ModelProvider, strings.ToUpper(agent.ModelProvider))
 	}
+
+	modelWarning, err := aiproviders.CheckModel(agent.Model)
+	if err != nil {
+		return nil, nil, "", http.StatusBadRequest, err
+	}
+	if modelWarning != "" {
+		logger.Warnw("agent uses a deprecated model", "agent", agent.Name, "model", agent.Model, "warning", modelWarning)
+	}
+
+	return agent, trimToContextWindow(agent.Model, agent.SystemPrompt, messages), modelWarning, 0, nil
+}
+
+// storeTimeout bounds execution store writes, which must also succeed after the
+// request context has been cancelled
+const storeTimeout = 5 * time.Second
+
+// startExecution records a running execution and marks its agent as executing.
+// A prompt over the stored size limit is recorded truncated.
+func startExecution(ctx context.Context, agent *Agent, messages []ChatMessage, modelWarning string) (*Execution, error) {
+	// The execution's ID is assigned by the store, so its prompt is keyed separately
+	prompt, promptRef, err := payloads.LimitPrompt(ctx, "executions/"+uuid.NewString(), messages[len(messages)-1].Content)
+	if err != nil {
+		return nil, err
+	}
+	execution := &Execution{
+		AgentID:      agent.ID,
+		AgentName:    agent.Name,
+		Prompt:       prompt,
+		PromptRef:    promptRef,
+		MessageCount: len(messages),
+		Status:       "running",
+		Provider:     agent.ModelProvider,
+		Model:        agent.Model,
+		ModelWarning: modelWarning,
+		StartTime:    time.Now(),
+	}
+	if err := execStore.Create(ctx, agent, execution); err != nil {
+		return nil, err
+	}
+
+	agent.Status = "executing"
+	return execution, nil
+}
+
+// finishExecution records the final state of an execution, counts it in the
+// execution metrics and adds its usage to the request's span. The simple API
+// has no tenant plans, so executions are counted under the unknown tier. The
+// result is stored even if ctx has been cancelled.
+func finishExecution(ctx context.Context, execution *Execution) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), storeTimeout)
+	defer cancel()
+
+	metrics.RecordExecution(execution.Status, execution.Provider, metrics.TierUnknown, execution.InputTokens, execution.OutputTokens, execution.CostUSD)
+
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(
+		attribute.String("delphi.execution_id", execution.ID),
+		attribute.String("delphi.execution.status", execution.Status),
+		attribute.String("delphi.provider", execution.Provider),
+		attribute.String("delphi.model", execution.Model),
+	)
+	tracing.RecordUsage(span, execution.InputTokens, execution.OutputTokens, execution.CostUSD)
+	if execution.Status == "failed" {
+		span.SetStatus(codes.Error, execution.ErrorMessage)
+	}
+
+	var err error
+	if execution.Status == "completed" {
+		// Token usage and cost were already taken from the full response. If
+		// it cannot be stored separately, it is kept whole rather than lost.
+		response, ref, limitErr := payloads.LimitResponse(ctx, "executions/"+execution.ID, execution.Response)
+		if limitErr != nil {
+			logger.Errorw("failed to store full response", "execution_id", execution.ID, "error", limitErr)
+		} else {
+			execution.Response, execution.ResponseRef = response, ref
+		}
+		err = execStore.Complete(ctx, execution)
+	} else {
+		err = execStore.Fail(ctx, execution)
+	}
+	if err != nil {
+		logger.Errorw("failed to record execution result", "execution_id", execution.ID, "status", execution.Status, "error", err)
+		return
+	}
+	publishExecution(ctx, execution)
+}
+
+// handleEstimateExecution returns the input tokens and projected cost of an
+// execute request without running it. The projected cost assumes the whole
+// output budget is used, so it is an upper bound.
+func handleEstimateExecution(w http.ResponseWriter, r *http.Request) {
+	var req executeRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		jsonError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	if req.AgentID == "" || req.Prompt == "" {
-		jsonError(w, http.StatusBadRequest, "agent_id and prompt are required")
+	agent, messages, modelWarning, status, err := prepareExecution(requestOrg(r), req)
+	if err != nil {
+		jsonError(w, status, err.Error())
 		return
 	}
 
-	agent, ok := agents[req.AgentID]
-	if !ok {
-		jsonError(w, http.StatusNotFound, "Agent not found")
+	input := aiproviders.CountTokens(agent.Model, agent.SystemPrompt)
+	for _, msg := range messages {
+		input += aiproviders.CountTokens(agent.Model, msg.Content)
+	}
+	inputCost := costCalculator.Calculate(agent.Model, aiproviders.TokenUsage{PromptTokens: input, TotalTokens: input})
+	projectedCost := costCalculator.Calculate(agent.Model, aiproviders.TokenUsage{
+		PromptTokens:     input,
+		CompletionTokens: maxOutputTokens,
+		TotalTokens:      input + maxOutputTokens,
+	})
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"agent_id":           agent.ID,
+		"provider":           agent.ModelProvider,
+		"model":              agent.Model,
+		"model_warning":      modelWarning,
+		"message_count":      len(messages),
+		"input_tokens":       input,
+		"max_output_tokens":  maxOutputTokens,
+		"input_cost_usd":     inputCost,
+		"projected_cost_usd": projectedCost,
+	})
+}
+
+// runTimeouts bound executions: agents without a timeout_seconds get def, and
+// no execution may run past max
+var runTimeouts struct{ def, max time.Duration }
+
+// writeDeadlineMargin is how long a blocking execution's response may take to
+// write once the execution has ended
+const writeDeadlineMargin = 10 * time.Second
+
+// initRunTimeouts reads the execution timeouts, in seconds, from
+// DEFAULT_RUN_TIMEOUT_SECONDS (300 by default) and MAX_RUN_TIMEOUT_SECONDS
+// (1800 by default)
+func initRunTimeouts() error {
+	def, err := envInt("DEFAULT_RUN_TIMEOUT_SECONDS", 300)
+	if err != nil {
+		return err
+	}
+	max, err := envInt("MAX_RUN_TIMEOUT_SECONDS", 1800)
+	if err != nil {
+		return err
+	}
+	runTimeouts.def = time.Duration(def) * time.Second
+	runTimeouts.max = time.Duration(max) * time.Second
+	return nil
+}
+
+// executionTimeout returns how long one of the agent's executions may run
+func executionTimeout(agent *Agent) time.Duration {
+	return agentexec.RunTimeout(agent.TimeoutSeconds, runTimeouts.def, runTimeouts.max)
+}
+
+// handleExecute - The main AI execution endpoint
+func handleExecute(w http.ResponseWriter, r *http.Request) {
+	var req executeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	// Get the appropriate provider
-	provider, ok := providers[agent.ModelProvider]
-	if !ok {
-		jsonError(w, http.StatusBadRequest, fmt.Sprintf("Provider '%s' not configured. Please set %s_API_KEY environment variable.", agent.ModelProvider, strings.ToUpper(agent.ModelProvider)))
+	agent, messages, modelWarning, status, err := prepareExecution(requestOrg(r), req)
+	if err != nil {
+		jsonError(w, status, err.Error())
+		return
+	}
+	if err := checkExecutionInput(r.Context(), agent, messages); err != nil {
+		jsonError(w, guardrailStatus(err), err.Error())
 		return
 	}
+	slot := acquireRunSlot(w, r)
+	if slot == nil {
+		return
+	}
+	defer slot.Release()
 
-	// Create execution record
-	execution := &Execution{
-		ID:        fmt.Sprintf("exec-%d", time.Now().UnixNano()),
-		AgentID:   agent.ID,
-		AgentName: agent.Name,
-		Prompt:    req.Prompt,
-		Status:    "running",
-		Provider:  agent.ModelProvider,
-		Model:     agent.Model,
-		StartTime: time.Now(),
-	}
-	executions[execution.ID] = execution
-
-	// Update agent status
-	agent.Status = "executing"
+	execution, err := startExecution(r.Context(), agent, messages, modelWarning)
+	if err != nil {
+		var exceeded *repository.BudgetExceededError
+		if errors.As(err, &exceeded) {
+			jsonError(w, http.StatusPaymentRequired, fmt.Sprintf("Budget exceeded: %v", exceeded))
+			return
+		}
+		logger.Errorw("failed to record execution", "agent", agent.Name, "error", err)
+		jsonError(w, http.StatusInternalServerError, "Failed to record execution")
+		return
+	}
 
-	// Call AI provider
-	ctx, cancel := context.WithTimeout(r.Context(), 120*time.Second)
+	// Call AI provider, for no longer than the agent's timeout. The response
+	// may be written past the server's write timeout, which is sized for the
+	// default.
+	timeout := executionTimeout(agent)
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout + writeDeadlineMargin))
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
 	defer cancel()
 
-	response, err := provider.Complete(ctx, agent.SystemPrompt, req.Prompt)
+	result, attempts, err := completeWithFallback(ctx, agent, messages)
 	execution.EndTime = time.Now()
+	if len(agent.FallbackChain) > 0 {
+		execution.Attempts = attempts
+	}
 
 	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && r.Context().Err() == nil {
+			err = agentexec.TimeoutError(timeout)
+			status = http.StatusGatewayTimeout
+		}
 		execution.Status = "failed"
 		execution.ErrorMessage = err.Error()
 		agent.Status = "error"
-		logger.Errorw("AI execution failed", "agent", agent.Name, "error", err)
-		jsonError(w, http.StatusInternalServerError, fmt.Sprintf("AI execution failed: %v", err))
+		finishExecution(ctx, execution)
+		logger.Errorw("AI execution failed", "agent", agent.Name, "attempts", attempts, "error", err)
+		jsonError(w, status, fmt.Sprintf("AI execution failed: %v", err))
+		return
+	}
+
+	// Cost is attributed to the model that served the request, whether or
+	// not its response gets through the guardrails
+	served := attempts[len(attempts)-1]
+	execution.Provider = served.Provider
+	execution.Model = result.Model
+	execution.RequestID = result.RequestID
+	execution.InputTokens = result.InputTokens
+	execution.OutputTokens = result.OutputTokens
+	execution.TokensUsed = result.InputTokens + result.OutputTokens
+	execution.CostUSD = costCalculator.Calculate(result.Model, aiproviders.TokenUsage{
+		PromptTokens:     result.InputTokens,
+		CompletionTokens: result.OutputTokens,
+		TotalTokens:      execution.TokensUsed,
+	})
+
+	response, err := checkExecutionOutput(ctx, agent, result.Content)
+	if err != nil {
+		execution.Status = "failed"
+		execution.ErrorMessage = err.Error()
+		agent.Status = "ready"
+		finishExecution(ctx, execution)
+		jsonError(w, guardrailStatus(err), err.Error())
 		return
 	}
 
 	execution.Status = "completed"
 	execution.Response = response
-	execution.TokensUsed = len(response) / 4 // Rough estimate
-	execution.CostUSD = float64(execution.TokensUsed) * 0.00003 // Rough estimate
 
 	agent.Status = "ready"
-
-	logger.Infow("AI execution completed", "agent", agent.Name, "tokens", execution.TokensUsed)
+	finishExecution(ctx, execution)
+
+	logger.Infow("AI execution completed",
+		"agent", agent.Name,
+		"provider", execution.Provider,
+		"model", result.Model,
+		"fallback", len(attempts) > 1,
+		"input_tokens", execution.InputTokens,
+		"output_tokens", execution.OutputTokens,
+		"cost_usd", execution.CostUSD,
+		"request_id", execution.RequestID,
+	)
 
 	jsonResponse(w, http.StatusOK, execution)
 }
 
+// streamWithFallback starts streaming the conversation from each model of the
+// agent's chain in turn until a stream starts. Only starting a stream falls
+// back; once text has been sent to the client, a failure ends the execution.
+// The shared providers don't classify their errors, so any failure to start
+// other than cancellation moves on to the next model.
+func streamWithFallback(ctx context.Context, agent *Agent, messages []ChatMessage) (<-chan aiproviders.StreamChunk, []ChainAttempt, error) {
+	chain := modelChain(agent)
+	attempts := make([]ChainAttempt, 0, len(chain))
+
+	var lastErr error
+	for _, choice := range chain {
+		attempt := ChainAttempt{Provider: choice.Provider, Model: choice.Model}
+
+		provider, ok := streamProviders[choice.Provider]
+		if !ok {
+			attempt.Error = "provider does not support streaming"
+			attempts = append(attempts, attempt)
+			lastErr = fmt.Errorf("provider '%s' does not support streaming", choice.Provider)
+			continue
+		}
+		if _, err := aiproviders.CheckModel(choice.Model); err != nil {
+			attempt.Error = err.Error()
+			attempts = append(attempts, attempt)
+			lastErr = err
+			continue
+		}
+
+		trimmed := trimToContextWindow(choice.Model, agent.SystemPrompt, messages)
+		streamReq := &aiproviders.CompletionRequest{
+			Model:     choice.Model,
+			Messages:  make([]aiproviders.Message, 0, len(trimmed)+1),
+			MaxTokens: maxOutputTokens,
+		}
+		streamReq.Messages = append(streamReq.Messages, aiproviders.Message{Role: "system", Content: agent.SystemPrompt})
+		for _, msg := range trimmed {
+			streamReq.Messages = append(streamReq.Messages, aiproviders.Message{Role: msg.Role, Content: msg.Content})
+		}
+
+		chunks, err := provider.Stream(ctx, streamReq)
+		if err == nil {
+			attempts = append(attempts, attempt)
+			return chunks, attempts, nil
+		}
+
+		attempt.Error = err.Error()
+		attempts = append(attempts, attempt)
+		lastErr = err
+		if ctx.Err() != nil {
+			return nil, attempts, err
+		}
+		logger.Warnw("stream failed to start, trying next model",
+			"agent", agent.Name,
+			"provider", choice.Provider,
+			"model", choice.Model,
+			"error", err,
+		)
+	}
+
+	if len(chain) > 1 {
+		return nil, attempts, fmt.Errorf("all %d models in the fallback chain failed: %w", len(chain), lastErr)
+	}
+	return nil, attempts, lastErr
+}
+
+// handleExecuteStream runs an execution like handleExecute but streams the
+// response as Server-Sent Events: a "start" event, a "delta" event per chunk of
+// text, then a "done" event with the finish reason and token usage, or an
+// "error" event.
+func handleExecuteStream(w http.ResponseWriter, r *http.Request) {
+	var req executeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	agent, messages, modelWarning, status, err := prepareExecution(requestOrg(r), req)
+	if err != nil {
+		jsonError(w, status, err.Error())
+		return
+	}
+	if err := checkExecutionInput(r.Context(), agent, messages); err != nil {
+		jsonError(w, guardrailStatus(err), err.Error())
+		return
+	}
+	if _, ok := streamProviders[agent.ModelProvider]; !ok {
+		jsonError(w, http.StatusBadRequest, fmt.Sprintf("Provider '%s' does not support streaming; use POST /api/v1/execute instead", agent.ModelProvider))
+		return
+	}
+
+	// The execution is recorded, and the budget and concurrency checked,
+	// before the stream starts, so an over-budget tenant gets a 402 and a busy
+	// one a 429 rather than an error event
+	slot := acquireRunSlot(w, r)
+	if slot == nil {
+		return
+	}
+	defer slot.Release()
+
+	execution, err := startExecution(r.Context(), agent, messages, modelWarning)
+	if err != nil {
+		var exceeded *repository.BudgetExceededError
+		if errors.As(err, &exceeded) {
+			jsonError(w, http.StatusPaymentRequired, fmt.Sprintf("Budget exceeded: %v", exceeded))
+			return
+		}
+		logger.Errorw("failed to record execution", "agent", agent.Name, "error", err)
+		jsonError(w, http.StatusInternalServerError, "Failed to record execution")
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// Streams outlive the server's write timeout, which is sized for blocking requests
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event string, data interface{}) error {
+		payload, _ := json.Marshal(data)
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	// The request context is cancelled when the client disconnects, which
+	// stops the provider stream, as does reaching the agent's timeout
+	timeout := executionTimeout(agent)
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	fail := func(err error) {
+		execution.EndTime = time.Now()
+		execution.Status = "failed"
+		execution.ErrorMessage = err.Error()
+		agent.Status = "error"
+		finishExecution(ctx, execution)
+		logger.Errorw("AI streaming execution failed", "agent", agent.Name, "execution_id", execution.ID, "error", err)
+		send("error", map[string]string{"execution_id": execution.ID, "error": fmt.Sprintf("AI execution failed: %v", err)})
+	}
+
+	chunks, attempts, err := streamWithFallback(ctx, agent, messages)
+	if len(agent.FallbackChain) > 0 {
+		execution.Attempts = attempts
+	}
+	if err != nil {
+		fail(err)
+		return
+	}
+	// Cost is attributed to the model that served the stream
+	served := attempts[len(attempts)-1]
+	execution.Provider = served.Provider
+	execution.Model = served.Model
+
+	// Keep draining after an early return so the provider goroutine can exit
+	defer func() {
+		go func() {
+			for range chunks {
+			}
+		}()
+	}()
+
+	start := map[string]string{"execution_id": execution.ID, "agent_id": agent.ID, "provider": execution.Provider, "model": execution.Model}
+	if modelWarning != "" {
+		start["model_warning"] = modelWarning
+	}
+	if err := send("start", start); err != nil {
+		fail(err)
+		return
+	}
+
+	var response strings.Builder
+	var finishReason string
+	var usage aiproviders.TokenUsage
+
+	// A response that may be blocked or redacted can only be checked once
+	// complete, so it is held back and sent as a single delta
+	holdOutput := guardrail.ChecksOutput(agent.Guardrails)
+
+	for {
+		select {
+		case <-ctx.Done():
+			if r.Context().Err() == nil {
+				fail(agentexec.TimeoutError(timeout))
+				return
+			}
+			fail(fmt.Errorf("client disconnected: %w", ctx.Err()))
+			return
+		case chunk, ok := <-chunks:
+			if !ok {
+				execution.EndTime = time.Now()
+				execution.InputTokens = usage.PromptTokens
+				execution.OutputTokens = usage.CompletionTokens
+				execution.TokensUsed = usage.PromptTokens + usage.CompletionTokens
+				execution.CostUSD = costCalculator.Calculate(execution.Model, usage)
+
+				output := response.String()
+				if holdOutput {
+					output, err = checkExecutionOutput(ctx, agent, output)
+					if err != nil {
+						execution.Status = "failed"
+						execution.ErrorMessage = err.Error()
+						finishExecution(ctx, execution)
+						send("error", map[string]string{"execution_id": execution.ID, "error": err.Error()})
+						return
+					}
+					if output != "" {
+						if err := send("delta", map[string]string{"delta": output}); err != nil {
+							fail(fmt.Errorf("client disconnected: %w", err))
+							return
+						}
+					}
+				}
+
+				execution.Status = "completed"
+				execution.Response = output
+				agent.Status = "ready"
+				finishExecution(ctx, execution)
+
+				logger.Infow("AI streaming execution completed",
+					"agent", agent.Name,
+					"execution_id", execution.ID,
+					"provider", execution.Provider,
+					"model", execution.Model,
+					"finish_reason", finishReason,
+					"input_tokens", execution.InputTokens,
+					"output_tokens", execution.OutputTokens,
+					"cost_usd", execution.CostUSD,
+				)
+
+				send("done", map[string]interface{}{
+					"execution_id":  execution.ID,
+					"finish_reason": finishReason,
+					"usage": map[string]int{
+						"input_tokens":  execution.InputTokens,
+						"output_tokens": execution.OutputTokens,
+						"total_tokens":  execution.TokensUsed,
+					},
+					"cost_usd": execution.CostUSD,
+				})
+				return
+			}
+
+			if chunk.Error != nil {
+				fail(chunk.Error)
+				return
+			}
+			if chunk.FinishReason != "" {
+				finishReason = chunk.FinishReason
+			}
+			if chunk.Usage != nil {
+				usage = *chunk.Usage
+			}
+			if chunk.Delta == "" {
+				continue
+			}
+
+			response.WriteString(chunk.Delta)
+			if holdOutput {
+				continue
+			}
+			if err := send("delta", map[string]string{"delta": chunk.Delta}); err != nil {
+				fail(fmt.Errorf("client disconnected: %w", err))
+				return
+			}
+		}
+	}
+}
+
+// Execution listing page sizes
+const (
+	defaultExecutionPageSize = 50
+	maxExecutionPageSize     = 200
+)
+
 func handleListExecutions(w http.ResponseWriter, r *http.Request) {
-	execList := make([]*Execution, 0, len(executions))
-	for _, exec := range executions {
-		execList = append(execList, exec)
+	limit := defaultExecutionPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l < 1 {
+			jsonError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = l
+	}
+	if limit > maxExecutionPageSize {
+		limit = maxExecutionPageSize
 	}
-	jsonResponse(w, http.StatusOK, execList)
+
+	page, err := execStore.Page(r.Context(), requestOrg(r), r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			jsonError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		logger.Errorw("failed to list executions", "error", err)
+		jsonError(w, http.StatusInternalServerError, "Failed to list executions")
+		return
+	}
+	jsonResponse(w, http.StatusOK, page)
 }
 
 func handleGetExecution(w http.ResponseWriter, r *http.Request) {
 	execID := chi.URLParam(r, "executionID")
-	exec, ok := executions[execID]
-	if !ok {
+	exec, err := execStore.Get(r.Context(), requestOrg(r), execID)
+	if err != nil {
+		logger.Errorw("failed to get execution", "execution_id", execID, "error", err)
+		jsonError(w, http.StatusInternalServerError, "Failed to get execution")
+		return
+	}
+	if exec == nil {
 		jsonError(w, http.StatusNotFound, "Execution not found")
 		return
 	}
 	jsonResponse(w, http.StatusOK, exec)
 }
 
+// handleGetExecutionPayload returns an execution's full prompt or response,
+// which the execution itself holds only truncated when it was over the stored
+// size limit
+func handleGetExecutionPayload(w http.ResponseWriter, r *http.Request) {
+	execID := chi.URLParam(r, "executionID")
+	kind := chi.URLParam(r, "kind")
+	if kind != "prompt" && kind != "response" {
+		jsonError(w, http.StatusBadRequest, "payload must be prompt or response")
+		return
+	}
+
+	exec, err := execStore.Get(r.Context(), requestOrg(r), execID)
+	if err != nil {
+		logger.Errorw("failed to get execution", "execution_id", execID, "error", err)
+		jsonError(w, http.StatusInternalServerError, "Failed to get execution")
+		return
+	}
+	if exec == nil {
+		jsonError(w, http.StatusNotFound, "Execution not found")
+		return
+	}
+
+	content, ref := exec.Prompt, exec.PromptRef
+	if kind == "response" {
+		content, ref = exec.Response, exec.ResponseRef
+	}
+	if ref != "" {
+		content, err = payloads.Get(r.Context(), ref)
+		if errors.Is(err, payload.ErrNotFound) {
+			jsonError(w, http.StatusNotFound, "Full payload is no longer available")
+			return
+		}
+		if err != nil {
+			logger.Errorw("failed to get execution payload", "execution_id", execID, "ref", ref, "error", err)
+			jsonError(w, http.StatusInternalServerError, "Failed to get payload")
+			return
+		}
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"execution_id": exec.ID,
+		"kind":         kind,
+		"content":      content,
+	})
+}
+
 func handleProviderStatus(w http.ResponseWriter, r *http.Request) {
+	health := providerHealth.status(r.Context())
+
 	status := make(map[string]interface{})
 	for name := range providers {
-		status[name] = map[string]interface{}{
+		entry := map[string]interface{}{
 			"configured": true,
 			"name":       name,
 		}
+		if limit, ok := rateLimits.Get(name); ok {
+			entry["rate_limit"] = limit
+		}
+		if deprecated := deprecatedModels(name); len(deprecated) > 0 {
+			entry["deprecated_models"] = deprecated
+		}
+		if h, ok := health[name]; ok {
+			entry["health"] = h
+		}
+		status[name] = entry
+	}
+	if h, ok := health["ollama"]; ok {
+		status["ollama"] = map[string]interface{}{
+			"configured": true,
+			"name":       "ollama",
+			"health":     h,
+		}
 	}
 
 	// Check for unconfigured providers
@@ -704,16 +2103,102 @@ func handleProviderStatus(w http.ResponseWriter, r *http.Request) {
 	jsonResponse(w, http.StatusOK, status)
 }
 
+// deprecatedModels returns the deprecated or retired models of a provider
+func deprecatedModels(provider string) []ModelStatus {
+	var deprecated []ModelStatus
+	for model := range contextWindows {
+		status := getModelStatus(model)
+		if status.Provider == provider && (status.Deprecated || status.Retired) {
+			deprecated = append(deprecated, status)
+		}
+	}
+	sort.Slice(deprecated, func(i, j int) bool { return deprecated[i].ID < deprecated[j].ID })
+	return deprecated
+}
+
+// handleListModels lists the known models with their deprecation status
+func handleListModels(w http.ResponseWriter, r *http.Request) {
+	models := make([]ModelStatus, 0, len(contextWindows))
+	for model := range contextWindows {
+		models = append(models, getModelStatus(model))
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].ID < models[j].ID })
+	jsonResponse(w, http.StatusOK, models)
+}
+
+// handleListProviderModels lists a provider's models, keeping those with every
+// capability given in the capability query parameter, which may be repeated or
+// comma-separated. Ollama lists the models last found on its server.
+func handleListProviderModels(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	var required []string
+	for _, value := range r.URL.Query()["capability"] {
+		for _, capability := range strings.Split(value, ",") {
+			capability = strings.TrimSpace(capability)
+			if capability == "" {
+				continue
+			}
+			if !aiproviders.IsCapability(capability) {
+				jsonError(w, http.StatusBadRequest, fmt.Sprintf("unknown capability: %s", capability))
+				return
+			}
+			required = append(required, capability)
+		}
+	}
+
+	var candidates []ModelStatus
+	switch name {
+	case "openai", "anthropic":
+		for model := range contextWindows {
+			if status := getModelStatus(model); status.Provider == name {
+				candidates = append(candidates, status)
+			}
+		}
+	case "ollama":
+		health, ok := providerHealth.status(r.Context())["ollama"]
+		if !ok {
+			jsonError(w, http.StatusNotFound, "OLLAMA_BASE_URL not set")
+			return
+		}
+		for _, model := range health.Models {
+			candidates = append(candidates, ModelStatus{ID: model, Provider: name, Capabilities: []string{aiproviders.CapabilityText}})
+		}
+	default:
+		jsonError(w, http.StatusNotFound, "provider not found")
+		return
+	}
+
+	models := make([]ModelStatus, 0, len(candidates))
+	for _, model := range candidates {
+		info := aiproviders.ModelInfo{Capabilities: model.Capabilities}
+		if info.HasCapabilities(required...) {
+			models = append(models, model)
+		}
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].ID < models[j].ID })
+	jsonResponse(w, http.StatusOK, models)
+}
+
 func handleDashboardOverview(w http.ResponseWriter, r *http.Request) {
 	// Calculate real stats
-	totalAgents := len(agents)
+	org := requestOrg(r)
+	orgAgentList := orgAgents(org)
+	totalAgents := len(orgAgentList)
 	activeAgents := 0
-	for _, agent := range agents {
+	for _, agent := range orgAgentList {
 		if agent.Status == "ready" || agent.Status == "executing" {
 			activeAgents++
 		}
 	}
 
+	executions, err := execStore.List(r.Context(), org)
+	if err != nil {
+		logger.Errorw("failed to list executions", "error", err)
+		jsonError(w, http.StatusInternalServerError, "Failed to load executions")
+		return
+	}
+
 	totalExecutions := len(executions)
 	var totalCost float64
 	var totalTokens int
@@ -758,6 +2243,13 @@ func handleListBusinesses(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleCostsSummary(w http.ResponseWriter, r *http.Request) {
+	executions, err := execStore.List(r.Context(), requestOrg(r))
+	if err != nil {
+		logger.Errorw("failed to list executions", "error", err)
+		jsonError(w, http.StatusInternalServerError, "Failed to load executions")
+		return
+	}
+
 	var totalCost float64
 	var totalTokens int
 	for _, exec := range executions {
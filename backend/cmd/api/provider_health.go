@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	aiproviders "github.com/delphi-platform/delphi/backend/internal/providers"
+)
+
+// ============================================================================
+// Provider Health
+// ============================================================================
+
+const (
+	// providerHealthTTL is how long a provider's check is reused, so polling
+	// the status doesn't send a request to every provider each time
+	providerHealthTTL = 30 * time.Second
+
+	// providerHealthTimeout bounds each provider's check
+	providerHealthTimeout = 5 * time.Second
+)
+
+// ProviderHealth is the outcome of checking a provider's key against its API.
+// Valid is false once the provider rejects the key; other failures, such as
+// an outage or a rate limit, say nothing about the key and keep the last
+// verdict.
+type ProviderHealth struct {
+	Reachable bool      `json:"reachable"`
+	Valid     bool      `json:"valid"`
+	LatencyMs int64     `json:"latency_ms,omitempty"` // of the last check that reached the provider
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+
+	// Models are the models available locally, reported for Ollama
+	Models []string `json:"models,omitempty"`
+}
+
+// providerCheck validates a provider's key and, for local providers, lists
+// its models
+type providerCheck struct {
+	validate func(ctx context.Context) error
+	models   func(ctx context.Context) ([]string, error)
+}
+
+// providerHealthChecker checks the configured providers and caches the
+// results for providerHealthTTL
+type providerHealthChecker struct {
+	mu      sync.Mutex
+	checks  map[string]providerCheck
+	results map[string]ProviderHealth
+	ttl     time.Duration
+	timeout time.Duration
+}
+
+var providerHealth = newProviderHealthChecker(providerHealthTTL, providerHealthTimeout)
+
+func newProviderHealthChecker(ttl, timeout time.Duration) *providerHealthChecker {
+	return &providerHealthChecker{
+		checks:  make(map[string]providerCheck),
+		results: make(map[string]ProviderHealth),
+		ttl:     ttl,
+		timeout: timeout,
+	}
+}
+
+// addKeyCheck checks the provider by validating key with it. Providers
+// validate keys by listing their models, which isn't billed.
+func (c *providerHealthChecker) addKeyCheck(name string, provider aiproviders.Provider, key string) {
+	c.add(name, providerCheck{
+		validate: func(ctx context.Context) error { return provider.ValidateAPIKey(ctx, key) },
+	})
+}
+
+// addOllamaCheck checks that the Ollama server is up and lists its models
+func (c *providerHealthChecker) addOllamaCheck(provider *aiproviders.OllamaProvider) {
+	c.add("ollama", providerCheck{
+		validate: func(ctx context.Context) error { return provider.ValidateAPIKey(ctx, "") },
+		models: func(ctx context.Context) ([]string, error) {
+			models, err := provider.ListModels(ctx)
+			if err != nil {
+				return nil, err
+			}
+			names := make([]string, len(models))
+			for i, model := range models {
+				names[i] = model.ID
+			}
+			sort.Strings(names)
+			return names, nil
+		},
+	})
+}
+
+func (c *providerHealthChecker) add(name string, check providerCheck) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+	delete(c.results, name)
+}
+
+// status returns each provider's health, checking concurrently those whose
+// last check is older than the TTL. Callers polling at the same time wait for
+// the same checks rather than starting their own.
+func (c *providerHealthChecker) status(ctx context.Context) map[string]ProviderHealth {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	var wg sync.WaitGroup
+	var resultsMu sync.Mutex
+	for name, check := range c.checks {
+		last, ok := c.results[name]
+		if ok && now.Sub(last.CheckedAt) < c.ttl {
+			continue
+		}
+
+		wg.Add(1)
+		go func(name string, check providerCheck, last ProviderHealth) {
+			defer wg.Done()
+			health := c.check(ctx, name, check, last)
+
+			resultsMu.Lock()
+			c.results[name] = health
+			resultsMu.Unlock()
+		}(name, check, last)
+	}
+	wg.Wait()
+
+	status := make(map[string]ProviderHealth, len(c.results))
+	for name, health := range c.results {
+		status[name] = health
+	}
+	return status
+}
+
+// check runs one provider's check. A provider that answered, even to reject
+// the key, is reachable; the latency of the previous check is kept when it
+// didn't answer. The check outlives the request that started it, as its
+// result is cached for everyone.
+func (c *providerHealthChecker) check(ctx context.Context, name string, check providerCheck, last ProviderHealth) ProviderHealth {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.validate(ctx)
+	rejected := errors.Is(err, aiproviders.ErrInvalidAPIKey)
+	health := ProviderHealth{
+		Reachable: err == nil || !unreachable(err),
+		Valid:     err == nil || (last.Valid && !rejected),
+		LatencyMs: last.LatencyMs,
+		CheckedAt: time.Now(),
+	}
+	if health.Reachable {
+		health.LatencyMs = time.Since(start).Milliseconds()
+	}
+	if rejected {
+		health.Error = err.Error()
+		logger.Errorw("provider rejected API key", "provider", name, "error", err)
+		return health
+	}
+	if err != nil {
+		health.Error = err.Error()
+		logger.Warnw("provider health check failed", "provider", name, "reachable", health.Reachable, "error", err)
+		return health
+	}
+
+	if check.models != nil {
+		models, err := check.models(ctx)
+		if err != nil {
+			health.Error = err.Error()
+		}
+		health.Models = models
+	}
+	return health
+}
+
+// unreachable reports whether err means the provider couldn't be reached,
+// rather than that it answered with an error
+func unreachable(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
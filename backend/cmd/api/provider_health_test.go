@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	aiproviders "github.com/delphi-platform/delphi/backend/internal/providers"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// =============================================================================
+// Provider Health Tests
+// =============================================================================
+
+func TestProviderHealthClassifiesFailures(t *testing.T) {
+	prevLogger := logger
+	logger = zap.NewNop().Sugar()
+	t.Cleanup(func() { logger = prevLogger })
+
+	var result error
+	checker := newProviderHealthChecker(0, providerHealthTimeout)
+	checker.add("anthropic", providerCheck{
+		validate: func(ctx context.Context) error { return result },
+	})
+	check := func(err error) ProviderHealth {
+		result = err
+		return checker.status(context.Background())["anthropic"]
+	}
+
+	health := check(nil)
+	assert.True(t, health.Reachable)
+	assert.True(t, health.Valid)
+	assert.Empty(t, health.Error)
+
+	// An outage or rate limit says nothing about the key
+	health = check(errors.New("anthropic API error: 529"))
+	assert.True(t, health.Reachable)
+	assert.True(t, health.Valid, "the key keeps its last verdict")
+	assert.Equal(t, "anthropic API error: 529", health.Error)
+
+	health = check(&net.OpError{Op: "dial", Err: errors.New("connection refused")})
+	assert.False(t, health.Reachable)
+	assert.True(t, health.Valid)
+
+	health = check(fmt.Errorf("%w: status 401", aiproviders.ErrInvalidAPIKey))
+	assert.True(t, health.Reachable)
+	assert.False(t, health.Valid)
+	assert.Equal(t, "invalid API key: status 401", health.Error)
+
+	// Until the key is accepted again, other failures don't make it valid
+	health = check(errors.New("anthropic API error: 500"))
+	assert.False(t, health.Valid)
+	assert.True(t, check(nil).Valid)
+}
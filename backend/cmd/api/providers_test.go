@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	aiproviders "github.com/delphi-platform/delphi/backend/internal/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Provider Endpoint Tests
+// =============================================================================
+
+func TestProvidersUseEndpoint(t *testing.T) {
+	var requests []string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RequestURI()+" "+r.Header.Get("Authorization")+r.Header.Get("api-key")+r.Header.Get("x-api-key"))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"content": "hi"}}},
+			"content": []map[string]string{{"text": "hi"}},
+		})
+	}))
+	defer gateway.Close()
+
+	messages := []ChatMessage{{Role: "user", Content: "hello"}}
+	openAI := NewOpenAIProvider("openai-key", "gpt-4o", aiproviders.Endpoint{BaseURL: gateway.URL + "/openai/v1"})
+	_, err := openAI.Complete(t.Context(), "", "system", messages, RetryPolicy{})
+	require.NoError(t, err)
+
+	azure := NewOpenAIProvider("azure-key", "gpt-4o", aiproviders.Endpoint{BaseURL: gateway.URL, APIVersion: "2024-10-21"})
+	_, err = azure.Complete(t.Context(), "", "system", messages, RetryPolicy{})
+	require.NoError(t, err)
+
+	anthropic := NewAnthropicProvider("anthropic-key", "", aiproviders.Endpoint{BaseURL: gateway.URL + "/anthropic/v1/"})
+	_, err = anthropic.Complete(t.Context(), "", "system", messages, RetryPolicy{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"/openai/v1/chat/completions Bearer openai-key",
+		"/openai/deployments/gpt-4o/chat/completions?api-version=2024-10-21 azure-key",
+		"/anthropic/v1/messages anthropic-key",
+	}, requests)
+}
+
+func TestRetryPolicyZeroRetriesTurnsRetriesOff(t *testing.T) {
+	var policy RetryPolicy
+	require.NoError(t, json.Unmarshal([]byte(`{"max_retries": 0}`), &policy))
+	effective := policy.withDefaults()
+	require.NotNil(t, effective.MaxRetries)
+	assert.Equal(t, 0, *effective.MaxRetries)
+	assert.Equal(t, defaultRetryPolicy.BackoffMs, effective.BackoffMs)
+
+	unset := RetryPolicy{}.withDefaults()
+	require.NotNil(t, unset.MaxRetries)
+	assert.Equal(t, defaultMaxRetries, *unset.MaxRetries)
+	assert.Equal(t, defaultRetryPolicy.MaxBackoffMs, unset.MaxBackoffMs)
+
+	attempts := 0
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	openAI := NewOpenAIProvider("openai-key", "gpt-4o", aiproviders.Endpoint{BaseURL: failing.URL})
+	_, err := openAI.Complete(t.Context(), "", "system", []ChatMessage{{Role: "user", Content: "hello"}}, effective)
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts, "a failed request is not retried")
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/billing"
+	agentexec "github.com/delphi-platform/delphi/backend/internal/execution"
+	internalmiddleware "github.com/delphi-platform/delphi/backend/internal/middleware"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	pkglogger "github.com/delphi-platform/delphi/backend/pkg/logger"
+)
+
+// ============================================================================
+// Rate Limits
+// ============================================================================
+
+var (
+	// requestLimits applies the tenant's plan rate limit to API requests, and
+	// the free plan's limit per client IP to unauthenticated ones. It is nil
+	// when DATABASE_URL is not set, as plans are looked up in Postgres.
+	requestLimits *services.RateLimitService
+
+	// runSlots bounds how many executions each tenant runs at once, by its
+	// plan when requestLimits is set and by MAX_CONCURRENT_RUNS_PER_TENANT
+	runSlots = agentexec.NewConcurrencyLimiter(nil, agentexec.ConcurrencyConfig{})
+)
+
+// runSlotWait is how long an execution over its tenant's concurrency limit
+// waits in the queue before it is refused. It is kept under the server's
+// write timeout, which is only extended once the execution starts.
+const runSlotWait = 30 * time.Second
+
+// newRequestLimits creates the rate limits, when DATABASE_URL is set, and the
+// execution concurrency limits. MAX_CONCURRENT_RUNS_PER_TENANT (20 by
+// default) caps every plan and MAX_QUEUED_RUNS_PER_TENANT (10 by default)
+// bounds the queue. With REDIS_URL set, rate limit buckets and running slots
+// are kept in Redis so every instance shares them. The returned function
+// releases the connections.
+func newRequestLimits() (*services.RateLimitService, *agentexec.ConcurrencyLimiter, func(), error) {
+	maxRuns, err := envInt("MAX_CONCURRENT_RUNS_PER_TENANT", 20)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	maxQueued, err := envInt("MAX_QUEUED_RUNS_PER_TENANT", 10)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var closers []func()
+	closeAll := func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}
+	log := pkglogger.New()
+
+	var limits *services.RateLimitService
+	var planLimit agentexec.LimitFunc
+	if databaseURL := os.Getenv("DATABASE_URL"); databaseURL != "" {
+		db, err := repository.NewPostgresDB(databaseURL)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		closers = append(closers, db.Close)
+
+		repos := repository.NewRepositories(db)
+		// Only plan limits are used, which need no Stripe prices; the key is
+		// passed through so the Stripe client stays configured as before
+		billingService := billing.NewService(os.Getenv("STRIPE_SECRET_KEY"), "", "", repos, nil, log)
+		limits = services.NewRateLimitService(repos, billingService, log)
+		planLimit = limits.MaxConcurrentRuns
+	}
+	slots := agentexec.NewConcurrencyLimiter(planLimit, agentexec.ConcurrencyConfig{
+		Max:       maxRuns,
+		MaxQueued: maxQueued,
+	})
+
+	if redisURL := os.Getenv("REDIS_URL"); redisURL != "" {
+		client, err := repository.NewRedisClient(redisURL)
+		if err != nil {
+			closeAll()
+			return nil, nil, nil, err
+		}
+		closers = append(closers, func() { client.Close() })
+		if limits != nil {
+			limits.SetRedis(client)
+		}
+		slots.SetStore(agentexec.NewRedisSlotStore(client.Client(), agentexec.SlotTTL(runTimeouts.max)), log)
+	}
+
+	return limits, slots, closeAll, nil
+}
+
+// acquireRunSlot claims one of the request's tenant's running slots, waiting
+// up to runSlotWait in its queue. On failure it writes the response, a 429
+// when the tenant is at its limit, and returns nil.
+func acquireRunSlot(w http.ResponseWriter, r *http.Request) *agentexec.Slot {
+	tenantID, _ := internalmiddleware.GetTenantID(r.Context())
+
+	ctx, cancel := context.WithTimeout(r.Context(), runSlotWait)
+	defer cancel()
+	slot, err := runSlots.Acquire(ctx, tenantID)
+	switch {
+	case err == nil:
+		return slot
+	case errors.Is(err, agentexec.ErrMaxConcurrentRuns), r.Context().Err() == nil:
+		stats := runSlots.Stats(r.Context(), tenantID)
+		logger.Warnw("execution refused over concurrency limit", "tenant_id", tenantID, "active", stats.Active, "queued", stats.Queued, "limit", stats.Limit)
+		jsonError(w, http.StatusTooManyRequests, "Too many concurrent executions; try again once one finishes")
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/billing"
+	"github.com/delphi-platform/delphi/backend/internal/config"
+	"github.com/delphi-platform/delphi/backend/internal/payload"
+	"github.com/delphi-platform/delphi/backend/internal/security"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/pkg/auth"
+	pkglogger "github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// =============================================================================
+// Router Tests
+// =============================================================================
+
+// testAPI serves the real router with authentication enabled and an agent
+// owned by tenant. It returns a function that sends a request as a user with
+// the given role in the given tenant.
+func testAPI(t *testing.T, tenant uuid.UUID, agent *Agent) func(method, path string, tenantID uuid.UUID, role string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	jwt := auth.NewJWTManager("router-test-secret", 60, 7)
+	prevAuth, prevLogger, prevStore, prevAgents := authService, logger, execStore, agents
+	prevGuardrail, prevPayloads := guardrail, payloads
+	authService = services.NewAuthService(&config.Config{}, nil, jwt, nil, nil, nil, pkglogger.New())
+	logger = zap.NewNop().Sugar()
+	execStore = &memoryExecutionStore{executions: make(map[string]*Execution)}
+	agents = map[string]*Agent{agent.ID: agent}
+	g, err := security.NewGuardrail(nil, nil, pkglogger.New())
+	require.NoError(t, err)
+	guardrail = g
+	payloads = payload.NewLimiter(payload.NewMemoryStore(), payload.Limits{})
+	t.Cleanup(func() {
+		authService, logger, execStore, agents = prevAuth, prevLogger, prevStore, prevAgents
+		guardrail, payloads = prevGuardrail, prevPayloads
+	})
+
+	agent.OrgID = tenant.String()
+	router := newRouter()
+
+	return func(method, path string, tenantID uuid.UUID, role string) *httptest.ResponseRecorder {
+		t.Helper()
+		token, err := jwt.GenerateAccessToken(uuid.New(), tenantID, role+"@example.com", role)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(method, path, strings.NewReader("{}"))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+}
+
+func TestRouterPermissions(t *testing.T) {
+	tenant := uuid.New()
+	agent := &Agent{ID: "agent-perm", Name: "Permissions", Status: "ready", CreatedAt: time.Now()}
+	do := testAPI(t, tenant, agent)
+
+	tests := []struct {
+		method string
+		path   string
+		role   string
+		want   int
+	}{
+		{http.MethodGet, "/api/v1/agents", "viewer", http.StatusOK},
+		{http.MethodGet, "/api/v1/agents/agent-perm", "viewer", http.StatusOK},
+		{http.MethodPost, "/api/v1/agents", "viewer", http.StatusForbidden},
+		{http.MethodPatch, "/api/v1/agents/agent-perm", "viewer", http.StatusForbidden},
+		{http.MethodDelete, "/api/v1/agents/agent-perm", "viewer", http.StatusForbidden},
+		{http.MethodPost, "/api/v1/agents/agent-perm/launch", "viewer", http.StatusForbidden},
+		{http.MethodPost, "/api/v1/agents/agent-perm/terminate", "viewer", http.StatusForbidden},
+		{http.MethodPost, "/api/v1/execute", "viewer", http.StatusForbidden},
+		{http.MethodPost, "/api/v1/execute/stream", "viewer", http.StatusForbidden},
+		{http.MethodGet, "/api/v1/costs/summary", "viewer", http.StatusForbidden},
+		{http.MethodDelete, "/api/v1/agents/agent-perm", "member", http.StatusForbidden},
+		{http.MethodPost, "/api/v1/agents/agent-perm/pause", "member", http.StatusOK},
+		{http.MethodPost, "/api/v1/agents/agent-perm/launch", "member", http.StatusOK},
+		{http.MethodGet, "/api/v1/costs/summary", "owner", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.role+" "+tt.method+" "+tt.path, func(t *testing.T) {
+			rec := do(tt.method, tt.path, tenant, tt.role)
+			assert.Equal(t, tt.want, rec.Code, rec.Body.String())
+		})
+	}
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		newRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/agents", nil))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
+
+func TestRouterTenantIsolation(t *testing.T) {
+	tenant, other := uuid.New(), uuid.New()
+	agent := &Agent{ID: "agent-tenant", Name: "Isolated", Status: "ready", CreatedAt: time.Now()}
+	do := testAPI(t, tenant, agent)
+
+	var listed []*Agent
+	require.NoError(t, json.Unmarshal(do(http.MethodGet, "/api/v1/agents", other, "admin").Body.Bytes(), &listed))
+	assert.Empty(t, listed, "another tenant's agents are not listed")
+
+	for _, req := range []struct{ method, path string }{
+		{http.MethodGet, "/api/v1/agents/agent-tenant"},
+		{http.MethodPatch, "/api/v1/agents/agent-tenant"},
+		{http.MethodPost, "/api/v1/agents/agent-tenant/terminate"},
+		{http.MethodDelete, "/api/v1/agents/agent-tenant"},
+	} {
+		rec := do(req.method, req.path, other, "admin")
+		assert.Equal(t, http.StatusNotFound, rec.Code, "%s %s", req.method, req.path)
+	}
+	assert.Equal(t, "ready", agent.Status)
+	assert.Contains(t, agents, "agent-tenant")
+
+	execution := &Execution{AgentID: agent.ID, Status: "completed", StartTime: time.Now()}
+	require.NoError(t, execStore.Create(t.Context(), agent, execution))
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/v1/executions/"+execution.ID, tenant, "viewer").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/v1/executions/"+execution.ID, other, "admin").Code)
+
+	var page executionPage
+	require.NoError(t, json.Unmarshal(do(http.MethodGet, "/api/v1/executions", other, "admin").Body.Bytes(), &page))
+	assert.Empty(t, page.Items)
+	assert.Zero(t, page.Total)
+
+	rec := do(http.MethodDelete, "/api/v1/agents/agent-tenant", tenant, "admin")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, agents, "agent-tenant")
+}
+
+func TestRouterRateLimitsAuthByIP(t *testing.T) {
+	prevLimits := requestLimits
+	requestLimits = services.NewRateLimitService(nil, billing.NewService("", "", "", nil, nil, pkglogger.New()), pkglogger.New())
+	t.Cleanup(func() { requestLimits = prevLimits })
+
+	router := newRouter()
+	login := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader("{}"))
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Anonymous requests get the free plan's 60 a minute
+	for i := 0; i < 60; i++ {
+		require.NotEqual(t, http.StatusTooManyRequests, login("203.0.113.7").Code, "request %d", i+1)
+	}
+	rec := login("203.0.113.7")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", rec.Header().Get("X-RateLimit-Remaining"))
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	assert.NotEqual(t, http.StatusTooManyRequests, login("203.0.113.8").Code, "other clients have their own limit")
+}
+
+func TestRouterUpdateChecksOnlyChangedModels(t *testing.T) {
+	agent := &Agent{ID: "agent-retired", Name: "Retired", Status: "ready", ModelProvider: "anthropic", Model: "claude-3-5-sonnet-20241022", CreatedAt: time.Now()}
+	testAPI(t, uuid.New(), agent)
+	authService = nil
+	agent.OrgID = defaultOrgID
+
+	router := newRouter()
+	update := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/api/v1/agents/agent-retired", strings.NewReader(body)))
+		return rec
+	}
+
+	// An agent left on a retired model can still be edited
+	rec := update(`{"name":"Renamed","model":"claude-3-5-sonnet-20241022"}`)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "Renamed", agent.Name)
+
+	rec = update(`{"model":"claude-3-opus-20240229"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "was retired")
+	assert.Equal(t, "claude-3-5-sonnet-20241022", agent.Model)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/delphi-platform/delphi/backend/internal/config"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	pkglogger "github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// ============================================================================
+// Webhooks
+// ============================================================================
+
+// webhooks delivers run.completed and run.failed events to the tenant's
+// webhooks. It is nil when DATABASE_URL is not set, as subscriptions and
+// deliveries are stored in Postgres.
+var (
+	webhooks    *services.WebhookDeliveryService
+	webhookRuns *repository.AgentRunRepository
+)
+
+// newWebhooks creates the webhook delivery service when DATABASE_URL is set.
+// WEBHOOK_MAX_ATTEMPTS and WEBHOOK_DISABLE_AFTER_FAILURES override the retry
+// and auto-disable limits. The returned function stops the delivery loop,
+// then releases the database connection.
+func newWebhooks() (*services.WebhookDeliveryService, *repository.AgentRunRepository, func(), error) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		return nil, nil, func() {}, nil
+	}
+
+	maxAttempts, err := envInt("WEBHOOK_MAX_ATTEMPTS", 6)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	disableAfter, err := envInt("WEBHOOK_DISABLE_AFTER_FAILURES", 15)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// Webhook secrets are stored encrypted, so deliveries are signed with
+	// the same key the main services use
+	encryptor, err := newEncryptor()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	db, err := repository.NewPostgresDB(databaseURL)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cfg := &config.Config{
+		Environment:                 environment(),
+		WebhookMaxAttempts:          maxAttempts,
+		WebhookDisableAfterFailures: disableAfter,
+	}
+	repos := repository.NewRepositories(db)
+	svc := services.NewWebhookDeliveryService(cfg, repos, encryptor, pkglogger.New())
+	return svc, repos.AgentRuns, func() {
+		svc.Stop()
+		db.Close()
+	}, nil
+}
+
+// publishExecution queues the webhook event for a finished execution. Only
+// executions stored in Postgres have a run to deliver; failures are logged
+// and never fail the execution.
+func publishExecution(ctx context.Context, execution *Execution) {
+	if webhooks == nil {
+		return
+	}
+	runID, err := uuid.Parse(execution.ID)
+	if err != nil {
+		return
+	}
+
+	event := services.WebhookEventRunCompleted
+	if execution.Status != "completed" {
+		event = services.WebhookEventRunFailed
+	}
+	run, err := webhookRuns.GetByID(ctx, runID)
+	if err != nil || run == nil {
+		logger.Warnw("failed to load run for webhooks", "execution_id", execution.ID, "event", event, "error", err)
+		return
+	}
+	if err := webhooks.Publish(ctx, run.TenantID, event, run); err != nil {
+		logger.Warnw("failed to publish run event", "execution_id", execution.ID, "event", event, "error", err)
+	}
+}
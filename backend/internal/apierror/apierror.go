@@ -0,0 +1,132 @@
+// Package apierror defines the envelope of API error responses. Each error
+// carries a stable, machine-readable code alongside its message, so clients
+// can branch on the code rather than parse the message.
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Code identifies a kind of error. Codes are part of the API: once
+// published, they don't change.
+type Code string
+
+// Codes for specific errors
+const (
+	CodeAgentNotFound        Code = "AGENT_NOT_FOUND"
+	CodeExecutionNotFound    Code = "EXECUTION_NOT_FOUND"
+	CodeBudgetExceeded       Code = "BUDGET_EXCEEDED"
+	CodeBillingRestricted    Code = "BILLING_RESTRICTED"
+	CodeProviderUnconfigured Code = "PROVIDER_UNCONFIGURED"
+	CodeGuardrailBlocked     Code = "GUARDRAIL_BLOCKED"
+	CodeConcurrencyLimited   Code = "CONCURRENCY_LIMITED"
+	CodeMFARequired          Code = "MFA_REQUIRED"
+	CodeSlugTaken            Code = "SLUG_TAKEN"
+	CodeValidation           Code = "VALIDATION_ERROR"
+)
+
+// Codes for errors nothing more specific is known about, by HTTP status
+const (
+	CodeBadRequest         Code = "BAD_REQUEST"
+	CodeUnauthorized       Code = "UNAUTHORIZED"
+	CodeForbidden          Code = "FORBIDDEN"
+	CodeNotFound           Code = "NOT_FOUND"
+	CodeConflict           Code = "CONFLICT"
+	CodeGone               Code = "GONE"
+	CodePayloadTooLarge    Code = "PAYLOAD_TOO_LARGE"
+	CodeUnprocessable      Code = "UNPROCESSABLE"
+	CodeRateLimited        Code = "RATE_LIMITED"
+	CodeInternal           Code = "INTERNAL_ERROR"
+	CodeNotImplemented     Code = "NOT_IMPLEMENTED"
+	CodeProviderError      Code = "PROVIDER_ERROR"
+	CodeServiceUnavailable Code = "SERVICE_UNAVAILABLE"
+	CodeTimeout            Code = "TIMEOUT"
+)
+
+var statusCodes = map[int]Code{
+	http.StatusBadRequest:            CodeBadRequest,
+	http.StatusUnauthorized:          CodeUnauthorized,
+	http.StatusPaymentRequired:       CodeBudgetExceeded,
+	http.StatusForbidden:             CodeForbidden,
+	http.StatusNotFound:              CodeNotFound,
+	http.StatusConflict:              CodeConflict,
+	http.StatusGone:                  CodeGone,
+	http.StatusRequestEntityTooLarge: CodePayloadTooLarge,
+	http.StatusUnprocessableEntity:   CodeUnprocessable,
+	http.StatusTooManyRequests:       CodeRateLimited,
+	http.StatusInternalServerError:   CodeInternal,
+	http.StatusNotImplemented:        CodeNotImplemented,
+	http.StatusBadGateway:            CodeProviderError,
+	http.StatusServiceUnavailable:    CodeServiceUnavailable,
+	http.StatusGatewayTimeout:        CodeTimeout,
+}
+
+// Error is the body of an error response
+type Error struct {
+	Code    Code        `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// Response is an error response. Message repeats the error's message at the
+// top level for clients that don't read the envelope.
+type Response struct {
+	Error   Error  `json:"error"`
+	Message string `json:"message"`
+}
+
+// New returns the response for an error sent with the given status
+func New(status int, message string) Response {
+	return WithCode(CodeFor(status, message), message, nil)
+}
+
+// WithCode returns the response for an error with a code of the caller's
+// choosing and optional details
+func WithCode(code Code, message string, details interface{}) Response {
+	return Response{
+		Error:   Error{Code: code, Message: message, Details: details},
+		Message: message,
+	}
+}
+
+// CodeFor maps an error to its code. Errors the services and handlers report
+// with a known message get their specific code; the rest get their status's,
+// or INTERNAL_ERROR for server errors and BAD_REQUEST for client errors
+// without one.
+func CodeFor(status int, message string) Code {
+	lower := strings.ToLower(message)
+	switch {
+	case status == http.StatusNotFound && strings.HasPrefix(lower, "agent not found"):
+		return CodeAgentNotFound
+	case status == http.StatusNotFound && (strings.HasPrefix(lower, "execution not found") || strings.HasPrefix(lower, "run not found")):
+		return CodeExecutionNotFound
+	case strings.Contains(lower, "budget exceeded"):
+		return CodeBudgetExceeded
+	case strings.Contains(lower, "restricted for non-payment"):
+		return CodeBillingRestricted
+	case strings.HasPrefix(lower, "provider") && strings.Contains(lower, "not configured"),
+		strings.HasPrefix(lower, "ollama_base_url not set"):
+		return CodeProviderUnconfigured
+	case strings.Contains(lower, "blocked by guardrails"):
+		return CodeGuardrailBlocked
+	case strings.Contains(lower, "max concurrent runs"):
+		return CodeConcurrencyLimited
+	}
+
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+	if status >= http.StatusInternalServerError {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
+
+// Write sends resp as a JSON error response with the given status
+func Write(w http.ResponseWriter, status int, resp Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
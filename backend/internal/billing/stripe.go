@@ -2,10 +2,13 @@ package billing
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 
 	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/notifications"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
 	"github.com/delphi-platform/delphi/backend/pkg/logger"
 	"github.com/google/uuid"
 	"github.com/stripe/stripe-go/v76"
@@ -15,21 +18,57 @@ import (
 	"github.com/stripe/stripe-go/v76/usagerecord"
 )
 
+// Notifier delivers tenant notifications
+type Notifier interface {
+	Send(ctx context.Context, notification *notifications.Notification) error
+}
+
+// TenantStore reads and updates the tenants webhook events apply to
+type TenantStore interface {
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Tenant, error)
+	Update(ctx context.Context, tenant *models.Tenant) error
+}
+
+// EventStore records which Stripe events have been applied
+type EventStore interface {
+	// MarkProcessed records an event, reporting false if it already was
+	MarkProcessed(ctx context.Context, eventID, eventType string) (bool, error)
+
+	// Unmark forgets an event so it can be applied again
+	Unmark(ctx context.Context, eventID string) error
+}
+
 // Service handles billing operations
 type Service struct {
 	stripePricePro        string
 	stripePriceEnterprise string
+	tenants               TenantStore
+	events                EventStore
+	notifier              Notifier
 	log                   *logger.Logger
 }
 
-// NewService creates a new billing service
-func NewService(stripeKey, pricePro, priceEnterprise string, log *logger.Logger) *Service {
+// NewService creates a new billing service. Webhook events update tenants
+// through repos and payment problems are reported through notifier.
+func NewService(stripeKey, pricePro, priceEnterprise string, repos *repository.Repositories, notifier Notifier, log *logger.Logger) *Service {
 	stripe.Key = stripeKey
-	return &Service{
+	s := &Service{
 		stripePricePro:        pricePro,
 		stripePriceEnterprise: priceEnterprise,
+		notifier:              notifier,
 		log:                   log,
 	}
+	if repos != nil {
+		s.SetStores(repos.Tenants, repos.BillingEvents)
+	}
+	return s
+}
+
+// SetStores sets where webhook events read and update tenants and record
+// the events they have applied
+func (s *Service) SetStores(tenants TenantStore, events EventStore) {
+	s.tenants = tenants
+	s.events = events
 }
 
 // =============================================================================
@@ -126,6 +165,32 @@ func (s *Service) CancelSubscription(ctx context.Context, subscriptionID string)
 	return sub, nil
 }
 
+// CancelCustomerSubscriptions cancels all of a customer's subscriptions
+// straight away rather than at the end of the period, for a tenant that is
+// being deleted
+func (s *Service) CancelCustomerSubscriptions(ctx context.Context, customerID string) error {
+	params := &stripe.SubscriptionListParams{
+		Customer: stripe.String(customerID),
+	}
+
+	var ids []string
+	iter := subscription.List(params)
+	for iter.Next() {
+		ids = append(ids, iter.Subscription().ID)
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+
+	for _, id := range ids {
+		if _, err := subscription.Cancel(id, nil); err != nil {
+			return fmt.Errorf("failed to cancel subscription %s: %w", id, err)
+		}
+		s.log.Infow("subscription cancelled", "subscription_id", id, "customer_id", customerID)
+	}
+	return nil
+}
+
 // GetSubscription retrieves a subscription
 func (s *Service) GetSubscription(ctx context.Context, subscriptionID string) (*stripe.Subscription, error) {
 	sub, err := subscription.Get(subscriptionID, nil)
@@ -208,10 +273,31 @@ type WebhookEvent struct {
 	Data map[string]interface{}
 }
 
-// HandleWebhook processes a Stripe webhook
+// HandleWebhook processes a Stripe webhook. Stripe delivers events at least
+// once, so each event ID is applied only once; an event that fails is
+// forgotten again so Stripe's retry can apply it.
 func (s *Service) HandleWebhook(ctx context.Context, event stripe.Event) error {
-	s.log.Infow("processing Stripe webhook", "event_type", event.Type)
+	s.log.Infow("processing Stripe webhook", "event_type", event.Type, "event_id", event.ID)
+
+	first, err := s.events.MarkProcessed(ctx, event.ID, string(event.Type))
+	if err != nil {
+		return fmt.Errorf("failed to record event: %w", err)
+	}
+	if !first {
+		s.log.Infow("skipping already processed Stripe event", "event_type", event.Type, "event_id", event.ID)
+		return nil
+	}
+
+	if err := s.dispatchWebhook(ctx, event); err != nil {
+		if unmarkErr := s.events.Unmark(ctx, event.ID); unmarkErr != nil {
+			s.log.Errorw("failed to unmark Stripe event", "event_id", event.ID, "error", unmarkErr)
+		}
+		return err
+	}
+	return nil
+}
 
+func (s *Service) dispatchWebhook(ctx context.Context, event stripe.Event) error {
 	switch event.Type {
 	case "customer.subscription.created":
 		return s.handleSubscriptionCreated(ctx, event)
@@ -232,31 +318,246 @@ func (s *Service) HandleWebhook(ctx context.Context, event stripe.Event) error {
 
 func (s *Service) handleSubscriptionCreated(ctx context.Context, event stripe.Event) error {
 	s.log.Infow("subscription created", "event_id", event.ID)
-	// Update tenant plan in database
-	return nil
+	return s.syncSubscription(ctx, event)
 }
 
 func (s *Service) handleSubscriptionUpdated(ctx context.Context, event stripe.Event) error {
 	s.log.Infow("subscription updated", "event_id", event.ID)
-	// Update tenant plan in database
-	return nil
+	return s.syncSubscription(ctx, event)
 }
 
 func (s *Service) handleSubscriptionDeleted(ctx context.Context, event stripe.Event) error {
 	s.log.Infow("subscription deleted", "event_id", event.ID)
-	// Downgrade tenant to free plan
-	return nil
+
+	var sub stripe.Subscription
+	if err := json.Unmarshal(event.Data.Raw, &sub); err != nil {
+		return fmt.Errorf("failed to parse subscription: %w", err)
+	}
+
+	tenant, err := s.tenantForSubscription(ctx, &sub)
+	if err != nil || tenant == nil {
+		return err
+	}
+	return s.setPlan(ctx, tenant, models.PlanFree, sub.ID)
 }
 
 func (s *Service) handleInvoicePaid(ctx context.Context, event stripe.Event) error {
 	s.log.Infow("invoice paid", "event_id", event.ID)
-	// Record payment in audit log
+
+	var inv stripe.Invoice
+	if err := json.Unmarshal(event.Data.Raw, &inv); err != nil {
+		return fmt.Errorf("failed to parse invoice: %w", err)
+	}
+
+	tenant, err := s.tenantForCustomer(ctx, inv.Customer)
+	if err != nil || tenant == nil {
+		return err
+	}
+	if !IsRestricted(tenant) {
+		return nil
+	}
+
+	if err := s.setRestricted(ctx, tenant, false); err != nil {
+		return err
+	}
+	s.log.Infow("tenant billing restriction lifted", "tenant_id", tenant.ID, "invoice_id", inv.ID)
 	return nil
 }
 
+// handleInvoicePaymentFailed notifies the tenant of a failed payment. Once
+// Stripe has stopped retrying the invoice, the tenant is also restricted until
+// an invoice is paid.
 func (s *Service) handleInvoicePaymentFailed(ctx context.Context, event stripe.Event) error {
 	s.log.Warnw("invoice payment failed", "event_id", event.ID)
-	// Notify tenant and potentially restrict access
+
+	var inv stripe.Invoice
+	if err := json.Unmarshal(event.Data.Raw, &inv); err != nil {
+		return fmt.Errorf("failed to parse invoice: %w", err)
+	}
+
+	tenant, err := s.tenantForCustomer(ctx, inv.Customer)
+	if err != nil || tenant == nil {
+		return err
+	}
+
+	restrict := inv.NextPaymentAttempt == 0
+	if restrict && !IsRestricted(tenant) {
+		if err := s.setRestricted(ctx, tenant, true); err != nil {
+			return err
+		}
+		s.log.Warnw("tenant restricted for non-payment", "tenant_id", tenant.ID, "invoice_id", inv.ID)
+	}
+
+	if s.notifier != nil {
+		amountDue := float64(inv.AmountDue) / 100
+		notification := notifications.PaymentFailedNotification(tenant.ID, amountDue, string(inv.Currency), restrict)
+		if err := s.notifier.Send(ctx, notification); err != nil {
+			// The restriction is applied; a missed notification should not make Stripe redeliver
+			s.log.Warnw("failed to send payment failed notification", "tenant_id", tenant.ID, "error", err)
+		}
+	}
+	return nil
+}
+
+// syncSubscription sets the tenant's plan from a created or updated subscription
+func (s *Service) syncSubscription(ctx context.Context, event stripe.Event) error {
+	var sub stripe.Subscription
+	if err := json.Unmarshal(event.Data.Raw, &sub); err != nil {
+		return fmt.Errorf("failed to parse subscription: %w", err)
+	}
+
+	tenant, err := s.tenantForSubscription(ctx, &sub)
+	if err != nil || tenant == nil {
+		return err
+	}
+
+	switch sub.Status {
+	case stripe.SubscriptionStatusActive, stripe.SubscriptionStatusTrialing, stripe.SubscriptionStatusPastDue:
+		plan, ok := s.planForSubscription(&sub)
+		if !ok {
+			s.log.Warnw("subscription has no known price, leaving plan unchanged",
+				"subscription_id", sub.ID,
+				"tenant_id", tenant.ID,
+			)
+			return nil
+		}
+		return s.setPlan(ctx, tenant, plan, sub.ID)
+	case stripe.SubscriptionStatusCanceled, stripe.SubscriptionStatusUnpaid, stripe.SubscriptionStatusIncompleteExpired:
+		return s.setPlan(ctx, tenant, models.PlanFree, sub.ID)
+	default:
+		// Incomplete subscriptions have not been paid for yet
+		return nil
+	}
+}
+
+// planForSubscription maps a subscription's price to a plan
+func (s *Service) planForSubscription(sub *stripe.Subscription) (models.TenantPlan, bool) {
+	if sub.Items == nil {
+		return "", false
+	}
+	for _, item := range sub.Items.Data {
+		if item.Price == nil || item.Price.ID == "" {
+			continue
+		}
+		switch item.Price.ID {
+		case s.stripePriceEnterprise:
+			return models.PlanEnterprise, true
+		case s.stripePricePro:
+			return models.PlanPro, true
+		}
+	}
+	return "", false
+}
+
+func (s *Service) setPlan(ctx context.Context, tenant *models.Tenant, plan models.TenantPlan, subscriptionID string) error {
+	if tenant.Plan == plan {
+		return nil
+	}
+
+	previous := tenant.Plan
+	tenant.Plan = plan
+	if err := s.tenants.Update(ctx, tenant); err != nil {
+		return fmt.Errorf("failed to update tenant plan: %w", err)
+	}
+
+	s.log.Infow("tenant plan changed",
+		"tenant_id", tenant.ID,
+		"subscription_id", subscriptionID,
+		"from", previous,
+		"to", plan,
+	)
+	return nil
+}
+
+// tenantForSubscription finds the tenant a subscription belongs to, preferring
+// the subscription's own tenant_id metadata over its customer's
+func (s *Service) tenantForSubscription(ctx context.Context, sub *stripe.Subscription) (*models.Tenant, error) {
+	if id, ok := sub.Metadata["tenant_id"]; ok {
+		return s.tenantByID(ctx, id, sub.ID)
+	}
+	return s.tenantForCustomer(ctx, sub.Customer)
+}
+
+// tenantForCustomer finds the tenant of a Stripe customer from the tenant_id
+// metadata set by CreateCustomer. It returns nil for customers that do not
+// belong to a tenant, which are ignored.
+func (s *Service) tenantForCustomer(ctx context.Context, cust *stripe.Customer) (*models.Tenant, error) {
+	if cust == nil || cust.ID == "" {
+		s.log.Warnw("Stripe object has no customer, ignoring")
+		return nil, nil
+	}
+
+	// Webhook payloads carry only the customer ID
+	metadata := cust.Metadata
+	if metadata == nil {
+		full, err := s.GetCustomer(ctx, cust.ID)
+		if err != nil {
+			return nil, err
+		}
+		metadata = full.Metadata
+	}
+
+	id, ok := metadata["tenant_id"]
+	if !ok {
+		s.log.Warnw("Stripe customer has no tenant, ignoring", "customer_id", cust.ID)
+		return nil, nil
+	}
+	return s.tenantByID(ctx, id, cust.ID)
+}
+
+func (s *Service) tenantByID(ctx context.Context, id, source string) (*models.Tenant, error) {
+	tenantID, err := uuid.Parse(id)
+	if err != nil {
+		s.log.Warnw("invalid tenant_id in Stripe metadata, ignoring", "source", source, "tenant_id", id)
+		return nil, nil
+	}
+
+	tenant, err := s.tenants.GetByID(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+	if tenant == nil {
+		s.log.Warnw("Stripe metadata references unknown tenant, ignoring", "source", source, "tenant_id", tenantID)
+	}
+	return tenant, nil
+}
+
+// IsRestricted reports whether a tenant has been restricted for non-payment
+func IsRestricted(tenant *models.Tenant) bool {
+	var settings struct {
+		BillingRestricted bool `json:"billing_restricted"`
+	}
+	if len(tenant.Settings) > 0 {
+		json.Unmarshal(tenant.Settings, &settings)
+	}
+	return settings.BillingRestricted
+}
+
+// setRestricted sets the billing_restricted flag in the tenant's settings,
+// preserving the other settings
+func (s *Service) setRestricted(ctx context.Context, tenant *models.Tenant, restricted bool) error {
+	settings := make(map[string]interface{})
+	if len(tenant.Settings) > 0 {
+		if err := json.Unmarshal(tenant.Settings, &settings); err != nil {
+			return fmt.Errorf("failed to parse tenant settings: %w", err)
+		}
+	}
+
+	if restricted {
+		settings["billing_restricted"] = true
+	} else {
+		delete(settings, "billing_restricted")
+	}
+
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tenant settings: %w", err)
+	}
+	tenant.Settings = data
+
+	if err := s.tenants.Update(ctx, tenant); err != nil {
+		return fmt.Errorf("failed to update tenant: %w", err)
+	}
 	return nil
 }
 
@@ -284,6 +585,8 @@ type PlanLimits struct {
 	MaxStorageGB     int
 	MaxRepositories  int
 	MaxKnowledgeBases int
+	RequestsPerMinute int // API requests
+	MaxConcurrentRuns int // agent runs executing at once
 }
 
 // GetPricingPlans returns available pricing plans
@@ -302,6 +605,7 @@ func (s *Service) GetPricingPlans() []PricingPlan {
 				"50K tokens/month",
 				"1 repository",
 				"1 knowledge base",
+				"2 concurrent runs",
 				"Community support",
 			},
 			Limits: PlanLimits{
@@ -311,6 +615,8 @@ func (s *Service) GetPricingPlans() []PricingPlan {
 				MaxStorageGB:      1,
 				MaxRepositories:   1,
 				MaxKnowledgeBases: 1,
+				RequestsPerMinute: 60,
+				MaxConcurrentRuns: 2,
 			},
 		},
 		{
@@ -326,6 +632,7 @@ func (s *Service) GetPricingPlans() []PricingPlan {
 				"1M tokens/month",
 				"10 repositories",
 				"10 knowledge bases",
+				"10 concurrent runs",
 				"Priority support",
 				"Advanced analytics",
 			},
@@ -336,6 +643,8 @@ func (s *Service) GetPricingPlans() []PricingPlan {
 				MaxStorageGB:      50,
 				MaxRepositories:   10,
 				MaxKnowledgeBases: 10,
+				RequestsPerMinute: 600,
+				MaxConcurrentRuns: 10,
 			},
 		},
 		{
@@ -363,11 +672,27 @@ func (s *Service) GetPricingPlans() []PricingPlan {
 				MaxStorageGB:      -1,
 				MaxRepositories:   -1,
 				MaxKnowledgeBases: -1,
+				RequestsPerMinute: 6000,
+				MaxConcurrentRuns: -1,
 			},
 		},
 	}
 }
 
+// LimitsForPlan returns a plan's limits. Unknown plans get the free plan's.
+func (s *Service) LimitsForPlan(plan models.TenantPlan) PlanLimits {
+	var free PlanLimits
+	for _, p := range s.GetPricingPlans() {
+		if p.ID == string(plan) {
+			return p.Limits
+		}
+		if p.ID == string(models.PlanFree) {
+			free = p.Limits
+		}
+	}
+	return free
+}
+
 // CheckLimits checks if a tenant is within their plan limits
 func (s *Service) CheckLimits(ctx context.Context, tenantID uuid.UUID, plan models.TenantPlan, usage PlanUsage) error {
 	plans := s.GetPricingPlans()
@@ -21,8 +21,17 @@ type Config struct {
 	// Redis
 	RedisURL string
 
-	// Encryption
-	EncryptionKey string
+	// Encryption. EncryptionKeyVersion labels values encrypted with
+	// EncryptionKey; EncryptionPreviousKeys lists retired keys still needed to
+	// decrypt older values, as comma-separated version:hexkey pairs.
+	EncryptionKey          string
+	EncryptionKeyVersion   int
+	EncryptionPreviousKeys string
+
+	// Authentication. JWTSecret signs access and refresh tokens.
+	JWTSecret           string
+	JWTAccessTTLMinutes int
+	JWTRefreshTTLDays   int
 
 	// Supabase
 	SupabaseURL            string
@@ -33,6 +42,69 @@ type Config struct {
 	FlyAPIToken string
 	FlyOrg      string
 	FlyRegion   string
+	FlyAppName  string
+
+	// Warm machine pool (0 disables it)
+	FlyWarmPoolSize        int
+	FlyWarmPoolIdleMinutes int
+
+	// Execution. Each plan limits a tenant's concurrent runs;
+	// MaxConcurrentRunsPerTenant caps every plan (0 leaves them uncapped).
+	MaxConcurrentRunsPerTenant int
+	MaxQueuedRunsPerTenant     int
+
+	// Batch executions run at most BatchConcurrency of their items at once,
+	// within the tenant's concurrency limit (0 leaves only the tenant's).
+	BatchConcurrency int
+
+	// Run timeouts. Agents without a timeout get DefaultRunTimeoutSeconds;
+	// MaxRunTimeoutSeconds caps every agent's (0 leaves them uncapped).
+	DefaultRunTimeoutSeconds int
+	MaxRunTimeoutSeconds     int
+
+	// Tenants whose deletion is confirmed are deleted for good after
+	// TenantDeletionGraceDays, and can be restored until then
+	TenantDeletionGraceDays int
+
+	// Cost forecasts project spend from the burn rate over the trailing
+	// CostForecastWindowDays
+	CostForecastWindowDays int
+
+	// Deterministic (temperature 0) runs identical to an earlier one reuse its
+	// result for ResponseCacheTTLMinutes when ResponseCacheEnabled and Redis
+	// is configured
+	ResponseCacheEnabled    bool
+	ResponseCacheTTLMinutes int
+
+	// Outbound webhooks. A delivery is attempted up to WebhookMaxAttempts
+	// times; a webhook is disabled after WebhookDisableAfterFailures failed
+	// attempts in a row (0 never disables it).
+	WebhookMaxAttempts          int
+	WebhookDisableAfterFailures int
+
+	// Run payloads. Prompts and responses over these sizes, in bytes, are
+	// stored truncated with the full text kept under PayloadStoreDir
+	// (0 stores them whole).
+	MaxStoredPromptBytes   int
+	MaxStoredResponseBytes int
+	PayloadStoreDir        string
+
+	// AuditOverflowFile receives, as JSON lines, audit entries that could
+	// not be stored after retrying (empty drops them)
+	AuditOverflowFile string
+
+	// GuardrailBlockedPatterns is a JSON array of regular expressions that
+	// every agent's prompts and responses are checked against
+	GuardrailBlockedPatterns string
+
+	// Knowledge
+	KnowledgeRequestLogging      bool
+	KnowledgeEmbedder            string // mock, openai or ollama
+	KnowledgeEmbeddingModel      string
+	KnowledgeOllamaModel         string
+	KnowledgeEmbeddingDimensions int // 0 uses the model's native size
+	KnowledgeVectorStore         string // memory or pgvector
+	KnowledgeBriefingTokens      int    // retrieved context per run; 0 uses the default
 
 	// GitHub
 	GitHubAppID         string
@@ -53,11 +125,32 @@ type Config struct {
 	GoogleAIAPIKey  string
 	OllamaBaseURL   string
 
+	// Provider endpoints, for routing through Azure OpenAI, a proxy or a
+	// gateway; unset URLs use the providers' public APIs. OpenAIDeployments
+	// maps models to Azure deployments as model=deployment,...
+	OpenAIBaseURL     string
+	OpenAIAPIVersion  string
+	OpenAIDeployments string
+	AnthropicBaseURL  string
+	GoogleAIBaseURL   string
+	ProviderProxyURL  string
+
 	// Social Media
 	TwitterAPIKey       string
 	TwitterAPISecret    string
 	LinkedInClientID    string
 	LinkedInClientSecret string
+	SocialConcurrency    int
+	SocialMaxRetries     int
+
+	// IoT
+	MQTTBrokerURL string
+	MQTTUsername  string
+	MQTTPassword  string
+
+	// IoTCommandTimeoutSeconds is how long a sent command may go
+	// unacknowledged before it is failed
+	IoTCommandTimeoutSeconds int
 
 	// Email
 	SMTPHost     string
@@ -70,6 +163,11 @@ type Config struct {
 	SlackClientSecret string
 	DiscordBotToken   string
 
+	// Web Push (VAPID key pair and contact for push services)
+	VAPIDPublicKey  string
+	VAPIDPrivateKey string
+	VAPIDSubject    string
+
 	// Monitoring
 	SentryDSN string
 }
@@ -101,10 +199,37 @@ func Load() (*Config, error) {
 	v.SetDefault("API_PORT", 8080)
 	v.SetDefault("FRONTEND_URL", "http://localhost:5173")
 	v.SetDefault("REDIS_URL", "redis://localhost:6379")
+	v.SetDefault("ENCRYPTION_KEY_VERSION", 1)
+	v.SetDefault("JWT_ACCESS_TTL_MINUTES", 60)
+	v.SetDefault("JWT_REFRESH_TTL_DAYS", 7)
 	v.SetDefault("OLLAMA_BASE_URL", "http://localhost:11434")
 	v.SetDefault("SMTP_PORT", 587)
 	v.SetDefault("FLY_REGION", "iad")
 	v.SetDefault("FLY_ORG", "personal")
+	v.SetDefault("FLY_APP_NAME", "delphi-agents")
+	v.SetDefault("FLY_WARM_POOL_SIZE", 0)
+	v.SetDefault("FLY_WARM_POOL_IDLE_MINUTES", 15)
+	v.SetDefault("MAX_CONCURRENT_RUNS_PER_TENANT", 20)
+	v.SetDefault("MAX_QUEUED_RUNS_PER_TENANT", 10)
+	v.SetDefault("BATCH_CONCURRENCY", 5)
+	v.SetDefault("DEFAULT_RUN_TIMEOUT_SECONDS", 300)
+	v.SetDefault("MAX_RUN_TIMEOUT_SECONDS", 1800)
+	v.SetDefault("TENANT_DELETION_GRACE_DAYS", 30)
+	v.SetDefault("COST_FORECAST_WINDOW_DAYS", 7)
+	v.SetDefault("RESPONSE_CACHE_ENABLED", false)
+	v.SetDefault("RESPONSE_CACHE_TTL_MINUTES", 60)
+	v.SetDefault("WEBHOOK_MAX_ATTEMPTS", 6)
+	v.SetDefault("WEBHOOK_DISABLE_AFTER_FAILURES", 15)
+	v.SetDefault("MAX_STORED_PROMPT_BYTES", 64*1024)
+	v.SetDefault("MAX_STORED_RESPONSE_BYTES", 256*1024)
+	v.SetDefault("PAYLOAD_STORE_DIR", "data/payloads")
+	v.SetDefault("KNOWLEDGE_EMBEDDER", "mock")
+	v.SetDefault("KNOWLEDGE_EMBEDDING_MODEL", "text-embedding-3-small")
+	v.SetDefault("KNOWLEDGE_OLLAMA_MODEL", "nomic-embed-text")
+	v.SetDefault("KNOWLEDGE_VECTOR_STORE", "memory")
+	v.SetDefault("SOCIAL_CONCURRENCY", 8)
+	v.SetDefault("SOCIAL_MAX_RETRIES", 3)
+	v.SetDefault("IOT_COMMAND_TIMEOUT_SECONDS", 300)
 
 	cfg := &Config{
 		// Core
@@ -120,9 +245,15 @@ func Load() (*Config, error) {
 		RedisURL: v.GetString("REDIS_URL"),
 
 		// Encryption
-		EncryptionKey: v.GetString("ENCRYPTION_KEY"),
+		EncryptionKey:          v.GetString("ENCRYPTION_KEY"),
+		EncryptionKeyVersion:   v.GetInt("ENCRYPTION_KEY_VERSION"),
+		EncryptionPreviousKeys: v.GetString("ENCRYPTION_PREVIOUS_KEYS"),
 
 		// Supabase
+		JWTSecret:           v.GetString("JWT_SECRET"),
+		JWTAccessTTLMinutes: v.GetInt("JWT_ACCESS_TTL_MINUTES"),
+		JWTRefreshTTLDays:   v.GetInt("JWT_REFRESH_TTL_DAYS"),
+
 		SupabaseURL:            v.GetString("SUPABASE_URL"),
 		SupabaseAnonKey:        v.GetString("SUPABASE_ANON_KEY"),
 		SupabaseServiceRoleKey: v.GetString("SUPABASE_SERVICE_ROLE_KEY"),
@@ -131,6 +262,44 @@ func Load() (*Config, error) {
 		FlyAPIToken: v.GetString("FLY_API_TOKEN"),
 		FlyOrg:      v.GetString("FLY_ORG"),
 		FlyRegion:   v.GetString("FLY_REGION"),
+		FlyAppName:  v.GetString("FLY_APP_NAME"),
+
+		FlyWarmPoolSize:        v.GetInt("FLY_WARM_POOL_SIZE"),
+		FlyWarmPoolIdleMinutes: v.GetInt("FLY_WARM_POOL_IDLE_MINUTES"),
+
+		// Execution
+		MaxConcurrentRunsPerTenant: v.GetInt("MAX_CONCURRENT_RUNS_PER_TENANT"),
+		MaxQueuedRunsPerTenant:     v.GetInt("MAX_QUEUED_RUNS_PER_TENANT"),
+		BatchConcurrency:           v.GetInt("BATCH_CONCURRENCY"),
+		DefaultRunTimeoutSeconds:   v.GetInt("DEFAULT_RUN_TIMEOUT_SECONDS"),
+		MaxRunTimeoutSeconds:       v.GetInt("MAX_RUN_TIMEOUT_SECONDS"),
+
+		TenantDeletionGraceDays: v.GetInt("TENANT_DELETION_GRACE_DAYS"),
+
+		CostForecastWindowDays: v.GetInt("COST_FORECAST_WINDOW_DAYS"),
+
+		ResponseCacheEnabled:    v.GetBool("RESPONSE_CACHE_ENABLED"),
+		ResponseCacheTTLMinutes: v.GetInt("RESPONSE_CACHE_TTL_MINUTES"),
+
+		WebhookMaxAttempts:          v.GetInt("WEBHOOK_MAX_ATTEMPTS"),
+		WebhookDisableAfterFailures: v.GetInt("WEBHOOK_DISABLE_AFTER_FAILURES"),
+
+		MaxStoredPromptBytes:   v.GetInt("MAX_STORED_PROMPT_BYTES"),
+		MaxStoredResponseBytes: v.GetInt("MAX_STORED_RESPONSE_BYTES"),
+		PayloadStoreDir:        v.GetString("PAYLOAD_STORE_DIR"),
+
+		AuditOverflowFile: v.GetString("AUDIT_OVERFLOW_FILE"),
+
+		GuardrailBlockedPatterns: v.GetString("GUARDRAIL_BLOCKED_PATTERNS"),
+
+		// Knowledge
+		KnowledgeRequestLogging:      v.GetBool("KNOWLEDGE_REQUEST_LOGGING"),
+		KnowledgeEmbedder:            v.GetString("KNOWLEDGE_EMBEDDER"),
+		KnowledgeEmbeddingModel:      v.GetString("KNOWLEDGE_EMBEDDING_MODEL"),
+		KnowledgeOllamaModel:         v.GetString("KNOWLEDGE_OLLAMA_MODEL"),
+		KnowledgeEmbeddingDimensions: v.GetInt("KNOWLEDGE_EMBEDDING_DIMENSIONS"),
+		KnowledgeVectorStore:         v.GetString("KNOWLEDGE_VECTOR_STORE"),
+		KnowledgeBriefingTokens:      v.GetInt("KNOWLEDGE_BRIEFING_TOKENS"),
 
 		// GitHub
 		GitHubAppID:         v.GetString("GITHUB_APP_ID"),
@@ -151,11 +320,27 @@ func Load() (*Config, error) {
 		GoogleAIAPIKey:  v.GetString("GOOGLE_AI_API_KEY"),
 		OllamaBaseURL:   v.GetString("OLLAMA_BASE_URL"),
 
+		OpenAIBaseURL:     v.GetString("OPENAI_BASE_URL"),
+		OpenAIAPIVersion:  v.GetString("OPENAI_API_VERSION"),
+		OpenAIDeployments: v.GetString("OPENAI_DEPLOYMENTS"),
+		AnthropicBaseURL:  v.GetString("ANTHROPIC_BASE_URL"),
+		GoogleAIBaseURL:   v.GetString("GOOGLE_AI_BASE_URL"),
+		ProviderProxyURL:  v.GetString("PROVIDER_PROXY_URL"),
+
 		// Social Media
 		TwitterAPIKey:        v.GetString("TWITTER_API_KEY"),
 		TwitterAPISecret:     v.GetString("TWITTER_API_SECRET"),
 		LinkedInClientID:     v.GetString("LINKEDIN_CLIENT_ID"),
 		LinkedInClientSecret: v.GetString("LINKEDIN_CLIENT_SECRET"),
+		SocialConcurrency:    v.GetInt("SOCIAL_CONCURRENCY"),
+		SocialMaxRetries:     v.GetInt("SOCIAL_MAX_RETRIES"),
+
+		// IoT
+		MQTTBrokerURL: v.GetString("MQTT_BROKER_URL"),
+		MQTTUsername:  v.GetString("MQTT_USERNAME"),
+		MQTTPassword:  v.GetString("MQTT_PASSWORD"),
+
+		IoTCommandTimeoutSeconds: v.GetInt("IOT_COMMAND_TIMEOUT_SECONDS"),
 
 		// Email
 		SMTPHost:     v.GetString("SMTP_HOST"),
@@ -168,17 +353,35 @@ func Load() (*Config, error) {
 		SlackClientSecret: v.GetString("SLACK_CLIENT_SECRET"),
 		DiscordBotToken:   v.GetString("DISCORD_BOT_TOKEN"),
 
+		// Web Push
+		VAPIDPublicKey:  v.GetString("VAPID_PUBLIC_KEY"),
+		VAPIDPrivateKey: v.GetString("VAPID_PRIVATE_KEY"),
+		VAPIDSubject:    v.GetString("VAPID_SUBJECT"),
+
 		// Monitoring
 		SentryDSN: v.GetString("SENTRY_DSN"),
 	}
 
+	// GOOGLE_API_KEY is the name Google's own SDKs read
+	if cfg.GoogleAIAPIKey == "" {
+		cfg.GoogleAIAPIKey = v.GetString("GOOGLE_API_KEY")
+	}
+
 	// Validate required config
 	if cfg.DatabaseURL == "" {
 		return nil, fmt.Errorf("DATABASE_URL is required")
 	}
 
-	if cfg.EncryptionKey == "" && cfg.Environment == "production" {
-		return nil, fmt.Errorf("ENCRYPTION_KEY is required in production")
+	if cfg.EncryptionKey == "" && !cfg.IsDevelopment() {
+		return nil, fmt.Errorf("ENCRYPTION_KEY is required outside development")
+	}
+
+	if cfg.JWTSecret == "" && cfg.Environment == "production" {
+		return nil, fmt.Errorf("JWT_SECRET is required in production")
+	}
+
+	if cfg.EncryptionKeyVersion < 1 || cfg.EncryptionKeyVersion > 255 {
+		return nil, fmt.Errorf("ENCRYPTION_KEY_VERSION must be between 1 and 255")
 	}
 
 	return cfg, nil
@@ -0,0 +1,71 @@
+package execution
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// =============================================================================
+// Active Runs
+// =============================================================================
+
+// ErrRunCancelled is the cause of a run's context when the run is cancelled
+var ErrRunCancelled = errors.New("run cancelled")
+
+// ActiveRuns tracks the runs in progress so they can be cancelled. Runs are
+// held in memory, so only runs started by this API instance can be cancelled
+// through it.
+type ActiveRuns struct {
+	mu      sync.Mutex
+	cancels map[uuid.UUID]context.CancelCauseFunc
+}
+
+// NewActiveRuns creates an empty registry of active runs
+func NewActiveRuns() *ActiveRuns {
+	return &ActiveRuns{cancels: make(map[uuid.UUID]context.CancelCauseFunc)}
+}
+
+// Start registers a run and returns the context it runs under, which Cancel
+// cancels with ErrRunCancelled. Finish must be called once the run ends.
+func (a *ActiveRuns) Start(ctx context.Context, runID uuid.UUID) context.Context {
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.cancels[runID] = cancel
+	return ctx
+}
+
+// Finish removes a run that has ended and releases its context
+func (a *ActiveRuns) Finish(runID uuid.UUID) {
+	a.mu.Lock()
+	cancel, ok := a.cancels[runID]
+	delete(a.cancels, runID)
+	a.mu.Unlock()
+
+	if ok {
+		cancel(context.Canceled)
+	}
+}
+
+// Cancel cancels a run in progress, reporting whether it was active. The run
+// stays registered until it finishes.
+func (a *ActiveRuns) Cancel(runID uuid.UUID) bool {
+	a.mu.Lock()
+	cancel, ok := a.cancels[runID]
+	a.mu.Unlock()
+
+	if ok {
+		cancel(ErrRunCancelled)
+	}
+	return ok
+}
+
+// Cancelled reports whether ctx, or a context it derives from, was cancelled
+// by Cancel
+func Cancelled(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrRunCancelled)
+}
@@ -6,14 +6,19 @@ import (
 	"strings"
 	"time"
 
+	"github.com/delphi-platform/delphi/backend/internal/knowledge"
 	"github.com/delphi-platform/delphi/backend/internal/models"
 	"github.com/delphi-platform/delphi/backend/internal/providers"
+	"github.com/delphi-platform/delphi/backend/internal/tracing"
 	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // BriefingEngine handles the agent briefing/grooming phase
 type BriefingEngine struct {
-	log *logger.Logger
+	knowledge       *knowledge.Service // nil disables retrieval; see SetKnowledge
+	knowledgeBudget int
+	log             *logger.Logger
 }
 
 // NewBriefingEngine creates a new briefing engine
@@ -92,20 +97,49 @@ type BriefingResult struct {
 	EstimatedTokens  int
 	Duration         time.Duration
 	Warnings         []string
+
+	// agent and context are what the prompt was built from, kept so it can
+	// be rebuilt with less context if it overflows the model's window
+	agent   *models.Agent
+	context *BriefingContext
 }
 
 // Brief performs the briefing process for an agent
 func (e *BriefingEngine) Brief(ctx context.Context, agent *models.Agent, briefingContext *BriefingContext) (*BriefingResult, error) {
+	_, span := tracing.Start(ctx, "briefing",
+		attribute.String("delphi.agent_id", agent.ID.String()),
+		attribute.String("delphi.briefing.depth", agent.Config.BriefingDepth),
+	)
+	defer span.End()
+
 	start := time.Now()
 	e.log.Infow("starting briefing", "agent_id", agent.ID, "depth", agent.Config.BriefingDepth)
 
 	result := &BriefingResult{
 		Success: true,
+		agent:   agent,
+		context: briefingContext,
 	}
 
-	// Build the enhanced system prompt based on briefing depth
+	result.EnhancedPrompt = e.buildPrompt(agent, briefingContext)
+	result.ContextSummary = e.generateContextSummary(briefingContext)
+	result.EstimatedTokens = providers.CountTokens(agent.Model, result.EnhancedPrompt)
+	result.Duration = time.Since(start)
+	span.SetAttributes(attribute.Int("delphi.briefing.estimated_tokens", result.EstimatedTokens))
+
+	e.log.Infow("briefing complete", 
+		"agent_id", agent.ID, 
+		"duration_ms", result.Duration.Milliseconds(),
+		"estimated_tokens", result.EstimatedTokens,
+	)
+
+	return result, nil
+}
+
+// buildPrompt builds the enhanced system prompt based on briefing depth
+func (e *BriefingEngine) buildPrompt(agent *models.Agent, briefingContext *BriefingContext) string {
 	var enhancedPrompt strings.Builder
-	
+
 	// Start with base system prompt
 	enhancedPrompt.WriteString(agent.SystemPrompt)
 	enhancedPrompt.WriteString("\n\n")
@@ -123,18 +157,7 @@ func (e *BriefingEngine) Brief(ctx context.Context, agent *models.Agent, briefin
 	// Add universal guidelines
 	e.addUniversalGuidelines(&enhancedPrompt, agent)
 
-	result.EnhancedPrompt = enhancedPrompt.String()
-	result.ContextSummary = e.generateContextSummary(briefingContext)
-	result.EstimatedTokens = len(result.EnhancedPrompt) / 4 // Approximate
-	result.Duration = time.Since(start)
-
-	e.log.Infow("briefing complete", 
-		"agent_id", agent.ID, 
-		"duration_ms", result.Duration.Milliseconds(),
-		"estimated_tokens", result.EstimatedTokens,
-	)
-
-	return result, nil
+	return enhancedPrompt.String()
 }
 
 // addQuickContext adds minimal context for quick briefings
@@ -188,6 +211,15 @@ func (e *BriefingEngine) addStandardContext(b *strings.Builder, ctx *BriefingCon
 		}
 		b.WriteString("\n")
 	}
+
+	// Relevant knowledge, already bounded by the retrieval budget
+	if ctx.KnowledgeContext != nil && len(ctx.KnowledgeContext.RelevantDocuments) > 0 {
+		b.WriteString("### Relevant Knowledge\n")
+		for _, doc := range ctx.KnowledgeContext.RelevantDocuments {
+			b.WriteString(fmt.Sprintf("**%s** (%s)\n", doc.Title, doc.Source))
+			b.WriteString(fmt.Sprintf("%s\n\n", doc.Summary))
+		}
+	}
 }
 
 // addFullContext adds comprehensive context for full briefings
@@ -197,16 +229,8 @@ func (e *BriefingEngine) addFullContext(b *strings.Builder, ctx *BriefingContext
 	// Start with standard context
 	e.addStandardContext(b, ctx)
 	
-	// Add knowledge context
+	// Add knowledge updates; relevant documents come with the standard context
 	if ctx.KnowledgeContext != nil {
-		if len(ctx.KnowledgeContext.RelevantDocuments) > 0 {
-			b.WriteString("### Relevant Knowledge\n")
-			for _, doc := range ctx.KnowledgeContext.RelevantDocuments[:min(5, len(ctx.KnowledgeContext.RelevantDocuments))] {
-				b.WriteString(fmt.Sprintf("**%s** (%s)\n", doc.Title, doc.Source))
-				b.WriteString(fmt.Sprintf("%s\n\n", doc.Summary))
-			}
-		}
-		
 		if len(ctx.KnowledgeContext.RecentUpdates) > 0 {
 			b.WriteString("### Recent Updates\n")
 			for _, update := range ctx.KnowledgeContext.RecentUpdates[:min(5, len(ctx.KnowledgeContext.RecentUpdates))] {
@@ -297,12 +321,17 @@ func (e *BriefingEngine) generateContextSummary(ctx *BriefingContext) string {
 	return strings.Join(parts, " | ")
 }
 
-// BuildCompletionRequest builds a completion request with briefing context
-func (e *BriefingEngine) BuildCompletionRequest(agent *models.Agent, briefingResult *BriefingResult, userPrompt string) *providers.CompletionRequest {
+// BuildCompletionRequest builds a completion request with briefing context.
+// Context is trimmed from the briefing if the request would not fit in the
+// model's context window; see fitContextWindow.
+func (e *BriefingEngine) BuildCompletionRequest(agent *models.Agent, briefingResult *BriefingResult, userPrompt string, counter TokenCounter) *providers.CompletionRequest {
+	e.fitContextWindow(agent, briefingResult, userPrompt, counter)
+
 	return providers.NewRequestBuilder(agent.Model).
 		WithSystemPrompt(briefingResult.EnhancedPrompt).
 		WithUserMessage(userPrompt).
-		WithTemperature(agent.Config.Temperature).
+		WithTemperature(agent.Config.SamplingTemperature()).
+		WithTopP(agent.Config.TopP).
 		WithMaxTokens(agent.Config.MaxTokens).
 		Build()
 }
@@ -0,0 +1,342 @@
+package execution
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// =============================================================================
+// Per-Tenant Concurrency
+// =============================================================================
+
+// ErrMaxConcurrentRuns is returned when a tenant already has as many runs
+// executing and queued as it is allowed
+var ErrMaxConcurrentRuns = errors.New("max concurrent runs reached")
+
+// LimitFunc returns how many runs a tenant may execute at once, typically from
+// its plan. Zero or less means the plan sets no limit.
+type LimitFunc func(ctx context.Context, tenantID uuid.UUID) int
+
+// ConcurrencyConfig controls the per-tenant concurrency limiter
+type ConcurrencyConfig struct {
+	// Max caps every tenant's limit, including plans without one. Zero leaves
+	// plan limits uncapped.
+	Max int
+
+	// MaxQueued is how many runs per tenant may wait for a slot. Runs beyond
+	// the limit and the queue are rejected with ErrMaxConcurrentRuns.
+	MaxQueued int
+
+	// PollInterval is how often queued runs check a shared SlotStore for
+	// slots freed by other instances. It defaults to a second.
+	PollInterval time.Duration
+}
+
+// SlotStore shares tenants' running slots between API instances
+type SlotStore interface {
+	// Acquire takes one of the tenant's limit slots for the slot id,
+	// reporting false when they are all taken
+	Acquire(ctx context.Context, tenantID uuid.UUID, id string, limit int) (bool, error)
+
+	// Release gives the slot id back
+	Release(ctx context.Context, tenantID uuid.UUID, id string) error
+
+	// Active returns how many of the tenant's slots are taken
+	Active(ctx context.Context, tenantID uuid.UUID) (int, error)
+}
+
+// ConcurrencyLimiter bounds how many runs each tenant executes at once.
+// Runs over the limit wait in a FIFO queue per tenant. Slots are held in
+// memory, so the limit applies per API instance unless a SlotStore is set.
+type ConcurrencyLimiter struct {
+	limit LimitFunc
+	cfg   ConcurrencyConfig
+	store SlotStore
+	log   *logger.Logger
+
+	onQueue func(tenantID uuid.UUID)
+
+	mu      sync.Mutex
+	tenants map[uuid.UUID]*tenantSlots
+}
+
+// tenantSlots tracks one tenant's running and waiting runs
+type tenantSlots struct {
+	active  int
+	limit   int
+	waiters []*Slot
+}
+
+// Slot is a run's claim on one of its tenant's concurrent runs. A slot is
+// either granted or waiting in the tenant's queue. Release must be called
+// once the run ends, whether or not it was granted.
+type Slot struct {
+	limiter  *ConcurrencyLimiter
+	tenantID uuid.UUID
+	id       string
+	ready    chan struct{}
+	granted  bool
+	shared   bool // holds a slot in the limiter's store
+	released bool
+}
+
+// ConcurrencyStats reports a tenant's current concurrency
+type ConcurrencyStats struct {
+	Active int `json:"active"`
+	Queued int `json:"queued"`
+	Limit  int `json:"limit"` // 0 when unlimited
+}
+
+// NewConcurrencyLimiter creates a limiter that resolves each tenant's limit
+// with limit
+func NewConcurrencyLimiter(limit LimitFunc, cfg ConcurrencyConfig) *ConcurrencyLimiter {
+	if cfg.MaxQueued < 0 {
+		cfg.MaxQueued = 0
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	return &ConcurrencyLimiter{
+		limit:   limit,
+		cfg:     cfg,
+		tenants: make(map[uuid.UUID]*tenantSlots),
+	}
+}
+
+// SetStore shares slots between instances through store, so a tenant's limit
+// holds across all of them. Queued runs are granted slots freed by other
+// instances by polling the store. When the store fails, slots fall back to
+// this instance's count and the error is logged.
+func (l *ConcurrencyLimiter) SetStore(store SlotStore, log *logger.Logger) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.store = store
+	l.log = log
+}
+
+// SetQueueListener sets a function called when a tenant's queue moves up, as
+// queued runs are granted slots or leave the queue. It is called on its own
+// goroutine, so it may use the limiter.
+func (l *ConcurrencyLimiter) SetQueueListener(listener func(tenantID uuid.UUID)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onQueue = listener
+}
+
+// Limit returns how many runs the tenant may execute at once, 0 meaning no limit
+func (l *ConcurrencyLimiter) Limit(ctx context.Context, tenantID uuid.UUID) int {
+	limit := 0
+	if l.limit != nil {
+		limit = l.limit(ctx, tenantID)
+	}
+	if l.cfg.Max > 0 && (limit <= 0 || limit > l.cfg.Max) {
+		limit = l.cfg.Max
+	}
+	if limit < 0 {
+		limit = 0
+	}
+	return limit
+}
+
+// Reserve claims a slot for one of the tenant's runs without blocking. The
+// slot is granted straight away when the tenant is under its limit, and
+// queued otherwise. ErrMaxConcurrentRuns is returned when the queue is full.
+func (l *ConcurrencyLimiter) Reserve(ctx context.Context, tenantID uuid.UUID) (*Slot, error) {
+	limit := l.Limit(ctx, tenantID)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	t, ok := l.tenants[tenantID]
+	if !ok {
+		t = &tenantSlots{}
+		l.tenants[tenantID] = t
+	}
+	t.limit = limit
+
+	slot := &Slot{limiter: l, tenantID: tenantID, id: uuid.NewString(), ready: make(chan struct{})}
+	if len(t.waiters) == 0 && (limit == 0 || t.active < limit) && l.grant(ctx, t, slot) {
+		return slot, nil
+	}
+	if len(t.waiters) >= l.cfg.MaxQueued {
+		return nil, fmt.Errorf("%w: %d running, limit %d", ErrMaxConcurrentRuns, t.active, limit)
+	}
+	t.waiters = append(t.waiters, slot)
+	return slot, nil
+}
+
+// Acquire claims a slot for one of the tenant's runs, waiting in the queue
+// until it is granted or ctx is done
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context, tenantID uuid.UUID) (*Slot, error) {
+	slot, err := l.Reserve(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if err := slot.Wait(ctx); err != nil {
+		slot.Release()
+		return nil, err
+	}
+	return slot, nil
+}
+
+// Stats returns the tenant's running and queued runs and its limit. With a
+// store, running runs are counted across instances and queued runs on this
+// one.
+func (l *ConcurrencyLimiter) Stats(ctx context.Context, tenantID uuid.UUID) ConcurrencyStats {
+	stats := ConcurrencyStats{Limit: l.Limit(ctx, tenantID)}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if t, ok := l.tenants[tenantID]; ok {
+		stats.Active = t.active
+		stats.Queued = len(t.waiters)
+	}
+	if l.store != nil && stats.Limit > 0 {
+		active, err := l.store.Active(ctx, tenantID)
+		if err != nil {
+			l.log.Warnw("failed to count shared run slots", "tenant_id", tenantID, "error", err)
+		} else {
+			stats.Active = active
+		}
+	}
+	return stats
+}
+
+// grant gives the slot one of the tenant's running slots, taking it from the
+// store when there is one. It reports false when the store has none left.
+// l.mu must be held.
+func (l *ConcurrencyLimiter) grant(ctx context.Context, t *tenantSlots, slot *Slot) bool {
+	if l.store != nil && t.limit > 0 {
+		ok, err := l.store.Acquire(ctx, slot.tenantID, slot.id, t.limit)
+		switch {
+		case err != nil:
+			l.log.Warnw("failed to take shared run slot, counting this instance's runs only", "tenant_id", slot.tenantID, "error", err)
+		case !ok:
+			return false
+		default:
+			slot.shared = true
+		}
+	}
+	t.active++
+	slot.granted = true
+	close(slot.ready)
+	return true
+}
+
+// promote grants queued runs the slots free under the tenant's limit, oldest
+// first. l.mu must be held.
+func (l *ConcurrencyLimiter) promote(ctx context.Context, tenantID uuid.UUID, t *tenantSlots) {
+	for len(t.waiters) > 0 && (t.limit == 0 || t.active < t.limit) {
+		if !l.grant(ctx, t, t.waiters[0]) {
+			return
+		}
+		t.waiters = t.waiters[1:]
+	}
+	if t.active == 0 && len(t.waiters) == 0 {
+		delete(l.tenants, tenantID)
+	}
+}
+
+// queueMoved tells the queue listener that the tenant's remaining queued runs
+// moved up from queued places. l.mu must be held.
+func (l *ConcurrencyLimiter) queueMoved(tenantID uuid.UUID, t *tenantSlots, queued int) {
+	if l.onQueue != nil && len(t.waiters) > 0 && len(t.waiters) < queued {
+		go l.onQueue(tenantID)
+	}
+}
+
+// Position returns the slot's 1-based place in its tenant's queue, or 0 once
+// it has been granted or released
+func (s *Slot) Position() int {
+	l := s.limiter
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if t, ok := l.tenants[s.tenantID]; ok {
+		for i, waiter := range t.waiters {
+			if waiter == s {
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
+
+// Wait blocks until the slot is granted or ctx is done. A slot that gave up
+// waiting still has to be released.
+func (s *Slot) Wait(ctx context.Context) error {
+	l := s.limiter
+	var poll <-chan time.Time
+	if l.store != nil {
+		ticker := time.NewTicker(l.cfg.PollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	for {
+		select {
+		case <-s.ready:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-poll:
+			// Another instance may have freed a slot
+			l.mu.Lock()
+			if t, ok := l.tenants[s.tenantID]; ok {
+				queued := len(t.waiters)
+				l.promote(context.Background(), s.tenantID, t)
+				l.queueMoved(s.tenantID, t, queued)
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+// Release gives the slot back, or leaves the queue if it was never granted,
+// and grants the next queued run its slot. It is safe to call more than once,
+// so it can be deferred alongside an earlier explicit release.
+func (s *Slot) Release() {
+	l := s.limiter
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if s.released {
+		return
+	}
+	s.released = true
+
+	t, ok := l.tenants[s.tenantID]
+	if !ok {
+		return
+	}
+	queued := len(t.waiters)
+	if s.granted {
+		t.active--
+	} else {
+		for i, waiter := range t.waiters {
+			if waiter == s {
+				t.waiters = append(t.waiters[:i], t.waiters[i+1:]...)
+				break
+			}
+		}
+	}
+
+	// The run may have ended because its context did, so the store is
+	// released and the next run granted without it
+	ctx := context.Background()
+	if s.shared {
+		if err := l.store.Release(ctx, s.tenantID, s.id); err != nil {
+			l.log.Warnw("failed to release shared run slot", "tenant_id", s.tenantID, "error", err)
+		}
+	}
+
+	// Hand freed slots to the oldest waiters
+	l.promote(ctx, s.tenantID, t)
+	l.queueMoved(s.tenantID, t, queued)
+}
@@ -0,0 +1,79 @@
+package execution
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// =============================================================================
+// Shared Run Slots
+// =============================================================================
+
+// runSlotsKeyPrefix namespaces tenants' run slots in Redis
+const runSlotsKeyPrefix = "run_slots:"
+
+// acquireSlotScript adds ARGV[1] to the sorted set in KEYS[1] unless it holds
+// ARGV[2] unexpired slots already. Each slot is scored by when it expires,
+// ARGV[3] milliseconds from now, so the slots of an instance that died
+// without releasing them are freed in time.
+var acquireSlotScript = redis.NewScript(`
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[2]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], now + tonumber(ARGV[3]), ARGV[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return 1
+`)
+
+// activeSlotsScript counts the unexpired slots in the sorted set in KEYS[1]
+var activeSlotsScript = redis.NewScript(`
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+return redis.call('ZCOUNT', KEYS[1], '(' .. now, '+inf')
+`)
+
+// RedisSlotStore keeps tenants' run slots in Redis, shared by every instance
+type RedisSlotStore struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// NewRedisSlotStore creates a slot store on client. A slot that isn't released
+// expires after ttl, which should outlast the longest run.
+func NewRedisSlotStore(client *redis.Client, ttl time.Duration) *RedisSlotStore {
+	return &RedisSlotStore{client: client, ttl: ttl}
+}
+
+// Acquire takes one of the tenant's limit slots for the slot id
+func (s *RedisSlotStore) Acquire(ctx context.Context, tenantID uuid.UUID, id string, limit int) (bool, error) {
+	acquired, err := acquireSlotScript.Run(ctx, s.client, []string{runSlotsKeyPrefix + tenantID.String()}, id, limit, s.ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return acquired == 1, nil
+}
+
+// Release gives the slot id back
+func (s *RedisSlotStore) Release(ctx context.Context, tenantID uuid.UUID, id string) error {
+	return s.client.ZRem(ctx, runSlotsKeyPrefix+tenantID.String(), id).Err()
+}
+
+// Active returns how many of the tenant's slots are taken
+func (s *RedisSlotStore) Active(ctx context.Context, tenantID uuid.UUID) (int, error) {
+	return activeSlotsScript.Run(ctx, s.client, []string{runSlotsKeyPrefix + tenantID.String()}).Int()
+}
+
+// SlotTTL returns how long shared slots last for runs capped at maxRunTimeout,
+// with a margin for the work around the run. Without a cap, slots last an hour.
+func SlotTTL(maxRunTimeout time.Duration) time.Duration {
+	if maxRunTimeout <= 0 {
+		return time.Hour
+	}
+	return maxRunTimeout + time.Minute
+}
@@ -0,0 +1,163 @@
+package execution
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/providers"
+	"github.com/google/uuid"
+)
+
+// =============================================================================
+// Agent Container Dispatch
+// =============================================================================
+
+const (
+	// agentServicePort is where the agent container serves its task API
+	agentServicePort = 8080
+
+	resultPollInterval = 2 * time.Second
+
+	// maxResultPollFailures is how many polls in a row may fail before the run
+	// is given up on
+	maxResultPollFailures = 3
+)
+
+// Task statuses reported by the agent container
+const (
+	TaskStatusRunning   = "running"
+	TaskStatusCompleted = "completed"
+	TaskStatusFailed    = "failed"
+)
+
+// AgentTask is the work sent to an agent container
+type AgentTask struct {
+	RunID        uuid.UUID              `json:"run_id"`
+	Prompt       string                 `json:"prompt"`
+	SystemPrompt string                 `json:"system_prompt"` // the briefed prompt
+	Provider     models.AIProvider      `json:"provider"`
+	Model        string                 `json:"model"`
+	Config       models.AgentConfig     `json:"config"`
+	Context      map[string]interface{} `json:"context,omitempty"`
+}
+
+// AgentTaskResult is an agent container's report on its task
+type AgentTaskResult struct {
+	Status   string               `json:"status"`
+	Response string               `json:"response,omitempty"`
+	Usage    providers.TokenUsage `json:"usage"`
+	Cost     float64              `json:"cost,omitempty"` // 0 when the container leaves pricing to us
+	Error    string               `json:"error,omitempty"`
+}
+
+// agentURL returns the address of a machine's agent service on the app's
+// private network
+func (m *FlyMachineManager) agentURL(machineID, path string) string {
+	return fmt.Sprintf("http://%s.vm.%s.internal:%d%s", machineID, m.appName, agentServicePort, path)
+}
+
+// SubmitTask sends a task to the agent container on a machine. The container
+// may finish it straight away or report it running; see GetTaskResult.
+func (m *FlyMachineManager) SubmitTask(ctx context.Context, machineID string, task *AgentTask) (*AgentTaskResult, error) {
+	body, err := json.Marshal(task)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal task: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", m.agentURL(machineID, "/tasks"), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return m.doTaskRequest(req)
+}
+
+// GetTaskResult returns the status of a task submitted to a machine
+func (m *FlyMachineManager) GetTaskResult(ctx context.Context, machineID string, runID uuid.UUID) (*AgentTaskResult, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", m.agentURL(machineID, "/tasks/"+runID.String()), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	return m.doTaskRequest(req)
+}
+
+func (m *FlyMachineManager) doTaskRequest(req *http.Request) (*AgentTaskResult, error) {
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("agent container unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("agent container error: %d - %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var result AgentTaskResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode agent result: %w", err)
+	}
+	switch result.Status {
+	case TaskStatusRunning, TaskStatusCompleted, TaskStatusFailed:
+	default:
+		return nil, fmt.Errorf("agent container returned unknown status: %q", result.Status)
+	}
+	return &result, nil
+}
+
+// dispatch sends the task to the machine and polls until the container
+// reports it completed or failed
+func (r *ExecutionRunner) dispatch(ctx context.Context, machineID string, task *AgentTask) (*AgentTaskResult, error) {
+	result, err := r.machineManager.SubmitTask(ctx, machineID, task)
+	if err != nil {
+		return nil, err
+	}
+
+	r.log.Infow("task dispatched", "run_id", task.RunID, "machine_id", machineID)
+
+	ticker := time.NewTicker(resultPollInterval)
+	defer ticker.Stop()
+
+	failures := 0
+	for result.Status == TaskStatusRunning {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		next, err := r.machineManager.GetTaskResult(ctx, machineID, task.RunID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			failures++
+			if failures >= maxResultPollFailures {
+				return nil, err
+			}
+			r.log.Warnw("failed to poll task result", "run_id", task.RunID, "machine_id", machineID, "error", err)
+			continue
+		}
+		failures = 0
+		result = next
+	}
+
+	if result.Status == TaskStatusFailed {
+		reason := result.Error
+		if reason == "" {
+			reason = "no error reported"
+		}
+		return result, fmt.Errorf("agent failed: %s", reason)
+	}
+	return result, nil
+}
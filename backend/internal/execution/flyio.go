@@ -4,14 +4,20 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"time"
 
+	"github.com/delphi-platform/delphi/backend/internal/metrics"
 	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/providers"
+	"github.com/delphi-platform/delphi/backend/internal/security"
+	"github.com/delphi-platform/delphi/backend/internal/tracing"
 	"github.com/delphi-platform/delphi/backend/pkg/logger"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
@@ -83,7 +89,8 @@ func NewFlyMachineManager(apiToken, org, appName, region string, log *logger.Log
 		appName:  appName,
 		region:   region,
 		httpClient: &http.Client{
-			Timeout: 5 * time.Minute,
+			Timeout:   5 * time.Minute,
+			Transport: tracing.Transport(nil),
 		},
 		log: log,
 	}
@@ -93,37 +100,35 @@ func NewFlyMachineManager(apiToken, org, appName, region string, log *logger.Log
 func (m *FlyMachineManager) CreateMachine(ctx context.Context, agent *models.Agent, run *models.AgentRun, secrets map[string]string) (*Machine, error) {
 	machineName := fmt.Sprintf("delphi-agent-%s-%s", agent.ID.String()[:8], run.ID.String()[:8])
 
-	// Prepare environment variables
-	env := map[string]string{
-		"AGENT_ID":    agent.ID.String(),
-		"RUN_ID":      run.ID.String(),
-		"TENANT_ID":   agent.TenantID.String(),
-		"AGENT_TYPE":  string(agent.Type),
-		"AGENT_MODEL": agent.Model,
-	}
-
-	// Merge secrets
-	for k, v := range secrets {
-		env[k] = v
-	}
+	return m.createMachine(ctx, CreateMachineRequest{
+		Name:   machineName,
+		Region: m.region,
+		Config: m.runConfig(agent, run, secrets),
+	})
+}
 
-	// Determine resources based on agent type
-	guest := m.getGuestConfig(agent)
+// CreatePoolMachine creates an idle machine for the warm pool. It carries no
+// run or tenant data until it is assigned to a run with UpdateMachine.
+func (m *FlyMachineManager) CreatePoolMachine(ctx context.Context, image string, guest GuestConfig) (*Machine, error) {
+	machineName := fmt.Sprintf("delphi-pool-%s", uuid.New().String()[:8])
 
-	req := CreateMachineRequest{
+	return m.createMachine(ctx, CreateMachineRequest{
 		Name:   machineName,
 		Region: m.region,
 		Config: MachineConfig{
-			Image: m.getAgentImage(agent.Type),
-			Env:   env,
+			Image: image,
+			Env:   map[string]string{},
 			Guest: guest,
 			Metadata: map[string]string{
-				"agent_id":  agent.ID.String(),
-				"run_id":    run.ID.String(),
-				"tenant_id": agent.TenantID.String(),
+				"pool": "warm",
 			},
 		},
-	}
+	})
+}
+
+func (m *FlyMachineManager) createMachine(ctx context.Context, req CreateMachineRequest) (created *Machine, err error) {
+	ctx, span := tracing.Start(ctx, "fly.machine.create", attribute.String("fly.machine.name", req.Name))
+	defer func() { tracing.End(span, err) }()
 
 	body, err := json.Marshal(req)
 	if err != nil {
@@ -160,8 +165,84 @@ func (m *FlyMachineManager) CreateMachine(ctx context.Context, agent *models.Age
 	return &machine, nil
 }
 
+// UpdateMachine replaces a machine's config. Fly restarts the machine with the
+// new config, which is how a pooled machine is handed the env and secrets of a run.
+func (m *FlyMachineManager) UpdateMachine(ctx context.Context, machineID string, config MachineConfig) (updated *Machine, err error) {
+	ctx, span := tracing.Start(ctx, "fly.machine.update", attribute.String("fly.machine.id", machineID))
+	defer func() { tracing.End(span, err) }()
+
+	body, err := json.Marshal(map[string]interface{}{"config": config})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/apps/%s/machines/%s", flyAPIBaseURL, m.appName, machineID)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Authorization", "Bearer "+m.apiToken)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := m.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("fly API error: %d - %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var machine Machine
+	if err := json.NewDecoder(resp.Body).Decode(&machine); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	m.log.Infow("machine updated", "machine_id", machine.ID, "name", machine.Name)
+
+	return &machine, nil
+}
+
+// runConfig builds the machine config for one agent run
+func (m *FlyMachineManager) runConfig(agent *models.Agent, run *models.AgentRun, secrets map[string]string) MachineConfig {
+	// Prepare environment variables
+	env := map[string]string{
+		"AGENT_ID":    agent.ID.String(),
+		"RUN_ID":      run.ID.String(),
+		"TENANT_ID":   agent.TenantID.String(),
+		"AGENT_TYPE":  string(agent.Type),
+		"AGENT_MODEL": agent.Model,
+	}
+
+	// Merge secrets
+	for k, v := range secrets {
+		env[k] = v
+	}
+
+	return MachineConfig{
+		Image: m.getAgentImage(agent.Type),
+		Env:   env,
+		// Determine resources based on agent type
+		Guest: m.getGuestConfig(agent),
+		Metadata: map[string]string{
+			"agent_id":  agent.ID.String(),
+			"run_id":    run.ID.String(),
+			"tenant_id": agent.TenantID.String(),
+		},
+	}
+}
+
 // WaitForMachine waits for a machine to reach a desired state
-func (m *FlyMachineManager) WaitForMachine(ctx context.Context, machineID string, desiredState string, timeout time.Duration) error {
+func (m *FlyMachineManager) WaitForMachine(ctx context.Context, machineID string, desiredState string, timeout time.Duration) (err error) {
+	ctx, span := tracing.Start(ctx, "fly.machine.wait",
+		attribute.String("fly.machine.id", machineID),
+		attribute.String("fly.machine.state", desiredState),
+	)
+	defer func() { tracing.End(span, err) }()
+
 	deadline := time.Now().Add(timeout)
 
 	for time.Now().Before(deadline) {
@@ -218,7 +299,10 @@ func (m *FlyMachineManager) GetMachine(ctx context.Context, machineID string) (*
 }
 
 // StopMachine stops a running machine
-func (m *FlyMachineManager) StopMachine(ctx context.Context, machineID string) error {
+func (m *FlyMachineManager) StopMachine(ctx context.Context, machineID string) (err error) {
+	ctx, span := tracing.Start(ctx, "fly.machine.stop", attribute.String("fly.machine.id", machineID))
+	defer func() { tracing.End(span, err) }()
+
 	url := fmt.Sprintf("%s/apps/%s/machines/%s/stop", flyAPIBaseURL, m.appName, machineID)
 	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
 	if err != nil {
@@ -242,7 +326,10 @@ func (m *FlyMachineManager) StopMachine(ctx context.Context, machineID string) e
 }
 
 // DestroyMachine destroys a machine
-func (m *FlyMachineManager) DestroyMachine(ctx context.Context, machineID string) error {
+func (m *FlyMachineManager) DestroyMachine(ctx context.Context, machineID string) (err error) {
+	ctx, span := tracing.Start(ctx, "fly.machine.destroy", attribute.String("fly.machine.id", machineID))
+	defer func() { tracing.End(span, err) }()
+
 	url := fmt.Sprintf("%s/apps/%s/machines/%s?force=true", flyAPIBaseURL, m.appName, machineID)
 	req, err := http.NewRequestWithContext(ctx, "DELETE", url, nil)
 	if err != nil {
@@ -308,19 +395,67 @@ func (m *FlyMachineManager) getGuestConfig(agent *models.Agent) GuestConfig {
 // ExecutionRunner orchestrates agent execution
 type ExecutionRunner struct {
 	machineManager *FlyMachineManager
+	machinePool    *MachinePool
+	runLogs        RunLogSink
 	briefingEngine *BriefingEngine
+	costCalculator *providers.CostCalculator
+	guardrail      security.Guardrail
+	audit          *security.AuditService
+	toolLoop       *ToolLoop
+	providers      *providers.Manager
+	runTimeout     time.Duration
+	maxRunTimeout  time.Duration
 	log            *logger.Logger
 }
 
 // NewExecutionRunner creates a new execution runner
 func NewExecutionRunner(machineManager *FlyMachineManager, briefingEngine *BriefingEngine, log *logger.Logger) *ExecutionRunner {
+	costCalculator := providers.NewCostCalculator()
+	for model, info := range providers.DefaultPricing() {
+		costCalculator.SetPricing(model, info)
+	}
+
 	return &ExecutionRunner{
 		machineManager: machineManager,
 		briefingEngine: briefingEngine,
+		costCalculator: costCalculator,
 		log:            log,
 	}
 }
 
+// SetRunTimeouts sets how long runs of agents without a timeout may take, and
+// caps every run at max (0 leaves runs uncapped)
+func (r *ExecutionRunner) SetRunTimeouts(def, max time.Duration) {
+	r.runTimeout = def
+	r.maxRunTimeout = max
+}
+
+// SetMachinePool enables warm starts for requests that opt into them
+func (r *ExecutionRunner) SetMachinePool(pool *MachinePool) {
+	r.machinePool = pool
+}
+
+// SetRunLogSink forwards the output of each run's machine to the run's log
+func (r *ExecutionRunner) SetRunLogSink(sink RunLogSink) {
+	r.runLogs = sink
+}
+
+// SetGuardrail checks each run's prompt before it starts and its response
+// before it is returned. Blocked and redacted content is recorded through audit,
+// which may be nil.
+func (r *ExecutionRunner) SetGuardrail(guardrail security.Guardrail, audit *security.AuditService) {
+	r.guardrail = guardrail
+	r.audit = audit
+}
+
+// SetToolLoop runs agents in-process when no machine is available: the
+// agent's provider is called from manager, and the tools the model calls are
+// executed by loop until it gives a final answer
+func (r *ExecutionRunner) SetToolLoop(loop *ToolLoop, manager *providers.Manager) {
+	r.toolLoop = loop
+	r.providers = manager
+}
+
 // ExecutionRequest represents an execution request
 type ExecutionRequest struct {
 	Agent           *models.Agent
@@ -329,6 +464,12 @@ type ExecutionRequest struct {
 	Context         map[string]interface{}
 	Secrets         map[string]string
 	BriefingContext *BriefingContext
+
+	// WarmPool assigns a pooled machine when one is available; see WarmPoolEnabled
+	WarmPool bool
+
+	// Tier is the tenant's plan, which labels the run's metrics
+	Tier string
 }
 
 // ExecutionResult represents the result of an execution
@@ -337,22 +478,81 @@ type ExecutionResult struct {
 	Success      bool
 	Response     string
 	TokensUsed   int
+	InputTokens  int
+	OutputTokens int
 	Cost         float64
 	Duration     time.Duration
 	MachineID    string
+	WarmStart    bool
 	Error        string
+
+	// Provider and Model served the run: the agent's own model, or the model
+	// of its fallback chain that answered. Attempts lists every model tried
+	// when the agent has a fallback chain.
+	Provider models.AIProvider
+	Model    string
+	Attempts []ModelAttempt
+}
+
+// ModelAttempt is a model of an agent's fallback chain that a run tried
+type ModelAttempt struct {
+	Provider models.AIProvider `json:"provider"`
+	Model    string            `json:"model"`
+	Error    string            `json:"error,omitempty"`
 }
 
-// Execute runs an agent in a sandboxed container
+// Execute runs an agent in a sandboxed container, recording the run in the
+// execution metrics and as an execution span
 func (r *ExecutionRunner) Execute(ctx context.Context, req *ExecutionRequest) (*ExecutionResult, error) {
+	ctx, span := tracing.Start(ctx, "execution",
+		attribute.String("delphi.run_id", req.Run.ID.String()),
+		attribute.String("delphi.agent_id", req.Agent.ID.String()),
+		attribute.String("delphi.provider", string(req.Agent.Provider)),
+		attribute.String("delphi.model", req.Agent.Model),
+	)
+	result, err := r.execute(ctx, req)
+	tracing.RecordUsage(span, result.InputTokens, result.OutputTokens, result.Cost)
+	if result.MachineID != "" {
+		span.SetAttributes(attribute.String("fly.machine.id", result.MachineID), attribute.Bool("fly.machine.warm", result.WarmStart))
+	}
+	tracing.End(span, err)
+
+	status := models.RunStatusCompleted
+	switch {
+	case err != nil && ctx.Err() != nil:
+		status = models.RunStatusCancelled
+	case err != nil:
+		status = models.RunStatusFailed
+	}
+	metrics.RecordExecution(string(status), string(req.Agent.Provider), req.Tier, result.InputTokens, result.OutputTokens, result.Cost)
+	return result, err
+}
+
+// execute runs an agent for Execute
+func (r *ExecutionRunner) execute(ctx context.Context, req *ExecutionRequest) (*ExecutionResult, error) {
 	start := time.Now()
 	result := &ExecutionResult{
-		RunID:   req.Run.ID,
-		Success: false,
+		RunID:    req.Run.ID,
+		Success:  false,
+		Provider: req.Agent.Provider,
+		Model:    req.Agent.Model,
+	}
+
+	if r.guardrail != nil {
+		if err := security.EnforceInput(ctx, r.guardrail, r.audit, req.Run.TenantID, req.Agent.ID, req.Prompt, req.Agent.Config.Guardrails); err != nil {
+			r.log.Warnw("run prompt rejected by guardrails", "run_id", req.Run.ID, "agent_id", req.Agent.ID, "error", err)
+			result.Error = err.Error()
+			return result, err
+		}
 	}
 
-	// Step 1: Perform briefing
-	briefingResult, err := r.briefingEngine.Brief(ctx, req.Agent, req.BriefingContext)
+	// Step 1: Perform briefing, with the knowledge relevant to the prompt.
+	// Retrieval only adds context, so the run goes ahead without it on failure.
+	briefingContext, err := r.briefingEngine.Retrieve(ctx, req.Agent, req.Prompt, req.BriefingContext)
+	if err != nil {
+		r.log.Warnw("knowledge retrieval failed", "run_id", req.Run.ID, "agent_id", req.Agent.ID, "error", err)
+	}
+	briefingResult, err := r.briefingEngine.Brief(ctx, req.Agent, briefingContext)
 	if err != nil {
 		result.Error = fmt.Sprintf("briefing failed: %v", err)
 		return result, err
@@ -365,36 +565,277 @@ func (r *ExecutionRunner) Execute(ctx context.Context, req *ExecutionRequest) (*
 
 	// Step 2: Create machine (in production)
 	if r.machineManager != nil && r.machineManager.apiToken != "" {
-		machine, err := r.machineManager.CreateMachine(ctx, req.Agent, req.Run, req.Secrets)
+		machineStart := time.Now()
+		machine, warm, err := r.startMachine(ctx, req)
 		if err != nil {
-			result.Error = fmt.Sprintf("failed to create machine: %v", err)
+			result.Error = err.Error()
 			return result, err
 		}
 		result.MachineID = machine.ID
-
-		// Wait for machine to be ready
-		if err := r.machineManager.WaitForMachine(ctx, machine.ID, "started", 2*time.Minute); err != nil {
-			r.machineManager.DestroyMachine(ctx, machine.ID)
-			result.Error = fmt.Sprintf("machine failed to start: %v", err)
+		result.WarmStart = warm
+
+		// Deferred first so it runs last, once the log stream is torn down
+		defer r.releaseMachine(ctx, req, machine.ID)
+
+		r.log.Infow("machine ready",
+			"run_id", req.Run.ID,
+			"machine_id", machine.ID,
+			"warm_start", result.WarmStart,
+			"startup_ms", time.Since(machineStart).Milliseconds(),
+		)
+
+		timeout := RunTimeout(req.Agent.Config.TimeoutSeconds, r.runTimeout, r.maxRunTimeout)
+		runCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		logs := r.streamLogs(runCtx, req.Run.ID, machine.ID)
+		defer logs.Stop()
+
+		task := &AgentTask{
+			RunID:        req.Run.ID,
+			Prompt:       req.Prompt,
+			SystemPrompt: briefingResult.EnhancedPrompt,
+			Provider:     req.Agent.Provider,
+			Model:        req.Agent.Model,
+			Config:       req.Agent.Config,
+			Context:      req.Context,
+		}
+		taskResult, err := r.dispatch(runCtx, machine.ID, task)
+		result.Duration = time.Since(start)
+		if err != nil {
+			switch {
+			case errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil:
+				// The machine is destroyed by the deferred release
+				err = TimeoutError(timeout)
+			case ctx.Err() != nil:
+				err = fmt.Errorf("run cancelled: %w", ctx.Err())
+			}
+			result.Error = err.Error()
+			if taskResult != nil {
+				r.recordUsage(result, req.Agent.Model, taskResult)
+			}
 			return result, err
 		}
 
-		// In production, we would:
-		// 1. Send the request to the agent container
-		// 2. Stream logs back to the client
-		// 3. Collect the response
-		// 4. Destroy the machine
+		r.recordUsage(result, req.Agent.Model, taskResult)
+		return r.finishResponse(ctx, req, result, taskResult.Response)
+	}
 
-		defer r.machineManager.DestroyMachine(context.Background(), machine.ID)
+	if r.toolLoop != nil && r.providers != nil {
+		return r.executeInProcess(ctx, req, result, briefingResult, start)
 	}
 
-	// For now, simulate successful execution
-	result.Success = true
-	result.Response = "Execution completed successfully"
+	// Without a Fly token (local development), simulate successful execution
+	result.InputTokens = briefingResult.EstimatedTokens
+	result.OutputTokens = 500
 	result.TokensUsed = briefingResult.EstimatedTokens + 500
 	result.Cost = float64(result.TokensUsed) * 0.00001
 	result.Duration = time.Since(start)
 
+	return r.finishResponse(ctx, req, result, "Execution completed successfully")
+}
+
+// executeInProcess completes the run through the agent's provider, executing
+// the tools the model calls. When a model fails with a retryable error, or its
+// provider isn't configured, the run falls back to the next model of the
+// agent's fallback chain. Usage covers every completion of every model tried,
+// each priced at its own model's rates.
+func (r *ExecutionRunner) executeInProcess(ctx context.Context, req *ExecutionRequest, result *ExecutionResult, briefingResult *BriefingResult, start time.Time) (*ExecutionResult, error) {
+	timeout := RunTimeout(req.Agent.Config.TimeoutSeconds, r.runTimeout, r.maxRunTimeout)
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	chain := modelChain(req.Agent)
+	var loopResult *ToolLoopResult
+	var err error
+	for i, choice := range chain {
+		var unavailable bool
+		loopResult, unavailable, err = r.completeWith(runCtx, req, briefingResult, choice)
+		result.Provider, result.Model = choice.Provider, choice.Model
+		if loopResult != nil {
+			result.TokensUsed += loopResult.Usage.TotalTokens
+			result.InputTokens += loopResult.Usage.PromptTokens
+			result.OutputTokens += loopResult.Usage.CompletionTokens
+			result.Cost += r.costCalculator.Calculate(choice.Model, loopResult.Usage)
+		}
+		if len(chain) > 1 {
+			attempt := ModelAttempt{Provider: choice.Provider, Model: choice.Model}
+			if err != nil {
+				attempt.Error = err.Error()
+			}
+			result.Attempts = append(result.Attempts, attempt)
+		}
+
+		if err == nil || i == len(chain)-1 || runCtx.Err() != nil || !(unavailable || providers.Retryable(err)) {
+			break
+		}
+		next := chain[i+1]
+		r.log.Warnw("falling back to next model",
+			"run_id", req.Run.ID,
+			"provider", choice.Provider,
+			"model", choice.Model,
+			"next_provider", next.Provider,
+			"next_model", next.Model,
+			"error", err,
+		)
+		r.appendRunLog(ctx, req.Run.ID, models.LogLevelWarn, "falling back to next model", map[string]interface{}{
+			"provider":      choice.Provider,
+			"model":         choice.Model,
+			"next_provider": next.Provider,
+			"next_model":    next.Model,
+			"error":         err.Error(),
+		})
+	}
+	result.Duration = time.Since(start)
+	if err != nil {
+		switch {
+		case errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil:
+			err = TimeoutError(timeout)
+		case len(result.Attempts) == len(chain) && len(chain) > 1:
+			err = fmt.Errorf("all %d models in the fallback chain failed: %w", len(chain), err)
+		}
+		result.Error = err.Error()
+		return result, err
+	}
+
+	r.log.Infow("in-process run complete",
+		"run_id", req.Run.ID,
+		"provider", result.Provider,
+		"model", result.Model,
+		"iterations", loopResult.Iterations,
+		"tool_calls", loopResult.ToolCalls,
+	)
+	return r.finishResponse(ctx, req, result, loopResult.Response.Message.Content)
+}
+
+// completeWith runs the tool loop with one model of the agent's chain. It
+// reports whether the model's provider is not configured, so the run can fall
+// back to the next model.
+func (r *ExecutionRunner) completeWith(ctx context.Context, req *ExecutionRequest, briefingResult *BriefingResult, choice models.ModelChoice) (*ToolLoopResult, bool, error) {
+	provider, err := r.providers.GetProvider(string(choice.Provider))
+	if err != nil {
+		return nil, true, err
+	}
+
+	agent := *req.Agent
+	agent.Provider, agent.Model = choice.Provider, choice.Model
+	completion := r.briefingEngine.BuildCompletionRequest(&agent, briefingResult, req.Prompt, provider)
+	loopResult, err := r.toolLoop.Run(ctx, provider, completion, &agent, req.Run)
+	return loopResult, false, err
+}
+
+// modelChain returns the models a run of the agent tries in turn: its own
+// model, then its fallback chain
+func modelChain(agent *models.Agent) []models.ModelChoice {
+	chain := make([]models.ModelChoice, 0, len(agent.Config.FallbackChain)+1)
+	chain = append(chain, models.ModelChoice{Provider: agent.Provider, Model: agent.Model})
+	return append(chain, agent.Config.FallbackChain...)
+}
+
+// appendRunLog adds an entry to the run's log when there is a log sink
+func (r *ExecutionRunner) appendRunLog(ctx context.Context, runID uuid.UUID, level models.LogLevel, message string, metadata map[string]interface{}) {
+	if r.runLogs == nil {
+		return
+	}
+	if err := r.runLogs.AppendRunLog(ctx, runID, level, message, metadata); err != nil {
+		r.log.Warnw("failed to append run log", "run_id", runID, "error", err)
+	}
+}
+
+// finishResponse checks a run's response against the guardrails and sets it
+// on the result, redacted if the agent is configured to. A blocked response
+// fails the run; its usage is still reported.
+func (r *ExecutionRunner) finishResponse(ctx context.Context, req *ExecutionRequest, result *ExecutionResult, response string) (*ExecutionResult, error) {
+	if r.guardrail != nil {
+		checked, err := security.EnforceOutput(ctx, r.guardrail, r.audit, req.Run.TenantID, req.Agent.ID, response, req.Agent.Config.Guardrails)
+		if err != nil {
+			r.log.Warnw("run response rejected by guardrails", "run_id", req.Run.ID, "agent_id", req.Agent.ID, "error", err)
+			result.Error = err.Error()
+			return result, err
+		}
+		response = checked
+	}
+
+	result.Success = true
+	result.Response = response
 	return result, nil
 }
 
+// recordUsage sets the tokens and cost an agent container reported for its
+// task. Cost is priced from the tokens when the container doesn't report it.
+func (r *ExecutionRunner) recordUsage(result *ExecutionResult, model string, taskResult *AgentTaskResult) {
+	usage := taskResult.Usage
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	result.TokensUsed = usage.TotalTokens
+	result.InputTokens = usage.PromptTokens
+	result.OutputTokens = usage.CompletionTokens
+	result.Cost = taskResult.Cost
+	if result.Cost == 0 {
+		result.Cost = r.costCalculator.Calculate(model, usage)
+	}
+}
+
+// defaultRunTimeout applies to agents without a configured timeout
+const defaultRunTimeout = 10 * time.Minute
+
+// ErrRunTimeout is returned when a run takes longer than its timeout
+var ErrRunTimeout = errors.New("timeout")
+
+// RunTimeout returns how long a run may take: the agent's timeout in seconds,
+// or def when it has none, capped at max. A def of 0 uses the package default
+// and a max of 0 leaves the timeout uncapped.
+func RunTimeout(timeoutSeconds int, def, max time.Duration) time.Duration {
+	timeout := def
+	if timeoutSeconds > 0 {
+		timeout = time.Duration(timeoutSeconds) * time.Second
+	}
+	if timeout <= 0 {
+		timeout = defaultRunTimeout
+	}
+	if max > 0 && timeout > max {
+		timeout = max
+	}
+	return timeout
+}
+
+// TimeoutError reports a run stopped at its timeout; it wraps ErrRunTimeout
+func TimeoutError(timeout time.Duration) error {
+	return fmt.Errorf("%w: run exceeded its %s limit", ErrRunTimeout, timeout)
+}
+
+// releaseMachine destroys the run's machine, through the pool if it came from
+// it. The machine is destroyed even if ctx is cancelled; ctx only carries the
+// run's trace.
+func (r *ExecutionRunner) releaseMachine(ctx context.Context, req *ExecutionRequest, machineID string) {
+	if req.WarmPool && r.machinePool != nil {
+		r.machinePool.Release(machineID)
+		return
+	}
+	r.machineManager.DestroyMachine(context.WithoutCancel(ctx), machineID)
+}
+
+// startMachine returns a started machine for the run and whether it was a warm
+// start. The warm pool is used when the request opted in and a pool is configured.
+func (r *ExecutionRunner) startMachine(ctx context.Context, req *ExecutionRequest) (*Machine, bool, error) {
+	if req.WarmPool && r.machinePool != nil {
+		machine, warm, err := r.machinePool.Acquire(ctx, req.Agent, req.Run, req.Secrets)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to create machine: %w", err)
+		}
+		return machine, warm, nil
+	}
+
+	machine, err := r.machineManager.CreateMachine(ctx, req.Agent, req.Run, req.Secrets)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create machine: %w", err)
+	}
+
+	// Wait for machine to be ready
+	if err := r.machineManager.WaitForMachine(ctx, machine.ID, "started", 2*time.Minute); err != nil {
+		r.machineManager.DestroyMachine(ctx, machine.ID)
+		return nil, false, fmt.Errorf("machine failed to start: %w", err)
+	}
+	return machine, false, nil
+}
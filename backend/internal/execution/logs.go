@@ -0,0 +1,190 @@
+package execution
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/google/uuid"
+)
+
+// =============================================================================
+// Machine Log Streaming
+// =============================================================================
+
+const (
+	flyLogsBaseURL = "https://api.fly.io/api/v1"
+
+	logPollInterval = time.Second
+	logFlushTimeout = 5 * time.Second
+)
+
+// RunLogSink stores a run's log entries and relays them to live subscribers
+type RunLogSink interface {
+	AppendRunLog(ctx context.Context, runID uuid.UUID, level models.LogLevel, message string, metadata map[string]interface{}) error
+}
+
+// LogEntry is one line of output from a machine
+type LogEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Level     string    `json:"level"`
+	Message   string    `json:"message"`
+	Instance  string    `json:"instance"`
+	Region    string    `json:"region"`
+}
+
+// logsResponse is a page of the Fly logs API
+type logsResponse struct {
+	Data []struct {
+		Attributes LogEntry `json:"attributes"`
+	} `json:"data"`
+	Meta struct {
+		NextToken string `json:"next_token"`
+	} `json:"meta"`
+}
+
+// GetLogs returns a machine's log lines written after nextToken, and the token
+// to pass on the next call. An empty token starts from the oldest retained line.
+func (m *FlyMachineManager) GetLogs(ctx context.Context, machineID, nextToken string) ([]LogEntry, string, error) {
+	query := url.Values{"instance": {machineID}}
+	if nextToken != "" {
+		query.Set("next_token", nextToken)
+	}
+	endpoint := fmt.Sprintf("%s/apps/%s/logs?%s", flyLogsBaseURL, m.appName, query.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, nextToken, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+m.apiToken)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return nil, nextToken, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, nextToken, fmt.Errorf("fly API error: %d - %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var page logsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, nextToken, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	entries := make([]LogEntry, len(page.Data))
+	for i, item := range page.Data {
+		entries[i] = item.Attributes
+	}
+	if page.Meta.NextToken != "" {
+		nextToken = page.Meta.NextToken
+	}
+	return entries, nextToken, nil
+}
+
+// logStream forwards a machine's logs to its run's log until stopped
+type logStream struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// streamLogs starts forwarding the machine's logs to the run's log. The
+// stream ends when ctx is done or Stop is called.
+func (r *ExecutionRunner) streamLogs(ctx context.Context, runID uuid.UUID, machineID string) *logStream {
+	s := &logStream{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go r.pollLogs(ctx, s, runID, machineID)
+	return s
+}
+
+// Stop ends the stream after a final poll for lines written since the last
+// one, and waits for it to finish
+func (s *logStream) Stop() {
+	close(s.stop)
+	<-s.done
+}
+
+func (r *ExecutionRunner) pollLogs(ctx context.Context, s *logStream, runID uuid.UUID, machineID string) {
+	defer close(s.done)
+
+	var token string
+	poll := func(ctx context.Context) {
+		entries, next, err := r.machineManager.GetLogs(ctx, machineID, token)
+		if err != nil {
+			if ctx.Err() == nil {
+				r.log.Warnw("failed to fetch machine logs", "run_id", runID, "machine_id", machineID, "error", err)
+			}
+			return
+		}
+		token = next
+		for _, entry := range entries {
+			r.forwardLog(ctx, runID, machineID, entry)
+		}
+	}
+
+	ticker := time.NewTicker(logPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.stop:
+			// Pick up output the machine wrote just before it stopped
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), logFlushTimeout)
+			poll(flushCtx)
+			cancel()
+			return
+		case <-ticker.C:
+			poll(ctx)
+		}
+	}
+}
+
+// forwardLog appends one machine log line to the run's log
+func (r *ExecutionRunner) forwardLog(ctx context.Context, runID uuid.UUID, machineID string, entry LogEntry) {
+	if r.runLogs == nil {
+		return
+	}
+
+	metadata := map[string]interface{}{
+		"source":     "container",
+		"machine_id": machineID,
+	}
+	if !entry.Timestamp.IsZero() {
+		metadata["timestamp"] = entry.Timestamp
+	}
+	if entry.Region != "" {
+		metadata["region"] = entry.Region
+	}
+
+	if err := r.runLogs.AppendRunLog(ctx, runID, containerLogLevel(entry.Level), entry.Message, metadata); err != nil {
+		r.log.Warnw("failed to append machine log", "run_id", runID, "machine_id", machineID, "error", err)
+	}
+}
+
+// containerLogLevel maps a machine log level to a run log level
+func containerLogLevel(level string) models.LogLevel {
+	switch strings.ToLower(level) {
+	case "debug", "trace":
+		return models.LogLevelDebug
+	case "warn", "warning":
+		return models.LogLevelWarn
+	case "error", "fatal", "panic":
+		return models.LogLevelError
+	default:
+		return models.LogLevelInfo
+	}
+}
@@ -0,0 +1,357 @@
+package execution
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+)
+
+// =============================================================================
+// Warm Machine Pool
+// =============================================================================
+
+const (
+	poolMachineStartTimeout = 2 * time.Minute
+	poolMaintainInterval    = time.Minute
+)
+
+// PoolConfig controls the warm machine pool
+type PoolConfig struct {
+	// Size is the number of idle machines kept ready per agent image
+	Size int
+
+	// IdleTimeout is how long an image's pool stays warm after its last run.
+	// Once it elapses the idle machines are destroyed until the next run.
+	IdleTimeout time.Duration
+}
+
+// MachinePool keeps started, idle Fly Machines per agent image so runs can skip
+// the create-and-boot cold start. Machines are single use: an assigned machine
+// is reconfigured with the run's env and secrets, destroyed when the run ends,
+// and replaced in the background.
+type MachinePool struct {
+	manager *FlyMachineManager
+	cfg     PoolConfig
+	log     *logger.Logger
+
+	mu    sync.Mutex
+	pools map[string]*imagePool
+	inUse map[string]*imagePool // machines assigned to runs, by machine ID
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// imagePool is the warm pool for one agent image
+type imagePool struct {
+	image    string
+	guest    GuestConfig
+	idle     []*Machine
+	creating int
+	inUse    int
+	lastUsed time.Time
+	hits     int64
+	misses   int64
+}
+
+// PoolStats reports the size and hit rate of one image's pool
+type PoolStats struct {
+	Image    string  `json:"image"`
+	Idle     int     `json:"idle"`
+	Creating int     `json:"creating"`
+	InUse    int     `json:"in_use"`
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	HitRate  float64 `json:"hit_rate"`
+}
+
+// NewMachinePool creates a warm pool and starts its maintenance loop.
+// Pools fill on the first run of each image.
+func NewMachinePool(manager *FlyMachineManager, cfg PoolConfig, log *logger.Logger) *MachinePool {
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = 15 * time.Minute
+	}
+
+	p := &MachinePool{
+		manager: manager,
+		cfg:     cfg,
+		log:     log,
+		pools:   make(map[string]*imagePool),
+		inUse:   make(map[string]*imagePool),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go p.maintainLoop()
+	return p
+}
+
+// WarmPoolEnabled reports whether a tenant has opted into warm machines.
+// Idle machines are billed, so this is off unless the tenant turns it on.
+func WarmPoolEnabled(tenant *models.Tenant) bool {
+	var settings struct {
+		WarmPool bool `json:"warm_pool"`
+	}
+	if len(tenant.Settings) > 0 {
+		json.Unmarshal(tenant.Settings, &settings)
+	}
+	return settings.WarmPool
+}
+
+// Acquire returns a started machine configured for the run. It reports whether
+// the machine came from the pool; on a miss it falls back to a cold start.
+// The machine counts as in use until it is given back with Release.
+func (p *MachinePool) Acquire(ctx context.Context, agent *models.Agent, run *models.AgentRun, secrets map[string]string) (*Machine, bool, error) {
+	config := p.manager.runConfig(agent, run, secrets)
+
+	p.mu.Lock()
+	pool := p.pool(config.Image, config.Guest)
+	pool.lastUsed = time.Now()
+	var machine *Machine
+	if n := len(pool.idle); n > 0 {
+		machine = pool.idle[n-1]
+		pool.idle = pool.idle[:n-1]
+	}
+	p.replenish(pool)
+	p.mu.Unlock()
+
+	if machine != nil {
+		assigned, err := p.assign(ctx, machine, config)
+		if err == nil {
+			p.record(config.Image, true)
+			p.markInUse(config.Image, assigned.ID)
+			p.log.Infow("warm machine assigned", "machine_id", assigned.ID, "run_id", run.ID, "image", config.Image)
+			return assigned, true, nil
+		}
+
+		p.log.Warnw("failed to assign warm machine, falling back to cold start",
+			"machine_id", machine.ID,
+			"run_id", run.ID,
+			"error", err,
+		)
+		go p.destroy(machine.ID)
+	}
+	p.record(config.Image, false)
+
+	cold, err := p.manager.CreateMachine(ctx, agent, run, secrets)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := p.manager.WaitForMachine(ctx, cold.ID, "started", poolMachineStartTimeout); err != nil {
+		p.manager.DestroyMachine(context.Background(), cold.ID)
+		return nil, false, fmt.Errorf("machine failed to start: %w", err)
+	}
+	p.markInUse(config.Image, cold.ID)
+	return cold, false, nil
+}
+
+// Release destroys a machine returned by Acquire once its run is done.
+// Machines are single use, so it is never returned to the idle pool.
+func (p *MachinePool) Release(machineID string) {
+	p.mu.Lock()
+	if pool, ok := p.inUse[machineID]; ok {
+		pool.inUse--
+		delete(p.inUse, machineID)
+	}
+	p.mu.Unlock()
+
+	p.destroy(machineID)
+}
+
+// markInUse counts a machine against its image's pool until it is released
+func (p *MachinePool) markInUse(image, machineID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pool := p.pools[image]
+	pool.inUse++
+	p.inUse[machineID] = pool
+}
+
+// assign resets a pooled machine's env and secrets to those of the run
+func (p *MachinePool) assign(ctx context.Context, machine *Machine, config MachineConfig) (*Machine, error) {
+	updated, err := p.manager.UpdateMachine(ctx, machine.ID, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.manager.WaitForMachine(ctx, updated.ID, "started", poolMachineStartTimeout); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// Stats returns the size and hit rate of each image's pool
+func (p *MachinePool) Stats() []PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := make([]PoolStats, 0, len(p.pools))
+	for _, pool := range p.pools {
+		s := PoolStats{
+			Image:    pool.image,
+			Idle:     len(pool.idle),
+			Creating: pool.creating,
+			InUse:    pool.inUse,
+			Hits:     pool.hits,
+			Misses:   pool.misses,
+		}
+		if total := pool.hits + pool.misses; total > 0 {
+			s.HitRate = float64(pool.hits) / float64(total)
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Image < stats[j].Image })
+	return stats
+}
+
+// Stop stops the maintenance loop and destroys all idle machines
+func (p *MachinePool) Stop() {
+	close(p.stop)
+	<-p.done
+
+	p.mu.Lock()
+	var idle []*Machine
+	for _, pool := range p.pools {
+		idle = append(idle, pool.idle...)
+		pool.idle = nil
+	}
+	p.mu.Unlock()
+
+	for _, machine := range idle {
+		p.destroy(machine.ID)
+	}
+}
+
+// pool returns the pool for an image, creating it on first use. Callers must hold p.mu.
+func (p *MachinePool) pool(image string, guest GuestConfig) *imagePool {
+	pool, ok := p.pools[image]
+	if !ok {
+		pool = &imagePool{image: image, guest: guest}
+		p.pools[image] = pool
+	}
+	return pool
+}
+
+func (p *MachinePool) record(image string, hit bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pool := p.pools[image]
+	if hit {
+		pool.hits++
+	} else {
+		pool.misses++
+	}
+}
+
+// replenish starts creating machines until the pool reaches its target size.
+// Callers must hold p.mu.
+func (p *MachinePool) replenish(pool *imagePool) {
+	if time.Since(pool.lastUsed) > p.cfg.IdleTimeout {
+		return
+	}
+
+	for missing := p.cfg.Size - len(pool.idle) - pool.creating; missing > 0; missing-- {
+		pool.creating++
+		go p.create(pool)
+	}
+}
+
+// create boots one idle machine and adds it to the pool
+func (p *MachinePool) create(pool *imagePool) {
+	ctx, cancel := context.WithTimeout(context.Background(), poolMachineStartTimeout+30*time.Second)
+	defer cancel()
+
+	machine, err := p.manager.CreatePoolMachine(ctx, pool.image, pool.guest)
+	if err == nil {
+		err = p.manager.WaitForMachine(ctx, machine.ID, "started", poolMachineStartTimeout)
+		if err != nil {
+			p.destroy(machine.ID)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pool.creating--
+
+	if err != nil {
+		p.log.Warnw("failed to create warm machine", "image", pool.image, "error", err)
+		return
+	}
+
+	// The pool may have gone idle or been stopped while the machine booted
+	select {
+	case <-p.stop:
+		go p.destroy(machine.ID)
+		return
+	default:
+	}
+	if time.Since(pool.lastUsed) > p.cfg.IdleTimeout || len(pool.idle) >= p.cfg.Size {
+		go p.destroy(machine.ID)
+		return
+	}
+	pool.idle = append(pool.idle, machine)
+}
+
+func (p *MachinePool) destroy(machineID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := p.manager.DestroyMachine(ctx, machineID); err != nil {
+		p.log.Warnw("failed to destroy warm machine", "machine_id", machineID, "error", err)
+	}
+}
+
+func (p *MachinePool) maintainLoop() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(poolMaintainInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.scaleDown()
+			p.logStats()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// scaleDown destroys the idle machines of pools that have not been used
+// within the idle timeout
+func (p *MachinePool) scaleDown() {
+	p.mu.Lock()
+	var expired []*Machine
+	for _, pool := range p.pools {
+		if len(pool.idle) > 0 && time.Since(pool.lastUsed) > p.cfg.IdleTimeout {
+			p.log.Infow("scaling down idle warm pool", "image", pool.image, "machines", len(pool.idle))
+			expired = append(expired, pool.idle...)
+			pool.idle = nil
+		}
+	}
+	p.mu.Unlock()
+
+	for _, machine := range expired {
+		p.destroy(machine.ID)
+	}
+}
+
+func (p *MachinePool) logStats() {
+	for _, s := range p.Stats() {
+		p.log.Infow("warm pool stats",
+			"image", s.Image,
+			"idle", s.Idle,
+			"creating", s.Creating,
+			"in_use", s.InUse,
+			"hits", s.Hits,
+			"misses", s.Misses,
+			"hit_rate", s.HitRate,
+		)
+	}
+}
@@ -0,0 +1,138 @@
+package execution
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/delphi-platform/delphi/backend/internal/knowledge"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/providers"
+)
+
+// =============================================================================
+// Knowledge Retrieval
+// =============================================================================
+
+const (
+	// defaultKnowledgeTokenBudget bounds retrieved context when no budget is set
+	defaultKnowledgeTokenBudget = 4000
+
+	// knowledgeRetrievalLimit is how many chunks are retrieved before the
+	// budget is applied
+	knowledgeRetrievalLimit = 10
+
+	// knowledgeContextShare is the share of the room left in the model's
+	// context window that retrieved context may take
+	knowledgeContextShare = 0.5
+)
+
+// SetKnowledge enables retrieval: runs of agents with knowledge bases are
+// briefed with the chunks most relevant to their prompt. tokenBudget bounds
+// the retrieved context (0 uses the default); it is further limited to what
+// fits in the agent's model's context window.
+func (e *BriefingEngine) SetKnowledge(svc *knowledge.Service, tokenBudget int) {
+	if tokenBudget <= 0 {
+		tokenBudget = defaultKnowledgeTokenBudget
+	}
+	e.knowledge = svc
+	e.knowledgeBudget = tokenBudget
+}
+
+// Retrieve returns the briefing context with the knowledge relevant to prompt
+// added to its documents. Quick briefings and agents without knowledge bases
+// are left as they are. The most relevant chunks are kept, and the lowest
+// scoring ones dropped first, to stay within the token budget.
+func (e *BriefingEngine) Retrieve(ctx context.Context, agent *models.Agent, prompt string, briefingContext *BriefingContext) (*BriefingContext, error) {
+	if briefingContext == nil {
+		briefingContext = &BriefingContext{}
+	}
+	if e.knowledge == nil || len(agent.KnowledgeBases) == 0 || agent.Config.BriefingDepth == "quick" {
+		return briefingContext, nil
+	}
+
+	budget := e.retrievalBudget(agent, prompt)
+	if budget <= 0 {
+		e.log.Warnw("no room for knowledge in the context window", "agent_id", agent.ID, "model", agent.Model)
+		return briefingContext, nil
+	}
+
+	result, err := e.knowledge.Query(ctx, &knowledge.QueryRequest{
+		KnowledgeBaseIDs: agent.KnowledgeBases,
+		Query:            prompt,
+		Limit:            knowledgeRetrievalLimit,
+	})
+	if err != nil {
+		return briefingContext, fmt.Errorf("failed to retrieve knowledge: %w", err)
+	}
+
+	chunks := result.Results
+	sort.SliceStable(chunks, func(i, j int) bool { return chunks[i].Score > chunks[j].Score })
+
+	var docs []DocumentSummary
+	used := 0
+	for _, chunk := range chunks {
+		tokens := providers.CountTokens(agent.Model, chunk.Content)
+		if used+tokens > budget {
+			break
+		}
+		used += tokens
+		docs = append(docs, chunkSummary(chunk))
+	}
+
+	augmented := *briefingContext
+	knowledgeContext := &KnowledgeBriefing{}
+	if briefingContext.KnowledgeContext != nil {
+		*knowledgeContext = *briefingContext.KnowledgeContext
+	}
+	knowledgeContext.RelevantDocuments = append(append([]DocumentSummary(nil), knowledgeContext.RelevantDocuments...), docs...)
+	augmented.KnowledgeContext = knowledgeContext
+
+	e.log.Infow("knowledge retrieved",
+		"agent_id", agent.ID,
+		"chunks", len(chunks),
+		"kept", len(docs),
+		"tokens", used,
+		"budget", budget,
+	)
+	return &augmented, nil
+}
+
+// retrievalBudget returns how many tokens of knowledge a run may be briefed
+// with: the configured budget, limited to a share of the room the model's
+// context window has left after the system prompt, prompt and output
+func (e *BriefingEngine) retrievalBudget(agent *models.Agent, prompt string) int {
+	budget := e.knowledgeBudget
+	info, ok := providers.DefaultPricing()[agent.Model]
+	if !ok || info.ContextWindow == 0 {
+		return budget
+	}
+
+	output := agent.Config.MaxTokens
+	if output == 0 {
+		output = info.MaxOutput
+	}
+	room := info.ContextWindow - output - providers.CountTokens(agent.Model, agent.SystemPrompt) - providers.CountTokens(agent.Model, prompt)
+	return min(budget, int(float64(room)*knowledgeContextShare))
+}
+
+// chunkSummary describes a retrieved chunk for the briefing, titled by the
+// path or title it was ingested with
+func chunkSummary(chunk knowledge.SearchResult) DocumentSummary {
+	title := fmt.Sprintf("Document %s", chunk.DocumentID.String()[:8])
+	for _, key := range []string{"title", "path"} {
+		if value, ok := chunk.Metadata[key].(string); ok && value != "" {
+			title = value
+			break
+		}
+	}
+	source := "knowledge base"
+	if repo, ok := chunk.Metadata["repository"].(string); ok && repo != "" {
+		source = repo
+	}
+	return DocumentSummary{
+		Title:   title,
+		Summary: chunk.Content,
+		Source:  source,
+	}
+}
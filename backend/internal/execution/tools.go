@@ -0,0 +1,373 @@
+package execution
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/delphi-platform/delphi/backend/internal/knowledge"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/providers"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+)
+
+// =============================================================================
+// Tools
+// =============================================================================
+
+// Tool is a function the model can call while a run is in progress
+type Tool interface {
+	// Definition describes the tool to the model
+	Definition() providers.Tool
+
+	// Call runs the tool and returns its result for the model
+	Call(ctx context.Context, inv *ToolInvocation) (string, error)
+}
+
+// ToolInvocation is a single call of a tool by a run's model
+type ToolInvocation struct {
+	Agent     *models.Agent
+	Run       *models.AgentRun
+	Arguments json.RawMessage
+}
+
+// ToolRegistry holds the tool implementations available to agents
+type ToolRegistry struct {
+	mu    sync.RWMutex
+	tools map[string]Tool
+}
+
+// NewToolRegistry creates an empty tool registry
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{tools: make(map[string]Tool)}
+}
+
+// Register adds a tool, replacing any tool of the same name
+func (r *ToolRegistry) Register(tool Tool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tools[tool.Definition().Function.Name] = tool
+}
+
+// Lookup returns the tool with the given name
+func (r *ToolRegistry) Lookup(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tool, ok := r.tools[name]
+	return tool, ok
+}
+
+// ForAgent returns the definitions of the registered tools an agent enables,
+// sorted by name. Tools the agent lists that are not registered here, such as
+// those only its container provides, are left out.
+func (r *ToolRegistry) ForAgent(agent *models.Agent) ([]providers.Tool, error) {
+	names, err := AgentToolNames(agent.Tools)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	var defs []providers.Tool
+	for _, name := range names {
+		if tool, ok := r.Lookup(name); ok {
+			defs = append(defs, tool.Definition())
+		}
+	}
+	return defs, nil
+}
+
+// AgentToolNames parses an agent's tools field: a JSON array of tool names, or
+// of objects with a "name"
+func AgentToolNames(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var entries []json.RawMessage
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, fmt.Errorf("tools must be a JSON array: %w", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		var name string
+		if err := json.Unmarshal(entry, &name); err != nil {
+			var named struct {
+				Name string `json:"name"`
+			}
+			if err := json.Unmarshal(entry, &named); err != nil {
+				return nil, fmt.Errorf("tools must be names or objects with a name")
+			}
+			name = named.Name
+		}
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// =============================================================================
+// Tool Loop
+// =============================================================================
+
+const (
+	// defaultMaxToolIterations is how many completions a run may make before
+	// it must give a final answer
+	defaultMaxToolIterations = 8
+
+	// maxToolResultBytes caps the tool output returned to the model
+	maxToolResultBytes = 16 * 1024
+)
+
+// ErrToolIterationsExceeded is returned when the model is still calling tools
+// after the loop's iteration cap
+var ErrToolIterationsExceeded = errors.New("tool call limit reached without a final answer")
+
+// ToolLoop runs a completion, executing the tools the model calls and passing
+// their results back, until the model gives a final answer
+type ToolLoop struct {
+	registry      *ToolRegistry
+	runLogs       RunLogSink
+	maxIterations int
+	log           *logger.Logger
+}
+
+// NewToolLoop creates a tool loop over the registry's tools. Each model turn
+// and tool call is recorded in the run's log when runLogs is non-nil.
+func NewToolLoop(registry *ToolRegistry, runLogs RunLogSink, log *logger.Logger) *ToolLoop {
+	return &ToolLoop{
+		registry:      registry,
+		runLogs:       runLogs,
+		maxIterations: defaultMaxToolIterations,
+		log:           log,
+	}
+}
+
+// SetMaxIterations caps how many completions a run may make
+func (l *ToolLoop) SetMaxIterations(n int) {
+	if n > 0 {
+		l.maxIterations = n
+	}
+}
+
+// ToolLoopResult is the outcome of a tool loop. Usage covers every completion.
+type ToolLoopResult struct {
+	Response   *providers.CompletionResponse
+	Messages   []providers.Message
+	Usage      providers.TokenUsage
+	Iterations int
+	ToolCalls  int
+}
+
+// Run completes req with provider, offering the agent's registered tools. The
+// result carries the usage so far even when it fails.
+func (l *ToolLoop) Run(ctx context.Context, provider providers.Provider, req *providers.CompletionRequest, agent *models.Agent, run *models.AgentRun) (*ToolLoopResult, error) {
+	tools, err := l.registry.ForAgent(agent)
+	if err != nil {
+		return nil, err
+	}
+
+	loopReq := *req
+	loopReq.Tools = append(append([]providers.Tool(nil), req.Tools...), tools...)
+	loopReq.Messages = append([]providers.Message(nil), req.Messages...)
+	result := &ToolLoopResult{}
+
+	for result.Iterations < l.maxIterations {
+		result.Iterations++
+
+		resp, err := provider.Complete(ctx, &loopReq)
+		if err != nil {
+			return result, err
+		}
+		result.Response = resp
+		result.Usage.PromptTokens += resp.Usage.PromptTokens
+		result.Usage.CompletionTokens += resp.Usage.CompletionTokens
+		result.Usage.TotalTokens += resp.Usage.TotalTokens
+
+		loopReq.Messages = append(loopReq.Messages, resp.Message)
+		if len(resp.Message.ToolCalls) == 0 {
+			l.runLog(ctx, run, models.LogLevelInfo, "model returned final answer", map[string]interface{}{
+				"iteration":     result.Iterations,
+				"finish_reason": resp.FinishReason,
+			})
+			result.Messages = loopReq.Messages
+			return result, nil
+		}
+
+		l.runLog(ctx, run, models.LogLevelInfo, "model requested tool calls", map[string]interface{}{
+			"iteration": result.Iterations,
+			"calls":     len(resp.Message.ToolCalls),
+		})
+		for _, call := range resp.Message.ToolCalls {
+			result.ToolCalls++
+			loopReq.Messages = append(loopReq.Messages, providers.Message{
+				Role:       "tool",
+				Name:       call.Function.Name,
+				ToolCallID: call.ID,
+				Content:    l.call(ctx, agent, run, call),
+			})
+		}
+	}
+
+	result.Messages = loopReq.Messages
+	l.runLog(ctx, run, models.LogLevelWarn, "tool call limit reached", map[string]interface{}{
+		"iterations": result.Iterations,
+	})
+	return result, ErrToolIterationsExceeded
+}
+
+// call runs one tool call and returns the content to send back to the model.
+// Failures are reported to the model so it can recover, rather than failing
+// the run.
+func (l *ToolLoop) call(ctx context.Context, agent *models.Agent, run *models.AgentRun, call providers.ToolCall) string {
+	name := call.Function.Name
+	metadata := map[string]interface{}{
+		"tool":         name,
+		"tool_call_id": call.ID,
+		"arguments":    truncate(call.Function.Arguments, 1000),
+	}
+
+	tool, ok := l.registry.Lookup(name)
+	if !ok || !agentEnablesTool(agent, name) {
+		metadata["error"] = "unknown tool"
+		l.runLog(ctx, run, models.LogLevelWarn, "model called an unavailable tool", metadata)
+		return fmt.Sprintf("error: tool %q is not available", name)
+	}
+
+	args := json.RawMessage(call.Function.Arguments)
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	if !json.Valid(args) {
+		metadata["error"] = "invalid arguments"
+		l.runLog(ctx, run, models.LogLevelWarn, "tool call had invalid arguments", metadata)
+		return "error: arguments must be a JSON object"
+	}
+
+	output, err := tool.Call(ctx, &ToolInvocation{Agent: agent, Run: run, Arguments: args})
+	if err != nil {
+		metadata["error"] = err.Error()
+		l.runLog(ctx, run, models.LogLevelWarn, "tool call failed", metadata)
+		return "error: " + err.Error()
+	}
+
+	if len(output) > maxToolResultBytes {
+		output = truncate(output, maxToolResultBytes)
+	}
+	metadata["result_bytes"] = len(output)
+	l.runLog(ctx, run, models.LogLevelInfo, "tool call completed", metadata)
+	return output
+}
+
+// agentEnablesTool reports whether the agent lists the tool, so a model cannot
+// call registered tools the agent was not given
+func agentEnablesTool(agent *models.Agent, name string) bool {
+	names, err := AgentToolNames(agent.Tools)
+	if err != nil {
+		return false
+	}
+	for _, enabled := range names {
+		if enabled == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *ToolLoop) runLog(ctx context.Context, run *models.AgentRun, level models.LogLevel, message string, metadata map[string]interface{}) {
+	if l.runLogs == nil {
+		return
+	}
+	if err := l.runLogs.AppendRunLog(ctx, run.ID, level, message, metadata); err != nil {
+		l.log.Warnw("failed to append run log", "run_id", run.ID, "error", err)
+	}
+}
+
+// =============================================================================
+// Built-in Tools
+// =============================================================================
+
+// KnowledgeSearchToolName is the name of the built-in knowledge search tool
+const KnowledgeSearchToolName = "knowledge_search"
+
+// defaultKnowledgeSearchLimit is how many chunks a search returns when the
+// model doesn't say
+const defaultKnowledgeSearchLimit = 5
+
+// KnowledgeSearchTool searches the knowledge bases attached to the agent
+type KnowledgeSearchTool struct {
+	knowledge *knowledge.Service
+}
+
+// NewKnowledgeSearchTool creates the knowledge_search tool
+func NewKnowledgeSearchTool(svc *knowledge.Service) *KnowledgeSearchTool {
+	return &KnowledgeSearchTool{knowledge: svc}
+}
+
+func (t *KnowledgeSearchTool) Definition() providers.Tool {
+	return providers.Tool{
+		Type: "function",
+		Function: providers.ToolFunction{
+			Name:        KnowledgeSearchToolName,
+			Description: "Search the knowledge bases available to you for passages relevant to a query.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"query": map[string]interface{}{
+						"type":        "string",
+						"description": "What to search for",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "How many passages to return (default 5, at most 20)",
+					},
+				},
+				"required": []string{"query"},
+			},
+		},
+	}
+}
+
+func (t *KnowledgeSearchTool) Call(ctx context.Context, inv *ToolInvocation) (string, error) {
+	var args struct {
+		Query string `json:"query"`
+		Limit int    `json:"limit"`
+	}
+	if err := json.Unmarshal(inv.Arguments, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	if strings.TrimSpace(args.Query) == "" {
+		return "", fmt.Errorf("query is required")
+	}
+	if args.Limit <= 0 {
+		args.Limit = defaultKnowledgeSearchLimit
+	}
+	args.Limit = min(args.Limit, 20)
+
+	if len(inv.Agent.KnowledgeBases) == 0 {
+		return "No knowledge bases are attached to this agent.", nil
+	}
+
+	result, err := t.knowledge.Query(ctx, &knowledge.QueryRequest{
+		KnowledgeBaseIDs: inv.Agent.KnowledgeBases,
+		Query:            args.Query,
+		Limit:            args.Limit,
+	})
+	if err != nil {
+		return "", fmt.Errorf("search failed: %w", err)
+	}
+	if len(result.Results) == 0 {
+		return "No matching passages found.", nil
+	}
+
+	var b strings.Builder
+	for i, r := range result.Results {
+		fmt.Fprintf(&b, "[%d] (score %.2f)\n%s\n\n", i+1, r.Score, r.Content)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
@@ -0,0 +1,139 @@
+package execution
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/providers"
+)
+
+// =============================================================================
+// Context Window
+// =============================================================================
+
+// TokenCounter counts the tokens a text takes; providers implement it
+type TokenCounter interface {
+	CountTokens(text string) (int, error)
+}
+
+// contextTrim removes one piece of context from a briefing, returning a
+// description of what it removed, or false when it has nothing left to remove
+type contextTrim func(ctx *BriefingContext) (string, bool)
+
+// contextTrims are tried in order, lowest priority context first: recent
+// activity, then knowledge (lowest-scoring documents first), then project
+// detail
+var contextTrims = []contextTrim{
+	func(ctx *BriefingContext) (string, bool) {
+		if ctx.RecentActivity == nil {
+			return "", false
+		}
+		ctx.RecentActivity = nil
+		return "recent activity", true
+	},
+	func(ctx *BriefingContext) (string, bool) {
+		if ctx.KnowledgeContext == nil || len(ctx.KnowledgeContext.RecentUpdates) == 0 {
+			return "", false
+		}
+		ctx.KnowledgeContext.RecentUpdates = nil
+		return "knowledge updates", true
+	},
+	func(ctx *BriefingContext) (string, bool) {
+		if ctx.KnowledgeContext == nil || len(ctx.KnowledgeContext.RelevantDocuments) == 0 {
+			return "", false
+		}
+		docs := ctx.KnowledgeContext.RelevantDocuments
+		ctx.KnowledgeContext.RelevantDocuments = docs[:len(docs)-1]
+		return fmt.Sprintf("knowledge document %q", docs[len(docs)-1].Title), true
+	},
+	func(ctx *BriefingContext) (string, bool) {
+		project := ctx.ProjectContext
+		if project == nil || (project.Description == "" && len(project.Repositories) == 0 &&
+			len(project.RecentCommits) == 0 && project.OpenPRs == 0 && len(project.ActiveBranches) == 0) {
+			return "", false
+		}
+		ctx.ProjectContext = &ProjectBriefing{ProjectName: project.ProjectName}
+		return "project detail", true
+	},
+}
+
+// fitContextWindow trims context from the briefing until its prompt and the
+// user prompt fit in the model's context window, less the tokens reserved for
+// output. Models with no known window are left as they are. What was trimmed
+// is logged and added to the briefing's warnings.
+func (e *BriefingEngine) fitContextWindow(agent *models.Agent, briefingResult *BriefingResult, userPrompt string, counter TokenCounter) {
+	info, ok := providers.DefaultPricing()[agent.Model]
+	if !ok || info.ContextWindow == 0 {
+		return
+	}
+	output := agent.Config.MaxTokens
+	if output == 0 {
+		output = info.MaxOutput
+	}
+	limit := info.ContextWindow - output
+
+	userTokens := countTokens(counter, agent.Model, userPrompt)
+	tokens := countTokens(counter, agent.Model, briefingResult.EnhancedPrompt) + userTokens
+	if tokens <= limit {
+		return
+	}
+
+	// Trim a copy so the caller's context is left alone
+	var trimmed []string
+	if briefingResult.context != nil && briefingResult.agent != nil {
+		ctx := copyBriefingContext(briefingResult.context)
+		for _, trim := range contextTrims {
+			for tokens > limit {
+				what, ok := trim(ctx)
+				if !ok {
+					break
+				}
+				trimmed = append(trimmed, what)
+				briefingResult.EnhancedPrompt = e.buildPrompt(briefingResult.agent, ctx)
+				tokens = countTokens(counter, agent.Model, briefingResult.EnhancedPrompt) + userTokens
+			}
+		}
+		briefingResult.context = ctx
+		briefingResult.EstimatedTokens = providers.CountTokens(agent.Model, briefingResult.EnhancedPrompt)
+	}
+
+	if len(trimmed) > 0 {
+		e.log.Warnw("trimmed briefing to fit context window",
+			"agent_id", agent.ID,
+			"model", agent.Model,
+			"trimmed", trimmed,
+			"tokens", tokens,
+			"limit", limit,
+		)
+		briefingResult.Warnings = append(briefingResult.Warnings,
+			fmt.Sprintf("briefing trimmed to fit the %s context window: removed %s", agent.Model, strings.Join(trimmed, ", ")))
+	}
+	if tokens > limit {
+		e.log.Warnw("prompt exceeds context window", "agent_id", agent.ID, "model", agent.Model, "tokens", tokens, "limit", limit)
+		briefingResult.Warnings = append(briefingResult.Warnings,
+			fmt.Sprintf("prompt is %d tokens, over the %d available in the %s context window", tokens, limit, agent.Model))
+	}
+}
+
+// countTokens counts with the provider's tokenizer, falling back to the
+// model's estimate when there is none or it fails
+func countTokens(counter TokenCounter, model, text string) int {
+	if counter != nil {
+		if n, err := counter.CountTokens(text); err == nil {
+			return n
+		}
+	}
+	return providers.CountTokens(model, text)
+}
+
+// copyBriefingContext copies the parts of a briefing context that trimming
+// changes
+func copyBriefingContext(ctx *BriefingContext) *BriefingContext {
+	copied := *ctx
+	if ctx.KnowledgeContext != nil {
+		knowledge := *ctx.KnowledgeContext
+		copied.KnowledgeContext = &knowledge
+	}
+	return &copied
+}
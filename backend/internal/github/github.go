@@ -3,14 +3,24 @@ package github
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/delphi-platform/delphi/backend/internal/models"
 	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
@@ -19,7 +29,11 @@ const githubAPIURL = "https://api.github.com"
 // Client handles GitHub API operations
 type Client struct {
 	httpClient *http.Client
+	baseURL    string
 	log        *logger.Logger
+
+	mu     sync.Mutex
+	tokens map[installationKey]*InstallationToken
 }
 
 // NewClient creates a new GitHub client
@@ -28,10 +42,18 @@ func NewClient(log *logger.Logger) *Client {
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
-		log: log,
+		baseURL: githubAPIURL,
+		log:     log,
+		tokens:  make(map[installationKey]*InstallationToken),
 	}
 }
 
+// SetBaseURL points the client at another API, such as GitHub Enterprise
+// Server's https://HOST/api/v3
+func (c *Client) SetBaseURL(baseURL string) {
+	c.baseURL = strings.TrimSuffix(baseURL, "/")
+}
+
 // =============================================================================
 // Authentication
 // =============================================================================
@@ -50,37 +72,245 @@ type Account struct {
 	Type  string `json:"type"` // User or Organization
 }
 
-// GetInstallationToken gets an access token for a GitHub App installation
-func (c *Client) GetInstallationToken(ctx context.Context, appID string, privateKey []byte, installationID int64) (string, error) {
-	// In production, this would:
-	// 1. Generate a JWT using the app private key
-	// 2. Exchange it for an installation access token
-	// For now, return a placeholder
-	c.log.Infow("getting installation token", "installation_id", installationID)
-	return "", fmt.Errorf("GitHub App authentication not implemented - configure via OAuth")
+const (
+	// appJWTLifetime is how long an app JWT is valid; GitHub allows at most 10 minutes
+	appJWTLifetime = 9 * time.Minute
+
+	// appJWTClockSkew backdates iat so a server clock slightly ahead of GitHub's is accepted
+	appJWTClockSkew = 60 * time.Second
+
+	// installationTokenRefreshMargin is how long before expiry a cached token is replaced
+	installationTokenRefreshMargin = 5 * time.Minute
+)
+
+// InstallationToken is an access token for a GitHub App installation
+type InstallationToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// installationKey identifies a cached installation token
+type installationKey struct {
+	appID          string
+	installationID int64
+}
+
+// GetInstallationToken gets an access token for a GitHub App installation.
+// Tokens are cached and reused until shortly before they expire.
+func (c *Client) GetInstallationToken(ctx context.Context, appID string, privateKey []byte, installationID int64) (*InstallationToken, error) {
+	key := installationKey{appID: appID, installationID: installationID}
+
+	c.mu.Lock()
+	cached, ok := c.tokens[key]
+	c.mu.Unlock()
+	if ok && time.Until(cached.ExpiresAt) > installationTokenRefreshMargin {
+		return cached, nil
+	}
+
+	appJWT, err := generateAppJWT(appID, privateKey, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/app/installations/%d/access_tokens", c.baseURL, installationID)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+appJWT)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("GitHub API error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var token InstallationToken
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, err
+	}
+	if token.Token == "" {
+		return nil, fmt.Errorf("GitHub returned an empty installation token")
+	}
+
+	c.mu.Lock()
+	c.tokens[key] = &token
+	c.mu.Unlock()
+
+	c.log.Infow("obtained installation token",
+		"installation_id", installationID,
+		"expires_at", token.ExpiresAt,
+	)
+	return &token, nil
+}
+
+// generateAppJWT creates the RS256 JWT that authenticates as the GitHub App itself
+func generateAppJWT(appID string, privateKey []byte, now time.Time) (string, error) {
+	if appID == "" {
+		return "", fmt.Errorf("GitHub App ID is required")
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid GitHub App private key: %w", err)
+	}
+
+	claims := jwt.RegisteredClaims{
+		IssuedAt:  jwt.NewNumericDate(now.Add(-appJWTClockSkew)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(appJWTLifetime)),
+		Issuer:    appID,
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign GitHub App JWT: %w", err)
+	}
+	return signed, nil
 }
 
 // =============================================================================
-// Repository Operations
+// Pagination and Rate Limits
 // =============================================================================
 
-// Repository represents a GitHub repository
-type Repository struct {
-	ID            int64  `json:"id"`
-	Name          string `json:"name"`
-	FullName      string `json:"full_name"`
-	Private       bool   `json:"private"`
-	Description   string `json:"description"`
-	HTMLURL       string `json:"html_url"`
-	CloneURL      string `json:"clone_url"`
-	DefaultBranch string `json:"default_branch"`
+const (
+	defaultPerPage = 100 // the most GitHub returns per page
+
+	// maxListPages bounds how many pages are fetched when listing everything
+	maxListPages = 50
+)
+
+// ListOptions selects a page of a list endpoint. A zero Page fetches every
+// page, following the Link header.
+type ListOptions struct {
+	Page    int
+	PerPage int // defaults to 100, the maximum
+}
+
+// RateLimit is the caller's GitHub API quota as of the last response
+type RateLimit struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
 }
 
-// ListRepositories lists repositories accessible to the authenticated user
-func (c *Client) ListRepositories(ctx context.Context, token string) ([]Repository, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", githubAPIURL+"/user/repos?per_page=100", nil)
+// RateLimitError is returned when GitHub refuses a request because the
+// token's rate limit is exhausted. Callers should wait until RetryAt.
+type RateLimitError struct {
+	RateLimit RateLimit
+	RetryAt   time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("GitHub rate limit exceeded, retry after %s", e.RetryAt.Format(time.RFC3339))
+}
+
+// parseRateLimit reads the rate limit headers of a response. It returns nil
+// when the response carries none.
+func parseRateLimit(h http.Header) *RateLimit {
+	remaining, err := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
 	if err != nil {
-		return nil, err
+		return nil
+	}
+
+	rl := &RateLimit{Remaining: remaining}
+	rl.Limit, _ = strconv.Atoi(h.Get("X-RateLimit-Limit"))
+	if reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		rl.Reset = time.Unix(reset, 0)
+	}
+	return rl
+}
+
+// rateLimitError returns a RateLimitError if the response was refused for
+// exceeding a primary or secondary rate limit
+func rateLimitError(resp *http.Response, rl *RateLimit) error {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+
+	var err RateLimitError
+	if rl != nil {
+		err.RateLimit = *rl
+	}
+	if seconds, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil {
+		err.RetryAt = time.Now().Add(time.Duration(seconds) * time.Second)
+	} else if rl != nil && rl.Remaining == 0 {
+		err.RetryAt = rl.Reset
+	} else {
+		// A 403 with quota left is a permissions problem, not a rate limit
+		return nil
+	}
+	return &err
+}
+
+// nextPageURL returns the rel="next" URL of a Link header, or "" on the last page
+func nextPageURL(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		segments := strings.Split(part, ";")
+		if len(segments) < 2 {
+			continue
+		}
+		target := strings.Trim(strings.TrimSpace(segments[0]), "<>")
+		for _, param := range segments[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return target
+			}
+		}
+	}
+	return ""
+}
+
+// listPages fetches a list endpoint, either the page selected by opts or
+// every page in turn. It returns the rate limit reported by the last response.
+func listPages[T any](ctx context.Context, c *Client, token, endpoint string, query url.Values, opts ListOptions) ([]T, *RateLimit, error) {
+	perPage := opts.PerPage
+	if perPage <= 0 || perPage > defaultPerPage {
+		perPage = defaultPerPage
+	}
+	query.Set("per_page", strconv.Itoa(perPage))
+	if opts.Page > 0 {
+		query.Set("page", strconv.Itoa(opts.Page))
+	}
+
+	var (
+		items []T
+		rl    *RateLimit
+	)
+	next := endpoint + "?" + query.Encode()
+	for pages := 0; next != ""; pages++ {
+		if pages == maxListPages {
+			c.log.Warnw("stopped listing GitHub results at page limit", "endpoint", endpoint, "pages", pages)
+			break
+		}
+
+		page, link, pageRL, err := getPage[T](ctx, c, token, next)
+		if pageRL != nil {
+			rl = pageRL
+		}
+		if err != nil {
+			return nil, rl, err
+		}
+		items = append(items, page...)
+
+		if opts.Page > 0 {
+			break
+		}
+		next = nextPageURL(link)
+	}
+	return items, rl, nil
+}
+
+// getPage fetches one page of a list endpoint and returns its Link header
+func getPage[T any](ctx context.Context, c *Client, token, pageURL string) ([]T, string, *RateLimit, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return nil, "", nil, err
 	}
 
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -88,26 +318,51 @@ func (c *Client) ListRepositories(ctx context.Context, token string) ([]Reposito
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, "", nil, err
 	}
 	defer resp.Body.Close()
 
+	rl := parseRateLimit(resp.Header)
+	if err := rateLimitError(resp, rl); err != nil {
+		return nil, "", rl, err
+	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("GitHub API error: %d - %s", resp.StatusCode, string(body))
+		return nil, "", rl, fmt.Errorf("GitHub API error: %d - %s", resp.StatusCode, string(body))
 	}
 
-	var repos []Repository
-	if err := json.NewDecoder(resp.Body).Decode(&repos); err != nil {
-		return nil, err
+	var items []T
+	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+		return nil, "", rl, err
 	}
+	return items, resp.Header.Get("Link"), rl, nil
+}
 
-	return repos, nil
+// =============================================================================
+// Repository Operations
+// =============================================================================
+
+// Repository represents a GitHub repository
+type Repository struct {
+	ID            int64  `json:"id"`
+	Name          string `json:"name"`
+	FullName      string `json:"full_name"`
+	Private       bool   `json:"private"`
+	Description   string `json:"description"`
+	HTMLURL       string `json:"html_url"`
+	CloneURL      string `json:"clone_url"`
+	DefaultBranch string `json:"default_branch"`
+}
+
+// ListRepositories lists repositories accessible to the authenticated user.
+// A zero opts.Page fetches every page.
+func (c *Client) ListRepositories(ctx context.Context, token string, opts ListOptions) ([]Repository, *RateLimit, error) {
+	return listPages[Repository](ctx, c, token, c.baseURL+"/user/repos", url.Values{}, opts)
 }
 
 // GetRepository gets a specific repository
 func (c *Client) GetRepository(ctx context.Context, token, owner, repo string) (*Repository, error) {
-	url := fmt.Sprintf("%s/repos/%s/%s", githubAPIURL, owner, repo)
+	url := fmt.Sprintf("%s/repos/%s/%s", c.baseURL, owner, repo)
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
@@ -150,7 +405,7 @@ type Branch struct {
 
 // ListBranches lists branches in a repository
 func (c *Client) ListBranches(ctx context.Context, token, owner, repo string) ([]Branch, error) {
-	url := fmt.Sprintf("%s/repos/%s/%s/branches", githubAPIURL, owner, repo)
+	url := fmt.Sprintf("%s/repos/%s/%s/branches", c.baseURL, owner, repo)
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
@@ -177,9 +432,43 @@ func (c *Client) ListBranches(ctx context.Context, token, owner, repo string) ([
 	return branches, nil
 }
 
+// GetBranch gets a branch and the commit at its head. It returns
+// ErrNotFound when the branch does not exist.
+func (c *Client) GetBranch(ctx context.Context, token, owner, repo, branch string) (*Branch, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/branches/%s", c.baseURL, owner, repo, branch)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("GitHub API error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var b Branch
+	if err := json.NewDecoder(resp.Body).Decode(&b); err != nil {
+		return nil, err
+	}
+
+	return &b, nil
+}
+
 // CreateBranch creates a new branch
 func (c *Client) CreateBranch(ctx context.Context, token, owner, repo, branchName, baseSHA string) error {
-	url := fmt.Sprintf("%s/repos/%s/%s/git/refs", githubAPIURL, owner, repo)
+	url := fmt.Sprintf("%s/repos/%s/%s/git/refs", c.baseURL, owner, repo)
 	
 	body := map[string]string{
 		"ref": "refs/heads/" + branchName,
@@ -214,6 +503,35 @@ func (c *Client) CreateBranch(ctx context.Context, token, owner, repo, branchNam
 	return nil
 }
 
+// DeleteBranch deletes a branch
+func (c *Client) DeleteBranch(ctx context.Context, token, owner, repo, branchName string) error {
+	url := fmt.Sprintf("%s/repos/%s/%s/git/refs/heads/%s", c.baseURL, owner, repo, branchName)
+
+	req, err := http.NewRequestWithContext(ctx, "DELETE", url, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("GitHub API error: %d - %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil
+}
+
 // =============================================================================
 // File Operations
 // =============================================================================
@@ -229,9 +547,13 @@ type FileContent struct {
 	DownloadURL string `json:"download_url"`
 }
 
-// GetFileContent gets the content of a file
+// ErrNotFound is returned when a file or branch does not exist
+var ErrNotFound = errors.New("not found on GitHub")
+
+// GetFileContent gets the content of a file. It returns ErrNotFound when
+// the file does not exist at ref.
 func (c *Client) GetFileContent(ctx context.Context, token, owner, repo, path, ref string) (*FileContent, error) {
-	url := fmt.Sprintf("%s/repos/%s/%s/contents/%s", githubAPIURL, owner, repo, path)
+	url := fmt.Sprintf("%s/repos/%s/%s/contents/%s", c.baseURL, owner, repo, path)
 	if ref != "" {
 		url += "?ref=" + ref
 	}
@@ -250,6 +572,9 @@ func (c *Client) GetFileContent(ctx context.Context, token, owner, repo, path, r
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("GitHub API error: %d", resp.StatusCode)
 	}
@@ -264,7 +589,7 @@ func (c *Client) GetFileContent(ctx context.Context, token, owner, repo, path, r
 
 // CreateOrUpdateFile creates or updates a file in the repository
 func (c *Client) CreateOrUpdateFile(ctx context.Context, token, owner, repo, path, message, content, branch string, sha *string) error {
-	url := fmt.Sprintf("%s/repos/%s/%s/contents/%s", githubAPIURL, owner, repo, path)
+	url := fmt.Sprintf("%s/repos/%s/%s/contents/%s", c.baseURL, owner, repo, path)
 	
 	body := map[string]interface{}{
 		"message": message,
@@ -304,6 +629,45 @@ func (c *Client) CreateOrUpdateFile(ctx context.Context, token, owner, repo, pat
 	return nil
 }
 
+// DeleteFile deletes a file from the repository. sha is the blob SHA of the
+// file being deleted.
+func (c *Client) DeleteFile(ctx context.Context, token, owner, repo, path, message, branch, sha string) error {
+	url := fmt.Sprintf("%s/repos/%s/%s/contents/%s", c.baseURL, owner, repo, path)
+
+	body := map[string]string{
+		"message": message,
+		"sha":     sha,
+		"branch":  branch,
+	}
+
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "DELETE", url, bytes.NewReader(jsonBody))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("GitHub API error: %d - %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil
+}
+
 // =============================================================================
 // Pull Request Operations
 // =============================================================================
@@ -330,7 +694,7 @@ type PullRequest struct {
 
 // CreatePullRequest creates a new pull request
 func (c *Client) CreatePullRequest(ctx context.Context, token, owner, repo, title, body, head, base string) (*PullRequest, error) {
-	url := fmt.Sprintf("%s/repos/%s/%s/pulls", githubAPIURL, owner, repo)
+	url := fmt.Sprintf("%s/repos/%s/%s/pulls", c.baseURL, owner, repo)
 	
 	reqBody := map[string]string{
 		"title": title,
@@ -372,34 +736,29 @@ func (c *Client) CreatePullRequest(ctx context.Context, token, owner, repo, titl
 	return &pr, nil
 }
 
-// ListPullRequests lists pull requests
-func (c *Client) ListPullRequests(ctx context.Context, token, owner, repo, state string) ([]PullRequest, error) {
-	url := fmt.Sprintf("%s/repos/%s/%s/pulls?state=%s", githubAPIURL, owner, repo, state)
+// PullRequestListOptions filters and pages ListPullRequests
+type PullRequestListOptions struct {
+	ListOptions
+	State string // open, closed or all; GitHub defaults to open
+	Base  string // base branch name
+	Head  string // head branch as user:ref-name or organization:ref-name
+}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, err
+// ListPullRequests lists pull requests. A zero opts.Page fetches every page.
+func (c *Client) ListPullRequests(ctx context.Context, token, owner, repo string, opts PullRequestListOptions) ([]PullRequest, *RateLimit, error) {
+	query := url.Values{}
+	if opts.State != "" {
+		query.Set("state", opts.State)
 	}
-
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Accept", "application/vnd.github+json")
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, err
+	if opts.Base != "" {
+		query.Set("base", opts.Base)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("GitHub API error: %d", resp.StatusCode)
-	}
-
-	var prs []PullRequest
-	if err := json.NewDecoder(resp.Body).Decode(&prs); err != nil {
-		return nil, err
+	if opts.Head != "" {
+		query.Set("head", opts.Head)
 	}
 
-	return prs, nil
+	endpoint := fmt.Sprintf("%s/repos/%s/%s/pulls", c.baseURL, owner, repo)
+	return listPages[PullRequest](ctx, c, token, endpoint, query, opts.ListOptions)
 }
 
 // =============================================================================
@@ -413,11 +772,68 @@ type WebhookPayload struct {
 	Sender       Account         `json:"sender"`
 	Installation *Installation   `json:"installation,omitempty"`
 	PullRequest  *PullRequest    `json:"pull_request,omitempty"`
+
+	// Push events
+	Ref     string       `json:"ref,omitempty"`
+	Before  string       `json:"before,omitempty"`
+	After   string       `json:"after,omitempty"`
+	Created bool         `json:"created,omitempty"`
+	Deleted bool         `json:"deleted,omitempty"`
+	Forced  bool         `json:"forced,omitempty"`
+	Commits []PushCommit `json:"commits,omitempty"`
+}
+
+// PushCommit is a commit in a push event, with the paths it touched
+type PushCommit struct {
+	ID       string   `json:"id"`
+	Message  string   `json:"message"`
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Modified []string `json:"modified"`
+}
+
+// Branch returns the branch a push event updated, or "" for tag pushes
+func (p WebhookPayload) Branch() string {
+	branch, ok := strings.CutPrefix(p.Ref, "refs/heads/")
+	if !ok {
+		return ""
+	}
+	return branch
+}
+
+// ChangedFiles returns the net effect of a push's commits: the paths that
+// exist after the push and were added or modified, and the paths it removed.
+// Later commits win, so a file added and then removed is only reported removed.
+func (p WebhookPayload) ChangedFiles() (changed, removed []string) {
+	state := make(map[string]bool) // path -> exists after the push
+	for _, commit := range p.Commits {
+		for _, path := range commit.Added {
+			state[path] = true
+		}
+		for _, path := range commit.Modified {
+			state[path] = true
+		}
+		for _, path := range commit.Removed {
+			state[path] = false
+		}
+	}
+
+	for path, exists := range state {
+		if exists {
+			changed = append(changed, path)
+		} else {
+			removed = append(removed, path)
+		}
+	}
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return changed, removed
 }
 
 // WebhookHandler handles GitHub webhooks
 type WebhookHandler struct {
 	secret string
+	onPush func(payload WebhookPayload) error
 	log    *logger.Logger
 }
 
@@ -429,7 +845,35 @@ func NewWebhookHandler(secret string, log *logger.Logger) *WebhookHandler {
 	}
 }
 
-// HandleWebhook processes a GitHub webhook
+// ErrInvalidSignature is returned when a webhook's signature does not match its payload
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// VerifySignature checks the X-Hub-Signature-256 header of a webhook against
+// the raw request body. Deliveries are rejected when no secret is configured.
+func (h *WebhookHandler) VerifySignature(payload []byte, signature string) error {
+	if h.secret == "" {
+		return fmt.Errorf("%w: webhook secret not configured", ErrInvalidSignature)
+	}
+
+	digest, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return ErrInvalidSignature
+	}
+	got, err := hex.DecodeString(digest)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(h.secret))
+	mac.Write(payload)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// HandleWebhook processes a GitHub webhook. The payload must already have
+// passed VerifySignature.
 func (h *WebhookHandler) HandleWebhook(eventType string, payload []byte) error {
 	h.log.Infow("received webhook", "event", eventType)
 
@@ -454,10 +898,22 @@ func (h *WebhookHandler) HandleWebhook(eventType string, payload []byte) error {
 	return nil
 }
 
+// OnPush sets the function called with each push event, which keeps
+// repository knowledge bases up to date
+func (h *WebhookHandler) OnPush(fn func(payload WebhookPayload) error) {
+	h.onPush = fn
+}
+
 func (h *WebhookHandler) handlePush(payload WebhookPayload) error {
-	h.log.Infow("processing push event", "repo", payload.Repository.FullName)
-	// Trigger knowledge base update for the repository
-	return nil
+	h.log.Infow("processing push event",
+		"repo", payload.Repository.FullName,
+		"ref", payload.Ref,
+		"commits", len(payload.Commits),
+	)
+	if h.onPush == nil {
+		return nil
+	}
+	return h.onPush(payload)
 }
 
 func (h *WebhookHandler) handlePullRequest(payload WebhookPayload) error {
@@ -0,0 +1,178 @@
+package github
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+)
+
+// =============================================================================
+// Opening Pull Requests
+// =============================================================================
+
+var (
+	// ErrProtectedBranch is returned when changes would be committed to the
+	// base branch, main or master rather than a branch of their own
+	ErrProtectedBranch = errors.New("changes must be committed to a new branch, not the default branch")
+
+	// ErrNoChanges is returned when none of the files changes the repository
+	ErrNoChanges = errors.New("no file changes to commit")
+)
+
+// branchCleanupTimeout bounds deleting the branch of a pull request that
+// couldn't be opened
+const branchCleanupTimeout = 30 * time.Second
+
+// PullRequestChanges are the file changes OpenPullRequest commits and
+// proposes in a pull request
+type PullRequestChanges struct {
+	Branch string // new branch the changes are committed to
+	Base   string // branch the pull request targets; the repository's default branch if empty
+	Title  string // "Changes from <branch>" if empty, since GitHub requires one
+	Body   string
+
+	// Message is each commit's message; Title if empty
+	Message string
+
+	// Files are the changes, as in a coding result. Added and modified files
+	// without content are left out.
+	Files []models.CodingFileChange
+}
+
+// OpenPullRequest creates changes.Branch off the head of the base branch,
+// commits each file change to it and opens a pull request into the base
+// branch. The base branch itself is never written to, and the new branch is
+// deleted again if a commit or the pull request fails.
+func (c *Client) OpenPullRequest(ctx context.Context, token, owner, repo string, changes PullRequestChanges) (*PullRequest, error) {
+	base := changes.Base
+	if base == "" {
+		repository, err := c.GetRepository(ctx, token, owner, repo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get repository: %w", err)
+		}
+		base = repository.DefaultBranch
+	}
+	if changes.Branch == "" || changes.Branch == base || changes.Branch == "main" || changes.Branch == "master" {
+		return nil, fmt.Errorf("%w: %q", ErrProtectedBranch, changes.Branch)
+	}
+
+	files := committedFiles(changes.Files)
+	if len(files) == 0 {
+		return nil, ErrNoChanges
+	}
+	title := strings.TrimSpace(changes.Title)
+	if title == "" {
+		title = "Changes from " + changes.Branch
+	}
+	message := changes.Message
+	if message == "" {
+		message = title
+	}
+
+	head, err := c.GetBranch(ctx, token, owner, repo, base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get base branch %s: %w", base, err)
+	}
+	if err := c.CreateBranch(ctx, token, owner, repo, changes.Branch, head.Commit.SHA); err != nil {
+		return nil, fmt.Errorf("failed to create branch %s: %w", changes.Branch, err)
+	}
+
+	for _, file := range files {
+		if err := c.commitFile(ctx, token, owner, repo, changes.Branch, message, file); err != nil {
+			c.deleteFailedBranch(ctx, token, owner, repo, changes.Branch)
+			return nil, fmt.Errorf("failed to commit %s: %w", file.Path, err)
+		}
+	}
+
+	pr, err := c.CreatePullRequest(ctx, token, owner, repo, title, changes.Body, changes.Branch, base)
+	if err != nil {
+		c.deleteFailedBranch(ctx, token, owner, repo, changes.Branch)
+		return nil, fmt.Errorf("failed to create pull request: %w", err)
+	}
+	return pr, nil
+}
+
+// deleteFailedBranch removes the branch of a pull request that couldn't be
+// opened, so no half-committed branch is left behind. It runs even if ctx
+// was cancelled, which may be why the pull request failed.
+func (c *Client) deleteFailedBranch(ctx context.Context, token, owner, repo, branch string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), branchCleanupTimeout)
+	defer cancel()
+
+	if err := c.DeleteBranch(ctx, token, owner, repo, branch); err != nil && !errors.Is(err, ErrNotFound) {
+		c.log.Warnw("failed to delete branch of failed pull request",
+			"repository", owner+"/"+repo,
+			"branch", branch,
+			"error", err,
+		)
+	}
+}
+
+// committedFiles returns the file changes that change the repository
+func committedFiles(files []models.CodingFileChange) []models.CodingFileChange {
+	var committed []models.CodingFileChange
+	for _, file := range files {
+		switch file.Status {
+		case models.CodingFileDeleted, models.CodingFileRenamed:
+		default:
+			if file.Content == nil {
+				continue
+			}
+		}
+		committed = append(committed, file)
+	}
+	return committed
+}
+
+// commitFile commits one file change to branch. A renamed file without new
+// content keeps its previous content.
+func (c *Client) commitFile(ctx context.Context, token, owner, repo, branch, message string, file models.CodingFileChange) error {
+	if file.Status == models.CodingFileDeleted {
+		existing, err := c.GetFileContent(ctx, token, owner, repo, file.Path, branch)
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return c.DeleteFile(ctx, token, owner, repo, file.Path, message, branch, existing.SHA)
+	}
+
+	var encoded string
+	var previous *FileContent
+	if file.Status == models.CodingFileRenamed {
+		var err error
+		previous, err = c.GetFileContent(ctx, token, owner, repo, file.PreviousPath, branch)
+		if err != nil {
+			return fmt.Errorf("failed to get %s: %w", file.PreviousPath, err)
+		}
+		// The contents API returns base64 wrapped across lines
+		encoded = strings.ReplaceAll(previous.Content, "\n", "")
+	}
+	if file.Content != nil {
+		encoded = base64.StdEncoding.EncodeToString([]byte(*file.Content))
+	}
+
+	// Updating a file that exists requires its current blob SHA
+	var sha *string
+	existing, err := c.GetFileContent(ctx, token, owner, repo, file.Path, branch)
+	switch {
+	case err == nil:
+		sha = &existing.SHA
+	case !errors.Is(err, ErrNotFound):
+		return err
+	}
+	if err := c.CreateOrUpdateFile(ctx, token, owner, repo, file.Path, message, encoded, branch, sha); err != nil {
+		return err
+	}
+
+	if previous != nil {
+		return c.DeleteFile(ctx, token, owner, repo, file.PreviousPath, message, branch, previous.SHA)
+	}
+	return nil
+}
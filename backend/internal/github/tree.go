@@ -0,0 +1,373 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/delphi-platform/delphi/backend/internal/knowledge"
+)
+
+// =============================================================================
+// Tree Walking
+// =============================================================================
+
+const (
+	// DefaultMaxFileSize is the largest file fetched for indexing, in bytes.
+	// It matches the size above which the repository indexer skips a file.
+	DefaultMaxFileSize = 100000
+
+	// blobFetchConcurrency bounds concurrent blob downloads per walk
+	blobFetchConcurrency = 8
+)
+
+// DefaultIgnorePaths are directories and files left out of repository
+// indexing. An entry matches any path segment, and may be a glob.
+var DefaultIgnorePaths = []string{
+	".git",
+	"node_modules",
+	"vendor",
+	"third_party",
+	"dist",
+	"build",
+	"target",
+	"__pycache__",
+	".venv",
+	".next",
+	"coverage",
+	"*.min.js",
+	"*.min.css",
+	"*.lock",
+	"package-lock.json",
+	"go.sum",
+}
+
+// TreeEntry is a file, directory or submodule in a git tree
+type TreeEntry struct {
+	Path string `json:"path"`
+	Mode string `json:"mode"`
+	Type string `json:"type"` // blob, tree or commit
+	SHA  string `json:"sha"`
+	Size int64  `json:"size"` // blobs only
+}
+
+// Tree is a git tree. Truncated is set when a recursive listing exceeded
+// GitHub's limit and entries are missing.
+type Tree struct {
+	SHA       string      `json:"sha"`
+	Tree      []TreeEntry `json:"tree"`
+	Truncated bool        `json:"truncated"`
+}
+
+// GetTree gets a git tree by SHA, or the root tree of a branch, tag or commit.
+// A recursive tree lists every entry below the root.
+func (c *Client) GetTree(ctx context.Context, token, owner, repo, ref string, recursive bool) (*Tree, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/git/trees/%s", c.baseURL, owner, repo, ref)
+	if recursive {
+		url += "?recursive=1"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := rateLimitError(resp, parseRateLimit(resp.Header)); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("GitHub API error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var tree Tree
+	if err := json.NewDecoder(resp.Body).Decode(&tree); err != nil {
+		return nil, err
+	}
+	return &tree, nil
+}
+
+// GetBlob gets the raw content of a git blob
+func (c *Client) GetBlob(ctx context.Context, token, owner, repo, sha string) ([]byte, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/git/blobs/%s", c.baseURL, owner, repo, sha)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github.raw+json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := rateLimitError(resp, parseRateLimit(resp.Header)); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("GitHub API error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// WalkOptions controls which files FetchRepositoryFiles returns
+type WalkOptions struct {
+	// MaxFileSize skips larger files; 0 uses DefaultMaxFileSize
+	MaxFileSize int64
+
+	// Ignore lists path segments or globs to skip; nil uses DefaultIgnorePaths
+	Ignore []string
+}
+
+// FetchRepositoryFiles walks a repository's tree at ref and returns its text
+// files, with contents and language, ready for the repository indexer.
+// Ignored paths, files over the size cap and binary files are skipped.
+func (c *Client) FetchRepositoryFiles(ctx context.Context, token, owner, repo, ref string, opts WalkOptions) ([]knowledge.RepositoryFile, error) {
+	if opts.MaxFileSize <= 0 {
+		opts.MaxFileSize = DefaultMaxFileSize
+	}
+	if opts.Ignore == nil {
+		opts.Ignore = DefaultIgnorePaths
+	}
+
+	entries, err := c.walkTree(ctx, token, owner, repo, ref, opts.Ignore)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := c.fetchBlobs(ctx, token, owner, repo, entries, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.log.Infow("fetched repository files",
+		"repo", owner+"/"+repo,
+		"ref", ref,
+		"entries", len(entries),
+		"files", len(files),
+	)
+	return files, nil
+}
+
+// FetchFiles returns the indexable files among paths at ref, filtered as
+// FetchRepositoryFiles filters them. Paths that do not exist at ref, or that
+// would not be indexed, are left out.
+func (c *Client) FetchFiles(ctx context.Context, token, owner, repo, ref string, paths []string, opts WalkOptions) ([]knowledge.RepositoryFile, error) {
+	if opts.MaxFileSize <= 0 {
+		opts.MaxFileSize = DefaultMaxFileSize
+	}
+	if opts.Ignore == nil {
+		opts.Ignore = DefaultIgnorePaths
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	entries, err := c.walkTree(ctx, token, owner, repo, ref, opts.Ignore)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		wanted[p] = true
+	}
+	var matched []TreeEntry
+	for _, entry := range entries {
+		if wanted[entry.Path] {
+			matched = append(matched, entry)
+		}
+	}
+
+	return c.fetchBlobs(ctx, token, owner, repo, matched, opts)
+}
+
+// fetchBlobs downloads the text blobs among entries that are within the size
+// cap, sorted by path
+func (c *Client) fetchBlobs(ctx context.Context, token, owner, repo string, entries []TreeEntry, opts WalkOptions) ([]knowledge.RepositoryFile, error) {
+	var blobs []TreeEntry
+	for _, entry := range entries {
+		if entry.Type != "blob" || entry.Size > opts.MaxFileSize || isBinaryPath(entry.Path) {
+			continue
+		}
+		blobs = append(blobs, entry)
+	}
+
+	var (
+		mu       sync.Mutex
+		files    []knowledge.RepositoryFile
+		firstErr error
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, blobFetchConcurrency)
+	)
+	for _, entry := range blobs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(entry TreeEntry) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			content, err := c.GetBlob(ctx, token, owner, repo, entry.SHA)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to fetch %s: %w", entry.Path, err)
+				}
+				return
+			}
+			if !isText(content) {
+				return
+			}
+			files = append(files, knowledge.RepositoryFile{
+				Path:     entry.Path,
+				Content:  string(content),
+				Language: LanguageForPath(entry.Path),
+			})
+		}(entry)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// walkTree lists the entries under ref that are not ignored. It uses a single
+// recursive listing, and walks the tree one directory at a time if GitHub
+// truncated it.
+func (c *Client) walkTree(ctx context.Context, token, owner, repo, ref string, ignore []string) ([]TreeEntry, error) {
+	tree, err := c.GetTree(ctx, token, owner, repo, ref, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree: %w", err)
+	}
+
+	if !tree.Truncated {
+		entries := make([]TreeEntry, 0, len(tree.Tree))
+		for _, entry := range tree.Tree {
+			if !isIgnored(entry.Path, ignore) {
+				entries = append(entries, entry)
+			}
+		}
+		return entries, nil
+	}
+
+	c.log.Infow("recursive tree truncated, walking directories", "repo", owner+"/"+repo, "ref", ref)
+
+	var entries []TreeEntry
+	type dir struct{ sha, prefix string }
+	queue := []dir{{sha: tree.SHA}}
+	for len(queue) > 0 {
+		d := queue[0]
+		queue = queue[1:]
+
+		subtree, err := c.GetTree(ctx, token, owner, repo, d.sha, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tree: %w", err)
+		}
+		for _, entry := range subtree.Tree {
+			entry.Path = path.Join(d.prefix, entry.Path)
+			if isIgnored(entry.Path, ignore) {
+				continue
+			}
+			if entry.Type == "tree" {
+				queue = append(queue, dir{sha: entry.SHA, prefix: entry.Path})
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// isIgnored reports whether any segment of p matches an ignore entry
+func isIgnored(p string, ignore []string) bool {
+	for _, segment := range strings.Split(p, "/") {
+		for _, pattern := range ignore {
+			if matched, _ := path.Match(pattern, segment); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// binaryExtensions are skipped without downloading them
+var binaryExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true, ".ico": true, ".bmp": true,
+	".pdf": true, ".zip": true, ".gz": true, ".tar": true, ".tgz": true, ".7z": true, ".rar": true,
+	".jar": true, ".war": true, ".class": true, ".exe": true, ".dll": true, ".so": true, ".dylib": true,
+	".woff": true, ".woff2": true, ".ttf": true, ".otf": true, ".eot": true,
+	".mp3": true, ".mp4": true, ".mov": true, ".wav": true, ".avi": true,
+	".wasm": true, ".bin": true, ".pyc": true, ".o": true, ".a": true,
+}
+
+func isBinaryPath(p string) bool {
+	return binaryExtensions[strings.ToLower(path.Ext(p))]
+}
+
+// isText reports whether content looks like text: valid UTF-8 with no NUL bytes
+func isText(content []byte) bool {
+	return utf8.Valid(content) && !strings.ContainsRune(string(content), 0)
+}
+
+// languages maps file extensions to the language names used by the
+// knowledge package's code chunking
+var languages = map[string]string{
+	".go":    "go",
+	".ts":    "typescript",
+	".tsx":   "typescript",
+	".js":    "javascript",
+	".jsx":   "javascript",
+	".mjs":   "javascript",
+	".py":    "python",
+	".rb":    "ruby",
+	".java":  "java",
+	".kt":    "kotlin",
+	".rs":    "rust",
+	".c":     "c",
+	".h":     "c",
+	".cpp":   "cpp",
+	".hpp":   "cpp",
+	".cs":    "csharp",
+	".php":   "php",
+	".swift": "swift",
+	".sql":   "sql",
+	".sh":    "shell",
+	".md":    "markdown",
+	".yaml":  "yaml",
+	".yml":   "yaml",
+	".json":  "json",
+	".toml":  "toml",
+	".html":  "html",
+	".css":   "css",
+}
+
+// LanguageForPath infers a file's language from its extension. It returns ""
+// for unknown extensions, which are chunked as plain text.
+func LanguageForPath(p string) string {
+	return languages[strings.ToLower(path.Ext(p))]
+}
@@ -1,10 +1,15 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/delphi-platform/delphi/backend/internal/middleware"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/internal/security"
 	"github.com/delphi-platform/delphi/backend/internal/services"
 	"github.com/delphi-platform/delphi/backend/pkg/logger"
 	"github.com/go-chi/chi/v5"
@@ -22,6 +27,49 @@ func NewAgentHandler(svc *services.AgentService, log *logger.Logger) *AgentHandl
 	return &AgentHandler{svc: svc, log: log}
 }
 
+// Routes returns the agent endpoints, each guarded by the permission it needs
+func (h *AgentHandler) Routes(rbac *security.RBAC) chi.Router {
+	read := middleware.RequirePermission(rbac, security.PermAgentRead)
+	create := middleware.RequirePermission(rbac, security.PermAgentCreate)
+	update := middleware.RequirePermission(rbac, security.PermAgentUpdate)
+	remove := middleware.RequirePermission(rbac, security.PermAgentDelete)
+	execute := middleware.RequirePermission(rbac, security.PermAgentExecute)
+
+	r := chi.NewRouter()
+	r.With(read).Get("/", h.List)
+	r.With(create).Post("/", h.Create)
+	r.With(read).Get("/templates", h.ListTemplates)
+	r.With(create).Post("/import", h.Import)
+	r.With(create).Post("/from-template/{templateID}", h.CreateFromTemplate)
+
+	r.Route("/{agentID}", func(r chi.Router) {
+		r.With(read).Get("/", h.Get)
+		r.With(update).Patch("/", h.Update)
+		r.With(remove).Delete("/", h.Delete)
+		r.With(read).Get("/export", h.Export)
+		r.With(create).Post("/save-as-template", h.SaveAsTemplate)
+
+		r.With(execute).Post("/launch", h.Launch)
+		r.With(execute).Post("/pause", h.Pause)
+		r.With(execute).Post("/terminate", h.Terminate)
+
+		r.With(read).Get("/runs", h.ListRuns)
+		r.With(read).Get("/runs/{runID}", h.GetRun)
+		r.With(read).Get("/runs/{runID}/logs", h.GetRunLogs)
+	})
+
+	return r
+}
+
+// TemplateRoutes returns the agent template catalog endpoints
+func (h *AgentHandler) TemplateRoutes(rbac *security.RBAC) chi.Router {
+	r := chi.NewRouter()
+	r.With(middleware.RequirePermission(rbac, security.PermAgentRead)).Get("/", h.ListTemplates)
+	r.With(middleware.RequirePermission(rbac, security.PermAgentCreate)).Post("/", h.CreateTemplate)
+	r.With(middleware.RequirePermission(rbac, security.PermAgentDelete)).Delete("/{templateID}", h.DeleteTemplate)
+	return r
+}
+
 // List returns all agents for the tenant
 func (h *AgentHandler) List(w http.ResponseWriter, r *http.Request) {
 	tenantID, ok := middleware.GetTenantID(r.Context())
@@ -43,7 +91,8 @@ func (h *AgentHandler) List(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// Create creates a new agent
+// Create creates a new agent. With ?dry_run=true the agent is only validated:
+// the response is the normalized agent and its warnings, and nothing is saved.
 func (h *AgentHandler) Create(w http.ResponseWriter, r *http.Request) {
 	tenantID, ok := middleware.GetTenantID(r.Context())
 	if !ok {
@@ -57,21 +106,42 @@ func (h *AgentHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Name == "" {
-		respondError(w, http.StatusBadRequest, "name is required")
+	req.Strict = r.URL.Query().Get("strict") == "true"
+
+	if r.URL.Query().Get("dry_run") == "true" {
+		validation, err := h.svc.Validate(r.Context(), tenantID, &req)
+		if err != nil {
+			h.respondCreateError(w, tenantID, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, validation)
 		return
 	}
 
 	agent, err := h.svc.Create(r.Context(), tenantID, &req)
 	if err != nil {
-		h.log.Errorw("failed to create agent", "tenant_id", tenantID, "error", err)
-		respondError(w, http.StatusInternalServerError, err.Error())
+		h.respondCreateError(w, tenantID, err)
 		return
 	}
 
 	respondJSON(w, http.StatusCreated, agent)
 }
 
+// respondCreateError reports an invalid agent as a bad request
+func (h *AgentHandler) respondCreateError(w http.ResponseWriter, tenantID uuid.UUID, err error) {
+	var invalid *services.ValidationError
+	if errors.As(err, &invalid) {
+		respondValidationError(w, invalid)
+		return
+	}
+	if strings.HasPrefix(err.Error(), "failed to") {
+		h.log.Errorw("failed to create agent", "tenant_id", tenantID, "error", err)
+		respondError(w, http.StatusInternalServerError, "failed to create agent")
+		return
+	}
+	respondError(w, http.StatusBadRequest, err.Error())
+}
+
 // Get returns an agent by ID
 func (h *AgentHandler) Get(w http.ResponseWriter, r *http.Request) {
 	tenantID, ok := middleware.GetTenantID(r.Context())
@@ -117,7 +187,7 @@ func (h *AgentHandler) Update(w http.ResponseWriter, r *http.Request) {
 
 	agent, err := h.svc.Update(r.Context(), tenantID, agentID, updates)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, err.Error())
+		respondServiceError(w, h.log, "update agent", err)
 		return
 	}
 
@@ -215,7 +285,7 @@ func (h *AgentHandler) Terminate(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, agent)
 }
 
-// ListRuns returns runs for an agent
+// ListRuns returns a page of runs for an agent, newest first
 func (h *AgentHandler) ListRuns(w http.ResponseWriter, r *http.Request) {
 	tenantID, ok := middleware.GetTenantID(r.Context())
 	if !ok {
@@ -229,23 +299,28 @@ func (h *AgentHandler) ListRuns(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	limit := 50
+	// The service applies the default and maximum page size
+	limit := 0
 	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
 		if l, err := strconv.Atoi(limitStr); err == nil {
 			limit = l
 		}
 	}
 
-	runs, err := h.svc.ListRuns(r.Context(), tenantID, agentID, limit)
+	page, err := h.svc.ListRuns(r.Context(), tenantID, agentID, r.URL.Query().Get("cursor"), limit)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, err.Error())
+		switch {
+		case errors.Is(err, repository.ErrInvalidCursor):
+			respondError(w, http.StatusBadRequest, err.Error())
+		case err.Error() == "agent not found":
+			respondError(w, http.StatusNotFound, err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, err.Error())
+		}
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"runs":  runs,
-		"count": len(runs),
-	})
+	respondJSON(w, http.StatusOK, page)
 }
 
 // GetRun returns a specific run
@@ -285,11 +360,19 @@ func (h *AgentHandler) GetRunLogs(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// ListTemplates returns available agent templates
+// ListTemplates returns the built-in templates and those saved by or shared
+// with the tenant
 func (h *AgentHandler) ListTemplates(w http.ResponseWriter, r *http.Request) {
-	templates, err := h.svc.GetTemplates(r.Context())
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	templates, err := h.svc.GetTemplates(r.Context(), tenantID)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, err.Error())
+		h.log.Errorw("failed to list agent templates", "tenant_id", tenantID, "error", err)
+		respondError(w, http.StatusInternalServerError, "failed to list templates")
 		return
 	}
 
@@ -299,3 +382,198 @@ func (h *AgentHandler) ListTemplates(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// CreateFromTemplate creates an agent from a template, with the name,
+// provider and model optionally overridden
+func (h *AgentHandler) CreateFromTemplate(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	templateID, err := uuid.Parse(chi.URLParam(r, "templateID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid template ID")
+		return
+	}
+
+	var req services.CreateFromTemplateRequest
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &req); err != nil {
+			respondError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+
+	agent, err := h.svc.CreateFromTemplate(r.Context(), tenantID, templateID, &req)
+	if err != nil {
+		switch {
+		case err.Error() == "template not found":
+			respondError(w, http.StatusNotFound, err.Error())
+		case strings.HasPrefix(err.Error(), "failed to"):
+			h.log.Errorw("failed to create agent from template", "tenant_id", tenantID, "template_id", templateID, "error", err)
+			respondError(w, http.StatusInternalServerError, "failed to create agent")
+		default:
+			respondError(w, http.StatusBadRequest, err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, agent)
+}
+
+// CreateTemplate saves a template for the tenant
+func (h *AgentHandler) CreateTemplate(w http.ResponseWriter, r *http.Request) {
+	tenantID, userID, role, ok := templateCaller(w, r)
+	if !ok {
+		return
+	}
+
+	var req services.CreateTemplateRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	template, err := h.svc.CreateTemplate(r.Context(), tenantID, userID, role, &req)
+	if err != nil {
+		h.respondTemplateError(w, "create template", err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, template)
+}
+
+// SaveAsTemplate saves an agent as a tenant template
+func (h *AgentHandler) SaveAsTemplate(w http.ResponseWriter, r *http.Request) {
+	tenantID, userID, role, ok := templateCaller(w, r)
+	if !ok {
+		return
+	}
+
+	agentID, err := uuid.Parse(chi.URLParam(r, "agentID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid agent ID")
+		return
+	}
+
+	var req services.SaveAsTemplateRequest
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &req); err != nil {
+			respondError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+
+	template, err := h.svc.SaveAsTemplate(r.Context(), tenantID, userID, role, agentID, &req)
+	if err != nil {
+		h.respondTemplateError(w, "save template", err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, template)
+}
+
+// DeleteTemplate deletes one of the tenant's templates
+func (h *AgentHandler) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	tenantID, _, role, ok := templateCaller(w, r)
+	if !ok {
+		return
+	}
+
+	templateID, err := uuid.Parse(chi.URLParam(r, "templateID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid template ID")
+		return
+	}
+
+	if err := h.svc.DeleteTemplate(r.Context(), tenantID, templateID, role); err != nil {
+		h.respondTemplateError(w, "delete template", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "template deleted"})
+}
+
+// templateCaller returns the caller's tenant, user and role, responding with
+// 401 when any is missing
+func templateCaller(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, models.UserRole, bool) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return uuid.Nil, uuid.Nil, "", false
+	}
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "user context required")
+		return uuid.Nil, uuid.Nil, "", false
+	}
+	role, _ := middleware.GetUserRole(r.Context())
+	return tenantID, userID, models.UserRole(role), true
+}
+
+// respondTemplateError maps agent template errors to HTTP responses
+func (h *AgentHandler) respondTemplateError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case errors.Is(err, services.ErrTemplateOwnerRequired):
+		respondError(w, http.StatusForbidden, err.Error())
+	case err.Error() == "template not found", err.Error() == "agent not found":
+		respondError(w, http.StatusNotFound, err.Error())
+	case strings.HasPrefix(err.Error(), "failed to"):
+		h.log.Errorw("failed to "+action, "error", err)
+		respondError(w, http.StatusInternalServerError, "failed to "+action)
+	default:
+		respondError(w, http.StatusBadRequest, err.Error())
+	}
+}
+
+
+// Export returns a portable manifest of an agent
+func (h *AgentHandler) Export(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	agentID, err := uuid.Parse(chi.URLParam(r, "agentID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid agent ID")
+		return
+	}
+
+	manifest, err := h.svc.Export(r.Context(), tenantID, agentID)
+	if err != nil {
+		respondServiceError(w, h.log, "export agent", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, manifest)
+}
+
+// Import creates an agent from a manifest produced by Export
+func (h *AgentHandler) Import(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	var manifest services.AgentManifest
+	if err := decodeJSON(r, &manifest); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := manifest.Validate(); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	agent, err := h.svc.Import(r.Context(), tenantID, &manifest)
+	if err != nil {
+		respondServiceError(w, h.log, "import agent", err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, agent)
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/middleware"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+)
+
+// AnalyticsHandler handles usage analytics endpoints
+type AnalyticsHandler struct {
+	svc *services.APIUsageService
+	log *logger.Logger
+}
+
+// NewAnalyticsHandler creates a new analytics handler
+func NewAnalyticsHandler(svc *services.APIUsageService, log *logger.Logger) *AnalyticsHandler {
+	return &AnalyticsHandler{svc: svc, log: log}
+}
+
+// APIUsage returns the tenant's API usage by endpoint and status over a time window
+func (h *AnalyticsHandler) APIUsage(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	until := time.Now()
+	since := until.AddDate(0, 0, -7)
+
+	switch r.URL.Query().Get("period") {
+	case "":
+	case "24h":
+		since = until.Add(-24 * time.Hour)
+	case "7d":
+		since = until.AddDate(0, 0, -7)
+	case "30d":
+		since = until.AddDate(0, 0, -30)
+	case "90d":
+		since = until.AddDate(0, 0, -90)
+	default:
+		respondError(w, http.StatusBadRequest, "period must be one of 24h, 7d, 30d, 90d")
+		return
+	}
+
+	if start := r.URL.Query().Get("start"); start != "" {
+		t, err := time.Parse(time.RFC3339, start)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "start must be an RFC 3339 timestamp")
+			return
+		}
+		since = t
+	}
+	if end := r.URL.Query().Get("end"); end != "" {
+		t, err := time.Parse(time.RFC3339, end)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "end must be an RFC 3339 timestamp")
+			return
+		}
+		until = t
+	}
+
+	report, err := h.svc.GetUsage(r.Context(), tenantID, since, until)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, report)
+}
@@ -1,9 +1,14 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 
+	"github.com/delphi-platform/delphi/backend/internal/apierror"
+	"github.com/delphi-platform/delphi/backend/internal/middleware"
 	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/pkg/auth"
 	"github.com/delphi-platform/delphi/backend/pkg/logger"
 )
 
@@ -33,6 +38,11 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	tokens, user, err := h.svc.Login(r.Context(), &req)
 	if err != nil {
+		if errors.Is(err, services.ErrMFARequired) || errors.Is(err, services.ErrInvalidMFACode) {
+			apierror.Write(w, http.StatusUnauthorized, apierror.WithCode(apierror.CodeMFARequired, err.Error(),
+				map[string]bool{"mfa_required": true}))
+			return
+		}
 		h.log.Warnw("login failed", "email", req.Email, "error", err)
 		respondError(w, http.StatusUnauthorized, "invalid credentials")
 		return
@@ -52,15 +62,29 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Email == "" || req.Password == "" || req.Name == "" || req.TenantName == "" || req.TenantSlug == "" {
-		respondError(w, http.StatusBadRequest, "email, password, name, tenant_name, and tenant_slug are required")
+	if req.Email == "" || req.Password == "" || req.Name == "" {
+		respondError(w, http.StatusBadRequest, "email, password, and name are required")
 		return
 	}
 
 	tokens, user, err := h.svc.Register(r.Context(), &req)
 	if err != nil {
-		h.log.Warnw("registration failed", "email", req.Email, "error", err)
-		respondError(w, http.StatusBadRequest, err.Error())
+		var taken *services.SlugTakenError
+		switch {
+		case errors.As(err, &taken):
+			var details map[string]string
+			if taken.Suggestion != "" {
+				details = map[string]string{"suggested_slug": taken.Suggestion}
+			}
+			apierror.Write(w, http.StatusConflict, apierror.WithCode(apierror.CodeSlugTaken, taken.Error(), details))
+		case err.Error() == "email already registered":
+			respondError(w, http.StatusConflict, err.Error())
+		case strings.HasPrefix(err.Error(), "failed to"):
+			h.log.Errorw("registration failed", "email", req.Email, "error", err)
+			respondError(w, http.StatusInternalServerError, "registration failed")
+		default:
+			respondError(w, http.StatusBadRequest, err.Error())
+		}
 		return
 	}
 
@@ -117,3 +141,97 @@ func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// EnrollMFA starts TOTP enrollment for the caller, returning the secret and
+// otpauth URL to add to an authenticator app
+func (h *AuthHandler) EnrollMFA(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "user context required")
+		return
+	}
+
+	enrollment, err := h.svc.EnrollMFA(r.Context(), userID)
+	if err != nil {
+		h.respondMFAError(w, "enroll mfa", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, enrollment)
+}
+
+// VerifyMFA confirms enrollment with a code and enables MFA, returning the
+// recovery codes
+func (h *AuthHandler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "user context required")
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Code == "" {
+		respondError(w, http.StatusBadRequest, "code is required")
+		return
+	}
+
+	codes, err := h.svc.VerifyMFA(r.Context(), userID, req.Code)
+	if err != nil {
+		h.respondMFAError(w, "verify mfa", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"mfa_enabled":    true,
+		"recovery_codes": codes,
+	})
+}
+
+// DisableMFA turns MFA off given the caller's password and a current code or
+// recovery code
+func (h *AuthHandler) DisableMFA(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "user context required")
+		return
+	}
+
+	var req struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Password == "" || req.Code == "" {
+		respondError(w, http.StatusBadRequest, "password and code are required")
+		return
+	}
+
+	if err := h.svc.DisableMFA(r.Context(), userID, req.Password, req.Code); err != nil {
+		h.respondMFAError(w, "disable mfa", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"mfa_enabled": false})
+}
+
+func (h *AuthHandler) respondMFAError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidMFACode), errors.Is(err, auth.ErrIncorrectPassword):
+		respondError(w, http.StatusForbidden, err.Error())
+	case err.Error() == "user not found":
+		respondError(w, http.StatusNotFound, err.Error())
+	case strings.HasPrefix(err.Error(), "failed to"):
+		h.log.Errorw("failed to "+action, "error", err)
+		respondError(w, http.StatusInternalServerError, "Failed to "+action)
+	default:
+		respondError(w, http.StatusConflict, err.Error())
+	}
+}
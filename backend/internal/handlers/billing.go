@@ -41,7 +41,7 @@ func (h *BillingHandler) GetCurrentPlan(w http.ResponseWriter, r *http.Request)
 	// Extract tenant from context (set by auth middleware)
 	tenantID := r.Context().Value("tenant_id")
 	if tenantID == nil {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		respondError(w, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
@@ -69,7 +69,7 @@ func (h *BillingHandler) CreateSubscription(w http.ResponseWriter, r *http.Reque
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
 
@@ -116,20 +116,20 @@ func (h *BillingHandler) HandleStripeWebhook(w http.ResponseWriter, r *http.Requ
 	payload, err := io.ReadAll(r.Body)
 	if err != nil {
 		h.log.Errorw("failed to read webhook body", "error", err)
-		http.Error(w, "failed to read body", http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, "failed to read body")
 		return
 	}
 
 	event, err := webhook.ConstructEvent(payload, r.Header.Get("Stripe-Signature"), h.webhookSecret)
 	if err != nil {
 		h.log.Errorw("failed to verify webhook signature", "error", err)
-		http.Error(w, "invalid signature", http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, "invalid signature")
 		return
 	}
 
 	if err := h.billingService.HandleWebhook(r.Context(), event); err != nil {
 		h.log.Errorw("failed to handle webhook", "error", err, "event_type", event.Type)
-		http.Error(w, "webhook handler failed", http.StatusInternalServerError)
+		respondError(w, http.StatusInternalServerError, "webhook handler failed")
 		return
 	}
 
@@ -179,7 +179,7 @@ func (h *BillingHandler) SetBudget(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
 
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/delphi-platform/delphi/backend/internal/middleware"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// respondBusinessError maps business and project service errors to responses
+func respondBusinessError(w http.ResponseWriter, log *logger.Logger, err error) {
+	switch {
+	case err.Error() == "business not found", err.Error() == "project not found":
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, repository.ErrBusinessHasProjects):
+		respondError(w, http.StatusConflict, err.Error())
+	case strings.HasPrefix(err.Error(), "failed to"):
+		log.Errorw("business request failed", "error", err)
+		respondError(w, http.StatusInternalServerError, "Internal error")
+	default:
+		respondError(w, http.StatusBadRequest, err.Error())
+	}
+}
+
+// urlID reads a tenant and a UUID URL parameter, writing an error response if
+// either is missing or malformed
+func urlID(w http.ResponseWriter, r *http.Request, param, name string) (tenantID, id uuid.UUID, ok bool) {
+	tenantID, ok = middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	id, err := uuid.Parse(chi.URLParam(r, param))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid "+name+" ID")
+		return tenantID, id, false
+	}
+	return tenantID, id, true
+}
+
+// BusinessHandler handles business endpoints
+type BusinessHandler struct {
+	svc *services.BusinessService
+	log *logger.Logger
+}
+
+// NewBusinessHandler creates a new business handler
+func NewBusinessHandler(svc *services.BusinessService, log *logger.Logger) *BusinessHandler {
+	return &BusinessHandler{svc: svc, log: log}
+}
+
+func (h *BusinessHandler) List(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	businesses, err := h.svc.List(r.Context(), tenantID)
+	if err != nil {
+		respondBusinessError(w, h.log, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"businesses": businesses})
+}
+
+func (h *BusinessHandler) Create(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	var req services.CreateBusinessRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	business, err := h.svc.Create(r.Context(), tenantID, &req)
+	if err != nil {
+		respondBusinessError(w, h.log, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, business)
+}
+
+func (h *BusinessHandler) Get(w http.ResponseWriter, r *http.Request) {
+	tenantID, businessID, ok := urlID(w, r, "businessID", "business")
+	if !ok {
+		return
+	}
+
+	business, err := h.svc.Get(r.Context(), tenantID, businessID)
+	if err != nil {
+		respondBusinessError(w, h.log, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, business)
+}
+
+func (h *BusinessHandler) Update(w http.ResponseWriter, r *http.Request) {
+	tenantID, businessID, ok := urlID(w, r, "businessID", "business")
+	if !ok {
+		return
+	}
+
+	var req services.UpdateBusinessRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	business, err := h.svc.Update(r.Context(), tenantID, businessID, &req)
+	if err != nil {
+		respondBusinessError(w, h.log, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, business)
+}
+
+// Delete removes a business, responding 409 Conflict while it has projects
+func (h *BusinessHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	tenantID, businessID, ok := urlID(w, r, "businessID", "business")
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Delete(r.Context(), tenantID, businessID); err != nil {
+		respondBusinessError(w, h.log, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "business deleted"})
+}
+
+// ProjectHandler handles project endpoints
+type ProjectHandler struct {
+	svc *services.ProjectService
+	log *logger.Logger
+}
+
+// NewProjectHandler creates a new project handler
+func NewProjectHandler(svc *services.ProjectService, log *logger.Logger) *ProjectHandler {
+	return &ProjectHandler{svc: svc, log: log}
+}
+
+// List returns the tenant's projects, filtered by ?business_id=
+func (h *ProjectHandler) List(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	var businessID *uuid.UUID
+	if v := r.URL.Query().Get("business_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid business ID")
+			return
+		}
+		businessID = &id
+	}
+
+	projects, err := h.svc.List(r.Context(), tenantID, businessID)
+	if err != nil {
+		respondBusinessError(w, h.log, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"projects": projects})
+}
+
+func (h *ProjectHandler) Create(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	var req services.CreateProjectRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	project, err := h.svc.Create(r.Context(), tenantID, &req)
+	if err != nil {
+		respondBusinessError(w, h.log, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, project)
+}
+
+func (h *ProjectHandler) Get(w http.ResponseWriter, r *http.Request) {
+	tenantID, projectID, ok := urlID(w, r, "projectID", "project")
+	if !ok {
+		return
+	}
+
+	project, err := h.svc.Get(r.Context(), tenantID, projectID)
+	if err != nil {
+		respondBusinessError(w, h.log, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, project)
+}
+
+func (h *ProjectHandler) Update(w http.ResponseWriter, r *http.Request) {
+	tenantID, projectID, ok := urlID(w, r, "projectID", "project")
+	if !ok {
+		return
+	}
+
+	var req services.UpdateProjectRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	project, err := h.svc.Update(r.Context(), tenantID, projectID, &req)
+	if err != nil {
+		respondBusinessError(w, h.log, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, project)
+}
+
+func (h *ProjectHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	tenantID, projectID, ok := urlID(w, r, "projectID", "project")
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Delete(r.Context(), tenantID, projectID); err != nil {
+		respondBusinessError(w, h.log, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "project deleted"})
+}
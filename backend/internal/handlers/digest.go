@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/delphi-platform/delphi/backend/internal/middleware"
+	"github.com/delphi-platform/delphi/backend/internal/security"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/go-chi/chi/v5"
+)
+
+// DigestHandler handles the tenant's weekly digest settings under
+// /settings/digest
+type DigestHandler struct {
+	svc *services.DigestService
+	log *logger.Logger
+}
+
+// NewDigestHandler creates a new digest handler
+func NewDigestHandler(svc *services.DigestService, log *logger.Logger) *DigestHandler {
+	return &DigestHandler{svc: svc, log: log}
+}
+
+// Routes returns the digest routes. Anyone who can read settings can see the
+// schedule and preview the digest; changing the schedule requires updating
+// settings.
+func (h *DigestHandler) Routes(rbac *security.RBAC) chi.Router {
+	read := middleware.RequirePermission(rbac, security.PermSettingsRead)
+	update := middleware.RequirePermission(rbac, security.PermSettingsUpdate)
+
+	r := chi.NewRouter()
+	r.With(read).Get("/", h.GetSchedule)
+	r.With(update).Put("/", h.UpdateSchedule)
+	r.With(read).Get("/preview", h.Preview)
+	return r
+}
+
+// respondDigestError maps digest service errors to responses
+func (h *DigestHandler) respondDigestError(w http.ResponseWriter, action string, err error) {
+	if strings.HasPrefix(err.Error(), "failed to") {
+		h.log.Errorw("digest request failed", "action", action, "error", err)
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondError(w, http.StatusBadRequest, err.Error())
+}
+
+// GetSchedule returns when the tenant's weekly digest is sent
+func (h *DigestHandler) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	sched, err := h.svc.GetSchedule(r.Context(), tenantID)
+	if err != nil {
+		h.respondDigestError(w, "get schedule", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, sched)
+}
+
+// UpdateSchedule changes when the tenant's weekly digest is sent
+func (h *DigestHandler) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	var req services.UpdateDigestScheduleRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	sched, err := h.svc.UpdateSchedule(r.Context(), tenantID, &req)
+	if err != nil {
+		h.respondDigestError(w, "update schedule", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, sched)
+}
+
+// Preview returns the digest for the week up to now without sending it
+func (h *DigestHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	digest, err := h.svc.Preview(r.Context(), tenantID)
+	if err != nil {
+		h.respondDigestError(w, "preview", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, digest)
+}
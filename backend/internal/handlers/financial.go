@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// FinancialHandler handles a business's financial endpoints under
+// /businesses/{businessID}/financial
+type FinancialHandler struct {
+	svc *services.FinancialService
+	log *logger.Logger
+}
+
+// NewFinancialHandler creates a new financial handler
+func NewFinancialHandler(svc *services.FinancialService, log *logger.Logger) *FinancialHandler {
+	return &FinancialHandler{svc: svc, log: log}
+}
+
+// financialParams reads the tenant and business ID of a request, writing an
+// error response if either is missing or malformed
+func financialParams(w http.ResponseWriter, r *http.Request) (tenantID, businessID uuid.UUID, ok bool) {
+	return urlID(w, r, "businessID", "business")
+}
+
+// respondFinancialError maps financial service errors to responses
+func (h *FinancialHandler) respondFinancialError(w http.ResponseWriter, businessID uuid.UUID, err error) {
+	switch {
+	case err.Error() == "business not found", err.Error() == "account not found":
+		respondError(w, http.StatusNotFound, err.Error())
+	case strings.HasPrefix(err.Error(), "failed to"):
+		h.log.Errorw("financial request failed", "business_id", businessID, "error", err)
+		respondError(w, http.StatusInternalServerError, "Internal error")
+	default:
+		respondError(w, http.StatusBadRequest, err.Error())
+	}
+}
+
+// ListAccounts returns the business's accounts
+func (h *FinancialHandler) ListAccounts(w http.ResponseWriter, r *http.Request) {
+	tenantID, businessID, ok := financialParams(w, r)
+	if !ok {
+		return
+	}
+
+	accounts, err := h.svc.ListAccounts(r.Context(), tenantID, businessID)
+	if err != nil {
+		h.respondFinancialError(w, businessID, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"accounts": accounts})
+}
+
+func (h *FinancialHandler) CreateAccount(w http.ResponseWriter, r *http.Request) {
+	tenantID, businessID, ok := financialParams(w, r)
+	if !ok {
+		return
+	}
+
+	var req services.CreateAccountRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	account, err := h.svc.CreateAccount(r.Context(), tenantID, businessID, &req)
+	if err != nil {
+		h.respondFinancialError(w, businessID, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, account)
+}
+
+// ListTransactions returns the business's transactions, newest first,
+// filtered by ?account_id=, ?category= and the inclusive YYYY-MM-DD dates
+// ?from= and ?to=, up to ?limit=
+func (h *FinancialHandler) ListTransactions(w http.ResponseWriter, r *http.Request) {
+	tenantID, businessID, ok := financialParams(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	filter := repository.TransactionFilter{Category: query.Get("category")}
+	if v := query.Get("account_id"); v != "" {
+		accountID, err := uuid.Parse(v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid account ID")
+			return
+		}
+		filter.AccountID = &accountID
+	}
+	for param, dst := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if v := query.Get(param); v != "" {
+			date, err := services.ParseDate(v)
+			if err != nil {
+				respondError(w, http.StatusBadRequest, param+": "+err.Error())
+				return
+			}
+			*dst = &date
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			respondError(w, http.StatusBadRequest, "limit must be a non-negative integer")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	transactions, err := h.svc.ListTransactions(r.Context(), tenantID, businessID, filter)
+	if err != nil {
+		h.respondFinancialError(w, businessID, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"transactions": transactions,
+		"count":        len(transactions),
+	})
+}
+
+// CreateTransaction records a transaction, responding with it and its
+// account's new balance
+func (h *FinancialHandler) CreateTransaction(w http.ResponseWriter, r *http.Request) {
+	tenantID, businessID, ok := financialParams(w, r)
+	if !ok {
+		return
+	}
+
+	var req services.CreateTransactionRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	result, err := h.svc.CreateTransaction(r.Context(), tenantID, businessID, &req)
+	if err != nil {
+		h.respondFinancialError(w, businessID, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, result)
+}
+
+// GetReports returns the business's profit and loss report for the calendar
+// ?period= (month, the default, quarter or year) containing today, or for the
+// inclusive YYYY-MM-DD dates ?from= and ?to=
+func (h *FinancialHandler) GetReports(w http.ResponseWriter, r *http.Request) {
+	tenantID, businessID, ok := financialParams(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	period := query.Get("period")
+	var from, to time.Time
+	var err error
+	switch {
+	case query.Get("from") != "" || query.Get("to") != "":
+		if period != "" && period != services.ReportPeriodCustom {
+			respondError(w, http.StatusBadRequest, "use either period or from and to")
+			return
+		}
+		if query.Get("from") == "" || query.Get("to") == "" {
+			respondError(w, http.StatusBadRequest, "from and to are both required for a custom range")
+			return
+		}
+		if from, err = services.ParseDate(query.Get("from")); err != nil {
+			respondError(w, http.StatusBadRequest, "from: "+err.Error())
+			return
+		}
+		if to, err = services.ParseDate(query.Get("to")); err != nil {
+			respondError(w, http.StatusBadRequest, "to: "+err.Error())
+			return
+		}
+		period = services.ReportPeriodCustom
+	default:
+		if period == "" {
+			period = services.ReportPeriodMonth
+		}
+		if from, to, err = services.ReportRange(period, time.Now().UTC()); err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	report, err := h.svc.Report(r.Context(), tenantID, businessID, period, from, to)
+	if err != nil {
+		h.respondFinancialError(w, businessID, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, report)
+}
+
+// ListBudgets returns the business's budgets
+func (h *FinancialHandler) ListBudgets(w http.ResponseWriter, r *http.Request) {
+	tenantID, businessID, ok := financialParams(w, r)
+	if !ok {
+		return
+	}
+
+	budgets, err := h.svc.ListBudgets(r.Context(), tenantID, businessID)
+	if err != nil {
+		h.respondFinancialError(w, businessID, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"budgets": budgets})
+}
+
+func (h *FinancialHandler) CreateBudget(w http.ResponseWriter, r *http.Request) {
+	tenantID, businessID, ok := financialParams(w, r)
+	if !ok {
+		return
+	}
+
+	var req services.CreateBudgetRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	budget, err := h.svc.CreateBudget(r.Context(), tenantID, businessID, &req)
+	if err != nil {
+		h.respondFinancialError(w, businessID, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, budget)
+}
@@ -2,59 +2,69 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strings"
 
+	"github.com/delphi-platform/delphi/backend/internal/apierror"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
 	"github.com/delphi-platform/delphi/backend/internal/services"
 	"github.com/delphi-platform/delphi/backend/pkg/logger"
 )
 
 // Handlers contains all handler instances
 type Handlers struct {
-	Health     *HealthHandler
-	Auth       *AuthHandler
-	User       *UserHandler
-	Tenant     *TenantHandler
-	APIKey     *APIKeyHandler
-	Agent      *AgentHandler
-	Execute    *ExecuteHandler
-	Knowledge  *KnowledgeHandler
-	Repository *RepositoryHandler
-	Business   *BusinessHandler
-	Project    *ProjectHandler
-	Financial  *FinancialHandler
-	Social     *SocialHandler
-	IoT        *IoTHandler
-	Cost       *CostHandler
-	Dashboard  *DashboardHandler
-	Audit      *AuditHandler
-	Settings   *SettingsHandler
-	Webhook    *WebhookHandler
-	WebSocket  *WebSocketHandler
+	Health       *HealthHandler
+	Auth         *AuthHandler
+	User         *UserHandler
+	Tenant       *TenantHandler
+	APIKey       *APIKeyHandler
+	Agent        *AgentHandler
+	Execute      *ExecuteHandler
+	Knowledge    *KnowledgeHandler
+	Repository   *RepositoryHandler
+	Business     *BusinessHandler
+	Project      *ProjectHandler
+	Financial    *FinancialHandler
+	Social       *SocialHandler
+	IoT          *IoTHandler
+	Cost         *CostHandler
+	Dashboard    *DashboardHandler
+	Audit        *AuditHandler
+	Settings     *SettingsHandler
+	Webhook      *WebhookHandler
+	WebSocket    *WebSocketHandler
+	Notification *NotificationHandler
+	Analytics    *AnalyticsHandler
+	Schedule     *ScheduleHandler
 }
 
 // NewHandlers creates all handler instances
 func NewHandlers(svc *services.Services, log *logger.Logger) *Handlers {
 	return &Handlers{
-		Health:     NewHealthHandler(svc, log),
-		Auth:       NewAuthHandler(svc.Auth, log),
-		User:       NewUserHandler(svc.User, log),
-		Tenant:     NewTenantHandler(svc.Tenant, log),
-		APIKey:     NewAPIKeyHandler(svc.APIKey, log),
-		Agent:      NewAgentHandler(svc.Agent, log),
-		Execute:    NewExecuteHandler(svc.Execute, log),
-		Knowledge:  NewKnowledgeHandler(svc.Knowledge, log),
-		Repository: NewRepositoryHandler(svc.Repository, log),
-		Business:   NewBusinessHandler(svc.Business, log),
-		Project:    NewProjectHandler(svc.Project, log),
-		Financial:  NewFinancialHandler(svc.Financial, log),
-		Social:     NewSocialHandler(svc.Social, log),
-		IoT:        NewIoTHandler(svc.IoT, log),
-		Cost:       NewCostHandler(svc.Cost, log),
-		Dashboard:  NewDashboardHandler(svc.Dashboard, log),
-		Audit:      NewAuditHandler(svc.Audit, log),
-		Settings:   NewSettingsHandler(svc.Settings, log),
-		Webhook:    NewWebhookHandler(svc.Webhook, log),
-		WebSocket:  NewWebSocketHandler(svc.WebSocket, log),
+		Health:       NewHealthHandler(svc, log),
+		Auth:         NewAuthHandler(svc.Auth, log),
+		User:         NewUserHandler(svc.User, log),
+		Tenant:       NewTenantHandler(svc.Tenant, log),
+		APIKey:       NewAPIKeyHandler(svc.APIKey, log),
+		Agent:        NewAgentHandler(svc.Agent, log),
+		Execute:      NewExecuteHandler(svc.Execute, log),
+		Knowledge:    NewKnowledgeHandler(svc.Knowledge, log),
+		Repository:   NewRepositoryHandler(svc.Repository, log),
+		Business:     NewBusinessHandler(svc.Business, log),
+		Project:      NewProjectHandler(svc.Project, log),
+		Financial:    NewFinancialHandler(svc.Financial, log),
+		Social:       NewSocialHandler(svc.Social, log),
+		IoT:          NewIoTHandler(svc.IoT, log),
+		Cost:         NewCostHandler(svc.Cost, log),
+		Dashboard:    NewDashboardHandler(svc.Dashboard, log),
+		Audit:        NewAuditHandler(svc.Audit, log),
+		Settings:     NewSettingsHandler(svc.Settings, log),
+		Webhook:      NewWebhookHandler(svc.Webhook, log),
+		WebSocket:    NewWebSocketHandler(svc.WebSocket, log),
+		Notification: NewNotificationHandler(svc.Notification, log),
+		Analytics:    NewAnalyticsHandler(svc.APIUsage, log),
+		Schedule:     NewScheduleHandler(svc.Schedule, log),
 	}
 }
 
@@ -69,9 +79,50 @@ func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	}
 }
 
-// respondError sends an error response
+// respondError sends an error response, with the code apierror maps the
+// status and message to
 func respondError(w http.ResponseWriter, status int, message string) {
-	respondJSON(w, status, map[string]string{"error": message})
+	apierror.Write(w, status, apierror.New(status, message))
+}
+
+// respondServiceError maps a service error to its status. Errors the services
+// define get their own, with the fields of a validation error as details; "... not found" is a 404; "failed to ..." is a 500,
+// logged and reported as failing to do action; anything else is a bad
+// request. The error code follows from the status and message.
+func respondServiceError(w http.ResponseWriter, log *logger.Logger, action string, err error) {
+	message := err.Error()
+	var invalid *services.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		respondValidationError(w, invalid)
+	case errors.Is(err, services.ErrBudgetExceeded), errors.Is(err, services.ErrBillingRestricted):
+		respondError(w, http.StatusPaymentRequired, message)
+	case errors.Is(err, services.ErrMaxConcurrentRuns):
+		respondError(w, http.StatusTooManyRequests, message)
+	case errors.Is(err, services.ErrProviderFailed):
+		respondError(w, http.StatusBadGateway, message)
+	case errors.Is(err, services.ErrRunFinished), errors.Is(err, services.ErrDeletionScheduled),
+		errors.Is(err, repository.ErrRecipientExists), errors.Is(err, services.ErrAgentExists):
+		respondError(w, http.StatusConflict, message)
+	case errors.Is(err, services.ErrTemplateOwnerRequired), errors.Is(err, services.ErrInvalidDeletionToken):
+		respondError(w, http.StatusForbidden, message)
+	case errors.Is(err, repository.ErrInvalidCursor):
+		respondError(w, http.StatusBadRequest, message)
+	case errors.Is(err, services.ErrRunNotActive), strings.HasSuffix(message, "not found"):
+		respondError(w, http.StatusNotFound, message)
+	case strings.HasPrefix(message, "failed to"):
+		log.Errorw("request failed", "action", action, "error", err)
+		respondError(w, http.StatusInternalServerError, "failed to "+action)
+	default:
+		respondError(w, http.StatusBadRequest, message)
+	}
+}
+
+// respondValidationError reports the fields of a request that are out of
+// bounds
+func respondValidationError(w http.ResponseWriter, invalid *services.ValidationError) {
+	apierror.Write(w, http.StatusBadRequest, apierror.WithCode(apierror.CodeValidation, invalid.Error(),
+		map[string][]services.FieldError{"fields": invalid.Fields}))
 }
 
 // decodeJSON decodes JSON request body
@@ -79,15 +130,8 @@ func decodeJSON(r *http.Request, v interface{}) error {
 	return json.NewDecoder(r.Body).Decode(v)
 }
 
-// ErrorResponse represents an error response
-type ErrorResponse struct {
-	Error   string `json:"error"`
-	Details string `json:"details,omitempty"`
-}
-
 // SuccessResponse represents a success response
 type SuccessResponse struct {
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
 }
-
@@ -26,15 +26,15 @@ func (h *HealthHandler) Check(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// Ready handles readiness check (includes dependencies)
+// Ready handles readiness check: the database and Redis must be reachable.
+// With ?providers=true the providers' reachability is reported too, without
+// affecting the status.
 func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
-	// In production, this would check database and Redis connectivity
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"status": "ready",
-		"checks": map[string]string{
-			"database": "ok",
-			"redis":    "ok",
-		},
-	})
+	readiness := h.svc.Health.Check(r.Context(), r.URL.Query().Get("providers") == "true")
+	status := http.StatusOK
+	if !readiness.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	respondJSON(w, status, readiness)
 }
 
@@ -0,0 +1,372 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/delphi-platform/delphi/backend/internal/middleware"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// NotificationHandler handles notification settings endpoints
+type NotificationHandler struct {
+	svc *services.NotificationService
+	log *logger.Logger
+}
+
+// NewNotificationHandler creates a new notification handler
+func NewNotificationHandler(svc *services.NotificationService, log *logger.Logger) *NotificationHandler {
+	return &NotificationHandler{svc: svc, log: log}
+}
+
+// ListRecipients returns the tenant's default notification recipients
+func (h *NotificationHandler) ListRecipients(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	recipients, err := h.svc.ListRecipients(r.Context(), tenantID)
+	if err != nil {
+		h.log.Errorw("failed to list notification recipients", "tenant_id", tenantID, "error", err)
+		respondError(w, http.StatusInternalServerError, "failed to list recipients")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"recipients": recipients,
+		"count":      len(recipients),
+	})
+}
+
+// AddRecipient adds a default notification recipient (admins only)
+func (h *NotificationHandler) AddRecipient(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	if !isTenantAdmin(r) {
+		respondError(w, http.StatusForbidden, "insufficient permissions")
+		return
+	}
+
+	var req services.AddRecipientRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	recipient, err := h.svc.AddRecipient(r.Context(), tenantID, &req)
+	if err != nil {
+		respondServiceError(w, h.log, "add notification recipient", err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, recipient)
+}
+
+// RemoveRecipient removes a default notification recipient (admins only)
+func (h *NotificationHandler) RemoveRecipient(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	if !isTenantAdmin(r) {
+		respondError(w, http.StatusForbidden, "insufficient permissions")
+		return
+	}
+
+	recipientID, err := uuid.Parse(chi.URLParam(r, "recipientID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid recipient ID")
+		return
+	}
+
+	if err := h.svc.RemoveRecipient(r.Context(), tenantID, recipientID); err != nil {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "recipient removed"})
+}
+
+// GetPushConfig returns the VAPID public key the dashboard subscribes to push
+// notifications with
+func (h *NotificationHandler) GetPushConfig(w http.ResponseWriter, r *http.Request) {
+	publicKey, err := h.svc.PushPublicKey()
+	if err != nil {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"vapid_public_key": publicKey})
+}
+
+// RegisterPushSubscription saves the caller's browser push subscription
+func (h *NotificationHandler) RegisterPushSubscription(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "user context required")
+		return
+	}
+
+	var req services.RegisterPushSubscriptionRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	sub, err := h.svc.RegisterPushSubscription(r.Context(), tenantID, userID, r.UserAgent(), &req)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "failed to") {
+			h.log.Errorw("failed to register push subscription", "user_id", userID, "error", err)
+			respondError(w, http.StatusInternalServerError, "failed to register push subscription")
+			return
+		}
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, sub)
+}
+
+// UnregisterPushSubscription removes one of the caller's push subscriptions,
+// identified by its endpoint
+func (h *NotificationHandler) UnregisterPushSubscription(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "user context required")
+		return
+	}
+
+	var req struct {
+		Endpoint string `json:"endpoint"`
+	}
+	if err := decodeJSON(r, &req); err != nil || req.Endpoint == "" {
+		respondError(w, http.StatusBadRequest, "endpoint is required")
+		return
+	}
+
+	if err := h.svc.UnregisterPushSubscription(r.Context(), userID, req.Endpoint); err != nil {
+		if strings.HasPrefix(err.Error(), "failed to") {
+			h.log.Errorw("failed to unregister push subscription", "user_id", userID, "error", err)
+			respondError(w, http.StatusInternalServerError, "failed to unregister push subscription")
+			return
+		}
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "push subscription removed"})
+}
+
+// respondNotificationError maps notification service errors to responses
+func (h *NotificationHandler) respondNotificationError(w http.ResponseWriter, action string, err error) {
+	if strings.HasPrefix(err.Error(), "failed to") {
+		h.log.Errorw("notification request failed", "action", action, "error", err)
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondError(w, http.StatusBadRequest, err.Error())
+}
+
+// ListPreferences returns the caller's effective channels for every
+// notification type
+func (h *NotificationHandler) ListPreferences(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "user context required")
+		return
+	}
+
+	h.listPreferences(w, r, tenantID, &userID)
+}
+
+// SetPreference sets the caller's channels for a notification type
+func (h *NotificationHandler) SetPreference(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "user context required")
+		return
+	}
+
+	h.setPreference(w, r, tenantID, &userID)
+}
+
+// ResetPreference drops the caller's channels for a notification type, so the
+// tenant's default applies
+func (h *NotificationHandler) ResetPreference(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "user context required")
+		return
+	}
+
+	h.resetPreference(w, r, tenantID, &userID)
+}
+
+// ListTenantPreferences returns the tenant's default channels for every
+// notification type
+func (h *NotificationHandler) ListTenantPreferences(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	h.listPreferences(w, r, tenantID, nil)
+}
+
+// SetTenantPreference sets the tenant's default channels for a notification
+// type (admins only)
+func (h *NotificationHandler) SetTenantPreference(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	if !isTenantAdmin(r) {
+		respondError(w, http.StatusForbidden, "insufficient permissions")
+		return
+	}
+
+	h.setPreference(w, r, tenantID, nil)
+}
+
+// ResetTenantPreference restores the built-in channels for a notification
+// type (admins only)
+func (h *NotificationHandler) ResetTenantPreference(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	if !isTenantAdmin(r) {
+		respondError(w, http.StatusForbidden, "insufficient permissions")
+		return
+	}
+
+	h.resetPreference(w, r, tenantID, nil)
+}
+
+func (h *NotificationHandler) listPreferences(w http.ResponseWriter, r *http.Request, tenantID uuid.UUID, userID *uuid.UUID) {
+	prefs, err := h.svc.GetPreferences(r.Context(), tenantID, userID)
+	if err != nil {
+		h.respondNotificationError(w, "list preferences", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"preferences": prefs})
+}
+
+func (h *NotificationHandler) setPreference(w http.ResponseWriter, r *http.Request, tenantID uuid.UUID, userID *uuid.UUID) {
+	var req services.SetPreferenceRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	pref, err := h.svc.SetPreference(r.Context(), tenantID, userID, chi.URLParam(r, "type"), &req)
+	if err != nil {
+		h.respondNotificationError(w, "set preference", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, pref)
+}
+
+func (h *NotificationHandler) resetPreference(w http.ResponseWriter, r *http.Request, tenantID uuid.UUID, userID *uuid.UUID) {
+	if err := h.svc.ResetPreference(r.Context(), tenantID, userID, chi.URLParam(r, "type")); err != nil {
+		h.respondNotificationError(w, "reset preference", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "preference reset"})
+}
+
+// GetDoNotDisturb returns the caller's do-not-disturb window
+func (h *NotificationHandler) GetDoNotDisturb(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "user context required")
+		return
+	}
+
+	dnd, err := h.svc.GetDoNotDisturb(r.Context(), tenantID, userID)
+	if err != nil {
+		h.respondNotificationError(w, "get do not disturb", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, dnd)
+}
+
+// SetDoNotDisturb sets the caller's do-not-disturb window
+func (h *NotificationHandler) SetDoNotDisturb(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "user context required")
+		return
+	}
+
+	var req services.SetDoNotDisturbRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	dnd, err := h.svc.SetDoNotDisturb(r.Context(), tenantID, userID, &req)
+	if err != nil {
+		h.respondNotificationError(w, "set do not disturb", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, dnd)
+}
+
+// isTenantAdmin reports whether the caller is an owner or admin of the tenant
+func isTenantAdmin(r *http.Request) bool {
+	role, _ := middleware.GetUserRole(r.Context())
+	return role == string(models.RoleOwner) || role == string(models.RoleAdmin)
+}
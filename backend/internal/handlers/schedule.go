@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/delphi-platform/delphi/backend/internal/middleware"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// ScheduleHandler handles an agent's scheduled execution endpoints under
+// /agents/{agentID}/schedules
+type ScheduleHandler struct {
+	svc *services.ScheduleService
+	log *logger.Logger
+}
+
+// NewScheduleHandler creates a new schedule handler
+func NewScheduleHandler(svc *services.ScheduleService, log *logger.Logger) *ScheduleHandler {
+	return &ScheduleHandler{svc: svc, log: log}
+}
+
+// scheduleParams reads the tenant and the agent and schedule IDs of a request,
+// writing an error response if any is missing or malformed. withSchedule
+// requires the scheduleID URL parameter.
+func scheduleParams(w http.ResponseWriter, r *http.Request, withSchedule bool) (tenantID, agentID, scheduleID uuid.UUID, ok bool) {
+	tenantID, ok = middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	var err error
+	agentID, err = uuid.Parse(chi.URLParam(r, "agentID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid agent ID")
+		return tenantID, agentID, scheduleID, false
+	}
+
+	if withSchedule {
+		scheduleID, err = uuid.Parse(chi.URLParam(r, "scheduleID"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid schedule ID")
+			return tenantID, agentID, scheduleID, false
+		}
+	}
+	return tenantID, agentID, scheduleID, true
+}
+
+// respondScheduleError maps schedule service errors to responses
+func respondScheduleError(w http.ResponseWriter, err error) {
+	switch {
+	case err.Error() == "agent not found", err.Error() == "schedule not found":
+		respondError(w, http.StatusNotFound, err.Error())
+	case strings.HasPrefix(err.Error(), "failed to"):
+		respondError(w, http.StatusInternalServerError, err.Error())
+	default:
+		respondError(w, http.StatusBadRequest, err.Error())
+	}
+}
+
+// List returns an agent's schedules
+func (h *ScheduleHandler) List(w http.ResponseWriter, r *http.Request) {
+	tenantID, agentID, _, ok := scheduleParams(w, r, false)
+	if !ok {
+		return
+	}
+
+	schedules, err := h.svc.List(r.Context(), tenantID, agentID)
+	if err != nil {
+		respondScheduleError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"schedules": schedules,
+		"count":     len(schedules),
+	})
+}
+
+// Create adds a schedule to an agent
+func (h *ScheduleHandler) Create(w http.ResponseWriter, r *http.Request) {
+	tenantID, agentID, _, ok := scheduleParams(w, r, false)
+	if !ok {
+		return
+	}
+
+	var req services.CreateScheduleRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	schedule, err := h.svc.Create(r.Context(), tenantID, agentID, &req)
+	if err != nil {
+		respondScheduleError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, schedule)
+}
+
+// Get returns one of an agent's schedules
+func (h *ScheduleHandler) Get(w http.ResponseWriter, r *http.Request) {
+	tenantID, agentID, scheduleID, ok := scheduleParams(w, r, true)
+	if !ok {
+		return
+	}
+
+	schedule, err := h.svc.Get(r.Context(), tenantID, agentID, scheduleID)
+	if err != nil {
+		respondScheduleError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, schedule)
+}
+
+// Update changes a schedule
+func (h *ScheduleHandler) Update(w http.ResponseWriter, r *http.Request) {
+	tenantID, agentID, scheduleID, ok := scheduleParams(w, r, true)
+	if !ok {
+		return
+	}
+
+	var req services.UpdateScheduleRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	schedule, err := h.svc.Update(r.Context(), tenantID, agentID, scheduleID, &req)
+	if err != nil {
+		respondScheduleError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, schedule)
+}
+
+// Delete removes a schedule
+func (h *ScheduleHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	tenantID, agentID, scheduleID, ok := scheduleParams(w, r, true)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Delete(r.Context(), tenantID, agentID, scheduleID); err != nil {
+		respondScheduleError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "schedule deleted"})
+}
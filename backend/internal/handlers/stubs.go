@@ -1,10 +1,25 @@
 package handlers
 
 import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/delphi-platform/delphi/backend/internal/iot"
+	"github.com/delphi-platform/delphi/backend/internal/knowledge"
 	"github.com/delphi-platform/delphi/backend/internal/middleware"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/payload"
+	"github.com/delphi-platform/delphi/backend/internal/providers"
+	"github.com/delphi-platform/delphi/backend/internal/security"
 	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/pkg/auth"
 	"github.com/delphi-platform/delphi/backend/pkg/logger"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -33,61 +48,218 @@ func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, map[string]string{"message": "profile updated"})
 }
 
+// ChangePassword changes the caller's password. The current password must be
+// given and the new one must meet the password policy.
 func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "user context required")
+		return
+	}
+
+	var req services.ChangePasswordRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		respondError(w, http.StatusBadRequest, "current_password and new_password are required")
+		return
+	}
+
+	if err := h.svc.ChangePassword(r.Context(), userID, &req); err != nil {
+		switch {
+		case errors.Is(err, auth.ErrIncorrectPassword):
+			respondError(w, http.StatusForbidden, err.Error())
+		case err.Error() == "user not found":
+			respondError(w, http.StatusNotFound, err.Error())
+		case strings.HasPrefix(err.Error(), "failed to"):
+			h.log.Errorw("failed to change password", "user_id", userID, "error", err)
+			respondError(w, http.StatusInternalServerError, "Failed to change password")
+		default:
+			respondError(w, http.StatusBadRequest, err.Error())
+		}
+		return
+	}
+
 	respondJSON(w, http.StatusOK, map[string]string{"message": "password changed"})
 }
 
-// TenantHandler handles tenant endpoints
-type TenantHandler struct {
-	svc *services.TenantService
+// APIKeyHandler handles API key endpoints
+type APIKeyHandler struct {
+	svc *services.APIKeyServiceImpl
 	log *logger.Logger
 }
 
-func NewTenantHandler(svc *services.TenantService, log *logger.Logger) *TenantHandler {
-	return &TenantHandler{svc: svc, log: log}
+func NewAPIKeyHandler(svc *services.APIKeyServiceImpl, log *logger.Logger) *APIKeyHandler {
+	return &APIKeyHandler{svc: svc, log: log}
 }
 
-func (h *TenantHandler) List(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]interface{}{"tenants": []interface{}{}})
+// Routes returns the API key endpoints, each guarded by the permission it needs
+func (h *APIKeyHandler) Routes(rbac *security.RBAC) chi.Router {
+	r := chi.NewRouter()
+	r.With(middleware.RequirePermission(rbac, security.PermAPIKeyRead)).Get("/", h.List)
+	r.With(middleware.RequirePermission(rbac, security.PermAPIKeyCreate)).Post("/", h.Create)
+	r.With(middleware.RequirePermission(rbac, security.PermAPIKeyCreate)).Post("/validate", h.Validate)
+	r.With(middleware.RequirePermission(rbac, security.PermAPIKeyRevoke)).Delete("/{keyID}", h.Delete)
+	return r
 }
 
-func (h *TenantHandler) Create(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusCreated, map[string]string{"message": "tenant created"})
+func (h *APIKeyHandler) List(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	keys, err := h.svc.List(r.Context(), tenantID)
+	if err != nil {
+		h.respondKeyError(w, "list API keys", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"api_keys": keys})
 }
 
-func (h *TenantHandler) Get(w http.ResponseWriter, r *http.Request) {
-	tenantID := chi.URLParam(r, "tenantID")
-	respondJSON(w, http.StatusOK, map[string]string{"tenant_id": tenantID})
+// Create stores a provider API key after checking it with the provider. A key
+// that fails the check is rejected unless the request sets force.
+func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+	userID, _ := middleware.GetUserID(r.Context())
+
+	var req services.CreateAPIKeyRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	key, err := h.svc.Create(r.Context(), tenantID, userID, &req)
+	if err != nil {
+		h.respondKeyError(w, "create API key", err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, key)
 }
 
-func (h *TenantHandler) Update(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]string{"message": "tenant updated"})
+func (h *APIKeyHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	keyID, err := uuid.Parse(chi.URLParam(r, "keyID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid API key ID")
+		return
+	}
+
+	if err := h.svc.Delete(r.Context(), tenantID, keyID); err != nil {
+		h.respondKeyError(w, "delete API key", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "API key deleted"})
 }
 
-// APIKeyHandler handles API key endpoints
-type APIKeyHandler struct {
-	svc *services.APIKeyService
-	log *logger.Logger
+// ValidateAPIKeyRequest names a stored key to check with its provider
+type ValidateAPIKeyRequest struct {
+	KeyID uuid.UUID `json:"key_id"`
 }
 
-func NewAPIKeyHandler(svc *services.APIKeyService, log *logger.Logger) *APIKeyHandler {
-	return &APIKeyHandler{svc: svc, log: log}
+// Validate checks a stored key with its provider and records the result
+func (h *APIKeyHandler) Validate(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	var req ValidateAPIKeyRequest
+	if err := decodeJSON(r, &req); err != nil || req.KeyID == uuid.Nil {
+		respondError(w, http.StatusBadRequest, "key_id is required")
+		return
+	}
+
+	valid, err := h.svc.Validate(r.Context(), tenantID, req.KeyID)
+	if err != nil {
+		h.respondKeyError(w, "validate API key", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]bool{"valid": valid})
 }
 
-func (h *APIKeyHandler) List(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]interface{}{"api_keys": []interface{}{}})
+// EndpointRoutes returns the provider endpoint settings routes, mounted
+// under /settings/provider-endpoints
+func (h *APIKeyHandler) EndpointRoutes(rbac *security.RBAC) chi.Router {
+	read := middleware.RequirePermission(rbac, security.PermSettingsRead)
+	update := middleware.RequirePermission(rbac, security.PermSettingsUpdate)
+
+	r := chi.NewRouter()
+	r.With(read).Get("/", h.GetEndpoints)
+	r.With(update).Put("/{provider}", h.UpdateEndpoint)
+	return r
 }
 
-func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusCreated, map[string]string{"message": "API key created"})
+// GetEndpoints returns the provider endpoints the tenant has set
+func (h *APIKeyHandler) GetEndpoints(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	endpoints, err := h.svc.GetProviderEndpoints(r.Context(), tenantID)
+	if err != nil {
+		respondServiceError(w, h.log, "get provider endpoints", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"endpoints": endpoints})
 }
 
-func (h *APIKeyHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]string{"message": "API key deleted"})
+// UpdateEndpoint sets where one of the tenant's providers sends requests. An
+// empty body goes back to the server's endpoint.
+func (h *APIKeyHandler) UpdateEndpoint(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	var endpoint providers.Endpoint
+	if err := decodeJSON(r, &endpoint); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	provider := models.AIProvider(chi.URLParam(r, "provider"))
+	endpoints, err := h.svc.UpdateProviderEndpoint(r.Context(), tenantID, provider, endpoint)
+	if err != nil {
+		respondServiceError(w, h.log, "update provider endpoint", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"endpoints": endpoints})
 }
 
-func (h *APIKeyHandler) Validate(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]bool{"valid": true})
+// respondKeyError maps an API key service error to a response
+func (h *APIKeyHandler) respondKeyError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case err.Error() == "API key not found":
+		respondError(w, http.StatusNotFound, err.Error())
+	case strings.HasPrefix(err.Error(), "failed to"):
+		h.log.Errorw("failed to "+action, "error", err)
+		respondError(w, http.StatusInternalServerError, "Failed to "+action)
+	default:
+		respondError(w, http.StatusBadRequest, err.Error())
+	}
 }
 
 // ExecuteHandler handles execution endpoints
@@ -100,6 +272,18 @@ func NewExecuteHandler(svc *services.ExecuteService, log *logger.Logger) *Execut
 	return &ExecuteHandler{svc: svc, log: log}
 }
 
+// noCache reports whether the request asks, with Cache-Control: no-cache or
+// no-store, for its runs to skip the response cache
+func noCache(r *http.Request) bool {
+	for _, directive := range strings.Split(r.Header.Get("Cache-Control"), ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "no-cache", "no-store":
+			return true
+		}
+	}
+	return false
+}
+
 func (h *ExecuteHandler) Create(w http.ResponseWriter, r *http.Request) {
 	tenantID, ok := middleware.GetTenantID(r.Context())
 	if !ok {
@@ -112,16 +296,75 @@ func (h *ExecuteHandler) Create(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
+	req.NoCache = noCache(r)
 
 	run, err := h.svc.Create(r.Context(), tenantID, &req)
 	if err != nil {
-		respondError(w, http.StatusBadRequest, err.Error())
+		respondServiceError(w, h.log, "start execution", err)
 		return
 	}
 
 	respondJSON(w, http.StatusCreated, run)
 }
 
+// CreateBatch starts a run for each item of a batch and returns their IDs
+// without waiting for them. Items that can't start are reported per item.
+func (h *ExecuteHandler) CreateBatch(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	var req services.BatchRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if noCache(r) {
+		for i := range req.Items {
+			req.Items[i].NoCache = true
+		}
+	}
+
+	batch, err := h.svc.CreateBatch(r.Context(), tenantID, &req)
+	if err != nil {
+		respondServiceError(w, h.log, "create batch", err)
+		return
+	}
+
+	respondJSON(w, http.StatusAccepted, batch)
+}
+
+// GetBatch returns a batch's runs with their aggregate status and cost
+func (h *ExecuteHandler) GetBatch(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	batchID, err := uuid.Parse(chi.URLParam(r, "batchID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid batch ID")
+		return
+	}
+
+	status, err := h.svc.GetBatch(r.Context(), tenantID, batchID)
+	if err != nil {
+		if err.Error() == "batch not found" {
+			respondError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		h.log.Errorw("failed to get batch", "batch_id", batchID, "error", err)
+		respondError(w, http.StatusInternalServerError, "failed to get batch")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, status)
+}
+
 func (h *ExecuteHandler) Get(w http.ResponseWriter, r *http.Request) {
 	tenantID, ok := middleware.GetTenantID(r.Context())
 	if !ok {
@@ -144,6 +387,117 @@ func (h *ExecuteHandler) Get(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, run)
 }
 
+func (h *ExecuteHandler) Queue(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	execID, err := uuid.Parse(chi.URLParam(r, "executionID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid execution ID")
+		return
+	}
+
+	status, err := h.svc.QueueStatus(r.Context(), tenantID, execID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "execution not found")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, status)
+}
+
+func (h *ExecuteHandler) Replay(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	execID, err := uuid.Parse(chi.URLParam(r, "executionID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid execution ID")
+		return
+	}
+
+	var overrides services.ReplayOverrides
+	if err := decodeJSON(r, &overrides); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	replay, err := h.svc.Replay(r.Context(), tenantID, execID, &overrides)
+	if err != nil {
+		respondServiceError(w, h.log, "replay execution", err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, replay)
+}
+
+// Estimate returns the projected tokens and cost of an execution request
+// without running it
+func (h *ExecuteHandler) Estimate(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	var req services.ExecuteRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	estimate, err := h.svc.Estimate(r.Context(), tenantID, &req)
+	if err != nil {
+		respondServiceError(w, h.log, "estimate execution", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, estimate)
+}
+
+// Payload returns a run's full prompt or response, which the run itself holds
+// only truncated when it was over the stored size limit
+func (h *ExecuteHandler) Payload(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	execID, err := uuid.Parse(chi.URLParam(r, "executionID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid execution ID")
+		return
+	}
+	kind := chi.URLParam(r, "kind")
+
+	content, err := h.svc.Payload(r.Context(), tenantID, execID, kind)
+	if err != nil {
+		switch {
+		case err.Error() == "run not found", errors.Is(err, payload.ErrNotFound):
+			respondError(w, http.StatusNotFound, "payload not found")
+		case strings.HasPrefix(err.Error(), "failed to"):
+			h.log.Errorw("failed to get run payload", "run_id", execID, "kind", kind, "error", err)
+			respondError(w, http.StatusInternalServerError, "failed to get payload")
+		default:
+			respondError(w, http.StatusBadRequest, err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"execution_id": execID,
+		"kind":         kind,
+		"content":      content,
+	})
+}
+
 func (h *ExecuteHandler) Cancel(w http.ResponseWriter, r *http.Request) {
 	tenantID, ok := middleware.GetTenantID(r.Context())
 	if !ok {
@@ -158,7 +512,7 @@ func (h *ExecuteHandler) Cancel(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.svc.Cancel(r.Context(), tenantID, execID); err != nil {
-		respondError(w, http.StatusBadRequest, err.Error())
+		respondServiceError(w, h.log, "cancel execution", err)
 		return
 	}
 
@@ -196,163 +550,231 @@ func (h *KnowledgeHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, map[string]string{"message": "knowledge base deleted"})
 }
 
+// maxDocumentUploadBytes bounds an uploaded document
+const maxDocumentUploadBytes = 10 << 20
+
+// UploadDocument ingests the multipart "file" field. The optional "chunking",
+// "max_tokens", "overlap_tokens" and "language" fields control how it is split.
 func (h *KnowledgeHandler) UploadDocument(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusCreated, map[string]string{"message": "document uploaded"})
-}
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
 
-func (h *KnowledgeHandler) ListDocuments(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]interface{}{"documents": []interface{}{}})
-}
+	kbID, err := uuid.Parse(chi.URLParam(r, "kbID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid knowledge base ID")
+		return
+	}
 
-func (h *KnowledgeHandler) DeleteDocument(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]string{"message": "document deleted"})
-}
+	r.Body = http.MaxBytesReader(w, r.Body, maxDocumentUploadBytes)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "file is required")
+		return
+	}
+	defer file.Close()
 
-func (h *KnowledgeHandler) Query(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]interface{}{"results": []interface{}{}})
-}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid file")
+		return
+	}
 
-// RepositoryHandler handles repository endpoints
-type RepositoryHandler struct {
-	svc *services.RepositoryService
-	log *logger.Logger
-}
-
-func NewRepositoryHandler(svc *services.RepositoryService, log *logger.Logger) *RepositoryHandler {
-	return &RepositoryHandler{svc: svc, log: log}
-}
-
-func (h *RepositoryHandler) List(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]interface{}{"repositories": []interface{}{}})
-}
+	req := services.UploadDocumentRequest{
+		Source:   header.Filename,
+		Content:  string(content),
+		Chunking: knowledge.ChunkStrategy(r.FormValue("chunking")),
+		Language: r.FormValue("language"),
+	}
+	if raw := r.FormValue("max_tokens"); raw != "" {
+		if req.MaxTokens, err = strconv.Atoi(raw); err != nil {
+			respondError(w, http.StatusBadRequest, "invalid max_tokens")
+			return
+		}
+	}
+	if raw := r.FormValue("overlap_tokens"); raw != "" {
+		if req.OverlapTokens, err = strconv.Atoi(raw); err != nil {
+			respondError(w, http.StatusBadRequest, "invalid overlap_tokens")
+			return
+		}
+	}
 
-func (h *RepositoryHandler) Connect(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusCreated, map[string]string{"message": "repository connected"})
-}
+	resp, err := h.svc.UploadDocument(r.Context(), tenantID, kbID, &req)
+	if err != nil {
+		respondServiceError(w, h.log, "upload document", err)
+		return
+	}
 
-func (h *RepositoryHandler) Get(w http.ResponseWriter, r *http.Request) {
-	repoID := chi.URLParam(r, "repoID")
-	respondJSON(w, http.StatusOK, map[string]string{"id": repoID})
+	status := http.StatusCreated
+	if resp.Outcome != knowledge.IngestCreated {
+		status = http.StatusOK
+	}
+	respondJSON(w, status, resp)
 }
 
-func (h *RepositoryHandler) Disconnect(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]string{"message": "repository disconnected"})
+func (h *KnowledgeHandler) ListDocuments(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, map[string]interface{}{"documents": []interface{}{}})
 }
 
-func (h *RepositoryHandler) Sync(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]string{"message": "sync started"})
+func (h *KnowledgeHandler) DeleteDocument(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, map[string]string{"message": "document deleted"})
 }
 
-func (h *RepositoryHandler) ListBranches(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]interface{}{"branches": []interface{}{}})
+func (h *KnowledgeHandler) Query(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, map[string]interface{}{"results": []interface{}{}})
 }
 
-func (h *RepositoryHandler) ListCommits(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]interface{}{"commits": []interface{}{}})
-}
+func (h *KnowledgeHandler) Ask(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
 
-func (h *RepositoryHandler) ListPRs(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]interface{}{"pull_requests": []interface{}{}})
-}
+	kbID, err := uuid.Parse(chi.URLParam(r, "kbID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid knowledge base ID")
+		return
+	}
 
-// BusinessHandler handles business endpoints
-type BusinessHandler struct {
-	svc *services.BusinessService
-	log *logger.Logger
-}
+	var req services.AskRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
 
-func NewBusinessHandler(svc *services.BusinessService, log *logger.Logger) *BusinessHandler {
-	return &BusinessHandler{svc: svc, log: log}
-}
+	resp, err := h.svc.Ask(r.Context(), tenantID, kbID, &req)
+	if err != nil {
+		h.log.Warnw("knowledge ask failed", "kb_id", kbID, "error", err)
+		respondServiceError(w, h.log, "answer question", err)
+		return
+	}
 
-func (h *BusinessHandler) List(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]interface{}{"businesses": []interface{}{}})
+	respondJSON(w, http.StatusOK, resp)
 }
 
-func (h *BusinessHandler) Create(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusCreated, map[string]string{"message": "business created"})
+// RepositoryHandler handles repository endpoints
+type RepositoryHandler struct {
+	svc *services.RepositoryService
+	log *logger.Logger
 }
 
-func (h *BusinessHandler) Get(w http.ResponseWriter, r *http.Request) {
-	businessID := chi.URLParam(r, "businessID")
-	respondJSON(w, http.StatusOK, map[string]string{"id": businessID})
+func NewRepositoryHandler(svc *services.RepositoryService, log *logger.Logger) *RepositoryHandler {
+	return &RepositoryHandler{svc: svc, log: log}
 }
 
-func (h *BusinessHandler) Update(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]string{"message": "business updated"})
-}
+// Routes returns the repository endpoints, each guarded by the permission it needs
+func (h *RepositoryHandler) Routes(rbac *security.RBAC) chi.Router {
+	read := middleware.RequirePermission(rbac, security.PermRepoRead)
+	connect := middleware.RequirePermission(rbac, security.PermRepoConnect)
 
-func (h *BusinessHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]string{"message": "business deleted"})
+	r := chi.NewRouter()
+	r.With(read).Get("/", h.List)
+	r.With(connect).Post("/", h.Connect)
+	r.With(read).Get("/{repoID}", h.Get)
+	r.With(middleware.RequirePermission(rbac, security.PermRepoDisconnect)).Delete("/{repoID}", h.Disconnect)
+	r.With(connect).Post("/{repoID}/sync", h.Sync)
+	r.With(read).Get("/{repoID}/sync/{jobID}", h.GetSyncJob)
+	r.With(read).Get("/{repoID}/branches", h.ListBranches)
+	r.With(read).Get("/{repoID}/commits", h.ListCommits)
+	r.With(read).Get("/{repoID}/pulls", h.ListPRs)
+	return r
 }
 
-// ProjectHandler handles project endpoints
-type ProjectHandler struct {
-	svc *services.ProjectService
-	log *logger.Logger
+func (h *RepositoryHandler) List(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, map[string]interface{}{"repositories": []interface{}{}})
 }
 
-func NewProjectHandler(svc *services.ProjectService, log *logger.Logger) *ProjectHandler {
-	return &ProjectHandler{svc: svc, log: log}
+func (h *RepositoryHandler) Connect(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusCreated, map[string]string{"message": "repository connected"})
 }
 
-func (h *ProjectHandler) List(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]interface{}{"projects": []interface{}{}})
+func (h *RepositoryHandler) Get(w http.ResponseWriter, r *http.Request) {
+	repoID := chi.URLParam(r, "repoID")
+	respondJSON(w, http.StatusOK, map[string]string{"id": repoID})
 }
 
-func (h *ProjectHandler) Create(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusCreated, map[string]string{"message": "project created"})
+func (h *RepositoryHandler) Disconnect(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, map[string]string{"message": "repository disconnected"})
 }
 
-func (h *ProjectHandler) Get(w http.ResponseWriter, r *http.Request) {
-	projectID := chi.URLParam(r, "projectID")
-	respondJSON(w, http.StatusOK, map[string]string{"id": projectID})
-}
+// Sync starts a full reindex of the repository into its knowledge base. The
+// response carries the job to poll with GetSyncJob.
+func (h *RepositoryHandler) Sync(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
 
-func (h *ProjectHandler) Update(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]string{"message": "project updated"})
-}
+	repoID, err := uuid.Parse(chi.URLParam(r, "repoID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid repository ID")
+		return
+	}
 
-func (h *ProjectHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]string{"message": "project deleted"})
-}
+	job, err := h.svc.Sync(r.Context(), tenantID, repoID)
+	if err != nil {
+		h.respondSyncError(w, "start sync", repoID, err)
+		return
+	}
 
-// FinancialHandler handles financial endpoints
-type FinancialHandler struct {
-	svc *services.FinancialService
-	log *logger.Logger
+	respondJSON(w, http.StatusAccepted, job)
 }
 
-func NewFinancialHandler(svc *services.FinancialService, log *logger.Logger) *FinancialHandler {
-	return &FinancialHandler{svc: svc, log: log}
-}
+// GetSyncJob returns the progress of a repository sync
+func (h *RepositoryHandler) GetSyncJob(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
 
-func (h *FinancialHandler) ListAccounts(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]interface{}{"accounts": []interface{}{}})
-}
+	repoID, err := uuid.Parse(chi.URLParam(r, "repoID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid repository ID")
+		return
+	}
+	jobID, err := uuid.Parse(chi.URLParam(r, "jobID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid job ID")
+		return
+	}
 
-func (h *FinancialHandler) CreateAccount(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusCreated, map[string]string{"message": "account created"})
-}
+	job, err := h.svc.GetSyncJob(r.Context(), tenantID, repoID, jobID)
+	if err != nil {
+		h.respondSyncError(w, "get sync job", repoID, err)
+		return
+	}
 
-func (h *FinancialHandler) ListTransactions(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]interface{}{"transactions": []interface{}{}})
+	respondJSON(w, http.StatusOK, job)
 }
 
-func (h *FinancialHandler) CreateTransaction(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusCreated, map[string]string{"message": "transaction created"})
+// respondSyncError maps a repository sync error to a response
+func (h *RepositoryHandler) respondSyncError(w http.ResponseWriter, action string, repoID uuid.UUID, err error) {
+	switch {
+	case err.Error() == "repository not found", err.Error() == "sync job not found":
+		respondError(w, http.StatusNotFound, err.Error())
+	case strings.HasPrefix(err.Error(), "failed to"):
+		h.log.Errorw("failed to "+action, "repository_id", repoID, "error", err)
+		respondError(w, http.StatusInternalServerError, "Failed to "+action)
+	default:
+		respondError(w, http.StatusBadRequest, err.Error())
+	}
 }
 
-func (h *FinancialHandler) GetReports(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]interface{}{"reports": []interface{}{}})
+func (h *RepositoryHandler) ListBranches(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, map[string]interface{}{"branches": []interface{}{}})
 }
 
-func (h *FinancialHandler) ListBudgets(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]interface{}{"budgets": []interface{}{}})
+func (h *RepositoryHandler) ListCommits(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, map[string]interface{}{"commits": []interface{}{}})
 }
 
-func (h *FinancialHandler) CreateBudget(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusCreated, map[string]string{"message": "budget created"})
+func (h *RepositoryHandler) ListPRs(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, map[string]interface{}{"pull_requests": []interface{}{}})
 }
 
 // SocialHandler handles social media endpoints
@@ -390,7 +812,56 @@ func (h *SocialHandler) UpdatePost(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *SocialHandler) SchedulePost(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]string{"message": "post scheduled"})
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	var req services.SchedulePostRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	post, err := h.svc.SchedulePost(r.Context(), tenantID, &req)
+	if err != nil {
+		switch {
+		case err.Error() == "account not found":
+			respondError(w, http.StatusNotFound, err.Error())
+		case strings.HasPrefix(err.Error(), "failed to"):
+			h.log.Errorw("failed to schedule post", "error", err)
+			respondError(w, http.StatusInternalServerError, "Failed to schedule post")
+		default:
+			respondError(w, http.StatusBadRequest, err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, post)
+}
+
+// CreatePipeline saves a content pipeline that generates posts on a schedule
+func (h *SocialHandler) CreatePipeline(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	var req services.CreatePipelineRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	pipeline, err := h.svc.CreatePipeline(r.Context(), tenantID, &req)
+	if err != nil {
+		respondServiceError(w, h.log, "create pipeline", err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, pipeline)
 }
 
 func (h *SocialHandler) PublishPost(w http.ResponseWriter, r *http.Request) {
@@ -411,33 +882,257 @@ func NewIoTHandler(svc *services.IoTService, log *logger.Logger) *IoTHandler {
 	return &IoTHandler{svc: svc, log: log}
 }
 
+// ListDevices returns the tenant's devices, filtered by ?type=, ?status= and
+// ?business_id=
 func (h *IoTHandler) ListDevices(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]interface{}{"devices": []interface{}{}})
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	filters := make(map[string]interface{})
+	if v := r.URL.Query().Get("type"); v != "" {
+		filters["type"] = iot.DeviceType(v)
+	}
+	if v := r.URL.Query().Get("status"); v != "" {
+		filters["status"] = iot.DeviceStatus(v)
+	}
+	if v := r.URL.Query().Get("business_id"); v != "" {
+		businessID, err := uuid.Parse(v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid business ID")
+			return
+		}
+		filters["business_id"] = businessID
+	}
+
+	devices, err := h.svc.ListDevices(r.Context(), tenantID, filters)
+	if err != nil {
+		h.log.Errorw("failed to list devices", "error", err)
+		respondError(w, http.StatusInternalServerError, "Failed to list devices")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"devices": devices})
 }
 
 func (h *IoTHandler) RegisterDevice(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusCreated, map[string]string{"message": "device registered"})
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	var req services.RegisterDeviceRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	device, err := h.svc.RegisterDevice(r.Context(), tenantID, &req)
+	if err != nil {
+		h.respondDeviceError(w, "register device", uuid.Nil, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, device)
 }
 
 func (h *IoTHandler) GetDevice(w http.ResponseWriter, r *http.Request) {
-	deviceID := chi.URLParam(r, "deviceID")
-	respondJSON(w, http.StatusOK, map[string]string{"id": deviceID})
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	deviceID, err := uuid.Parse(chi.URLParam(r, "deviceID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid device ID")
+		return
+	}
+
+	device, err := h.svc.GetDevice(r.Context(), tenantID, deviceID)
+	if err != nil {
+		h.respondDeviceError(w, "get device", deviceID, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, device)
 }
 
 func (h *IoTHandler) UpdateDevice(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]string{"message": "device updated"})
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	deviceID, err := uuid.Parse(chi.URLParam(r, "deviceID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid device ID")
+		return
+	}
+
+	var req services.UpdateDeviceRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	device, err := h.svc.UpdateDevice(r.Context(), tenantID, deviceID, &req)
+	if err != nil {
+		h.respondDeviceError(w, "update device", deviceID, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, device)
 }
 
 func (h *IoTHandler) DeleteDevice(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	deviceID, err := uuid.Parse(chi.URLParam(r, "deviceID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid device ID")
+		return
+	}
+
+	if err := h.svc.DeleteDevice(r.Context(), tenantID, deviceID); err != nil {
+		h.respondDeviceError(w, "delete device", deviceID, err)
+		return
+	}
+
 	respondJSON(w, http.StatusOK, map[string]string{"message": "device deleted"})
 }
 
+// respondDeviceError maps an IoT service error to a response
+func (h *IoTHandler) respondDeviceError(w http.ResponseWriter, action string, deviceID uuid.UUID, err error) {
+	switch {
+	case err.Error() == "device not found", err.Error() == "command not found":
+		respondError(w, http.StatusNotFound, err.Error())
+	case strings.HasPrefix(err.Error(), "failed to"):
+		h.log.Errorw("failed to "+action, "device_id", deviceID, "error", err)
+		respondError(w, http.StatusInternalServerError, "Failed to "+action)
+	default:
+		respondError(w, http.StatusBadRequest, err.Error())
+	}
+}
+
+// SendCommand queues a command for a device. The response carries the
+// command ID to poll with GetCommand.
 func (h *IoTHandler) SendCommand(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]string{"message": "command sent"})
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	deviceID, err := uuid.Parse(chi.URLParam(r, "deviceID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid device ID")
+		return
+	}
+
+	var req services.SendCommandRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	cmd, err := h.svc.SendCommand(r.Context(), tenantID, deviceID, &req)
+	if err != nil {
+		h.respondDeviceError(w, "send command", deviceID, err)
+		return
+	}
+
+	respondJSON(w, http.StatusAccepted, cmd)
 }
 
+// GetCommand returns a command's status, for
+// GET /api/v1/iot/devices/{deviceID}/commands/{commandID}
+func (h *IoTHandler) GetCommand(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	deviceID, err := uuid.Parse(chi.URLParam(r, "deviceID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid device ID")
+		return
+	}
+	commandID, err := uuid.Parse(chi.URLParam(r, "commandID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid command ID")
+		return
+	}
+
+	cmd, err := h.svc.GetCommand(r.Context(), tenantID, deviceID, commandID)
+	if err != nil {
+		h.respondDeviceError(w, "get command", deviceID, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, cmd)
+}
+
+// GetTelemetry returns a device's data points between ?from= and ?to=
+// (RFC 3339, default the last 24 hours), newest first, up to ?limit=
 func (h *IoTHandler) GetTelemetry(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]interface{}{"telemetry": []interface{}{}})
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	deviceID, err := uuid.Parse(chi.URLParam(r, "deviceID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid device ID")
+		return
+	}
+
+	to := time.Now().UTC()
+	from := to.Add(-24 * time.Hour)
+	if v := r.URL.Query().Get("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			respondError(w, http.StatusBadRequest, "from must be an RFC 3339 timestamp")
+			return
+		}
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			respondError(w, http.StatusBadRequest, "to must be an RFC 3339 timestamp")
+			return
+		}
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	points, err := h.svc.GetTelemetry(r.Context(), tenantID, deviceID, from, to, limit)
+	if err != nil {
+		switch {
+		case err.Error() == "device not found":
+			respondError(w, http.StatusNotFound, err.Error())
+		case strings.HasPrefix(err.Error(), "failed to"):
+			h.log.Errorw("failed to get telemetry", "device_id", deviceID, "error", err)
+			respondError(w, http.StatusInternalServerError, "Failed to get telemetry")
+		default:
+			respondError(w, http.StatusBadRequest, err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"device_id": deviceID,
+		"from":      from,
+		"to":        to,
+		"telemetry": points,
+	})
 }
 
 // CostHandler handles cost tracking endpoints
@@ -458,20 +1153,204 @@ func (h *CostHandler) GetSummary(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// ByAgent returns the tenant's costs and token usage per agent over ?since= and
+// ?until=, most expensive first
 func (h *CostHandler) ByAgent(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]interface{}{"costs_by_agent": []interface{}{}})
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	since, until, err := costRange(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	costs, err := h.svc.ByAgent(r.Context(), tenantID, since, until)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"costs_by_agent": costs,
+		"since":          since,
+		"until":          until,
+	})
 }
 
+// ByProvider returns the tenant's costs and token usage per AI provider over
+// ?since= and ?until=, most expensive first
 func (h *CostHandler) ByProvider(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]interface{}{"costs_by_provider": []interface{}{}})
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	since, until, err := costRange(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	costs, err := h.svc.ByProvider(r.Context(), tenantID, since, until)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"costs_by_provider": costs,
+		"since":             since,
+		"until":             until,
+	})
+}
+
+// costRange reads the RFC 3339 ?since= and ?until= parameters. since defaults
+// to the start of the current month in UTC and until to now.
+func costRange(r *http.Request) (time.Time, time.Time, error) {
+	now := time.Now().UTC()
+	since := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	until := now
+
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return since, until, errors.New("since must be an RFC 3339 timestamp")
+		}
+		since = t
+	}
+	if v := r.URL.Query().Get("until"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return since, until, errors.New("until must be an RFC 3339 timestamp")
+		}
+		until = t
+	}
+	return since, until, nil
+}
+
+// Forecast projects the tenant's spend to the end of the month from its recent
+// burn rate, against its monthly limit
+func (h *CostHandler) Forecast(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	forecast, err := h.svc.Forecast(r.Context(), tenantID)
+	if err != nil {
+		h.log.Errorw("failed to forecast costs", "tenant_id", tenantID, "error", err)
+		respondError(w, http.StatusInternalServerError, "failed to forecast costs")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, forecast)
+}
+
+// AnomalySettingsRoutes returns the cost anomaly settings routes, mounted
+// under /settings/cost-anomalies
+func (h *CostHandler) AnomalySettingsRoutes(rbac *security.RBAC) chi.Router {
+	read := middleware.RequirePermission(rbac, security.PermSettingsRead)
+	update := middleware.RequirePermission(rbac, security.PermSettingsUpdate)
+
+	r := chi.NewRouter()
+	r.With(read).Get("/", h.GetAnomalySettings)
+	r.With(update).Put("/", h.UpdateAnomalySettings)
+	return r
+}
+
+// respondAnomalySettingsError maps cost anomaly settings errors to responses
+func (h *CostHandler) respondAnomalySettingsError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case err.Error() == "tenant not found":
+		respondError(w, http.StatusNotFound, err.Error())
+	case strings.HasPrefix(err.Error(), "failed to"):
+		h.log.Errorw("cost anomaly settings request failed", "action", action, "error", err)
+		respondError(w, http.StatusInternalServerError, err.Error())
+	default:
+		respondError(w, http.StatusBadRequest, err.Error())
+	}
+}
+
+// GetAnomalySettings returns how the tenant's runs are checked for cost anomalies
+func (h *CostHandler) GetAnomalySettings(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	settings, err := h.svc.GetAnomalySettings(r.Context(), tenantID)
+	if err != nil {
+		h.respondAnomalySettingsError(w, "get", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, settings)
+}
+
+// UpdateAnomalySettings changes how the tenant's runs are checked for cost anomalies
+func (h *CostHandler) UpdateAnomalySettings(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	var req services.UpdateCostAnomalySettingsRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	settings, err := h.svc.UpdateAnomalySettings(r.Context(), tenantID, &req)
+	if err != nil {
+		h.respondAnomalySettingsError(w, "update", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, settings)
 }
 
 func (h *CostHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, map[string]interface{}{"history": []interface{}{}})
 }
 
+// GetLimits returns the tenant's cost limits, or an agent's with ?agent_id=,
+// with the spend and remaining budget in each limit's current period
 func (h *CostHandler) GetLimits(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]interface{}{"limits": []interface{}{}})
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	var agentID *uuid.UUID
+	if v := r.URL.Query().Get("agent_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid agent ID")
+			return
+		}
+		agentID = &id
+	}
+
+	limits, err := h.svc.GetRemaining(r.Context(), tenantID, agentID)
+	if err != nil {
+		if err.Error() == "agent not found" {
+			respondError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"limits": limits})
 }
 
 func (h *CostHandler) UpdateLimits(w http.ResponseWriter, r *http.Request) {
@@ -489,24 +1368,111 @@ func NewDashboardHandler(svc *services.DashboardService, log *logger.Logger) *Da
 }
 
 func (h *DashboardHandler) Overview(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	overview, err := h.svc.Overview(r.Context(), tenantID)
+	if err != nil {
+		h.log.Errorw("failed to load dashboard overview", "tenant_id", tenantID, "error", err)
+		respondError(w, http.StatusInternalServerError, "failed to load dashboard overview")
+		return
+	}
+
 	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"active_agents":   0,
-		"total_agents":    0,
-		"executions_today": 0,
-		"cost_today":      0.0,
+		"active_agents":    overview.ActiveAgents,
+		"total_agents":     overview.TotalAgents,
+		"executions_today": overview.ExecutionsToday,
+		"cost_today":       overview.CostToday,
+		"concurrency":      h.svc.Concurrency(r.Context(), tenantID),
 	})
 }
 
+// AgentsStatus returns each of the tenant's agents with its status and most
+// recent run
 func (h *DashboardHandler) AgentsStatus(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]interface{}{"agents": []interface{}{}})
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	agents, err := h.svc.AgentsStatus(r.Context(), tenantID)
+	if err != nil {
+		h.log.Errorw("failed to load agents status", "tenant_id", tenantID, "error", err)
+		respondError(w, http.StatusInternalServerError, "failed to load agents status")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"agents": agents})
 }
 
 func (h *DashboardHandler) RecentActivity(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]interface{}{"activity": []interface{}{}})
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	// The service applies the default and maximum page size
+	limit, offset := 0, 0
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil {
+		limit = l
+	}
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil {
+		offset = o
+	}
+
+	activity, err := h.svc.RecentActivity(r.Context(), tenantID, limit, offset)
+	if err != nil {
+		h.log.Errorw("failed to load recent activity", "tenant_id", tenantID, "error", err)
+		respondError(w, http.StatusInternalServerError, "failed to load recent activity")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"activity": activity})
+}
+
+// CostAnomalies returns the tenant's runs flagged as costing far more than
+// their agent's recent runs, newest first, up to ?limit=
+func (h *DashboardHandler) CostAnomalies(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	// The service applies the default and maximum page size
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	anomalies, err := h.svc.CostAnomalies(r.Context(), tenantID, limit)
+	if err != nil {
+		h.log.Errorw("failed to load cost anomalies", "tenant_id", tenantID, "error", err)
+		respondError(w, http.StatusInternalServerError, "failed to load cost anomalies")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"anomalies": anomalies})
 }
 
+// CostTrends returns the tenant's daily cost over the last 30 days
 func (h *DashboardHandler) CostTrends(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]interface{}{"trends": []interface{}{}})
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	trends, err := h.svc.CostTrends(r.Context(), tenantID)
+	if err != nil {
+		h.log.Errorw("failed to load cost trends", "tenant_id", tenantID, "error", err)
+		respondError(w, http.StatusInternalServerError, "failed to load cost trends")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"trends": trends})
 }
 
 func (h *DashboardHandler) GetWidget(w http.ResponseWriter, r *http.Request) {
@@ -524,14 +1490,193 @@ func NewAuditHandler(svc *services.AuditService, log *logger.Logger) *AuditHandl
 	return &AuditHandler{svc: svc, log: log}
 }
 
+// List returns a page of the tenant's audit logs, newest first. See
+// parseAuditQuery for the filters; ?limit= and ?offset= page through results.
 func (h *AuditHandler) List(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]interface{}{"logs": []interface{}{}})
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	query, err := parseAuditQuery(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+	query.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+
+	entries, err := h.svc.Query(r.Context(), tenantID, query)
+	if err != nil {
+		h.log.Errorw("failed to list audit logs", "tenant_id", tenantID, "error", err)
+		respondError(w, http.StatusInternalServerError, "Failed to list audit logs")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"logs":   entries,
+		"count":  len(entries),
+		"offset": query.Offset,
+	})
+}
+
+// auditCSVHeader lists the columns of an audit log export
+var auditCSVHeader = []string{
+	"id", "timestamp", "action", "severity", "resource_type", "resource_id",
+	"user_id", "agent_id", "ip_address", "user_agent", "session_id", "details",
 }
 
+// Export streams the tenant's audit logs matching the List filters, as CSV or,
+// with ?format=json, as newline-delimited JSON
 func (h *AuditHandler) Export(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/csv")
-	w.Header().Set("Content-Disposition", "attachment; filename=audit_logs.csv")
-	w.Write([]byte("timestamp,action,resource_type,resource_id,user_id\n"))
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	query, err := parseAuditQuery(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var write func(*security.AuditEntry) error
+	var flush func() error
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", "attachment; filename=audit_logs.csv")
+
+		cw := csv.NewWriter(w)
+		cw.Write(auditCSVHeader)
+		write = func(entry *security.AuditEntry) error {
+			row, err := auditCSVRow(entry)
+			if err != nil {
+				return err
+			}
+			cw.Write(row)
+			return cw.Error()
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	case "json":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", "attachment; filename=audit_logs.ndjson")
+
+		enc := json.NewEncoder(w)
+		write = func(entry *security.AuditEntry) error {
+			return enc.Encode(entry)
+		}
+		flush = func() error { return nil }
+	default:
+		respondError(w, http.StatusBadRequest, "format must be csv or json")
+		return
+	}
+
+	err = h.svc.Export(r.Context(), tenantID, query, write)
+	if flushErr := flush(); err == nil {
+		err = flushErr
+	}
+	if err != nil {
+		// Headers are already sent, so the export can only be cut short
+		h.log.Errorw("failed to export audit logs", "tenant_id", tenantID, "error", err)
+	}
+}
+
+// auditCSVRow formats an audit entry as a CSV row, with its details encoded as JSON
+func auditCSVRow(entry *security.AuditEntry) ([]string, error) {
+	details := ""
+	if len(entry.Details) > 0 {
+		encoded, err := json.Marshal(entry.Details)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode details of audit entry %s: %w", entry.ID, err)
+		}
+		details = string(encoded)
+	}
+
+	return []string{
+		entry.ID.String(),
+		entry.Timestamp.UTC().Format(time.RFC3339),
+		string(entry.Action),
+		string(entry.Severity),
+		entry.ResourceType,
+		optionalUUID(entry.ResourceID),
+		optionalUUID(entry.UserID),
+		optionalUUID(entry.AgentID),
+		entry.IPAddress,
+		entry.UserAgent,
+		entry.SessionID,
+		details,
+	}, nil
+}
+
+// parseAuditQuery reads audit log filters: ?action= (repeatable or comma
+// separated), ?severity=, ?resource_type=, ?resource_id=, ?user_id=, and
+// ?from= and ?to= as RFC 3339 timestamps
+func parseAuditQuery(r *http.Request) (security.AuditQuery, error) {
+	var query security.AuditQuery
+	params := r.URL.Query()
+
+	for _, value := range params["action"] {
+		for _, action := range strings.Split(value, ",") {
+			if action = strings.TrimSpace(action); action != "" {
+				query.Actions = append(query.Actions, security.AuditAction(action))
+			}
+		}
+	}
+
+	switch severity := security.AuditSeverity(params.Get("severity")); severity {
+	case "":
+	case security.SeverityInfo, security.SeverityWarning, security.SeverityCritical:
+		query.Severity = &severity
+	default:
+		return query, fmt.Errorf("severity must be info, warning or critical")
+	}
+
+	query.ResourceType = params.Get("resource_type")
+	if v := params.Get("resource_id"); v != "" {
+		resourceID, err := uuid.Parse(v)
+		if err != nil {
+			return query, fmt.Errorf("invalid resource ID")
+		}
+		query.ResourceID = &resourceID
+	}
+	if v := params.Get("user_id"); v != "" {
+		userID, err := uuid.Parse(v)
+		if err != nil {
+			return query, fmt.Errorf("invalid user ID")
+		}
+		query.UserID = &userID
+	}
+
+	if v := params.Get("from"); v != "" {
+		from, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return query, fmt.Errorf("from must be an RFC 3339 timestamp")
+		}
+		query.StartTime = &from
+	}
+	if v := params.Get("to"); v != "" {
+		to, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return query, fmt.Errorf("to must be an RFC 3339 timestamp")
+		}
+		query.EndTime = &to
+	}
+
+	return query, nil
+}
+
+// optionalUUID formats an optional ID, empty when unset
+func optionalUUID(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
 }
 
 // SettingsHandler handles settings endpoints
@@ -544,6 +1689,19 @@ func NewSettingsHandler(svc *services.SettingsService, log *logger.Logger) *Sett
 	return &SettingsHandler{svc: svc, log: log}
 }
 
+// Routes returns the settings endpoints, each guarded by the permission it needs
+func (h *SettingsHandler) Routes(rbac *security.RBAC) chi.Router {
+	read := middleware.RequirePermission(rbac, security.PermSettingsRead)
+	update := middleware.RequirePermission(rbac, security.PermSettingsUpdate)
+
+	r := chi.NewRouter()
+	r.With(read).Get("/", h.Get)
+	r.With(update).Patch("/", h.Update)
+	r.With(read).Get("/integrations", h.ListIntegrations)
+	r.With(update).Put("/integrations/{integration}", h.ConfigureIntegration)
+	return r
+}
+
 func (h *SettingsHandler) Get(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, map[string]interface{}{"settings": map[string]interface{}{}})
 }
@@ -560,41 +1718,3 @@ func (h *SettingsHandler) ConfigureIntegration(w http.ResponseWriter, r *http.Re
 	integration := chi.URLParam(r, "integration")
 	respondJSON(w, http.StatusOK, map[string]string{"message": integration + " configured"})
 }
-
-// WebhookHandler handles webhook endpoints
-type WebhookHandler struct {
-	svc *services.WebhookService
-	log *logger.Logger
-}
-
-func NewWebhookHandler(svc *services.WebhookService, log *logger.Logger) *WebhookHandler {
-	return &WebhookHandler{svc: svc, log: log}
-}
-
-func (h *WebhookHandler) GitHub(w http.ResponseWriter, r *http.Request) {
-	// Handle GitHub webhooks
-	h.log.Infow("received GitHub webhook")
-	respondJSON(w, http.StatusOK, map[string]string{"message": "webhook received"})
-}
-
-func (h *WebhookHandler) Stripe(w http.ResponseWriter, r *http.Request) {
-	// Handle Stripe webhooks
-	h.log.Infow("received Stripe webhook")
-	respondJSON(w, http.StatusOK, map[string]string{"message": "webhook received"})
-}
-
-// WebSocketHandler handles WebSocket connections
-type WebSocketHandler struct {
-	svc *services.WebSocketService
-	log *logger.Logger
-}
-
-func NewWebSocketHandler(svc *services.WebSocketService, log *logger.Logger) *WebSocketHandler {
-	return &WebSocketHandler{svc: svc, log: log}
-}
-
-func (h *WebSocketHandler) Handle(w http.ResponseWriter, r *http.Request) {
-	// In production, upgrade to WebSocket connection
-	respondError(w, http.StatusNotImplemented, "WebSocket not yet implemented")
-}
-
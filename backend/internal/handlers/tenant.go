@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/delphi-platform/delphi/backend/internal/apierror"
+	"github.com/delphi-platform/delphi/backend/internal/middleware"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// TenantHandler handles tenant endpoints
+type TenantHandler struct {
+	svc *services.TenantService
+	log *logger.Logger
+}
+
+// NewTenantHandler creates a new tenant handler
+func NewTenantHandler(svc *services.TenantService, log *logger.Logger) *TenantHandler {
+	return &TenantHandler{svc: svc, log: log}
+}
+
+// List returns the tenants the caller belongs to
+func (h *TenantHandler) List(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	tenants, err := h.svc.List(r.Context(), tenantID)
+	if err != nil {
+		h.log.Errorw("failed to list tenants", "tenant_id", tenantID, "error", err)
+		respondError(w, http.StatusInternalServerError, "failed to list tenants")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"tenants": tenants,
+		"count":   len(tenants),
+	})
+}
+
+// Create creates a tenant and its owner account. A taken slug is rejected
+// with 409 and a suggested alternative.
+func (h *TenantHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req services.CreateTenantRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	tenant, owner, err := h.svc.Create(r.Context(), &req)
+	if err != nil {
+		h.respondTenantError(w, "create tenant", err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"tenant": tenant,
+		"owner":  owner,
+	})
+}
+
+// Get returns one of the caller's tenants
+func (h *TenantHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userTenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	tenantID, err := uuid.Parse(chi.URLParam(r, "tenantID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid tenant ID")
+		return
+	}
+
+	tenant, err := h.svc.Get(r.Context(), userTenantID, tenantID)
+	if err != nil {
+		h.respondTenantError(w, "get tenant", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, tenant)
+}
+
+// Update renames a tenant or changes its slug (owners and admins only)
+func (h *TenantHandler) Update(w http.ResponseWriter, r *http.Request) {
+	userTenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	if !isTenantAdmin(r) {
+		respondError(w, http.StatusForbidden, "insufficient permissions")
+		return
+	}
+
+	tenantID, err := uuid.Parse(chi.URLParam(r, "tenantID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid tenant ID")
+		return
+	}
+
+	var req services.UpdateTenantRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	tenant, err := h.svc.Update(r.Context(), userTenantID, tenantID, &req)
+	if err != nil {
+		h.respondTenantError(w, "update tenant", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, tenant)
+}
+
+// RequestDeletion issues the token that confirms the tenant's deletion
+// (owners only)
+func (h *TenantHandler) RequestDeletion(w http.ResponseWriter, r *http.Request) {
+	userTenantID, userID, tenantID, ok := h.ownerRequest(w, r)
+	if !ok {
+		return
+	}
+
+	confirmation, err := h.svc.RequestDeletion(r.Context(), userTenantID, userID, tenantID)
+	if err != nil {
+		h.respondTenantError(w, "request tenant deletion", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, confirmation)
+}
+
+// Delete schedules the tenant's deletion with a confirmation token from
+// RequestDeletion (owners only). The tenant is deleted for good once the
+// grace period is over.
+func (h *TenantHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userTenantID, userID, tenantID, ok := h.ownerRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		ConfirmationToken string `json:"confirmation_token"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	tenant, err := h.svc.ConfirmDeletion(r.Context(), userTenantID, userID, tenantID, req.ConfirmationToken)
+	if err != nil {
+		h.respondTenantError(w, "delete tenant", err)
+		return
+	}
+
+	respondJSON(w, http.StatusAccepted, tenant)
+}
+
+// Restore cancels the tenant's scheduled deletion during its grace period
+// (owners only)
+func (h *TenantHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	userTenantID, userID, tenantID, ok := h.ownerRequest(w, r)
+	if !ok {
+		return
+	}
+
+	tenant, err := h.svc.CancelDeletion(r.Context(), userTenantID, userID, tenantID)
+	if err != nil {
+		h.respondTenantError(w, "restore tenant", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, tenant)
+}
+
+// ownerRequest reads the caller's tenant and user and the tenant in the URL,
+// responding with an error unless the caller is an owner
+func (h *TenantHandler) ownerRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, uuid.UUID, bool) {
+	userTenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return uuid.Nil, uuid.Nil, uuid.Nil, false
+	}
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "user context required")
+		return uuid.Nil, uuid.Nil, uuid.Nil, false
+	}
+
+	if role, _ := middleware.GetUserRole(r.Context()); role != string(models.RoleOwner) {
+		respondError(w, http.StatusForbidden, "only the tenant owner can do this")
+		return uuid.Nil, uuid.Nil, uuid.Nil, false
+	}
+
+	tenantID, err := uuid.Parse(chi.URLParam(r, "tenantID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid tenant ID")
+		return uuid.Nil, uuid.Nil, uuid.Nil, false
+	}
+	return userTenantID, userID, tenantID, true
+}
+
+// respondTenantError maps tenant service errors to HTTP responses
+func (h *TenantHandler) respondTenantError(w http.ResponseWriter, action string, err error) {
+	var taken *services.SlugTakenError
+	switch {
+	case errors.As(err, &taken):
+		var details map[string]string
+		if taken.Suggestion != "" {
+			details = map[string]string{"suggested_slug": taken.Suggestion}
+		}
+		apierror.Write(w, http.StatusConflict, apierror.WithCode(apierror.CodeSlugTaken, taken.Error(), details))
+	case err.Error() == "email already registered":
+		respondError(w, http.StatusConflict, err.Error())
+	case err.Error() == "tenant not found":
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrInvalidDeletionToken):
+		respondError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, services.ErrDeletionScheduled), err.Error() == "tenant is not scheduled for deletion":
+		respondError(w, http.StatusConflict, err.Error())
+	case strings.HasPrefix(err.Error(), "failed to"):
+		h.log.Errorw("failed to "+action, "error", err)
+		respondError(w, http.StatusInternalServerError, "failed to "+action)
+	default:
+		respondError(w, http.StatusBadRequest, err.Error())
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+)
+
+const (
+	// maxGitHubPayloadBytes matches the largest payload GitHub will deliver
+	maxGitHubPayloadBytes = 25 << 20
+
+	// maxStripePayloadBytes is well above the size of any Stripe event
+	maxStripePayloadBytes = 64 << 10
+)
+
+// WebhookHandler handles webhook endpoints
+type WebhookHandler struct {
+	svc *services.WebhookService
+	log *logger.Logger
+}
+
+// NewWebhookHandler creates a new webhook handler
+func NewWebhookHandler(svc *services.WebhookService, log *logger.Logger) *WebhookHandler {
+	return &WebhookHandler{svc: svc, log: log}
+}
+
+// GitHub handles GitHub webhook deliveries. The signature is checked against
+// the raw body before anything in it is trusted.
+func (h *WebhookHandler) GitHub(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxGitHubPayloadBytes))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "failed to read body")
+		return
+	}
+
+	event := r.Header.Get("X-GitHub-Event")
+	delivery := r.Header.Get("X-GitHub-Delivery")
+
+	err = h.svc.HandleGitHub(event, r.Header.Get("X-Hub-Signature-256"), payload)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidWebhookSignature) {
+			h.log.Warnw("rejected GitHub webhook", "event", event, "delivery", delivery, "error", err)
+			respondError(w, http.StatusUnauthorized, "invalid signature")
+			return
+		}
+		h.log.Errorw("failed to handle GitHub webhook", "event", event, "delivery", delivery, "error", err)
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "webhook received"})
+}
+
+// Stripe handles Stripe webhook deliveries. Stripe retries any delivery that
+// does not get a 2xx, so failures to apply an event return 500.
+func (h *WebhookHandler) Stripe(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxStripePayloadBytes))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "failed to read body")
+		return
+	}
+
+	err = h.svc.HandleStripe(r.Context(), r.Header.Get("Stripe-Signature"), payload)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidWebhookSignature) {
+			h.log.Warnw("rejected Stripe webhook", "error", err)
+			respondError(w, http.StatusUnauthorized, "invalid signature")
+			return
+		}
+		h.log.Errorw("failed to handle Stripe webhook", "error", err)
+		respondError(w, http.StatusInternalServerError, "failed to handle webhook")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "webhook received"})
+}
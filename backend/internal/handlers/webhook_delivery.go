@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/delphi-platform/delphi/backend/internal/middleware"
+	"github.com/delphi-platform/delphi/backend/internal/security"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// WebhookDeliveryHandler handles the tenant's outbound webhook endpoints
+// under /settings/webhooks
+type WebhookDeliveryHandler struct {
+	svc *services.WebhookDeliveryService
+	log *logger.Logger
+}
+
+// NewWebhookDeliveryHandler creates a new outbound webhook handler
+func NewWebhookDeliveryHandler(svc *services.WebhookDeliveryService, log *logger.Logger) *WebhookDeliveryHandler {
+	return &WebhookDeliveryHandler{svc: svc, log: log}
+}
+
+// Routes returns the webhook routes. Anyone who can read settings can see
+// webhooks and their deliveries; changing them requires updating settings.
+func (h *WebhookDeliveryHandler) Routes(rbac *security.RBAC) chi.Router {
+	read := middleware.RequirePermission(rbac, security.PermSettingsRead)
+	update := middleware.RequirePermission(rbac, security.PermSettingsUpdate)
+
+	r := chi.NewRouter()
+	r.With(read).Get("/", h.List)
+	r.With(update).Post("/", h.Create)
+	r.With(read).Get("/{webhookID}", h.Get)
+	r.With(update).Patch("/{webhookID}", h.Update)
+	r.With(update).Delete("/{webhookID}", h.Delete)
+	r.With(read).Get("/{webhookID}/deliveries", h.ListDeliveries)
+	return r
+}
+
+// webhookParams reads the tenant and webhook ID of a request, writing an
+// error response if either is missing or malformed. withWebhook requires the
+// webhookID URL parameter.
+func webhookParams(w http.ResponseWriter, r *http.Request, withWebhook bool) (tenantID, webhookID uuid.UUID, ok bool) {
+	tenantID, ok = middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	if withWebhook {
+		var err error
+		webhookID, err = uuid.Parse(chi.URLParam(r, "webhookID"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid webhook ID")
+			return tenantID, webhookID, false
+		}
+	}
+	return tenantID, webhookID, true
+}
+
+// respondWebhookError maps webhook service errors to responses
+func (h *WebhookDeliveryHandler) respondWebhookError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case err.Error() == "webhook not found":
+		respondError(w, http.StatusNotFound, err.Error())
+	case strings.HasPrefix(err.Error(), "failed to"):
+		h.log.Errorw("webhook request failed", "action", action, "error", err)
+		respondError(w, http.StatusInternalServerError, err.Error())
+	default:
+		respondError(w, http.StatusBadRequest, err.Error())
+	}
+}
+
+// List returns the tenant's webhooks
+func (h *WebhookDeliveryHandler) List(w http.ResponseWriter, r *http.Request) {
+	tenantID, _, ok := webhookParams(w, r, false)
+	if !ok {
+		return
+	}
+
+	webhooks, err := h.svc.List(r.Context(), tenantID)
+	if err != nil {
+		h.respondWebhookError(w, "list", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"webhooks": webhooks,
+		"count":    len(webhooks),
+	})
+}
+
+// Create adds a webhook. The response carries the signing secret, which is
+// not shown again.
+func (h *WebhookDeliveryHandler) Create(w http.ResponseWriter, r *http.Request) {
+	tenantID, _, ok := webhookParams(w, r, false)
+	if !ok {
+		return
+	}
+
+	var req services.CreateWebhookRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	webhook, err := h.svc.Create(r.Context(), tenantID, &req)
+	if err != nil {
+		h.respondWebhookError(w, "create", err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, webhook)
+}
+
+// Get returns one of the tenant's webhooks
+func (h *WebhookDeliveryHandler) Get(w http.ResponseWriter, r *http.Request) {
+	tenantID, webhookID, ok := webhookParams(w, r, true)
+	if !ok {
+		return
+	}
+
+	webhook, err := h.svc.Get(r.Context(), tenantID, webhookID)
+	if err != nil {
+		h.respondWebhookError(w, "get", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, webhook)
+}
+
+// Update changes a webhook
+func (h *WebhookDeliveryHandler) Update(w http.ResponseWriter, r *http.Request) {
+	tenantID, webhookID, ok := webhookParams(w, r, true)
+	if !ok {
+		return
+	}
+
+	var req services.UpdateWebhookRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	webhook, err := h.svc.Update(r.Context(), tenantID, webhookID, &req)
+	if err != nil {
+		h.respondWebhookError(w, "update", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, webhook)
+}
+
+// Delete removes a webhook
+func (h *WebhookDeliveryHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	tenantID, webhookID, ok := webhookParams(w, r, true)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Delete(r.Context(), tenantID, webhookID); err != nil {
+		h.respondWebhookError(w, "delete", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "webhook deleted"})
+}
+
+// ListDeliveries returns a webhook's delivery log, newest first
+func (h *WebhookDeliveryHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	tenantID, webhookID, ok := webhookParams(w, r, true)
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	deliveries, err := h.svc.ListDeliveries(r.Context(), tenantID, webhookID, limit)
+	if err != nil {
+		h.respondWebhookError(w, "list deliveries", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"deliveries": deliveries,
+		"count":      len(deliveries),
+	})
+}
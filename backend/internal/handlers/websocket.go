@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/middleware"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// wsWriteWait is the time allowed to write a frame to the client
+	wsWriteWait = 10 * time.Second
+
+	// wsPongWait is the time allowed to hear back from the client before the connection is dropped
+	wsPongWait = 60 * time.Second
+
+	// wsPingPeriod must be shorter than wsPongWait
+	wsPingPeriod = 50 * time.Second
+
+	// wsMaxMessageSize caps frames from the client, which only ever sends control frames
+	wsMaxMessageSize = 512
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+	// Connections authenticate with an explicit token rather than cookies, so a
+	// cross-origin page cannot ride on a user's session
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// WebSocketHandler handles WebSocket connections
+type WebSocketHandler struct {
+	svc *services.WebSocketService
+	log *logger.Logger
+}
+
+// NewWebSocketHandler creates a new WebSocket handler
+func NewWebSocketHandler(svc *services.WebSocketService, log *logger.Logger) *WebSocketHandler {
+	return &WebSocketHandler{svc: svc, log: log}
+}
+
+// runLogFrame is one log entry as sent to the client
+type runLogFrame struct {
+	ID        uuid.UUID       `json:"id"`
+	RunID     uuid.UUID       `json:"run_id"`
+	Level     models.LogLevel `json:"level"`
+	Message   string          `json:"message"`
+	Metadata  interface{}     `json:"metadata,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+func newRunLogFrame(entry *models.AgentLog) runLogFrame {
+	frame := runLogFrame{
+		ID:        entry.ID,
+		RunID:     entry.RunID,
+		Level:     entry.Level,
+		Message:   entry.Message,
+		Timestamp: entry.CreatedAt,
+	}
+	if len(entry.Metadata) > 0 {
+		frame.Metadata = entry.Metadata
+	}
+	return frame
+}
+
+// Handle tails a run's logs. The run is given by the run_id query parameter;
+// the connection first receives the run's recent entries, then new ones as
+// they are logged.
+func (h *WebSocketHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.GetTenantID(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "tenant context required")
+		return
+	}
+
+	runID, err := uuid.Parse(r.URL.Query().Get("run_id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid run_id")
+		return
+	}
+
+	sub, backlog, err := h.svc.SubscribeRun(r.Context(), tenantID, runID)
+	if err != nil {
+		if err.Error() == "run not found" {
+			respondError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		h.log.Errorw("failed to subscribe to run logs", "run_id", runID, "error", err)
+		respondError(w, http.StatusInternalServerError, "failed to subscribe to run logs")
+		return
+	}
+	defer sub.Close()
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written an error response
+		h.log.Warnw("websocket upgrade failed", "run_id", runID, "error", err)
+		return
+	}
+	defer conn.Close()
+
+	// The client sends nothing but pongs and close frames; reading is what
+	// processes them and notices a dead connection
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(wsMaxMessageSize)
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(entry *models.AgentLog) error {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		return conn.WriteJSON(newRunLogFrame(entry))
+	}
+
+	// Entries logged while the backlog loaded can arrive twice
+	sent := make(map[uuid.UUID]bool, len(backlog))
+	for _, entry := range backlog {
+		if err := send(entry); err != nil {
+			return
+		}
+		sent[entry.ID] = true
+	}
+
+	h.log.Infow("run log subscriber connected", "run_id", runID, "tenant_id", tenantID)
+	defer h.log.Infow("run log subscriber disconnected", "run_id", runID, "tenant_id", tenantID)
+
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+
+		case entry, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind; the client can reconnect to catch up
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "subscriber too slow"),
+					time.Now().Add(wsWriteWait))
+				return
+			}
+			if sent[entry.ID] {
+				delete(sent, entry.ID)
+				continue
+			}
+			if err := send(entry); err != nil {
+				return
+			}
+
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package iot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// =============================================================================
+// Command Tracking
+// =============================================================================
+
+// DefaultCommandTimeout is how long a sent command may go unacknowledged
+// before it is failed
+const DefaultCommandTimeout = 5 * time.Minute
+
+const (
+	commandSweepInterval = 30 * time.Second
+	commandStoreTimeout  = 10 * time.Second
+
+	// commandRetention is how long finished commands stay in memory
+	commandRetention = time.Hour
+)
+
+// CommandResult is a device's report on a command
+type CommandResult struct {
+	// Status is acknowledged, completed or failed; empty means completed
+	Status string                 `json:"status"`
+	Output map[string]interface{} `json:"output,omitempty"`
+	Error  string                 `json:"error,omitempty"`
+}
+
+// AckReporter is implemented by adapters that receive command results from
+// devices. RegisterAdapter routes them to the command they belong to.
+type AckReporter interface {
+	OnCommandAck(handler func(deviceID, commandID uuid.UUID, result CommandResult))
+}
+
+// CommandStore persists commands and their progress
+type CommandStore interface {
+	// Save creates or updates a command. A status older than the stored one
+	// must not overwrite it.
+	Save(ctx context.Context, cmd *Command) error
+
+	// Get returns nil without an error if the command doesn't exist
+	Get(ctx context.Context, commandID uuid.UUID) (*Command, error)
+
+	// FailExpired fails commands sent before sentBefore that were never
+	// acknowledged
+	FailExpired(ctx context.Context, sentBefore time.Time, reason string) (int64, error)
+}
+
+// errCommandUnchanged signals an update that left the command as it was
+var errCommandUnchanged = errors.New("command unchanged")
+
+// commandStatusRank orders command statuses; a command only moves forward
+var commandStatusRank = map[string]int{
+	CommandStatusPending:      0,
+	CommandStatusSent:         1,
+	CommandStatusAcknowledged: 2,
+	CommandStatusCompleted:    3,
+	CommandStatusFailed:       3,
+}
+
+func isFinalCommandStatus(status string) bool {
+	return status == CommandStatusCompleted || status == CommandStatusFailed
+}
+
+// SetCommandStore sets where commands are persisted
+func (s *Service) SetCommandStore(store CommandStore) {
+	s.commandsMu.Lock()
+	defer s.commandsMu.Unlock()
+	s.commandStore = store
+}
+
+// SetCommandTimeout sets how long a sent command may go unacknowledged
+func (s *Service) SetCommandTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+	s.commandsMu.Lock()
+	defer s.commandsMu.Unlock()
+	s.commandTimeout = timeout
+}
+
+// GetCommand returns a command and its progress
+func (s *Service) GetCommand(ctx context.Context, commandID uuid.UUID) (*Command, error) {
+	cmd, err := s.loadCommand(ctx, commandID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.commandsMu.Lock()
+	defer s.commandsMu.Unlock()
+	snapshot := *cmd
+	return &snapshot, nil
+}
+
+// IngestCommandAck records a device's report on a command. Adapters call it
+// when a device acknowledges or finishes a command; completed and failed are
+// final and set CompletedAt.
+func (s *Service) IngestCommandAck(ctx context.Context, commandID uuid.UUID, result CommandResult) error {
+	return s.ackCommand(ctx, uuid.Nil, commandID, result)
+}
+
+// handleDeviceAck ingests a result reported through an adapter, which must
+// come from the device the command was sent to
+func (s *Service) handleDeviceAck(deviceID, commandID uuid.UUID, result CommandResult) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandStoreTimeout)
+	defer cancel()
+
+	if err := s.ackCommand(ctx, deviceID, commandID, result); err != nil {
+		s.log.Warnw("failed to ingest command acknowledgement",
+			"command_id", commandID,
+			"device_id", deviceID,
+			"error", err,
+		)
+	}
+}
+
+// ackCommand applies a command result. A non-nil deviceID must match the
+// command's device.
+func (s *Service) ackCommand(ctx context.Context, deviceID, commandID uuid.UUID, result CommandResult) error {
+	status := result.Status
+	if status == "" {
+		status = CommandStatusCompleted
+	}
+	if status != CommandStatusAcknowledged && !isFinalCommandStatus(status) {
+		return fmt.Errorf("invalid command status: %s", status)
+	}
+
+	cmd, err := s.updateCommand(ctx, commandID, func(cmd *Command) error {
+		if deviceID != uuid.Nil && cmd.DeviceID != deviceID {
+			return fmt.Errorf("command not found")
+		}
+		if isFinalCommandStatus(cmd.Status) {
+			return fmt.Errorf("command already %s", cmd.Status)
+		}
+		if commandStatusRank[status] <= commandStatusRank[cmd.Status] {
+			return errCommandUnchanged
+		}
+
+		now := time.Now()
+		if cmd.AcknowledgedAt == nil {
+			cmd.AcknowledgedAt = &now
+		}
+		if isFinalCommandStatus(status) {
+			cmd.CompletedAt = &now
+		}
+		if result.Output != nil {
+			cmd.Result = result.Output
+		}
+		cmd.Error = result.Error
+		cmd.Status = status
+		return nil
+	})
+	if errors.Is(err, errCommandUnchanged) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	s.log.Infow("command acknowledged",
+		"command_id", cmd.ID,
+		"device_id", cmd.DeviceID,
+		"status", cmd.Status,
+	)
+	return nil
+}
+
+// trackCommand starts tracking a new command
+func (s *Service) trackCommand(ctx context.Context, cmd *Command) error {
+	tracked := *cmd
+
+	s.commandsMu.Lock()
+	s.commands[cmd.ID] = &tracked
+	store := s.commandStore
+	s.commandsMu.Unlock()
+
+	if store == nil {
+		return nil
+	}
+	if err := store.Save(ctx, cmd); err != nil {
+		s.commandsMu.Lock()
+		delete(s.commands, cmd.ID)
+		s.commandsMu.Unlock()
+		return fmt.Errorf("failed to save command: %w", err)
+	}
+	return nil
+}
+
+// markCommandSent records that the adapter delivered a command. A device may
+// acknowledge before this runs, in which case the command is left as is.
+func (s *Service) markCommandSent(commandID uuid.UUID) {
+	s.backgroundUpdate(commandID, func(cmd *Command) error {
+		if commandStatusRank[cmd.Status] >= commandStatusRank[CommandStatusSent] {
+			return errCommandUnchanged
+		}
+		now := time.Now()
+		cmd.SentAt = &now
+		cmd.Status = CommandStatusSent
+		return nil
+	})
+}
+
+// failCommand fails a command that could not be delivered
+func (s *Service) failCommand(commandID uuid.UUID, reason string) {
+	s.backgroundUpdate(commandID, func(cmd *Command) error {
+		if isFinalCommandStatus(cmd.Status) {
+			return errCommandUnchanged
+		}
+		now := time.Now()
+		cmd.CompletedAt = &now
+		cmd.Error = reason
+		cmd.Status = CommandStatusFailed
+		return nil
+	})
+}
+
+// backgroundUpdate applies a command update outside a request, logging failures
+func (s *Service) backgroundUpdate(commandID uuid.UUID, apply func(cmd *Command) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandStoreTimeout)
+	defer cancel()
+
+	if _, err := s.updateCommand(ctx, commandID, apply); err != nil && !errors.Is(err, errCommandUnchanged) {
+		s.log.Errorw("failed to update command", "command_id", commandID, "error", err)
+	}
+}
+
+// updateCommand applies an update to a tracked command and persists the result
+func (s *Service) updateCommand(ctx context.Context, commandID uuid.UUID, apply func(cmd *Command) error) (*Command, error) {
+	cmd, err := s.loadCommand(ctx, commandID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.commandsMu.Lock()
+	if err := apply(cmd); err != nil {
+		s.commandsMu.Unlock()
+		return nil, err
+	}
+	snapshot := *cmd
+	store := s.commandStore
+	s.commandsMu.Unlock()
+
+	if store != nil {
+		if err := store.Save(ctx, &snapshot); err != nil {
+			return nil, fmt.Errorf("failed to save command: %w", err)
+		}
+	}
+	return &snapshot, nil
+}
+
+// loadCommand returns the tracked command, loading it from the store if it is
+// no longer in memory
+func (s *Service) loadCommand(ctx context.Context, commandID uuid.UUID) (*Command, error) {
+	s.commandsMu.Lock()
+	cmd, ok := s.commands[commandID]
+	store := s.commandStore
+	s.commandsMu.Unlock()
+	if ok {
+		return cmd, nil
+	}
+	if store == nil {
+		return nil, fmt.Errorf("command not found")
+	}
+
+	stored, err := store.Get(ctx, commandID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get command: %w", err)
+	}
+	if stored == nil {
+		return nil, fmt.Errorf("command not found")
+	}
+
+	s.commandsMu.Lock()
+	defer s.commandsMu.Unlock()
+	if cmd, ok := s.commands[commandID]; ok {
+		return cmd, nil
+	}
+	s.commands[commandID] = stored
+	return stored, nil
+}
+
+// expireCommands periodically fails commands stuck in sent
+func (s *Service) expireCommands() {
+	defer s.workers.Done()
+
+	ticker := time.NewTicker(commandSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.ExpireCommands(time.Now())
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// ExpireCommands fails commands sent longer than the command timeout before
+// now without an acknowledgement, and forgets finished commands past
+// retention. The service runs it every 30 seconds.
+func (s *Service) ExpireCommands(now time.Time) {
+	s.commandsMu.Lock()
+	timeout := s.commandTimeout
+	store := s.commandStore
+	deadline := now.Add(-timeout)
+	reason := fmt.Sprintf("no acknowledgement from device within %s", timeout)
+
+	var expired []Command
+	for id, cmd := range s.commands {
+		switch {
+		case cmd.Status == CommandStatusSent && cmd.SentAt != nil && cmd.SentAt.Before(deadline):
+			completedAt := now
+			cmd.CompletedAt = &completedAt
+			cmd.Error = reason
+			cmd.Status = CommandStatusFailed
+			expired = append(expired, *cmd)
+		case isFinalCommandStatus(cmd.Status) && cmd.CompletedAt != nil && now.Sub(*cmd.CompletedAt) > commandRetention:
+			delete(s.commands, id)
+		}
+	}
+	s.commandsMu.Unlock()
+
+	for _, cmd := range expired {
+		s.log.Warnw("command timed out",
+			"command_id", cmd.ID,
+			"device_id", cmd.DeviceID,
+			"action", cmd.Action,
+			"timeout", timeout,
+		)
+	}
+
+	if store == nil {
+		return
+	}
+
+	// Failing in the store also covers commands sent before a restart
+	ctx, cancel := context.WithTimeout(context.Background(), commandStoreTimeout)
+	defer cancel()
+
+	failed, err := store.FailExpired(ctx, deadline, reason)
+	if err != nil {
+		s.log.Errorw("failed to expire commands", "error", err)
+		return
+	}
+	if failed > int64(len(expired)) {
+		s.log.Warnw("expired unacknowledged commands", "count", failed)
+	}
+}
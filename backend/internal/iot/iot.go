@@ -3,6 +3,7 @@ package iot
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"sync"
 	"time"
 
@@ -61,6 +62,7 @@ type DeviceConfig struct {
 	Thresholds      map[string]Threshold `json:"thresholds,omitempty"`
 	Actions         []Action          `json:"actions,omitempty"`
 	Credentials     *Credentials      `json:"-"`
+	MQTT            *MQTTSettings     `json:"mqtt,omitempty"`
 }
 
 // Threshold defines alert thresholds for a metric
@@ -100,11 +102,23 @@ type Command struct {
 	Action    string                 `json:"action"`
 	Params    map[string]interface{} `json:"params,omitempty"`
 	Status    string                 `json:"status"` // pending, sent, acknowledged, completed, failed
+	Result    map[string]interface{} `json:"result,omitempty"`
+	Error     string                 `json:"error,omitempty"`
 	CreatedAt time.Time              `json:"created_at"`
 	SentAt    *time.Time             `json:"sent_at,omitempty"`
+	AcknowledgedAt *time.Time        `json:"acknowledged_at,omitempty"`
 	CompletedAt *time.Time           `json:"completed_at,omitempty"`
 }
 
+// Command statuses
+const (
+	CommandStatusPending      = "pending"
+	CommandStatusSent         = "sent"
+	CommandStatusAcknowledged = "acknowledged"
+	CommandStatusCompleted    = "completed"
+	CommandStatusFailed       = "failed"
+)
+
 // DeviceGroup represents a group of devices
 type DeviceGroup struct {
 	ID          uuid.UUID   `json:"id"`
@@ -129,6 +143,24 @@ type Service struct {
 	dataBuffer    chan DataPoint
 	commandQueue  chan Command
 	adapters      map[string]Adapter
+	telemetry     TelemetryStore
+	telemetryMu   sync.RWMutex
+	store         DeviceStore
+	commands      map[uuid.UUID]*Command
+	commandsMu    sync.Mutex
+	commandStore  CommandStore
+	commandTimeout time.Duration
+
+	// connected holds the devices whose adapter delivers their telemetry
+	connected   map[uuid.UUID]bool
+	connectedMu sync.Mutex
+
+	// Shutdown: stop ends the data and expiry workers, then stopCommands the
+	// command worker, so commands raised by the last data are still sent
+	stop         chan struct{}
+	workers      sync.WaitGroup
+	stopCommands chan struct{}
+	commandsDone chan struct{}
 }
 
 // Adapter interface for IoT protocols
@@ -157,24 +189,356 @@ func NewService(log *logger.Logger) *Service {
 		dataBuffer:   make(chan DataPoint, 10000),
 		commandQueue: make(chan Command, 1000),
 		adapters:     make(map[string]Adapter),
+		commands:     make(map[uuid.UUID]*Command),
+		connected:    make(map[uuid.UUID]bool),
+		commandTimeout: DefaultCommandTimeout,
+		stop:         make(chan struct{}),
+		stopCommands: make(chan struct{}),
+		commandsDone: make(chan struct{}),
 	}
 
 	// Start background workers
+	s.workers.Add(2)
 	go s.processDataBuffer()
 	go s.processCommandQueue()
+	go s.expireCommands()
 
 	return s
 }
 
+// Stop drains the background workers: buffered data is processed and its
+// telemetry written, then queued commands are sent, before they exit. Devices
+// are disconnected last.
+func (s *Service) Stop() {
+	close(s.stop)
+	s.workers.Wait()
+	close(s.stopCommands)
+	<-s.commandsDone
+
+	s.devicesMu.RLock()
+	devices := make([]*Device, 0, len(s.devices))
+	for _, device := range s.devices {
+		devices = append(devices, device)
+	}
+	s.devicesMu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, device := range devices {
+		s.disconnect(ctx, device)
+	}
+}
+
+// DataQueueDepth returns how many data points are buffered for processing
+func (s *Service) DataQueueDepth() int {
+	return len(s.dataBuffer)
+}
+
+// CommandQueueDepth returns how many commands are queued to be sent
+func (s *Service) CommandQueueDepth() int {
+	return len(s.commandQueue)
+}
+
 // RegisterAdapter registers an IoT protocol adapter
 func (s *Service) RegisterAdapter(protocol string, adapter Adapter) {
 	s.adapters[protocol] = adapter
+
+	if reporter, ok := adapter.(AckReporter); ok {
+		reporter.OnCommandAck(s.handleDeviceAck)
+	}
 }
 
 // =============================================================================
 // Device Management
 // =============================================================================
 
+// DeviceFilter narrows a device listing; zero fields match everything
+type DeviceFilter struct {
+	Type       DeviceType
+	Status     DeviceStatus
+	BusinessID *uuid.UUID
+}
+
+// deviceSyncInterval is how often the device cache is reconciled with the
+// store, picking up devices other instances registered, changed or deleted
+const deviceSyncInterval = time.Minute
+
+// DeviceStore persists devices. The service keeps every device in memory as a
+// cache over the store, loaded by LoadDevices. Single devices are read from
+// the store, which other instances write to as well.
+type DeviceStore interface {
+	Create(ctx context.Context, device *Device) error
+	// Get returns nil without an error if the device doesn't exist
+	Get(ctx context.Context, deviceID uuid.UUID) (*Device, error)
+	Update(ctx context.Context, device *Device) error
+	UpdateStatus(ctx context.Context, deviceID uuid.UUID, status DeviceStatus, lastSeen time.Time) error
+	Delete(ctx context.Context, deviceID uuid.UUID) error
+	List(ctx context.Context, tenantID uuid.UUID, filter DeviceFilter) ([]*Device, error)
+	ListAll(ctx context.Context) ([]*Device, error)
+}
+
+// SetDeviceStore sets where devices are persisted. Without one, devices only
+// live in memory.
+func (s *Service) SetDeviceStore(store DeviceStore) {
+	s.store = store
+}
+
+// LoadDevices builds the device cache from the store and connects the devices
+// in the background, then keeps the cache in sync with the store until the
+// service stops. Adapters must be registered first. Call it once.
+func (s *Service) LoadDevices(ctx context.Context) error {
+	if s.store == nil {
+		return nil
+	}
+
+	s.workers.Add(1)
+	go s.syncDevices()
+
+	count, err := s.reloadDevices(ctx)
+	if err != nil {
+		return err
+	}
+	s.log.Infow("device cache loaded", "devices", count)
+	return nil
+}
+
+// syncDevices periodically reconciles the device cache with the store
+func (s *Service) syncDevices() {
+	defer s.workers.Done()
+
+	ticker := time.NewTicker(deviceSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			if _, err := s.reloadDevices(ctx); err != nil {
+				s.log.Warnw("failed to sync device cache", "error", err)
+			}
+			cancel()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// reloadDevices reconciles the cache with every stored device. New devices
+// are cached and connected, changed ones refreshed, and deleted ones dropped
+// and disconnected. It returns how many devices are stored.
+func (s *Service) reloadDevices(ctx context.Context) (int, error) {
+	listedAt := time.Now()
+	stored, err := s.store.ListAll(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load devices: %w", err)
+	}
+
+	type cached struct{ device, previous *Device }
+	refreshed := make([]cached, 0, len(stored))
+	listed := make(map[uuid.UUID]bool, len(stored))
+	var removed []*Device
+
+	s.devicesMu.Lock()
+	for _, device := range stored {
+		previous := s.cacheStoredLocked(device)
+		refreshed = append(refreshed, cached{device, previous})
+		listed[device.ID] = true
+	}
+	for id, device := range s.devices {
+		// Devices registered here since the listing aren't in it yet
+		if !listed[id] && device.CreatedAt.Before(listedAt) {
+			delete(s.devices, id)
+			removed = append(removed, device)
+		}
+	}
+	s.devicesMu.Unlock()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		for _, device := range removed {
+			s.disconnect(ctx, device)
+		}
+		for _, c := range refreshed {
+			s.reconnectChanged(c.device, c.previous)
+		}
+	}()
+	return len(stored), nil
+}
+
+// device returns a device, or nil if it doesn't exist. With a store it is read
+// from the store, so changes made by other instances are seen, and the cache
+// is refreshed with it.
+func (s *Service) device(ctx context.Context, deviceID uuid.UUID) (*Device, error) {
+	if s.store == nil {
+		s.devicesMu.RLock()
+		defer s.devicesMu.RUnlock()
+		return s.devices[deviceID], nil
+	}
+
+	stored, err := s.store.Get(ctx, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device: %w", err)
+	}
+
+	s.devicesMu.Lock()
+	if stored == nil {
+		deleted, ok := s.devices[deviceID]
+		delete(s.devices, deviceID)
+		s.devicesMu.Unlock()
+		if ok {
+			s.disconnect(ctx, deleted)
+		}
+		return nil, nil
+	}
+	previous := s.cacheStoredLocked(stored)
+	s.devicesMu.Unlock()
+
+	go s.reconnectChanged(stored, previous)
+	return stored, nil
+}
+
+// cacheStoredLocked caches a device read from the store in place of the cached
+// one, which it returns. The store lags behind the device's live state, so the
+// latest data, and the status if it was seen more recently, are kept from the
+// cache. devicesMu must be held.
+func (s *Service) cacheStoredLocked(stored *Device) *Device {
+	previous, ok := s.devices[stored.ID]
+	if !ok {
+		s.devices[stored.ID] = stored
+		return nil
+	}
+
+	stored.LastData = previous.LastData
+	if !previous.LastSeen.Before(stored.LastSeen) {
+		stored.LastSeen = previous.LastSeen
+		stored.Status = previous.Status
+	}
+	s.devices[stored.ID] = stored
+	return previous
+}
+
+// reconnectChanged connects a device that is new to the cache, and reconnects
+// one whose connection settings were changed on another instance
+func (s *Service) reconnectChanged(device, previous *Device) {
+	if previous == nil {
+		s.connectInBackground(device)
+		return
+	}
+	if !connectionChanged(previous, device) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	s.disconnect(ctx, previous)
+	cancel()
+	s.connectInBackground(device)
+}
+
+// connectionChanged reports whether two versions of a device connect
+// differently
+func connectionChanged(a, b *Device) bool {
+	return deviceProtocol(a) != deviceProtocol(b) ||
+		!reflect.DeepEqual(a.Config.MQTT, b.Config.MQTT) ||
+		!reflect.DeepEqual(a.Config.Credentials, b.Config.Credentials)
+}
+
+// adapter returns the adapter for the device's protocol
+func (s *Service) adapter(device *Device) (Adapter, string, bool) {
+	protocol := deviceProtocol(device)
+	adapter, ok := s.adapters[protocol]
+	return adapter, protocol, ok
+}
+
+// deviceProtocol returns the protocol a device is reached by, mqtt by default
+func deviceProtocol(device *Device) string {
+	if protocol, ok := device.Metadata["protocol"].(string); ok {
+		return protocol
+	}
+	return "mqtt"
+}
+
+// connect connects a device through its protocol's adapter and ingests the
+// telemetry it publishes. Connecting a connected device is a no-op.
+func (s *Service) connect(ctx context.Context, device *Device) error {
+	adapter, protocol, ok := s.adapter(device)
+	if !ok {
+		return fmt.Errorf("no adapter for protocol %s", protocol)
+	}
+	if err := adapter.Connect(ctx, device); err != nil {
+		return err
+	}
+
+	// Adapters connect once but add a handler per subscription, so each
+	// device is only subscribed once
+	s.connectedMu.Lock()
+	defer s.connectedMu.Unlock()
+	if s.connected[device.ID] {
+		return nil
+	}
+	err := adapter.Subscribe(ctx, device, func(point DataPoint) {
+		if err := s.IngestData(context.Background(), point); err != nil {
+			s.log.Warnw("dropped device telemetry", "device_id", point.DeviceID, "error", err)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to device: %w", err)
+	}
+	s.connected[device.ID] = true
+	return nil
+}
+
+// connectInBackground connects a device without holding up the caller.
+// Devices that can't be connected now are connected when a command is sent
+// to them.
+func (s *Service) connectInBackground(device *Device) {
+	if _, _, ok := s.adapter(device); !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := s.connect(ctx, device); err != nil {
+		s.log.Warnw("failed to connect device", "device_id", device.ID, "error", err)
+	}
+}
+
+// disconnect closes a device's connection, if it has one
+func (s *Service) disconnect(ctx context.Context, device *Device) {
+	s.connectedMu.Lock()
+	connected := s.connected[device.ID]
+	delete(s.connected, device.ID)
+	s.connectedMu.Unlock()
+	if !connected {
+		return
+	}
+
+	adapter, _, ok := s.adapter(device)
+	if !ok {
+		return
+	}
+	if err := adapter.Disconnect(ctx, device); err != nil {
+		s.log.Warnw("failed to disconnect device", "device_id", device.ID, "error", err)
+	}
+}
+
+// saveStatus persists a device's status and last seen time after it changes
+func (s *Service) saveStatus(deviceID uuid.UUID, status DeviceStatus, lastSeen time.Time) {
+	if s.store == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := s.store.UpdateStatus(ctx, deviceID, status, lastSeen); err != nil {
+		s.log.Warnw("failed to save device status",
+			"device_id", deviceID,
+			"status", status,
+			"error", err,
+		)
+	}
+}
+
 // RegisterDevice registers a new IoT device
 func (s *Service) RegisterDevice(ctx context.Context, device *Device) error {
 	device.ID = uuid.New()
@@ -182,6 +546,12 @@ func (s *Service) RegisterDevice(ctx context.Context, device *Device) error {
 	device.CreatedAt = time.Now()
 	device.UpdatedAt = time.Now()
 
+	if s.store != nil {
+		if err := s.store.Create(ctx, device); err != nil {
+			return fmt.Errorf("failed to create device: %w", err)
+		}
+	}
+
 	s.devicesMu.Lock()
 	s.devices[device.ID] = device
 	s.devicesMu.Unlock()
@@ -192,48 +562,106 @@ func (s *Service) RegisterDevice(ctx context.Context, device *Device) error {
 		"type", device.Type,
 	)
 
+	go s.connectInBackground(device)
 	return nil
 }
 
-// GetDevice retrieves a device by ID
-func (s *Service) GetDevice(ctx context.Context, deviceID uuid.UUID) (*Device, error) {
-	s.devicesMu.RLock()
-	device, ok := s.devices[deviceID]
-	s.devicesMu.RUnlock()
+// GetDevice retrieves a tenant's device by ID
+func (s *Service) GetDevice(ctx context.Context, tenantID, deviceID uuid.UUID) (*Device, error) {
+	device, err := s.device(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
 
-	if !ok {
-		return nil, fmt.Errorf("device not found: %s", deviceID)
+	if device == nil || device.TenantID != tenantID {
+		return nil, fmt.Errorf("device not found")
 	}
 
 	return device, nil
 }
 
-// UpdateDevice updates a device
+// UpdateDevice updates a device. The device must already belong to its tenant.
 func (s *Service) UpdateDevice(ctx context.Context, device *Device) error {
-	s.devicesMu.Lock()
-	if _, ok := s.devices[device.ID]; !ok {
-		s.devicesMu.Unlock()
-		return fmt.Errorf("device not found: %s", device.ID)
+	previous, err := s.GetDevice(ctx, device.TenantID, device.ID)
+	if err != nil {
+		return err
 	}
+
 	device.UpdatedAt = time.Now()
+	if s.store != nil {
+		if err := s.store.Update(ctx, device); err != nil {
+			return fmt.Errorf("failed to update device: %w", err)
+		}
+	}
+
+	s.devicesMu.Lock()
 	s.devices[device.ID] = device
 	s.devicesMu.Unlock()
 
+	// The device's broker, topics or credentials may have changed
+	s.disconnect(ctx, previous)
+	go s.connectInBackground(device)
 	return nil
 }
 
-// DeleteDevice removes a device
-func (s *Service) DeleteDevice(ctx context.Context, deviceID uuid.UUID) error {
+// DeleteDevice removes a tenant's device
+func (s *Service) DeleteDevice(ctx context.Context, tenantID, deviceID uuid.UUID) error {
+	device, err := s.GetDevice(ctx, tenantID, deviceID)
+	if err != nil {
+		return err
+	}
+
+	if s.store != nil {
+		if err := s.store.Delete(ctx, deviceID); err != nil {
+			return fmt.Errorf("failed to delete device: %w", err)
+		}
+	}
+
 	s.devicesMu.Lock()
 	delete(s.devices, deviceID)
 	s.devicesMu.Unlock()
+	s.disconnect(ctx, device)
 
 	s.log.Infow("device deleted", "device_id", deviceID)
 	return nil
 }
 
-// ListDevices lists devices with optional filters
+// ListDevices lists devices with optional filters: "type" (DeviceType),
+// "status" (DeviceStatus) and "business_id" (uuid.UUID). With a store the
+// filters are applied in the query and the listed devices refresh the cache.
 func (s *Service) ListDevices(ctx context.Context, tenantID uuid.UUID, filters map[string]interface{}) ([]*Device, error) {
+	var filter DeviceFilter
+	if deviceType, ok := filters["type"].(DeviceType); ok {
+		filter.Type = deviceType
+	}
+	if status, ok := filters["status"].(DeviceStatus); ok {
+		filter.Status = status
+	}
+	if businessID, ok := filters["business_id"].(uuid.UUID); ok {
+		filter.BusinessID = &businessID
+	}
+
+	if s.store != nil {
+		stored, err := s.store.List(ctx, tenantID, filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list devices: %w", err)
+		}
+
+		previous := make([]*Device, len(stored))
+		s.devicesMu.Lock()
+		for i, device := range stored {
+			previous[i] = s.cacheStoredLocked(device)
+		}
+		s.devicesMu.Unlock()
+
+		go func() {
+			for i, device := range stored {
+				s.reconnectChanged(device, previous[i])
+			}
+		}()
+		return stored, nil
+	}
+
 	s.devicesMu.RLock()
 	defer s.devicesMu.RUnlock()
 
@@ -244,13 +672,13 @@ func (s *Service) ListDevices(ctx context.Context, tenantID uuid.UUID, filters m
 		}
 
 		// Apply filters
-		if deviceType, ok := filters["type"].(DeviceType); ok && device.Type != deviceType {
+		if filter.Type != "" && device.Type != filter.Type {
 			continue
 		}
-		if status, ok := filters["status"].(DeviceStatus); ok && device.Status != status {
+		if filter.Status != "" && device.Status != filter.Status {
 			continue
 		}
-		if businessID, ok := filters["business_id"].(uuid.UUID); ok && device.BusinessID != businessID {
+		if filter.BusinessID != nil && device.BusinessID != *filter.BusinessID {
 			continue
 		}
 
@@ -269,13 +697,21 @@ func (s *Service) IngestData(ctx context.Context, data DataPoint) error {
 	select {
 	case s.dataBuffer <- data:
 		// Update device last seen and last data
+		cameOnline := false
 		s.devicesMu.Lock()
 		if device, ok := s.devices[data.DeviceID]; ok {
 			device.LastSeen = data.Timestamp
 			device.LastData = data.Data
-			device.Status = DeviceStatusOnline
+			if device.Status != DeviceStatusOnline {
+				device.Status = DeviceStatusOnline
+				cameOnline = true
+			}
 		}
 		s.devicesMu.Unlock()
+
+		if cameOnline {
+			go s.saveStatus(data.DeviceID, DeviceStatusOnline, data.Timestamp)
+		}
 		return nil
 	case <-ctx.Done():
 		return ctx.Err()
@@ -284,37 +720,74 @@ func (s *Service) IngestData(ctx context.Context, data DataPoint) error {
 	}
 }
 
-// processDataBuffer processes incoming device data
+// processDataBuffer processes incoming device data, checking thresholds as
+// each point arrives and writing points to the telemetry store in batches
 func (s *Service) processDataBuffer() {
-	for data := range s.dataBuffer {
-		// Check thresholds and trigger alerts
-		s.devicesMu.RLock()
-		device, ok := s.devices[data.DeviceID]
-		s.devicesMu.RUnlock()
+	defer s.workers.Done()
 
-		if !ok {
-			continue
+	ticker := time.NewTicker(telemetryFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]DataPoint, 0, telemetryBatchSize)
+	process := func(data DataPoint) {
+		s.checkThresholds(data)
+
+		batch = append(batch, data)
+		if len(batch) >= telemetryBatchSize {
+			s.flushTelemetry(batch)
+			batch = batch[:0]
 		}
+	}
 
-		for metric, threshold := range device.Config.Thresholds {
-			value, ok := data.Data[metric].(float64)
-			if !ok {
-				continue
+	for {
+		select {
+		case <-s.stop:
+			for {
+				select {
+				case data := <-s.dataBuffer:
+					process(data)
+				default:
+					s.flushTelemetry(batch)
+					return
+				}
 			}
-
-			if threshold.Min != nil && value < *threshold.Min {
-				s.handleThresholdBreach(device, metric, value, "below_min")
+		case data, ok := <-s.dataBuffer:
+			if !ok {
+				s.flushTelemetry(batch)
+				return
 			}
-			if threshold.Max != nil && value > *threshold.Max {
-				s.handleThresholdBreach(device, metric, value, "above_max")
+			process(data)
+		case <-ticker.C:
+			if len(batch) > 0 {
+				s.flushTelemetry(batch)
+				batch = batch[:0]
 			}
 		}
+	}
+}
 
-		// Store data point (would integrate with time series database)
-		s.log.Debugw("data point processed",
-			"device_id", data.DeviceID,
-			"data", data.Data,
-		)
+// checkThresholds triggers alerts for metrics outside the device's thresholds
+func (s *Service) checkThresholds(data DataPoint) {
+	s.devicesMu.RLock()
+	device, ok := s.devices[data.DeviceID]
+	s.devicesMu.RUnlock()
+
+	if !ok {
+		return
+	}
+
+	for metric, threshold := range device.Config.Thresholds {
+		value, ok := data.Data[metric].(float64)
+		if !ok {
+			continue
+		}
+
+		if threshold.Min != nil && value < *threshold.Min {
+			s.handleThresholdBreach(device, metric, value, "below_min")
+		}
+		if threshold.Max != nil && value > *threshold.Max {
+			s.handleThresholdBreach(device, metric, value, "above_max")
+		}
 	}
 }
 
@@ -336,9 +809,16 @@ func (s *Service) handleThresholdBreach(device *Device, metric string, value flo
 				DeviceID:  device.ID,
 				Action:    action.Command,
 				Params:    action.Params,
-				Status:    "pending",
+				Status:    CommandStatusPending,
 				CreatedAt: time.Now(),
 			}
+			if err := s.trackCommand(context.Background(), &cmd); err != nil {
+				s.log.Errorw("failed to track command",
+					"command_id", cmd.ID,
+					"device_id", device.ID,
+					"error", err,
+				)
+			}
 			s.commandQueue <- cmd
 		}
 	}
@@ -348,72 +828,104 @@ func (s *Service) handleThresholdBreach(device *Device, metric string, value flo
 // Command Execution
 // =============================================================================
 
-// SendCommand sends a command to a device
+// SendCommand queues a command for a device. Its progress can be followed
+// with GetCommand.
 func (s *Service) SendCommand(ctx context.Context, cmd *Command) error {
 	cmd.ID = uuid.New()
-	cmd.Status = "pending"
+	cmd.Status = CommandStatusPending
 	cmd.CreatedAt = time.Now()
 
+	if err := s.trackCommand(ctx, cmd); err != nil {
+		return err
+	}
+
 	select {
 	case s.commandQueue <- *cmd:
 		return nil
 	case <-ctx.Done():
+		s.failCommand(cmd.ID, ctx.Err().Error())
 		return ctx.Err()
 	default:
+		s.failCommand(cmd.ID, "command queue full")
 		return fmt.Errorf("command queue full")
 	}
 }
 
 // processCommandQueue processes outgoing device commands
 func (s *Service) processCommandQueue() {
-	for cmd := range s.commandQueue {
-		s.devicesMu.RLock()
-		device, ok := s.devices[cmd.DeviceID]
-		s.devicesMu.RUnlock()
-
-		if !ok {
-			s.log.Warnw("command for unknown device",
-				"command_id", cmd.ID,
-				"device_id", cmd.DeviceID,
-			)
-			continue
+	defer close(s.commandsDone)
+
+	for {
+		select {
+		case cmd := <-s.commandQueue:
+			s.executeCommand(cmd)
+		case <-s.stopCommands:
+			for {
+				select {
+				case cmd := <-s.commandQueue:
+					s.executeCommand(cmd)
+				default:
+					return
+				}
+			}
 		}
+	}
+}
 
-		// Get adapter for device protocol
-		protocol := "mqtt" // Default protocol
-		if p, ok := device.Metadata["protocol"].(string); ok {
-			protocol = p
-		}
+// executeCommand sends a command through its device's protocol adapter
+func (s *Service) executeCommand(cmd Command) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	device, err := s.device(ctx, cmd.DeviceID)
+	if err != nil {
+		s.log.Errorw("failed to send command",
+			"command_id", cmd.ID,
+			"device_id", cmd.DeviceID,
+			"error", err,
+		)
+		s.failCommand(cmd.ID, err.Error())
+		return
+	}
+	if device == nil {
+		s.log.Warnw("command for unknown device",
+			"command_id", cmd.ID,
+			"device_id", cmd.DeviceID,
+		)
+		s.failCommand(cmd.ID, "device not found")
+		return
+	}
 
-		adapter, ok := s.adapters[protocol]
-		if !ok {
-			s.log.Warnw("no adapter for protocol",
-				"protocol", protocol,
-				"device_id", device.ID,
-			)
-			continue
-		}
+	// Get adapter for device protocol
+	adapter, protocol, ok := s.adapter(device)
+	if !ok {
+		s.log.Warnw("no adapter for protocol",
+			"protocol", protocol,
+			"device_id", device.ID,
+		)
+		s.failCommand(cmd.ID, fmt.Sprintf("no adapter for protocol %s", protocol))
+		return
+	}
 
-		// Send command
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		if err := adapter.SendCommand(ctx, device, &cmd); err != nil {
-			s.log.Errorw("failed to send command",
-				"command_id", cmd.ID,
-				"device_id", device.ID,
-				"error", err,
-			)
-			cmd.Status = "failed"
-		} else {
-			now := time.Now()
-			cmd.SentAt = &now
-			cmd.Status = "sent"
-			s.log.Infow("command sent",
-				"command_id", cmd.ID,
-				"device_id", device.ID,
-				"action", cmd.Action,
-			)
-		}
-		cancel()
+	// Send command, connecting the device first if it isn't yet
+	err = s.connect(ctx, device)
+	if err == nil {
+		err = adapter.SendCommand(ctx, device, &cmd)
+	}
+	if err != nil {
+		s.log.Errorw("failed to send command",
+			"command_id", cmd.ID,
+			"device_id", device.ID,
+			"error", err,
+		)
+		s.failCommand(cmd.ID, err.Error())
+	} else {
+		s.markCommandSent(cmd.ID)
+		s.log.Infow("command sent",
+			"command_id", cmd.ID,
+			"device_id", device.ID,
+			"action", cmd.Action,
+		)
 	}
 }
 
@@ -470,6 +982,7 @@ func (s *Service) CheckDeviceHealth(ctx context.Context) error {
 	for _, device := range s.devices {
 		if device.Status == DeviceStatusOnline && now.Sub(device.LastSeen) > offlineThreshold {
 			device.Status = DeviceStatusOffline
+			go s.saveStatus(device.ID, DeviceStatusOffline, device.LastSeen)
 			s.log.Warnw("device went offline",
 				"device_id", device.ID,
 				"name", device.Name,
@@ -0,0 +1,428 @@
+package iot
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/google/uuid"
+)
+
+// =============================================================================
+// MQTT Adapter
+// =============================================================================
+
+// Default MQTT topics; {device_id} is replaced with the device's ID
+const (
+	DefaultMQTTTelemetryTopic = "devices/{device_id}/telemetry"
+	DefaultMQTTCommandTopic   = "devices/{device_id}/commands"
+	DefaultMQTTAckTopic       = "devices/{device_id}/commands/ack"
+)
+
+// MQTTSettings configures how a device is reached over MQTT. Empty fields use
+// the adapter's defaults.
+type MQTTSettings struct {
+	BrokerURL      string `json:"broker_url,omitempty"`
+	TelemetryTopic string `json:"telemetry_topic,omitempty"`
+	CommandTopic   string `json:"command_topic,omitempty"`
+	AckTopic       string `json:"ack_topic,omitempty"`
+	QoS            *int   `json:"qos,omitempty"` // 0, 1 or 2
+	RetainCommands bool   `json:"retain_commands,omitempty"`
+}
+
+// MQTTConfig holds the adapter's broker defaults
+type MQTTConfig struct {
+	BrokerURL string
+	Username  string
+	Password  string
+
+	// ClientIDPrefix is prepended to the device ID to form each client ID
+	ClientIDPrefix string
+
+	// QoS is used for devices that don't set their own
+	QoS int
+
+	ConnectTimeout       time.Duration
+	MaxReconnectInterval time.Duration
+}
+
+// DefaultMQTTConfig returns the adapter defaults for a broker
+func DefaultMQTTConfig(brokerURL string) MQTTConfig {
+	return MQTTConfig{
+		BrokerURL:            brokerURL,
+		ClientIDPrefix:       "delphi-",
+		QoS:                  1,
+		ConnectTimeout:       10 * time.Second,
+		MaxReconnectInterval: 2 * time.Minute,
+	}
+}
+
+// MQTTAdapter talks to devices through an MQTT broker. Each connected device
+// gets its own client, which reconnects on its own and resubscribes to the
+// device's telemetry and acknowledgement topics whenever it does.
+type MQTTAdapter struct {
+	cfg MQTTConfig
+	log *logger.Logger
+
+	// newClient creates each device's client; tests replace it with a fake
+	newClient func(opts *mqtt.ClientOptions) mqtt.Client
+
+	mu    sync.Mutex
+	conns map[uuid.UUID]*mqttConn
+	onAck func(deviceID, commandID uuid.UUID, result CommandResult)
+}
+
+// mqttConn is the client and telemetry state of one device
+type mqttConn struct {
+	client         mqtt.Client
+	qos            byte
+	retain         bool
+	telemetryTopic string
+	commandTopic   string
+	ackTopic       string
+
+	mu       sync.Mutex
+	last     *DataPoint
+	handlers []func(DataPoint)
+}
+
+// NewMQTTAdapter creates an MQTT adapter
+func NewMQTTAdapter(cfg MQTTConfig, log *logger.Logger) *MQTTAdapter {
+	defaults := DefaultMQTTConfig(cfg.BrokerURL)
+	if cfg.ClientIDPrefix == "" {
+		cfg.ClientIDPrefix = defaults.ClientIDPrefix
+	}
+	if cfg.ConnectTimeout <= 0 {
+		cfg.ConnectTimeout = defaults.ConnectTimeout
+	}
+	if cfg.MaxReconnectInterval <= 0 {
+		cfg.MaxReconnectInterval = defaults.MaxReconnectInterval
+	}
+
+	return &MQTTAdapter{
+		cfg:       cfg,
+		log:       log,
+		newClient: mqtt.NewClient,
+		conns:     make(map[uuid.UUID]*mqttConn),
+	}
+}
+
+// SetClientFactory replaces how device clients are created, such as with a
+// client for an in-process broker
+func (a *MQTTAdapter) SetClientFactory(newClient func(opts *mqtt.ClientOptions) mqtt.Client) {
+	a.newClient = newClient
+}
+
+// Connect connects a device's client to its broker and subscribes to its
+// telemetry topic. Connecting an already connected device is a no-op.
+func (a *MQTTAdapter) Connect(ctx context.Context, device *Device) error {
+	a.mu.Lock()
+	_, connected := a.conns[device.ID]
+	a.mu.Unlock()
+	if connected {
+		return nil
+	}
+
+	settings := MQTTSettings{}
+	if device.Config.MQTT != nil {
+		settings = *device.Config.MQTT
+	}
+
+	broker := settings.BrokerURL
+	if broker == "" {
+		broker = a.cfg.BrokerURL
+	}
+	if broker == "" {
+		return fmt.Errorf("no MQTT broker configured for device %s", device.ID)
+	}
+
+	qos := a.cfg.QoS
+	if settings.QoS != nil {
+		qos = *settings.QoS
+	}
+	if qos < 0 || qos > 2 {
+		return fmt.Errorf("invalid MQTT QoS %d: must be 0, 1 or 2", qos)
+	}
+
+	conn := &mqttConn{
+		qos:            byte(qos),
+		retain:         settings.RetainCommands,
+		telemetryTopic: deviceTopic(settings.TelemetryTopic, DefaultMQTTTelemetryTopic, device.ID),
+		commandTopic:   deviceTopic(settings.CommandTopic, DefaultMQTTCommandTopic, device.ID),
+		ackTopic:       deviceTopic(settings.AckTopic, DefaultMQTTAckTopic, device.ID),
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(broker).
+		SetClientID(a.cfg.ClientIDPrefix + device.ID.String()).
+		SetCleanSession(true).
+		SetAutoReconnect(true).
+		SetMaxReconnectInterval(a.cfg.MaxReconnectInterval).
+		SetConnectTimeout(a.cfg.ConnectTimeout).
+		SetOnConnectHandler(func(client mqtt.Client) {
+			// Clean sessions drop subscriptions, so renew them on every (re)connect
+			a.subscribe(client, device.ID, conn.telemetryTopic, conn.qos, func(_ mqtt.Client, msg mqtt.Message) {
+				a.handleTelemetry(device.ID, conn, msg)
+			})
+			a.subscribe(client, device.ID, conn.ackTopic, conn.qos, func(_ mqtt.Client, msg mqtt.Message) {
+				a.handleAck(device.ID, msg)
+			})
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			a.log.Warnw("MQTT connection lost, reconnecting", "device_id", device.ID, "error", err)
+		}).
+		SetReconnectingHandler(func(_ mqtt.Client, _ *mqtt.ClientOptions) {
+			a.log.Debugw("MQTT reconnecting", "device_id", device.ID)
+		})
+
+	if err := a.applyCredentials(opts, device); err != nil {
+		return err
+	}
+
+	conn.client = a.newClient(opts)
+	if err := waitToken(ctx, conn.client.Connect()); err != nil {
+		return fmt.Errorf("failed to connect to MQTT broker: %w", err)
+	}
+
+	a.mu.Lock()
+	if _, raced := a.conns[device.ID]; raced {
+		a.mu.Unlock()
+		conn.client.Disconnect(250)
+		return nil
+	}
+	a.conns[device.ID] = conn
+	a.mu.Unlock()
+
+	a.log.Infow("device connected over MQTT",
+		"device_id", device.ID,
+		"broker", broker,
+		"telemetry_topic", conn.telemetryTopic,
+		"qos", qos,
+	)
+
+	return nil
+}
+
+// subscribe subscribes the client to a topic. Waiting on the token inside the
+// connect handler would block the client, so the result is checked apart.
+func (a *MQTTAdapter) subscribe(client mqtt.Client, deviceID uuid.UUID, topic string, qos byte, handler mqtt.MessageHandler) {
+	token := client.Subscribe(topic, qos, handler)
+	go func() {
+		if token.WaitTimeout(a.cfg.ConnectTimeout) && token.Error() != nil {
+			a.log.Errorw("failed to subscribe to device topic",
+				"device_id", deviceID,
+				"topic", topic,
+				"error", token.Error(),
+			)
+		}
+	}()
+}
+
+// applyCredentials sets the client's login from the device's credentials,
+// falling back to the adapter's
+func (a *MQTTAdapter) applyCredentials(opts *mqtt.ClientOptions, device *Device) error {
+	creds := device.Config.Credentials
+	if creds == nil {
+		if a.cfg.Username != "" {
+			opts.SetUsername(a.cfg.Username)
+			opts.SetPassword(a.cfg.Password)
+		}
+		return nil
+	}
+
+	switch creds.Type {
+	case "certificate":
+		// CertPath holds a PEM file with both the client certificate and its key
+		cert, err := tls.LoadX509KeyPair(creds.CertPath, creds.CertPath)
+		if err != nil {
+			return fmt.Errorf("failed to load device certificate: %w", err)
+		}
+		opts.SetTLSConfig(&tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12})
+	default:
+		password := creds.Token
+		if password == "" {
+			password = creds.APIKey
+		}
+		opts.SetUsername(device.ID.String())
+		opts.SetPassword(password)
+	}
+	return nil
+}
+
+// Disconnect closes a device's client
+func (a *MQTTAdapter) Disconnect(ctx context.Context, device *Device) error {
+	a.mu.Lock()
+	conn, ok := a.conns[device.ID]
+	delete(a.conns, device.ID)
+	a.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+	conn.client.Disconnect(250)
+
+	a.log.Infow("device disconnected from MQTT", "device_id", device.ID)
+	return nil
+}
+
+// ReadData returns the latest telemetry the device published. MQTT devices
+// push their data, so this does not query the device.
+func (a *MQTTAdapter) ReadData(ctx context.Context, device *Device) (map[string]interface{}, error) {
+	conn, err := a.conn(device.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if conn.last == nil {
+		return nil, fmt.Errorf("no telemetry received from device %s", device.ID)
+	}
+	return conn.last.Data, nil
+}
+
+// mqttCommand is the payload published to a device's command topic
+type mqttCommand struct {
+	ID        uuid.UUID              `json:"id"`
+	Action    string                 `json:"action"`
+	Params    map[string]interface{} `json:"params,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// SendCommand publishes a command to the device's command topic and waits for
+// the broker to accept it at the configured QoS
+func (a *MQTTAdapter) SendCommand(ctx context.Context, device *Device, cmd *Command) error {
+	conn, err := a.conn(device.ID)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(mqttCommand{
+		ID:        cmd.ID,
+		Action:    cmd.Action,
+		Params:    cmd.Params,
+		CreatedAt: cmd.CreatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode command: %w", err)
+	}
+
+	if err := waitToken(ctx, conn.client.Publish(conn.commandTopic, conn.qos, conn.retain, payload)); err != nil {
+		return fmt.Errorf("failed to publish command: %w", err)
+	}
+	return nil
+}
+
+// Subscribe forwards the device's telemetry to handler. The underlying topic
+// subscription is made by Connect and survives reconnects.
+func (a *MQTTAdapter) Subscribe(ctx context.Context, device *Device, handler func(DataPoint)) error {
+	conn, err := a.conn(device.ID)
+	if err != nil {
+		return err
+	}
+
+	conn.mu.Lock()
+	conn.handlers = append(conn.handlers, handler)
+	conn.mu.Unlock()
+	return nil
+}
+
+// OnCommandAck sets the handler for command results published by devices
+func (a *MQTTAdapter) OnCommandAck(handler func(deviceID, commandID uuid.UUID, result CommandResult)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.onAck = handler
+}
+
+func (a *MQTTAdapter) conn(deviceID uuid.UUID) (*mqttConn, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	conn, ok := a.conns[deviceID]
+	if !ok {
+		return nil, fmt.Errorf("device %s is not connected", deviceID)
+	}
+	return conn, nil
+}
+
+// handleTelemetry decodes a telemetry message, which must be a JSON object,
+// and hands it to the device's subscribers. A "timestamp" field in RFC 3339
+// format is used as the data point's time.
+func (a *MQTTAdapter) handleTelemetry(deviceID uuid.UUID, conn *mqttConn, msg mqtt.Message) {
+	var data map[string]interface{}
+	if err := json.Unmarshal(msg.Payload(), &data); err != nil {
+		a.log.Warnw("ignoring malformed device telemetry",
+			"device_id", deviceID,
+			"topic", msg.Topic(),
+			"error", err,
+		)
+		return
+	}
+
+	point := DataPoint{DeviceID: deviceID, Timestamp: time.Now(), Data: data}
+	if ts, ok := data["timestamp"].(string); ok {
+		if parsed, err := time.Parse(time.RFC3339, ts); err == nil {
+			point.Timestamp = parsed
+		}
+	}
+
+	conn.mu.Lock()
+	conn.last = &point
+	handlers := append([]func(DataPoint){}, conn.handlers...)
+	conn.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(point)
+	}
+}
+
+// mqttAck is the payload a device publishes to its acknowledgement topic
+type mqttAck struct {
+	ID uuid.UUID `json:"id"`
+	CommandResult
+}
+
+// handleAck decodes a command result and hands it to the ack handler
+func (a *MQTTAdapter) handleAck(deviceID uuid.UUID, msg mqtt.Message) {
+	var ack mqttAck
+	if err := json.Unmarshal(msg.Payload(), &ack); err != nil || ack.ID == uuid.Nil {
+		a.log.Warnw("ignoring malformed command acknowledgement",
+			"device_id", deviceID,
+			"topic", msg.Topic(),
+			"error", err,
+		)
+		return
+	}
+
+	a.mu.Lock()
+	handler := a.onAck
+	a.mu.Unlock()
+
+	if handler != nil {
+		handler(deviceID, ack.ID, ack.CommandResult)
+	}
+}
+
+// deviceTopic returns the configured topic, or the default, for a device
+func deviceTopic(topic, fallback string, deviceID uuid.UUID) string {
+	if topic == "" {
+		topic = fallback
+	}
+	return strings.ReplaceAll(topic, "{device_id}", deviceID.String())
+}
+
+// waitToken waits for an MQTT operation to complete or the context to end
+func waitToken(ctx context.Context, token mqtt.Token) error {
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
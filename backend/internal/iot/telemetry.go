@@ -0,0 +1,146 @@
+package iot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/google/uuid"
+)
+
+// =============================================================================
+// Telemetry Storage
+// =============================================================================
+
+// Telemetry batching. Points are written once a batch fills or the interval
+// passes, whichever comes first.
+const (
+	telemetryBatchSize     = 500
+	telemetryFlushInterval = time.Second
+	telemetryWriteTimeout  = 10 * time.Second
+)
+
+// TelemetryStore persists device data points for later querying
+type TelemetryStore interface {
+	// Write stores data points
+	Write(ctx context.Context, points ...DataPoint) error
+
+	// Query returns a device's data points in [from, to), newest first
+	Query(ctx context.Context, deviceID uuid.UUID, from, to time.Time, limit int) ([]DataPoint, error)
+}
+
+// SetTelemetryStore sets where ingested data points are stored
+func (s *Service) SetTelemetryStore(store TelemetryStore) {
+	s.telemetryMu.Lock()
+	defer s.telemetryMu.Unlock()
+	s.telemetry = store
+}
+
+func (s *Service) telemetryStore() TelemetryStore {
+	s.telemetryMu.RLock()
+	defer s.telemetryMu.RUnlock()
+	return s.telemetry
+}
+
+// flushTelemetry writes a batch of data points. Points that fail to write are
+// dropped so a slow or failing store can't back up ingestion.
+func (s *Service) flushTelemetry(batch []DataPoint) {
+	if len(batch) == 0 {
+		return
+	}
+
+	store := s.telemetryStore()
+	if store == nil {
+		s.log.Debugw("no telemetry store, data points discarded", "count", len(batch))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), telemetryWriteTimeout)
+	defer cancel()
+
+	if err := store.Write(ctx, batch...); err != nil {
+		s.log.Errorw("failed to write telemetry", "count", len(batch), "error", err)
+	}
+}
+
+// QueryTelemetry returns a device's data points in [from, to), newest first
+func (s *Service) QueryTelemetry(ctx context.Context, deviceID uuid.UUID, from, to time.Time, limit int) ([]DataPoint, error) {
+	store := s.telemetryStore()
+	if store == nil {
+		return nil, fmt.Errorf("telemetry store not configured")
+	}
+	return store.Query(ctx, deviceID, from, to, limit)
+}
+
+// PgTelemetryStore stores data points in the iot_telemetry table
+type PgTelemetryStore struct {
+	db *repository.PostgresDB
+}
+
+// NewPgTelemetryStore creates a Postgres telemetry store
+func NewPgTelemetryStore(db *repository.PostgresDB) *PgTelemetryStore {
+	return &PgTelemetryStore{db: db}
+}
+
+// Write inserts data points in a single statement. Points for devices that no
+// longer exist are skipped rather than failing the batch.
+func (s *PgTelemetryStore) Write(ctx context.Context, points ...DataPoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(points))
+	deviceIDs := make([]uuid.UUID, len(points))
+	data := make([]string, len(points))
+	receivedAt := make([]time.Time, len(points))
+	for i, p := range points {
+		encoded, err := json.Marshal(p.Data)
+		if err != nil {
+			return fmt.Errorf("failed to encode data point: %w", err)
+		}
+		ids[i] = uuid.New()
+		deviceIDs[i] = p.DeviceID
+		data[i] = string(encoded)
+		receivedAt[i] = p.Timestamp
+	}
+
+	query := `
+		INSERT INTO iot_telemetry (id, device_id, data, received_at)
+		SELECT t.id, t.device_id, t.data::jsonb, t.received_at
+		FROM unnest($1::uuid[], $2::uuid[], $3::text[], $4::timestamptz[]) AS t(id, device_id, data, received_at)
+		WHERE EXISTS (SELECT 1 FROM iot_devices d WHERE d.id = t.device_id)
+	`
+	_, err := s.db.Pool().Exec(ctx, query, ids, deviceIDs, data, receivedAt)
+	return err
+}
+
+// Query returns a device's data points in [from, to), newest first
+func (s *PgTelemetryStore) Query(ctx context.Context, deviceID uuid.UUID, from, to time.Time, limit int) ([]DataPoint, error) {
+	query := `
+		SELECT data, received_at FROM iot_telemetry
+		WHERE device_id = $1 AND received_at >= $2 AND received_at < $3
+		ORDER BY received_at DESC
+		LIMIT $4
+	`
+	rows, err := s.db.Pool().Query(ctx, query, deviceID, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []DataPoint{}
+	for rows.Next() {
+		var raw []byte
+		point := DataPoint{DeviceID: deviceID}
+		if err := rows.Scan(&raw, &point.Timestamp); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(raw, &point.Data); err != nil {
+			return nil, fmt.Errorf("failed to decode data point: %w", err)
+		}
+		points = append(points, point)
+	}
+	return points, rows.Err()
+}
@@ -0,0 +1,190 @@
+package knowledge
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// =============================================================================
+// Code Chunking
+// =============================================================================
+
+// maxCodeChunkLines bounds a chunk of code. Declarations longer than this are
+// split into consecutive pieces that share the symbol name.
+const maxCodeChunkLines = 200
+
+// codeSyntax describes how to find top-level declarations in a language
+type codeSyntax struct {
+	// declaration matches the first line of a top-level declaration; its first
+	// non-empty capture group, if any, is the symbol name
+	declaration *regexp.Regexp
+
+	// leading reports whether a line directly above a declaration belongs to
+	// it, such as a doc comment or decorator
+	leading func(line string) bool
+}
+
+var (
+	goSyntax = &codeSyntax{
+		declaration: regexp.MustCompile(`^(?:func\s+(?:\([^)]*\)\s*)?(\w+)|type\s+(\w+)|(?:var|const)\s+(\w+)|(?:type|var|const)\s*\()`),
+		leading:     isSlashComment,
+	}
+
+	typeScriptSyntax = &codeSyntax{
+		declaration: regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:declare\s+)?(?:async\s+)?(?:abstract\s+)?(?:function\*?|class|interface|type|enum|const|let|var|namespace)\s+(\w+)`),
+		leading: func(line string) bool {
+			return isSlashComment(line) || strings.HasPrefix(strings.TrimSpace(line), "@")
+		},
+	}
+
+	pythonSyntax = &codeSyntax{
+		declaration: regexp.MustCompile(`^(?:async\s+)?(?:def|class)\s+(\w+)`),
+		leading: func(line string) bool {
+			return strings.HasPrefix(line, "#") || strings.HasPrefix(line, "@")
+		},
+	}
+)
+
+// codeSyntaxes maps language names, as given in RepositoryFile.Language, to
+// their syntax
+var codeSyntaxes = map[string]*codeSyntax{
+	"go":         goSyntax,
+	"golang":     goSyntax,
+	"typescript": typeScriptSyntax,
+	"ts":         typeScriptSyntax,
+	"tsx":        typeScriptSyntax,
+	"python":     pythonSyntax,
+	"py":         pythonSyntax,
+}
+
+// isSlashComment reports whether line is part of a // or /* */ comment
+func isSlashComment(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "//") ||
+		strings.HasPrefix(trimmed, "/*") ||
+		strings.HasPrefix(trimmed, "*")
+}
+
+// codeSegment is a run of source lines, [start, end) zero-based
+type codeSegment struct {
+	symbol     string
+	start, end int
+}
+
+// chunkCode splits source code into one chunk per top-level declaration,
+// keeping each declaration together with its doc comment. Anything before the
+// first declaration, such as package and import lines, becomes its own chunk.
+// Chunk metadata carries the symbol name and the 1-based line range. Unknown
+// languages, and code with no recognisable declarations, fall back to
+// chunkContent.
+func (s *Service) chunkCode(content string, documentID uuid.UUID, language string) []Chunk {
+	syntax, ok := codeSyntaxes[strings.ToLower(strings.TrimSpace(language))]
+	if !ok {
+		return s.chunkContent(content, documentID)
+	}
+
+	lines := strings.Split(content, "\n")
+	segments := syntax.segments(lines)
+	if len(segments) == 0 {
+		return s.chunkContent(content, documentID)
+	}
+
+	var chunks []Chunk
+	for _, seg := range segments {
+		for start := seg.start; start < seg.end; start += maxCodeChunkLines {
+			end := min(start+maxCodeChunkLines, seg.end)
+
+			text := strings.Join(lines[start:end], "\n")
+			if strings.TrimSpace(text) == "" {
+				continue
+			}
+
+			metadata := map[string]interface{}{
+				"start_line": start + 1,
+				"end_line":   end,
+			}
+			if seg.symbol != "" {
+				metadata["symbol"] = seg.symbol
+			}
+
+			chunks = append(chunks, Chunk{
+				ID:         uuid.New(),
+				DocumentID: documentID,
+				Content:    text,
+				Metadata:   metadata,
+				Index:      len(chunks),
+			})
+		}
+	}
+
+	return chunks
+}
+
+// segments divides lines into the preamble and one segment per declaration.
+// Blank lines between declarations are trimmed. It returns nil if there are no
+// declarations.
+func (c *codeSyntax) segments(lines []string) []codeSegment {
+	var segments []codeSegment
+	for i, line := range lines {
+		m := c.declaration.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		// Pull in the doc comment and decorators directly above
+		start := i
+		for start > 0 && c.leading(lines[start-1]) {
+			start--
+		}
+
+		segments = append(segments, codeSegment{symbol: symbolName(m, line), start: start})
+	}
+	if len(segments) == 0 {
+		return nil
+	}
+
+	// Each declaration runs up to the next one
+	for i := range segments {
+		if i+1 < len(segments) {
+			segments[i].end = segments[i+1].start
+		} else {
+			segments[i].end = len(lines)
+		}
+	}
+	if segments[0].start > 0 {
+		segments = append([]codeSegment{{start: 0, end: segments[0].start}}, segments...)
+	}
+
+	for i := range segments {
+		for segments[i].end > segments[i].start && strings.TrimSpace(lines[segments[i].end-1]) == "" {
+			segments[i].end--
+		}
+	}
+
+	return segments
+}
+
+// goReceiver matches the receiver type of a Go method, e.g. Service in (s *Service)
+var goReceiver = regexp.MustCompile(`^func\s+\(\s*(?:\w+\s+)?\*?\s*(\w+)`)
+
+// symbolName returns the declared name from a declaration match. Go methods
+// are qualified with their receiver type, e.g. Service.Ingest.
+func symbolName(match []string, line string) string {
+	var name string
+	for _, group := range match[1:] {
+		if group != "" {
+			name = group
+			break
+		}
+	}
+	if name == "" {
+		return ""
+	}
+
+	if recv := goReceiver.FindStringSubmatch(line); recv != nil {
+		return recv[1] + "." + name
+	}
+	return name
+}
@@ -0,0 +1,119 @@
+package knowledge
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// =============================================================================
+// Hybrid Search
+// =============================================================================
+
+// rrfK dampens reciprocal rank fusion so a chunk ranked first by one signal
+// doesn't outweigh one ranked well by both. 60 is the usual choice.
+const rrfK = 60
+
+// KeywordSearcher is a VectorStore that can also rank chunks by how well they
+// match a query's terms. Hybrid queries need one.
+type KeywordSearcher interface {
+	// KeywordSearch returns the chunks matching query's terms, best first
+	KeywordSearch(ctx context.Context, kbID uuid.UUID, query string, limit int) ([]SearchResult, error)
+}
+
+// SignalScores are a hybrid result's score and rank from each search. A rank
+// of 0 means that search did not retrieve the chunk.
+type SignalScores struct {
+	Vector      float32 `json:"vector"`
+	VectorRank  int     `json:"vector_rank"`
+	Keyword     float32 `json:"keyword"`
+	KeywordRank int     `json:"keyword_rank"`
+}
+
+// fuseRankings merges vector and keyword results, each ranked best first,
+// by reciprocal rank fusion: a chunk scores the sum of 1/(rrfK+rank) over
+// the rankings it appears in.
+func fuseRankings(vector, keyword []SearchResult) []SearchResult {
+	fused := make([]SearchResult, 0, len(vector)+len(keyword))
+	index := make(map[uuid.UUID]int, len(vector)+len(keyword))
+	add := func(r SearchResult, rank int, isVector bool) {
+		score := r.Score
+		i, ok := index[r.ChunkID]
+		if !ok {
+			i = len(fused)
+			index[r.ChunkID] = i
+			r.Signals = &SignalScores{}
+			r.Score = 0
+			fused = append(fused, r)
+		}
+		signals := fused[i].Signals
+		if isVector {
+			signals.Vector, signals.VectorRank = score, rank
+		} else {
+			signals.Keyword, signals.KeywordRank = score, rank
+		}
+		fused[i].Score += 1 / float32(rrfK+rank)
+	}
+	for i, r := range vector {
+		add(r, i+1, true)
+	}
+	for i, r := range keyword {
+		add(r, i+1, false)
+	}
+
+	sort.SliceStable(fused, func(i, j int) bool {
+		return fused[i].Score > fused[j].Score
+	})
+	return fused
+}
+
+// searchTerms splits text into lowercase terms. Underscores are kept so
+// identifiers such as parse_config stay whole.
+func searchTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+}
+
+// KeywordSearch ranks the knowledge base's chunks by the share of the query's
+// distinct terms they contain. Chunks containing none are left out.
+func (s *MockVectorStore) KeywordSearch(ctx context.Context, kbID uuid.UUID, query string, limit int) ([]SearchResult, error) {
+	terms := make(map[string]bool)
+	for _, term := range searchTerms(query) {
+		terms[term] = true
+	}
+	if len(terms) == 0 || limit <= 0 {
+		return nil, nil
+	}
+
+	var results []SearchResult
+	for _, chunk := range s.chunks[kbID] {
+		matched := make(map[string]bool)
+		for _, term := range searchTerms(chunk.Content) {
+			if terms[term] {
+				matched[term] = true
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		results = append(results, SearchResult{
+			ChunkID:    chunk.ID,
+			DocumentID: chunk.DocumentID,
+			Content:    chunk.Content,
+			Score:      float32(len(matched)) / float32(len(terms)),
+			Metadata:   chunk.Metadata,
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
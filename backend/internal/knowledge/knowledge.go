@@ -4,13 +4,19 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"math"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/tracing"
 	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // =============================================================================
@@ -19,9 +25,12 @@ import (
 
 // Service handles knowledge base operations
 type Service struct {
-	vectorStore VectorStore
-	embedder    Embedder
-	log         *logger.Logger
+	vectorStore    VectorStore
+	embedder       Embedder
+	documents      DocumentStore
+	countTokens    TokenCounter
+	log            *logger.Logger
+	requestLogging bool
 }
 
 // NewService creates a new knowledge service
@@ -33,6 +42,39 @@ func NewService(vectorStore VectorStore, embedder Embedder, log *logger.Logger)
 	}
 }
 
+// SetRequestLogging enables detailed pipeline logging: chunk counts, embedding
+// latency and the chunks retrieved for each query with their scores. Logs carry
+// the request ID so a bad answer can be traced back to what was retrieved.
+func (s *Service) SetRequestLogging(enabled bool) {
+	s.requestLogging = enabled
+}
+
+// SetTokenCounter sets how token-based chunking counts tokens, typically a
+// provider's CountTokens. Without one, tokens are estimated at 4 characters each.
+func (s *Service) SetTokenCounter(counter TokenCounter) {
+	s.countTokens = counter
+}
+
+// SetDocumentStore sets where ingested documents are recorded. With one,
+// re-ingesting a source replaces its previous document, and re-ingesting
+// unchanged content is a no-op.
+func (s *Service) SetDocumentStore(documents DocumentStore) {
+	s.documents = documents
+}
+
+// RequestLogging reports whether detailed pipeline logging is enabled
+func (s *Service) RequestLogging() bool {
+	return s.requestLogging
+}
+
+// requestLog returns the logger tagged with the request ID of ctx, if any
+func (s *Service) requestLog(ctx context.Context) *logger.Logger {
+	if reqID := middleware.GetReqID(ctx); reqID != "" {
+		return s.log.WithRequestID(reqID)
+	}
+	return s.log
+}
+
 // VectorStore interface for vector database operations
 type VectorStore interface {
 	// Store stores chunks with embeddings
@@ -60,6 +102,19 @@ type Embedder interface {
 	Dimension() int
 }
 
+// DocumentStore records the documents ingested into knowledge bases
+type DocumentStore interface {
+	// GetDocumentBySource returns the newest document ingested from source,
+	// or nil if there is none
+	GetDocumentBySource(ctx context.Context, kbID uuid.UUID, source string) (*models.KnowledgeDocument, error)
+
+	// CreateDocument records an ingested document
+	CreateDocument(ctx context.Context, doc *models.KnowledgeDocument) error
+
+	// DeleteDocument removes a document's record
+	DeleteDocument(ctx context.Context, id uuid.UUID) error
+}
+
 // Chunk represents a text chunk with its embedding
 type Chunk struct {
 	ID         uuid.UUID
@@ -77,6 +132,10 @@ type SearchResult struct {
 	Content    string
 	Score      float32
 	Metadata   map[string]interface{}
+
+	// Signals are the vector and keyword scores a hybrid result's Score was
+	// fused from; nil for other queries
+	Signals *SignalScores
 }
 
 // =============================================================================
@@ -90,17 +149,107 @@ type IngestRequest struct {
 	SourceType      string // file, url, text, repository
 	Content         string
 	Metadata        map[string]interface{}
+	Chunking        ChunkOptions
+}
+
+// ChunkStrategy selects how documents are split into chunks
+type ChunkStrategy string
+
+const (
+	// ChunkByChars splits on character count. It is the default.
+	ChunkByChars ChunkStrategy = "char"
+
+	// ChunkByTokens splits on estimated token count, keeping chunks within the
+	// embedding model's limits regardless of how dense the text is
+	ChunkByTokens ChunkStrategy = "token"
+
+	// ChunkByCode splits source code on top-level declarations, keeping each
+	// function, type or class whole. ChunkOptions.Language selects the syntax.
+	ChunkByCode ChunkStrategy = "code"
+)
+
+// Token chunking defaults
+const (
+	defaultChunkMaxTokens     = 512
+	defaultChunkOverlapTokens = 64
+)
+
+// ChunkOptions controls chunking. MaxTokens and OverlapTokens apply to
+// ChunkByTokens. MaxTokens defaults to 512; OverlapTokens defaults to 64 or a
+// quarter of MaxTokens, whichever is less, and a negative value disables overlap.
+// Language applies to ChunkByCode; Go, TypeScript and Python are understood and
+// anything else is chunked as prose.
+type ChunkOptions struct {
+	Strategy      ChunkStrategy
+	MaxTokens     int
+	OverlapTokens int
+	Language      string
+}
+
+// Validate checks that the options name a known strategy and, for token
+// chunking, leave room for more than the overlap in each chunk
+func (o ChunkOptions) Validate() error {
+	switch o.Strategy {
+	case "", ChunkByChars, ChunkByCode:
+		return nil
+	case ChunkByTokens:
+		_, _, err := o.tokenSizes()
+		return err
+	default:
+		return fmt.Errorf("unknown chunking strategy: %s", o.Strategy)
+	}
 }
 
+// tokenSizes returns the chunk size and overlap for ChunkByTokens, defaults
+// applied
+func (o ChunkOptions) tokenSizes() (maxTokens, overlapTokens int, err error) {
+	maxTokens = o.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultChunkMaxTokens
+	}
+	overlapTokens = o.OverlapTokens
+	if overlapTokens == 0 {
+		overlapTokens = min(defaultChunkOverlapTokens, maxTokens/4)
+	}
+	if overlapTokens < 0 {
+		overlapTokens = 0
+	}
+	if overlapTokens >= maxTokens {
+		return 0, 0, fmt.Errorf("overlap of %d tokens must be less than the %d token chunk size", overlapTokens, maxTokens)
+	}
+	return maxTokens, overlapTokens, nil
+}
+
+// TokenCounter counts the tokens in a text
+type TokenCounter func(text string) (int, error)
+
+// IngestOutcome reports what ingesting a document changed
+type IngestOutcome string
+
+const (
+	// IngestCreated is a source ingested for the first time
+	IngestCreated IngestOutcome = "created"
+
+	// IngestUpdated is a source whose previous document was replaced
+	IngestUpdated IngestOutcome = "updated"
+
+	// IngestUnchanged is a source whose content was already ingested. Nothing
+	// was chunked, embedded or stored.
+	IngestUnchanged IngestOutcome = "unchanged"
+)
+
 // IngestResult represents the result of document ingestion
 type IngestResult struct {
 	DocumentID  uuid.UUID
 	ChunkCount  int
 	ContentHash string
+	Outcome     IngestOutcome
 	Duration    time.Duration
 }
 
-// Ingest ingests a document into the knowledge base
+// Ingest ingests a document into the knowledge base. With a document store,
+// a source whose content hash matches its recorded document is skipped, and
+// one whose content changed replaces its previous document.
 func (s *Service) Ingest(ctx context.Context, req *IngestRequest) (*IngestResult, error) {
 	start := time.Now()
 
@@ -108,11 +257,43 @@ func (s *Service) Ingest(ctx context.Context, req *IngestRequest) (*IngestResult
 	hash := sha256.Sum256([]byte(req.Content))
 	contentHash := hex.EncodeToString(hash[:])
 
+	var existing *models.KnowledgeDocument
+	if s.documents != nil {
+		var err error
+		existing, err = s.documents.GetDocumentBySource(ctx, req.KnowledgeBaseID, req.Source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get existing document: %w", err)
+		}
+		if existing != nil && existing.ContentHash == contentHash {
+			return &IngestResult{
+				DocumentID:  existing.ID,
+				ChunkCount:  existing.ChunkCount,
+				ContentHash: contentHash,
+				Outcome:     IngestUnchanged,
+				Duration:    time.Since(start),
+			}, nil
+		}
+	}
+
 	// Create document record
 	documentID := uuid.New()
 
 	// Chunk the content
-	chunks := s.chunkContent(req.Content, documentID)
+	var chunks []Chunk
+	switch req.Chunking.Strategy {
+	case "", ChunkByChars:
+		chunks = s.chunkContent(req.Content, documentID)
+	case ChunkByTokens:
+		var err error
+		chunks, err = s.chunkByTokens(req.Content, documentID, req.Chunking)
+		if err != nil {
+			return nil, fmt.Errorf("failed to chunk content: %w", err)
+		}
+	case ChunkByCode:
+		chunks = s.chunkCode(req.Content, documentID, req.Chunking.Language)
+	default:
+		return nil, fmt.Errorf("unknown chunking strategy: %s", req.Chunking.Strategy)
+	}
 
 	s.log.Infow("chunking complete", 
 		"document_id", documentID, 
@@ -125,30 +306,120 @@ func (s *Service) Ingest(ctx context.Context, req *IngestRequest) (*IngestResult
 		texts[i] = chunk.Content
 	}
 
+	embedStart := time.Now()
 	embeddings, err := s.embedder.EmbedBatch(ctx, texts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate embeddings: %w", err)
 	}
+	embedLatency := time.Since(embedStart)
 
-	// Attach embeddings to chunks
+	// Attach embeddings to chunks, adding the document's metadata to any the
+	// chunker set
 	for i := range chunks {
 		chunks[i].Embedding = embeddings[i]
-		chunks[i].Metadata = req.Metadata
+		chunks[i].Metadata = mergeMetadata(req.Metadata, chunks[i].Metadata)
+	}
+
+	// The document is recorded before its chunks, which reference it
+	if s.documents != nil {
+		if err := s.recordDocument(ctx, req, documentID, contentHash, len(chunks)); err != nil {
+			return nil, err
+		}
 	}
 
 	// Store chunks
 	if err := s.vectorStore.StoreChunks(ctx, req.KnowledgeBaseID, chunks); err != nil {
+		if s.documents != nil {
+			if delErr := s.documents.DeleteDocument(ctx, documentID); delErr != nil {
+				s.log.Warnw("failed to remove document without chunks", "document_id", documentID, "error", delErr)
+			}
+		}
 		return nil, fmt.Errorf("failed to store chunks: %w", err)
 	}
 
+	outcome := IngestCreated
+	if s.documents != nil {
+		// The new version is searchable before the old one is removed
+		if existing != nil {
+			outcome = IngestUpdated
+			if err := s.vectorStore.DeleteDocument(ctx, existing.ID); err != nil {
+				return nil, fmt.Errorf("failed to delete previous chunks: %w", err)
+			}
+			if err := s.documents.DeleteDocument(ctx, existing.ID); err != nil {
+				return nil, fmt.Errorf("failed to delete previous document: %w", err)
+			}
+		}
+	}
+
+	if s.requestLogging {
+		chunkIDs := make([]string, len(chunks))
+		totalChars := 0
+		for i, chunk := range chunks {
+			chunkIDs[i] = chunk.ID.String()
+			totalChars += len(chunk.Content)
+		}
+		s.requestLog(ctx).Infow("knowledge ingest",
+			"kb_id", req.KnowledgeBaseID,
+			"document_id", documentID,
+			"source", req.Source,
+			"source_type", req.SourceType,
+			"content_hash", contentHash,
+			"outcome", outcome,
+			"chunk_count", len(chunks),
+			"chunk_chars", totalChars,
+			"chunk_ids", chunkIDs,
+			"embedding_dimension", s.embedder.Dimension(),
+			"embedding_ms", embedLatency.Milliseconds(),
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+	}
+
 	return &IngestResult{
 		DocumentID:  documentID,
 		ChunkCount:  len(chunks),
 		ContentHash: contentHash,
+		Outcome:     outcome,
 		Duration:    time.Since(start),
 	}, nil
 }
 
+// recordDocument records an ingested document in the document store
+func (s *Service) recordDocument(ctx context.Context, req *IngestRequest, documentID uuid.UUID, contentHash string, chunkCount int) error {
+	metadata, _ := json.Marshal(req.Metadata)
+	now := time.Now()
+	doc := &models.KnowledgeDocument{
+		ID:              documentID,
+		KnowledgeBaseID: req.KnowledgeBaseID,
+		Source:          req.Source,
+		SourceType:      req.SourceType,
+		ContentHash:     contentHash,
+		Metadata:        metadata,
+		ChunkCount:      chunkCount,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if err := s.documents.CreateDocument(ctx, doc); err != nil {
+		return fmt.Errorf("failed to record document: %w", err)
+	}
+	return nil
+}
+
+// mergeMetadata returns document metadata overlaid with chunk metadata. The
+// document's map is shared by every chunk, so it is copied rather than modified.
+func mergeMetadata(document, chunk map[string]interface{}) map[string]interface{} {
+	if len(chunk) == 0 {
+		return document
+	}
+	merged := make(map[string]interface{}, len(document)+len(chunk))
+	for k, v := range document {
+		merged[k] = v
+	}
+	for k, v := range chunk {
+		merged[k] = v
+	}
+	return merged
+}
+
 // chunkContent splits content into chunks with overlap
 func (s *Service) chunkContent(content string, documentID uuid.UUID) []Chunk {
 	const (
@@ -208,16 +479,178 @@ func (s *Service) chunkContent(content string, documentID uuid.UUID) []Chunk {
 	return chunks
 }
 
+// chunkByTokens splits content into chunks of at most opts.MaxTokens estimated
+// tokens. Chunks end on paragraph boundaries where possible; a paragraph too
+// long for one chunk is split between words. Each chunk after the first starts
+// with up to opts.OverlapTokens of the previous chunk's trailing words.
+func (s *Service) chunkByTokens(content string, documentID uuid.UUID, opts ChunkOptions) ([]Chunk, error) {
+	maxTokens, overlapTokens, err := opts.tokenSizes()
+	if err != nil {
+		return nil, err
+	}
+
+	// Break the content into pieces that each fit in a chunk: whole paragraphs,
+	// or runs of words from paragraphs that are too long
+	type piece struct {
+		text   string
+		tokens int
+	}
+	var pieces []piece
+	for _, para := range strings.Split(content, "\n\n") {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+
+		tokens, err := s.tokens(para)
+		if err != nil {
+			return nil, err
+		}
+		if tokens <= maxTokens {
+			pieces = append(pieces, piece{para, tokens})
+			continue
+		}
+
+		// Leave room for the overlap carried into each chunk
+		runs, err := s.splitWords(strings.Fields(para), maxTokens-overlapTokens)
+		if err != nil {
+			return nil, err
+		}
+		for _, run := range runs {
+			pieces = append(pieces, piece{run.text, run.tokens})
+		}
+	}
+
+	var chunks []Chunk
+	var current []string
+	currentTokens := 0
+
+	emit := func() {
+		chunks = append(chunks, Chunk{
+			ID:         uuid.New(),
+			DocumentID: documentID,
+			Content:    strings.Join(current, "\n\n"),
+			Index:      len(chunks),
+		})
+	}
+
+	for _, p := range pieces {
+		if currentTokens+p.tokens > maxTokens && len(current) > 0 {
+			emit()
+
+			overlap, tokens, err := s.trailingWords(current[len(current)-1], overlapTokens)
+			if err != nil {
+				return nil, err
+			}
+			current, currentTokens = nil, 0
+			if overlap != "" && tokens+p.tokens <= maxTokens {
+				current, currentTokens = []string{overlap}, tokens
+			}
+		}
+
+		current = append(current, p.text)
+		currentTokens += p.tokens
+	}
+	if len(current) > 0 {
+		emit()
+	}
+
+	return chunks, nil
+}
+
+// wordRun is a run of whole words and its token count
+type wordRun struct {
+	text   string
+	tokens int
+}
+
+// splitWords groups words into runs of at most maxTokens. A single word longer
+// than maxTokens becomes a run of its own rather than being cut.
+func (s *Service) splitWords(words []string, maxTokens int) ([]wordRun, error) {
+	var runs []wordRun
+	var run []string
+	runTokens := 0
+
+	for _, word := range words {
+		tokens, err := s.wordTokens(word)
+		if err != nil {
+			return nil, err
+		}
+		if runTokens+tokens > maxTokens && len(run) > 0 {
+			runs = append(runs, wordRun{strings.Join(run, " "), runTokens})
+			run, runTokens = nil, 0
+		}
+		run = append(run, word)
+		runTokens += tokens
+	}
+	if len(run) > 0 {
+		runs = append(runs, wordRun{strings.Join(run, " "), runTokens})
+	}
+	return runs, nil
+}
+
+// trailingWords returns the longest run of whole words from the end of text
+// that fits in maxTokens
+func (s *Service) trailingWords(text string, maxTokens int) (string, int, error) {
+	if maxTokens <= 0 {
+		return "", 0, nil
+	}
+
+	words := strings.Fields(text)
+	start, total := len(words), 0
+	for start > 0 {
+		tokens, err := s.wordTokens(words[start-1])
+		if err != nil {
+			return "", 0, err
+		}
+		if total+tokens > maxTokens {
+			break
+		}
+		total += tokens
+		start--
+	}
+	return strings.Join(words[start:], " "), total, nil
+}
+
+// tokens counts the tokens in text with the configured counter
+func (s *Service) tokens(text string) (int, error) {
+	if s.countTokens == nil {
+		return (len(text) + 3) / 4, nil
+	}
+	n, err := s.countTokens(text)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count tokens: %w", err)
+	}
+	return n, nil
+}
+
+// wordTokens counts a word as at least one token, since estimators round short words down to zero
+func (s *Service) wordTokens(word string) (int, error) {
+	n, err := s.tokens(word)
+	if err != nil {
+		return 0, err
+	}
+	return max(n, 1), nil
+}
+
 // =============================================================================
 // Querying
 // =============================================================================
 
-// QueryRequest represents a query request
+// QueryRequest represents a query request. Results are ranked globally across
+// all KnowledgeBaseIDs by score; chunks below MinScore are dropped before the
+// top Limit are taken.
+//
+// HybridSearch also ranks chunks by keyword match, so exact terms such as
+// function names and error codes are found, and fuses the two rankings by
+// reciprocal rank fusion. Scores are then fused scores; MinScore still applies
+// to vector similarity, and keyword matches are kept regardless.
 type QueryRequest struct {
 	KnowledgeBaseIDs []uuid.UUID
 	Query            string
 	Limit            int
 	MinScore         float32
+	HybridSearch     bool
 }
 
 // QueryResult represents query results
@@ -226,31 +659,54 @@ type QueryResult struct {
 	Duration time.Duration
 }
 
-// Query searches the knowledge base
-func (s *Service) Query(ctx context.Context, req *QueryRequest) (*QueryResult, error) {
+// Query searches the requested knowledge bases and returns the highest scoring
+// chunks among all of them
+func (s *Service) Query(ctx context.Context, req *QueryRequest) (_ *QueryResult, err error) {
+	ctx, span := tracing.Start(ctx, "knowledge.query",
+		attribute.Int("delphi.knowledge.bases", len(req.KnowledgeBaseIDs)),
+		attribute.Int("delphi.knowledge.limit", req.Limit),
+		attribute.Bool("delphi.knowledge.hybrid", req.HybridSearch),
+	)
+	defer func() { tracing.End(span, err) }()
+
 	start := time.Now()
 
+	var keywords KeywordSearcher
+	if req.HybridSearch {
+		var ok bool
+		if keywords, ok = s.vectorStore.(KeywordSearcher); !ok {
+			return nil, fmt.Errorf("hybrid search is not supported by the vector store")
+		}
+	}
+
 	// Generate embedding for query
+	embedStart := time.Now()
 	embedding, err := s.embedder.Embed(ctx, req.Query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to embed query: %w", err)
 	}
+	embedLatency := time.Since(embedStart)
 
 	limit := req.Limit
 	if limit <= 0 {
 		limit = 10
 	}
 
-	// Search each knowledge base
+	// Search each knowledge base, remembering each chunk's rank within its own base
 	var allResults []SearchResult
+	sourceRank := make(map[uuid.UUID]int)
 	for _, kbID := range req.KnowledgeBaseIDs {
 		results, err := s.vectorStore.Search(ctx, kbID, embedding, limit)
 		if err != nil {
 			s.log.Warnw("search failed for knowledge base", "kb_id", kbID, "error", err)
 			continue
 		}
+		for i, r := range results {
+			sourceRank[r.ChunkID] = i + 1
+		}
 		allResults = append(allResults, results...)
 	}
+	retrieved := len(allResults)
 
 	// Filter by minimum score
 	if req.MinScore > 0 {
@@ -263,18 +719,94 @@ func (s *Service) Query(ctx context.Context, req *QueryRequest) (*QueryResult, e
 		allResults = filtered
 	}
 
-	// Sort by score and limit
-	// (In production, use a proper sorting algorithm)
+	belowMinScore := retrieved - len(allResults)
+
+	// Rank across all knowledge bases before limiting, so the best chunks of a
+	// later base are not dropped in favour of weaker ones from an earlier base
+	sort.SliceStable(allResults, func(i, j int) bool {
+		return allResults[i].Score > allResults[j].Score
+	})
+	if keywords != nil {
+		keywordResults := s.keywordSearch(ctx, keywords, req, limit)
+		retrieved += len(keywordResults)
+		allResults = fuseRankings(allResults, keywordResults)
+	}
 	if len(allResults) > limit {
 		allResults = allResults[:limit]
 	}
 
+	span.SetAttributes(
+		attribute.Int("delphi.knowledge.retrieved", retrieved),
+		attribute.Int("delphi.knowledge.results", len(allResults)),
+	)
+
+	if s.requestLogging {
+		s.logQuery(ctx, req, limit, retrieved, belowMinScore, allResults, sourceRank, embedLatency, time.Since(start))
+	}
+
 	return &QueryResult{
 		Results:  allResults,
 		Duration: time.Since(start),
 	}, nil
 }
 
+// keywordSearch searches each knowledge base by keyword and ranks the matches
+// across all of them
+func (s *Service) keywordSearch(ctx context.Context, keywords KeywordSearcher, req *QueryRequest, limit int) []SearchResult {
+	var results []SearchResult
+	for _, kbID := range req.KnowledgeBaseIDs {
+		matches, err := keywords.KeywordSearch(ctx, kbID, req.Query, limit)
+		if err != nil {
+			s.log.Warnw("keyword search failed for knowledge base", "kb_id", kbID, "error", err)
+			continue
+		}
+		results = append(results, matches...)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	return results
+}
+
+// logQuery records what a query retrieved. rank_delta is how far each chunk moved
+// between its rank in its own knowledge base and its rank in the merged results.
+func (s *Service) logQuery(ctx context.Context, req *QueryRequest, limit, retrieved, belowMinScore int, results []SearchResult, sourceRank map[uuid.UUID]int, embedLatency, duration time.Duration) {
+	type loggedResult struct {
+		ChunkID    string  `json:"chunk_id"`
+		DocumentID string  `json:"document_id"`
+		Score      float32       `json:"score"`
+		Rank       int           `json:"rank"`
+		RankDelta  int           `json:"rank_delta"`
+		Signals    *SignalScores `json:"signals,omitempty"`
+	}
+
+	logged := make([]loggedResult, len(results))
+	for i, r := range results {
+		logged[i] = loggedResult{
+			ChunkID:    r.ChunkID.String(),
+			DocumentID: r.DocumentID.String(),
+			Score:      r.Score,
+			Rank:       i + 1,
+			RankDelta:  sourceRank[r.ChunkID] - (i + 1),
+			Signals:    r.Signals,
+		}
+	}
+
+	s.requestLog(ctx).Infow("knowledge query",
+		"kb_ids", req.KnowledgeBaseIDs,
+		"query_chars", len(req.Query),
+		"limit", limit,
+		"min_score", req.MinScore,
+		"hybrid", req.HybridSearch,
+		"retrieved", retrieved,
+		"below_min_score", belowMinScore,
+		"returned", len(results),
+		"results", logged,
+		"embedding_ms", embedLatency.Milliseconds(),
+		"duration_ms", duration.Milliseconds(),
+	)
+}
+
 // =============================================================================
 // Repository Indexing
 // =============================================================================
@@ -315,25 +847,7 @@ func (i *RepositoryIndexer) IndexRepository(ctx context.Context, req *IndexRepos
 	)
 
 	for _, file := range req.Files {
-		// Skip binary or very large files
-		if len(file.Content) > 100000 {
-			continue
-		}
-
-		metadata := map[string]interface{}{
-			"path":       file.Path,
-			"language":   file.Language,
-			"repository": req.Repository.FullName,
-		}
-
-		_, err := i.service.Ingest(ctx, &IngestRequest{
-			KnowledgeBaseID: req.KnowledgeBaseID,
-			Source:          file.Path,
-			SourceType:      "repository",
-			Content:         file.Content,
-			Metadata:        metadata,
-		})
-		if err != nil {
+		if _, err := i.IndexFile(ctx, req.KnowledgeBaseID, req.Repository, file); err != nil {
 			i.log.Warnw("failed to index file", "path", file.Path, "error", err)
 			continue
 		}
@@ -343,6 +857,61 @@ func (i *RepositoryIndexer) IndexRepository(ctx context.Context, req *IndexRepos
 	return nil
 }
 
+// maxIndexedFileSize is the largest file the indexer ingests, in bytes
+const maxIndexedFileSize = 100000
+
+// ErrFileTooLarge is returned for files too large to index
+var ErrFileTooLarge = fmt.Errorf("file exceeds %d bytes", maxIndexedFileSize)
+
+// IndexFile ingests one repository file as a document. The document's source
+// is RepositorySource(repo, file.Path), so a later version of the file can
+// replace it.
+func (i *RepositoryIndexer) IndexFile(ctx context.Context, kbID uuid.UUID, repo *models.Repository, file RepositoryFile) (*IngestResult, error) {
+	if len(file.Content) > maxIndexedFileSize {
+		return nil, ErrFileTooLarge
+	}
+
+	metadata := map[string]interface{}{
+		"path":       file.Path,
+		"language":   file.Language,
+		"repository": repo.FullName,
+	}
+
+	return i.service.Ingest(ctx, &IngestRequest{
+		KnowledgeBaseID: kbID,
+		Source:          RepositorySource(repo, file.Path),
+		SourceType:      "repository",
+		Content:         file.Content,
+		Metadata:        metadata,
+		Chunking: ChunkOptions{
+			Strategy: ChunkByCode,
+			Language: file.Language,
+		},
+	})
+}
+
+// RepositorySource is the document source of a repository file. It includes
+// the repository so several repositories can share a knowledge base.
+func RepositorySource(repo *models.Repository, path string) string {
+	return repo.FullName + "/" + path
+}
+
+// DeleteDocument removes a document's chunks from the knowledge base
+func (s *Service) DeleteDocument(ctx context.Context, documentID uuid.UUID) error {
+	if err := s.vectorStore.DeleteDocument(ctx, documentID); err != nil {
+		return fmt.Errorf("failed to delete chunks: %w", err)
+	}
+	return nil
+}
+
+// DeleteKnowledgeBase removes all of a knowledge base's chunks
+func (s *Service) DeleteKnowledgeBase(ctx context.Context, kbID uuid.UUID) error {
+	if err := s.vectorStore.DeleteKnowledgeBase(ctx, kbID); err != nil {
+		return fmt.Errorf("failed to delete chunks: %w", err)
+	}
+	return nil
+}
+
 // =============================================================================
 // Mock Implementations for Development
 // =============================================================================
@@ -364,30 +933,58 @@ func (s *MockVectorStore) StoreChunks(ctx context.Context, kbID uuid.UUID, chunk
 	return nil
 }
 
+// Search ranks the knowledge base's chunks by cosine similarity to the query
+// embedding. Chunks whose embedding has a different dimension from the query
+// are skipped, and a zero vector scores 0 against everything.
 func (s *MockVectorStore) Search(ctx context.Context, kbID uuid.UUID, embedding []float32, limit int) ([]SearchResult, error) {
 	chunks := s.chunks[kbID]
-	if len(chunks) == 0 {
+	if len(chunks) == 0 || limit <= 0 {
 		return nil, nil
 	}
 
-	// Simple mock search - return first N chunks
-	var results []SearchResult
-	for i, chunk := range chunks {
-		if i >= limit {
-			break
+	results := make([]SearchResult, 0, len(chunks))
+	for _, chunk := range chunks {
+		if len(chunk.Embedding) != len(embedding) {
+			continue
 		}
 		results = append(results, SearchResult{
 			ChunkID:    chunk.ID,
 			DocumentID: chunk.DocumentID,
 			Content:    chunk.Content,
-			Score:      0.9 - float32(i)*0.1,
+			Score:      CosineSimilarity(embedding, chunk.Embedding),
 			Metadata:   chunk.Metadata,
 		})
 	}
 
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
 	return results, nil
 }
 
+// CosineSimilarity returns the cosine of the angle between a and b, from -1 to 1.
+// It returns 0 when the vectors differ in length or either is a zero vector.
+func CosineSimilarity(a, b []float32) float32 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB)))
+}
+
 func (s *MockVectorStore) DeleteDocument(ctx context.Context, documentID uuid.UUID) error {
 	for kbID, chunks := range s.chunks {
 		var filtered []Chunk
@@ -0,0 +1,169 @@
+package knowledge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// Ollama Embedder
+// =============================================================================
+
+// ollamaEmbeddingConcurrency is how many embeddings requests EmbedBatch sends
+// to the Ollama server at once. Ollama embeds one prompt per request and
+// queues the rest, so a few in flight keep it busy without piling up.
+const ollamaEmbeddingConcurrency = 4
+
+// ErrEmbedderUnreachable is returned when the embedding server can't be
+// reached, such as an Ollama server that isn't running
+var ErrEmbedderUnreachable = errors.New("embedding server not reachable")
+
+// OllamaEmbedder generates embeddings with a local Ollama server, so
+// knowledge bases work without sending documents to a hosted API
+type OllamaEmbedder struct {
+	baseURL    string
+	model      string
+	httpClient *http.Client
+	dimension  int
+}
+
+type ollamaEmbeddingRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+}
+
+type ollamaEmbeddingResponse struct {
+	Embedding []float32 `json:"embedding"`
+}
+
+// NewOllamaEmbedder creates an embedder for an embedding model pulled on the
+// Ollama server at baseURL, such as nomic-embed-text. The model's dimension
+// is found by embedding a sample text, so the server must be up.
+func NewOllamaEmbedder(ctx context.Context, baseURL, model string) (*OllamaEmbedder, error) {
+	if baseURL == "" {
+		baseURL = "http://localhost:11434"
+	}
+	if model == "" {
+		model = "nomic-embed-text"
+	}
+
+	e := &OllamaEmbedder{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		model:   model,
+		httpClient: &http.Client{
+			Timeout: 2 * time.Minute, // The first request loads the model
+		},
+	}
+
+	sample, err := e.Embed(ctx, "dimension probe")
+	if err != nil {
+		return nil, err
+	}
+	if len(sample) == 0 {
+		return nil, fmt.Errorf("ollama returned an empty embedding for %s; is it an embedding model?", model)
+	}
+	e.dimension = len(sample)
+	return e, nil
+}
+
+// Embed generates the embedding of a single text
+func (e *OllamaEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	body, err := json.Marshal(ollamaEmbeddingRequest{Model: e.model, Prompt: text})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.baseURL+"/api/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("%w: ollama at %s: %v", ErrEmbedderUnreachable, e.baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("ollama embedding model %s not found; pull it with `ollama pull %s`", e.model, e.model)
+		}
+		return nil, fmt.Errorf("ollama embeddings error: %d - %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var embeddingResp ollamaEmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embeddingResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if e.dimension > 0 && len(embeddingResp.Embedding) != e.dimension {
+		return nil, fmt.Errorf("ollama returned a %d-dimensional embedding, expected %d", len(embeddingResp.Embedding), e.dimension)
+	}
+	return embeddingResp.Embedding, nil
+}
+
+// EmbedBatch generates embeddings for texts, a few requests at a time. The
+// first error stops the remaining requests.
+func (e *OllamaEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	embeddings := make([][]float32, len(texts))
+	slots := make(chan struct{}, ollamaEmbeddingConcurrency)
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for i, text := range texts {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, text string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			embedding, err := e.Embed(ctx, text)
+			if err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			embeddings[i] = embedding
+		}(i, text)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return embeddings, nil
+}
+
+// Dimension returns the size of the vectors this embedder produces
+func (e *OllamaEmbedder) Dimension() int {
+	return e.dimension
+}
@@ -0,0 +1,125 @@
+package knowledge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// =============================================================================
+// OpenAI Embedder
+// =============================================================================
+
+// maxEmbeddingBatch is the most inputs OpenAI accepts in one embeddings request
+const maxEmbeddingBatch = 2048
+
+// openAIEmbeddingDimensions are the native dimensions of the supported models
+var openAIEmbeddingDimensions = map[string]int{
+	"text-embedding-3-small": 1536,
+	"text-embedding-3-large": 3072,
+	"text-embedding-ada-002": 1536,
+}
+
+// ErrEmbeddingRateLimited is returned when the embedding provider rejects a
+// request with a rate limit. Callers can retry after backing off.
+var ErrEmbeddingRateLimited = errors.New("embedding rate limit exceeded")
+
+// OpenAIEmbedder generates embeddings with the OpenAI embeddings API
+type OpenAIEmbedder struct {
+	client     *openai.Client
+	model      string
+	dimensions int // requested size; 0 uses the model's native size
+}
+
+// NewOpenAIEmbedder creates an embedder for a text-embedding model. dimensions
+// shortens text-embedding-3 vectors to that size; 0 keeps the model's native size.
+func NewOpenAIEmbedder(apiKey, model string, dimensions int) (*OpenAIEmbedder, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("OpenAI API key is required")
+	}
+	if model == "" {
+		model = "text-embedding-3-small"
+	}
+
+	native, ok := openAIEmbeddingDimensions[model]
+	if !ok {
+		return nil, fmt.Errorf("unsupported embedding model: %s", model)
+	}
+	if dimensions < 0 || dimensions > native {
+		return nil, fmt.Errorf("%s supports at most %d dimensions", model, native)
+	}
+	if dimensions > 0 && dimensions != native && model == "text-embedding-ada-002" {
+		return nil, fmt.Errorf("%s does not support custom dimensions", model)
+	}
+
+	return &OpenAIEmbedder{
+		client:     openai.NewClient(apiKey),
+		model:      model,
+		dimensions: dimensions,
+	}, nil
+}
+
+// Embed generates the embedding of a single text
+func (e *OpenAIEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	embeddings, err := e.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// EmbedBatch generates embeddings for texts, splitting them into as many
+// requests as the API's batch limit requires
+func (e *OpenAIEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += maxEmbeddingBatch {
+		end := min(start+maxEmbeddingBatch, len(texts))
+
+		batch, err := e.embed(ctx, texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		embeddings = append(embeddings, batch...)
+	}
+	return embeddings, nil
+}
+
+func (e *OpenAIEmbedder) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	resp, err := e.client.CreateEmbeddings(ctx, openai.EmbeddingRequestStrings{
+		Input:      texts,
+		Model:      openai.EmbeddingModel(e.model),
+		Dimensions: e.dimensions,
+	})
+	if err != nil {
+		var apiErr *openai.APIError
+		if errors.As(err, &apiErr) && apiErr.HTTPStatusCode == http.StatusTooManyRequests {
+			return nil, fmt.Errorf("%w: %s", ErrEmbeddingRateLimited, apiErr.Message)
+		}
+		return nil, fmt.Errorf("OpenAI embeddings request failed: %w", err)
+	}
+
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("OpenAI returned %d embeddings for %d inputs", len(resp.Data), len(texts))
+	}
+
+	// The response carries each input's index; don't rely on its order
+	embeddings := make([][]float32, len(texts))
+	for _, data := range resp.Data {
+		if data.Index < 0 || data.Index >= len(texts) {
+			return nil, fmt.Errorf("OpenAI returned an embedding for unknown input %d", data.Index)
+		}
+		embeddings[data.Index] = data.Embedding
+	}
+	return embeddings, nil
+}
+
+// Dimension returns the size of the vectors this embedder produces
+func (e *OpenAIEmbedder) Dimension() int {
+	if e.dimensions > 0 {
+		return e.dimensions
+	}
+	return openAIEmbeddingDimensions[e.model]
+}
@@ -0,0 +1,193 @@
+package knowledge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// =============================================================================
+// pgvector Store
+// =============================================================================
+
+// PgVectorStore stores chunk embeddings in Postgres with pgvector and searches
+// them by cosine distance. Embeddings of one dimension are stored and searched;
+// it must match the embedder's. Chunks belong to a document recorded in
+// knowledge_documents and are deleted with it.
+type PgVectorStore struct {
+	db        *repository.PostgresDB
+	dimension int
+}
+
+// NewPgVectorStore creates a pgvector store for embeddings of the given
+// dimension and makes sure the similarity index for it exists
+func NewPgVectorStore(ctx context.Context, db *repository.PostgresDB, dimension int) (*PgVectorStore, error) {
+	if dimension <= 0 {
+		return nil, fmt.Errorf("embedding dimension must be positive")
+	}
+
+	s := &PgVectorStore{db: db, dimension: dimension}
+
+	// The embedding column has no fixed dimension, so the index is built on a
+	// cast to this store's dimension. Search repeats the cast and predicate so
+	// the planner can use it. HNSW rather than IVFFlat, whose lists are fixed
+	// from the rows present when it's built and this runs on an empty table.
+	query := fmt.Sprintf(`
+		CREATE INDEX IF NOT EXISTS idx_knowledge_vectors_hnsw_%[1]d ON knowledge_vectors
+		USING hnsw ((embedding::vector(%[1]d)) vector_cosine_ops)
+		WHERE vector_dims(embedding) = %[1]d
+	`, dimension)
+	if _, err := db.Pool().Exec(ctx, query); err != nil {
+		return nil, fmt.Errorf("failed to create vector index: %w", err)
+	}
+
+	return s, nil
+}
+
+// StoreChunks inserts chunks and their embeddings in one transaction
+func (s *PgVectorStore) StoreChunks(ctx context.Context, kbID uuid.UUID, chunks []Chunk) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO knowledge_vectors (id, knowledge_base_id, document_id, chunk_index, content, metadata, embedding)
+		VALUES ($1, $2, $3, $4, $5, $6, $7::vector)
+	`
+
+	batch := &pgx.Batch{}
+	for _, chunk := range chunks {
+		if len(chunk.Embedding) != s.dimension {
+			return fmt.Errorf("chunk %d has %d dimensions, store expects %d", chunk.Index, len(chunk.Embedding), s.dimension)
+		}
+
+		metadata := []byte("{}")
+		if chunk.Metadata != nil {
+			var err error
+			if metadata, err = json.Marshal(chunk.Metadata); err != nil {
+				return fmt.Errorf("failed to marshal chunk metadata: %w", err)
+			}
+		}
+
+		batch.Queue(query, chunk.ID, kbID, chunk.DocumentID, chunk.Index, chunk.Content, metadata, formatVector(chunk.Embedding))
+	}
+
+	tx, err := s.db.Pool().Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to insert chunks: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// Search returns the chunks nearest to embedding by cosine distance. Scores
+// are cosine similarities, 1 - distance.
+func (s *PgVectorStore) Search(ctx context.Context, kbID uuid.UUID, embedding []float32, limit int) ([]SearchResult, error) {
+	if len(embedding) != s.dimension {
+		return nil, fmt.Errorf("query has %d dimensions, store expects %d", len(embedding), s.dimension)
+	}
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, document_id, content, metadata, 1 - (embedding::vector(%[1]d) <=> $2::vector(%[1]d)) AS score
+		FROM knowledge_vectors
+		WHERE knowledge_base_id = $1 AND vector_dims(embedding) = %[1]d
+		ORDER BY embedding::vector(%[1]d) <=> $2::vector(%[1]d)
+		LIMIT $3
+	`, s.dimension)
+
+	rows, err := s.db.Pool().Query(ctx, query, kbID, formatVector(embedding), limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanSearchResults(rows)
+}
+
+// scanSearchResults reads rows of id, document_id, content, metadata and score
+func scanSearchResults(rows pgx.Rows) ([]SearchResult, error) {
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var r SearchResult
+		var metadata []byte
+		var score float64
+		if err := rows.Scan(&r.ChunkID, &r.DocumentID, &r.Content, &metadata, &score); err != nil {
+			return nil, err
+		}
+		if len(metadata) > 0 {
+			if err := json.Unmarshal(metadata, &r.Metadata); err != nil {
+				return nil, fmt.Errorf("failed to parse chunk metadata: %w", err)
+			}
+		}
+		r.Score = float32(score)
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// KeywordSearch returns the chunks matching any of query's terms, ranked by
+// full-text cover density. Terms are matched unstemmed, so identifiers and
+// error codes must match exactly. Scores are from 0 to 1.
+func (s *PgVectorStore) KeywordSearch(ctx context.Context, kbID uuid.UUID, query string, limit int) ([]SearchResult, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	// The query's lexemes are ORed, since a question rarely shares every word
+	// with the chunk that answers it
+	rows, err := s.db.Pool().Query(ctx, `
+		WITH q AS (
+			SELECT to_tsquery('simple', string_agg(quote_literal(lexeme), ' | ')) AS query
+			FROM unnest(tsvector_to_array(to_tsvector('simple', $2::text))) AS lexeme
+		)
+		SELECT id, document_id, content, metadata, ts_rank_cd(content_tsv, q.query, 32) AS score
+		FROM knowledge_vectors, q
+		WHERE knowledge_base_id = $1 AND content_tsv @@ q.query
+		ORDER BY score DESC
+		LIMIT $3
+	`, kbID, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanSearchResults(rows)
+}
+
+// DeleteDocument removes all chunks of a document
+func (s *PgVectorStore) DeleteDocument(ctx context.Context, documentID uuid.UUID) error {
+	_, err := s.db.Pool().Exec(ctx, `DELETE FROM knowledge_vectors WHERE document_id = $1`, documentID)
+	return err
+}
+
+// DeleteKnowledgeBase removes all chunks of a knowledge base
+func (s *PgVectorStore) DeleteKnowledgeBase(ctx context.Context, kbID uuid.UUID) error {
+	_, err := s.db.Pool().Exec(ctx, `DELETE FROM knowledge_vectors WHERE knowledge_base_id = $1`, kbID)
+	return err
+}
+
+// formatVector renders an embedding in pgvector's text format, e.g. [0.1,0.2]
+func formatVector(embedding []float32) string {
+	var b strings.Builder
+	b.Grow(len(embedding) * 10)
+	b.WriteByte('[')
+	for i, v := range embedding {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(v), 'g', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
@@ -0,0 +1,195 @@
+// Package metrics exposes the platform's Prometheus metrics. Metrics are
+// labelled by tenant tier (plan) rather than tenant ID to bound cardinality.
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// TierUnknown labels work whose tenant's plan isn't known
+const TierUnknown = "unknown"
+
+var registry = prometheus.NewRegistry()
+
+var (
+	// Executions counts finished executions by status, provider and tier
+	Executions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "delphi_executions_total",
+		Help: "Finished executions by status, provider and tenant tier.",
+	}, []string{"status", "provider", "tier"})
+
+	// Tokens counts the tokens executions consumed, by direction (input or
+	// output)
+	Tokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "delphi_tokens_total",
+		Help: "Tokens consumed by executions, by provider, direction and tenant tier.",
+	}, []string{"provider", "direction", "tier"})
+
+	// Cost sums the cost of executions
+	Cost = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "delphi_cost_usd_total",
+		Help: "Cost of executions in USD, by provider and tenant tier.",
+	}, []string{"provider", "tier"})
+
+	// ProviderRequests times provider calls by operation (complete or stream)
+	// and outcome (success or error); the error rate is the share of errors
+	ProviderRequests = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "delphi_provider_request_duration_seconds",
+		Help:    "Provider request latency by provider, operation and outcome.",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"provider", "operation", "outcome"})
+
+	// ProviderRateLimitRemaining is the quota providers last reported left, by
+	// resource (requests or tokens)
+	ProviderRateLimitRemaining = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "delphi_provider_rate_limit_remaining",
+		Help: "Requests or tokens left in a provider's rate limit as it last reported, by provider and resource.",
+	}, []string{"provider", "resource"})
+
+	// RateLimitRejections counts requests refused by the API rate limit
+	RateLimitRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "delphi_rate_limit_rejections_total",
+		Help: "Requests rejected by the API rate limit, by tenant tier.",
+	}, []string{"tier"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		Executions,
+		Tokens,
+		Cost,
+		ProviderRequests,
+		ProviderRateLimitRemaining,
+		RateLimitRejections,
+		queues,
+		auditOverflow,
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// Tier returns the label for a tenant's plan
+func Tier(plan string) string {
+	if plan == "" {
+		return TierUnknown
+	}
+	return plan
+}
+
+// RecordExecution counts a finished execution with its token usage and cost
+func RecordExecution(status, provider, tier string, inputTokens, outputTokens int, cost float64) {
+	tier = Tier(tier)
+	Executions.WithLabelValues(status, provider, tier).Inc()
+	if inputTokens > 0 {
+		Tokens.WithLabelValues(provider, "input", tier).Add(float64(inputTokens))
+	}
+	if outputTokens > 0 {
+		Tokens.WithLabelValues(provider, "output", tier).Add(float64(outputTokens))
+	}
+	if cost > 0 {
+		Cost.WithLabelValues(provider, tier).Add(cost)
+	}
+}
+
+// ObserveProviderRequest records a provider call that started at start
+func ObserveProviderRequest(provider, operation string, start time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	ProviderRequests.WithLabelValues(provider, operation, outcome).Observe(time.Since(start).Seconds())
+}
+
+// SetProviderRateLimitRemaining records the requests and tokens a provider
+// reported left in its rate limit
+func SetProviderRateLimitRemaining(provider string, requests, tokens int) {
+	ProviderRateLimitRemaining.WithLabelValues(provider, "requests").Set(float64(requests))
+	ProviderRateLimitRemaining.WithLabelValues(provider, "tokens").Set(float64(tokens))
+}
+
+// =============================================================================
+// Queue Depths
+// =============================================================================
+
+// queues reports the depth of the in-process queues registered with
+// RegisterQueue, read at scrape time
+var queues = &queueCollector{
+	desc:   prometheus.NewDesc("delphi_queue_depth", "Items waiting in an in-process queue.", []string{"queue"}, nil),
+	depths: make(map[string]func() int),
+}
+
+// RegisterQueue reports depth as the named queue's depth, replacing any queue
+// registered under the same name
+func RegisterQueue(name string, depth func() int) {
+	queues.mu.Lock()
+	defer queues.mu.Unlock()
+	queues.depths[name] = depth
+}
+
+type queueCollector struct {
+	desc   *prometheus.Desc
+	mu     sync.Mutex
+	depths map[string]func() int
+}
+
+func (c *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name, depth := range c.depths {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(depth()), name)
+	}
+}
+
+// =============================================================================
+// Audit Overflow
+// =============================================================================
+
+// auditOverflow reports the counters of the audit service registered with
+// RegisterAuditOverflow, read at scrape time
+var auditOverflow = &auditOverflowCollector{
+	desc: prometheus.NewDesc("delphi_audit_overflow_total", "Audit entries that missed their first store attempt, by outcome.", []string{"outcome"}, nil),
+}
+
+// RegisterAuditOverflow reports counts, keyed by outcome, as the audit
+// overflow counters, replacing any registered before
+func RegisterAuditOverflow(counts func() map[string]int64) {
+	auditOverflow.mu.Lock()
+	defer auditOverflow.mu.Unlock()
+	auditOverflow.counts = counts
+}
+
+type auditOverflowCollector struct {
+	desc   *prometheus.Desc
+	mu     sync.Mutex
+	counts func() map[string]int64
+}
+
+func (c *auditOverflowCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *auditOverflowCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		return
+	}
+	for outcome, count := range c.counts() {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.CounterValue, float64(count), outcome)
+	}
+}
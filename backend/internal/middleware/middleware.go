@@ -2,16 +2,28 @@ package middleware
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/delphi-platform/delphi/backend/internal/apierror"
+	"github.com/delphi-platform/delphi/backend/internal/security"
 	"github.com/delphi-platform/delphi/backend/internal/services"
 	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/google/uuid"
 )
 
+// writeError sends an error response in the API's error envelope
+func writeError(w http.ResponseWriter, status int, message string) {
+	apierror.Write(w, status, apierror.New(status, message))
+}
+
 // Context keys
 type contextKey string
 
@@ -46,25 +58,99 @@ func Logger(log *logger.Logger) func(next http.Handler) http.Handler {
 	}
 }
 
+// APIUsage records a per-tenant summary of every request for usage analytics.
+// It must run after Authenticate so the tenant is known.
+func APIUsage(usage *services.APIUsageService) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			next.ServeHTTP(ww, r)
+
+			tenantID, ok := GetTenantID(r.Context())
+			if !ok {
+				return
+			}
+
+			// Record the route pattern rather than the path so IDs don't fragment the stats
+			route := r.URL.Path
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			usage.RecordRequest(tenantID, r.Method, route, status, time.Since(start))
+		})
+	}
+}
+
+// RateLimit enforces per-tenant API rate limits based on the tenant's plan.
+// Requests without a tenant are limited by client IP, so chi's RealIP should
+// run first. It must run after Authenticate for tenants to be recognised.
+func RateLimit(limits *services.RateLimitService) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var result security.RateLimitResult
+			if tenantID, ok := GetTenantID(r.Context()); ok {
+				result = limits.CheckTenant(r.Context(), tenantID)
+			} else {
+				ip, _, err := net.SplitHostPort(r.RemoteAddr)
+				if err != nil {
+					ip = r.RemoteAddr
+				}
+				result = limits.CheckAnonymous(r.Context(), ip)
+			}
+
+			if result.Limit > 0 {
+				w.Header().Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+				w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			}
+
+			if !result.Allowed {
+				retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+				writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // Authenticate validates JWT tokens and populates context
 func Authenticate(authService *services.AuthService) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
+
+			// Browsers cannot set headers on a WebSocket handshake, so upgrades
+			// may pass the token as a query parameter instead
+			if authHeader == "" && strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+				if token := r.URL.Query().Get("access_token"); token != "" {
+					authHeader = "Bearer " + token
+				}
+			}
+
 			if authHeader == "" {
-				http.Error(w, `{"error": "missing authorization header"}`, http.StatusUnauthorized)
+				writeError(w, http.StatusUnauthorized, "missing authorization header")
 				return
 			}
 
 			parts := strings.Split(authHeader, " ")
 			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-				http.Error(w, `{"error": "invalid authorization format"}`, http.StatusUnauthorized)
+				writeError(w, http.StatusUnauthorized, "invalid authorization format")
 				return
 			}
 
 			claims, err := authService.ValidateToken(parts[1])
 			if err != nil {
-				http.Error(w, `{"error": "invalid token"}`, http.StatusUnauthorized)
+				writeError(w, http.StatusUnauthorized, "invalid token")
 				return
 			}
 
@@ -107,7 +193,7 @@ func RequireRole(roles ...string) func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			userRole, ok := r.Context().Value(UserRoleKey).(string)
 			if !ok {
-				http.Error(w, `{"error": "unauthorized"}`, http.StatusUnauthorized)
+				writeError(w, http.StatusUnauthorized, "unauthorized")
 				return
 			}
 
@@ -118,7 +204,27 @@ func RequireRole(roles ...string) func(next http.Handler) http.Handler {
 				}
 			}
 
-			http.Error(w, `{"error": "insufficient permissions"}`, http.StatusForbidden)
+			writeError(w, http.StatusForbidden, "insufficient permissions")
+		})
+	}
+}
+
+// RequirePermission checks that the user's role grants permission
+func RequirePermission(rbac *security.RBAC, permission security.Permission) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userRole, ok := GetUserRole(r.Context())
+			if !ok {
+				writeError(w, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+
+			if !rbac.HasPermission(security.Role(userRole), permission) {
+				writeError(w, http.StatusForbidden, fmt.Sprintf("role %q does not have permission %q", userRole, permission))
+				return
+			}
+
+			next.ServeHTTP(w, r)
 		})
 	}
 }
@@ -20,6 +20,11 @@ type Tenant struct {
 	Settings  json.RawMessage `json:"settings" db:"settings"`
 	CreatedAt time.Time       `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time       `json:"updated_at" db:"updated_at"`
+
+	// DeleteAfter is when a tenant whose deletion was confirmed is deleted
+	// for good; until then the deletion can be cancelled
+	DeletionRequestedAt *time.Time `json:"deletion_requested_at,omitempty" db:"deletion_requested_at"`
+	DeleteAfter         *time.Time `json:"delete_after,omitempty" db:"delete_after"`
 }
 
 type TenantPlan string
@@ -41,6 +46,15 @@ type User struct {
 	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time       `json:"updated_at" db:"updated_at"`
 	LastLoginAt *time.Time      `json:"last_login_at" db:"last_login_at"`
+
+	// PasswordHash is the bcrypt hash of the user's password; empty if they cannot sign in with one
+	PasswordHash string `json:"-" db:"password_hash"`
+
+	// MFA. MFASecret is the encrypted TOTP secret, set at enrollment and
+	// enforced once MFAEnabled. MFARecoveryCodes are hashes of unused codes.
+	MFAEnabled       bool     `json:"mfa_enabled" db:"mfa_enabled"`
+	MFASecret        string   `json:"-" db:"mfa_secret"`
+	MFARecoveryCodes []string `json:"-" db:"mfa_recovery_codes"`
 }
 
 type UserRole string
@@ -67,6 +81,9 @@ type APIKey struct {
 	IsValid      bool          `json:"is_valid" db:"is_valid"`
 	LastUsedAt   *time.Time    `json:"last_used_at" db:"last_used_at"`
 	CreatedAt    time.Time     `json:"created_at" db:"created_at"`
+
+	// LastValidatedAt is when the key was last checked with its provider; nil if never
+	LastValidatedAt *time.Time `json:"last_validated_at" db:"last_validated_at"`
 }
 
 type AIProvider string
@@ -99,6 +116,9 @@ type Agent struct {
 	Status         AgentStatus     `json:"status" db:"status"`
 	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
 	UpdatedAt      time.Time       `json:"updated_at" db:"updated_at"`
+
+	// Warnings are surfaced to the caller on create/update and are not persisted
+	Warnings []string `json:"warnings,omitempty" db:"-"`
 }
 
 type AgentType string
@@ -125,25 +145,113 @@ const (
 	AgentStatusTerminated AgentStatus = "terminated"
 )
 
+// DefaultTemperature is the sampling temperature of agents that don't set one
+const DefaultTemperature = 0.7
+
 type AgentConfig struct {
-	Temperature      float64     `json:"temperature"`
+	// Temperature is nil until the agent is validated, which fills in
+	// DefaultTemperature when it is absent; 0 is a valid setting
+	Temperature      *float64    `json:"temperature"`
+	TopP             float64     `json:"top_p,omitempty"` // 0 leaves the provider's default
 	MaxTokens        int         `json:"max_tokens"`
 	BudgetLimit      float64     `json:"budget_limit"`
 	TimeoutSeconds   int         `json:"timeout_seconds"`
 	RetryPolicy      RetryPolicy `json:"retry_policy"`
 	BriefingRequired bool        `json:"briefing_required"`
 	BriefingDepth    string      `json:"briefing_depth"` // quick, standard, full
+
+	// FallbackChain lists the models tried, in order, when the agent's own
+	// model fails with a retryable error such as a rate limit or overload
+	FallbackChain []ModelChoice `json:"fallback_chain,omitempty"`
+
+	// RequiredCapabilities are model capabilities the agent relies on, such
+	// as vision; function_calling is implied when the agent has tools
+	RequiredCapabilities []string `json:"required_capabilities,omitempty"`
+
+	// Guardrails filters the agent's prompts and responses; nil applies only
+	// the platform-wide rules
+	Guardrails *GuardrailConfig `json:"guardrails,omitempty"`
+
+	// PullRequests opens a pull request with each of a coding agent's
+	// results; nil leaves the changes in the run's result only
+	PullRequests *PullRequestConfig `json:"pull_requests,omitempty"`
+}
+
+// SamplingTemperature returns the temperature the agent's runs sample at
+func (c AgentConfig) SamplingTemperature() float64 {
+	if c.Temperature == nil {
+		return DefaultTemperature
+	}
+	return *c.Temperature
+}
+
+// Deterministic reports whether the agent samples at temperature 0, so its
+// identical runs give the same result
+func (c AgentConfig) Deterministic() bool {
+	return c.Temperature != nil && *c.Temperature == 0
+}
+
+// Float64 returns a pointer to v, for optional fields such as
+// AgentConfig.Temperature
+func Float64(v float64) *float64 {
+	return &v
+}
+
+// PullRequestConfig configures the pull requests a coding agent opens. Changes
+// are committed to a new branch off the repository's default branch, never to
+// the default branch itself.
+type PullRequestConfig struct {
+	RepositoryID uuid.UUID `json:"repository_id"`
+
+	// BranchPrefix starts the names of the branches changes are committed
+	// to; the run's ID completes them
+	BranchPrefix string `json:"branch_prefix,omitempty"`
+}
+
+// GuardrailOutputAction is what happens to a response that breaks a guardrail
+type GuardrailOutputAction string
+
+const (
+	GuardrailOutputBlock  GuardrailOutputAction = "block"
+	GuardrailOutputRedact GuardrailOutputAction = "redact"
+)
+
+// GuardrailConfig configures an agent's content guardrails. Patterns are
+// regular expressions and keywords match case-insensitively; both apply to
+// prompts and responses on top of the platform-wide rules.
+type GuardrailConfig struct {
+	BlockedPatterns []string `json:"blocked_patterns,omitempty"`
+	BlockedKeywords []string `json:"blocked_keywords,omitempty"`
+
+	// Moderation also checks content with the moderation API
+	Moderation bool `json:"moderation,omitempty"`
+
+	// OutputAction is block (the default) or redact. Responses flagged by
+	// moderation are always blocked, as there is no span to redact.
+	OutputAction GuardrailOutputAction `json:"output_action,omitempty"`
+}
+
+// ModelChoice is a provider and one of its models
+type ModelChoice struct {
+	Provider AIProvider `json:"provider"`
+	Model    string     `json:"model"`
 }
 
+// RetryPolicy controls how transient provider failures are retried.
+// MaxRetries is nil when unset, so that 0 turns retries off.
 type RetryPolicy struct {
-	MaxRetries  int `json:"max_retries"`
-	BackoffMs   int `json:"backoff_ms"`
-	MaxBackoffMs int `json:"max_backoff_ms"`
+	MaxRetries   *int `json:"max_retries,omitempty"`
+	BackoffMs    int  `json:"backoff_ms"`
+	MaxBackoffMs int  `json:"max_backoff_ms"`
 }
 
-// AgentTemplate provides pre-configured agent templates
+// AgentTemplate provides pre-configured agent templates. Built-in templates
+// have no TenantID; saved templates belong to the tenant that created them and
+// are visible only to it unless IsPublic.
 type AgentTemplate struct {
 	ID            uuid.UUID       `json:"id" db:"id"`
+	TenantID      *uuid.UUID      `json:"tenant_id,omitempty" db:"tenant_id"`
+	CreatedBy     *uuid.UUID      `json:"created_by,omitempty" db:"created_by"`
 	Name          string          `json:"name" db:"name"`
 	Description   string          `json:"description" db:"description"`
 	Type          AgentType       `json:"type" db:"type"`
@@ -152,6 +260,7 @@ type AgentTemplate struct {
 	Tools         json.RawMessage `json:"tools" db:"tools"`
 	Category      string          `json:"category" db:"category"`
 	IsPublic      bool            `json:"is_public" db:"is_public"`
+	CreatedAt     *time.Time      `json:"created_at,omitempty" db:"created_at"`
 }
 
 // =============================================================================
@@ -172,6 +281,67 @@ type AgentRun struct {
 	StartedAt   time.Time       `json:"started_at" db:"started_at"`
 	CompletedAt *time.Time      `json:"completed_at" db:"completed_at"`
 	Error       string          `json:"error,omitempty" db:"error"`
+
+	// ProviderRequestID is the AI provider's request ID, kept for support escalation
+	ProviderRequestID string `json:"provider_request_id,omitempty" db:"provider_request_id"`
+
+	// ReplayOf links a replayed run to the run it re-executes, with the parameter overrides applied
+	ReplayOf        *uuid.UUID      `json:"replay_of,omitempty" db:"replay_of"`
+	ReplayOverrides json.RawMessage `json:"replay_overrides,omitempty" db:"replay_overrides"`
+
+	// Parameters are the RunParameters the run executed with; runs recorded
+	// before they were kept have none
+	Parameters json.RawMessage `json:"parameters,omitempty" db:"parameters"`
+
+	// BatchID links a run to the batch execution that started it
+	BatchID *uuid.UUID `json:"batch_id,omitempty" db:"batch_id"`
+
+	// CostAnomaly flags a run that cost far more than the agent's recent runs,
+	// whose average cost was CostBaseline
+	CostAnomaly  bool     `json:"cost_anomaly" db:"cost_anomaly"`
+	CostBaseline *float64 `json:"cost_baseline,omitempty" db:"cost_baseline"`
+
+	// Cached is set on a run served from the response cache, which reused an
+	// identical earlier run's result without calling the provider
+	Cached bool `json:"cached" db:"cached"`
+
+	// PRURL is the pull request opened with a coding run's changes
+	PRURL string `json:"pr_url,omitempty" db:"pr_url"`
+
+	// PromptRef and ResponseRef reference the full prompt and response when
+	// they were too large to store inline and have been truncated
+	PromptRef   string `json:"prompt_ref,omitempty" db:"prompt_ref"`
+	ResponseRef string `json:"response_ref,omitempty" db:"response_ref"`
+}
+
+// RunParameters are the agent settings a run executed with
+type RunParameters struct {
+	Provider     AIProvider `json:"provider"`
+	Model        string     `json:"model"`
+	Temperature  float64    `json:"temperature"`
+	MaxTokens    int        `json:"max_tokens"`
+	SystemPrompt string     `json:"system_prompt"`
+}
+
+// NewRunParameters returns the parameters a run of the agent executes with
+func NewRunParameters(agent *Agent) RunParameters {
+	return RunParameters{
+		Provider:     agent.Provider,
+		Model:        agent.Model,
+		Temperature:  agent.Config.SamplingTemperature(),
+		MaxTokens:    agent.Config.MaxTokens,
+		SystemPrompt: agent.SystemPrompt,
+	}
+}
+
+// Apply sets the parameters on the agent
+func (p RunParameters) Apply(agent *Agent) {
+	temperature := p.Temperature
+	agent.Provider = p.Provider
+	agent.Model = p.Model
+	agent.Config.Temperature = &temperature
+	agent.Config.MaxTokens = p.MaxTokens
+	agent.SystemPrompt = p.SystemPrompt
 }
 
 type RunStatus string
@@ -183,8 +353,57 @@ const (
 	RunStatusCompleted  RunStatus = "completed"
 	RunStatusFailed     RunStatus = "failed"
 	RunStatusCancelled  RunStatus = "cancelled"
+
+	// RunStatusPartial is a run that finished but whose result didn't match
+	// its agent's result schema; the raw result is kept and Error says why
+	RunStatusPartial RunStatus = "partial"
+)
+
+// CodingResult is the result of a coding agent's run, normalized from the
+// agent's output so the UI and PR creation can rely on its shape
+type CodingResult struct {
+	SchemaVersion int                `json:"schema_version"`
+	Summary       string             `json:"summary"`
+	Files         []CodingFileChange `json:"files"`
+	Commits       []CodingCommit     `json:"commits"`
+	PRURL         string             `json:"pr_url,omitempty"`
+}
+
+// CodingFileStatus is how a coding run changed a file
+type CodingFileStatus string
+
+const (
+	CodingFileAdded    CodingFileStatus = "added"
+	CodingFileModified CodingFileStatus = "modified"
+	CodingFileDeleted  CodingFileStatus = "deleted"
+	CodingFileRenamed  CodingFileStatus = "renamed"
 )
 
+// CodingFileChange is a file a coding run changed. Content is the file's new
+// content, when the agent returned it; PreviousPath is set for renames.
+type CodingFileChange struct {
+	Path         string           `json:"path"`
+	Status       CodingFileStatus `json:"status"`
+	PreviousPath string           `json:"previous_path,omitempty"`
+	Content      *string          `json:"content,omitempty"`
+}
+
+// CodingCommit is a commit a coding run made or proposes
+type CodingCommit struct {
+	SHA     string   `json:"sha,omitempty"`
+	Message string   `json:"message"`
+	Files   []string `json:"files,omitempty"`
+}
+
+// RunBatch groups the runs started by one batch execution request. ItemCount
+// includes items that were refused before a run was created.
+type RunBatch struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	TenantID  uuid.UUID `json:"tenant_id" db:"tenant_id"`
+	ItemCount int       `json:"item_count" db:"item_count"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
 // AgentLog represents a log entry from an agent run
 type AgentLog struct {
 	ID        uuid.UUID       `json:"id" db:"id"`
@@ -204,6 +423,23 @@ const (
 	LogLevelError LogLevel = "error"
 )
 
+// ScheduledExecution runs an agent with a fixed prompt on a cron schedule.
+// CronExpr is evaluated in Timezone; NextRunAt is nil while disabled.
+type ScheduledExecution struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	TenantID  uuid.UUID  `json:"tenant_id" db:"tenant_id"`
+	AgentID   uuid.UUID  `json:"agent_id" db:"agent_id"`
+	CronExpr  string     `json:"cron_expr" db:"cron_expr"`
+	Timezone  string     `json:"timezone" db:"timezone"`
+	Prompt    string     `json:"prompt" db:"prompt"`
+	Enabled   bool       `json:"enabled" db:"enabled"`
+	NextRunAt *time.Time `json:"next_run_at" db:"next_run_at"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty" db:"last_run_at"`
+	LastRunID *uuid.UUID `json:"last_run_id,omitempty" db:"last_run_id"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+}
+
 // =============================================================================
 // Knowledge Base
 // =============================================================================
@@ -262,8 +498,40 @@ type Repository struct {
 	LastSyncAt   *time.Time      `json:"last_sync_at" db:"last_sync_at"`
 	Metadata     json.RawMessage `json:"metadata" db:"metadata"`
 	CreatedAt    time.Time       `json:"created_at" db:"created_at"`
+
+	// KnowledgeBaseID is where the repository's files are indexed; nil leaves it unindexed
+	KnowledgeBaseID *uuid.UUID `json:"knowledge_base_id,omitempty" db:"knowledge_base_id"`
+	InstallationID  *int64     `json:"installation_id,omitempty" db:"installation_id"`
+}
+
+// RepositorySyncJob reindexes a repository into its knowledge base. Manual jobs
+// reindex every file; push jobs only the files a push changed.
+type RepositorySyncJob struct {
+	ID           uuid.UUID  `json:"id" db:"id"`
+	TenantID     uuid.UUID  `json:"tenant_id" db:"tenant_id"`
+	RepositoryID uuid.UUID  `json:"repository_id" db:"repository_id"`
+	Trigger      string     `json:"trigger" db:"trigger"` // manual, push
+	Status       string     `json:"status" db:"status"`   // pending, running, completed, failed
+	CommitSHA    string     `json:"commit_sha,omitempty" db:"commit_sha"`
+	FilesIndexed int        `json:"files_indexed" db:"files_indexed"`
+	FilesDeleted int        `json:"files_deleted" db:"files_deleted"`
+	Error        string     `json:"error,omitempty" db:"error"`
+	StartedAt    *time.Time `json:"started_at" db:"started_at"`
+	CompletedAt  *time.Time `json:"completed_at" db:"completed_at"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
 }
 
+// Repository sync job triggers and statuses
+const (
+	SyncTriggerManual = "manual"
+	SyncTriggerPush   = "push"
+
+	SyncStatusPending   = "pending"
+	SyncStatusRunning   = "running"
+	SyncStatusCompleted = "completed"
+	SyncStatusFailed    = "failed"
+)
+
 // =============================================================================
 // Business & Projects
 // =============================================================================
@@ -340,14 +608,15 @@ type Budget struct {
 // =============================================================================
 
 type SocialAccount struct {
-	ID          uuid.UUID       `json:"id" db:"id"`
-	TenantID    uuid.UUID       `json:"tenant_id" db:"tenant_id"`
-	Platform    string          `json:"platform" db:"platform"`
-	AccountID   string          `json:"account_id" db:"account_id"`
-	AccountName string          `json:"account_name" db:"account_name"`
-	Credentials string          `json:"-" db:"credentials"` // encrypted
-	Metadata    json.RawMessage `json:"metadata" db:"metadata"`
-	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+	ID             uuid.UUID       `json:"id" db:"id"`
+	TenantID       uuid.UUID       `json:"tenant_id" db:"tenant_id"`
+	Platform       string          `json:"platform" db:"platform"`
+	AccountID      string          `json:"account_id" db:"account_id"`
+	AccountName    string          `json:"account_name" db:"account_name"`
+	Credentials    string          `json:"-" db:"credentials"` // encrypted
+	TokenExpiresAt *time.Time      `json:"token_expires_at" db:"token_expires_at"`
+	Metadata       json.RawMessage `json:"metadata" db:"metadata"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
 }
 
 type SocialPost struct {
@@ -355,13 +624,35 @@ type SocialPost struct {
 	AccountID   uuid.UUID       `json:"account_id" db:"account_id"`
 	Content     string          `json:"content" db:"content"`
 	MediaURLs   []string        `json:"media_urls" db:"media_urls"`
-	Status      string          `json:"status" db:"status"` // draft, scheduled, published
+	Status      string          `json:"status" db:"status"` // draft, scheduled, publishing, published, failed
 	ScheduledAt *time.Time      `json:"scheduled_at" db:"scheduled_at"`
 	PublishedAt *time.Time      `json:"published_at" db:"published_at"`
+	ExternalID  string          `json:"external_id,omitempty" db:"external_id"`
+	Attempts    int             `json:"attempts" db:"attempts"`
+	LastError   string          `json:"last_error,omitempty" db:"last_error"`
 	Analytics   json.RawMessage `json:"analytics" db:"analytics"`
 	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
 }
 
+// ContentPipeline generates social posts with an agent on a cron schedule
+type ContentPipeline struct {
+	ID          uuid.UUID            `json:"id" db:"id"`
+	TenantID    uuid.UUID            `json:"tenant_id" db:"tenant_id"`
+	BusinessID  *uuid.UUID           `json:"business_id,omitempty" db:"business_id"`
+	Name        string               `json:"name" db:"name"`
+	Description string               `json:"description" db:"description"`
+	AgentID     uuid.UUID            `json:"agent_id" db:"agent_id"`
+	Schedule    string               `json:"schedule" db:"schedule"`
+	Platforms   []string             `json:"platforms" db:"platforms"`
+	Accounts    map[string]uuid.UUID `json:"accounts" db:"accounts"` // account to post from on each platform
+	Config      json.RawMessage      `json:"config" db:"config"`
+	IsActive    bool                 `json:"is_active" db:"is_active"`
+	LastRunAt   *time.Time           `json:"last_run_at,omitempty" db:"last_run_at"`
+	NextRunAt   *time.Time           `json:"next_run_at,omitempty" db:"next_run_at"`
+	CreatedAt   time.Time            `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at" db:"updated_at"`
+}
+
 // =============================================================================
 // IoT
 // =============================================================================
@@ -369,26 +660,34 @@ type SocialPost struct {
 type IoTDevice struct {
 	ID          uuid.UUID       `json:"id" db:"id"`
 	TenantID    uuid.UUID       `json:"tenant_id" db:"tenant_id"`
+	BusinessID  *uuid.UUID      `json:"business_id,omitempty" db:"business_id"`
 	Name        string          `json:"name" db:"name"`
+	Description string          `json:"description" db:"description"`
 	Type        string          `json:"type" db:"type"`
 	Protocol    string          `json:"protocol" db:"protocol"` // mqtt, http, websocket
 	Endpoint    string          `json:"endpoint" db:"endpoint"`
 	Credentials string          `json:"-" db:"credentials"` // encrypted
 	Status      string          `json:"status" db:"status"`
+	Location    string          `json:"location" db:"location"`
+	Config      json.RawMessage `json:"config" db:"config"`
 	LastSeenAt  *time.Time      `json:"last_seen_at" db:"last_seen_at"`
 	Metadata    json.RawMessage `json:"metadata" db:"metadata"`
 	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at" db:"updated_at"`
 }
 
 type IoTCommand struct {
-	ID         uuid.UUID       `json:"id" db:"id"`
-	DeviceID   uuid.UUID       `json:"device_id" db:"device_id"`
-	Command    string          `json:"command" db:"command"`
-	Parameters json.RawMessage `json:"parameters" db:"parameters"`
-	Status     string          `json:"status" db:"status"`
-	Result     json.RawMessage `json:"result" db:"result"`
-	ExecutedAt *time.Time      `json:"executed_at" db:"executed_at"`
-	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
+	ID             uuid.UUID       `json:"id" db:"id"`
+	DeviceID       uuid.UUID       `json:"device_id" db:"device_id"`
+	Command        string          `json:"command" db:"command"`
+	Parameters     json.RawMessage `json:"parameters" db:"parameters"`
+	Status         string          `json:"status" db:"status"` // pending, sent, acknowledged, completed, failed
+	Result         json.RawMessage `json:"result" db:"result"`
+	Error          string          `json:"error,omitempty" db:"error"`
+	SentAt         *time.Time      `json:"sent_at" db:"sent_at"`
+	AcknowledgedAt *time.Time      `json:"acknowledged_at" db:"acknowledged_at"`
+	CompletedAt    *time.Time      `json:"completed_at" db:"completed_at"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
 }
 
 type IoTTelemetry struct {
@@ -414,9 +713,113 @@ type AuditLog struct {
 	NewValue     json.RawMessage `json:"new_value" db:"new_value"`
 	IPAddress    string          `json:"ip_address" db:"ip_address"`
 	UserAgent    string          `json:"user_agent" db:"user_agent"`
+	Severity     string          `json:"severity" db:"severity"` // info, warning, critical
+	SessionID    string          `json:"session_id" db:"session_id"`
+	Details      json.RawMessage `json:"details" db:"details"`
 	CreatedAt    time.Time       `json:"created_at" db:"created_at"`
 }
 
+// =============================================================================
+// Notifications
+// =============================================================================
+
+// NotificationRecipient is a tenant-level default audience entry for notifications
+type NotificationRecipient struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	TenantID  uuid.UUID `json:"tenant_id" db:"tenant_id"`
+	Channel   string    `json:"channel" db:"channel"` // email, slack, discord
+	Address   string    `json:"address" db:"address"` // email address or webhook URL
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// NotificationPreference sets the channels a notification type is delivered
+// on. UserID is nil for the tenant's default, which users can override.
+type NotificationPreference struct {
+	ID               uuid.UUID  `json:"id" db:"id"`
+	TenantID         uuid.UUID  `json:"tenant_id" db:"tenant_id"`
+	UserID           *uuid.UUID `json:"user_id,omitempty" db:"user_id"`
+	NotificationType string     `json:"notification_type" db:"notification_type"`
+	EnabledChannels  []string   `json:"enabled_channels" db:"enabled_channels"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// DoNotDisturb is a user's daily window in which non-critical notifications
+// are suppressed. StartTime and EndTime are HH:MM in Timezone; a window whose
+// end is before its start runs past midnight.
+type DoNotDisturb struct {
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	TenantID  uuid.UUID `json:"tenant_id" db:"tenant_id"`
+	Enabled   bool      `json:"enabled" db:"enabled"`
+	StartTime string    `json:"start_time" db:"start_time"`
+	EndTime   string    `json:"end_time" db:"end_time"`
+	Timezone  string    `json:"timezone" db:"timezone"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// DigestSchedule is when a tenant's weekly digest is sent: every DayOfWeek
+// (0 is Sunday) at TimeOfDay, HH:MM in Timezone. NextRunAt is nil until the
+// digest scheduler first picks the schedule up, and while it is disabled.
+type DigestSchedule struct {
+	TenantID   uuid.UUID  `json:"tenant_id" db:"tenant_id"`
+	Enabled    bool       `json:"enabled" db:"enabled"`
+	DayOfWeek  int        `json:"day_of_week" db:"day_of_week"`
+	TimeOfDay  string     `json:"time_of_day" db:"time_of_day"`
+	Timezone   string     `json:"timezone" db:"timezone"`
+	NextRunAt  *time.Time `json:"next_run_at" db:"next_run_at"`
+	LastSentAt *time.Time `json:"last_sent_at,omitempty" db:"last_sent_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// PushSubscription is a user's browser subscription to Web Push notifications
+type PushSubscription struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	TenantID  uuid.UUID `json:"tenant_id" db:"tenant_id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	Endpoint  string    `json:"endpoint" db:"endpoint"`
+	P256dh    string    `json:"-" db:"p256dh"`
+	Auth      string    `json:"-" db:"auth"`
+	UserAgent string    `json:"user_agent" db:"user_agent"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// =============================================================================
+// Outbound Webhooks
+// =============================================================================
+
+// Webhook is a tenant URL that receives signed POSTs for the events it
+// subscribes to. It is disabled automatically after too many failed attempts
+// in a row.
+type Webhook struct {
+	ID                  uuid.UUID  `json:"id" db:"id"`
+	TenantID            uuid.UUID  `json:"tenant_id" db:"tenant_id"`
+	URL                 string     `json:"url" db:"url"`
+	Events              []string   `json:"events" db:"events"`
+	Secret              string     `json:"-" db:"secret"` // encrypted
+	Enabled             bool       `json:"enabled" db:"enabled"`
+	ConsecutiveFailures int        `json:"consecutive_failures" db:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty" db:"disabled_at"`
+	CreatedAt           time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// WebhookDelivery is one event sent to a webhook, with the outcome of its
+// latest attempt
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id" db:"id"`
+	WebhookID      uuid.UUID       `json:"webhook_id" db:"webhook_id"`
+	Event          string          `json:"event" db:"event"`
+	Payload        json.RawMessage `json:"payload" db:"payload"`
+	Status         string          `json:"status" db:"status"` // pending, delivering, delivered, failed
+	Attempts       int             `json:"attempts" db:"attempts"`
+	ResponseStatus *int            `json:"response_status" db:"response_status"`
+	Error          string          `json:"error,omitempty" db:"error"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at" db:"next_attempt_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at" db:"delivered_at"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+}
+
 // =============================================================================
 // Cost Tracking
 // =============================================================================
@@ -444,3 +847,19 @@ type CostLimit struct {
 	CreatedAt time.Time  `json:"created_at" db:"created_at"`
 }
 
+
+// =============================================================================
+// API Usage
+// =============================================================================
+
+// APIUsageRollup summarizes a tenant's requests to one route and status within an hour
+type APIUsageRollup struct {
+	TenantID        uuid.UUID `json:"tenant_id" db:"tenant_id"`
+	Bucket          time.Time `json:"bucket" db:"bucket"` // start of the hour
+	Method          string    `json:"method" db:"method"`
+	Route           string    `json:"route" db:"route"` // route pattern, e.g. /agents/{agentID}
+	Status          int       `json:"status" db:"status"`
+	RequestCount    int64     `json:"request_count" db:"request_count"`
+	TotalDurationMs int64     `json:"total_duration_ms" db:"total_duration_ms"`
+	MaxDurationMs   int64     `json:"max_duration_ms" db:"max_duration_ms"`
+}
@@ -5,10 +5,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"html"
 	"net/http"
 	"net/smtp"
+	"strings"
 	"time"
 
+	"github.com/SherClockHolmes/webpush-go"
 	"github.com/delphi-platform/delphi/backend/pkg/logger"
 	"github.com/google/uuid"
 )
@@ -18,6 +21,11 @@ type Service struct {
 	emailConfig  *EmailConfig
 	slackConfig  *SlackConfig
 	discordConfig *DiscordConfig
+	pushConfig   *PushConfig
+	recipients   RecipientResolver
+	preferences  PreferenceResolver
+	pushSubscriptions PushSubscriptionStore
+	users        UserLookup
 	httpClient   *http.Client
 	log          *logger.Logger
 }
@@ -41,12 +49,22 @@ type DiscordConfig struct {
 	WebhookURL string
 }
 
+// PushConfig holds Web Push configuration. The VAPID key pair identifies the
+// platform to browser push services; Subscriber is a mailto: or https:
+// contact for the push service operators.
+type PushConfig struct {
+	VAPIDPublicKey  string
+	VAPIDPrivateKey string
+	Subscriber      string
+}
+
 // NewService creates a new notification service
-func NewService(emailConfig *EmailConfig, slackConfig *SlackConfig, discordConfig *DiscordConfig, log *logger.Logger) *Service {
+func NewService(emailConfig *EmailConfig, slackConfig *SlackConfig, discordConfig *DiscordConfig, pushConfig *PushConfig, log *logger.Logger) *Service {
 	return &Service{
 		emailConfig:   emailConfig,
 		slackConfig:   slackConfig,
 		discordConfig: discordConfig,
+		pushConfig:    pushConfig,
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
 		},
@@ -54,6 +72,151 @@ func NewService(emailConfig *EmailConfig, slackConfig *SlackConfig, discordConfi
 	}
 }
 
+// SetRecipientResolver sets the resolver used to find the default audience of
+// notifications that are not targeted at a specific user
+func (s *Service) SetRecipientResolver(resolver RecipientResolver) {
+	s.recipients = resolver
+}
+
+// SetPreferenceResolver sets the resolver that chooses a notification's
+// channels and applies do-not-disturb windows
+func (s *Service) SetPreferenceResolver(resolver PreferenceResolver) {
+	s.preferences = resolver
+}
+
+// SetPushSubscriptionStore sets where push notifications find the browser
+// subscriptions they are delivered to
+func (s *Service) SetPushSubscriptionStore(store PushSubscriptionStore) {
+	s.pushSubscriptions = store
+}
+
+// SetUserLookup sets how email notifications find their recipients'
+// addresses from user IDs
+func (s *Service) SetUserLookup(lookup UserLookup) {
+	s.users = lookup
+}
+
+// =============================================================================
+// Recipients
+// =============================================================================
+
+// Recipients is the set of destinations a notification is delivered to
+type Recipients struct {
+	Emails             []string
+	SlackWebhookURLs   []string
+	DiscordWebhookURLs []string
+}
+
+// RecipientResolver resolves the default notification audience of a tenant
+type RecipientResolver interface {
+	ResolveRecipients(ctx context.Context, tenantID uuid.UUID) (*Recipients, error)
+}
+
+// resolveRecipients returns the tenant's default audience for notifications
+// without a specific user target, or nil if none applies
+func (s *Service) resolveRecipients(ctx context.Context, notification *Notification) *Recipients {
+	if notification.UserID != nil || s.recipients == nil {
+		return nil
+	}
+
+	recipients, err := s.recipients.ResolveRecipients(ctx, notification.TenantID)
+	if err != nil {
+		s.log.Warnw("failed to resolve notification recipients", "tenant_id", notification.TenantID, "error", err)
+		return nil
+	}
+	return recipients
+}
+
+// User is a user notifications can be addressed to
+type User struct {
+	ID    uuid.UUID
+	Email string
+}
+
+// UserLookup finds the users notifications are addressed to
+type UserLookup interface {
+	// GetUser returns a user, or nil if they don't exist
+	GetUser(ctx context.Context, userID uuid.UUID) (*User, error)
+
+	// ListTenantUsers returns every user of a tenant
+	ListTenantUsers(ctx context.Context, tenantID uuid.UUID) ([]*User, error)
+}
+
+// userCache memoizes user and preference lookups for the duration of a
+// single Send, so channels resolving the same users don't query them again
+type userCache struct {
+	svc         *Service
+	users       map[uuid.UUID]*User
+	tenantUsers map[uuid.UUID][]*User
+	emailOn     map[uuid.UUID]bool
+}
+
+func (s *Service) newUserCache() *userCache {
+	return &userCache{
+		svc:         s,
+		users:       make(map[uuid.UUID]*User),
+		tenantUsers: make(map[uuid.UUID][]*User),
+		emailOn:     make(map[uuid.UUID]bool),
+	}
+}
+
+// user returns a user by ID, or nil if they don't exist
+func (c *userCache) user(ctx context.Context, userID uuid.UUID) (*User, error) {
+	if user, ok := c.users[userID]; ok {
+		return user, nil
+	}
+	user, err := c.svc.users.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	c.users[userID] = user
+	return user, nil
+}
+
+// tenant returns every user of a tenant
+func (c *userCache) tenant(ctx context.Context, tenantID uuid.UUID) ([]*User, error) {
+	if users, ok := c.tenantUsers[tenantID]; ok {
+		return users, nil
+	}
+	users, err := c.svc.users.ListTenantUsers(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	c.tenantUsers[tenantID] = users
+	for _, user := range users {
+		c.users[user.ID] = user
+	}
+	return users, nil
+}
+
+// emailEnabled reports which of the users receive the notification by email:
+// their preference for its type includes email (or they have none), and they
+// are not in a do-not-disturb window unless the type is critical. The
+// preferences of users not seen yet are resolved in one lookup. Users whose
+// preferences can't be resolved are treated as having email enabled.
+func (c *userCache) emailEnabled(ctx context.Context, notification *Notification, users []*User) map[uuid.UUID]bool {
+	var missing []uuid.UUID
+	for _, user := range users {
+		if _, ok := c.emailOn[user.ID]; !ok {
+			c.emailOn[user.ID] = true
+			missing = append(missing, user.ID)
+		}
+	}
+	if len(missing) == 0 || c.svc.preferences == nil {
+		return c.emailOn
+	}
+
+	prefs, err := c.svc.preferences.ResolveUserPreferences(ctx, notification.TenantID, missing, notification.Type)
+	if err != nil {
+		c.svc.log.Warnw("failed to resolve notification preferences", "tenant_id", notification.TenantID, "users", len(missing), "error", err)
+		return c.emailOn
+	}
+	for userID, p := range prefs {
+		c.emailOn[userID] = p.delivers(ChannelEmail, notification.Type)
+	}
+	return c.emailOn
+}
+
 // =============================================================================
 // Notification Types
 // =============================================================================
@@ -66,11 +229,34 @@ const (
 	NotificationExecutionFailed   NotificationType = "execution_failed"
 	NotificationBudgetAlert       NotificationType = "budget_alert"
 	NotificationBudgetExceeded    NotificationType = "budget_exceeded"
+	NotificationPaymentFailed     NotificationType = "payment_failed"
 	NotificationAgentError        NotificationType = "agent_error"
 	NotificationPRCreated         NotificationType = "pr_created"
 	NotificationWeeklyDigest      NotificationType = "weekly_digest"
 )
 
+// NotificationTypes lists every notification type
+var NotificationTypes = []NotificationType{
+	NotificationExecutionComplete,
+	NotificationExecutionFailed,
+	NotificationBudgetAlert,
+	NotificationBudgetExceeded,
+	NotificationPaymentFailed,
+	NotificationAgentError,
+	NotificationPRCreated,
+	NotificationWeeklyDigest,
+}
+
+// IsCritical reports whether notifications of this type are delivered even
+// during a recipient's do-not-disturb window
+func (t NotificationType) IsCritical() bool {
+	switch t {
+	case NotificationBudgetExceeded, NotificationPaymentFailed, NotificationAgentError:
+		return true
+	}
+	return false
+}
+
 // NotificationChannel represents a notification channel
 type NotificationChannel string
 
@@ -81,6 +267,34 @@ const (
 	ChannelPush    NotificationChannel = "push"
 )
 
+// defaultChannels are the channels each notification type uses when neither
+// the tenant nor the user has set a preference
+var defaultChannels = map[NotificationType][]NotificationChannel{
+	NotificationExecutionComplete: {ChannelSlack, ChannelDiscord, ChannelPush},
+	NotificationExecutionFailed:   {ChannelSlack, ChannelDiscord, ChannelEmail, ChannelPush},
+	NotificationBudgetAlert:       {ChannelSlack, ChannelEmail, ChannelPush},
+	NotificationBudgetExceeded:    {ChannelSlack, ChannelEmail, ChannelPush},
+	NotificationPaymentFailed:     {ChannelSlack, ChannelEmail},
+	NotificationAgentError:        {ChannelSlack, ChannelEmail, ChannelPush},
+	NotificationPRCreated:         {ChannelSlack, ChannelDiscord},
+	NotificationWeeklyDigest:      {ChannelEmail, ChannelSlack},
+}
+
+// DefaultChannels returns the channels a notification type uses unless a
+// preference says otherwise
+func DefaultChannels(t NotificationType) []NotificationChannel {
+	return append([]NotificationChannel(nil), defaultChannels[t]...)
+}
+
+// IsValidChannel reports whether the channel is one notifications can be sent on
+func IsValidChannel(channel NotificationChannel) bool {
+	switch channel {
+	case ChannelEmail, ChannelSlack, ChannelDiscord, ChannelPush:
+		return true
+	}
+	return false
+}
+
 // Notification represents a notification to send
 type Notification struct {
 	ID        uuid.UUID
@@ -98,24 +312,92 @@ type Notification struct {
 // Send Notifications
 // =============================================================================
 
-// Send sends a notification through all specified channels
+// Preferences is how a notification's recipient wants it delivered
+type Preferences struct {
+	// Channels replaces the notification's channels; nil keeps them
+	Channels []NotificationChannel
+
+	// DoNotDisturb is set while the recipient is in a do-not-disturb window
+	DoNotDisturb bool
+}
+
+// delivers reports whether a notification of type t reaches the recipient on
+// channel, assuming it is one of the notification's own channels
+func (p *Preferences) delivers(channel NotificationChannel, t NotificationType) bool {
+	if p.DoNotDisturb && !t.IsCritical() {
+		return false
+	}
+	if p.Channels == nil {
+		return true
+	}
+	for _, c := range p.Channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// PreferenceResolver looks up the delivery preferences for a notification.
+// Tenant-wide notifications (nil userID) use the tenant's preferences;
+// notifications for a user use theirs, falling back to the tenant's.
+type PreferenceResolver interface {
+	ResolvePreferences(ctx context.Context, tenantID uuid.UUID, userID *uuid.UUID, notificationType NotificationType) (*Preferences, error)
+
+	// ResolveUserPreferences resolves the preferences of several users of a
+	// tenant at once, for tenant-wide notifications that reach each of them
+	ResolveUserPreferences(ctx context.Context, tenantID uuid.UUID, userIDs []uuid.UUID, notificationType NotificationType) (map[uuid.UUID]*Preferences, error)
+}
+
+// applyPreferences sets the notification's channels from the recipient's
+// preferences, reporting false if it should not be sent at all. Preferences
+// that can't be resolved leave the notification as it is.
+func (s *Service) applyPreferences(ctx context.Context, notification *Notification) bool {
+	if s.preferences == nil {
+		return true
+	}
+
+	prefs, err := s.preferences.ResolvePreferences(ctx, notification.TenantID, notification.UserID, notification.Type)
+	if err != nil {
+		s.log.Warnw("failed to resolve notification preferences", "tenant_id", notification.TenantID, "type", notification.Type, "error", err)
+		return true
+	}
+	if prefs.DoNotDisturb && !notification.Type.IsCritical() {
+		s.log.Infow("notification suppressed by do not disturb", "type", notification.Type, "user_id", notification.UserID)
+		return false
+	}
+	if prefs.Channels != nil {
+		notification.Channels = prefs.Channels
+	}
+	return true
+}
+
+// Send sends a notification through the channels its recipient prefers, or
+// the notification's own channels if they have no preference
 func (s *Service) Send(ctx context.Context, notification *Notification) error {
+	if !s.applyPreferences(ctx, notification) {
+		return nil
+	}
+
 	s.log.Infow("sending notification",
 		"type", notification.Type,
 		"channels", notification.Channels,
 	)
 
+	recipients := s.resolveRecipients(ctx, notification)
+	users := s.newUserCache()
+
 	var errors []error
 
 	for _, channel := range notification.Channels {
 		var err error
 		switch channel {
 		case ChannelEmail:
-			err = s.sendEmail(ctx, notification)
+			err = s.sendEmail(ctx, notification, recipients, users)
 		case ChannelSlack:
-			err = s.sendSlack(ctx, notification)
+			err = s.sendSlack(ctx, notification, recipients)
 		case ChannelDiscord:
-			err = s.sendDiscord(ctx, notification)
+			err = s.sendDiscord(ctx, notification, recipients)
 		case ChannelPush:
 			err = s.sendPush(ctx, notification)
 		}
@@ -137,14 +419,16 @@ func (s *Service) Send(ctx context.Context, notification *Notification) error {
 // Email
 // =============================================================================
 
-func (s *Service) sendEmail(ctx context.Context, notification *Notification) error {
+func (s *Service) sendEmail(ctx context.Context, notification *Notification, recipients *Recipients, users *userCache) error {
 	if s.emailConfig == nil || s.emailConfig.Host == "" {
 		return fmt.Errorf("email not configured")
 	}
 
-	// Get recipient email from notification.Data or lookup by UserID
-	to, ok := notification.Data["email"].(string)
-	if !ok || to == "" {
+	to, err := s.emailRecipients(ctx, notification, recipients, users)
+	if err != nil {
+		return err
+	}
+	if len(to) == 0 {
 		return fmt.Errorf("no email recipient specified")
 	}
 
@@ -176,28 +460,91 @@ func (s *Service) sendEmail(ctx context.Context, notification *Notification) err
     </div>
 </body>
 </html>
-`, subject, body)
-
-	msg := fmt.Sprintf("From: %s\r\n"+
-		"To: %s\r\n"+
-		"Subject: %s\r\n"+
-		"MIME-Version: 1.0\r\n"+
-		"Content-Type: text/html; charset=UTF-8\r\n"+
-		"\r\n"+
-		"%s", s.emailConfig.From, to, subject, htmlBody)
+`, html.EscapeString(subject), strings.ReplaceAll(html.EscapeString(body), "\n", "<br>"))
 
 	auth := smtp.PlainAuth("", s.emailConfig.User, s.emailConfig.Password, s.emailConfig.Host)
 	addr := fmt.Sprintf("%s:%d", s.emailConfig.Host, s.emailConfig.Port)
 
-	err := smtp.SendMail(addr, auth, s.emailConfig.From, []string{to}, []byte(msg))
-	if err != nil {
-		return fmt.Errorf("failed to send email: %w", err)
+	// Each recipient gets their own message, so tenant users and added
+	// recipients never see each other's addresses
+	failed := 0
+	var lastErr error
+	for _, recipient := range to {
+		msg := fmt.Sprintf("From: %s\r\n"+
+			"To: %s\r\n"+
+			"Subject: %s\r\n"+
+			"MIME-Version: 1.0\r\n"+
+			"Content-Type: text/html; charset=UTF-8\r\n"+
+			"\r\n"+
+			"%s", s.emailConfig.From, recipient, subject, htmlBody)
+
+		if err := smtp.SendMail(addr, auth, s.emailConfig.From, []string{recipient}, []byte(msg)); err != nil {
+			s.log.Warnw("failed to send email", "to", recipient, "subject", subject, "error", err)
+			failed++
+			lastErr = err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to send email to %d of %d recipients: %w", failed, len(to), lastErr)
 	}
 
 	s.log.Infow("email sent", "to", to, "subject", subject)
 	return nil
 }
 
+// emailRecipients returns the addresses an email notification goes to. An
+// address in notification.Data["email"] wins; otherwise a user-targeted
+// notification goes to that user, and a tenant-wide one to every user of the
+// tenant with email enabled for its type plus the tenant's added recipients.
+func (s *Service) emailRecipients(ctx context.Context, notification *Notification, recipients *Recipients, users *userCache) ([]string, error) {
+	if email, ok := notification.Data["email"].(string); ok && email != "" {
+		return []string{email}, nil
+	}
+
+	if notification.UserID != nil {
+		if s.users == nil {
+			return nil, nil
+		}
+		user, err := users.user(ctx, *notification.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up user: %w", err)
+		}
+		if user == nil || user.Email == "" {
+			return nil, nil
+		}
+		return []string{user.Email}, nil
+	}
+
+	var to []string
+	seen := make(map[string]bool)
+	add := func(email string) {
+		key := strings.ToLower(email)
+		if email != "" && !seen[key] {
+			to = append(to, email)
+			seen[key] = true
+		}
+	}
+
+	if s.users != nil {
+		tenantUsers, err := users.tenant(ctx, notification.TenantID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tenant users: %w", err)
+		}
+		enabled := users.emailEnabled(ctx, notification, tenantUsers)
+		for _, user := range tenantUsers {
+			if enabled[user.ID] {
+				add(user.Email)
+			}
+		}
+	}
+	if recipients != nil {
+		for _, email := range recipients.Emails {
+			add(email)
+		}
+	}
+	return to, nil
+}
+
 // =============================================================================
 // Slack
 // =============================================================================
@@ -225,8 +572,15 @@ type slackAttachment struct {
 	Footer string `json:"footer"`
 }
 
-func (s *Service) sendSlack(ctx context.Context, notification *Notification) error {
-	if s.slackConfig == nil || s.slackConfig.WebhookURL == "" {
+func (s *Service) sendSlack(ctx context.Context, notification *Notification, recipients *Recipients) error {
+	// Tenant webhooks take precedence over the platform-wide webhook
+	var webhookURLs []string
+	if recipients != nil && len(recipients.SlackWebhookURLs) > 0 {
+		webhookURLs = recipients.SlackWebhookURLs
+	} else if s.slackConfig != nil && s.slackConfig.WebhookURL != "" {
+		webhookURLs = []string{s.slackConfig.WebhookURL}
+	}
+	if len(webhookURLs) == 0 {
 		return fmt.Errorf("Slack not configured")
 	}
 
@@ -237,7 +591,7 @@ func (s *Service) sendSlack(ctx context.Context, notification *Notification) err
 		color = "#00d68f" // green
 	case NotificationExecutionFailed, NotificationAgentError:
 		color = "#ff4757" // red
-	case NotificationBudgetAlert, NotificationBudgetExceeded:
+	case NotificationBudgetAlert, NotificationBudgetExceeded, NotificationPaymentFailed:
 		color = "#ffaa00" // yellow
 	}
 
@@ -257,24 +611,35 @@ func (s *Service) sendSlack(ctx context.Context, notification *Notification) err
 		return err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", s.slackConfig.WebhookURL, bytes.NewReader(body))
+	for _, webhookURL := range webhookURLs {
+		status, err := s.postWebhook(ctx, webhookURL, body)
+		if err != nil {
+			return err
+		}
+		if status != http.StatusOK {
+			return fmt.Errorf("Slack webhook returned %d", status)
+		}
+	}
+
+	s.log.Infow("Slack notification sent", "title", notification.Title, "webhooks", len(webhookURLs))
+	return nil
+}
+
+// postWebhook posts a JSON payload to a webhook URL and returns the response status
+func (s *Service) postWebhook(ctx context.Context, webhookURL string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", webhookURL, bytes.NewReader(body))
 	if err != nil {
-		return err
+		return 0, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("Slack webhook returned %d", resp.StatusCode)
-	}
-
-	s.log.Infow("Slack notification sent", "title", notification.Title)
-	return nil
+	return resp.StatusCode, nil
 }
 
 // =============================================================================
@@ -298,8 +663,15 @@ type discordFooter struct {
 	Text string `json:"text"`
 }
 
-func (s *Service) sendDiscord(ctx context.Context, notification *Notification) error {
-	if s.discordConfig == nil || s.discordConfig.WebhookURL == "" {
+func (s *Service) sendDiscord(ctx context.Context, notification *Notification, recipients *Recipients) error {
+	// Tenant webhooks take precedence over the platform-wide webhook
+	var webhookURLs []string
+	if recipients != nil && len(recipients.DiscordWebhookURLs) > 0 {
+		webhookURLs = recipients.DiscordWebhookURLs
+	} else if s.discordConfig != nil && s.discordConfig.WebhookURL != "" {
+		webhookURLs = []string{s.discordConfig.WebhookURL}
+	}
+	if len(webhookURLs) == 0 {
 		return fmt.Errorf("Discord not configured")
 	}
 
@@ -310,7 +682,7 @@ func (s *Service) sendDiscord(ctx context.Context, notification *Notification) e
 		color = 54927 // green
 	case NotificationExecutionFailed, NotificationAgentError:
 		color = 16729943 // red
-	case NotificationBudgetAlert, NotificationBudgetExceeded:
+	case NotificationBudgetAlert, NotificationBudgetExceeded, NotificationPaymentFailed:
 		color = 16755200 // yellow
 	}
 
@@ -333,34 +705,132 @@ func (s *Service) sendDiscord(ctx context.Context, notification *Notification) e
 		return err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", s.discordConfig.WebhookURL, bytes.NewReader(body))
+	for _, webhookURL := range webhookURLs {
+		status, err := s.postWebhook(ctx, webhookURL, body)
+		if err != nil {
+			return err
+		}
+		if status != http.StatusOK && status != http.StatusNoContent {
+			return fmt.Errorf("Discord webhook returned %d", status)
+		}
+	}
+
+	s.log.Infow("Discord notification sent", "title", notification.Title, "webhooks", len(webhookURLs))
+	return nil
+}
+
+// =============================================================================
+// Push
+// =============================================================================
+
+// pushTTL is how long a push service holds a notification for an offline browser
+const pushTTL = 24 * 60 * 60
+
+// PushSubscription is a browser's Web Push subscription
+type PushSubscription struct {
+	Endpoint string
+	P256dh   string
+	Auth     string
+}
+
+// PushSubscriptionStore finds the push subscriptions notifications are
+// delivered to and forgets ones the push service reports gone
+type PushSubscriptionStore interface {
+	// ListPushSubscriptions returns the user's subscriptions, or those of
+	// every user of the tenant when userID is nil
+	ListPushSubscriptions(ctx context.Context, tenantID uuid.UUID, userID *uuid.UUID) ([]PushSubscription, error)
+	RemovePushSubscription(ctx context.Context, endpoint string) error
+}
+
+// pushMessage is the payload the dashboard's service worker receives
+type pushMessage struct {
+	ID        uuid.UUID              `json:"id"`
+	Type      NotificationType       `json:"type"`
+	Title     string                 `json:"title"`
+	Message   string                 `json:"message"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// sendPush encrypts the notification for each of the recipients' browser
+// subscriptions and posts it to their push services. Subscriptions the push
+// service no longer knows (404 or 410) are pruned. Push is opt-in per
+// browser, so having no subscriptions, or push being disabled, is not an
+// error.
+func (s *Service) sendPush(ctx context.Context, notification *Notification) error {
+	if s.pushConfig == nil || s.pushConfig.VAPIDPrivateKey == "" || s.pushSubscriptions == nil {
+		s.log.Debugw("push not configured", "title", notification.Title)
+		return nil
+	}
+
+	subscriptions, err := s.pushSubscriptions.ListPushSubscriptions(ctx, notification.TenantID, notification.UserID)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to list push subscriptions: %w", err)
+	}
+	if len(subscriptions) == 0 {
+		s.log.Debugw("no push subscriptions", "tenant_id", notification.TenantID, "type", notification.Type)
+		return nil
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := s.httpClient.Do(req)
+	payload, err := json.Marshal(pushMessage{
+		ID:        notification.ID,
+		Type:      notification.Type,
+		Title:     notification.Title,
+		Message:   notification.Message,
+		Data:      notification.Data,
+		CreatedAt: notification.CreatedAt,
+	})
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("Discord webhook returned %d", resp.StatusCode)
+	urgency := webpush.UrgencyNormal
+	switch notification.Type {
+	case NotificationExecutionFailed, NotificationAgentError, NotificationBudgetAlert, NotificationBudgetExceeded, NotificationPaymentFailed:
+		urgency = webpush.UrgencyHigh
+	}
+	options := &webpush.Options{
+		HTTPClient:      s.httpClient,
+		Subscriber:      s.pushConfig.Subscriber,
+		VAPIDPublicKey:  s.pushConfig.VAPIDPublicKey,
+		VAPIDPrivateKey: s.pushConfig.VAPIDPrivateKey,
+		TTL:             pushTTL,
+		Urgency:         urgency,
 	}
 
-	s.log.Infow("Discord notification sent", "title", notification.Title)
-	return nil
-}
+	var failed, sent int
+	for _, sub := range subscriptions {
+		resp, err := webpush.SendNotificationWithContext(ctx, payload, &webpush.Subscription{
+			Endpoint: sub.Endpoint,
+			Keys:     webpush.Keys{P256dh: sub.P256dh, Auth: sub.Auth},
+		}, options)
+		if err != nil {
+			s.log.Warnw("failed to send push notification", "endpoint", sub.Endpoint, "error", err)
+			failed++
+			continue
+		}
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+			if err := s.pushSubscriptions.RemovePushSubscription(ctx, sub.Endpoint); err != nil {
+				s.log.Warnw("failed to remove expired push subscription", "endpoint", sub.Endpoint, "error", err)
+			} else {
+				s.log.Infow("expired push subscription removed", "endpoint", sub.Endpoint)
+			}
+		case resp.StatusCode < 200 || resp.StatusCode >= 300:
+			s.log.Warnw("push service rejected notification", "endpoint", sub.Endpoint, "status", resp.StatusCode)
+			failed++
+		default:
+			sent++
+		}
+	}
 
-// =============================================================================
-// Push (placeholder for web push or mobile push)
-// =============================================================================
+	if failed > 0 {
+		return fmt.Errorf("push failed for %d of %d subscriptions", failed, len(subscriptions))
+	}
 
-func (s *Service) sendPush(ctx context.Context, notification *Notification) error {
-	// In production, implement web push via Service Workers
-	// or mobile push via FCM/APNs
-	s.log.Debugw("push notification not implemented", "title", notification.Title)
+	s.log.Infow("push notification sent", "title", notification.Title, "subscriptions", sent)
 	return nil
 }
 
@@ -381,7 +851,7 @@ func ExecutionCompleteNotification(tenantID uuid.UUID, agentName string, runID u
 			"run_id":     runID.String(),
 			"duration":   duration.String(),
 		},
-		Channels:  []NotificationChannel{ChannelSlack, ChannelDiscord},
+		Channels:  DefaultChannels(NotificationExecutionComplete),
 		CreatedAt: time.Now(),
 	}
 }
@@ -399,7 +869,7 @@ func ExecutionFailedNotification(tenantID uuid.UUID, agentName string, runID uui
 			"run_id":     runID.String(),
 			"error":      errorMsg,
 		},
-		Channels:  []NotificationChannel{ChannelSlack, ChannelDiscord, ChannelEmail},
+		Channels:  DefaultChannels(NotificationExecutionFailed),
 		CreatedAt: time.Now(),
 	}
 }
@@ -417,7 +887,77 @@ func BudgetAlertNotification(tenantID uuid.UUID, spent float64, limit float64, p
 			"limit":      limit,
 			"percentage": percentage,
 		},
-		Channels:  []NotificationChannel{ChannelSlack, ChannelEmail},
+		Channels:  DefaultChannels(NotificationBudgetAlert),
+		CreatedAt: time.Now(),
+	}
+}
+
+// BudgetForecastNotification creates a budget alert for spend projected to
+// pass the limit by the end of the period, before it has
+func BudgetForecastNotification(tenantID uuid.UUID, spent, projected, limit float64, periodEnd time.Time) *Notification {
+	return &Notification{
+		ID:       uuid.New(),
+		TenantID: tenantID,
+		Type:     NotificationBudgetAlert,
+		Title:    "Budget Forecast Alert",
+		Message:  fmt.Sprintf("At your current rate you'll spend $%.2f by %s, over your budget of $%.2f ($%.2f spent so far)", projected, periodEnd.Format("Jan 2"), limit, spent),
+		Data: map[string]interface{}{
+			"spent":      spent,
+			"projected":  projected,
+			"limit":      limit,
+			"period_end": periodEnd,
+			"forecast":   true,
+		},
+		Channels:  DefaultChannels(NotificationBudgetAlert),
+		CreatedAt: time.Now(),
+	}
+}
+
+// CostAnomalyNotification creates an agent error notification for a run that
+// cost far more than the agent's recent runs
+func CostAnomalyNotification(tenantID uuid.UUID, agentName string, runID uuid.UUID, cost, baseline float64, paused bool) *Notification {
+	message := fmt.Sprintf("Execution %s cost $%.4f, %.1fx the agent's recent average of $%.4f", runID.String()[:8], cost, cost/baseline, baseline)
+	if paused {
+		message += ". The agent has been paused."
+	}
+
+	return &Notification{
+		ID:       uuid.New(),
+		TenantID: tenantID,
+		Type:     NotificationAgentError,
+		Title:    fmt.Sprintf("Oracle '%s' cost anomaly", agentName),
+		Message:  message,
+		Data: map[string]interface{}{
+			"agent_name": agentName,
+			"run_id":     runID.String(),
+			"cost":       cost,
+			"baseline":   baseline,
+			"paused":     paused,
+		},
+		Channels:  DefaultChannels(NotificationAgentError),
+		CreatedAt: time.Now(),
+	}
+}
+
+// PaymentFailedNotification creates a notification for a failed subscription payment
+func PaymentFailedNotification(tenantID uuid.UUID, amountDue float64, currency string, restricted bool) *Notification {
+	message := fmt.Sprintf("We couldn't collect your payment of %.2f %s. Please update your payment method.", amountDue, strings.ToUpper(currency))
+	if restricted {
+		message = fmt.Sprintf("We couldn't collect your payment of %.2f %s and your account has been restricted. Please update your payment method to restore access.", amountDue, strings.ToUpper(currency))
+	}
+
+	return &Notification{
+		ID:       uuid.New(),
+		TenantID: tenantID,
+		Type:     NotificationPaymentFailed,
+		Title:    "Payment Failed",
+		Message:  message,
+		Data: map[string]interface{}{
+			"amount_due": amountDue,
+			"currency":   currency,
+			"restricted": restricted,
+		},
+		Channels:  DefaultChannels(NotificationPaymentFailed),
 		CreatedAt: time.Now(),
 	}
 }
@@ -436,8 +976,23 @@ func PRCreatedNotification(tenantID uuid.UUID, agentName, repoName string, prNum
 			"pr_number":  prNumber,
 			"pr_url":     prURL,
 		},
-		Channels:  []NotificationChannel{ChannelSlack, ChannelDiscord},
+		Channels:  DefaultChannels(NotificationPRCreated),
 		CreatedAt: time.Now(),
 	}
 }
 
+// WeeklyDigestNotification creates a tenant's weekly digest for the week
+// ending at weekEnding. summary is the formatted digest; data carries its
+// figures for channels that render them.
+func WeeklyDigestNotification(tenantID uuid.UUID, weekEnding time.Time, summary string, data map[string]interface{}) *Notification {
+	return &Notification{
+		ID:        uuid.New(),
+		TenantID:  tenantID,
+		Type:      NotificationWeeklyDigest,
+		Title:     fmt.Sprintf("Your week in Delphi: %s", weekEnding.Format("Jan 2, 2006")),
+		Message:   summary,
+		Data:      data,
+		Channels:  DefaultChannels(NotificationWeeklyDigest),
+		CreatedAt: time.Now(),
+	}
+}
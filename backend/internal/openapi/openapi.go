@@ -0,0 +1,22 @@
+// Package openapi serves the OpenAPI 3 description of the v1 API, kept in
+// openapi.json alongside the routes it describes in cmd/api.
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+// Spec returns the OpenAPI document
+func Spec() []byte {
+	return spec
+}
+
+// Handler serves the OpenAPI document
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(spec)
+}
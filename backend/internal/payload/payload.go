@@ -0,0 +1,169 @@
+// Package payload bounds the size of stored prompts and responses. Text over
+// a limit is stored truncated, with its full form kept in object storage under
+// a reference that can be fetched separately.
+package payload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ErrNotFound is returned for a reference with no stored payload
+var ErrNotFound = errors.New("payload not found")
+
+// Store keeps full payloads by key
+type Store interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// Limits are the largest prompt and response, in bytes, stored inline.
+// Zero leaves that payload unbounded.
+type Limits struct {
+	MaxPromptBytes   int
+	MaxResponseBytes int
+}
+
+// Limiter truncates payloads over its limits, keeping the full text in a store
+type Limiter struct {
+	store  Store
+	limits Limits
+}
+
+// NewLimiter creates a limiter that keeps full payloads in store
+func NewLimiter(store Store, limits Limits) *Limiter {
+	return &Limiter{store: store, limits: limits}
+}
+
+// LimitPrompt returns the prompt to store under key and, if it had to be
+// truncated, the reference of the full prompt
+func (l *Limiter) LimitPrompt(ctx context.Context, key, prompt string) (string, string, error) {
+	return l.limit(ctx, key+"/prompt", prompt, l.limits.MaxPromptBytes)
+}
+
+// LimitResponse returns the response to store under key and, if it had to be
+// truncated, the reference of the full response
+func (l *Limiter) LimitResponse(ctx context.Context, key, response string) (string, string, error) {
+	return l.limit(ctx, key+"/response", response, l.limits.MaxResponseBytes)
+}
+
+func (l *Limiter) limit(ctx context.Context, ref, text string, max int) (string, string, error) {
+	if max <= 0 || len(text) <= max {
+		return text, "", nil
+	}
+	if err := l.store.Put(ctx, ref, []byte(text)); err != nil {
+		return "", "", fmt.Errorf("failed to store full payload: %w", err)
+	}
+	return Truncate(text, max, ref), ref, nil
+}
+
+// Get returns the full payload stored under ref
+func (l *Limiter) Get(ctx context.Context, ref string) (string, error) {
+	data, err := l.store.Get(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Truncate cuts text to at most max bytes, on a character boundary, and
+// appends a marker naming the full payload's size and reference
+func Truncate(text string, max int, ref string) string {
+	if len(text) <= max {
+		return text
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s\n\n[truncated: %d of %d bytes shown, full payload at %s]", text[:cut], cut, len(text), ref)
+}
+
+// =============================================================================
+// Stores
+// =============================================================================
+
+// FileStore keeps payloads as files under a directory, which may be a mounted
+// bucket
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates a store under dir, creating the directory if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create payload directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) Put(ctx context.Context, key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	// Write then rename so readers never see a partial payload
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *FileStore) Get(ctx context.Context, key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// path maps a key to a file, refusing keys that would escape the directory
+func (s *FileStore) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", fmt.Errorf("invalid payload key %q", key)
+	}
+	return filepath.Join(s.dir, clean), nil
+}
+
+// MemoryStore keeps payloads in memory; they are lost on restart
+type MemoryStore struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: make(map[string][]byte)}
+}
+
+func (s *MemoryStore) Put(ctx context.Context, key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = append([]byte(nil), data...)
+	return nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	data, ok := s.data[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return data, nil
+}
@@ -1,31 +1,49 @@
 package providers
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 )
 
-const anthropicAPIURL = "https://api.anthropic.com/v1/messages"
+const anthropicAPIURL = "https://api.anthropic.com/v1"
 
 // AnthropicProvider implements the Provider interface for Anthropic Claude
 type AnthropicProvider struct {
 	apiKey     string
+	baseURL    string
 	httpClient *http.Client
 	models     []ModelInfo
 }
 
 // NewAnthropicProvider creates a new Anthropic provider
 func NewAnthropicProvider(apiKey string) *AnthropicProvider {
+	return newAnthropicProvider(apiKey, Endpoint{})
+}
+
+// NewAnthropicProviderWithEndpoint creates an Anthropic provider whose
+// requests go to endpoint
+func NewAnthropicProviderWithEndpoint(apiKey string, endpoint Endpoint) (*AnthropicProvider, error) {
+	if err := endpoint.Validate(); err != nil {
+		return nil, err
+	}
+	if endpoint.Azure() {
+		return nil, fmt.Errorf("api_version and deployments only apply to Azure OpenAI")
+	}
+	return newAnthropicProvider(apiKey, endpoint), nil
+}
+
+func newAnthropicProvider(apiKey string, endpoint Endpoint) *AnthropicProvider {
 	return &AnthropicProvider{
-		apiKey: apiKey,
-		httpClient: &http.Client{
-			Timeout: 5 * time.Minute,
-		},
+		apiKey:     apiKey,
+		baseURL:    endpoint.URL(anthropicAPIURL),
+		httpClient: endpoint.HTTPClient(5 * time.Minute),
 		models: []ModelInfo{
 			{
 				ID: "claude-3-5-sonnet-20241022", Name: "Claude 3.5 Sonnet", ContextWindow: 200000, MaxOutput: 8192,
@@ -65,11 +83,24 @@ type anthropicRequest struct {
 	Temperature float64            `json:"temperature,omitempty"`
 	TopP        float64            `json:"top_p,omitempty"`
 	Tools       []anthropicTool    `json:"tools,omitempty"`
+	Stream      bool               `json:"stream,omitempty"`
 }
 
+// anthropicMessage content is a string, or content blocks for tool use
 type anthropicMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role    string      `json:"role"`
+	Content interface{} `json:"content"`
+}
+
+// anthropicContentBlock is a text, tool_use or tool_result content block
+type anthropicContentBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
 }
 
 type anthropicTool struct {
@@ -83,10 +114,7 @@ type anthropicResponse struct {
 	ID         string `json:"id"`
 	Type       string `json:"type"`
 	Role       string `json:"role"`
-	Content    []struct {
-		Type string `json:"type"`
-		Text string `json:"text,omitempty"`
-	} `json:"content"`
+	Content    []anthropicContentBlock `json:"content"`
 	Model      string `json:"model"`
 	StopReason string `json:"stop_reason"`
 	Usage      struct {
@@ -97,21 +125,58 @@ type anthropicResponse struct {
 
 // Complete sends a completion request
 func (p *AnthropicProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
-	// Extract system message
-	var systemPrompt string
-	var messages []anthropicMessage
-	
-	for _, msg := range req.Messages {
-		if msg.Role == "system" {
-			systemPrompt = msg.Content
-		} else {
-			messages = append(messages, anthropicMessage{
-				Role:    msg.Role,
-				Content: msg.Content,
+	resp, err := p.send(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var anthropicResp anthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&anthropicResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	// Extract text content and tool calls
+	var content string
+	var toolCalls []ToolCall
+	for _, c := range anthropicResp.Content {
+		switch c.Type {
+		case "text":
+			content += c.Text
+		case "tool_use":
+			toolCalls = append(toolCalls, ToolCall{
+				ID:       c.ID,
+				Type:     "function",
+				Function: FunctionCall{Name: c.Name, Arguments: string(c.Input)},
 			})
 		}
 	}
 
+	return &CompletionResponse{
+		ID:    anthropicResp.ID,
+		Model: anthropicResp.Model,
+		Message: Message{
+			Role:      anthropicResp.Role,
+			Content:   content,
+			ToolCalls: toolCalls,
+		},
+		FinishReason: anthropicResp.StopReason,
+		Usage: TokenUsage{
+			PromptTokens:     anthropicResp.Usage.InputTokens,
+			CompletionTokens: anthropicResp.Usage.OutputTokens,
+			TotalTokens:      anthropicResp.Usage.InputTokens + anthropicResp.Usage.OutputTokens,
+		},
+		CreatedAt: time.Now(),
+		RequestID: resp.Header.Get("request-id"),
+		RateLimit: parseAnthropicRateLimitHeaders(resp.Header),
+	}, nil
+}
+
+// send posts a request to the Messages API and returns the successful
+// response, whose body the caller must close
+func (p *AnthropicProvider) send(ctx context.Context, req *CompletionRequest, stream bool) (*http.Response, error) {
+	systemPrompt, messages := anthropicMessages(req.Messages)
+
 	maxTokens := req.MaxTokens
 	if maxTokens == 0 {
 		maxTokens = 4096
@@ -124,6 +189,7 @@ func (p *AnthropicProvider) Complete(ctx context.Context, req *CompletionRequest
 		System:      systemPrompt,
 		Temperature: req.Temperature,
 		TopP:        req.TopP,
+		Stream:      stream,
 	}
 
 	// Add tools if provided
@@ -143,7 +209,7 @@ func (p *AnthropicProvider) Complete(ctx context.Context, req *CompletionRequest
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", anthropicAPIURL, bytes.NewReader(body))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/messages", bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -152,74 +218,168 @@ func (p *AnthropicProvider) Complete(ctx context.Context, req *CompletionRequest
 	httpReq.Header.Set("x-api-key", p.apiKey)
 	httpReq.Header.Set("anthropic-version", "2023-06-01")
 
+	if stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+
 	resp, err := p.httpClient.Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("anthropic API error: %d - %s", resp.StatusCode, string(bodyBytes))
+		return nil, &APIError{Provider: "anthropic", StatusCode: resp.StatusCode, Body: string(bodyBytes)}
 	}
+	return resp, nil
+}
 
-	var anthropicResp anthropicResponse
-	if err := json.NewDecoder(resp.Body).Decode(&anthropicResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
+// anthropicMessages separates the system prompt from the conversation and
+// converts tool calls and results to content blocks. Anthropic expects tool
+// results in a user turn, so consecutive results share one.
+func anthropicMessages(msgs []Message) (string, []anthropicMessage) {
+	var systemPrompt string
+	var messages []anthropicMessage
 
-	// Extract text content
-	var content string
-	for _, c := range anthropicResp.Content {
-		if c.Type == "text" {
-			content += c.Text
+	for _, msg := range msgs {
+		switch {
+		case msg.Role == "system":
+			systemPrompt = msg.Content
+		case msg.Role == "assistant" && len(msg.ToolCalls) > 0:
+			var blocks []anthropicContentBlock
+			if msg.Content != "" {
+				blocks = append(blocks, anthropicContentBlock{Type: "text", Text: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				input := json.RawMessage(call.Function.Arguments)
+				if !json.Valid(input) {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, anthropicContentBlock{Type: "tool_use", ID: call.ID, Name: call.Function.Name, Input: input})
+			}
+			messages = append(messages, anthropicMessage{Role: "assistant", Content: blocks})
+		case msg.Role == "tool":
+			block := anthropicContentBlock{Type: "tool_result", ToolUseID: msg.ToolCallID, Content: msg.Content}
+			if n := len(messages); n > 0 {
+				if blocks, ok := messages[n-1].Content.([]anthropicContentBlock); ok && messages[n-1].Role == "user" {
+					messages[n-1].Content = append(blocks, block)
+					continue
+				}
+			}
+			messages = append(messages, anthropicMessage{Role: "user", Content: []anthropicContentBlock{block}})
+		default:
+			messages = append(messages, anthropicMessage{Role: msg.Role, Content: msg.Content})
 		}
 	}
+	return systemPrompt, messages
+}
 
-	return &CompletionResponse{
-		ID:    anthropicResp.ID,
-		Model: anthropicResp.Model,
-		Message: Message{
-			Role:    anthropicResp.Role,
-			Content: content,
-		},
-		FinishReason: anthropicResp.StopReason,
-		Usage: TokenUsage{
-			PromptTokens:     anthropicResp.Usage.InputTokens,
-			CompletionTokens: anthropicResp.Usage.OutputTokens,
-			TotalTokens:      anthropicResp.Usage.InputTokens + anthropicResp.Usage.OutputTokens,
-		},
-		CreatedAt: time.Now(),
-	}, nil
+// anthropicStreamEvent is an event of a streamed Messages API response. Only
+// the fields of the events the provider reads are declared.
+type anthropicStreamEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		ID    string `json:"id"`
+		Usage struct {
+			InputTokens int `json:"input_tokens"`
+		} `json:"usage"`
+	} `json:"message"`
+	Delta struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage struct {
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
 }
 
-// Stream sends a streaming completion request
+// Stream sends a streaming completion request. Text deltas are sent as they
+// arrive; the last chunk carries the stop reason and token usage.
 func (p *AnthropicProvider) Stream(ctx context.Context, req *CompletionRequest) (<-chan StreamChunk, error) {
-	// For now, fall back to non-streaming
-	// TODO: Implement SSE streaming
-	resp, err := p.Complete(ctx, req)
+	resp, err := p.send(ctx, req, true)
 	if err != nil {
 		return nil, err
 	}
 
-	chunks := make(chan StreamChunk, 1)
+	chunks := make(chan StreamChunk)
 	go func() {
 		defer close(chunks)
-		chunks <- StreamChunk{
-			ID:           resp.ID,
-			Delta:        resp.Message.Content,
-			FinishReason: resp.FinishReason,
-			Usage:        &resp.Usage,
-		}
+		defer resp.Body.Close()
+		readAnthropicStream(ctx, resp.Body, chunks)
 	}()
 
 	return chunks, nil
 }
 
-// CountTokens estimates token count
+// readAnthropicStream parses server-sent events from body into chunks until
+// the message stops, the stream ends or ctx is done
+func readAnthropicStream(ctx context.Context, body io.Reader, chunks chan<- StreamChunk) {
+	send := func(chunk StreamChunk) bool {
+		select {
+		case chunks <- chunk:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	var id, stopReason string
+	var usage TokenUsage
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue // event names, comments and blank separators
+		}
+
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			send(StreamChunk{ID: id, Error: fmt.Errorf("failed to decode stream event: %w", err)})
+			return
+		}
+
+		switch event.Type {
+		case "message_start":
+			id = event.Message.ID
+			usage.PromptTokens = event.Message.Usage.InputTokens
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+				if !send(StreamChunk{ID: id, Delta: event.Delta.Text}) {
+					return
+				}
+			}
+		case "message_delta":
+			if event.Delta.StopReason != "" {
+				stopReason = event.Delta.StopReason
+			}
+			usage.CompletionTokens = event.Usage.OutputTokens
+		case "message_stop":
+			usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+			send(StreamChunk{ID: id, FinishReason: stopReason, Usage: &usage})
+			return
+		case "error":
+			send(StreamChunk{ID: id, Error: fmt.Errorf("anthropic stream error: %s - %s", event.Error.Type, event.Error.Message)})
+			return
+		}
+	}
+
+	err := scanner.Err()
+	if err == nil {
+		err = io.ErrUnexpectedEOF
+	}
+	send(StreamChunk{ID: id, Error: fmt.Errorf("anthropic stream ended early: %w", err)})
+}
+
+// CountTokens estimates token count for Claude models
 func (p *AnthropicProvider) CountTokens(text string) (int, error) {
-	// Approximate: ~4 chars per token for English
-	return len(text) / 4, nil
+	return CountTokens("claude", text), nil
 }
 
 // GetModels returns available models
@@ -227,20 +387,29 @@ func (p *AnthropicProvider) GetModels() []ModelInfo {
 	return p.models
 }
 
-// ValidateAPIKey validates the API key
+// ValidateAPIKey validates the API key against the provider's endpoint
 func (p *AnthropicProvider) ValidateAPIKey(ctx context.Context, key string) error {
-	// Send a minimal request to verify the key
-	tempProvider := NewAnthropicProvider(key)
-	_, err := tempProvider.Complete(ctx, &CompletionRequest{
-		Model: "claude-3-haiku-20240307",
-		Messages: []Message{
-			{Role: "user", Content: "Hi"},
-		},
-		MaxTokens: 10,
-	})
+	// Listing models verifies the key without paying for a completion
+	req, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"/models?limit=1", nil)
 	if err != nil {
-		return fmt.Errorf("invalid API key: %w", err)
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("x-api-key", key)
+	req.Header.Set("anthropic-version", "2023-06-01")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return nil
+	case rejectedKey(resp.StatusCode):
+		return fmt.Errorf("%w: status %d", ErrInvalidAPIKey, resp.StatusCode)
+	default:
+		return fmt.Errorf("anthropic API error: %d", resp.StatusCode)
 	}
-	return nil
 }
 
@@ -0,0 +1,63 @@
+package providers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// =============================================================================
+// Model Capabilities
+// =============================================================================
+
+// Capabilities a model may have, as listed in ModelInfo.Capabilities
+const (
+	CapabilityText            = "text"
+	CapabilityVision          = "vision"
+	CapabilityFunctionCalling = "function_calling"
+	CapabilityReasoning       = "reasoning"
+)
+
+// IsCapability reports whether name is a capability models are described with
+func IsCapability(name string) bool {
+	switch name {
+	case CapabilityText, CapabilityVision, CapabilityFunctionCalling, CapabilityReasoning:
+		return true
+	}
+	return false
+}
+
+// HasCapabilities reports whether the model has every one of required
+func (m ModelInfo) HasCapabilities(required ...string) bool {
+	return len(m.MissingCapabilities(required...)) == 0
+}
+
+// MissingCapabilities returns those of required the model lacks, in order
+func (m ModelInfo) MissingCapabilities(required ...string) []string {
+	var missing []string
+	for _, capability := range required {
+		found := false
+		for _, has := range m.Capabilities {
+			if has == capability {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, capability)
+		}
+	}
+	return missing
+}
+
+// CheckCapabilities returns an error listing the capabilities a known model
+// lacks. Models without pricing, such as local Ollama models, aren't checked.
+func CheckCapabilities(model string, required []string) error {
+	info, ok := DefaultPricing()[model]
+	if !ok {
+		return nil
+	}
+	if missing := info.MissingCapabilities(required...); len(missing) > 0 {
+		return fmt.Errorf("model %s does not support %s", model, strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/security"
+)
+
+// Endpoint configures where a provider's requests are sent, for deployments
+// that route through Azure OpenAI, a corporate proxy or a self-hosted
+// gateway. The zero Endpoint uses the provider's public API.
+type Endpoint struct {
+	// BaseURL replaces the provider's API URL, such as
+	// https://gateway.example.com/openai/v1
+	BaseURL string `json:"base_url,omitempty"`
+
+	// ProxyURL sends requests through an HTTP proxy. When empty, the
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables apply.
+	ProxyURL string `json:"proxy_url,omitempty"`
+
+	// APIVersion is Azure OpenAI's api-version query parameter
+	APIVersion string `json:"api_version,omitempty"`
+
+	// Deployments maps models to Azure OpenAI deployment names. Models
+	// without a deployment are routed to a deployment named after the model.
+	Deployments map[string]string `json:"deployments,omitempty"`
+
+	// publicOnly restricts requests to public addresses
+	publicOnly bool
+}
+
+// defaultAzureAPIVersion is the Azure OpenAI api-version used when an Azure
+// endpoint sets none
+const defaultAzureAPIVersion = "2024-06-01"
+
+// IsZero reports whether the endpoint leaves every setting at its default
+func (e Endpoint) IsZero() bool {
+	return e.BaseURL == "" && e.ProxyURL == "" && e.APIVersion == "" && len(e.Deployments) == 0
+}
+
+// Azure reports whether the endpoint is an Azure OpenAI resource: its host
+// is under openai.azure.com, or it sets an api-version
+func (e Endpoint) Azure() bool {
+	if e.APIVersion != "" {
+		return true
+	}
+	u, err := url.Parse(e.BaseURL)
+	return err == nil && strings.HasSuffix(u.Hostname(), ".openai.azure.com")
+}
+
+// Validate checks that the base and proxy URLs are absolute http(s) URLs
+func (e Endpoint) Validate() error {
+	if err := checkEndpointURL("base_url", e.BaseURL); err != nil {
+		return err
+	}
+	if err := checkEndpointURL("proxy_url", e.ProxyURL); err != nil {
+		return err
+	}
+	if e.APIVersion != "" && e.BaseURL == "" {
+		return fmt.Errorf("api_version needs the Azure OpenAI resource's base_url")
+	}
+	if len(e.Deployments) > 0 && !e.Azure() {
+		return fmt.Errorf("deployments need an Azure OpenAI base_url or api_version")
+	}
+	for model, deployment := range e.Deployments {
+		if strings.TrimSpace(model) == "" || strings.TrimSpace(deployment) == "" {
+			return fmt.Errorf("deployments need a model and a deployment name")
+		}
+	}
+	return nil
+}
+
+func checkEndpointURL(field, raw string) error {
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s must be an absolute http(s) URL", field)
+	}
+	return nil
+}
+
+// PublicOnly returns the endpoint with its requests restricted to public
+// addresses. Tenants' endpoints are restricted, so a base_url can't reach the
+// platform's internal services.
+func (e Endpoint) PublicOnly() Endpoint {
+	e.publicOnly = true
+	return e
+}
+
+// ValidatePublic checks that the base and proxy URLs resolve to public
+// addresses, so an endpoint pointing at internal services is rejected when it
+// is saved
+func (e Endpoint) ValidatePublic(ctx context.Context) error {
+	for field, raw := range map[string]string{"base_url": e.BaseURL, "proxy_url": e.ProxyURL} {
+		if raw == "" {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil {
+			return fmt.Errorf("%s must be an absolute http(s) URL", field)
+		}
+		if err := security.ValidatePublicURL(ctx, u); err != nil {
+			if errors.Is(err, security.ErrNonPublicAddress) {
+				return fmt.Errorf("%s must not point to a private or internal address", field)
+			}
+			return fmt.Errorf("%s host can't be resolved", field)
+		}
+	}
+	return nil
+}
+
+// OpenAIChatURL returns the URL of OpenAI chat completions of model at the
+// endpoint. Azure OpenAI routes the model to its deployment.
+func (e Endpoint) OpenAIChatURL(model string) string {
+	if !e.Azure() {
+		return e.URL(openAIAPIURL) + "/chat/completions"
+	}
+	deployment := model
+	if d, ok := e.Deployments[model]; ok {
+		deployment = d
+	}
+	version := defaultAzureAPIVersion
+	if e.APIVersion != "" {
+		version = e.APIVersion
+	}
+	return fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+		e.URL(""), url.PathEscape(deployment), url.QueryEscape(version))
+}
+
+// URL returns the endpoint's base URL without a trailing slash, or fallback
+// when it sets none
+func (e Endpoint) URL(fallback string) string {
+	if e.BaseURL == "" {
+		return fallback
+	}
+	return strings.TrimSuffix(e.BaseURL, "/")
+}
+
+// HTTPClient returns a client for the endpoint's requests, sent through its
+// proxy if it has one. A public-only endpoint connects only to public
+// addresses and ignores the proxy environment variables, which could reach
+// internal services.
+func (e Endpoint) HTTPClient(timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if e.publicOnly {
+		transport.Proxy = nil
+		dialer := security.PublicDialer(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second})
+		transport.DialContext = dialer.DialContext
+	}
+	if proxy, err := url.Parse(e.ProxyURL); err == nil && e.ProxyURL != "" {
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
@@ -1,32 +1,65 @@
 package providers
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 )
 
-const googleAPIURL = "https://generativelanguage.googleapis.com/v1beta/models"
+const googleAPIURL = "https://generativelanguage.googleapis.com/v1beta"
 
 // GoogleProvider implements the Provider interface for Google Gemini
 type GoogleProvider struct {
 	apiKey     string
+	baseURL    string
 	httpClient *http.Client
 	models     []ModelInfo
 }
 
 // NewGoogleProvider creates a new Google AI provider
 func NewGoogleProvider(apiKey string) *GoogleProvider {
+	return newGoogleProvider(apiKey, Endpoint{})
+}
+
+// NewGoogleProviderWithEndpoint creates a Google AI provider whose requests
+// go to endpoint
+func NewGoogleProviderWithEndpoint(apiKey string, endpoint Endpoint) (*GoogleProvider, error) {
+	if err := endpoint.Validate(); err != nil {
+		return nil, err
+	}
+	if endpoint.Azure() {
+		return nil, fmt.Errorf("api_version and deployments only apply to Azure OpenAI")
+	}
+	return newGoogleProvider(apiKey, endpoint), nil
+}
+
+func newGoogleProvider(apiKey string, endpoint Endpoint) *GoogleProvider {
 	return &GoogleProvider{
-		apiKey: apiKey,
-		httpClient: &http.Client{
-			Timeout: 5 * time.Minute,
-		},
+		apiKey:     apiKey,
+		baseURL:    endpoint.URL(googleAPIURL),
+		httpClient: endpoint.HTTPClient(5 * time.Minute),
 		models: []ModelInfo{
+			{
+				ID: "gemini-2.5-pro", Name: "Gemini 2.5 Pro", ContextWindow: 1048576, MaxOutput: 65536,
+				InputPrice: 0.00125, OutputPrice: 0.01,
+				Capabilities: []string{"text", "vision", "function_calling"},
+			},
+			{
+				ID: "gemini-2.5-flash", Name: "Gemini 2.5 Flash", ContextWindow: 1048576, MaxOutput: 65536,
+				InputPrice: 0.0003, OutputPrice: 0.0025,
+				Capabilities: []string{"text", "vision", "function_calling"},
+			},
+			{
+				ID: "gemini-2.5-flash-lite", Name: "Gemini 2.5 Flash-Lite", ContextWindow: 1048576, MaxOutput: 65536,
+				InputPrice: 0.0001, OutputPrice: 0.0004,
+				Capabilities: []string{"text", "vision", "function_calling"},
+			},
 			{
 				ID: "gemini-1.5-pro", Name: "Gemini 1.5 Pro", ContextWindow: 2000000, MaxOutput: 8192,
 				InputPrice: 0.00125, OutputPrice: 0.005,
@@ -53,10 +86,10 @@ func (p *GoogleProvider) Name() string {
 
 // googleRequest represents the Google AI API request format
 type googleRequest struct {
-	Contents         []googleContent       `json:"contents"`
-	SystemInstruction *googleContent       `json:"systemInstruction,omitempty"`
-	GenerationConfig *googleGenerationConfig `json:"generationConfig,omitempty"`
-	Tools            []googleTool          `json:"tools,omitempty"`
+	Contents          []googleContent         `json:"contents"`
+	SystemInstruction *googleContent          `json:"systemInstruction,omitempty"`
+	GenerationConfig  *googleGenerationConfig `json:"generationConfig,omitempty"`
+	Tools             []googleTool            `json:"tools,omitempty"`
 }
 
 type googleContent struct {
@@ -103,32 +136,37 @@ type googleResponse struct {
 	} `json:"usageMetadata"`
 }
 
-// Complete sends a completion request
-func (p *GoogleProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
-	// Extract system message and convert to Google format
-	var systemContent *googleContent
+// buildRequest converts a completion request to the Gemini format. Gemini only
+// knows "user" and "model" turns, so assistant messages become "model", tool
+// results are sent as user turns, and system messages move to systemInstruction.
+func (p *GoogleProvider) buildRequest(req *CompletionRequest) googleRequest {
+	var systemParts []googlePart
 	var contents []googleContent
 
 	for _, msg := range req.Messages {
 		if msg.Role == "system" {
-			systemContent = &googleContent{
-				Parts: []googlePart{{Text: msg.Content}},
-			}
-		} else {
-			role := msg.Role
-			if role == "assistant" {
-				role = "model"
-			}
-			contents = append(contents, googleContent{
-				Role:  role,
-				Parts: []googlePart{{Text: msg.Content}},
-			})
+			systemParts = append(systemParts, googlePart{Text: msg.Content})
+			continue
+		}
+
+		role := "user"
+		if msg.Role == "assistant" {
+			role = "model"
+		}
+
+		// Consecutive turns from the same side are merged into one content
+		if n := len(contents); n > 0 && contents[n-1].Role == role {
+			contents[n-1].Parts = append(contents[n-1].Parts, googlePart{Text: msg.Content})
+			continue
 		}
+		contents = append(contents, googleContent{
+			Role:  role,
+			Parts: []googlePart{{Text: msg.Content}},
+		})
 	}
 
 	googleReq := googleRequest{
-		Contents:          contents,
-		SystemInstruction: systemContent,
+		Contents: contents,
 		GenerationConfig: &googleGenerationConfig{
 			Temperature:     req.Temperature,
 			TopP:            req.TopP,
@@ -136,6 +174,9 @@ func (p *GoogleProvider) Complete(ctx context.Context, req *CompletionRequest) (
 			StopSequences:   req.Stop,
 		},
 	}
+	if len(systemParts) > 0 {
+		googleReq.SystemInstruction = &googleContent{Parts: systemParts}
+	}
 
 	// Add tools if provided
 	if len(req.Tools) > 0 {
@@ -150,30 +191,48 @@ func (p *GoogleProvider) Complete(ctx context.Context, req *CompletionRequest) (
 		googleReq.Tools = []googleTool{{FunctionDeclarations: funcDecls}}
 	}
 
+	return googleReq
+}
+
+// do sends a request to a Gemini model method such as generateContent
+func (p *GoogleProvider) do(ctx context.Context, model, method string, googleReq googleRequest) (*http.Response, error) {
 	body, err := json.Marshal(googleReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	url := fmt.Sprintf("%s/%s:generateContent?key=%s", googleAPIURL, req.Model, p.apiKey)
+	url := fmt.Sprintf("%s/models/%s:%s", p.baseURL, model, method)
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	httpReq.Header.Set("Content-Type", "application/json")
+	// Sent as a header rather than a query parameter so the key stays out of URLs and logs
+	httpReq.Header.Set("x-goog-api-key", p.apiKey)
 
 	resp, err := p.httpClient.Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("google API error: %d - %s", resp.StatusCode, string(bodyBytes))
+		return nil, &APIError{Provider: "google", StatusCode: resp.StatusCode, Body: string(bodyBytes)}
 	}
 
+	return resp, nil
+}
+
+// Complete sends a completion request
+func (p *GoogleProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	resp, err := p.do(ctx, req.Model, "generateContent", p.buildRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
 	var googleResp googleResponse
 	if err := json.NewDecoder(resp.Body).Decode(&googleResp); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
@@ -197,41 +256,86 @@ func (p *GoogleProvider) Complete(ctx context.Context, req *CompletionRequest) (
 			Content: content,
 		},
 		FinishReason: candidate.FinishReason,
-		Usage: TokenUsage{
-			PromptTokens:     googleResp.UsageMetadata.PromptTokenCount,
-			CompletionTokens: googleResp.UsageMetadata.CandidatesTokenCount,
-			TotalTokens:      googleResp.UsageMetadata.TotalTokenCount,
-		},
-		CreatedAt: time.Now(),
+		Usage:        googleResp.usage(),
+		CreatedAt:    time.Now(),
 	}, nil
 }
 
 // Stream sends a streaming completion request
 func (p *GoogleProvider) Stream(ctx context.Context, req *CompletionRequest) (<-chan StreamChunk, error) {
-	// For now, fall back to non-streaming
-	resp, err := p.Complete(ctx, req)
+	resp, err := p.do(ctx, req.Model, "streamGenerateContent?alt=sse", p.buildRequest(req))
 	if err != nil {
 		return nil, err
 	}
 
-	chunks := make(chan StreamChunk, 1)
+	id := fmt.Sprintf("google-%d", time.Now().UnixNano())
+	chunks := make(chan StreamChunk)
 	go func() {
 		defer close(chunks)
-		chunks <- StreamChunk{
-			ID:           resp.ID,
-			Delta:        resp.Message.Content,
-			FinishReason: resp.FinishReason,
-			Usage:        &resp.Usage,
+		defer resp.Body.Close()
+
+		send := func(chunk StreamChunk) bool {
+			select {
+			case chunks <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok {
+				continue
+			}
+
+			var event googleResponse
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				send(StreamChunk{Error: fmt.Errorf("failed to decode stream event: %w", err)})
+				return
+			}
+			if len(event.Candidates) == 0 {
+				continue
+			}
+
+			candidate := event.Candidates[0]
+			chunk := StreamChunk{ID: id, FinishReason: candidate.FinishReason}
+			for _, part := range candidate.Content.Parts {
+				chunk.Delta += part.Text
+			}
+			// usageMetadata is cumulative; the final event carries the totals
+			if candidate.FinishReason != "" {
+				usage := event.usage()
+				chunk.Usage = &usage
+			}
+
+			if !send(chunk) {
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			send(StreamChunk{Error: err})
 		}
 	}()
 
 	return chunks, nil
 }
 
+// usage converts Gemini usage metadata to token usage
+func (r *googleResponse) usage() TokenUsage {
+	return TokenUsage{
+		PromptTokens:     r.UsageMetadata.PromptTokenCount,
+		CompletionTokens: r.UsageMetadata.CandidatesTokenCount,
+		TotalTokens:      r.UsageMetadata.TotalTokenCount,
+	}
+}
+
 // CountTokens estimates token count
 func (p *GoogleProvider) CountTokens(text string) (int, error) {
-	// Approximate: ~4 chars per token
-	return len(text) / 4, nil
+	return CountTokens("gemini", text), nil
 }
 
 // GetModels returns available models
@@ -242,11 +346,11 @@ func (p *GoogleProvider) GetModels() []ModelInfo {
 // ValidateAPIKey validates the API key
 func (p *GoogleProvider) ValidateAPIKey(ctx context.Context, key string) error {
 	// List models to verify the key
-	url := fmt.Sprintf("%s?key=%s", googleAPIURL, key)
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"/models", nil)
 	if err != nil {
 		return err
 	}
+	req.Header.Set("x-goog-api-key", key)
 
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
@@ -254,10 +358,14 @@ func (p *GoogleProvider) ValidateAPIKey(ctx context.Context, key string) error {
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("invalid API key: status %d", resp.StatusCode)
+	// Google answers a malformed or unknown key with 400 API_KEY_INVALID
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return nil
+	case resp.StatusCode == http.StatusBadRequest, rejectedKey(resp.StatusCode):
+		return fmt.Errorf("%w: status %d", ErrInvalidAPIKey, resp.StatusCode)
+	default:
+		return fmt.Errorf("google API error: %d", resp.StatusCode)
 	}
-
-	return nil
 }
 
@@ -0,0 +1,105 @@
+package providers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/metrics"
+	"github.com/delphi-platform/delphi/backend/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// =============================================================================
+// Instrumentation
+// =============================================================================
+
+// instrumentedProvider records the latency and outcome of a provider's
+// completions and streams
+type instrumentedProvider struct {
+	Provider
+}
+
+// Instrument wraps a provider so its Complete and Stream calls are recorded
+// in the provider request metrics and traced as spans. Providers the manager
+// registers or creates are instrumented already.
+func Instrument(provider Provider) Provider {
+	if _, ok := provider.(*instrumentedProvider); ok {
+		return provider
+	}
+	return &instrumentedProvider{Provider: provider}
+}
+
+func (p *instrumentedProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	ctx, span := p.startSpan(ctx, "provider.complete", req)
+	start := time.Now()
+	resp, err := p.Provider.Complete(ctx, req)
+	metrics.ObserveProviderRequest(p.Name(), "complete", start, err)
+	if resp != nil {
+		recordUsage(span, req.Model, resp.Usage)
+	}
+	tracing.End(span, err)
+	return resp, err
+}
+
+// Stream times the whole stream, which fails if any chunk carries an error
+func (p *instrumentedProvider) Stream(ctx context.Context, req *CompletionRequest) (<-chan StreamChunk, error) {
+	ctx, span := p.startSpan(ctx, "provider.stream", req)
+	start := time.Now()
+	chunks, err := p.Provider.Stream(ctx, req)
+	if err != nil {
+		metrics.ObserveProviderRequest(p.Name(), "stream", start, err)
+		tracing.End(span, err)
+		return nil, err
+	}
+
+	out := make(chan StreamChunk)
+	go func() {
+		defer close(out)
+		var streamErr error
+		for chunk := range chunks {
+			if chunk.Error != nil {
+				streamErr = chunk.Error
+			}
+			if chunk.Usage != nil {
+				recordUsage(span, req.Model, *chunk.Usage)
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				// The reader is gone; drain so the provider can finish
+				for range chunks {
+				}
+				metrics.ObserveProviderRequest(p.Name(), "stream", start, ctx.Err())
+				tracing.End(span, ctx.Err())
+				return
+			}
+		}
+		metrics.ObserveProviderRequest(p.Name(), "stream", start, streamErr)
+		tracing.End(span, streamErr)
+	}()
+	return out, nil
+}
+
+func (p *instrumentedProvider) startSpan(ctx context.Context, name string, req *CompletionRequest) (context.Context, trace.Span) {
+	return tracing.Start(ctx, name,
+		attribute.String("delphi.provider", p.Name()),
+		attribute.String("delphi.model", req.Model),
+	)
+}
+
+// spanPricing prices the usage recorded on provider spans
+var spanPricing = sync.OnceValue(func() *CostCalculator {
+	calc := NewCostCalculator()
+	for model, info := range DefaultPricing() {
+		calc.SetPricing(model, info)
+	}
+	return calc
+})
+
+// recordUsage adds a completion's tokens, and their cost at default pricing,
+// to its span
+func recordUsage(span trace.Span, model string, usage TokenUsage) {
+	tracing.RecordUsage(span, usage.PromptTokens, usage.CompletionTokens, spanPricing().Calculate(model, usage))
+}
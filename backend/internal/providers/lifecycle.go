@@ -0,0 +1,124 @@
+package providers
+
+import (
+	"fmt"
+	"time"
+)
+
+// =============================================================================
+// Model Lifecycle
+// =============================================================================
+
+// retirementWarningWindow is how long before retirement a model starts
+// producing warnings even if the provider has not formally deprecated it yet
+const retirementWarningWindow = 90 * 24 * time.Hour
+
+// ModelLifecycle records when a provider deprecates and retires a model
+type ModelLifecycle struct {
+	DeprecatedAt time.Time
+	RetiresAt    time.Time
+	Successor    string
+}
+
+// modelLifecycles lists the announced deprecations of the models we ship with.
+// Keep this in sync with the providers' deprecation pages.
+var modelLifecycles = map[string]ModelLifecycle{
+	// OpenAI
+	"o1-mini": {
+		DeprecatedAt: date(2025, 4, 28),
+		RetiresAt:    date(2025, 10, 27),
+		Successor:    "o4-mini",
+	},
+
+	// Anthropic
+	"claude-3-opus-20240229": {
+		DeprecatedAt: date(2025, 6, 30),
+		RetiresAt:    date(2026, 1, 5),
+		Successor:    "claude-opus-4-20250514",
+	},
+	"claude-3-5-sonnet-20241022": {
+		DeprecatedAt: date(2025, 8, 13),
+		RetiresAt:    date(2025, 10, 22),
+		Successor:    "claude-sonnet-4-20250514",
+	},
+
+	// Google
+	"gemini-1.5-pro": {
+		DeprecatedAt: date(2025, 4, 29),
+		RetiresAt:    date(2025, 9, 24),
+		Successor:    "gemini-2.5-pro",
+	},
+	"gemini-1.5-flash": {
+		DeprecatedAt: date(2025, 4, 29),
+		RetiresAt:    date(2025, 9, 24),
+		Successor:    "gemini-2.5-flash",
+	},
+	"gemini-1.5-flash-8b": {
+		DeprecatedAt: date(2025, 4, 29),
+		RetiresAt:    date(2025, 9, 24),
+		Successor:    "gemini-2.5-flash-lite",
+	},
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// GetModelLifecycle returns the announced lifecycle of a model, if any
+func GetModelLifecycle(model string) (ModelLifecycle, bool) {
+	lifecycle, ok := modelLifecycles[model]
+	return lifecycle, ok
+}
+
+// CheckModel reports whether a model can still be used. It returns a warning
+// for deprecated models and models retiring soon, and an error once the model
+// has been retired.
+func CheckModel(model string) (string, error) {
+	lifecycle, ok := modelLifecycles[model]
+	if !ok {
+		return "", nil
+	}
+
+	now := time.Now()
+	suggestion := ""
+	if lifecycle.Successor != "" {
+		suggestion = fmt.Sprintf("; use %s instead", lifecycle.Successor)
+	}
+
+	if !lifecycle.RetiresAt.IsZero() && !now.Before(lifecycle.RetiresAt) {
+		return "", fmt.Errorf("model %s was retired on %s%s", model, lifecycle.RetiresAt.Format("2006-01-02"), suggestion)
+	}
+
+	deprecated := !lifecycle.DeprecatedAt.IsZero() && !now.Before(lifecycle.DeprecatedAt)
+	retiringSoon := !lifecycle.RetiresAt.IsZero() && lifecycle.RetiresAt.Sub(now) <= retirementWarningWindow
+	if !deprecated && !retiringSoon {
+		return "", nil
+	}
+
+	if lifecycle.RetiresAt.IsZero() {
+		return fmt.Sprintf("model %s is deprecated%s", model, suggestion), nil
+	}
+	return fmt.Sprintf("model %s is deprecated and will be retired on %s%s", model, lifecycle.RetiresAt.Format("2006-01-02"), suggestion), nil
+}
+
+// withLifecycle annotates model info with its deprecation and retirement dates
+func withLifecycle(info ModelInfo) ModelInfo {
+	lifecycle, ok := modelLifecycles[info.ID]
+	if !ok {
+		return info
+	}
+
+	now := time.Now()
+	if !lifecycle.DeprecatedAt.IsZero() {
+		deprecatedAt := lifecycle.DeprecatedAt
+		info.DeprecatedAt = &deprecatedAt
+	}
+	if !lifecycle.RetiresAt.IsZero() {
+		retiresAt := lifecycle.RetiresAt
+		info.RetiresAt = &retiresAt
+	}
+	info.Deprecated = info.DeprecatedAt != nil && !now.Before(*info.DeprecatedAt)
+	info.Retired = info.RetiresAt != nil && !now.Before(*info.RetiresAt)
+	info.Successor = lifecycle.Successor
+	return info
+}
@@ -14,6 +14,8 @@ import (
 type Manager struct {
 	registry       *Registry
 	costCalculator *CostCalculator
+	rateLimits     *RateLimitTracker
+	endpoints      map[models.AIProvider]Endpoint
 	mu             sync.RWMutex
 }
 
@@ -22,6 +24,8 @@ func NewManager() *Manager {
 	m := &Manager{
 		registry:       NewRegistry(),
 		costCalculator: NewCostCalculator(),
+		rateLimits:     NewRateLimitTracker(30 * time.Second),
+		endpoints:      make(map[models.AIProvider]Endpoint),
 	}
 
 	// Load default pricing
@@ -32,11 +36,12 @@ func NewManager() *Manager {
 	return m
 }
 
-// RegisterProvider registers a provider with the manager
+// RegisterProvider registers a provider with the manager, instrumented for
+// the provider request metrics
 func (m *Manager) RegisterProvider(provider Provider) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.registry.Register(provider)
+	m.registry.Register(Instrument(provider))
 
 	// Add pricing for this provider's models
 	for _, model := range provider.GetModels() {
@@ -51,17 +56,52 @@ func (m *Manager) GetProvider(name string) (Provider, error) {
 	return m.registry.Get(name)
 }
 
-// CreateProviderWithKey creates a provider instance with the given API key
-func (m *Manager) CreateProviderWithKey(providerName models.AIProvider, apiKey string, baseURL string) (Provider, error) {
+// SetEndpoint sets the endpoint providers created with CreateProviderWithKey
+// use when they are given none
+func (m *Manager) SetEndpoint(providerName models.AIProvider, endpoint Endpoint) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.endpoints[providerName] = endpoint
+}
+
+// CreateProviderWithKey creates a provider instance with the given API key,
+// instrumented for the provider request metrics. Its requests go to endpoint,
+// or to the provider's default endpoint when endpoint is zero.
+func (m *Manager) CreateProviderWithKey(providerName models.AIProvider, apiKey string, endpoint Endpoint) (Provider, error) {
+	if endpoint.IsZero() {
+		m.mu.RLock()
+		endpoint = m.endpoints[providerName]
+		m.mu.RUnlock()
+	}
+
 	switch providerName {
 	case models.ProviderOpenAI:
-		return NewOpenAIProvider(apiKey), nil
+		provider, err := NewOpenAIProviderWithEndpoint(apiKey, endpoint)
+		if err != nil {
+			return nil, err
+		}
+		return Instrument(provider), nil
 	case models.ProviderAnthropic:
-		return NewAnthropicProvider(apiKey), nil
+		provider, err := NewAnthropicProviderWithEndpoint(apiKey, endpoint)
+		if err != nil {
+			return nil, err
+		}
+		return Instrument(provider), nil
 	case models.ProviderGoogle:
-		return NewGoogleProvider(apiKey), nil
+		provider, err := NewGoogleProviderWithEndpoint(apiKey, endpoint)
+		if err != nil {
+			return nil, err
+		}
+		return Instrument(provider), nil
 	case models.ProviderOllama:
-		return NewOllamaProvider(baseURL), nil
+		if err := endpoint.Validate(); err != nil {
+			return nil, err
+		}
+		provider := NewOllamaProvider(endpoint.BaseURL)
+		if endpoint.ProxyURL != "" || endpoint.publicOnly {
+			provider.httpClient = endpoint.HTTPClient(provider.httpClient.Timeout)
+		}
+		return Instrument(provider), nil
 	default:
 		return nil, fmt.Errorf("unsupported provider: %s", providerName)
 	}
@@ -70,12 +110,26 @@ func (m *Manager) CreateProviderWithKey(providerName models.AIProvider, apiKey s
 // Complete sends a completion request using the specified provider
 func (m *Manager) Complete(ctx context.Context, provider Provider, req *CompletionRequest) (*CompletionResponse, error) {
 	start := time.Now()
-	
+
+	// Retired models fail at the provider anyway; reject them with a clear message
+	warning, err := CheckModel(req.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	// Throttle pre-emptively if the provider reported an exhausted quota
+	if err := m.rateLimits.Wait(ctx, provider.Name(), req.MaxTokens); err != nil {
+		return nil, err
+	}
+
 	resp, err := provider.Complete(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
+	m.rateLimits.Update(provider.Name(), resp.RateLimit)
+	resp.ModelWarning = warning
+
 	// Calculate cost
 	cost := m.costCalculator.Calculate(req.Model, resp.Usage)
 	
@@ -85,6 +139,11 @@ func (m *Manager) Complete(ctx context.Context, provider Provider, req *Completi
 	return resp, nil
 }
 
+// RateLimits returns the latest rate-limit state reported by each provider
+func (m *Manager) RateLimits() map[string]RateLimitInfo {
+	return m.rateLimits.Snapshot()
+}
+
 // Stream sends a streaming request using the specified provider
 func (m *Manager) Stream(ctx context.Context, provider Provider, req *CompletionRequest) (<-chan StreamChunk, error) {
 	return provider.Stream(ctx, req)
@@ -105,7 +164,7 @@ func (m *Manager) GetModelInfo(model string) (ModelInfo, bool) {
 		provider, _ := m.registry.Get(name)
 		for _, info := range provider.GetModels() {
 			if info.ID == model {
-				return info, true
+				return withLifecycle(info), true
 			}
 		}
 	}
@@ -113,7 +172,7 @@ func (m *Manager) GetModelInfo(model string) (ModelInfo, bool) {
 	// Check default pricing
 	pricing := DefaultPricing()
 	if info, ok := pricing[model]; ok {
-		return info, true
+		return withLifecycle(info), true
 	}
 
 	return ModelInfo{}, false
@@ -131,7 +190,7 @@ func (m *Manager) ListAllModels() []ModelInfo {
 		provider, _ := m.registry.Get(name)
 		for _, info := range provider.GetModels() {
 			if !seen[info.ID] {
-				models = append(models, info)
+				models = append(models, withLifecycle(info))
 				seen[info.ID] = true
 			}
 		}
@@ -191,6 +250,12 @@ func (b *RequestBuilder) WithTemperature(temp float64) *RequestBuilder {
 	return b
 }
 
+// WithTopP sets the nucleus sampling threshold
+func (b *RequestBuilder) WithTopP(topP float64) *RequestBuilder {
+	b.req.TopP = topP
+	return b
+}
+
 // WithMaxTokens sets the max tokens
 func (b *RequestBuilder) WithMaxTokens(tokens int) *RequestBuilder {
 	b.req.MaxTokens = tokens
@@ -121,7 +121,7 @@ func (p *OllamaProvider) Complete(ctx context.Context, req *CompletionRequest) (
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("ollama API error: %d - %s", resp.StatusCode, string(bodyBytes))
+		return nil, &APIError{Provider: "ollama", StatusCode: resp.StatusCode, Body: string(bodyBytes)}
 	}
 
 	var ollamaResp ollamaResponse
@@ -230,8 +230,8 @@ func (p *OllamaProvider) Stream(ctx context.Context, req *CompletionRequest) (<-
 
 // CountTokens estimates token count
 func (p *OllamaProvider) CountTokens(text string) (int, error) {
-	// Approximate: ~4 chars per token
-	return len(text) / 4, nil
+	// Local models' tokenizers vary; cl100k_base is a close estimate
+	return CountTokens("", text), nil
 }
 
 // GetModels returns available models from Ollama
@@ -245,25 +245,36 @@ func (p *OllamaProvider) GetModels() []ModelInfo {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	models, err := p.ListModels(ctx)
+	if err != nil {
+		return nil
+	}
+
+	p.models = models
+	return models
+}
+
+// ListModels fetches the models available on the Ollama server
+func (p *OllamaProvider) ListModels(ctx context.Context) ([]ModelInfo, error) {
 	url := fmt.Sprintf("%s/api/tags", p.baseURL)
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
-		return nil
+		return nil, fmt.Errorf("ollama server not reachable: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil
+		return nil, fmt.Errorf("ollama server error: %d", resp.StatusCode)
 	}
 
 	var modelsResp ollamaModelsResponse
 	if err := json.NewDecoder(resp.Body).Decode(&modelsResp); err != nil {
-		return nil
+		return nil, fmt.Errorf("failed to decode models: %w", err)
 	}
 
 	models := make([]ModelInfo, len(modelsResp.Models))
@@ -279,9 +290,7 @@ func (p *OllamaProvider) GetModels() []ModelInfo {
 			Capabilities: []string{"text"},
 		}
 	}
-
-	p.models = models
-	return models
+	return models, nil
 }
 
 // ValidateAPIKey validates the API key (for Ollama, just checks connectivity)
@@ -2,24 +2,63 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/sashabaranov/go-openai"
 )
 
+const openAIAPIURL = "https://api.openai.com/v1"
+
 // OpenAIProvider implements the Provider interface for OpenAI
 type OpenAIProvider struct {
-	client *openai.Client
-	models []ModelInfo
+	client   *openai.Client
+	endpoint Endpoint
+	models   []ModelInfo
 }
 
 // NewOpenAIProvider creates a new OpenAI provider
 func NewOpenAIProvider(apiKey string) *OpenAIProvider {
-	client := openai.NewClient(apiKey)
-	
+	return newOpenAIProvider(openai.NewClient(apiKey), Endpoint{})
+}
+
+// NewOpenAIProviderWithEndpoint creates an OpenAI provider whose requests go
+// to endpoint. Azure OpenAI endpoints route each model to its deployment and
+// send the api-version.
+func NewOpenAIProviderWithEndpoint(apiKey string, endpoint Endpoint) (*OpenAIProvider, error) {
+	if err := endpoint.Validate(); err != nil {
+		return nil, err
+	}
+
+	config := openai.DefaultConfig(apiKey)
+	if endpoint.Azure() {
+		config = openai.DefaultAzureConfig(apiKey, endpoint.URL(""))
+		config.APIVersion = defaultAzureAPIVersion
+		if endpoint.APIVersion != "" {
+			config.APIVersion = endpoint.APIVersion
+		}
+		deployments := endpoint.Deployments
+		config.AzureModelMapperFunc = func(model string) string {
+			if deployment, ok := deployments[model]; ok {
+				return deployment
+			}
+			return model
+		}
+	} else {
+		config.BaseURL = endpoint.URL(config.BaseURL)
+	}
+	if endpoint.ProxyURL != "" || endpoint.publicOnly {
+		config.HTTPClient = endpoint.HTTPClient(0)
+	}
+	return newOpenAIProvider(openai.NewClientWithConfig(config), endpoint), nil
+}
+
+func newOpenAIProvider(client *openai.Client, endpoint Endpoint) *OpenAIProvider {
 	return &OpenAIProvider{
-		client: client,
+		client:   client,
+		endpoint: endpoint,
 		models: []ModelInfo{
 			{
 				ID: "gpt-4o", Name: "GPT-4o", ContextWindow: 128000, MaxOutput: 16384,
@@ -55,20 +94,37 @@ func (p *OpenAIProvider) Name() string {
 	return "openai"
 }
 
-// Complete sends a completion request
-func (p *OpenAIProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
-	messages := make([]openai.ChatCompletionMessage, len(req.Messages))
-	for i, msg := range req.Messages {
+// openAIMessages converts messages, including the tool calls of assistant
+// turns and the call IDs of tool results
+func openAIMessages(msgs []Message) []openai.ChatCompletionMessage {
+	messages := make([]openai.ChatCompletionMessage, len(msgs))
+	for i, msg := range msgs {
 		messages[i] = openai.ChatCompletionMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
-			Name:    msg.Name,
+			Role:       msg.Role,
+			Content:    msg.Content,
+			Name:       msg.Name,
+			ToolCallID: msg.ToolCallID,
+		}
+		for _, call := range msg.ToolCalls {
+			messages[i].ToolCalls = append(messages[i].ToolCalls, openai.ToolCall{
+				ID:   call.ID,
+				Type: openai.ToolTypeFunction,
+				Function: openai.FunctionCall{
+					Name:      call.Function.Name,
+					Arguments: call.Function.Arguments,
+				},
+			})
 		}
 	}
+	return messages
+}
 
+// openAIChatRequest converts a completion request, mapping its tools and tool
+// choice, for both completions and streams
+func openAIChatRequest(req *CompletionRequest) openai.ChatCompletionRequest {
 	chatReq := openai.ChatCompletionRequest{
 		Model:       req.Model,
-		Messages:    messages,
+		Messages:    openAIMessages(req.Messages),
 		MaxTokens:   req.MaxTokens,
 		Temperature: float32(req.Temperature),
 		TopP:        float32(req.TopP),
@@ -88,9 +144,31 @@ func (p *OpenAIProvider) Complete(ctx context.Context, req *CompletionRequest) (
 				},
 			}
 		}
+		chatReq.ToolChoice = openAIToolChoice(req.ToolChoice)
 	}
 
-	resp, err := p.client.CreateChatCompletion(ctx, chatReq)
+	return chatReq
+}
+
+// openAIToolChoice maps a tool choice: "auto", "none" and "required" pass
+// through, and any other value names the function the model must call
+func openAIToolChoice(choice string) any {
+	switch choice {
+	case "":
+		return nil
+	case "auto", "none", "required":
+		return choice
+	default:
+		return openai.ToolChoice{
+			Type:     openai.ToolTypeFunction,
+			Function: openai.ToolFunction{Name: choice},
+		}
+	}
+}
+
+// Complete sends a completion request
+func (p *OpenAIProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	resp, err := p.client.CreateChatCompletion(ctx, openAIChatRequest(req))
 	if err != nil {
 		return nil, fmt.Errorf("openai completion failed: %w", err)
 	}
@@ -131,29 +209,19 @@ func (p *OpenAIProvider) Complete(ctx context.Context, req *CompletionRequest) (
 			CompletionTokens: resp.Usage.CompletionTokens,
 			TotalTokens:      resp.Usage.TotalTokens,
 		},
+		CreatedAt: time.Unix(resp.Created, 0),
+		RequestID: resp.Header().Get("x-request-id"),
+		RateLimit: parseOpenAIRateLimitHeaders(resp.Header()),
 	}, nil
 }
 
-// Stream sends a streaming completion request
+// Stream sends a streaming completion request. The client parses the data:
+// events and ends the stream at [DONE].
 func (p *OpenAIProvider) Stream(ctx context.Context, req *CompletionRequest) (<-chan StreamChunk, error) {
-	messages := make([]openai.ChatCompletionMessage, len(req.Messages))
-	for i, msg := range req.Messages {
-		messages[i] = openai.ChatCompletionMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
-			Name:    msg.Name,
-		}
-	}
-
-	chatReq := openai.ChatCompletionRequest{
-		Model:       req.Model,
-		Messages:    messages,
-		MaxTokens:   req.MaxTokens,
-		Temperature: float32(req.Temperature),
-		TopP:        float32(req.TopP),
-		Stop:        req.Stop,
-		Stream:      true,
-	}
+	chatReq := openAIChatRequest(req)
+	chatReq.Stream = true
+	// Ask for a final chunk carrying the token usage of the whole stream
+	chatReq.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
 
 	stream, err := p.client.CreateChatCompletionStream(ctx, chatReq)
 	if err != nil {
@@ -164,34 +232,99 @@ func (p *OpenAIProvider) Stream(ctx context.Context, req *CompletionRequest) (<-
 	go func() {
 		defer close(chunks)
 		defer stream.Close()
+		readOpenAIStream(ctx, stream, chunks)
+	}()
 
-		for {
-			resp, err := stream.Recv()
-			if err == io.EOF {
-				return
+	return chunks, nil
+}
+
+// readOpenAIStream reads stream into chunks until it ends or ctx is done.
+// Tool calls arrive in fragments, keyed by their index, and are sent whole
+// with the finish reason.
+func readOpenAIStream(ctx context.Context, stream *openai.ChatCompletionStream, chunks chan<- StreamChunk) {
+	send := func(chunk StreamChunk) bool {
+		select {
+		case chunks <- chunk:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	var toolCalls []ToolCall
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			send(StreamChunk{Error: err})
+			return
+		}
+
+		if len(resp.Choices) > 0 {
+			choice := resp.Choices[0]
+			for _, call := range choice.Delta.ToolCalls {
+				toolCalls = appendToolCallDelta(toolCalls, call)
 			}
-			if err != nil {
-				chunks <- StreamChunk{Error: err}
+
+			chunk := StreamChunk{
+				ID:           resp.ID,
+				Delta:        choice.Delta.Content,
+				FinishReason: string(choice.FinishReason),
+			}
+			if chunk.FinishReason != "" {
+				chunk.ToolCalls = toolCalls
+			}
+			if !send(chunk) {
 				return
 			}
+		}
 
-			if len(resp.Choices) > 0 {
-				chunks <- StreamChunk{
-					ID:           resp.ID,
-					Delta:        resp.Choices[0].Delta.Content,
-					FinishReason: string(resp.Choices[0].FinishReason),
-				}
+		if resp.Usage != nil {
+			usage := StreamChunk{
+				ID: resp.ID,
+				Usage: &TokenUsage{
+					PromptTokens:     resp.Usage.PromptTokens,
+					CompletionTokens: resp.Usage.CompletionTokens,
+					TotalTokens:      resp.Usage.TotalTokens,
+				},
+			}
+			if !send(usage) {
+				return
 			}
 		}
-	}()
+	}
+}
 
-	return chunks, nil
+// appendToolCallDelta adds a streamed fragment to the tool call at its index.
+// The first fragment of a call carries its ID and name; the arguments arrive
+// in pieces.
+func appendToolCallDelta(calls []ToolCall, delta openai.ToolCall) []ToolCall {
+	index := len(calls)
+	if delta.Index != nil {
+		index = *delta.Index
+	}
+	for len(calls) <= index {
+		calls = append(calls, ToolCall{Type: "function"})
+	}
+
+	call := &calls[index]
+	if delta.ID != "" {
+		call.ID = delta.ID
+	}
+	if delta.Type != "" {
+		call.Type = string(delta.Type)
+	}
+	call.Function.Name += delta.Function.Name
+	call.Function.Arguments += delta.Function.Arguments
+	return calls
 }
 
-// CountTokens estimates token count
+// CountTokens counts tokens with cl100k_base, the encoding of OpenAI's
+// embedding models; use the package CountTokens to count for a chat model
 func (p *OpenAIProvider) CountTokens(text string) (int, error) {
-	// Approximate: ~4 chars per token for English
-	return len(text) / 4, nil
+	return CountTokens("text-embedding-3-small", text), nil
 }
 
 // GetModels returns available models
@@ -199,13 +332,23 @@ func (p *OpenAIProvider) GetModels() []ModelInfo {
 	return p.models
 }
 
-// ValidateAPIKey validates the API key
+// ValidateAPIKey validates the API key against the provider's endpoint
 func (p *OpenAIProvider) ValidateAPIKey(ctx context.Context, key string) error {
-	client := openai.NewClient(key)
-	_, err := client.ListModels(ctx)
+	provider, err := NewOpenAIProviderWithEndpoint(key, p.endpoint)
 	if err != nil {
-		return fmt.Errorf("invalid API key: %w", err)
+		return err
+	}
+	_, err = provider.client.ListModels(ctx)
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &apiErr) && rejectedKey(apiErr.HTTPStatusCode),
+		errors.As(err, &reqErr) && rejectedKey(reqErr.HTTPStatusCode):
+		return fmt.Errorf("%w: %v", ErrInvalidAPIKey, err)
+	default:
+		return fmt.Errorf("failed to list models: %w", err)
 	}
-	return nil
 }
 
@@ -2,14 +2,67 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"strings"
 	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/sashabaranov/go-openai"
 )
 
 // =============================================================================
 // Core Provider Interface
 // =============================================================================
 
+// ErrInvalidAPIKey is returned by ValidateAPIKey when the provider rejects
+// the key, as opposed to failing to answer
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// rejectedKey reports whether an HTTP status means the provider rejected the
+// request's key
+func rejectedKey(status int) bool {
+	return status == http.StatusUnauthorized || status == http.StatusForbidden
+}
+
+// APIError is a provider's error response to a request
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s API error: %d - %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// Retryable reports whether a failed request may succeed when sent again or
+// to another model: the provider rate limited it, was overloaded or failing,
+// or could not be reached. Errors in the request itself are not retryable.
+func Retryable(err error) bool {
+	var apiErr *APIError
+	var openaiErr *openai.APIError
+	var reqErr *openai.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		return retryableStatus(apiErr.StatusCode)
+	case errors.As(err, &openaiErr):
+		return retryableStatus(openaiErr.HTTPStatusCode)
+	case errors.As(err, &reqErr):
+		return retryableStatus(reqErr.HTTPStatusCode)
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// retryableStatus reports whether an HTTP status means the provider was rate
+// limited, overloaded or failing
+func retryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
 // Provider is the unified interface for all AI providers
 type Provider interface {
 	// Name returns the provider identifier
@@ -27,7 +80,8 @@ type Provider interface {
 	// GetModels returns available models for this provider
 	GetModels() []ModelInfo
 
-	// ValidateAPIKey checks if the API key is valid
+	// ValidateAPIKey checks if the API key is valid, without being billed.
+	// A key the provider rejects fails with ErrInvalidAPIKey.
 	ValidateAPIKey(ctx context.Context, key string) error
 }
 
@@ -50,11 +104,11 @@ type CompletionRequest struct {
 
 // Message represents a chat message
 type Message struct {
-	Role       string      `json:"role"` // system, user, assistant, tool
-	Content    string      `json:"content"`
-	Name       string      `json:"name,omitempty"`
-	ToolCallID string      `json:"tool_call_id,omitempty"`
-	ToolCalls  []ToolCall  `json:"tool_calls,omitempty"`
+	Role       string     `json:"role"` // system, user, assistant, tool
+	Content    string     `json:"content"`
+	Name       string     `json:"name,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
 }
 
 // Tool represents a function/tool available to the model
@@ -91,6 +145,15 @@ type CompletionResponse struct {
 	FinishReason string     `json:"finish_reason"`
 	Usage        TokenUsage `json:"usage"`
 	CreatedAt    time.Time  `json:"created_at"`
+
+	// RequestID is the provider-assigned request identifier, useful for support escalation
+	RequestID string `json:"request_id,omitempty"`
+
+	// RateLimit is the provider's rate-limit state after this request, if reported
+	RateLimit *RateLimitInfo `json:"rate_limit,omitempty"`
+
+	// ModelWarning is set when the requested model is deprecated or retiring soon
+	ModelWarning string `json:"model_warning,omitempty"`
 }
 
 // TokenUsage represents token consumption
@@ -102,11 +165,15 @@ type TokenUsage struct {
 
 // StreamChunk represents a streaming response chunk
 type StreamChunk struct {
-	ID           string     `json:"id"`
-	Delta        string     `json:"delta"`
-	FinishReason string     `json:"finish_reason,omitempty"`
+	ID           string      `json:"id"`
+	Delta        string      `json:"delta"`
+	FinishReason string      `json:"finish_reason,omitempty"`
 	Usage        *TokenUsage `json:"usage,omitempty"`
-	Error        error      `json:"-"`
+	Error        error       `json:"-"`
+
+	// ToolCalls are the complete tool calls the model made, sent with the
+	// chunk carrying the finish reason
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
 }
 
 // =============================================================================
@@ -115,14 +182,21 @@ type StreamChunk struct {
 
 // ModelInfo contains information about a model
 type ModelInfo struct {
-	ID           string  `json:"id"`
-	Name         string  `json:"name"`
-	Description  string  `json:"description"`
-	ContextWindow int    `json:"context_window"`
-	MaxOutput    int     `json:"max_output"`
-	InputPrice   float64 `json:"input_price"`   // per 1K tokens
-	OutputPrice  float64 `json:"output_price"`  // per 1K tokens
-	Capabilities []string `json:"capabilities"` // text, vision, function_calling, etc.
+	ID            string   `json:"id"`
+	Name          string   `json:"name"`
+	Description   string   `json:"description"`
+	ContextWindow int      `json:"context_window"`
+	MaxOutput     int      `json:"max_output"`
+	InputPrice    float64  `json:"input_price"`  // per 1K tokens
+	OutputPrice   float64  `json:"output_price"` // per 1K tokens
+	Capabilities  []string `json:"capabilities"` // text, vision, function_calling, etc.
+
+	// Lifecycle, populated from the deprecation registry
+	DeprecatedAt *time.Time `json:"deprecated_at,omitempty"`
+	RetiresAt    *time.Time `json:"retires_at,omitempty"`
+	Deprecated   bool       `json:"deprecated"`
+	Retired      bool       `json:"retired"`
+	Successor    string     `json:"successor,omitempty"`
 }
 
 // =============================================================================
@@ -248,8 +322,33 @@ func DefaultPricing() map[string]ModelInfo {
 			InputPrice: 0.00025, OutputPrice: 0.00125,
 			Capabilities: []string{"text", "vision", "function_calling"},
 		},
+		"claude-3-5-haiku-20241022": {
+			ID: "claude-3-5-haiku-20241022", Name: "Claude 3.5 Haiku", ContextWindow: 200000, MaxOutput: 8192,
+			InputPrice: 0.0008, OutputPrice: 0.004,
+			Capabilities: []string{"text", "vision", "function_calling"},
+		},
+		"claude-sonnet-4-20250514": {
+			ID: "claude-sonnet-4-20250514", Name: "Claude Sonnet 4", ContextWindow: 200000, MaxOutput: 64000,
+			InputPrice: 0.003, OutputPrice: 0.015,
+			Capabilities: []string{"text", "vision", "function_calling"},
+		},
 
 		// Google
+		"gemini-2.5-pro": {
+			ID: "gemini-2.5-pro", Name: "Gemini 2.5 Pro", ContextWindow: 1048576, MaxOutput: 65536,
+			InputPrice: 0.00125, OutputPrice: 0.01,
+			Capabilities: []string{"text", "vision", "function_calling"},
+		},
+		"gemini-2.5-flash": {
+			ID: "gemini-2.5-flash", Name: "Gemini 2.5 Flash", ContextWindow: 1048576, MaxOutput: 65536,
+			InputPrice: 0.0003, OutputPrice: 0.0025,
+			Capabilities: []string{"text", "vision", "function_calling"},
+		},
+		"gemini-2.5-flash-lite": {
+			ID: "gemini-2.5-flash-lite", Name: "Gemini 2.5 Flash-Lite", ContextWindow: 1048576, MaxOutput: 65536,
+			InputPrice: 0.0001, OutputPrice: 0.0004,
+			Capabilities: []string{"text", "vision", "function_calling"},
+		},
 		"gemini-1.5-pro": {
 			ID: "gemini-1.5-pro", Name: "Gemini 1.5 Pro", ContextWindow: 2000000, MaxOutput: 8192,
 			InputPrice: 0.00125, OutputPrice: 0.005,
@@ -263,3 +362,17 @@ func DefaultPricing() map[string]ModelInfo {
 	}
 }
 
+// ModelProvider returns the provider serving a model in the default pricing
+func ModelProvider(model string) (models.AIProvider, bool) {
+	if _, ok := DefaultPricing()[model]; !ok {
+		return "", false
+	}
+	switch {
+	case strings.HasPrefix(model, "claude"):
+		return models.ProviderAnthropic, true
+	case strings.HasPrefix(model, "gemini"):
+		return models.ProviderGoogle, true
+	default:
+		return models.ProviderOpenAI, true
+	}
+}
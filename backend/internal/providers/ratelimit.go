@@ -0,0 +1,174 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/metrics"
+)
+
+// =============================================================================
+// Rate Limit Tracking
+// =============================================================================
+
+// RateLimitInfo captures the rate-limit state reported by a provider
+type RateLimitInfo struct {
+	LimitRequests     int       `json:"limit_requests,omitempty"`
+	LimitTokens       int       `json:"limit_tokens,omitempty"`
+	RemainingRequests int       `json:"remaining_requests"`
+	RemainingTokens   int       `json:"remaining_tokens"`
+	RequestsReset     time.Time `json:"requests_reset,omitempty"`
+	TokensReset       time.Time `json:"tokens_reset,omitempty"`
+	ObservedAt        time.Time `json:"observed_at"`
+}
+
+// ParseRateLimitHeaders extracts the rate-limit state from an OpenAI
+// (x-ratelimit-*) or Anthropic (anthropic-ratelimit-*) response, or returns nil
+// when the response has neither
+func ParseRateLimitHeaders(h http.Header) *RateLimitInfo {
+	if info := parseOpenAIRateLimitHeaders(h); info != nil {
+		return info
+	}
+	return parseAnthropicRateLimitHeaders(h)
+}
+
+// parseOpenAIRateLimitHeaders extracts x-ratelimit-* headers from an OpenAI response.
+// Reset values are durations such as "1s" or "6m0s".
+func parseOpenAIRateLimitHeaders(h http.Header) *RateLimitInfo {
+	if h.Get("x-ratelimit-remaining-requests") == "" && h.Get("x-ratelimit-remaining-tokens") == "" {
+		return nil
+	}
+
+	now := time.Now()
+	info := &RateLimitInfo{
+		LimitRequests:     headerInt(h, "x-ratelimit-limit-requests"),
+		LimitTokens:       headerInt(h, "x-ratelimit-limit-tokens"),
+		RemainingRequests: headerInt(h, "x-ratelimit-remaining-requests"),
+		RemainingTokens:   headerInt(h, "x-ratelimit-remaining-tokens"),
+		ObservedAt:        now,
+	}
+	if d, err := time.ParseDuration(h.Get("x-ratelimit-reset-requests")); err == nil {
+		info.RequestsReset = now.Add(d)
+	}
+	if d, err := time.ParseDuration(h.Get("x-ratelimit-reset-tokens")); err == nil {
+		info.TokensReset = now.Add(d)
+	}
+	return info
+}
+
+// parseAnthropicRateLimitHeaders extracts anthropic-ratelimit-* headers from an Anthropic response.
+// Reset values are RFC 3339 timestamps.
+func parseAnthropicRateLimitHeaders(h http.Header) *RateLimitInfo {
+	if h.Get("anthropic-ratelimit-requests-remaining") == "" && h.Get("anthropic-ratelimit-tokens-remaining") == "" {
+		return nil
+	}
+
+	info := &RateLimitInfo{
+		LimitRequests:     headerInt(h, "anthropic-ratelimit-requests-limit"),
+		LimitTokens:       headerInt(h, "anthropic-ratelimit-tokens-limit"),
+		RemainingRequests: headerInt(h, "anthropic-ratelimit-requests-remaining"),
+		RemainingTokens:   headerInt(h, "anthropic-ratelimit-tokens-remaining"),
+		ObservedAt:        time.Now(),
+	}
+	if t, err := time.Parse(time.RFC3339, h.Get("anthropic-ratelimit-requests-reset")); err == nil {
+		info.RequestsReset = t
+	}
+	if t, err := time.Parse(time.RFC3339, h.Get("anthropic-ratelimit-tokens-reset")); err == nil {
+		info.TokensReset = t
+	}
+	return info
+}
+
+func headerInt(h http.Header, key string) int {
+	v, _ := strconv.Atoi(h.Get(key))
+	return v
+}
+
+// RateLimitTracker remembers the latest rate-limit state per provider and
+// throttles requests before a provider would reject them with a 429
+type RateLimitTracker struct {
+	state   map[string]*RateLimitInfo
+	maxWait time.Duration
+	mu      sync.RWMutex
+}
+
+// NewRateLimitTracker creates a new rate limit tracker. Requests that would
+// have to wait longer than maxWait for a reset fail immediately instead.
+func NewRateLimitTracker(maxWait time.Duration) *RateLimitTracker {
+	return &RateLimitTracker{
+		state:   make(map[string]*RateLimitInfo),
+		maxWait: maxWait,
+	}
+}
+
+// Update records the latest rate-limit state for a provider and exports its
+// remaining quota
+func (t *RateLimitTracker) Update(provider string, info *RateLimitInfo) {
+	if info == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.state[provider] = info
+	metrics.SetProviderRateLimitRemaining(provider, info.RemainingRequests, info.RemainingTokens)
+}
+
+// Get returns the latest known rate-limit state for a provider
+func (t *RateLimitTracker) Get(provider string) (RateLimitInfo, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	info, ok := t.state[provider]
+	if !ok {
+		return RateLimitInfo{}, false
+	}
+	return *info, true
+}
+
+// Snapshot returns the latest rate-limit state of every provider
+func (t *RateLimitTracker) Snapshot() map[string]RateLimitInfo {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	out := make(map[string]RateLimitInfo, len(t.state))
+	for name, info := range t.state {
+		out[name] = *info
+	}
+	return out
+}
+
+// Wait blocks until the provider's quota has reset when the last observed
+// state shows it is exhausted for the tokens the request needs
+func (t *RateLimitTracker) Wait(ctx context.Context, provider string, tokensNeeded int) error {
+	info, ok := t.Get(provider)
+	if !ok {
+		return nil
+	}
+
+	var resetAt time.Time
+	if info.LimitRequests > 0 && info.RemainingRequests <= 0 {
+		resetAt = info.RequestsReset
+	}
+	if info.LimitTokens > 0 && info.RemainingTokens < tokensNeeded && info.TokensReset.After(resetAt) {
+		resetAt = info.TokensReset
+	}
+
+	wait := time.Until(resetAt)
+	if wait <= 0 {
+		return nil
+	}
+	if wait > t.maxWait {
+		return fmt.Errorf("%s rate limit exhausted, resets at %s", provider, resetAt.Format(time.RFC3339))
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
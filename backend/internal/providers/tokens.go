@@ -0,0 +1,91 @@
+package providers
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+// =============================================================================
+// Token Counting
+// =============================================================================
+
+// tokenizerScales adjusts cl100k_base counts for model families with their own
+// tokenizers, whose exact vocabularies aren't public. Families not listed are
+// counted as cl100k_base.
+var tokenizerScales = map[string]float64{
+	"claude": 1.1, // Claude's tokenizer yields roughly 10% more tokens
+}
+
+var (
+	loaderOnce  sync.Once
+	encodingsMu sync.Mutex
+	encodings   = make(map[string]*tiktoken.Tiktoken)
+)
+
+// CountTokens counts the tokens text takes for a model. OpenAI models are
+// counted with their own encoding; other models are estimated from
+// cl100k_base, which tracks code and non-English text far better than a
+// characters-per-token ratio.
+func CountTokens(model, text string) int {
+	if text == "" {
+		return 0
+	}
+
+	name := tiktoken.MODEL_CL100K_BASE
+	scale := 1.0
+	if encoding, ok := openAIEncoding(model); ok {
+		name = encoding
+	} else {
+		for family, s := range tokenizerScales {
+			if strings.HasPrefix(model, family) {
+				scale = s
+			}
+		}
+	}
+
+	n, ok := encodingTokens(name, text)
+	if !ok {
+		// Approximate: ~4 chars per token for English
+		return (len(text) + 3) / 4
+	}
+	return int(float64(n)*scale + 0.5)
+}
+
+// openAIEncoding returns the name of an OpenAI model's encoding
+func openAIEncoding(model string) (string, bool) {
+	if name, ok := tiktoken.MODEL_TO_ENCODING[model]; ok {
+		return name, true
+	}
+	for prefix, name := range tiktoken.MODEL_PREFIX_TO_ENCODING {
+		if strings.HasPrefix(model, prefix) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// encodingTokens counts text's tokens with a named encoding. Encodings are
+// loaded from the embedded vocabularies, so counting never needs the network.
+func encodingTokens(name, text string) (int, bool) {
+	loaderOnce.Do(func() {
+		tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+	})
+
+	encodingsMu.Lock()
+	enc, ok := encodings[name]
+	if !ok {
+		var err error
+		enc, err = tiktoken.GetEncoding(name)
+		if err != nil {
+			encodingsMu.Unlock()
+			return 0, false
+		}
+		encodings[name] = enc
+	}
+	encodingsMu.Unlock()
+
+	return len(enc.EncodeOrdinary(text)), true
+}
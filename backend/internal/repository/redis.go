@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -38,6 +39,11 @@ func (r *RedisClient) Client() *redis.Client {
 	return r.client
 }
 
+// Ping checks that Redis is reachable
+func (r *RedisClient) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
 // Close closes the Redis connection
 func (r *RedisClient) Close() error {
 	return r.client.Close()
@@ -53,11 +59,38 @@ func (r *RedisClient) Get(ctx context.Context, key string) (string, error) {
 	return r.client.Get(ctx, key).Result()
 }
 
+// Lookup retrieves a value by key, reporting false when there is none
+func (r *RedisClient) Lookup(ctx context.Context, key string) (string, bool, error) {
+	value, err := r.client.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
 // Delete removes a key
 func (r *RedisClient) Delete(ctx context.Context, keys ...string) error {
 	return r.client.Del(ctx, keys...).Err()
 }
 
+// DeleteMatching removes the keys matching a glob pattern, returning how many
+// were removed. Keys are found with SCAN, so Redis isn't blocked meanwhile.
+func (r *RedisClient) DeleteMatching(ctx context.Context, pattern string) (int, error) {
+	deleted := 0
+	iter := r.client.Scan(ctx, 0, pattern, 100).Iterator()
+	for iter.Next(ctx) {
+		n, err := r.client.Del(ctx, iter.Val()).Result()
+		if err != nil {
+			return deleted, err
+		}
+		deleted += int(n)
+	}
+	return deleted, iter.Err()
+}
+
 // Exists checks if a key exists
 func (r *RedisClient) Exists(ctx context.Context, key string) (bool, error) {
 	result, err := r.client.Exists(ctx, key).Result()
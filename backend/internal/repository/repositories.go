@@ -2,13 +2,18 @@ package repository
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/delphi-platform/delphi/backend/internal/models"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 // Repositories contains all repository instances
@@ -19,6 +24,7 @@ type Repositories struct {
 	APIKeys     *APIKeyRepository
 	Agents      *AgentRepository
 	AgentRuns   *AgentRunRepository
+	AgentLogs   *AgentLogRepository
 	Knowledge   *KnowledgeRepository
 	Repositories *RepositoryRepository
 	Businesses  *BusinessRepository
@@ -28,6 +34,14 @@ type Repositories struct {
 	IoT         *IoTRepository
 	Audit       *AuditRepository
 	Costs       *CostRepository
+	Notifications *NotificationRepository
+	APIUsage      *APIUsageRepository
+	BillingEvents *BillingEventRepository
+	Schedules     *ScheduledExecutionRepository
+	Webhooks      *WebhookRepository
+	Digests       *DigestRepository
+	Templates     *AgentTemplateRepository
+	RunBatches    *RunBatchRepository
 }
 
 // NewRepositories creates all repository instances
@@ -39,6 +53,7 @@ func NewRepositories(db *PostgresDB) *Repositories {
 		APIKeys:      &APIKeyRepository{db: db},
 		Agents:       &AgentRepository{db: db},
 		AgentRuns:    &AgentRunRepository{db: db},
+		AgentLogs:    &AgentLogRepository{db: db},
 		Knowledge:    &KnowledgeRepository{db: db},
 		Repositories: &RepositoryRepository{db: db},
 		Businesses:   &BusinessRepository{db: db},
@@ -48,6 +63,14 @@ func NewRepositories(db *PostgresDB) *Repositories {
 		IoT:          &IoTRepository{db: db},
 		Audit:        &AuditRepository{db: db},
 		Costs:        &CostRepository{db: db},
+		Notifications: &NotificationRepository{db: db},
+		APIUsage:      &APIUsageRepository{db: db},
+		BillingEvents: &BillingEventRepository{db: db},
+		Schedules:     &ScheduledExecutionRepository{db: db},
+		Webhooks:      &WebhookRepository{db: db},
+		Digests:       &DigestRepository{db: db},
+		Templates:     &AgentTemplateRepository{db: db},
+		RunBatches:    &RunBatchRepository{db: db},
 	}
 }
 
@@ -59,6 +82,18 @@ type TenantRepository struct {
 	db *PostgresDB
 }
 
+const tenantColumns = `id, name, slug, plan, settings, created_at, updated_at, deletion_requested_at, delete_after`
+
+func scanTenant(row pgx.Row) (*models.Tenant, error) {
+	var t models.Tenant
+	err := row.Scan(&t.ID, &t.Name, &t.Slug, &t.Plan, &t.Settings, &t.CreatedAt, &t.UpdatedAt,
+		&t.DeletionRequestedAt, &t.DeleteAfter)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
 func (r *TenantRepository) Create(ctx context.Context, tenant *models.Tenant) error {
 	query := `
 		INSERT INTO tenants (id, name, slug, plan, settings, created_at, updated_at)
@@ -71,36 +106,227 @@ func (r *TenantRepository) Create(ctx context.Context, tenant *models.Tenant) er
 }
 
 func (r *TenantRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Tenant, error) {
-	query := `SELECT id, name, slug, plan, settings, created_at, updated_at FROM tenants WHERE id = $1`
-	var tenant models.Tenant
-	err := r.db.pool.QueryRow(ctx, query, id).Scan(
-		&tenant.ID, &tenant.Name, &tenant.Slug, &tenant.Plan, &tenant.Settings,
-		&tenant.CreatedAt, &tenant.UpdatedAt)
+	query := `SELECT ` + tenantColumns + ` FROM tenants WHERE id = $1`
+	tenant, err := scanTenant(r.db.pool.QueryRow(ctx, query, id))
 	if err == pgx.ErrNoRows {
 		return nil, nil
 	}
-	return &tenant, err
+	return tenant, err
 }
 
 func (r *TenantRepository) GetBySlug(ctx context.Context, slug string) (*models.Tenant, error) {
-	query := `SELECT id, name, slug, plan, settings, created_at, updated_at FROM tenants WHERE slug = $1`
-	var tenant models.Tenant
-	err := r.db.pool.QueryRow(ctx, query, slug).Scan(
-		&tenant.ID, &tenant.Name, &tenant.Slug, &tenant.Plan, &tenant.Settings,
-		&tenant.CreatedAt, &tenant.UpdatedAt)
+	query := `SELECT ` + tenantColumns + ` FROM tenants WHERE slug = $1`
+	tenant, err := scanTenant(r.db.pool.QueryRow(ctx, query, slug))
 	if err == pgx.ErrNoRows {
 		return nil, nil
 	}
-	return &tenant, err
+	return tenant, err
 }
 
+// Update saves a tenant's name, slug, plan and settings. It returns
+// ErrSlugTaken if another tenant has the slug.
 func (r *TenantRepository) Update(ctx context.Context, tenant *models.Tenant) error {
 	query := `
-		UPDATE tenants SET name = $2, plan = $3, settings = $4, updated_at = $5
+		UPDATE tenants SET name = $2, slug = $3, plan = $4, settings = $5, updated_at = $6
 		WHERE id = $1
 	`
 	_, err := r.db.pool.Exec(ctx, query,
-		tenant.ID, tenant.Name, tenant.Plan, tenant.Settings, time.Now())
+		tenant.ID, tenant.Name, tenant.Slug, tenant.Plan, tenant.Settings, time.Now())
+	return uniqueViolation(err)
+}
+
+// CreateWithOwner creates a tenant and its first user together, so a tenant
+// never exists without an owner. It returns ErrSlugTaken or ErrEmailTaken if
+// the slug or the owner's email is already in use.
+func (r *TenantRepository) CreateWithOwner(ctx context.Context, tenant *models.Tenant, owner *models.User) error {
+	tx, err := r.db.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO tenants (id, name, slug, plan, settings, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	if _, err := tx.Exec(ctx, query,
+		tenant.ID, tenant.Name, tenant.Slug, tenant.Plan, tenant.Settings,
+		tenant.CreatedAt, tenant.UpdatedAt); err != nil {
+		return uniqueViolation(err)
+	}
+
+	query = `
+		INSERT INTO users (id, tenant_id, email, name, role, preferences, password_hash, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9)
+	`
+	if _, err := tx.Exec(ctx, query,
+		owner.ID, owner.TenantID, owner.Email, owner.Name, owner.Role, owner.Preferences, owner.PasswordHash,
+		owner.CreatedAt, owner.UpdatedAt); err != nil {
+		return uniqueViolation(err)
+	}
+	return tx.Commit(ctx)
+}
+
+// ScheduleDeletion marks a tenant to be deleted once deleteAfter has passed.
+// It returns false if the tenant doesn't exist.
+func (r *TenantRepository) ScheduleDeletion(ctx context.Context, id uuid.UUID, requestedAt, deleteAfter time.Time) (bool, error) {
+	query := `UPDATE tenants SET deletion_requested_at = $2, delete_after = $3, updated_at = NOW() WHERE id = $1`
+	tag, err := r.db.pool.Exec(ctx, query, id, requestedAt, deleteAfter)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// CancelDeletion unmarks a tenant scheduled for deletion. It returns false if
+// the tenant isn't scheduled, or its grace period is over or its purge has
+// started.
+func (r *TenantRepository) CancelDeletion(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `
+		UPDATE tenants SET deletion_requested_at = NULL, delete_after = NULL, updated_at = NOW()
+		WHERE id = $1 AND delete_after > NOW() AND purge_started_at IS NULL
+	`
+	tag, err := r.db.pool.Exec(ctx, query, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ListDueDeletions returns tenants whose deletion grace period ended before
+// now, longest overdue first
+func (r *TenantRepository) ListDueDeletions(ctx context.Context, now time.Time, limit int) ([]*models.Tenant, error) {
+	query := `SELECT ` + tenantColumns + ` FROM tenants
+			  WHERE delete_after <= $1 ORDER BY delete_after LIMIT $2`
+	rows, err := r.db.pool.Query(ctx, query, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tenants []*models.Tenant
+	for rows.Next() {
+		t, err := scanTenant(rows)
+		if err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, rows.Err()
+}
+
+// StartPurge marks a tenant due for deletion as being purged, before anything
+// it owns outside the database is removed. Its row is locked and its grace
+// period checked against the database's clock, so a deletion cancelled in the
+// meantime is seen. Once marked, the deletion can't be cancelled. It returns
+// false if the tenant isn't due for deletion.
+func (r *TenantRepository) StartPurge(ctx context.Context, id uuid.UUID) (bool, error) {
+	tx, err := r.db.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var due bool
+	err = tx.QueryRow(ctx, `SELECT COALESCE(delete_after <= NOW(), FALSE) FROM tenants WHERE id = $1 FOR UPDATE`, id).Scan(&due)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !due {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE tenants SET purge_started_at = COALESCE(purge_started_at, NOW()), updated_at = NOW() WHERE id = $1`, id); err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+// tenantDataTables are counted when a tenant is deleted, to record how much
+// of its data went with it
+var tenantDataTables = []string{
+	"users", "agents", "agent_runs", "knowledge_bases", "cost_records", "audit_logs",
+	"social_accounts", "iot_devices",
+}
+
+// Delete deletes a tenant whose purge has started, together with everything
+// it owns, which cascades from the tenant. It returns how many rows of the
+// main tables were deleted, or false if the tenant's purge hasn't started.
+func (r *TenantRepository) Delete(ctx context.Context, id uuid.UUID) (map[string]int64, bool, error) {
+	tx, err := r.db.pool.Begin(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var purging bool
+	err = tx.QueryRow(ctx, `SELECT purge_started_at IS NOT NULL FROM tenants WHERE id = $1 FOR UPDATE`, id).Scan(&purging)
+	if err == pgx.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if !purging {
+		return nil, false, nil
+	}
+
+	counts := make(map[string]int64, len(tenantDataTables))
+	for _, table := range tenantDataTables {
+		var n int64
+		if err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM `+table+` WHERE tenant_id = $1`, id).Scan(&n); err != nil {
+			return nil, false, fmt.Errorf("failed to count %s: %w", table, err)
+		}
+		counts[table] = n
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM tenants WHERE id = $1`, id); err != nil {
+		return nil, false, err
+	}
+	return counts, true, tx.Commit(ctx)
+}
+
+// WithDeletionLock runs fn while holding the tenant deletion advisory lock. It
+// returns false without running fn when another instance holds the lock.
+func (r *TenantRepository) WithDeletionLock(ctx context.Context, fn func(ctx context.Context) error) (bool, error) {
+	return withAdvisoryLock(ctx, r.db, tenantDeletionLockKey, fn)
+}
+
+// tenantDeletionLockKey is the advisory lock held while deleting tenants whose
+// grace period is over, so only one instance deletes them at a time
+const tenantDeletionLockKey = 0x64656c7068690003
+
+var (
+	// ErrSlugTaken is returned when a tenant slug is already in use
+	ErrSlugTaken = errors.New("tenant slug already taken")
+
+	// ErrEmailTaken is returned when a user's email is already registered
+	ErrEmailTaken = errors.New("email already registered")
+
+	// ErrRecipientExists is returned when a tenant adds a notification
+	// recipient it already has
+	ErrRecipientExists = errors.New("notification recipient already added")
+)
+
+// uniqueViolation maps a violation of the tenant slug, user email or
+// notification recipient unique constraints to ErrSlugTaken, ErrEmailTaken or
+// ErrRecipientExists, and returns other errors unchanged
+func uniqueViolation(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+		return err
+	}
+	switch pgErr.ConstraintName {
+	case "tenants_slug_key":
+		return ErrSlugTaken
+	case "users_email_key":
+		return ErrEmailTaken
+	case "notification_recipients_tenant_id_channel_address_key":
+		return ErrRecipientExists
+	}
 	return err
 }
 
@@ -114,22 +340,24 @@ type UserRepository struct {
 
 func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
 	query := `
-		INSERT INTO users (id, tenant_id, email, name, role, preferences, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO users (id, tenant_id, email, name, role, preferences, password_hash, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9)
 	`
 	_, err := r.db.pool.Exec(ctx, query,
-		user.ID, user.TenantID, user.Email, user.Name, user.Role, user.Preferences,
+		user.ID, user.TenantID, user.Email, user.Name, user.Role, user.Preferences, user.PasswordHash,
 		user.CreatedAt, user.UpdatedAt)
 	return err
 }
 
 func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
-	query := `SELECT id, tenant_id, email, name, role, preferences, created_at, updated_at, last_login_at 
+	query := `SELECT id, tenant_id, email, name, role, preferences, created_at, updated_at, last_login_at,
+			  COALESCE(password_hash, ''), mfa_enabled, COALESCE(mfa_secret, ''), mfa_recovery_codes
 			  FROM users WHERE id = $1`
 	var user models.User
 	err := r.db.pool.QueryRow(ctx, query, id).Scan(
 		&user.ID, &user.TenantID, &user.Email, &user.Name, &user.Role, &user.Preferences,
-		&user.CreatedAt, &user.UpdatedAt, &user.LastLoginAt)
+		&user.CreatedAt, &user.UpdatedAt, &user.LastLoginAt, &user.PasswordHash,
+		&user.MFAEnabled, &user.MFASecret, &user.MFARecoveryCodes)
 	if err == pgx.ErrNoRows {
 		return nil, nil
 	}
@@ -137,12 +365,14 @@ func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Use
 }
 
 func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
-	query := `SELECT id, tenant_id, email, name, role, preferences, created_at, updated_at, last_login_at 
-			  FROM users WHERE email = $1`
+	query := `SELECT id, tenant_id, email, name, role, preferences, created_at, updated_at, last_login_at,
+			  COALESCE(password_hash, ''), mfa_enabled, COALESCE(mfa_secret, ''), mfa_recovery_codes
+			  FROM users WHERE lower(email) = lower($1)`
 	var user models.User
 	err := r.db.pool.QueryRow(ctx, query, email).Scan(
 		&user.ID, &user.TenantID, &user.Email, &user.Name, &user.Role, &user.Preferences,
-		&user.CreatedAt, &user.UpdatedAt, &user.LastLoginAt)
+		&user.CreatedAt, &user.UpdatedAt, &user.LastLoginAt, &user.PasswordHash,
+		&user.MFAEnabled, &user.MFASecret, &user.MFARecoveryCodes)
 	if err == pgx.ErrNoRows {
 		return nil, nil
 	}
@@ -177,6 +407,66 @@ func (r *UserRepository) UpdateLastLogin(ctx context.Context, userID uuid.UUID)
 	return err
 }
 
+// SetMFASecret stores a new TOTP secret for enrollment. It does nothing if MFA
+// is already enabled, and reports whether the secret was stored.
+func (r *UserRepository) SetMFASecret(ctx context.Context, userID uuid.UUID, secret string) (bool, error) {
+	query := `UPDATE users SET mfa_secret = $2, mfa_last_step = NULL, updated_at = NOW()
+			  WHERE id = $1 AND NOT mfa_enabled`
+	tag, err := r.db.pool.Exec(ctx, query, userID, secret)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// EnableMFA turns on MFA with the enrolled secret and stores the recovery code
+// hashes, replacing any previous ones
+func (r *UserRepository) EnableMFA(ctx context.Context, userID uuid.UUID, recoveryCodes []string) error {
+	query := `UPDATE users SET mfa_enabled = true, mfa_recovery_codes = $2, mfa_enabled_at = NOW(), updated_at = NOW()
+			  WHERE id = $1 AND mfa_secret IS NOT NULL`
+	_, err := r.db.pool.Exec(ctx, query, userID, recoveryCodes)
+	return err
+}
+
+// DisableMFA turns off MFA and clears the secret and recovery codes
+func (r *UserRepository) DisableMFA(ctx context.Context, userID uuid.UUID) error {
+	query := `UPDATE users SET mfa_enabled = false, mfa_secret = NULL, mfa_recovery_codes = '{}',
+			  mfa_last_step = NULL, mfa_enabled_at = NULL, updated_at = NOW()
+			  WHERE id = $1`
+	_, err := r.db.pool.Exec(ctx, query, userID)
+	return err
+}
+
+// UseMFAStep records a TOTP time step as used. It reports false if that step,
+// or a later one, was already used, so each code works once.
+func (r *UserRepository) UseMFAStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	query := `UPDATE users SET mfa_last_step = $2
+			  WHERE id = $1 AND (mfa_last_step IS NULL OR mfa_last_step < $2)`
+	tag, err := r.db.pool.Exec(ctx, query, userID, step)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// UseRecoveryCode removes a recovery code hash, reporting false if the user
+// has no such unused code
+func (r *UserRepository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	query := `UPDATE users SET mfa_recovery_codes = array_remove(mfa_recovery_codes, $2), updated_at = NOW()
+			  WHERE id = $1 AND $2 = ANY(mfa_recovery_codes)`
+	tag, err := r.db.pool.Exec(ctx, query, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *UserRepository) UpdatePassword(ctx context.Context, userID uuid.UUID, passwordHash string) error {
+	query := `UPDATE users SET password_hash = $2, updated_at = NOW() WHERE id = $1`
+	_, err := r.db.pool.Exec(ctx, query, userID, passwordHash)
+	return err
+}
+
 // =============================================================================
 // API Key Repository
 // =============================================================================
@@ -187,29 +477,37 @@ type APIKeyRepository struct {
 
 func (r *APIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
 	query := `
-		INSERT INTO api_keys (id, tenant_id, provider, name, encrypted_key, is_valid, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO api_keys (id, tenant_id, provider, name, encrypted_key, is_valid, last_validated_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 	`
 	_, err := r.db.pool.Exec(ctx, query,
-		key.ID, key.TenantID, key.Provider, key.Name, key.EncryptedKey, key.IsValid, key.CreatedAt)
+		key.ID, key.TenantID, key.Provider, key.Name, key.EncryptedKey, key.IsValid, key.LastValidatedAt, key.CreatedAt)
 	return err
 }
 
-func (r *APIKeyRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.APIKey, error) {
-	query := `SELECT id, tenant_id, provider, name, encrypted_key, is_valid, last_used_at, created_at 
-			  FROM api_keys WHERE id = $1`
+const apiKeyColumns = `id, tenant_id, provider, name, encrypted_key, is_valid, last_used_at, last_validated_at, created_at`
+
+func scanAPIKey(row pgx.Row) (*models.APIKey, error) {
 	var key models.APIKey
-	err := r.db.pool.QueryRow(ctx, query, id).Scan(
-		&key.ID, &key.TenantID, &key.Provider, &key.Name, &key.EncryptedKey,
-		&key.IsValid, &key.LastUsedAt, &key.CreatedAt)
+	err := row.Scan(&key.ID, &key.TenantID, &key.Provider, &key.Name, &key.EncryptedKey,
+		&key.IsValid, &key.LastUsedAt, &key.LastValidatedAt, &key.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *APIKeyRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE id = $1`
+	key, err := scanAPIKey(r.db.pool.QueryRow(ctx, query, id))
 	if err == pgx.ErrNoRows {
 		return nil, nil
 	}
-	return &key, err
+	return key, err
 }
 
 func (r *APIKeyRepository) ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]*models.APIKey, error) {
-	query := `SELECT id, tenant_id, provider, name, is_valid, last_used_at, created_at 
+	query := `SELECT id, tenant_id, provider, name, is_valid, last_used_at, last_validated_at, created_at 
 			  FROM api_keys WHERE tenant_id = $1 ORDER BY created_at DESC`
 	rows, err := r.db.pool.Query(ctx, query, tenantID)
 	if err != nil {
@@ -222,7 +520,7 @@ func (r *APIKeyRepository) ListByTenant(ctx context.Context, tenantID uuid.UUID)
 		var key models.APIKey
 		if err := rows.Scan(
 			&key.ID, &key.TenantID, &key.Provider, &key.Name,
-			&key.IsValid, &key.LastUsedAt, &key.CreatedAt); err != nil {
+			&key.IsValid, &key.LastUsedAt, &key.LastValidatedAt, &key.CreatedAt); err != nil {
 			return nil, err
 		}
 		keys = append(keys, &key)
@@ -231,17 +529,44 @@ func (r *APIKeyRepository) ListByTenant(ctx context.Context, tenantID uuid.UUID)
 }
 
 func (r *APIKeyRepository) GetByTenantAndProvider(ctx context.Context, tenantID uuid.UUID, provider models.AIProvider) (*models.APIKey, error) {
-	query := `SELECT id, tenant_id, provider, name, encrypted_key, is_valid, last_used_at, created_at 
-			  FROM api_keys WHERE tenant_id = $1 AND provider = $2 AND is_valid = true
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys
+			  WHERE tenant_id = $1 AND provider = $2 AND is_valid = true
 			  ORDER BY created_at DESC LIMIT 1`
-	var key models.APIKey
-	err := r.db.pool.QueryRow(ctx, query, tenantID, provider).Scan(
-		&key.ID, &key.TenantID, &key.Provider, &key.Name, &key.EncryptedKey,
-		&key.IsValid, &key.LastUsedAt, &key.CreatedAt)
+	key, err := scanAPIKey(r.db.pool.QueryRow(ctx, query, tenantID, provider))
 	if err == pgx.ErrNoRows {
 		return nil, nil
 	}
-	return &key, err
+	return key, err
+}
+
+// ListDueForValidation returns keys of any tenant not checked with their
+// provider since before, least recently checked first
+func (r *APIKeyRepository) ListDueForValidation(ctx context.Context, before time.Time, limit int) ([]*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys
+			  WHERE last_validated_at IS NULL OR last_validated_at < $1
+			  ORDER BY last_validated_at NULLS FIRST LIMIT $2`
+	rows, err := r.db.pool.Query(ctx, query, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*models.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// UpdateValidity records the outcome of checking a key with its provider
+func (r *APIKeyRepository) UpdateValidity(ctx context.Context, id uuid.UUID, isValid bool, validatedAt time.Time) error {
+	query := `UPDATE api_keys SET is_valid = $2, last_validated_at = $3 WHERE id = $1`
+	_, err := r.db.pool.Exec(ctx, query, id, isValid, validatedAt)
+	return err
 }
 
 func (r *APIKeyRepository) Delete(ctx context.Context, id uuid.UUID) error {
@@ -327,6 +652,27 @@ func (r *AgentRepository) ListByTenant(ctx context.Context, tenantID uuid.UUID)
 	return agents, rows.Err()
 }
 
+// CountByStatus counts the tenant's agents per status
+func (r *AgentRepository) CountByStatus(ctx context.Context, tenantID uuid.UUID) (map[models.AgentStatus]int, error) {
+	query := `SELECT status, COUNT(*) FROM agents WHERE tenant_id = $1 GROUP BY status`
+	rows, err := r.db.pool.Query(ctx, query, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[models.AgentStatus]int)
+	for rows.Next() {
+		var status models.AgentStatus
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		counts[status] = count
+	}
+	return counts, rows.Err()
+}
+
 func (r *AgentRepository) Update(ctx context.Context, agent *models.Agent) error {
 	configJSON, _ := json.Marshal(agent.Config)
 	kbJSON, _ := json.Marshal(agent.KnowledgeBases)
@@ -348,10 +694,24 @@ func (r *AgentRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status
 	return err
 }
 
+// Delete removes an agent, and removes it from the projects that list it
 func (r *AgentRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	tx, err := r.db.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
 	query := `DELETE FROM agents WHERE id = $1`
-	_, err := r.db.pool.Exec(ctx, query, id)
-	return err
+	if _, err := tx.Exec(ctx, query, id); err != nil {
+		return err
+	}
+
+	query = `UPDATE projects SET agents = agents - $1::text, updated_at = NOW() WHERE agents ? $1::text`
+	if _, err := tx.Exec(ctx, query, id.String()); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
 // =============================================================================
@@ -364,33 +724,240 @@ type AgentRunRepository struct {
 
 func (r *AgentRunRepository) Create(ctx context.Context, run *models.AgentRun) error {
 	query := `
-		INSERT INTO agent_runs (id, agent_id, tenant_id, prompt, status, machine_id, started_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO agent_runs (id, agent_id, tenant_id, prompt, status, machine_id, started_at, replay_of, replay_overrides, prompt_ref, batch_id, parameters)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), $11, $12)
 	`
 	_, err := r.db.pool.Exec(ctx, query,
-		run.ID, run.AgentID, run.TenantID, run.Prompt, run.Status, run.MachineID, run.StartedAt)
+		run.ID, run.AgentID, run.TenantID, run.Prompt, run.Status, run.MachineID, run.StartedAt,
+		run.ReplayOf, run.ReplayOverrides, run.PromptRef, run.BatchID, run.Parameters)
 	return err
 }
 
+// budgetEstimateSample is how many recent completed runs are averaged to
+// estimate the cost of a run still in flight
+const budgetEstimateSample = 20
+
+// BudgetWindow is a cost limit and the start of the period it is measured over
+type BudgetWindow struct {
+	Limit *models.CostLimit
+	Since time.Time
+}
+
+// BudgetExceededError is returned when a run would take a tenant past a cost limit
+type BudgetExceededError struct {
+	Limit    *models.CostLimit
+	Spent    float64 // cost recorded since the window started
+	Reserved float64 // estimated cost of the tenant's runs still in flight
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("%s cost limit of $%.2f reached ($%.2f spent, $%.2f reserved for running executions)",
+		e.Limit.LimitType, e.Limit.Amount, e.Spent, e.Reserved)
+}
+
+// CreateWithinBudget creates run unless the tenant's spend in one of the windows,
+// plus an estimate for its runs still in flight, has reached the window's limit.
+// The check and insert hold a per-tenant advisory lock, so concurrent callers
+// each see the runs admitted before them and cannot all pass on the same
+// remaining budget. It returns a *BudgetExceededError when the run is refused.
+func (r *AgentRunRepository) CreateWithinBudget(ctx context.Context, run *models.AgentRun, windows []BudgetWindow) error {
+	if len(windows) == 0 {
+		return r.Create(ctx, run)
+	}
+
+	tx, err := r.db.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('budget:' || $1::text))`, run.TenantID); err != nil {
+		return fmt.Errorf("failed to lock tenant budget: %w", err)
+	}
+
+	// Runs in flight have not recorded their cost yet; assume each costs what
+	// the tenant's recent runs did
+	var inFlight int
+	var averageCost float64
+	err = tx.QueryRow(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM agent_runs WHERE tenant_id = $1 AND status IN ($2, $3, $4)),
+			(SELECT COALESCE(AVG(cost), 0) FROM (
+				SELECT cost FROM agent_runs
+				WHERE tenant_id = $1 AND status = $5
+				ORDER BY completed_at DESC LIMIT $6
+			) recent)
+	`, run.TenantID, models.RunStatusPending, models.RunStatusBriefing, models.RunStatusRunning,
+		models.RunStatusCompleted, budgetEstimateSample).Scan(&inFlight, &averageCost)
+	if err != nil {
+		return fmt.Errorf("failed to estimate in-flight cost: %w", err)
+	}
+	reserved := float64(inFlight) * averageCost
+
+	for _, window := range windows {
+		var spent float64
+		err := tx.QueryRow(ctx, `SELECT COALESCE(SUM(cost), 0) FROM cost_records WHERE tenant_id = $1 AND created_at >= $2`,
+			run.TenantID, window.Since).Scan(&spent)
+		if err != nil {
+			return fmt.Errorf("failed to get %s spend: %w", window.Limit.LimitType, err)
+		}
+		if spent+reserved >= window.Limit.Amount {
+			return &BudgetExceededError{Limit: window.Limit, Spent: spent, Reserved: reserved}
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO agent_runs (id, agent_id, tenant_id, prompt, status, machine_id, started_at, replay_of, replay_overrides, prompt_ref, batch_id, parameters)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), $11, $12)
+	`, run.ID, run.AgentID, run.TenantID, run.Prompt, run.Status, run.MachineID, run.StartedAt,
+		run.ReplayOf, run.ReplayOverrides, run.PromptRef, run.BatchID, run.Parameters)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
 func (r *AgentRunRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.AgentRun, error) {
 	query := `SELECT id, agent_id, tenant_id, prompt, status, result, tokens_used, cost, 
-					 machine_id, started_at, completed_at, error 
+					 COALESCE(machine_id, ''), started_at, completed_at, COALESCE(error, ''), COALESCE(provider_request_id, ''),
+					 replay_of, replay_overrides, parameters, COALESCE(prompt_ref, ''), COALESCE(response_ref, ''), batch_id,
+					 cost_anomaly, cost_baseline, cached, COALESCE(pr_url, '')
 			  FROM agent_runs WHERE id = $1`
 	var run models.AgentRun
 	err := r.db.pool.QueryRow(ctx, query, id).Scan(
 		&run.ID, &run.AgentID, &run.TenantID, &run.Prompt, &run.Status, &run.Result,
-		&run.TokensUsed, &run.Cost, &run.MachineID, &run.StartedAt, &run.CompletedAt, &run.Error)
+		&run.TokensUsed, &run.Cost, &run.MachineID, &run.StartedAt, &run.CompletedAt, &run.Error,
+		&run.ProviderRequestID, &run.ReplayOf, &run.ReplayOverrides, &run.Parameters, &run.PromptRef, &run.ResponseRef, &run.BatchID,
+		&run.CostAnomaly, &run.CostBaseline, &run.Cached, &run.PRURL)
 	if err == pgx.ErrNoRows {
 		return nil, nil
 	}
 	return &run, err
 }
 
-func (r *AgentRunRepository) ListByAgent(ctx context.Context, agentID uuid.UUID, limit int) ([]*models.AgentRun, error) {
+// ListByAgent returns a page of an agent's runs, newest first, starting after
+// cursor (empty for the first page). The returned cursor is empty on the last page.
+func (r *AgentRunRepository) ListByAgent(ctx context.Context, agentID uuid.UUID, cursor string, limit int) ([]*models.AgentRun, string, error) {
+	return r.page(ctx, "agent_id", agentID, cursor, limit)
+}
+
+// PageByTenant is ListByAgent across all of a tenant's agents
+func (r *AgentRunRepository) PageByTenant(ctx context.Context, tenantID uuid.UUID, cursor string, limit int) ([]*models.AgentRun, string, error) {
+	return r.page(ctx, "tenant_id", tenantID, cursor, limit)
+}
+
+// page returns a page of the runs whose column equals id
+func (r *AgentRunRepository) page(ctx context.Context, column string, id uuid.UUID, cursor string, limit int) ([]*models.AgentRun, string, error) {
+	if limit <= 0 {
+		return nil, "", fmt.Errorf("limit must be positive")
+	}
+	after, err := decodeRunCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
 	query := `SELECT id, agent_id, tenant_id, prompt, status, result, tokens_used, cost, 
-					 machine_id, started_at, completed_at, error 
-			  FROM agent_runs WHERE agent_id = $1 ORDER BY started_at DESC LIMIT $2`
-	rows, err := r.db.pool.Query(ctx, query, agentID, limit)
+					 COALESCE(machine_id, ''), started_at, completed_at, COALESCE(error, ''), COALESCE(provider_request_id, ''),
+					 replay_of, replay_overrides, parameters, COALESCE(prompt_ref, ''), COALESCE(response_ref, ''), batch_id,
+					 cost_anomaly, cost_baseline, cached, COALESCE(pr_url, '')
+			  FROM agent_runs WHERE ` + column + ` = $1`
+	args := []interface{}{id}
+	if after != nil {
+		query += ` AND (started_at, id) < ($2, $3)`
+		args = append(args, after.startedAt, after.id)
+	}
+	// Fetch one extra row to learn whether there is a next page
+	query += fmt.Sprintf(` ORDER BY started_at DESC, id DESC LIMIT $%d`, len(args)+1)
+	args = append(args, limit+1)
+
+	rows, err := r.db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var runs []*models.AgentRun
+	for rows.Next() {
+		var run models.AgentRun
+		if err := rows.Scan(
+			&run.ID, &run.AgentID, &run.TenantID, &run.Prompt, &run.Status, &run.Result,
+			&run.TokensUsed, &run.Cost, &run.MachineID, &run.StartedAt, &run.CompletedAt, &run.Error,
+			&run.ProviderRequestID, &run.ReplayOf, &run.ReplayOverrides, &run.Parameters, &run.PromptRef, &run.ResponseRef, &run.BatchID,
+			&run.CostAnomaly, &run.CostBaseline, &run.Cached, &run.PRURL); err != nil {
+			return nil, "", err
+		}
+		runs = append(runs, &run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(runs) > limit {
+		runs = runs[:limit]
+		last := runs[limit-1]
+		next = encodeRunCursor(runCursor{startedAt: last.StartedAt, id: last.ID})
+	}
+	return runs, next, nil
+}
+
+// CountByAgent counts an agent's runs
+func (r *AgentRunRepository) CountByAgent(ctx context.Context, agentID uuid.UUID) (int, error) {
+	query := `SELECT COUNT(*) FROM agent_runs WHERE agent_id = $1`
+	var count int
+	err := r.db.pool.QueryRow(ctx, query, agentID).Scan(&count)
+	return count, err
+}
+
+// CountByTenant counts the runs of all of a tenant's agents
+func (r *AgentRunRepository) CountByTenant(ctx context.Context, tenantID uuid.UUID) (int, error) {
+	query := `SELECT COUNT(*) FROM agent_runs WHERE tenant_id = $1`
+	var count int
+	err := r.db.pool.QueryRow(ctx, query, tenantID).Scan(&count)
+	return count, err
+}
+
+// ListByTenant returns runs across all of a tenant's agents, newest first
+func (r *AgentRunRepository) ListByTenant(ctx context.Context, tenantID uuid.UUID, limit, offset int) ([]*models.AgentRun, error) {
+	query := `SELECT r.id, r.agent_id, r.tenant_id, r.prompt, r.status, r.result, r.tokens_used, r.cost,
+					 COALESCE(r.machine_id, ''), r.started_at, r.completed_at, COALESCE(r.error, ''), COALESCE(r.provider_request_id, ''),
+					 r.replay_of, r.replay_overrides, r.parameters, COALESCE(r.prompt_ref, ''), COALESCE(r.response_ref, ''), r.batch_id,
+					 r.cost_anomaly, r.cost_baseline, r.cached, COALESCE(r.pr_url, '')
+			  FROM agent_runs r
+			  JOIN agents a ON a.id = r.agent_id AND a.tenant_id = r.tenant_id
+			  WHERE r.tenant_id = $1
+			  ORDER BY r.started_at DESC, r.id DESC
+			  LIMIT $2 OFFSET $3`
+	rows, err := r.db.pool.Query(ctx, query, tenantID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []*models.AgentRun
+	for rows.Next() {
+		var run models.AgentRun
+		if err := rows.Scan(
+			&run.ID, &run.AgentID, &run.TenantID, &run.Prompt, &run.Status, &run.Result,
+			&run.TokensUsed, &run.Cost, &run.MachineID, &run.StartedAt, &run.CompletedAt, &run.Error,
+			&run.ProviderRequestID, &run.ReplayOf, &run.ReplayOverrides, &run.Parameters, &run.PromptRef, &run.ResponseRef, &run.BatchID,
+			&run.CostAnomaly, &run.CostBaseline, &run.Cached, &run.PRURL); err != nil {
+			return nil, err
+		}
+		runs = append(runs, &run)
+	}
+	return runs, rows.Err()
+}
+
+// ListByBatch returns the runs started by a batch, in the order they were created
+func (r *AgentRunRepository) ListByBatch(ctx context.Context, batchID uuid.UUID) ([]*models.AgentRun, error) {
+	query := `SELECT id, agent_id, tenant_id, prompt, status, result, tokens_used, cost,
+					 COALESCE(machine_id, ''), started_at, completed_at, COALESCE(error, ''), COALESCE(provider_request_id, ''),
+					 replay_of, replay_overrides, parameters, COALESCE(prompt_ref, ''), COALESCE(response_ref, ''), batch_id,
+					 cost_anomaly, cost_baseline, cached, COALESCE(pr_url, '')
+			  FROM agent_runs WHERE batch_id = $1
+			  ORDER BY started_at, id`
+	rows, err := r.db.pool.Query(ctx, query, batchID)
 	if err != nil {
 		return nil, err
 	}
@@ -401,7 +968,9 @@ func (r *AgentRunRepository) ListByAgent(ctx context.Context, agentID uuid.UUID,
 		var run models.AgentRun
 		if err := rows.Scan(
 			&run.ID, &run.AgentID, &run.TenantID, &run.Prompt, &run.Status, &run.Result,
-			&run.TokensUsed, &run.Cost, &run.MachineID, &run.StartedAt, &run.CompletedAt, &run.Error); err != nil {
+			&run.TokensUsed, &run.Cost, &run.MachineID, &run.StartedAt, &run.CompletedAt, &run.Error,
+			&run.ProviderRequestID, &run.ReplayOf, &run.ReplayOverrides, &run.Parameters, &run.PromptRef, &run.ResponseRef, &run.BatchID,
+			&run.CostAnomaly, &run.CostBaseline, &run.Cached, &run.PRURL); err != nil {
 			return nil, err
 		}
 		runs = append(runs, &run)
@@ -409,148 +978,2579 @@ func (r *AgentRunRepository) ListByAgent(ctx context.Context, agentID uuid.UUID,
 	return runs, rows.Err()
 }
 
+// runCursor is a position in a run listing ordered by started_at DESC, id DESC.
+// Including the ID keeps pages stable when runs share a start time.
+type runCursor struct {
+	startedAt time.Time
+	id        uuid.UUID
+}
+
+// ErrInvalidCursor is returned for a malformed pagination cursor
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+func encodeRunCursor(c runCursor) string {
+	raw := fmt.Sprintf("%d:%s", c.startedAt.UnixMicro(), c.id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeRunCursor(cursor string) (*runCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	micros, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	usec, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	runID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &runCursor{startedAt: time.UnixMicro(usec), id: runID}, nil
+}
+
 func (r *AgentRunRepository) Complete(ctx context.Context, id uuid.UUID, result json.RawMessage, tokensUsed int, cost float64) error {
 	query := `UPDATE agent_runs SET status = $2, result = $3, tokens_used = $4, cost = $5, completed_at = $6 WHERE id = $1`
 	_, err := r.db.pool.Exec(ctx, query, id, models.RunStatusCompleted, result, tokensUsed, cost, time.Now())
 	return err
 }
 
+// CompletePartial finishes a run whose result didn't match its agent's result
+// schema, keeping the raw result and why it didn't
+func (r *AgentRunRepository) CompletePartial(ctx context.Context, id uuid.UUID, result json.RawMessage, tokensUsed int, cost float64, reason string) error {
+	query := `UPDATE agent_runs SET status = $2, result = $3, tokens_used = $4, cost = $5, error = $6, completed_at = $7 WHERE id = $1`
+	_, err := r.db.pool.Exec(ctx, query, id, models.RunStatusPartial, result, tokensUsed, cost, reason, time.Now())
+	return err
+}
+
+// CompleteCached completes a run with a result served from the response cache,
+// which used no tokens and cost nothing
+func (r *AgentRunRepository) CompleteCached(ctx context.Context, id uuid.UUID, result json.RawMessage) error {
+	query := `UPDATE agent_runs SET status = $2, result = $3, tokens_used = 0, cost = 0, cached = TRUE, completed_at = $4 WHERE id = $1`
+	_, err := r.db.pool.Exec(ctx, query, id, models.RunStatusCompleted, result, time.Now())
+	return err
+}
+
 func (r *AgentRunRepository) Fail(ctx context.Context, id uuid.UUID, errMsg string) error {
 	query := `UPDATE agent_runs SET status = $2, error = $3, completed_at = $4 WHERE id = $1`
 	_, err := r.db.pool.Exec(ctx, query, id, models.RunStatusFailed, errMsg, time.Now())
 	return err
 }
 
+// Cancel marks a run cancelled, reporting false when it had already finished
+func (r *AgentRunRepository) Cancel(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `UPDATE agent_runs SET status = $2, completed_at = $3
+			  WHERE id = $1 AND status IN ($4, $5, $6)`
+	tag, err := r.db.pool.Exec(ctx, query, id, models.RunStatusCancelled, time.Now(),
+		models.RunStatusPending, models.RunStatusBriefing, models.RunStatusRunning)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
 func (r *AgentRunRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status models.RunStatus) error {
 	query := `UPDATE agent_runs SET status = $2 WHERE id = $1`
 	_, err := r.db.pool.Exec(ctx, query, id, status)
 	return err
 }
 
-// =============================================================================
-// Placeholder repositories for other entities
-// =============================================================================
+// CountByStatus counts the tenant's runs started in [since, until) per status
+func (r *AgentRunRepository) CountByStatus(ctx context.Context, tenantID uuid.UUID, since, until time.Time) (map[models.RunStatus]int, error) {
+	query := `
+		SELECT status, COUNT(*) FROM agent_runs
+		WHERE tenant_id = $1 AND started_at >= $2 AND started_at < $3
+		GROUP BY status
+	`
+	rows, err := r.db.pool.Query(ctx, query, tenantID, since, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-type KnowledgeRepository struct {
-	db *PostgresDB
+	counts := make(map[models.RunStatus]int)
+	for rows.Next() {
+		var status models.RunStatus
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		counts[status] = count
+	}
+	return counts, rows.Err()
 }
 
-type RepositoryRepository struct {
-	db *PostgresDB
+// LatestByAgent returns the most recently started run of each of the
+// tenant's agents that has run, keyed by agent. Prompts and results are left out.
+func (r *AgentRunRepository) LatestByAgent(ctx context.Context, tenantID uuid.UUID) (map[uuid.UUID]*models.AgentRun, error) {
+	query := `
+		SELECT DISTINCT ON (agent_id) id, agent_id, tenant_id, status, tokens_used, cost,
+			   started_at, completed_at, COALESCE(error, '')
+		FROM agent_runs
+		WHERE tenant_id = $1
+		ORDER BY agent_id, started_at DESC, id DESC
+	`
+	rows, err := r.db.pool.Query(ctx, query, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := make(map[uuid.UUID]*models.AgentRun)
+	for rows.Next() {
+		var run models.AgentRun
+		if err := rows.Scan(&run.ID, &run.AgentID, &run.TenantID, &run.Status, &run.TokensUsed, &run.Cost,
+			&run.StartedAt, &run.CompletedAt, &run.Error); err != nil {
+			return nil, err
+		}
+		runs[run.AgentID] = &run
+	}
+	return runs, rows.Err()
 }
 
-type BusinessRepository struct {
-	db *PostgresDB
+// AverageDuration returns the mean duration of the tenant's most recent completed runs
+func (r *AgentRunRepository) AverageDuration(ctx context.Context, tenantID uuid.UUID, sample int) (time.Duration, error) {
+	query := `
+		SELECT COALESCE(AVG(EXTRACT(EPOCH FROM completed_at - started_at)), 0)
+		FROM (
+			SELECT started_at, completed_at FROM agent_runs
+			WHERE tenant_id = $1 AND status = $2 AND completed_at IS NOT NULL
+			ORDER BY completed_at DESC LIMIT $3
+		) recent
+	`
+	var seconds float64
+	err := r.db.pool.QueryRow(ctx, query, tenantID, models.RunStatusCompleted, sample).Scan(&seconds)
+	return time.Duration(seconds * float64(time.Second)), err
 }
 
-type ProjectRepository struct {
-	db *PostgresDB
+// CostBaseline returns the mean cost of the agent's most recent completed runs
+// other than excludeRunID, leaving out runs already flagged as anomalies and
+// runs served from the response cache, and how many runs it was taken over
+func (r *AgentRunRepository) CostBaseline(ctx context.Context, agentID, excludeRunID uuid.UUID, sample int) (float64, int, error) {
+	query := `
+		SELECT COALESCE(AVG(cost), 0), COUNT(*)
+		FROM (
+			SELECT cost FROM agent_runs
+			WHERE agent_id = $1 AND id <> $2 AND status = $3 AND NOT cost_anomaly AND NOT cached
+			ORDER BY completed_at DESC LIMIT $4
+		) recent
+	`
+	var baseline float64
+	var runs int
+	err := r.db.pool.QueryRow(ctx, query, agentID, excludeRunID, models.RunStatusCompleted, sample).Scan(&baseline, &runs)
+	return baseline, runs, err
 }
 
-type FinancialRepository struct {
-	db *PostgresDB
+// FlagCostAnomaly marks a run as costing far more than the baseline it was compared against
+func (r *AgentRunRepository) FlagCostAnomaly(ctx context.Context, id uuid.UUID, baseline float64) error {
+	query := `UPDATE agent_runs SET cost_anomaly = TRUE, cost_baseline = $2 WHERE id = $1`
+	_, err := r.db.pool.Exec(ctx, query, id, baseline)
+	return err
 }
 
-type SocialRepository struct {
-	db *PostgresDB
+// ListCostAnomalies returns the tenant's runs flagged as cost anomalies, newest first
+func (r *AgentRunRepository) ListCostAnomalies(ctx context.Context, tenantID uuid.UUID, limit int) ([]*models.AgentRun, error) {
+	query := `SELECT id, agent_id, tenant_id, prompt, status, result, tokens_used, cost,
+					 COALESCE(machine_id, ''), started_at, completed_at, COALESCE(error, ''), COALESCE(provider_request_id, ''),
+					 replay_of, replay_overrides, parameters, COALESCE(prompt_ref, ''), COALESCE(response_ref, ''), batch_id,
+					 cost_anomaly, cost_baseline, cached, COALESCE(pr_url, '')
+			  FROM agent_runs WHERE tenant_id = $1 AND cost_anomaly
+			  ORDER BY started_at DESC, id DESC
+			  LIMIT $2`
+	rows, err := r.db.pool.Query(ctx, query, tenantID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []*models.AgentRun
+	for rows.Next() {
+		var run models.AgentRun
+		if err := rows.Scan(
+			&run.ID, &run.AgentID, &run.TenantID, &run.Prompt, &run.Status, &run.Result,
+			&run.TokensUsed, &run.Cost, &run.MachineID, &run.StartedAt, &run.CompletedAt, &run.Error,
+			&run.ProviderRequestID, &run.ReplayOf, &run.ReplayOverrides, &run.Parameters, &run.PromptRef, &run.ResponseRef, &run.BatchID,
+			&run.CostAnomaly, &run.CostBaseline, &run.Cached, &run.PRURL); err != nil {
+			return nil, err
+		}
+		runs = append(runs, &run)
+	}
+	return runs, rows.Err()
 }
 
-type IoTRepository struct {
-	db *PostgresDB
+func (r *AgentRunRepository) SetProviderRequestID(ctx context.Context, id uuid.UUID, requestID string) error {
+	query := `UPDATE agent_runs SET provider_request_id = $2 WHERE id = $1`
+	_, err := r.db.pool.Exec(ctx, query, id, requestID)
+	return err
 }
 
-type AuditRepository struct {
+// SetResponseRef records where the full response of a truncated run result is stored
+func (r *AgentRunRepository) SetResponseRef(ctx context.Context, id uuid.UUID, ref string) error {
+	query := `UPDATE agent_runs SET response_ref = $2 WHERE id = $1`
+	_, err := r.db.pool.Exec(ctx, query, id, ref)
+	return err
+}
+
+// SetPRURL records the pull request opened with a coding run's changes
+func (r *AgentRunRepository) SetPRURL(ctx context.Context, id uuid.UUID, prURL string) error {
+	query := `UPDATE agent_runs SET pr_url = $2 WHERE id = $1`
+	_, err := r.db.pool.Exec(ctx, query, id, prURL)
+	return err
+}
+
+// =============================================================================
+// Agent Log Repository
+// =============================================================================
+
+type AgentLogRepository struct {
 	db *PostgresDB
 }
 
-func (r *AuditRepository) Create(ctx context.Context, log *models.AuditLog) error {
-	query := `
-		INSERT INTO audit_logs (id, tenant_id, user_id, agent_id, action, resource_type, resource_id,
-							   old_value, new_value, ip_address, user_agent, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-	`
+func (r *AgentLogRepository) Create(ctx context.Context, entry *models.AgentLog) error {
+	query := `INSERT INTO agent_logs (id, run_id, level, message, metadata, created_at) VALUES ($1, $2, $3, $4, $5, $6)`
 	_, err := r.db.pool.Exec(ctx, query,
-		log.ID, log.TenantID, log.UserID, log.AgentID, log.Action, log.ResourceType,
-		log.ResourceID, log.OldValue, log.NewValue, log.IPAddress, log.UserAgent, log.CreatedAt)
+		entry.ID, entry.RunID, entry.Level, entry.Message, entry.Metadata, entry.CreatedAt)
 	return err
 }
 
-type CostRepository struct {
+// ListByRun returns a run's most recent log entries, oldest first
+func (r *AgentLogRepository) ListByRun(ctx context.Context, runID uuid.UUID, limit int) ([]*models.AgentLog, error) {
+	query := `
+		SELECT id, run_id, level, message, metadata, created_at FROM (
+			SELECT id, run_id, level, message, metadata, created_at FROM agent_logs
+			WHERE run_id = $1 ORDER BY created_at DESC LIMIT $2
+		) recent ORDER BY created_at
+	`
+	rows, err := r.db.pool.Query(ctx, query, runID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*models.AgentLog
+	for rows.Next() {
+		var entry models.AgentLog
+		if err := rows.Scan(&entry.ID, &entry.RunID, &entry.Level, &entry.Message, &entry.Metadata, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, rows.Err()
+}
+
+// =============================================================================
+// Knowledge Repository
+// =============================================================================
+
+type KnowledgeRepository struct {
 	db *PostgresDB
 }
 
-func (r *CostRepository) RecordCost(ctx context.Context, record *models.CostRecord) error {
+func (r *KnowledgeRepository) GetBaseByID(ctx context.Context, id uuid.UUID) (*models.KnowledgeBase, error) {
+	query := `SELECT id, tenant_id, name, type, config, created_at, updated_at FROM knowledge_bases WHERE id = $1`
+	var kb models.KnowledgeBase
+	err := r.db.pool.QueryRow(ctx, query, id).Scan(
+		&kb.ID, &kb.TenantID, &kb.Name, &kb.Type, &kb.Config, &kb.CreatedAt, &kb.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return &kb, err
+}
+
+// ListBasesByTenant returns a tenant's knowledge bases ordered by name
+func (r *KnowledgeRepository) ListBasesByTenant(ctx context.Context, tenantID uuid.UUID) ([]*models.KnowledgeBase, error) {
+	query := `SELECT id, tenant_id, name, type, config, created_at, updated_at
+			  FROM knowledge_bases WHERE tenant_id = $1 ORDER BY name`
+	rows, err := r.db.pool.Query(ctx, query, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bases []*models.KnowledgeBase
+	for rows.Next() {
+		var kb models.KnowledgeBase
+		if err := rows.Scan(&kb.ID, &kb.TenantID, &kb.Name, &kb.Type, &kb.Config, &kb.CreatedAt, &kb.UpdatedAt); err != nil {
+			return nil, err
+		}
+		bases = append(bases, &kb)
+	}
+	return bases, rows.Err()
+}
+
+// CreateDocument records a document ingested into a knowledge base
+func (r *KnowledgeRepository) CreateDocument(ctx context.Context, doc *models.KnowledgeDocument) error {
 	query := `
-		INSERT INTO cost_records (id, tenant_id, agent_id, run_id, provider, model, 
-								 input_tokens, output_tokens, cost, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		INSERT INTO knowledge_documents (id, knowledge_base_id, source, source_type, content_hash, metadata, chunk_count, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	`
 	_, err := r.db.pool.Exec(ctx, query,
-		record.ID, record.TenantID, record.AgentID, record.RunID, record.Provider,
-		record.Model, record.InputTokens, record.OutputTokens, record.Cost, record.CreatedAt)
+		doc.ID, doc.KnowledgeBaseID, doc.Source, doc.SourceType, doc.ContentHash, doc.Metadata,
+		doc.ChunkCount, doc.CreatedAt, doc.UpdatedAt)
 	return err
 }
 
-func (r *CostRepository) GetTotalByTenant(ctx context.Context, tenantID uuid.UUID, since time.Time) (float64, error) {
-	query := `SELECT COALESCE(SUM(cost), 0) FROM cost_records WHERE tenant_id = $1 AND created_at >= $2`
-	var total float64
-	err := r.db.pool.QueryRow(ctx, query, tenantID, since).Scan(&total)
-	return total, err
+const knowledgeDocumentColumns = `id, knowledge_base_id, source, source_type, content_hash, metadata, chunk_count, created_at, updated_at`
+
+func scanKnowledgeDocument(row pgx.Row) (*models.KnowledgeDocument, error) {
+	var doc models.KnowledgeDocument
+	err := row.Scan(&doc.ID, &doc.KnowledgeBaseID, &doc.Source, &doc.SourceType, &doc.ContentHash,
+		&doc.Metadata, &doc.ChunkCount, &doc.CreatedAt, &doc.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &doc, nil
 }
 
-func (r *CostRepository) GetTotalByAgent(ctx context.Context, agentID uuid.UUID, since time.Time) (float64, error) {
-	query := `SELECT COALESCE(SUM(cost), 0) FROM cost_records WHERE agent_id = $1 AND created_at >= $2`
-	var total float64
-	err := r.db.pool.QueryRow(ctx, query, agentID, since).Scan(&total)
-	return total, err
+// GetDocumentBySource returns the newest document ingested from source
+func (r *KnowledgeRepository) GetDocumentBySource(ctx context.Context, kbID uuid.UUID, source string) (*models.KnowledgeDocument, error) {
+	query := `SELECT ` + knowledgeDocumentColumns + ` FROM knowledge_documents
+			  WHERE knowledge_base_id = $1 AND source = $2 ORDER BY created_at DESC LIMIT 1`
+	doc, err := scanKnowledgeDocument(r.db.pool.QueryRow(ctx, query, kbID, source))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return doc, err
 }
 
-// GetLimit retrieves cost limit for tenant or agent
-func (r *CostRepository) GetLimit(ctx context.Context, tenantID uuid.UUID, agentID *uuid.UUID, limitType string) (*models.CostLimit, error) {
-	var query string
-	var args []interface{}
-	
-	if agentID != nil {
-		query = `SELECT id, tenant_id, agent_id, limit_type, amount, alert_at, created_at 
-				 FROM cost_limits WHERE tenant_id = $1 AND agent_id = $2 AND limit_type = $3`
-		args = []interface{}{tenantID, agentID, limitType}
-	} else {
-		query = `SELECT id, tenant_id, agent_id, limit_type, amount, alert_at, created_at 
-				 FROM cost_limits WHERE tenant_id = $1 AND agent_id IS NULL AND limit_type = $2`
-		args = []interface{}{tenantID, limitType}
+// ListDocumentsBySourcePrefix returns a knowledge base's documents whose source
+// starts with prefix
+func (r *KnowledgeRepository) ListDocumentsBySourcePrefix(ctx context.Context, kbID uuid.UUID, prefix string) ([]*models.KnowledgeDocument, error) {
+	query := `SELECT ` + knowledgeDocumentColumns + ` FROM knowledge_documents
+			  WHERE knowledge_base_id = $1 AND starts_with(source, $2) ORDER BY source`
+	rows, err := r.db.pool.Query(ctx, query, kbID, prefix)
+	if err != nil {
+		return nil, err
 	}
-	
-	var limit models.CostLimit
-	err := r.db.pool.QueryRow(ctx, query, args...).Scan(
-		&limit.ID, &limit.TenantID, &limit.AgentID, &limit.LimitType,
-		&limit.Amount, &limit.AlertAt, &limit.CreatedAt)
+	defer rows.Close()
+
+	var docs []*models.KnowledgeDocument
+	for rows.Next() {
+		doc, err := scanKnowledgeDocument(rows)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, rows.Err()
+}
+
+func (r *KnowledgeRepository) DeleteDocument(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM knowledge_documents WHERE id = $1`
+	_, err := r.db.pool.Exec(ctx, query, id)
+	return err
+}
+
+// =============================================================================
+// Repository Repository
+// =============================================================================
+
+type RepositoryRepository struct {
+	db *PostgresDB
+}
+
+const repositoryColumns = `id, tenant_id, name, full_name, url, default_branch, is_private, last_sync_at,
+			metadata, created_at, knowledge_base_id, installation_id`
+
+func scanRepository(row pgx.Row) (*models.Repository, error) {
+	var repo models.Repository
+	err := row.Scan(&repo.ID, &repo.TenantID, &repo.Name, &repo.FullName, &repo.URL, &repo.DefaultBranch,
+		&repo.IsPrivate, &repo.LastSyncAt, &repo.Metadata, &repo.CreatedAt, &repo.KnowledgeBaseID, &repo.InstallationID)
+	if err != nil {
+		return nil, err
+	}
+	return &repo, nil
+}
+
+func (r *RepositoryRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Repository, error) {
+	query := `SELECT ` + repositoryColumns + ` FROM repositories WHERE id = $1`
+	repo, err := scanRepository(r.db.pool.QueryRow(ctx, query, id))
 	if err == pgx.ErrNoRows {
 		return nil, nil
 	}
-	return &limit, err
+	return repo, err
 }
 
-// SetLimit creates or updates a cost limit
-func (r *CostRepository) SetLimit(ctx context.Context, limit *models.CostLimit) error {
+// ListByFullName returns every tenant's connection to a GitHub repository
+func (r *RepositoryRepository) ListByFullName(ctx context.Context, fullName string) ([]*models.Repository, error) {
+	query := `SELECT ` + repositoryColumns + ` FROM repositories WHERE lower(full_name) = lower($1)`
+	rows, err := r.db.pool.Query(ctx, query, fullName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var repos []*models.Repository
+	for rows.Next() {
+		repo, err := scanRepository(rows)
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, repo)
+	}
+	return repos, rows.Err()
+}
+
+func (r *RepositoryRepository) UpdateLastSync(ctx context.Context, id uuid.UUID, syncedAt time.Time) error {
+	query := `UPDATE repositories SET last_sync_at = $2 WHERE id = $1`
+	_, err := r.db.pool.Exec(ctx, query, id, syncedAt)
+	return err
+}
+
+// SetInstallation records the GitHub App installation that can read the repository
+func (r *RepositoryRepository) SetInstallation(ctx context.Context, id uuid.UUID, installationID int64) error {
+	query := `UPDATE repositories SET installation_id = $2 WHERE id = $1`
+	_, err := r.db.pool.Exec(ctx, query, id, installationID)
+	return err
+}
+
+const syncJobColumns = `id, tenant_id, repository_id, trigger, status, COALESCE(commit_sha, ''), files_indexed,
+			files_deleted, COALESCE(error, ''), started_at, completed_at, created_at`
+
+func (r *RepositoryRepository) CreateSyncJob(ctx context.Context, job *models.RepositorySyncJob) error {
 	query := `
-		INSERT INTO cost_limits (id, tenant_id, agent_id, limit_type, amount, alert_at, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		ON CONFLICT (tenant_id, agent_id, limit_type) 
-		DO UPDATE SET amount = EXCLUDED.amount, alert_at = EXCLUDED.alert_at
+		INSERT INTO repository_sync_jobs (id, tenant_id, repository_id, trigger, status, commit_sha, created_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
 	`
 	_, err := r.db.pool.Exec(ctx, query,
-		limit.ID, limit.TenantID, limit.AgentID, limit.LimitType,
-		limit.Amount, limit.AlertAt, limit.CreatedAt)
+		job.ID, job.TenantID, job.RepositoryID, job.Trigger, job.Status, job.CommitSHA, job.CreatedAt)
 	return err
 }
 
-// Health check for repositories
-func (r *Repositories) Ping(ctx context.Context) error {
-	return r.db.Ping(ctx)
+func (r *RepositoryRepository) GetSyncJob(ctx context.Context, id uuid.UUID) (*models.RepositorySyncJob, error) {
+	query := `SELECT ` + syncJobColumns + ` FROM repository_sync_jobs WHERE id = $1`
+	var job models.RepositorySyncJob
+	err := r.db.pool.QueryRow(ctx, query, id).Scan(
+		&job.ID, &job.TenantID, &job.RepositoryID, &job.Trigger, &job.Status, &job.CommitSHA,
+		&job.FilesIndexed, &job.FilesDeleted, &job.Error, &job.StartedAt, &job.CompletedAt, &job.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return &job, err
 }
 
-// Helper function to generate error messages
-func ErrNotFound(entity string, id interface{}) error {
-	return fmt.Errorf("%s not found: %v", entity, id)
+// StartSyncJob marks a sync job running
+func (r *RepositoryRepository) StartSyncJob(ctx context.Context, id uuid.UUID, startedAt time.Time) error {
+	query := `UPDATE repository_sync_jobs SET status = $2, started_at = $3 WHERE id = $1`
+	_, err := r.db.pool.Exec(ctx, query, id, models.SyncStatusRunning, startedAt)
+	return err
+}
+
+// FinishSyncJob records a sync job's outcome
+func (r *RepositoryRepository) FinishSyncJob(ctx context.Context, job *models.RepositorySyncJob) error {
+	query := `
+		UPDATE repository_sync_jobs
+		SET status = $2, files_indexed = $3, files_deleted = $4, error = NULLIF($5, ''), completed_at = $6
+		WHERE id = $1
+	`
+	_, err := r.db.pool.Exec(ctx, query,
+		job.ID, job.Status, job.FilesIndexed, job.FilesDeleted, job.Error, job.CompletedAt)
+	return err
 }
 
+// =============================================================================
+// Business & Project Repositories
+// =============================================================================
+
+// ErrBusinessHasProjects is returned when deleting a business that still has
+// projects
+var ErrBusinessHasProjects = errors.New("business has projects")
+
+type BusinessRepository struct {
+	db *PostgresDB
+}
+
+const businessColumns = `id, tenant_id, name, type, settings, metadata, created_at, updated_at`
+
+func scanBusiness(row pgx.Row) (*models.Business, error) {
+	var b models.Business
+	err := row.Scan(&b.ID, &b.TenantID, &b.Name, &b.Type, &b.Settings, &b.Metadata, &b.CreatedAt, &b.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (r *BusinessRepository) Create(ctx context.Context, b *models.Business) error {
+	query := `
+		INSERT INTO businesses (id, tenant_id, name, type, settings, metadata, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	_, err := r.db.pool.Exec(ctx, query,
+		b.ID, b.TenantID, b.Name, b.Type, b.Settings, b.Metadata, b.CreatedAt, b.UpdatedAt)
+	return err
+}
+
+// GetByID returns a business, or nil if it does not exist
+func (r *BusinessRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Business, error) {
+	query := `SELECT ` + businessColumns + ` FROM businesses WHERE id = $1`
+	b, err := scanBusiness(r.db.pool.QueryRow(ctx, query, id))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return b, err
+}
+
+// ListByTenant returns a tenant's businesses, by name
+func (r *BusinessRepository) ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]*models.Business, error) {
+	query := `SELECT ` + businessColumns + ` FROM businesses WHERE tenant_id = $1 ORDER BY name, id`
+	rows, err := r.db.pool.Query(ctx, query, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var businesses []*models.Business
+	for rows.Next() {
+		b, err := scanBusiness(rows)
+		if err != nil {
+			return nil, err
+		}
+		businesses = append(businesses, b)
+	}
+	return businesses, rows.Err()
+}
+
+func (r *BusinessRepository) Update(ctx context.Context, b *models.Business) error {
+	query := `
+		UPDATE businesses SET name = $2, type = $3, settings = $4, metadata = $5, updated_at = $6
+		WHERE id = $1
+	`
+	_, err := r.db.pool.Exec(ctx, query, b.ID, b.Name, b.Type, b.Settings, b.Metadata, b.UpdatedAt)
+	return err
+}
+
+// Delete removes a business with its financial records. It returns
+// ErrBusinessHasProjects, and deletes nothing, while the business has
+// projects; the check and the delete are one statement, so a project created
+// concurrently cannot be orphaned.
+func (r *BusinessRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `
+		DELETE FROM businesses
+		WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM projects WHERE business_id = $1)
+	`
+	tag, err := r.db.pool.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		var hasProjects bool
+		query = `SELECT EXISTS (SELECT 1 FROM projects WHERE business_id = $1)`
+		if err := r.db.pool.QueryRow(ctx, query, id).Scan(&hasProjects); err != nil {
+			return err
+		}
+		if hasProjects {
+			return ErrBusinessHasProjects
+		}
+	}
+	return nil
+}
+
+type ProjectRepository struct {
+	db *PostgresDB
+}
+
+const projectColumns = `p.id, p.business_id, p.name, COALESCE(p.description, ''), p.repositories, p.agents,
+	p.status, p.created_at, p.updated_at`
+
+// scanProject scans a row of projectColumns, followed by any extra columns
+// into extra
+func scanProject(row pgx.Row, extra ...interface{}) (*models.Project, error) {
+	var p models.Project
+	var reposJSON, agentsJSON []byte
+	dest := append([]interface{}{&p.ID, &p.BusinessID, &p.Name, &p.Description, &reposJSON, &agentsJSON,
+		&p.Status, &p.CreatedAt, &p.UpdatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(reposJSON, &p.Repositories); err != nil {
+		return nil, fmt.Errorf("failed to decode project repositories: %w", err)
+	}
+	if err := json.Unmarshal(agentsJSON, &p.Agents); err != nil {
+		return nil, fmt.Errorf("failed to decode project agents: %w", err)
+	}
+	return &p, nil
+}
+
+// projectRefs encodes a project's repository or agent IDs as a JSON array
+func projectRefs(ids []uuid.UUID) []byte {
+	if ids == nil {
+		ids = []uuid.UUID{}
+	}
+	data, _ := json.Marshal(ids)
+	return data
+}
+
+// Create saves a project. It fails if the business has been deleted.
+func (r *ProjectRepository) Create(ctx context.Context, p *models.Project) error {
+	query := `
+		INSERT INTO projects (id, business_id, name, description, repositories, agents, status, created_at, updated_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9)
+	`
+	_, err := r.db.pool.Exec(ctx, query,
+		p.ID, p.BusinessID, p.Name, p.Description, projectRefs(p.Repositories), projectRefs(p.Agents),
+		p.Status, p.CreatedAt, p.UpdatedAt)
+	return err
+}
+
+// GetByID returns a project and the tenant owning its business, or nil if it
+// does not exist
+func (r *ProjectRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Project, uuid.UUID, error) {
+	query := `
+		SELECT ` + projectColumns + `, b.tenant_id
+		FROM projects p JOIN businesses b ON b.id = p.business_id
+		WHERE p.id = $1
+	`
+	var tenantID uuid.UUID
+	project, err := scanProject(r.db.pool.QueryRow(ctx, query, id), &tenantID)
+	if err == pgx.ErrNoRows {
+		return nil, uuid.Nil, nil
+	}
+	return project, tenantID, err
+}
+
+// ListByTenant returns the projects of a tenant's businesses, or of one of
+// them when businessID is set, by name
+func (r *ProjectRepository) ListByTenant(ctx context.Context, tenantID uuid.UUID, businessID *uuid.UUID) ([]*models.Project, error) {
+	query := `
+		SELECT ` + projectColumns + `
+		FROM projects p JOIN businesses b ON b.id = p.business_id
+		WHERE b.tenant_id = $1
+	`
+	args := []interface{}{tenantID}
+	if businessID != nil {
+		args = append(args, *businessID)
+		query += fmt.Sprintf(" AND p.business_id = $%d", len(args))
+	}
+	query += " ORDER BY p.name, p.id"
+
+	rows, err := r.db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var projects []*models.Project
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, p)
+	}
+	return projects, rows.Err()
+}
+
+func (r *ProjectRepository) Update(ctx context.Context, p *models.Project) error {
+	query := `
+		UPDATE projects SET business_id = $2, name = $3, description = NULLIF($4, ''), repositories = $5,
+			agents = $6, status = $7, updated_at = $8
+		WHERE id = $1
+	`
+	_, err := r.db.pool.Exec(ctx, query,
+		p.ID, p.BusinessID, p.Name, p.Description, projectRefs(p.Repositories), projectRefs(p.Agents),
+		p.Status, p.UpdatedAt)
+	return err
+}
+
+func (r *ProjectRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM projects WHERE id = $1`
+	_, err := r.db.pool.Exec(ctx, query, id)
+	return err
+}
+
+// =============================================================================
+// IoT Repository
+// =============================================================================
+
+type IoTRepository struct {
+	db *PostgresDB
+}
+
+const iotDeviceColumns = `id, tenant_id, business_id, name, COALESCE(description, ''), COALESCE(type, ''), protocol,
+			COALESCE(endpoint, ''), COALESCE(credentials, ''), status, COALESCE(location, ''), config,
+			last_seen_at, metadata, created_at, updated_at`
+
+func scanIoTDevice(row pgx.Row) (*models.IoTDevice, error) {
+	var d models.IoTDevice
+	err := row.Scan(&d.ID, &d.TenantID, &d.BusinessID, &d.Name, &d.Description, &d.Type, &d.Protocol,
+		&d.Endpoint, &d.Credentials, &d.Status, &d.Location, &d.Config,
+		&d.LastSeenAt, &d.Metadata, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// IoTDeviceFilter narrows a device listing; empty fields match everything
+type IoTDeviceFilter struct {
+	Type       string
+	Status     string
+	BusinessID *uuid.UUID
+}
+
+func (r *IoTRepository) CreateDevice(ctx context.Context, d *models.IoTDevice) error {
+	query := `
+		INSERT INTO iot_devices (id, tenant_id, business_id, name, description, type, protocol, endpoint,
+								 credentials, status, location, config, last_seen_at, metadata, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+	`
+	_, err := r.db.pool.Exec(ctx, query,
+		d.ID, d.TenantID, d.BusinessID, d.Name, d.Description, d.Type, d.Protocol, d.Endpoint,
+		d.Credentials, d.Status, d.Location, d.Config, d.LastSeenAt, d.Metadata, d.CreatedAt, d.UpdatedAt)
+	return err
+}
+
+func (r *IoTRepository) GetDevice(ctx context.Context, id uuid.UUID) (*models.IoTDevice, error) {
+	query := `SELECT ` + iotDeviceColumns + ` FROM iot_devices WHERE id = $1`
+	d, err := scanIoTDevice(r.db.pool.QueryRow(ctx, query, id))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return d, err
+}
+
+func (r *IoTRepository) UpdateDevice(ctx context.Context, d *models.IoTDevice) error {
+	query := `
+		UPDATE iot_devices
+		SET business_id = $2, name = $3, description = $4, type = $5, protocol = $6, endpoint = $7,
+			credentials = $8, status = $9, location = $10, config = $11, last_seen_at = $12, metadata = $13
+		WHERE id = $1
+	`
+	_, err := r.db.pool.Exec(ctx, query,
+		d.ID, d.BusinessID, d.Name, d.Description, d.Type, d.Protocol, d.Endpoint,
+		d.Credentials, d.Status, d.Location, d.Config, d.LastSeenAt, d.Metadata)
+	return err
+}
+
+// UpdateDeviceStatus records a device going online or offline
+func (r *IoTRepository) UpdateDeviceStatus(ctx context.Context, id uuid.UUID, status string, lastSeenAt time.Time) error {
+	query := `UPDATE iot_devices SET status = $2, last_seen_at = $3 WHERE id = $1`
+	_, err := r.db.pool.Exec(ctx, query, id, status, lastSeenAt)
+	return err
+}
+
+func (r *IoTRepository) DeleteDevice(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM iot_devices WHERE id = $1`
+	_, err := r.db.pool.Exec(ctx, query, id)
+	return err
+}
+
+// ListDevices returns a tenant's devices matching the filter, by name
+func (r *IoTRepository) ListDevices(ctx context.Context, tenantID uuid.UUID, filter IoTDeviceFilter) ([]*models.IoTDevice, error) {
+	query := `SELECT ` + iotDeviceColumns + ` FROM iot_devices WHERE tenant_id = $1`
+	args := []interface{}{tenantID}
+	if filter.Type != "" {
+		args = append(args, filter.Type)
+		query += fmt.Sprintf(" AND type = $%d", len(args))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		query += fmt.Sprintf(" AND status = $%d", len(args))
+	}
+	if filter.BusinessID != nil {
+		args = append(args, *filter.BusinessID)
+		query += fmt.Sprintf(" AND business_id = $%d", len(args))
+	}
+	query += " ORDER BY name, id"
+	return r.listDevices(ctx, query, args...)
+}
+
+// ListAllDevices returns every tenant's devices
+func (r *IoTRepository) ListAllDevices(ctx context.Context) ([]*models.IoTDevice, error) {
+	query := `SELECT ` + iotDeviceColumns + ` FROM iot_devices`
+	return r.listDevices(ctx, query)
+}
+
+func (r *IoTRepository) listDevices(ctx context.Context, query string, args ...interface{}) ([]*models.IoTDevice, error) {
+	rows, err := r.db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var devices []*models.IoTDevice
+	for rows.Next() {
+		d, err := scanIoTDevice(rows)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, d)
+	}
+	return devices, rows.Err()
+}
+
+const iotCommandColumns = `id, device_id, command, parameters, status, result, COALESCE(error, ''),
+			sent_at, acknowledged_at, completed_at, created_at`
+
+// SaveCommand inserts a command or records its latest state. Statuses only
+// move forward (pending, sent, acknowledged, then completed or failed), so a
+// late write of an older state is ignored.
+func (r *IoTRepository) SaveCommand(ctx context.Context, c *models.IoTCommand) error {
+	query := `
+		INSERT INTO iot_commands (id, device_id, command, parameters, status, result, error,
+								  sent_at, acknowledged_at, completed_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9, $10, $11)
+		ON CONFLICT (id) DO UPDATE
+		SET status = EXCLUDED.status, result = EXCLUDED.result, error = EXCLUDED.error,
+			sent_at = EXCLUDED.sent_at, acknowledged_at = EXCLUDED.acknowledged_at,
+			completed_at = EXCLUDED.completed_at
+		WHERE iot_commands.status NOT IN ('completed', 'failed')
+			AND array_position(ARRAY['pending', 'sent', 'acknowledged', 'completed', 'failed'], EXCLUDED.status::text)
+				> array_position(ARRAY['pending', 'sent', 'acknowledged', 'completed', 'failed'], iot_commands.status::text)
+	`
+	_, err := r.db.pool.Exec(ctx, query,
+		c.ID, c.DeviceID, c.Command, c.Parameters, c.Status, c.Result, c.Error,
+		c.SentAt, c.AcknowledgedAt, c.CompletedAt, c.CreatedAt)
+	return err
+}
+
+func (r *IoTRepository) GetCommand(ctx context.Context, id uuid.UUID) (*models.IoTCommand, error) {
+	query := `SELECT ` + iotCommandColumns + ` FROM iot_commands WHERE id = $1`
+	var c models.IoTCommand
+	err := r.db.pool.QueryRow(ctx, query, id).Scan(&c.ID, &c.DeviceID, &c.Command, &c.Parameters, &c.Status,
+		&c.Result, &c.Error, &c.SentAt, &c.AcknowledgedAt, &c.CompletedAt, &c.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// FailUnacknowledgedCommands fails commands sent before sentBefore that the
+// device never acknowledged
+func (r *IoTRepository) FailUnacknowledgedCommands(ctx context.Context, sentBefore time.Time, reason string) (int64, error) {
+	query := `
+		UPDATE iot_commands
+		SET status = 'failed', error = $2, completed_at = NOW()
+		WHERE status = 'sent' AND sent_at < $1
+	`
+	tag, err := r.db.pool.Exec(ctx, query, sentBefore, reason)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+type AuditRepository struct {
+	db *PostgresDB
+}
+
+func (r *AuditRepository) Create(ctx context.Context, log *models.AuditLog) error {
+	query := `
+		INSERT INTO audit_logs (id, tenant_id, user_id, agent_id, action, resource_type, resource_id,
+							   old_value, new_value, ip_address, user_agent, severity, session_id, details, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9, NULLIF($10, ''), NULLIF($11, ''), COALESCE(NULLIF($12, ''), 'info'), NULLIF($13, ''), $14, $15)
+	`
+	_, err := r.db.pool.Exec(ctx, query,
+		log.ID, log.TenantID, log.UserID, log.AgentID, log.Action, log.ResourceType,
+		log.ResourceID, log.OldValue, log.NewValue, log.IPAddress, log.UserAgent,
+		log.Severity, log.SessionID, log.Details, log.CreatedAt)
+	return err
+}
+
+const auditLogColumns = `id, tenant_id, user_id, agent_id, action, resource_type, COALESCE(resource_id, ''),
+			old_value, new_value, COALESCE(ip_address, ''), COALESCE(user_agent, ''), severity,
+			COALESCE(session_id, ''), details, created_at`
+
+func scanAuditLog(row pgx.Row) (*models.AuditLog, error) {
+	var l models.AuditLog
+	err := row.Scan(&l.ID, &l.TenantID, &l.UserID, &l.AgentID, &l.Action, &l.ResourceType, &l.ResourceID,
+		&l.OldValue, &l.NewValue, &l.IPAddress, &l.UserAgent, &l.Severity,
+		&l.SessionID, &l.Details, &l.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (r *AuditRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.AuditLog, error) {
+	query := `SELECT ` + auditLogColumns + ` FROM audit_logs WHERE id = $1`
+	l, err := scanAuditLog(r.db.pool.QueryRow(ctx, query, id))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return l, err
+}
+
+// AuditLogFilter narrows an audit log query; empty fields match everything.
+// The time range is [Start, End).
+type AuditLogFilter struct {
+	TenantID     uuid.UUID
+	UserID       *uuid.UUID
+	Actions      []string
+	ResourceType string
+	ResourceID   string
+	Severity     string
+	Start        *time.Time
+	End          *time.Time
+	Limit        int
+	Offset       int
+}
+
+// CountAction counts the tenant's audit logs of an action recorded in [since, until)
+func (r *AuditRepository) CountAction(ctx context.Context, tenantID uuid.UUID, action string, since, until time.Time) (int, error) {
+	query := `SELECT COUNT(*) FROM audit_logs WHERE tenant_id = $1 AND action = $2 AND created_at >= $3 AND created_at < $4`
+	var count int
+	err := r.db.pool.QueryRow(ctx, query, tenantID, action, since, until).Scan(&count)
+	return count, err
+}
+
+// Query returns a tenant's audit logs matching the filter, newest first
+func (r *AuditRepository) Query(ctx context.Context, filter AuditLogFilter) ([]*models.AuditLog, error) {
+	query := `SELECT ` + auditLogColumns + ` FROM audit_logs WHERE tenant_id = $1`
+	args := []interface{}{filter.TenantID}
+	if filter.UserID != nil {
+		args = append(args, *filter.UserID)
+		query += fmt.Sprintf(" AND user_id = $%d", len(args))
+	}
+	if len(filter.Actions) > 0 {
+		args = append(args, filter.Actions)
+		query += fmt.Sprintf(" AND action = ANY($%d)", len(args))
+	}
+	if filter.ResourceType != "" {
+		args = append(args, filter.ResourceType)
+		query += fmt.Sprintf(" AND resource_type = $%d", len(args))
+	}
+	if filter.ResourceID != "" {
+		args = append(args, filter.ResourceID)
+		query += fmt.Sprintf(" AND resource_id = $%d", len(args))
+	}
+	if filter.Severity != "" {
+		args = append(args, filter.Severity)
+		query += fmt.Sprintf(" AND severity = $%d", len(args))
+	}
+	if filter.Start != nil {
+		args = append(args, *filter.Start)
+		query += fmt.Sprintf(" AND created_at >= $%d", len(args))
+	}
+	if filter.End != nil {
+		args = append(args, *filter.End)
+		query += fmt.Sprintf(" AND created_at < $%d", len(args))
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if filter.Offset > 0 {
+		args = append(args, filter.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	rows, err := r.db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logs []*models.AuditLog
+	for rows.Next() {
+		l, err := scanAuditLog(rows)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, l)
+	}
+	return logs, rows.Err()
+}
+
+type CostRepository struct {
+	db *PostgresDB
+}
+
+func (r *CostRepository) RecordCost(ctx context.Context, record *models.CostRecord) error {
+	query := `
+		INSERT INTO cost_records (id, tenant_id, agent_id, run_id, provider, model, 
+								 input_tokens, output_tokens, cost, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+	_, err := r.db.pool.Exec(ctx, query,
+		record.ID, record.TenantID, record.AgentID, record.RunID, record.Provider,
+		record.Model, record.InputTokens, record.OutputTokens, record.Cost, record.CreatedAt)
+	return err
+}
+
+func (r *CostRepository) GetTotalByTenant(ctx context.Context, tenantID uuid.UUID, since time.Time) (float64, error) {
+	query := `SELECT COALESCE(SUM(cost), 0) FROM cost_records WHERE tenant_id = $1 AND created_at >= $2`
+	var total float64
+	err := r.db.pool.QueryRow(ctx, query, tenantID, since).Scan(&total)
+	return total, err
+}
+
+func (r *CostRepository) GetTotalByAgent(ctx context.Context, agentID uuid.UUID, since time.Time) (float64, error) {
+	query := `SELECT COALESCE(SUM(cost), 0) FROM cost_records WHERE agent_id = $1 AND created_at >= $2`
+	var total float64
+	err := r.db.pool.QueryRow(ctx, query, agentID, since).Scan(&total)
+	return total, err
+}
+
+// GetLimit retrieves cost limit for tenant or agent
+func (r *CostRepository) GetLimit(ctx context.Context, tenantID uuid.UUID, agentID *uuid.UUID, limitType string) (*models.CostLimit, error) {
+	var query string
+	var args []interface{}
+	
+	if agentID != nil {
+		query = `SELECT id, tenant_id, agent_id, limit_type, amount, alert_at, created_at 
+				 FROM cost_limits WHERE tenant_id = $1 AND agent_id = $2 AND limit_type = $3`
+		args = []interface{}{tenantID, agentID, limitType}
+	} else {
+		query = `SELECT id, tenant_id, agent_id, limit_type, amount, alert_at, created_at 
+				 FROM cost_limits WHERE tenant_id = $1 AND agent_id IS NULL AND limit_type = $2`
+		args = []interface{}{tenantID, limitType}
+	}
+	
+	var limit models.CostLimit
+	err := r.db.pool.QueryRow(ctx, query, args...).Scan(
+		&limit.ID, &limit.TenantID, &limit.AgentID, &limit.LimitType,
+		&limit.Amount, &limit.AlertAt, &limit.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return &limit, err
+}
+
+// CostTotal is the summed cost and token usage of a group of cost records
+type CostTotal struct {
+	Group        string // agent ID or provider; empty for records with no agent
+	Cost         float64
+	InputTokens  int
+	OutputTokens int
+	Records      int
+}
+
+// TotalsByAgent sums the tenant's costs in [since, until) per agent, most expensive first
+func (r *CostRepository) TotalsByAgent(ctx context.Context, tenantID uuid.UUID, since, until time.Time) ([]CostTotal, error) {
+	return r.totals(ctx, `COALESCE(agent_id::text, '')`, tenantID, since, until)
+}
+
+// TotalsByProvider sums the tenant's costs in [since, until) per provider, most expensive first
+func (r *CostRepository) TotalsByProvider(ctx context.Context, tenantID uuid.UUID, since, until time.Time) ([]CostTotal, error) {
+	return r.totals(ctx, `provider`, tenantID, since, until)
+}
+
+func (r *CostRepository) totals(ctx context.Context, group string, tenantID uuid.UUID, since, until time.Time) ([]CostTotal, error) {
+	query := fmt.Sprintf(`
+		SELECT %[1]s, COALESCE(SUM(cost), 0), COALESCE(SUM(input_tokens), 0), COALESCE(SUM(output_tokens), 0), COUNT(*)
+		FROM cost_records
+		WHERE tenant_id = $1 AND created_at >= $2 AND created_at < $3
+		GROUP BY %[1]s
+		ORDER BY 2 DESC, 1
+	`, group)
+	rows, err := r.db.pool.Query(ctx, query, tenantID, since, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var totals []CostTotal
+	for rows.Next() {
+		var t CostTotal
+		if err := rows.Scan(&t.Group, &t.Cost, &t.InputTokens, &t.OutputTokens, &t.Records); err != nil {
+			return nil, err
+		}
+		totals = append(totals, t)
+	}
+	return totals, rows.Err()
+}
+
+// DailyTotals sums the tenant's costs in each 24 hours from since up to until,
+// oldest first. Days without costs are zero.
+func (r *CostRepository) DailyTotals(ctx context.Context, tenantID uuid.UUID, since, until time.Time) ([]float64, error) {
+	days := int((until.Sub(since) + 24*time.Hour - 1) / (24 * time.Hour))
+	if days <= 0 {
+		return nil, nil
+	}
+
+	query := `
+		SELECT FLOOR(EXTRACT(EPOCH FROM created_at - $2) / 86400)::int, SUM(cost)
+		FROM cost_records
+		WHERE tenant_id = $1 AND created_at >= $2 AND created_at < $3
+		GROUP BY 1
+	`
+	rows, err := r.db.pool.Query(ctx, query, tenantID, since, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := make([]float64, days)
+	for rows.Next() {
+		var day int
+		var cost float64
+		if err := rows.Scan(&day, &cost); err != nil {
+			return nil, err
+		}
+		if day >= 0 && day < days {
+			totals[day] += cost
+		}
+	}
+	return totals, rows.Err()
+}
+
+// BudgetWindows returns the tenant-wide daily and monthly limits that are set,
+// each with the start of its current period
+func (r *CostRepository) BudgetWindows(ctx context.Context, tenantID uuid.UUID, now time.Time) ([]BudgetWindow, error) {
+	var windows []BudgetWindow
+	for _, limitType := range []string{"daily", "monthly"} {
+		limit, err := r.GetLimit(ctx, tenantID, nil, limitType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s limit: %w", limitType, err)
+		}
+		if limit == nil || limit.Amount <= 0 {
+			continue
+		}
+		start, _, err := BudgetPeriod(limit, now)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, BudgetWindow{Limit: limit, Since: start})
+	}
+	return windows, nil
+}
+
+// BudgetPeriod returns the bounds of the limit's period containing now, in UTC:
+// from midnight to midnight for a daily limit and from the first of the month
+// to the first of the next for a monthly one. The end is exclusive.
+func BudgetPeriod(limit *models.CostLimit, now time.Time) (time.Time, time.Time, error) {
+	now = now.UTC()
+	switch limit.LimitType {
+	case "daily":
+		start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 0, 1), nil
+	case "monthly":
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0), nil
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("unknown limit type: %s", limit.LimitType)
+	}
+}
+
+// RemainingBudget returns how much of the limit is left after spent, never
+// less than zero
+func RemainingBudget(limit *models.CostLimit, spent float64) float64 {
+	return max(limit.Amount-spent, 0)
+}
+
+// SetLimit creates or updates a cost limit
+func (r *CostRepository) SetLimit(ctx context.Context, limit *models.CostLimit) error {
+	query := `
+		INSERT INTO cost_limits (id, tenant_id, agent_id, limit_type, amount, alert_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (tenant_id, agent_id, limit_type) 
+		DO UPDATE SET amount = EXCLUDED.amount, alert_at = EXCLUDED.alert_at
+	`
+	_, err := r.db.pool.Exec(ctx, query,
+		limit.ID, limit.TenantID, limit.AgentID, limit.LimitType,
+		limit.Amount, limit.AlertAt, limit.CreatedAt)
+	return err
+}
+
+// =============================================================================
+// Notification Repository
+// =============================================================================
+
+type NotificationRepository struct {
+	db *PostgresDB
+}
+
+func (r *NotificationRepository) CreateRecipient(ctx context.Context, recipient *models.NotificationRecipient) error {
+	query := `
+		INSERT INTO notification_recipients (id, tenant_id, channel, address, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	_, err := r.db.pool.Exec(ctx, query,
+		recipient.ID, recipient.TenantID, recipient.Channel, recipient.Address, recipient.CreatedAt)
+	return uniqueViolation(err)
+}
+
+func (r *NotificationRepository) GetRecipientByID(ctx context.Context, id uuid.UUID) (*models.NotificationRecipient, error) {
+	query := `SELECT id, tenant_id, channel, address, created_at FROM notification_recipients WHERE id = $1`
+	var recipient models.NotificationRecipient
+	err := r.db.pool.QueryRow(ctx, query, id).Scan(
+		&recipient.ID, &recipient.TenantID, &recipient.Channel, &recipient.Address, &recipient.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return &recipient, err
+}
+
+func (r *NotificationRepository) ListRecipients(ctx context.Context, tenantID uuid.UUID) ([]*models.NotificationRecipient, error) {
+	query := `SELECT id, tenant_id, channel, address, created_at 
+			  FROM notification_recipients WHERE tenant_id = $1 ORDER BY created_at`
+	rows, err := r.db.pool.Query(ctx, query, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipients []*models.NotificationRecipient
+	for rows.Next() {
+		var recipient models.NotificationRecipient
+		if err := rows.Scan(
+			&recipient.ID, &recipient.TenantID, &recipient.Channel, &recipient.Address, &recipient.CreatedAt); err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, &recipient)
+	}
+	return recipients, rows.Err()
+}
+
+func (r *NotificationRepository) DeleteRecipient(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM notification_recipients WHERE id = $1`
+	_, err := r.db.pool.Exec(ctx, query, id)
+	return err
+}
+
+// ListPreferences returns the tenant's default preferences and, when userID
+// is set, that user's overrides
+func (r *NotificationRepository) ListPreferences(ctx context.Context, tenantID uuid.UUID, userID *uuid.UUID) ([]*models.NotificationPreference, error) {
+	query := `SELECT id, tenant_id, user_id, notification_type, enabled_channels, created_at, updated_at
+			  FROM notification_preferences WHERE tenant_id = $1 AND (user_id IS NULL OR user_id = $2)
+			  ORDER BY notification_type, user_id NULLS FIRST`
+	rows, err := r.db.pool.Query(ctx, query, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prefs []*models.NotificationPreference
+	for rows.Next() {
+		var p models.NotificationPreference
+		if err := rows.Scan(&p.ID, &p.TenantID, &p.UserID, &p.NotificationType, &p.EnabledChannels,
+			&p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		prefs = append(prefs, &p)
+	}
+	return prefs, rows.Err()
+}
+
+// ListUserPreferences returns the tenant's default preferences and the
+// overrides of each of the users
+func (r *NotificationRepository) ListUserPreferences(ctx context.Context, tenantID uuid.UUID, userIDs []uuid.UUID) ([]*models.NotificationPreference, error) {
+	query := `SELECT id, tenant_id, user_id, notification_type, enabled_channels, created_at, updated_at
+			  FROM notification_preferences WHERE tenant_id = $1 AND (user_id IS NULL OR user_id = ANY($2))
+			  ORDER BY notification_type, user_id NULLS FIRST`
+	rows, err := r.db.pool.Query(ctx, query, tenantID, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prefs []*models.NotificationPreference
+	for rows.Next() {
+		var p models.NotificationPreference
+		if err := rows.Scan(&p.ID, &p.TenantID, &p.UserID, &p.NotificationType, &p.EnabledChannels,
+			&p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		prefs = append(prefs, &p)
+	}
+	return prefs, rows.Err()
+}
+
+// SavePreference creates or replaces the tenant's or a user's preference for
+// a notification type
+func (r *NotificationRepository) SavePreference(ctx context.Context, p *models.NotificationPreference) error {
+	conflict := `(tenant_id, notification_type) WHERE user_id IS NULL`
+	if p.UserID != nil {
+		conflict = `(user_id, notification_type) WHERE user_id IS NOT NULL`
+	}
+	query := `
+		INSERT INTO notification_preferences (id, tenant_id, user_id, notification_type, enabled_channels, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT ` + conflict + ` DO UPDATE SET enabled_channels = EXCLUDED.enabled_channels
+		RETURNING id, created_at, updated_at
+	`
+	return r.db.pool.QueryRow(ctx, query,
+		p.ID, p.TenantID, p.UserID, p.NotificationType, p.EnabledChannels, p.CreatedAt, p.UpdatedAt,
+	).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+}
+
+// DeletePreference removes the tenant's (userID nil) or a user's preference
+// for a notification type
+func (r *NotificationRepository) DeletePreference(ctx context.Context, tenantID uuid.UUID, userID *uuid.UUID, notificationType string) error {
+	query := `DELETE FROM notification_preferences
+			  WHERE tenant_id = $1 AND user_id IS NOT DISTINCT FROM $2 AND notification_type = $3`
+	_, err := r.db.pool.Exec(ctx, query, tenantID, userID, notificationType)
+	return err
+}
+
+// GetDoNotDisturb returns a user's do-not-disturb window, or nil if none is set
+func (r *NotificationRepository) GetDoNotDisturb(ctx context.Context, userID uuid.UUID) (*models.DoNotDisturb, error) {
+	query := `SELECT user_id, tenant_id, enabled, to_char(start_time, 'HH24:MI'), to_char(end_time, 'HH24:MI'),
+			  timezone, updated_at FROM notification_do_not_disturb WHERE user_id = $1`
+	var d models.DoNotDisturb
+	err := r.db.pool.QueryRow(ctx, query, userID).Scan(
+		&d.UserID, &d.TenantID, &d.Enabled, &d.StartTime, &d.EndTime, &d.Timezone, &d.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// ListDoNotDisturb returns the do-not-disturb windows the users have set
+func (r *NotificationRepository) ListDoNotDisturb(ctx context.Context, userIDs []uuid.UUID) ([]*models.DoNotDisturb, error) {
+	query := `SELECT user_id, tenant_id, enabled, to_char(start_time, 'HH24:MI'), to_char(end_time, 'HH24:MI'),
+			  timezone, updated_at FROM notification_do_not_disturb WHERE user_id = ANY($1)`
+	rows, err := r.db.pool.Query(ctx, query, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var windows []*models.DoNotDisturb
+	for rows.Next() {
+		var d models.DoNotDisturb
+		if err := rows.Scan(&d.UserID, &d.TenantID, &d.Enabled, &d.StartTime, &d.EndTime, &d.Timezone, &d.UpdatedAt); err != nil {
+			return nil, err
+		}
+		windows = append(windows, &d)
+	}
+	return windows, rows.Err()
+}
+
+// SaveDoNotDisturb creates or replaces a user's do-not-disturb window
+func (r *NotificationRepository) SaveDoNotDisturb(ctx context.Context, d *models.DoNotDisturb) error {
+	query := `
+		INSERT INTO notification_do_not_disturb (user_id, tenant_id, enabled, start_time, end_time, timezone, updated_at)
+		VALUES ($1, $2, $3, $4::time, $5::time, $6, $7)
+		ON CONFLICT (user_id) DO UPDATE SET
+			enabled = EXCLUDED.enabled, start_time = EXCLUDED.start_time, end_time = EXCLUDED.end_time,
+			timezone = EXCLUDED.timezone, updated_at = EXCLUDED.updated_at
+	`
+	_, err := r.db.pool.Exec(ctx, query,
+		d.UserID, d.TenantID, d.Enabled, d.StartTime, d.EndTime, d.Timezone, d.UpdatedAt)
+	return err
+}
+
+// SavePushSubscription stores a push subscription. A browser has one
+// subscription per endpoint, so re-registering an endpoint replaces its keys
+// and moves it to the registering user.
+func (r *NotificationRepository) SavePushSubscription(ctx context.Context, sub *models.PushSubscription) error {
+	query := `
+		INSERT INTO push_subscriptions (id, tenant_id, user_id, endpoint, p256dh, auth, user_agent, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (endpoint) DO UPDATE SET
+			tenant_id = EXCLUDED.tenant_id, user_id = EXCLUDED.user_id,
+			p256dh = EXCLUDED.p256dh, auth = EXCLUDED.auth, user_agent = EXCLUDED.user_agent
+		RETURNING id, created_at
+	`
+	return r.db.pool.QueryRow(ctx, query,
+		sub.ID, sub.TenantID, sub.UserID, sub.Endpoint, sub.P256dh, sub.Auth, sub.UserAgent, sub.CreatedAt,
+	).Scan(&sub.ID, &sub.CreatedAt)
+}
+
+// ListPushSubscriptions returns a user's push subscriptions, or those of every
+// user of the tenant when userID is nil
+func (r *NotificationRepository) ListPushSubscriptions(ctx context.Context, tenantID uuid.UUID, userID *uuid.UUID) ([]*models.PushSubscription, error) {
+	query := `SELECT id, tenant_id, user_id, endpoint, p256dh, auth, COALESCE(user_agent, ''), created_at
+			  FROM push_subscriptions WHERE tenant_id = $1`
+	args := []interface{}{tenantID}
+	if userID != nil {
+		args = append(args, *userID)
+		query += fmt.Sprintf(" AND user_id = $%d", len(args))
+	}
+
+	rows, err := r.db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []*models.PushSubscription
+	for rows.Next() {
+		var sub models.PushSubscription
+		if err := rows.Scan(&sub.ID, &sub.TenantID, &sub.UserID, &sub.Endpoint, &sub.P256dh, &sub.Auth,
+			&sub.UserAgent, &sub.CreatedAt); err != nil {
+			return nil, err
+		}
+		subs = append(subs, &sub)
+	}
+	return subs, rows.Err()
+}
+
+// DeletePushSubscription removes the subscription for an endpoint
+func (r *NotificationRepository) DeletePushSubscription(ctx context.Context, endpoint string) error {
+	query := `DELETE FROM push_subscriptions WHERE endpoint = $1`
+	_, err := r.db.pool.Exec(ctx, query, endpoint)
+	return err
+}
+
+// DeleteUserPushSubscription removes a user's subscription for an endpoint,
+// reporting whether there was one
+func (r *NotificationRepository) DeleteUserPushSubscription(ctx context.Context, userID uuid.UUID, endpoint string) (bool, error) {
+	query := `DELETE FROM push_subscriptions WHERE user_id = $1 AND endpoint = $2`
+	tag, err := r.db.pool.Exec(ctx, query, userID, endpoint)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// =============================================================================
+// API Usage Repository
+// =============================================================================
+
+type APIUsageRepository struct {
+	db *PostgresDB
+}
+
+// UpsertRollups adds the given counts to the stored hourly rollups
+func (r *APIUsageRepository) UpsertRollups(ctx context.Context, rollups []*models.APIUsageRollup) error {
+	query := `
+		INSERT INTO api_usage_rollups (tenant_id, bucket, method, route, status,
+									   request_count, total_duration_ms, max_duration_ms)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (tenant_id, bucket, method, route, status)
+		DO UPDATE SET request_count = api_usage_rollups.request_count + EXCLUDED.request_count,
+					  total_duration_ms = api_usage_rollups.total_duration_ms + EXCLUDED.total_duration_ms,
+					  max_duration_ms = GREATEST(api_usage_rollups.max_duration_ms, EXCLUDED.max_duration_ms)
+	`
+	batch := &pgx.Batch{}
+	for _, rollup := range rollups {
+		batch.Queue(query,
+			rollup.TenantID, rollup.Bucket, rollup.Method, rollup.Route, rollup.Status,
+			rollup.RequestCount, rollup.TotalDurationMs, rollup.MaxDurationMs)
+	}
+	return r.db.pool.SendBatch(ctx, batch).Close()
+}
+
+// ListRollups returns a tenant's rollups with buckets in [since, until)
+func (r *APIUsageRepository) ListRollups(ctx context.Context, tenantID uuid.UUID, since, until time.Time) ([]*models.APIUsageRollup, error) {
+	query := `SELECT tenant_id, bucket, method, route, status, request_count, total_duration_ms, max_duration_ms
+			  FROM api_usage_rollups WHERE tenant_id = $1 AND bucket >= $2 AND bucket < $3
+			  ORDER BY bucket`
+	rows, err := r.db.pool.Query(ctx, query, tenantID, since, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rollups []*models.APIUsageRollup
+	for rows.Next() {
+		var rollup models.APIUsageRollup
+		if err := rows.Scan(
+			&rollup.TenantID, &rollup.Bucket, &rollup.Method, &rollup.Route, &rollup.Status,
+			&rollup.RequestCount, &rollup.TotalDurationMs, &rollup.MaxDurationMs); err != nil {
+			return nil, err
+		}
+		rollups = append(rollups, &rollup)
+	}
+	return rollups, rows.Err()
+}
+
+// Health check for repositories
+func (r *Repositories) Ping(ctx context.Context) error {
+	return r.db.Ping(ctx)
+}
+
+// DB returns the database the repositories use, for stores outside this package
+func (r *Repositories) DB() *PostgresDB {
+	return r.db
+}
+
+// Helper function to generate error messages
+func ErrNotFound(entity string, id interface{}) error {
+	return fmt.Errorf("%s not found: %v", entity, id)
+}
+
+// =============================================================================
+// Financial Repository
+// =============================================================================
+
+type FinancialRepository struct {
+	db *PostgresDB
+}
+
+// CreateAccount saves a financial account
+func (r *FinancialRepository) CreateAccount(ctx context.Context, a *models.FinancialAccount) error {
+	query := `
+		INSERT INTO financial_accounts (id, business_id, name, type, currency, balance, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err := r.db.pool.Exec(ctx, query,
+		a.ID, a.BusinessID, a.Name, a.Type, a.Currency, a.Balance, a.CreatedAt)
+	return err
+}
+
+// GetAccount returns a financial account, or nil if it does not exist
+func (r *FinancialRepository) GetAccount(ctx context.Context, id uuid.UUID) (*models.FinancialAccount, error) {
+	query := `
+		SELECT id, business_id, name, type, currency, balance, created_at
+		FROM financial_accounts WHERE id = $1
+	`
+	var a models.FinancialAccount
+	err := r.db.pool.QueryRow(ctx, query, id).Scan(
+		&a.ID, &a.BusinessID, &a.Name, &a.Type, &a.Currency, &a.Balance, &a.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// ListAccounts returns a business's financial accounts, by name
+func (r *FinancialRepository) ListAccounts(ctx context.Context, businessID uuid.UUID) ([]*models.FinancialAccount, error) {
+	query := `
+		SELECT id, business_id, name, type, currency, balance, created_at
+		FROM financial_accounts WHERE business_id = $1
+		ORDER BY name, id
+	`
+	rows, err := r.db.pool.Query(ctx, query, businessID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []*models.FinancialAccount
+	for rows.Next() {
+		var a models.FinancialAccount
+		if err := rows.Scan(&a.ID, &a.BusinessID, &a.Name, &a.Type, &a.Currency, &a.Balance, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, &a)
+	}
+	return accounts, rows.Err()
+}
+
+// CreateTransaction saves a transaction and adds its amount to its account's
+// balance in one database transaction, returning the new balance. The
+// account row is locked by the update, so concurrent transactions on an
+// account cannot lose each other's amounts.
+func (r *FinancialRepository) CreateTransaction(ctx context.Context, t *models.Transaction) (float64, error) {
+	tx, err := r.db.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO transactions (id, account_id, amount, category, description, date, metadata, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6, $7, $8)
+	`
+	if _, err := tx.Exec(ctx, query,
+		t.ID, t.AccountID, t.Amount, t.Category, t.Description, t.Date, t.Metadata, t.CreatedAt); err != nil {
+		return 0, err
+	}
+
+	var balance float64
+	query = `UPDATE financial_accounts SET balance = balance + $2 WHERE id = $1 RETURNING balance`
+	if err := tx.QueryRow(ctx, query, t.AccountID, t.Amount).Scan(&balance); err != nil {
+		return 0, err
+	}
+	return balance, tx.Commit(ctx)
+}
+
+// TransactionFilter narrows a business's transactions. From and To are
+// inclusive dates; a zero Limit returns every match.
+type TransactionFilter struct {
+	AccountID *uuid.UUID
+	Category  string
+	From      *time.Time
+	To        *time.Time
+	Limit     int
+}
+
+// ListTransactions returns the transactions on a business's accounts that
+// match the filter, newest first
+func (r *FinancialRepository) ListTransactions(ctx context.Context, businessID uuid.UUID, filter TransactionFilter) ([]*models.Transaction, error) {
+	query := `
+		SELECT t.id, t.account_id, t.amount, COALESCE(t.category, ''), COALESCE(t.description, ''),
+			t.date, t.metadata, t.created_at
+		FROM transactions t
+		JOIN financial_accounts a ON a.id = t.account_id
+		WHERE a.business_id = $1
+	`
+	args := []interface{}{businessID}
+	if filter.AccountID != nil {
+		args = append(args, *filter.AccountID)
+		query += fmt.Sprintf(" AND t.account_id = $%d", len(args))
+	}
+	if filter.Category != "" {
+		args = append(args, filter.Category)
+		query += fmt.Sprintf(" AND t.category = $%d", len(args))
+	}
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		query += fmt.Sprintf(" AND t.date >= $%d", len(args))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		query += fmt.Sprintf(" AND t.date <= $%d", len(args))
+	}
+	query += " ORDER BY t.date DESC, t.created_at DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := r.db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []*models.Transaction
+	for rows.Next() {
+		var t models.Transaction
+		if err := rows.Scan(&t.ID, &t.AccountID, &t.Amount, &t.Category, &t.Description,
+			&t.Date, &t.Metadata, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, &t)
+	}
+	return transactions, rows.Err()
+}
+
+// CreateBudget saves a budget
+func (r *FinancialRepository) CreateBudget(ctx context.Context, b *models.Budget) error {
+	query := `
+		INSERT INTO budgets (id, business_id, category, amount, period, start_date, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err := r.db.pool.Exec(ctx, query,
+		b.ID, b.BusinessID, b.Category, b.Amount, b.Period, b.StartDate, b.CreatedAt)
+	return err
+}
+
+// ListBudgets returns a business's budgets, by category and start date
+func (r *FinancialRepository) ListBudgets(ctx context.Context, businessID uuid.UUID) ([]*models.Budget, error) {
+	query := `
+		SELECT id, business_id, category, amount, period, start_date, created_at
+		FROM budgets WHERE business_id = $1
+		ORDER BY category, start_date DESC
+	`
+	rows, err := r.db.pool.Query(ctx, query, businessID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var budgets []*models.Budget
+	for rows.Next() {
+		var b models.Budget
+		if err := rows.Scan(&b.ID, &b.BusinessID, &b.Category, &b.Amount, &b.Period, &b.StartDate, &b.CreatedAt); err != nil {
+			return nil, err
+		}
+		budgets = append(budgets, &b)
+	}
+	return budgets, rows.Err()
+}
+
+// =============================================================================
+// Billing Event Repository
+// =============================================================================
+
+type BillingEventRepository struct {
+	db *PostgresDB
+}
+
+// MarkProcessed records a billing event as processed. It returns false when the
+// event was already recorded, so the caller should skip it.
+func (r *BillingEventRepository) MarkProcessed(ctx context.Context, eventID, eventType string) (bool, error) {
+	query := `
+		INSERT INTO billing_events (id, type, processed_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO NOTHING
+	`
+	tag, err := r.db.pool.Exec(ctx, query, eventID, eventType, time.Now())
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// Unmark forgets a billing event so a failed event is processed again on redelivery
+func (r *BillingEventRepository) Unmark(ctx context.Context, eventID string) error {
+	_, err := r.db.pool.Exec(ctx, `DELETE FROM billing_events WHERE id = $1`, eventID)
+	return err
+}
+
+// =============================================================================
+// Scheduled Execution Repository
+// =============================================================================
+
+// schedulerLockKey is the advisory lock held while firing due schedules, so
+// only one instance fires them at a time
+const schedulerLockKey = 0x64656c7068690001
+
+type ScheduledExecutionRepository struct {
+	db *PostgresDB
+}
+
+const scheduleColumns = `id, tenant_id, agent_id, cron_expr, timezone, prompt, enabled,
+			next_run_at, last_run_at, last_run_id, created_at, updated_at`
+
+func scanSchedule(row pgx.Row) (*models.ScheduledExecution, error) {
+	var s models.ScheduledExecution
+	err := row.Scan(&s.ID, &s.TenantID, &s.AgentID, &s.CronExpr, &s.Timezone, &s.Prompt, &s.Enabled,
+		&s.NextRunAt, &s.LastRunAt, &s.LastRunID, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *ScheduledExecutionRepository) Create(ctx context.Context, s *models.ScheduledExecution) error {
+	query := `
+		INSERT INTO scheduled_executions (id, tenant_id, agent_id, cron_expr, timezone, prompt, enabled,
+										  next_run_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+	_, err := r.db.pool.Exec(ctx, query,
+		s.ID, s.TenantID, s.AgentID, s.CronExpr, s.Timezone, s.Prompt, s.Enabled,
+		s.NextRunAt, s.CreatedAt, s.UpdatedAt)
+	return err
+}
+
+func (r *ScheduledExecutionRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.ScheduledExecution, error) {
+	query := `SELECT ` + scheduleColumns + ` FROM scheduled_executions WHERE id = $1`
+	s, err := scanSchedule(r.db.pool.QueryRow(ctx, query, id))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return s, err
+}
+
+// ListByAgent returns an agent's schedules, oldest first
+func (r *ScheduledExecutionRepository) ListByAgent(ctx context.Context, agentID uuid.UUID) ([]*models.ScheduledExecution, error) {
+	query := `SELECT ` + scheduleColumns + ` FROM scheduled_executions WHERE agent_id = $1 ORDER BY created_at`
+	return r.list(ctx, query, agentID)
+}
+
+// ListDue returns enabled schedules whose next run is at or before now, most overdue first
+func (r *ScheduledExecutionRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]*models.ScheduledExecution, error) {
+	query := `SELECT ` + scheduleColumns + ` FROM scheduled_executions
+			  WHERE enabled AND next_run_at <= $1 ORDER BY next_run_at LIMIT $2`
+	return r.list(ctx, query, now, limit)
+}
+
+func (r *ScheduledExecutionRepository) list(ctx context.Context, query string, args ...interface{}) ([]*models.ScheduledExecution, error) {
+	rows, err := r.db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []*models.ScheduledExecution
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, s)
+	}
+	return schedules, rows.Err()
+}
+
+// Update saves a schedule's definition and next run time
+func (r *ScheduledExecutionRepository) Update(ctx context.Context, s *models.ScheduledExecution) error {
+	query := `
+		UPDATE scheduled_executions
+		SET cron_expr = $2, timezone = $3, prompt = $4, enabled = $5, next_run_at = $6
+		WHERE id = $1
+	`
+	_, err := r.db.pool.Exec(ctx, query, s.ID, s.CronExpr, s.Timezone, s.Prompt, s.Enabled, s.NextRunAt)
+	return err
+}
+
+// Advance moves a schedule from its due run at prev to next. It reports false
+// if the schedule was changed or already advanced since it was read.
+func (r *ScheduledExecutionRepository) Advance(ctx context.Context, id uuid.UUID, prev, next time.Time) (bool, error) {
+	query := `
+		UPDATE scheduled_executions SET next_run_at = $3, last_run_at = NOW()
+		WHERE id = $1 AND enabled AND next_run_at = $2
+	`
+	tag, err := r.db.pool.Exec(ctx, query, id, prev, next)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// SetLastRun records the run a schedule last started
+func (r *ScheduledExecutionRepository) SetLastRun(ctx context.Context, id, runID uuid.UUID) error {
+	query := `UPDATE scheduled_executions SET last_run_id = $2 WHERE id = $1`
+	_, err := r.db.pool.Exec(ctx, query, id, runID)
+	return err
+}
+
+func (r *ScheduledExecutionRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM scheduled_executions WHERE id = $1`
+	_, err := r.db.pool.Exec(ctx, query, id)
+	return err
+}
+
+// WithSchedulerLock runs fn while holding the scheduler's advisory lock. It
+// returns false without running fn when another instance holds the lock.
+func (r *ScheduledExecutionRepository) WithSchedulerLock(ctx context.Context, fn func(ctx context.Context) error) (bool, error) {
+	return withAdvisoryLock(ctx, r.db, schedulerLockKey, fn)
+}
+
+// withAdvisoryLock runs fn while holding the session advisory lock key. It
+// returns false without running fn when another session holds the lock.
+func withAdvisoryLock(ctx context.Context, db *PostgresDB, key int64, fn func(ctx context.Context) error) (bool, error) {
+	// Session locks belong to a connection, so hold one for the duration
+	conn, err := db.pool.Acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Release()
+
+	var acquired bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&acquired); err != nil {
+		return false, fmt.Errorf("failed to acquire advisory lock: %w", err)
+	}
+	if !acquired {
+		return false, nil
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, key)
+
+	return true, fn(ctx)
+}
+
+// =============================================================================
+// Social Repository
+// =============================================================================
+
+type SocialRepository struct {
+	db *PostgresDB
+}
+
+const socialAccountColumns = `id, tenant_id, platform, account_id, COALESCE(account_name, ''), COALESCE(credentials, ''),
+			   token_expires_at, metadata, created_at`
+
+func scanSocialAccount(row pgx.Row) (*models.SocialAccount, error) {
+	var a models.SocialAccount
+	err := row.Scan(
+		&a.ID, &a.TenantID, &a.Platform, &a.AccountID, &a.AccountName, &a.Credentials,
+		&a.TokenExpiresAt, &a.Metadata, &a.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *SocialRepository) GetAccount(ctx context.Context, id uuid.UUID) (*models.SocialAccount, error) {
+	query := `SELECT ` + socialAccountColumns + ` FROM social_accounts WHERE id = $1`
+	return scanSocialAccount(r.db.pool.QueryRow(ctx, query, id))
+}
+
+// RefreshAccount passes an account to refresh with its row locked, saving its
+// credentials and token expiry if refresh reports they changed. Refreshes of
+// the same account on different instances take turns, so one that waited sees
+// the tokens saved before it. It returns nil if there is no such account.
+func (r *SocialRepository) RefreshAccount(ctx context.Context, id uuid.UUID, refresh func(account *models.SocialAccount) (bool, error)) (*models.SocialAccount, error) {
+	tx, err := r.db.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	account, err := scanSocialAccount(tx.QueryRow(ctx, `SELECT `+socialAccountColumns+` FROM social_accounts WHERE id = $1 FOR UPDATE`, id))
+	if err != nil || account == nil {
+		return nil, err
+	}
+
+	changed, err := refresh(account)
+	if err != nil {
+		return nil, err
+	}
+	if changed {
+		query := `UPDATE social_accounts SET credentials = $2, token_expires_at = $3 WHERE id = $1`
+		if _, err := tx.Exec(ctx, query, id, account.Credentials, account.TokenExpiresAt); err != nil {
+			return nil, err
+		}
+	}
+	return account, tx.Commit(ctx)
+}
+
+const socialPostColumns = `id, account_id, content, media_urls, status, scheduled_at, published_at,
+			COALESCE(external_id, ''), attempts, COALESCE(last_error, ''), analytics, created_at`
+
+func scanSocialPost(row pgx.Row) (*models.SocialPost, error) {
+	var p models.SocialPost
+	var mediaJSON []byte
+	err := row.Scan(&p.ID, &p.AccountID, &p.Content, &mediaJSON, &p.Status, &p.ScheduledAt, &p.PublishedAt,
+		&p.ExternalID, &p.Attempts, &p.LastError, &p.Analytics, &p.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	json.Unmarshal(mediaJSON, &p.MediaURLs)
+	return &p, nil
+}
+
+const insertSocialPost = `
+		INSERT INTO social_posts (id, account_id, content, media_urls, status, scheduled_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+func (r *SocialRepository) CreatePost(ctx context.Context, post *models.SocialPost) error {
+	mediaJSON, _ := json.Marshal(post.MediaURLs)
+	_, err := r.db.pool.Exec(ctx, insertSocialPost,
+		post.ID, post.AccountID, post.Content, mediaJSON, post.Status, post.ScheduledAt, post.CreatedAt)
+	return err
+}
+
+func (r *SocialRepository) GetPost(ctx context.Context, id uuid.UUID) (*models.SocialPost, error) {
+	query := `SELECT ` + socialPostColumns + ` FROM social_posts WHERE id = $1`
+	p, err := scanSocialPost(r.db.pool.QueryRow(ctx, query, id))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return p, err
+}
+
+// ClaimDuePosts moves up to limit scheduled posts that are due to publishing
+// and returns them, oldest first. Rows locked by another worker are skipped,
+// so each post is claimed by exactly one worker.
+func (r *SocialRepository) ClaimDuePosts(ctx context.Context, now time.Time, limit int) ([]*models.SocialPost, error) {
+	query := `
+		UPDATE social_posts SET status = 'publishing', claimed_at = NOW(), attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM social_posts
+			WHERE status = 'scheduled' AND scheduled_at <= $1
+			ORDER BY scheduled_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + socialPostColumns
+	rows, err := r.db.pool.Query(ctx, query, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []*models.SocialPost
+	for rows.Next() {
+		p, err := scanSocialPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+	return posts, rows.Err()
+}
+
+// MarkPublished records a post as published on the platform. It applies even
+// if the post's claim has since been given up as stale, since the platform
+// post exists either way.
+func (r *SocialRepository) MarkPublished(ctx context.Context, id uuid.UUID, externalID string, publishedAt time.Time) error {
+	query := `
+		UPDATE social_posts SET status = 'published', external_id = $2, published_at = $3, last_error = NULL
+		WHERE id = $1
+	`
+	_, err := r.db.pool.Exec(ctx, query, id, externalID, publishedAt)
+	return err
+}
+
+// RetryPost returns a claimed post to the schedule, to be published again at retryAt
+func (r *SocialRepository) RetryPost(ctx context.Context, id uuid.UUID, lastError string, retryAt time.Time) error {
+	query := `
+		UPDATE social_posts SET status = 'scheduled', scheduled_at = $3, last_error = $2
+		WHERE id = $1 AND status = 'publishing'
+	`
+	_, err := r.db.pool.Exec(ctx, query, id, lastError, retryAt)
+	return err
+}
+
+// FailPost marks a claimed post as failed for good
+func (r *SocialRepository) FailPost(ctx context.Context, id uuid.UUID, lastError string) error {
+	query := `UPDATE social_posts SET status = 'failed', last_error = $2 WHERE id = $1 AND status = 'publishing'`
+	_, err := r.db.pool.Exec(ctx, query, id, lastError)
+	return err
+}
+
+// FailStalePosts marks posts claimed before the given time as failed. Their
+// worker stopped mid-publish, so whether they reached the platform is unknown
+// and they are not retried automatically.
+func (r *SocialRepository) FailStalePosts(ctx context.Context, claimedBefore time.Time) (int64, error) {
+	query := `
+		UPDATE social_posts SET status = 'failed', last_error = 'publishing was interrupted; check the platform before rescheduling'
+		WHERE status = 'publishing' AND claimed_at < $1
+	`
+	tag, err := r.db.pool.Exec(ctx, query, claimedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+const contentPipelineColumns = `id, tenant_id, business_id, name, COALESCE(description, ''), agent_id, schedule,
+			platforms, accounts, config, is_active, last_run_at, next_run_at, created_at, updated_at`
+
+func scanContentPipeline(row pgx.Row) (*models.ContentPipeline, error) {
+	var p models.ContentPipeline
+	var platformsJSON, accountsJSON []byte
+	err := row.Scan(&p.ID, &p.TenantID, &p.BusinessID, &p.Name, &p.Description, &p.AgentID, &p.Schedule,
+		&platformsJSON, &accountsJSON, &p.Config, &p.IsActive, &p.LastRunAt, &p.NextRunAt, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	json.Unmarshal(platformsJSON, &p.Platforms)
+	json.Unmarshal(accountsJSON, &p.Accounts)
+	return &p, nil
+}
+
+// CreatePipeline saves a content pipeline
+func (r *SocialRepository) CreatePipeline(ctx context.Context, pipeline *models.ContentPipeline) error {
+	platformsJSON, _ := json.Marshal(pipeline.Platforms)
+	accountsJSON, _ := json.Marshal(pipeline.Accounts)
+	query := `
+		INSERT INTO content_pipelines (id, tenant_id, business_id, name, description, agent_id, schedule,
+			platforms, accounts, config, is_active, next_run_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+	`
+	_, err := r.db.pool.Exec(ctx, query,
+		pipeline.ID, pipeline.TenantID, pipeline.BusinessID, pipeline.Name, pipeline.Description, pipeline.AgentID,
+		pipeline.Schedule, platformsJSON, accountsJSON, pipeline.Config, pipeline.IsActive, pipeline.NextRunAt,
+		pipeline.CreatedAt, pipeline.UpdatedAt)
+	return err
+}
+
+// ClaimDuePipelines claims active pipelines whose next run is due at now by
+// moving their next run to retryAt, so no other worker runs them meanwhile.
+// A claimed pipeline whose run is never saved becomes due again at retryAt.
+func (r *SocialRepository) ClaimDuePipelines(ctx context.Context, now, retryAt time.Time, limit int) ([]*models.ContentPipeline, error) {
+	query := `
+		UPDATE content_pipelines SET next_run_at = $2
+		WHERE id IN (
+			SELECT id FROM content_pipelines
+			WHERE is_active AND next_run_at <= $1
+			ORDER BY next_run_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + contentPipelineColumns
+	rows, err := r.db.pool.Query(ctx, query, now, retryAt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pipelines []*models.ContentPipeline
+	for rows.Next() {
+		p, err := scanContentPipeline(rows)
+		if err != nil {
+			return nil, err
+		}
+		pipelines = append(pipelines, p)
+	}
+	return pipelines, rows.Err()
+}
+
+// SavePipelineRun saves the posts a pipeline run generated together with the
+// pipeline's last and next run times, so a failed save leaves neither
+func (r *SocialRepository) SavePipelineRun(ctx context.Context, pipelineID uuid.UUID, lastRunAt time.Time, nextRunAt *time.Time, posts []*models.SocialPost) error {
+	tx, err := r.db.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, post := range posts {
+		mediaJSON, _ := json.Marshal(post.MediaURLs)
+		if _, err := tx.Exec(ctx, insertSocialPost,
+			post.ID, post.AccountID, post.Content, mediaJSON, post.Status, post.ScheduledAt, post.CreatedAt); err != nil {
+			return err
+		}
+	}
+
+	query := `UPDATE content_pipelines SET last_run_at = $2, next_run_at = $3 WHERE id = $1`
+	if _, err := tx.Exec(ctx, query, pipelineID, lastRunAt, nextRunAt); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// =============================================================================
+// Webhook Repository
+// =============================================================================
+
+type WebhookRepository struct {
+	db *PostgresDB
+}
+
+const webhookColumns = `id, tenant_id, url, events, secret, enabled, consecutive_failures, disabled_at,
+			created_at, updated_at`
+
+func scanWebhook(row pgx.Row) (*models.Webhook, error) {
+	var w models.Webhook
+	err := row.Scan(&w.ID, &w.TenantID, &w.URL, &w.Events, &w.Secret, &w.Enabled, &w.ConsecutiveFailures,
+		&w.DisabledAt, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (r *WebhookRepository) Create(ctx context.Context, w *models.Webhook) error {
+	query := `
+		INSERT INTO webhooks (id, tenant_id, url, events, secret, enabled, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	_, err := r.db.pool.Exec(ctx, query,
+		w.ID, w.TenantID, w.URL, w.Events, w.Secret, w.Enabled, w.CreatedAt, w.UpdatedAt)
+	return err
+}
+
+func (r *WebhookRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = $1`
+	w, err := scanWebhook(r.db.pool.QueryRow(ctx, query, id))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return w, err
+}
+
+// ListByTenant returns a tenant's webhooks, oldest first
+func (r *WebhookRepository) ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]*models.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE tenant_id = $1 ORDER BY created_at`
+	return r.list(ctx, query, tenantID)
+}
+
+// ListSubscribed returns a tenant's enabled webhooks that subscribe to an event
+func (r *WebhookRepository) ListSubscribed(ctx context.Context, tenantID uuid.UUID, event string) ([]*models.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE tenant_id = $1 AND enabled AND $2 = ANY(events)`
+	return r.list(ctx, query, tenantID, event)
+}
+
+func (r *WebhookRepository) list(ctx context.Context, query string, args ...interface{}) ([]*models.Webhook, error) {
+	rows, err := r.db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []*models.Webhook
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+	return webhooks, rows.Err()
+}
+
+// Update saves a webhook's definition and failure state
+func (r *WebhookRepository) Update(ctx context.Context, w *models.Webhook) error {
+	query := `
+		UPDATE webhooks
+		SET url = $2, events = $3, secret = $4, enabled = $5, consecutive_failures = $6, disabled_at = $7
+		WHERE id = $1
+	`
+	_, err := r.db.pool.Exec(ctx, query,
+		w.ID, w.URL, w.Events, w.Secret, w.Enabled, w.ConsecutiveFailures, w.DisabledAt)
+	return err
+}
+
+func (r *WebhookRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM webhooks WHERE id = $1`
+	_, err := r.db.pool.Exec(ctx, query, id)
+	return err
+}
+
+const webhookDeliveryColumns = `id, webhook_id, event, payload, status, attempts, response_status,
+			COALESCE(error, ''), next_attempt_at, delivered_at, created_at`
+
+func scanWebhookDelivery(row pgx.Row) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	err := row.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.ResponseStatus,
+		&d.Error, &d.NextAttemptAt, &d.DeliveredAt, &d.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// CreateDeliveries queues deliveries in a single round trip
+func (r *WebhookRepository) CreateDeliveries(ctx context.Context, deliveries []*models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO webhook_deliveries (id, webhook_id, event, payload, status, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	batch := &pgx.Batch{}
+	for _, d := range deliveries {
+		batch.Queue(query, d.ID, d.WebhookID, d.Event, d.Payload, d.Status, d.NextAttemptAt, d.CreatedAt)
+	}
+	return r.db.pool.SendBatch(ctx, batch).Close()
+}
+
+// ListDeliveries returns a webhook's most recent deliveries, newest first
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]*models.WebhookDelivery, error) {
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries
+			  WHERE webhook_id = $1 ORDER BY created_at DESC LIMIT $2`
+	return r.listDeliveries(ctx, query, webhookID, limit)
+}
+
+// ClaimDueDeliveries moves up to limit due deliveries of enabled webhooks to
+// delivering and returns them, oldest first. Rows locked by another worker
+// are skipped, so each attempt is made by exactly one worker.
+func (r *WebhookRepository) ClaimDueDeliveries(ctx context.Context, now time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	query := `
+		UPDATE webhook_deliveries SET status = 'delivering', claimed_at = NOW(), attempts = attempts + 1
+		WHERE id IN (
+			SELECT d.id FROM webhook_deliveries d
+			JOIN webhooks w ON w.id = d.webhook_id
+			WHERE d.status = 'pending' AND d.next_attempt_at <= $1 AND w.enabled
+			ORDER BY d.next_attempt_at
+			LIMIT $2
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		RETURNING ` + webhookDeliveryColumns
+	return r.listDeliveries(ctx, query, now, limit)
+}
+
+func (r *WebhookRepository) listDeliveries(ctx context.Context, query string, args ...interface{}) ([]*models.WebhookDelivery, error) {
+	rows, err := r.db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// ReleaseStaleDeliveries returns deliveries claimed before the given time to
+// pending. Their worker stopped mid-attempt; receivers deduplicate on the
+// delivery ID, so they are simply attempted again.
+func (r *WebhookRepository) ReleaseStaleDeliveries(ctx context.Context, claimedBefore time.Time) (int64, error) {
+	query := `
+		UPDATE webhook_deliveries SET status = 'pending', next_attempt_at = NOW()
+		WHERE status = 'delivering' AND claimed_at < $1
+	`
+	tag, err := r.db.pool.Exec(ctx, query, claimedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// MarkDelivered records a successful attempt and resets the webhook's
+// consecutive failures
+func (r *WebhookRepository) MarkDelivered(ctx context.Context, d *models.WebhookDelivery) error {
+	tx, err := r.db.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		UPDATE webhook_deliveries SET status = 'delivered', response_status = $2, error = NULL, delivered_at = $3
+		WHERE id = $1
+	`
+	if _, err := tx.Exec(ctx, query, d.ID, d.ResponseStatus, d.DeliveredAt); err != nil {
+		return err
+	}
+
+	query = `UPDATE webhooks SET consecutive_failures = 0 WHERE id = $1 AND consecutive_failures > 0`
+	if _, err := tx.Exec(ctx, query, d.WebhookID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// RecordFailedAttempt records a failed attempt, returning the delivery to
+// pending until retryAt or, with no retryAt, failing it for good. It counts
+// the failure against the webhook and disables the webhook once disableAfter
+// attempts in a row have failed, failing its pending deliveries; it reports
+// whether this attempt disabled the webhook.
+func (r *WebhookRepository) RecordFailedAttempt(ctx context.Context, d *models.WebhookDelivery, retryAt *time.Time, disableAfter int) (bool, error) {
+	tx, err := r.db.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	status, nextAttemptAt := "failed", d.NextAttemptAt
+	if retryAt != nil {
+		status, nextAttemptAt = "pending", *retryAt
+	}
+	query := `
+		UPDATE webhook_deliveries SET status = $2, response_status = $3, error = $4, next_attempt_at = $5
+		WHERE id = $1 AND status = 'delivering'
+	`
+	if _, err := tx.Exec(ctx, query, d.ID, status, d.ResponseStatus, d.Error, nextAttemptAt); err != nil {
+		return false, err
+	}
+
+	var failures int
+	var enabled bool
+	query = `SELECT consecutive_failures, enabled FROM webhooks WHERE id = $1 FOR UPDATE`
+	if err := tx.QueryRow(ctx, query, d.WebhookID).Scan(&failures, &enabled); err != nil {
+		return false, err
+	}
+	failures++
+	disable := enabled && disableAfter > 0 && failures >= disableAfter
+
+	query = `
+		UPDATE webhooks SET consecutive_failures = $2,
+			enabled = enabled AND NOT $3,
+			disabled_at = CASE WHEN $3 THEN NOW() ELSE disabled_at END
+		WHERE id = $1
+	`
+	if _, err := tx.Exec(ctx, query, d.WebhookID, failures, disable); err != nil {
+		return false, err
+	}
+
+	if disable {
+		query = `
+			UPDATE webhook_deliveries SET status = 'failed', error = 'webhook disabled after repeated failures'
+			WHERE webhook_id = $1 AND status = 'pending'
+		`
+		if _, err := tx.Exec(ctx, query, d.WebhookID); err != nil {
+			return false, err
+		}
+	}
+
+	return disable, tx.Commit(ctx)
+}
+
+// =============================================================================
+// Digest Repository
+// =============================================================================
+
+// digestLockKey is the advisory lock held while sending due weekly digests,
+// so only one instance sends them at a time
+const digestLockKey = 0x64656c7068690002
+
+type DigestRepository struct {
+	db *PostgresDB
+}
+
+const digestScheduleColumns = `tenant_id, enabled, day_of_week, to_char(time_of_day, 'HH24:MI'), timezone,
+			next_run_at, last_sent_at, created_at, updated_at`
+
+func scanDigestSchedule(row pgx.Row) (*models.DigestSchedule, error) {
+	var d models.DigestSchedule
+	err := row.Scan(&d.TenantID, &d.Enabled, &d.DayOfWeek, &d.TimeOfDay, &d.Timezone,
+		&d.NextRunAt, &d.LastSentAt, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// SeedSchedules gives every tenant without a digest schedule the default one
+func (r *DigestRepository) SeedSchedules(ctx context.Context) (int64, error) {
+	query := `
+		INSERT INTO digest_schedules (tenant_id)
+		SELECT id FROM tenants t
+		WHERE NOT EXISTS (SELECT 1 FROM digest_schedules d WHERE d.tenant_id = t.id)
+		ON CONFLICT (tenant_id) DO NOTHING
+	`
+	tag, err := r.db.pool.Exec(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// GetSchedule returns a tenant's digest schedule, or nil if it has none yet
+func (r *DigestRepository) GetSchedule(ctx context.Context, tenantID uuid.UUID) (*models.DigestSchedule, error) {
+	query := `SELECT ` + digestScheduleColumns + ` FROM digest_schedules WHERE tenant_id = $1`
+	d, err := scanDigestSchedule(r.db.pool.QueryRow(ctx, query, tenantID))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return d, err
+}
+
+// SaveSchedule creates or replaces a tenant's digest schedule
+func (r *DigestRepository) SaveSchedule(ctx context.Context, d *models.DigestSchedule) error {
+	query := `
+		INSERT INTO digest_schedules (tenant_id, enabled, day_of_week, time_of_day, timezone, next_run_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4::time, $5, $6, $7, $8)
+		ON CONFLICT (tenant_id) DO UPDATE SET
+			enabled = EXCLUDED.enabled, day_of_week = EXCLUDED.day_of_week, time_of_day = EXCLUDED.time_of_day,
+			timezone = EXCLUDED.timezone, next_run_at = EXCLUDED.next_run_at
+	`
+	_, err := r.db.pool.Exec(ctx, query,
+		d.TenantID, d.Enabled, d.DayOfWeek, d.TimeOfDay, d.Timezone, d.NextRunAt, d.CreatedAt, d.UpdatedAt)
+	return err
+}
+
+// ListDue returns enabled schedules that are due at now or have not been
+// scheduled yet, most overdue first
+func (r *DigestRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]*models.DigestSchedule, error) {
+	query := `SELECT ` + digestScheduleColumns + ` FROM digest_schedules
+			  WHERE enabled AND (next_run_at IS NULL OR next_run_at <= $1)
+			  ORDER BY next_run_at NULLS FIRST LIMIT $2`
+	rows, err := r.db.pool.Query(ctx, query, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []*models.DigestSchedule
+	for rows.Next() {
+		d, err := scanDigestSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, d)
+	}
+	return schedules, rows.Err()
+}
+
+// Advance moves a schedule from prev, its due time or nil if it was never
+// scheduled, to next. It reports false if the schedule was changed or already
+// advanced since it was read.
+func (r *DigestRepository) Advance(ctx context.Context, tenantID uuid.UUID, prev *time.Time, next time.Time) (bool, error) {
+	query := `
+		UPDATE digest_schedules SET next_run_at = $3
+		WHERE tenant_id = $1 AND enabled AND next_run_at IS NOT DISTINCT FROM $2
+	`
+	tag, err := r.db.pool.Exec(ctx, query, tenantID, prev, next)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// MarkSent records when a tenant's digest was last sent
+func (r *DigestRepository) MarkSent(ctx context.Context, tenantID uuid.UUID, sentAt time.Time) error {
+	query := `UPDATE digest_schedules SET last_sent_at = $2 WHERE tenant_id = $1`
+	_, err := r.db.pool.Exec(ctx, query, tenantID, sentAt)
+	return err
+}
+
+// WithDigestLock runs fn while holding the digest sender's advisory lock. It
+// returns false without running fn when another instance holds the lock.
+func (r *DigestRepository) WithDigestLock(ctx context.Context, fn func(ctx context.Context) error) (bool, error) {
+	return withAdvisoryLock(ctx, r.db, digestLockKey, fn)
+}
+
+// =============================================================================
+// Agent Template Repository
+// =============================================================================
+
+type AgentTemplateRepository struct {
+	db *PostgresDB
+}
+
+const agentTemplateColumns = `id, tenant_id, created_by, name, COALESCE(description, ''), type,
+			COALESCE(system_prompt, ''), default_config, tools, COALESCE(category, ''), is_public, created_at`
+
+func scanAgentTemplate(row pgx.Row) (*models.AgentTemplate, error) {
+	var t models.AgentTemplate
+	var configJSON []byte
+	err := row.Scan(&t.ID, &t.TenantID, &t.CreatedBy, &t.Name, &t.Description, &t.Type,
+		&t.SystemPrompt, &configJSON, &t.Tools, &t.Category, &t.IsPublic, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	json.Unmarshal(configJSON, &t.DefaultConfig)
+	return &t, nil
+}
+
+func (r *AgentTemplateRepository) Create(ctx context.Context, t *models.AgentTemplate) error {
+	configJSON, _ := json.Marshal(t.DefaultConfig)
+	tools := t.Tools
+	if len(tools) == 0 {
+		tools = json.RawMessage("[]")
+	}
+	query := `
+		INSERT INTO agent_templates (id, tenant_id, created_by, name, description, type, system_prompt,
+									 default_config, tools, category, is_public, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`
+	_, err := r.db.pool.Exec(ctx, query,
+		t.ID, t.TenantID, t.CreatedBy, t.Name, t.Description, t.Type, t.SystemPrompt,
+		configJSON, tools, t.Category, t.IsPublic, t.CreatedAt)
+	return err
+}
+
+func (r *AgentTemplateRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.AgentTemplate, error) {
+	query := `SELECT ` + agentTemplateColumns + ` FROM agent_templates WHERE id = $1`
+	t, err := scanAgentTemplate(r.db.pool.QueryRow(ctx, query, id))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return t, err
+}
+
+// ListVisible returns the tenant's own templates and public templates saved
+// by any tenant, by name
+func (r *AgentTemplateRepository) ListVisible(ctx context.Context, tenantID uuid.UUID) ([]*models.AgentTemplate, error) {
+	query := `SELECT ` + agentTemplateColumns + ` FROM agent_templates
+			  WHERE tenant_id = $1 OR is_public ORDER BY name`
+	rows, err := r.db.pool.Query(ctx, query, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []*models.AgentTemplate
+	for rows.Next() {
+		t, err := scanAgentTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+func (r *AgentTemplateRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM agent_templates WHERE id = $1`
+	_, err := r.db.pool.Exec(ctx, query, id)
+	return err
+}
+
+// =============================================================================
+// Run Batch Repository
+// =============================================================================
+
+type RunBatchRepository struct {
+	db *PostgresDB
+}
+
+func (r *RunBatchRepository) Create(ctx context.Context, batch *models.RunBatch) error {
+	query := `INSERT INTO run_batches (id, tenant_id, item_count, created_at) VALUES ($1, $2, $3, $4)`
+	_, err := r.db.pool.Exec(ctx, query, batch.ID, batch.TenantID, batch.ItemCount, batch.CreatedAt)
+	return err
+}
+
+func (r *RunBatchRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.RunBatch, error) {
+	query := `SELECT id, tenant_id, item_count, created_at FROM run_batches WHERE id = $1`
+	var batch models.RunBatch
+	err := r.db.pool.QueryRow(ctx, query, id).Scan(&batch.ID, &batch.TenantID, &batch.ItemCount, &batch.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return &batch, err
+}
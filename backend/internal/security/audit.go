@@ -8,6 +8,7 @@ import (
 
 	"github.com/delphi-platform/delphi/backend/pkg/logger"
 	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
 )
 
 // =============================================================================
@@ -38,6 +39,11 @@ const (
 	AuditActionAgentDeleted   AuditAction = "agent.deleted"
 	AuditActionAgentExecuted  AuditAction = "agent.executed"
 
+	// Guardrail actions
+	AuditActionInputBlocked   AuditAction = "guardrail.input_blocked"
+	AuditActionOutputBlocked  AuditAction = "guardrail.output_blocked"
+	AuditActionOutputRedacted AuditAction = "guardrail.output_redacted"
+
 	// API key actions
 	AuditActionAPIKeyCreated  AuditAction = "apikey.created"
 	AuditActionAPIKeyUpdated  AuditAction = "apikey.updated"
@@ -76,6 +82,7 @@ type AuditEntry struct {
 	ID          uuid.UUID              `json:"id"`
 	TenantID    uuid.UUID              `json:"tenant_id"`
 	UserID      *uuid.UUID             `json:"user_id,omitempty"`
+	AgentID     *uuid.UUID             `json:"agent_id,omitempty"`
 	Action      AuditAction            `json:"action"`
 	Severity    AuditSeverity          `json:"severity"`
 	ResourceType string                `json:"resource_type"`
@@ -91,11 +98,16 @@ type AuditEntry struct {
 // Audit Service
 // =============================================================================
 
-// AuditService handles audit logging
+// AuditService handles audit logging. Entries are buffered and stored in
+// batches; entries that can't be buffered or stored are retried from a
+// dead-letter queue (see audit_overflow.go).
 type AuditService struct {
-	log     *logger.Logger
-	buffer  chan *AuditEntry
-	storage AuditStorage
+	log      *logger.Logger
+	buffer   chan *AuditEntry
+	storage  AuditStorage
+	overflow *auditOverflow
+	stop     chan struct{}
+	done     chan struct{}
 }
 
 // AuditStorage interface for storing audit logs
@@ -122,13 +134,17 @@ type AuditQuery struct {
 // NewAuditService creates a new audit service
 func NewAuditService(log *logger.Logger, storage AuditStorage) *AuditService {
 	s := &AuditService{
-		log:     log,
-		buffer:  make(chan *AuditEntry, 10000),
-		storage: storage,
+		log:      log,
+		buffer:   make(chan *AuditEntry, 10000),
+		storage:  storage,
+		overflow: newAuditOverflow(),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
 	}
 
-	// Start background processor
+	// Start background processors
 	go s.processBuffer()
+	go s.retryDeadLetters()
 
 	return s
 }
@@ -142,28 +158,84 @@ func (s *AuditService) Log(ctx context.Context, entry *AuditEntry) {
 		entry.Severity = SeverityInfo
 	}
 
+	// Once stopped, nothing drains the buffer
+	select {
+	case <-s.stop:
+		s.storeOrSpill(ctx, entry)
+		return
+	default:
+	}
+
 	select {
 	case s.buffer <- entry:
+		return
 	default:
-		// Buffer full, log directly
-		s.log.Warnw("audit buffer full, logging directly",
-			"action", entry.Action,
-			"tenant_id", entry.TenantID,
-		)
-		if s.storage != nil {
-			s.storage.Store(ctx, entry)
+	}
+
+	// Critical entries are never dropped: wait briefly for room, and failing
+	// that store the entry now
+	if entry.Severity == SeverityCritical {
+		timer := time.NewTimer(criticalEnqueueTimeout)
+		defer timer.Stop()
+		select {
+		case s.buffer <- entry:
+		case <-timer.C:
+			s.log.Warnw("audit buffer full, storing critical entry directly",
+				"action", entry.Action,
+				"tenant_id", entry.TenantID,
+			)
+			if err := s.write(ctx, entry); err != nil {
+				s.deadLetter(entry, 1, err)
+			}
 		}
+		return
 	}
+
+	s.log.Warnw("audit buffer full, deferring entry",
+		"action", entry.Action,
+		"tenant_id", entry.TenantID,
+	)
+	s.deadLetter(entry, 0, nil)
+}
+
+// Stop writes the buffered and pending entries, makes a last attempt at the
+// dead-letter queue, and stops the background processors. Entries logged
+// afterwards are written directly.
+func (s *AuditService) Stop() {
+	close(s.stop)
+	<-s.done
+	close(s.overflow.stopRetries)
+	<-s.overflow.retriesDone
+	s.overflow.closeFile()
 }
 
 // processBuffer processes buffered audit entries
 func (s *AuditService) processBuffer() {
+	defer close(s.done)
+
 	batch := make([]*AuditEntry, 0, 100)
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
 	for {
 		select {
+		case <-s.stop:
+			// Drain what was buffered before the stop, then the pending batch
+			for {
+				select {
+				case entry := <-s.buffer:
+					batch = append(batch, entry)
+					if len(batch) >= 100 {
+						s.flushBatch(batch)
+						batch = batch[:0]
+					}
+				default:
+					if len(batch) > 0 {
+						s.flushBatch(batch)
+					}
+					return
+				}
+			}
 		case entry := <-s.buffer:
 			batch = append(batch, entry)
 			if len(batch) >= 100 {
@@ -179,17 +251,13 @@ func (s *AuditService) processBuffer() {
 	}
 }
 
-// flushBatch writes a batch of audit entries to storage
+// flushBatch writes a batch of audit entries to storage. Entries that fail
+// are queued for retry.
 func (s *AuditService) flushBatch(batch []*AuditEntry) {
 	ctx := context.Background()
 	for _, entry := range batch {
-		if s.storage != nil {
-			if err := s.storage.Store(ctx, entry); err != nil {
-				s.log.Errorw("failed to store audit entry",
-					"error", err,
-					"entry_id", entry.ID,
-				)
-			}
+		if err := s.write(ctx, entry); err != nil {
+			s.deadLetter(entry, 1, err)
 		}
 
 		// Also log to structured logger
@@ -204,6 +272,14 @@ func (s *AuditService) flushBatch(batch []*AuditEntry) {
 	}
 }
 
+// write stores an entry; without storage, entries are only logged
+func (s *AuditService) write(ctx context.Context, entry *AuditEntry) error {
+	if s.storage == nil {
+		return nil
+	}
+	return s.storage.Store(ctx, entry)
+}
+
 // Query retrieves audit entries based on filters
 func (s *AuditService) Query(ctx context.Context, query AuditQuery) ([]*AuditEntry, error) {
 	if s.storage == nil {
@@ -262,6 +338,21 @@ func (s *AuditService) LogAgentExecution(ctx context.Context, tenantID, userID,
 	})
 }
 
+// LogGuardrail logs a prompt or response blocked or redacted by guardrails
+func (s *AuditService) LogGuardrail(ctx context.Context, tenantID, agentID uuid.UUID, action AuditAction, reasons []string) {
+	s.Log(ctx, &AuditEntry{
+		TenantID:     tenantID,
+		AgentID:      &agentID,
+		Action:       action,
+		Severity:     SeverityWarning,
+		ResourceType: "agent",
+		ResourceID:   &agentID,
+		Details: map[string]interface{}{
+			"reasons": reasons,
+		},
+	})
+}
+
 // LogAPIKeyCreated logs API key creation
 func (s *AuditService) LogAPIKeyCreated(ctx context.Context, tenantID, userID uuid.UUID, provider string) {
 	s.Log(ctx, &AuditEntry{
@@ -408,11 +499,18 @@ func (r *RBAC) CanAccess(role Role, resource string, action string) bool {
 // Rate Limiting
 // =============================================================================
 
-// RateLimiter implements rate limiting for API calls
+// rateLimitSweepInterval is how often idle buckets are dropped
+const rateLimitSweepInterval = time.Minute
+
+// RateLimiter implements rate limiting for API calls. Buckets are kept in
+// memory unless Redis is set, when they are shared by every instance.
 type RateLimiter struct {
-	log      *logger.Logger
-	requests map[string]*rateLimitBucket
-	mu       sync.RWMutex
+	log       *logger.Logger
+	requests  map[string]*rateLimitBucket
+	mu        sync.RWMutex
+	lastSweep time.Time
+	now       func() time.Time
+	redis     *redis.Client
 }
 
 type rateLimitBucket struct {
@@ -420,44 +518,114 @@ type rateLimitBucket struct {
 	lastFill  time.Time
 	maxTokens float64
 	fillRate  float64 // tokens per second
+	window    time.Duration
+}
+
+// RateLimitResult describes the outcome of a rate limit check
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long until the next request is allowed; zero if allowed
+	RetryAfter time.Duration
 }
 
 // NewRateLimiter creates a new rate limiter
 func NewRateLimiter(log *logger.Logger) *RateLimiter {
 	return &RateLimiter{
-		log:      log,
-		requests: make(map[string]*rateLimitBucket),
+		log:       log,
+		requests:  make(map[string]*rateLimitBucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
 	}
 }
 
+// SetClock replaces the clock in-memory buckets refill by, for tests
+func (r *RateLimiter) SetClock(now func() time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.now = now
+	r.lastSweep = now()
+}
+
+// Size returns how many buckets are kept in memory
+func (r *RateLimiter) Size() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.requests)
+}
+
 // Allow checks if a request is allowed
-func (r *RateLimiter) Allow(key string, maxRequests int, window time.Duration) bool {
+func (r *RateLimiter) Allow(ctx context.Context, key string, maxRequests int, window time.Duration) bool {
+	return r.Check(ctx, key, maxRequests, window).Allowed
+}
+
+// Check takes a token from the key's bucket, which holds maxRequests tokens
+// and refills over window. A bucket whose limits changed, such as after a plan
+// change, keeps its tokens up to the new maximum. With Redis, a failed check
+// falls back to this instance's bucket.
+func (r *RateLimiter) Check(ctx context.Context, key string, maxRequests int, window time.Duration) RateLimitResult {
+	if r.redis != nil {
+		result, err := r.checkRedis(ctx, key, maxRequests, window)
+		if err == nil {
+			return result
+		}
+		r.log.Warnw("shared rate limit check failed, using this instance's", "key", key, "error", err)
+	}
+	return r.checkMemory(key, maxRequests, window)
+}
+
+// checkMemory takes a token from the key's bucket in memory
+func (r *RateLimiter) checkMemory(key string, maxRequests int, window time.Duration) RateLimitResult {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	now := r.now()
+	if now.Sub(r.lastSweep) >= rateLimitSweepInterval {
+		r.sweep(now)
+	}
+
+	maxTokens := float64(maxRequests)
+	fillRate := maxTokens / window.Seconds()
+
 	bucket, ok := r.requests[key]
 	if !ok {
 		bucket = &rateLimitBucket{
-			tokens:    float64(maxRequests),
-			lastFill:  time.Now(),
-			maxTokens: float64(maxRequests),
-			fillRate:  float64(maxRequests) / window.Seconds(),
+			tokens:   maxTokens,
+			lastFill: now,
 		}
 		r.requests[key] = bucket
 	}
+	bucket.maxTokens = maxTokens
+	bucket.fillRate = fillRate
+	bucket.window = window
 
 	// Refill tokens
-	now := time.Now()
 	elapsed := now.Sub(bucket.lastFill).Seconds()
 	bucket.tokens = min(bucket.maxTokens, bucket.tokens+elapsed*bucket.fillRate)
 	bucket.lastFill = now
 
+	result := RateLimitResult{Limit: maxRequests}
 	if bucket.tokens >= 1 {
 		bucket.tokens--
-		return true
+		result.Allowed = true
+	} else if bucket.fillRate > 0 {
+		result.RetryAfter = time.Duration((1 - bucket.tokens) / bucket.fillRate * float64(time.Second))
 	}
+	result.Remaining = int(bucket.tokens)
 
-	return false
+	return result
+}
+
+// sweep drops buckets that have been idle for their whole window. Such a
+// bucket has refilled completely, so forgetting it changes nothing.
+func (r *RateLimiter) sweep(now time.Time) {
+	for key, bucket := range r.requests {
+		if now.Sub(bucket.lastFill) >= bucket.window {
+			delete(r.requests, key)
+		}
+	}
+	r.lastSweep = now
 }
 
 func min(a, b float64) float64 {
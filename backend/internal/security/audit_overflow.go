@@ -0,0 +1,332 @@
+package security
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// =============================================================================
+// Audit Overflow
+// =============================================================================
+
+const (
+	// deadLetterCapacity bounds the entries waiting to be retried
+	deadLetterCapacity = 1000
+
+	// deadLetterMaxAttempts is how many times an entry is stored before it
+	// is spilled to the overflow file, or dropped without one. Critical
+	// entries keep being retried.
+	deadLetterMaxAttempts = 5
+
+	// Retries back off exponentially between these bounds
+	deadLetterBaseBackoff = time.Second
+	deadLetterMaxBackoff  = time.Minute
+
+	// criticalEnqueueTimeout is how long logging a critical entry waits for
+	// room in a full buffer or dead-letter queue
+	criticalEnqueueTimeout = 5 * time.Second
+)
+
+// AuditStats counts the audit entries that could not be stored on the first try
+type AuditStats struct {
+	DeadLettered int64 `json:"dead_lettered"` // queued for retry
+	Retried      int64 `json:"retried"`       // store attempts from the queue
+	Recovered    int64 `json:"recovered"`     // stored on a retry
+	Spilled      int64 `json:"spilled"`       // written to the overflow file
+	Dropped      int64 `json:"dropped"`       // lost
+	Pending      int   `json:"pending"`       // waiting in the queue
+}
+
+// deadLetter is an entry waiting to be stored again
+type deadLetter struct {
+	entry    *AuditEntry
+	attempts int
+	retryAt  time.Time
+}
+
+// auditOverflow holds the dead-letter queue, its counters and the optional
+// overflow file
+type auditOverflow struct {
+	letters     chan *deadLetter
+	held        atomic.Int64 // taken off the queue by the retry loop
+	stopRetries chan struct{}
+	retriesDone chan struct{}
+
+	deadLettered atomic.Int64
+	retried      atomic.Int64
+	recovered    atomic.Int64
+	spilled      atomic.Int64
+	dropped      atomic.Int64
+
+	fileMu sync.Mutex
+	file   *os.File
+}
+
+func newAuditOverflow() *auditOverflow {
+	return &auditOverflow{
+		letters:     make(chan *deadLetter, deadLetterCapacity),
+		stopRetries: make(chan struct{}),
+		retriesDone: make(chan struct{}),
+	}
+}
+
+// SetOverflowFile appends entries that can't be stored, as JSON lines, to the
+// file at path as a last resort rather than dropping them
+func (s *AuditService) SetOverflowFile(path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit overflow file: %w", err)
+	}
+
+	s.overflow.fileMu.Lock()
+	defer s.overflow.fileMu.Unlock()
+	if s.overflow.file != nil {
+		s.overflow.file.Close()
+	}
+	s.overflow.file = file
+	return nil
+}
+
+// Stats returns the overflow counters
+func (s *AuditService) Stats() AuditStats {
+	o := s.overflow
+	return AuditStats{
+		DeadLettered: o.deadLettered.Load(),
+		Retried:      o.retried.Load(),
+		Recovered:    o.recovered.Load(),
+		Spilled:      o.spilled.Load(),
+		Dropped:      o.dropped.Load(),
+		Pending:      len(o.letters) + int(o.held.Load()),
+	}
+}
+
+// OverflowCounts returns the overflow counters by outcome, for metrics
+func (s *AuditService) OverflowCounts() map[string]int64 {
+	stats := s.Stats()
+	return map[string]int64{
+		"dead_lettered": stats.DeadLettered,
+		"retried":       stats.Retried,
+		"recovered":     stats.Recovered,
+		"spilled":       stats.Spilled,
+		"dropped":       stats.Dropped,
+	}
+}
+
+// QueueDepth returns how many entries are waiting to be stored, buffered or
+// in the dead-letter queue
+func (s *AuditService) QueueDepth() int {
+	return len(s.buffer) + len(s.overflow.letters) + int(s.overflow.held.Load())
+}
+
+// deadLetter queues an entry for another store attempt after a backoff. If
+// the queue is full the entry is spilled, though critical entries first wait
+// briefly for room.
+func (s *AuditService) deadLetter(entry *AuditEntry, attempts int, cause error) {
+	if cause != nil {
+		s.log.Warnw("failed to store audit entry, will retry",
+			"entry_id", entry.ID,
+			"action", entry.Action,
+			"attempts", attempts,
+			"error", cause,
+		)
+	}
+
+	letter := &deadLetter{entry: entry, attempts: attempts, retryAt: time.Now().Add(deadLetterBackoff(attempts))}
+	select {
+	case s.overflow.letters <- letter:
+		s.overflow.deadLettered.Add(1)
+		return
+	default:
+	}
+
+	if entry.Severity == SeverityCritical {
+		timer := time.NewTimer(criticalEnqueueTimeout)
+		defer timer.Stop()
+		select {
+		case s.overflow.letters <- letter:
+			s.overflow.deadLettered.Add(1)
+			return
+		case <-timer.C:
+		}
+	}
+	s.spill(entry, "dead-letter queue full")
+}
+
+// deadLetterBackoff returns how long to wait before the next store attempt
+func deadLetterBackoff(attempts int) time.Duration {
+	if attempts <= 0 {
+		return 0
+	}
+	backoff := deadLetterBaseBackoff << (attempts - 1)
+	if backoff <= 0 || backoff > deadLetterMaxBackoff {
+		return deadLetterMaxBackoff
+	}
+	return backoff
+}
+
+// retryDeadLetters stores dead-lettered entries as their backoffs expire.
+// Entries taken off the queue are held here until they are stored or spilled,
+// so a failed retry is requeued without sending to the channel this loop
+// drains. On stop, each remaining entry gets one last attempt before it is
+// spilled.
+func (s *AuditService) retryDeadLetters() {
+	defer close(s.overflow.retriesDone)
+
+	var held []*deadLetter
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		// Stop taking entries off the queue while holding a full queue's
+		// worth, so entries back up into it and then spill
+		letters := s.overflow.letters
+		if len(held) >= deadLetterCapacity {
+			letters = nil
+		}
+
+		next := nextDeadLetter(held)
+		var due <-chan time.Time
+		if next >= 0 {
+			timer.Reset(time.Until(held[next].retryAt))
+			due = timer.C
+		}
+
+		select {
+		case letter := <-letters:
+			held = append(held, letter)
+			s.overflow.held.Add(1)
+		case <-due:
+			if s.retry(held[next]) {
+				continue
+			}
+			held = append(held[:next], held[next+1:]...)
+			s.overflow.held.Add(-1)
+		case <-s.overflow.stopRetries:
+			for _, letter := range held {
+				s.finalAttempt(letter)
+			}
+			s.overflow.held.Store(0)
+			s.drainDeadLetters()
+			return
+		}
+		timer.Stop()
+	}
+}
+
+// nextDeadLetter returns the index of the held entry due first, or -1
+func nextDeadLetter(held []*deadLetter) int {
+	next := -1
+	for i, letter := range held {
+		if next < 0 || letter.retryAt.Before(held[next].retryAt) {
+			next = i
+		}
+	}
+	return next
+}
+
+// retry makes another store attempt. On failure it backs the entry off and
+// reports that it should be held for another attempt, until it runs out of
+// attempts and is spilled.
+func (s *AuditService) retry(letter *deadLetter) bool {
+	s.overflow.retried.Add(1)
+	err := s.write(context.Background(), letter.entry)
+	if err == nil {
+		s.overflow.recovered.Add(1)
+		return false
+	}
+
+	letter.attempts++
+	if letter.attempts >= deadLetterMaxAttempts && letter.entry.Severity != SeverityCritical {
+		s.spill(letter.entry, err.Error())
+		return false
+	}
+	s.log.Warnw("failed to store audit entry, will retry",
+		"entry_id", letter.entry.ID,
+		"action", letter.entry.Action,
+		"attempts", letter.attempts,
+		"error", err,
+	)
+	letter.retryAt = time.Now().Add(deadLetterBackoff(letter.attempts))
+	return true
+}
+
+// drainDeadLetters makes a last attempt at every queued entry
+func (s *AuditService) drainDeadLetters() {
+	for {
+		select {
+		case letter := <-s.overflow.letters:
+			s.finalAttempt(letter)
+		default:
+			return
+		}
+	}
+}
+
+// finalAttempt stores an entry once more, spilling it on failure
+func (s *AuditService) finalAttempt(letter *deadLetter) {
+	s.overflow.retried.Add(1)
+	if err := s.write(context.Background(), letter.entry); err != nil {
+		s.spill(letter.entry, err.Error())
+		return
+	}
+	s.overflow.recovered.Add(1)
+}
+
+// storeOrSpill stores an entry now, spilling it on failure
+func (s *AuditService) storeOrSpill(ctx context.Context, entry *AuditEntry) {
+	if err := s.write(ctx, entry); err != nil {
+		s.spill(entry, err.Error())
+	}
+}
+
+// spill writes an entry to the overflow file as a last resort, or drops it
+// if there is none. Dropped entries are logged in full so they can still be
+// recovered from the process logs.
+func (s *AuditService) spill(entry *AuditEntry, reason string) {
+	o := s.overflow
+	o.fileMu.Lock()
+	defer o.fileMu.Unlock()
+
+	if o.file != nil {
+		line, err := json.Marshal(entry)
+		if err == nil {
+			_, err = o.file.Write(append(line, '\n'))
+		}
+		if err == nil {
+			o.spilled.Add(1)
+			s.log.Warnw("audit entry written to overflow file", "entry_id", entry.ID, "action", entry.Action, "reason", reason)
+			return
+		}
+		s.log.Errorw("failed to write audit overflow file", "entry_id", entry.ID, "error", err)
+	}
+
+	o.dropped.Add(1)
+	s.log.Errorw("audit entry dropped",
+		"reason", reason,
+		"entry_id", entry.ID,
+		"action", entry.Action,
+		"severity", entry.Severity,
+		"tenant_id", entry.TenantID,
+		"user_id", entry.UserID,
+		"resource_type", entry.ResourceType,
+		"resource_id", entry.ResourceID,
+		"details", entry.Details,
+		"timestamp", entry.Timestamp,
+	)
+}
+
+// closeFile closes the overflow file; later spills drop their entries
+func (o *auditOverflow) closeFile() {
+	o.fileMu.Lock()
+	defer o.fileMu.Unlock()
+	if o.file != nil {
+		o.file.Close()
+		o.file = nil
+	}
+}
@@ -0,0 +1,152 @@
+package security
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/google/uuid"
+)
+
+// =============================================================================
+// Postgres Audit Storage
+// =============================================================================
+
+// PostgresAuditStorage stores audit entries in the audit_logs table
+type PostgresAuditStorage struct {
+	repo *repository.AuditRepository
+}
+
+// NewPostgresAuditStorage creates a Postgres audit storage
+func NewPostgresAuditStorage(repo *repository.AuditRepository) *PostgresAuditStorage {
+	return &PostgresAuditStorage{repo: repo}
+}
+
+// Store writes an audit entry
+func (s *PostgresAuditStorage) Store(ctx context.Context, entry *AuditEntry) error {
+	log := &models.AuditLog{
+		ID:           entry.ID,
+		TenantID:     entry.TenantID,
+		UserID:       entry.UserID,
+		AgentID:      entry.AgentID,
+		Action:       string(entry.Action),
+		ResourceType: entry.ResourceType,
+		IPAddress:    entry.IPAddress,
+		UserAgent:    entry.UserAgent,
+		Severity:     string(entry.Severity),
+		SessionID:    entry.SessionID,
+		CreatedAt:    entry.Timestamp,
+	}
+	if entry.ResourceID != nil {
+		log.ResourceID = entry.ResourceID.String()
+	}
+	if len(entry.Details) > 0 {
+		details, err := json.Marshal(entry.Details)
+		if err != nil {
+			return fmt.Errorf("failed to encode audit details: %w", err)
+		}
+		log.Details = details
+	}
+	return s.repo.Create(ctx, log)
+}
+
+// Query returns the tenant's audit entries matching the query, newest first
+func (s *PostgresAuditStorage) Query(ctx context.Context, query AuditQuery) ([]*AuditEntry, error) {
+	filter := repository.AuditLogFilter{
+		TenantID:     query.TenantID,
+		UserID:       query.UserID,
+		ResourceType: query.ResourceType,
+		Start:        query.StartTime,
+		End:          query.EndTime,
+		Limit:        query.Limit,
+		Offset:       query.Offset,
+	}
+	for _, action := range query.Actions {
+		filter.Actions = append(filter.Actions, string(action))
+	}
+	if query.ResourceID != nil {
+		filter.ResourceID = query.ResourceID.String()
+	}
+	if query.Severity != nil {
+		filter.Severity = string(*query.Severity)
+	}
+
+	logs, err := s.repo.Query(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*AuditEntry, 0, len(logs))
+	for _, log := range logs {
+		entry, err := auditEntryFromLog(log)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// GetByID returns an audit entry, or nil if it doesn't exist
+func (s *PostgresAuditStorage) GetByID(ctx context.Context, id uuid.UUID) (*AuditEntry, error) {
+	log, err := s.repo.GetByID(ctx, id)
+	if err != nil || log == nil {
+		return nil, err
+	}
+	return auditEntryFromLog(log)
+}
+
+// auditEntryFromLog converts a stored row to an entry. Resource IDs that
+// aren't UUIDs are kept in the details, and the old_value and new_value
+// columns written by other paths are surfaced there too.
+func auditEntryFromLog(log *models.AuditLog) (*AuditEntry, error) {
+	entry := &AuditEntry{
+		ID:           log.ID,
+		TenantID:     log.TenantID,
+		UserID:       log.UserID,
+		AgentID:      log.AgentID,
+		Action:       AuditAction(log.Action),
+		Severity:     AuditSeverity(log.Severity),
+		ResourceType: log.ResourceType,
+		IPAddress:    log.IPAddress,
+		UserAgent:    log.UserAgent,
+		SessionID:    log.SessionID,
+		Timestamp:    log.CreatedAt,
+	}
+
+	if len(log.Details) > 0 {
+		if err := json.Unmarshal(log.Details, &entry.Details); err != nil {
+			return nil, fmt.Errorf("failed to decode details of audit entry %s: %w", log.ID, err)
+		}
+	}
+	setDetail := func(key string, value interface{}) {
+		if entry.Details == nil {
+			entry.Details = make(map[string]interface{})
+		}
+		if _, ok := entry.Details[key]; !ok {
+			entry.Details[key] = value
+		}
+	}
+
+	if log.ResourceID != "" {
+		if resourceID, err := uuid.Parse(log.ResourceID); err == nil {
+			entry.ResourceID = &resourceID
+		} else {
+			setDetail("resource_id", log.ResourceID)
+		}
+	}
+	for key, raw := range map[string]json.RawMessage{"old_value": log.OldValue, "new_value": log.NewValue} {
+		if len(raw) == 0 {
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, fmt.Errorf("failed to decode %s of audit entry %s: %w", key, log.ID, err)
+		}
+		setDetail(key, value)
+	}
+
+	return entry, nil
+}
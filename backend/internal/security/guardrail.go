@@ -0,0 +1,338 @@
+package security
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/sashabaranov/go-openai"
+)
+
+// =============================================================================
+// Guardrails
+// =============================================================================
+
+// redactedText replaces the parts of a response that break a guardrail
+const redactedText = "[REDACTED]"
+
+// Guardrail checks the content sent to and returned by an agent. config is the
+// agent's own guardrail configuration and may be nil.
+type Guardrail interface {
+	CheckInput(ctx context.Context, input string, config *models.GuardrailConfig) (*GuardrailResult, error)
+	CheckOutput(ctx context.Context, output string, config *models.GuardrailConfig) (*GuardrailResult, error)
+}
+
+// GuardrailResult is the outcome of a guardrail check. Blocked content must not
+// be used; otherwise Content is the content to use, redacted if Redacted.
+type GuardrailResult struct {
+	Blocked  bool     `json:"blocked"`
+	Redacted bool     `json:"redacted,omitempty"`
+	Reasons  []string `json:"reasons,omitempty"`
+	Content  string   `json:"-"`
+}
+
+// GuardrailError is returned by EnforceInput and EnforceOutput when content is
+// blocked
+type GuardrailError struct {
+	Stage   string // input or output
+	Reasons []string
+}
+
+func (e *GuardrailError) Error() string {
+	return fmt.Sprintf("%s blocked by guardrails: %s", e.Stage, strings.Join(e.Reasons, "; "))
+}
+
+// Moderator classifies content with a moderation service. It returns the
+// categories the content was flagged for, or none.
+type Moderator interface {
+	Moderate(ctx context.Context, text string) ([]string, error)
+}
+
+// DefaultGuardrail blocks content matching platform-wide and per-agent
+// patterns and keywords, and optionally content flagged by a moderator
+type DefaultGuardrail struct {
+	patterns  []*regexp.Regexp // platform-wide, applied to every agent
+	moderator Moderator        // nil disables moderation
+	log       *logger.Logger
+
+	compiled sync.Map // agent pattern -> *regexp.Regexp
+}
+
+// NewGuardrail creates a guardrail with platform-wide blocked patterns.
+// moderator may be nil, in which case agents asking for moderation get only
+// their pattern and keyword checks.
+func NewGuardrail(patterns []string, moderator Moderator, log *logger.Logger) (*DefaultGuardrail, error) {
+	g := &DefaultGuardrail{moderator: moderator, log: log}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid guardrail pattern %q: %w", pattern, err)
+		}
+		g.patterns = append(g.patterns, re)
+	}
+	return g, nil
+}
+
+// ValidateGuardrailConfig checks that an agent's guardrail patterns compile
+// and its output action is known
+func ValidateGuardrailConfig(config *models.GuardrailConfig) error {
+	if config == nil {
+		return nil
+	}
+	for _, pattern := range config.BlockedPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid guardrail pattern %q: %w", pattern, err)
+		}
+	}
+	switch config.OutputAction {
+	case "", models.GuardrailOutputBlock, models.GuardrailOutputRedact:
+		return nil
+	default:
+		return fmt.Errorf("invalid guardrail output action %q: use block or redact", config.OutputAction)
+	}
+}
+
+// ChecksOutput reports whether CheckOutput can block or change an agent's
+// responses, so callers streaming them know whether to hold them back
+func (g *DefaultGuardrail) ChecksOutput(config *models.GuardrailConfig) bool {
+	if len(g.patterns) > 0 {
+		return true
+	}
+	if config == nil {
+		return false
+	}
+	return len(config.BlockedPatterns) > 0 || len(config.BlockedKeywords) > 0 ||
+		(config.Moderation && g.moderator != nil)
+}
+
+// CheckInput blocks a prompt that matches any rule or is flagged by moderation
+func (g *DefaultGuardrail) CheckInput(ctx context.Context, input string, config *models.GuardrailConfig) (*GuardrailResult, error) {
+	result := &GuardrailResult{Content: input}
+
+	matches, err := g.match(input, config)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range matches {
+		result.Reasons = append(result.Reasons, rule.reason)
+	}
+
+	flagged, err := g.moderate(ctx, input, config)
+	if err != nil {
+		return nil, err
+	}
+	result.Reasons = append(result.Reasons, flagged...)
+
+	result.Blocked = len(result.Reasons) > 0
+	return result, nil
+}
+
+// CheckOutput blocks a response that matches any rule or is flagged by
+// moderation, or redacts the matches when the agent is configured to
+func (g *DefaultGuardrail) CheckOutput(ctx context.Context, output string, config *models.GuardrailConfig) (*GuardrailResult, error) {
+	result := &GuardrailResult{Content: output}
+
+	flagged, err := g.moderate(ctx, output, config)
+	if err != nil {
+		return nil, err
+	}
+	if len(flagged) > 0 {
+		result.Blocked = true
+		result.Reasons = flagged
+		return result, nil
+	}
+
+	matches, err := g.match(output, config)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return result, nil
+	}
+	for _, rule := range matches {
+		result.Reasons = append(result.Reasons, rule.reason)
+	}
+
+	if config == nil || config.OutputAction != models.GuardrailOutputRedact {
+		result.Blocked = true
+		return result, nil
+	}
+	for _, rule := range matches {
+		result.Content = rule.re.ReplaceAllString(result.Content, redactedText)
+	}
+	result.Redacted = true
+	return result, nil
+}
+
+// guardrailRule is a compiled pattern and how to describe a match of it
+type guardrailRule struct {
+	re     *regexp.Regexp
+	reason string
+}
+
+// match returns the platform and agent rules the text matches. Keywords are
+// matched as case-insensitive whole words.
+func (g *DefaultGuardrail) match(text string, config *models.GuardrailConfig) ([]guardrailRule, error) {
+	rules := make([]guardrailRule, 0, len(g.patterns))
+	for _, re := range g.patterns {
+		rules = append(rules, guardrailRule{re: re, reason: "matched a platform content rule"})
+	}
+	if config != nil {
+		for _, pattern := range config.BlockedPatterns {
+			re, err := g.compile(pattern)
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, guardrailRule{re: re, reason: fmt.Sprintf("matched blocked pattern %q", pattern)})
+		}
+		for _, keyword := range config.BlockedKeywords {
+			if keyword = strings.TrimSpace(keyword); keyword == "" {
+				continue
+			}
+			re, err := g.compile(`(?i)\b` + regexp.QuoteMeta(keyword) + `\b`)
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, guardrailRule{re: re, reason: fmt.Sprintf("contains blocked keyword %q", keyword)})
+		}
+	}
+
+	var matches []guardrailRule
+	for _, rule := range rules {
+		if rule.re.MatchString(text) {
+			matches = append(matches, rule)
+		}
+	}
+	return matches, nil
+}
+
+// compile returns the compiled form of an agent pattern, caching it since the
+// same agents' patterns are checked on every execution
+func (g *DefaultGuardrail) compile(pattern string) (*regexp.Regexp, error) {
+	if re, ok := g.compiled.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid guardrail pattern %q: %w", pattern, err)
+	}
+	g.compiled.Store(pattern, re)
+	return re, nil
+}
+
+// moderate returns the reasons the moderator flagged the text for, when the
+// agent asks for moderation and a moderator is configured. A failed check is
+// returned as an error, so unmoderated content is never let through.
+func (g *DefaultGuardrail) moderate(ctx context.Context, text string, config *models.GuardrailConfig) ([]string, error) {
+	if config == nil || !config.Moderation || g.moderator == nil || strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+
+	categories, err := g.moderator.Moderate(ctx, text)
+	if err != nil {
+		g.log.Warnw("content moderation failed", "error", err)
+		return nil, fmt.Errorf("content moderation failed: %w", err)
+	}
+
+	reasons := make([]string, 0, len(categories))
+	for _, category := range categories {
+		reasons = append(reasons, "flagged by moderation: "+category)
+	}
+	return reasons, nil
+}
+
+// EnforceInput checks a prompt and returns a *GuardrailError if it is blocked.
+// Blocked prompts are recorded in the audit log when audit is non-nil.
+func EnforceInput(ctx context.Context, g Guardrail, audit *AuditService, tenantID, agentID uuid.UUID, input string, config *models.GuardrailConfig) error {
+	result, err := g.CheckInput(ctx, input, config)
+	if err != nil {
+		return err
+	}
+	if !result.Blocked {
+		return nil
+	}
+	if audit != nil {
+		audit.LogGuardrail(ctx, tenantID, agentID, AuditActionInputBlocked, result.Reasons)
+	}
+	return &GuardrailError{Stage: "input", Reasons: result.Reasons}
+}
+
+// EnforceOutput checks a response and returns the content to deliver,
+// redacted if the agent is configured to, or a *GuardrailError if it is
+// blocked. Blocked and redacted responses are recorded in the audit log when
+// audit is non-nil.
+func EnforceOutput(ctx context.Context, g Guardrail, audit *AuditService, tenantID, agentID uuid.UUID, output string, config *models.GuardrailConfig) (string, error) {
+	result, err := g.CheckOutput(ctx, output, config)
+	if err != nil {
+		return "", err
+	}
+	if result.Blocked {
+		if audit != nil {
+			audit.LogGuardrail(ctx, tenantID, agentID, AuditActionOutputBlocked, result.Reasons)
+		}
+		return "", &GuardrailError{Stage: "output", Reasons: result.Reasons}
+	}
+	if result.Redacted && audit != nil {
+		audit.LogGuardrail(ctx, tenantID, agentID, AuditActionOutputRedacted, result.Reasons)
+	}
+	return result.Content, nil
+}
+
+// =============================================================================
+// OpenAI Moderator
+// =============================================================================
+
+// OpenAIModerator classifies content with the OpenAI moderation API
+type OpenAIModerator struct {
+	client *openai.Client
+}
+
+// NewOpenAIModerator creates a moderator using the given OpenAI API key
+func NewOpenAIModerator(apiKey string) *OpenAIModerator {
+	return &OpenAIModerator{client: openai.NewClient(apiKey)}
+}
+
+// Moderate returns the categories OpenAI flagged the text for, sorted
+func (m *OpenAIModerator) Moderate(ctx context.Context, text string) ([]string, error) {
+	resp, err := m.client.Moderations(ctx, openai.ModerationRequest{
+		Input: text,
+		Model: openai.ModerationOmniLatest,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var flagged []string
+	for _, result := range resp.Results {
+		if !result.Flagged {
+			continue
+		}
+		// The categories are struct fields; their JSON names are the API's
+		// category names
+		data, err := json.Marshal(result.Categories)
+		if err != nil {
+			return nil, err
+		}
+		var categories map[string]bool
+		if err := json.Unmarshal(data, &categories); err != nil {
+			return nil, err
+		}
+		for category, set := range categories {
+			if set {
+				flagged = append(flagged, category)
+			}
+		}
+		if len(flagged) == 0 {
+			flagged = append(flagged, "unspecified")
+		}
+	}
+	sort.Strings(flagged)
+	return flagged, nil
+}
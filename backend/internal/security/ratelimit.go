@@ -0,0 +1,70 @@
+package security
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// =============================================================================
+// Shared Rate Limits
+// =============================================================================
+
+// rateLimitKeyPrefix namespaces rate limit buckets in Redis
+const rateLimitKeyPrefix = "ratelimit:"
+
+// tokenBucketScript takes a token from the bucket in KEYS[1], which holds
+// ARGV[1] tokens and refills over ARGV[2] milliseconds. Redis's clock is used
+// so every instance refills alike. The bucket expires once idle for its whole
+// window, when it would be full again anyway. It returns whether the request
+// is allowed, the tokens left and the milliseconds until the next one.
+var tokenBucketScript = redis.NewScript(`
+local max = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local rate = max / window
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'filled')
+local tokens = tonumber(state[1]) or max
+local filled = tonumber(state[2]) or now
+tokens = math.min(max, tokens + math.max(0, now - filled) * rate)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'filled', now)
+redis.call('PEXPIRE', KEYS[1], window)
+return {allowed, math.floor(tokens), retry}
+`)
+
+// SetRedis shares buckets between instances through Redis, so a limit holds
+// however requests are spread across them
+func (r *RateLimiter) SetRedis(client *redis.Client) {
+	r.redis = client
+}
+
+// checkRedis takes a token from the key's bucket in Redis
+func (r *RateLimiter) checkRedis(ctx context.Context, key string, maxRequests int, window time.Duration) (RateLimitResult, error) {
+	values, err := tokenBucketScript.Run(ctx, r.redis, []string{rateLimitKeyPrefix + key}, maxRequests, window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	if len(values) != 3 {
+		return RateLimitResult{}, fmt.Errorf("unexpected rate limit result %v", values)
+	}
+	return RateLimitResult{
+		Allowed:    values[0] == 1,
+		Limit:      maxRequests,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,98 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"syscall"
+)
+
+// =============================================================================
+// Outbound Request Guard
+// =============================================================================
+
+// ErrNonPublicAddress is returned when a request to a tenant-supplied URL
+// would reach a loopback, private, link-local or otherwise internal address
+var ErrNonPublicAddress = errors.New("address is not public")
+
+// nonPublicPrefixes are the reserved ranges net.IP has no predicate for
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"),  // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),  // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),    // reserved
+	netip.MustParsePrefix("64:ff9b::/96"),   // NAT64, which can reach IPv4 internals
+	netip.MustParsePrefix("64:ff9b:1::/48"), // local-use NAT64
+	netip.MustParsePrefix("2001:db8::/32"),  // documentation
+	netip.MustParsePrefix("fec0::/10"),      // deprecated site-local
+}
+
+// IsPublicIP reports whether ip is a public unicast address, one a request
+// to a tenant-supplied URL may reach
+func IsPublicIP(ip net.IP) bool {
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// PublicDialer returns a dialer that refuses to connect to addresses that
+// aren't public. The address is checked as the connection is made, after DNS
+// resolution, so a hostname can't pass validation and then resolve to an
+// internal address.
+func PublicDialer(dialer *net.Dialer) *net.Dialer {
+	d := *dialer
+	d.Control = func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		if ip := net.ParseIP(host); ip == nil || !IsPublicIP(ip) {
+			return fmt.Errorf("%w: %s", ErrNonPublicAddress, host)
+		}
+		return nil
+	}
+	return &d
+}
+
+// ValidatePublicURL checks that every address the URL's host resolves to is
+// public, so configuration pointing at internal services is rejected when it
+// is saved. Requests must still go through a PublicDialer, as DNS can change.
+func ValidatePublicURL(ctx context.Context, u *url.URL) error {
+	host := u.Hostname()
+	if host == "" {
+		return fmt.Errorf("url has no host")
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if !IsPublicIP(ip) {
+			return fmt.Errorf("%w: %s", ErrNonPublicAddress, host)
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if !IsPublicIP(addr.IP) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrNonPublicAddress, host, addr.IP)
+		}
+	}
+	return nil
+}
@@ -3,12 +3,15 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/delphi-platform/delphi/backend/internal/config"
 	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/providers"
 	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/internal/security"
 	"github.com/delphi-platform/delphi/backend/pkg/logger"
 	"github.com/google/uuid"
 )
@@ -42,26 +45,21 @@ type CreateAgentRequest struct {
 	Tools          json.RawMessage     `json:"tools"`
 	KnowledgeBases []uuid.UUID         `json:"knowledge_bases"`
 	Config         models.AgentConfig  `json:"config"`
+
+	// Strict rejects config values over their limits instead of lowering
+	// them; see CheckAgentConfigBounds
+	Strict bool `json:"-"`
 }
 
-// Create creates a new agent
+// Create validates and creates a new agent
 func (s *AgentService) Create(ctx context.Context, tenantID uuid.UUID, req *CreateAgentRequest) (*models.Agent, error) {
 	now := time.Now()
 
-	// Set defaults for config if not provided
-	if req.Config.Temperature == 0 {
-		req.Config.Temperature = 0.7
-	}
-	if req.Config.MaxTokens == 0 {
-		req.Config.MaxTokens = 4096
-	}
-	if req.Config.TimeoutSeconds == 0 {
-		req.Config.TimeoutSeconds = 300
-	}
-	if req.Config.BriefingDepth == "" {
-		req.Config.BriefingDepth = "standard"
+	validation, err := s.Validate(ctx, tenantID, req)
+	if err != nil {
+		return nil, err
 	}
-	req.Config.BriefingRequired = true // Always require briefing
+	req = validation.Agent
 
 	agent := &models.Agent{
 		ID:             uuid.New(),
@@ -78,6 +76,7 @@ func (s *AgentService) Create(ctx context.Context, tenantID uuid.UUID, req *Crea
 		Status:         models.AgentStatusConfigured,
 		CreatedAt:      now,
 		UpdatedAt:      now,
+		Warnings:       validation.Warnings,
 	}
 
 	if err := s.repos.Agents.Create(ctx, agent); err != nil {
@@ -113,6 +112,8 @@ func (s *AgentService) Update(ctx context.Context, tenantID, agentID uuid.UUID,
 		return nil, err
 	}
 
+	systemPrompt, previousModel := agent.SystemPrompt, agent.Model
+
 	// Apply updates
 	if name, ok := updates["name"].(string); ok {
 		agent.Name = name
@@ -135,6 +136,32 @@ func (s *AgentService) Update(ctx context.Context, tenantID, agentID uuid.UUID,
 	if configData, ok := updates["config"].(map[string]interface{}); ok {
 		configJSON, _ := json.Marshal(configData)
 		json.Unmarshal(configJSON, &agent.Config)
+		if err := security.ValidateGuardrailConfig(agent.Config.Guardrails); err != nil {
+			return nil, err
+		}
+		if err := CheckPullRequestConfig(agent.Type, agent.Config.PullRequests); err != nil {
+			return nil, err
+		}
+		boundsWarnings, err := CheckAgentConfigBounds(agent.Model, &agent.Config, AgentLimits{MaxTimeoutSeconds: s.cfg.MaxRunTimeoutSeconds})
+		if err != nil {
+			return nil, err
+		}
+		agent.Warnings = append(agent.Warnings, boundsWarnings...)
+	}
+
+	// An agent left on a retired model can still be edited; it is only
+	// checked when it moves to another model
+	if agent.Model != previousModel {
+		modelWarning, err := providers.CheckModel(agent.Model)
+		if err != nil {
+			return nil, err
+		}
+		if modelWarning != "" {
+			agent.Warnings = append(agent.Warnings, modelWarning)
+		}
+	}
+	if err := CheckAgentCapabilities(agent.Model, agent.Tools, agent.Config); err != nil {
+		return nil, err
 	}
 
 	agent.UpdatedAt = time.Now()
@@ -143,6 +170,13 @@ func (s *AgentService) Update(ctx context.Context, tenantID, agentID uuid.UUID,
 		return nil, fmt.Errorf("failed to update agent: %w", err)
 	}
 
+	// Responses cached under the old system prompt no longer apply
+	if agent.SystemPrompt != systemPrompt {
+		if err := invalidateResponseCache(ctx, s.redis, agent.ID); err != nil {
+			s.log.Warnw("failed to invalidate response cache", "agent_id", agent.ID, "error", err)
+		}
+	}
+
 	return agent, nil
 }
 
@@ -258,8 +292,22 @@ func (s *AgentService) Terminate(ctx context.Context, tenantID, agentID uuid.UUI
 	return agent, nil
 }
 
-// ListRuns returns runs for an agent
-func (s *AgentService) ListRuns(ctx context.Context, tenantID, agentID uuid.UUID, limit int) ([]*models.AgentRun, error) {
+// Run listing page sizes
+const (
+	defaultRunPageSize = 50
+	maxRunPageSize     = 200
+)
+
+// RunPage is one page of runs, newest first
+type RunPage struct {
+	Items      []*models.AgentRun `json:"items"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+	Total      int                `json:"total"`
+}
+
+// ListRuns returns a page of runs for an agent. Pass the previous page's
+// NextCursor to continue; an empty cursor starts from the newest run.
+func (s *AgentService) ListRuns(ctx context.Context, tenantID, agentID uuid.UUID, cursor string, limit int) (*RunPage, error) {
 	// Verify agent belongs to tenant
 	_, err := s.Get(ctx, tenantID, agentID)
 	if err != nil {
@@ -267,10 +315,29 @@ func (s *AgentService) ListRuns(ctx context.Context, tenantID, agentID uuid.UUID
 	}
 
 	if limit <= 0 {
-		limit = 50
+		limit = defaultRunPageSize
+	}
+	if limit > maxRunPageSize {
+		limit = maxRunPageSize
+	}
+
+	runs, next, err := s.repos.AgentRuns.ListByAgent(ctx, agentID, cursor, limit)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to list runs: %w", err)
 	}
 
-	return s.repos.AgentRuns.ListByAgent(ctx, agentID, limit)
+	total, err := s.repos.AgentRuns.CountByAgent(ctx, agentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count runs: %w", err)
+	}
+
+	if runs == nil {
+		runs = []*models.AgentRun{}
+	}
+	return &RunPage{Items: runs, NextCursor: next, Total: total}, nil
 }
 
 // GetRun returns a specific run
@@ -292,10 +359,9 @@ func (s *AgentService) GetRun(ctx context.Context, tenantID, agentID, runID uuid
 	return run, nil
 }
 
-// GetTemplates returns available agent templates
-func (s *AgentService) GetTemplates(ctx context.Context) ([]*models.AgentTemplate, error) {
-	// Return predefined templates
-	templates := []*models.AgentTemplate{
+// builtinTemplates returns the predefined public agent templates
+func builtinTemplates() []*models.AgentTemplate {
+	return []*models.AgentTemplate{
 		{
 			ID:          uuid.MustParse("00000000-0000-0000-0000-000000000001"),
 			Name:        "Full-Stack Developer",
@@ -306,7 +372,7 @@ func (s *AgentService) GetTemplates(ctx context.Context) ([]*models.AgentTemplat
 You are proficient in React, TypeScript, Node.js, Go, Python, and SQL. 
 Always commit to the dev branch and create descriptive commit messages.`,
 			DefaultConfig: models.AgentConfig{
-				Temperature:      0.3,
+				Temperature:      models.Float64(0.3),
 				MaxTokens:        8192,
 				TimeoutSeconds:   600,
 				BriefingRequired: true,
@@ -323,7 +389,7 @@ Always commit to the dev branch and create descriptive commit messages.`,
 			SystemPrompt: `You are a senior business strategist with expertise in market analysis, competitive intelligence, and growth strategy.
 You provide data-driven insights and actionable recommendations.`,
 			DefaultConfig: models.AgentConfig{
-				Temperature:      0.7,
+				Temperature:      models.Float64(0.7),
 				MaxTokens:        4096,
 				TimeoutSeconds:   300,
 				BriefingRequired: true,
@@ -340,7 +406,7 @@ You provide data-driven insights and actionable recommendations.`,
 			SystemPrompt: `You are a financial analyst and accountant. You analyze financial data, categorize transactions, 
 generate reports, and ensure compliance with accounting standards.`,
 			DefaultConfig: models.AgentConfig{
-				Temperature:      0.2,
+				Temperature:      models.Float64(0.2),
 				MaxTokens:        4096,
 				TimeoutSeconds:   300,
 				BriefingRequired: true,
@@ -357,7 +423,7 @@ generate reports, and ensure compliance with accounting standards.`,
 			SystemPrompt: `You are a creative marketing specialist. You create compelling content for social media, 
 blogs, email campaigns, and advertisements. You understand SEO and audience engagement.`,
 			DefaultConfig: models.AgentConfig{
-				Temperature:      0.8,
+				Temperature:      models.Float64(0.8),
 				MaxTokens:        2048,
 				TimeoutSeconds:   180,
 				BriefingRequired: true,
@@ -374,7 +440,7 @@ blogs, email campaigns, and advertisements. You understand SEO and audience enga
 			SystemPrompt: `You are a DevOps engineer specializing in infrastructure as code, CI/CD pipelines, 
 containerization, and cloud services (AWS, GCP, Fly.io). You prioritize security and reliability.`,
 			DefaultConfig: models.AgentConfig{
-				Temperature:      0.3,
+				Temperature:      models.Float64(0.3),
 				MaxTokens:        4096,
 				TimeoutSeconds:   300,
 				BriefingRequired: true,
@@ -391,7 +457,7 @@ containerization, and cloud services (AWS, GCP, Fly.io). You prioritize security
 			SystemPrompt: `You are an experienced product manager. You help define product vision, prioritize features, 
 analyze user feedback, and create product specifications. You think strategically about product-market fit.`,
 			DefaultConfig: models.AgentConfig{
-				Temperature:      0.6,
+				Temperature:      models.Float64(0.6),
 				MaxTokens:        4096,
 				TimeoutSeconds:   300,
 				BriefingRequired: true,
@@ -408,7 +474,7 @@ analyze user feedback, and create product specifications. You think strategicall
 			SystemPrompt: `You are a professional executive assistant. You help manage communications, 
 draft emails, organize schedules, and handle administrative tasks efficiently and professionally.`,
 			DefaultConfig: models.AgentConfig{
-				Temperature:      0.5,
+				Temperature:      models.Float64(0.5),
 				MaxTokens:        2048,
 				TimeoutSeconds:   180,
 				BriefingRequired: true,
@@ -425,7 +491,7 @@ draft emails, organize schedules, and handle administrative tasks efficiently an
 			SystemPrompt: `You are a security expert specializing in code review and vulnerability assessment. 
 You identify security issues, suggest fixes, and ensure code follows security best practices.`,
 			DefaultConfig: models.AgentConfig{
-				Temperature:      0.2,
+				Temperature:      models.Float64(0.2),
 				MaxTokens:        8192,
 				TimeoutSeconds:   600,
 				BriefingRequired: true,
@@ -434,7 +500,71 @@ You identify security issues, suggest fixes, and ensure code follows security be
 			IsPublic: true,
 		},
 	}
+}
 
-	return templates, nil
+// CreateFromTemplateRequest overrides parts of a template when creating an
+// agent from it. Empty fields take the template's values, or for the provider
+// and model the tenant's defaults.
+type CreateFromTemplateRequest struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Provider    models.AIProvider `json:"provider"`
+	Model       string            `json:"model"`
+}
+
+// CreateFromTemplate creates an agent with a template's type, system prompt,
+// default config and tools
+func (s *AgentService) CreateFromTemplate(ctx context.Context, tenantID, templateID uuid.UUID, req *CreateFromTemplateRequest) (*models.Agent, error) {
+	template, err := s.GetTemplate(ctx, tenantID, templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	create := &CreateAgentRequest{
+		Name:         req.Name,
+		Description:  req.Description,
+		Type:         template.Type,
+		Provider:     req.Provider,
+		Model:        req.Model,
+		SystemPrompt: template.SystemPrompt,
+		Tools:        template.Tools,
+		Config:       template.DefaultConfig,
+	}
+	if create.Name == "" {
+		create.Name = template.Name
+	}
+	if create.Description == "" {
+		create.Description = template.Description
+	}
+
+	if create.Provider == "" {
+		tenant, err := s.repos.Tenants.GetByID(ctx, tenantID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tenant: %w", err)
+		}
+		if tenant == nil {
+			return nil, fmt.Errorf("tenant not found")
+		}
+		provider, model, err := TenantDefaultModel(tenant)
+		if err != nil {
+			return nil, err
+		}
+		create.Provider = provider
+		if create.Model == "" {
+			create.Model = model
+		}
+	}
+	if create.Model == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+
+	agent, err := s.Create(ctx, tenantID, create)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Infow("agent created from template", "agent_id", agent.ID, "tenant_id", tenantID, "template_id", templateID)
+
+	return agent, nil
 }
 
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/google/uuid"
+)
+
+// AgentManifestVersion is the manifest format written by Export and the only
+// one Import accepts
+const AgentManifestVersion = 1
+
+// ErrAgentExists is returned when importing an agent into a tenant that
+// already has an agent of the same name
+var ErrAgentExists = errors.New("an agent with this name already exists")
+
+// AgentManifest is a portable description of an agent for moving it between
+// tenants and environments. It carries none of the source tenant's IDs;
+// knowledge bases are referenced by name and matched in the target tenant.
+type AgentManifest struct {
+	Version        int                     `json:"version"`
+	ExportedAt     time.Time               `json:"exported_at"`
+	Name           string                  `json:"name"`
+	Description    string                  `json:"description"`
+	Type           models.AgentType        `json:"type"`
+	Provider       models.AIProvider       `json:"provider"`
+	Model          string                  `json:"model"`
+	SystemPrompt   string                  `json:"system_prompt"`
+	Tools          json.RawMessage         `json:"tools,omitempty"`
+	KnowledgeBases []ManifestKnowledgeBase `json:"knowledge_bases,omitempty"`
+	Config         models.AgentConfig      `json:"config"`
+}
+
+// ManifestKnowledgeBase references a knowledge base an exported agent used
+type ManifestKnowledgeBase struct {
+	Name string `json:"name"`
+}
+
+// NewAgentManifest describes agent as a manifest. knowledgeBases are the
+// exporting tenant's knowledge bases, used to name the ones the agent uses;
+// any it can't name are left out.
+func NewAgentManifest(agent *models.Agent, knowledgeBases []*models.KnowledgeBase) *AgentManifest {
+	names := make(map[uuid.UUID]string, len(knowledgeBases))
+	for _, kb := range knowledgeBases {
+		names[kb.ID] = kb.Name
+	}
+
+	manifest := &AgentManifest{
+		Version:      AgentManifestVersion,
+		ExportedAt:   time.Now().UTC(),
+		Name:         agent.Name,
+		Description:  agent.Description,
+		Type:         agent.Type,
+		Provider:     agent.Provider,
+		Model:        agent.Model,
+		SystemPrompt: agent.SystemPrompt,
+		Tools:        agent.Tools,
+		Config:       agent.Config,
+	}
+	for _, id := range agent.KnowledgeBases {
+		if name, ok := names[id]; ok {
+			manifest.KnowledgeBases = append(manifest.KnowledgeBases, ManifestKnowledgeBase{Name: name})
+		}
+	}
+	return manifest
+}
+
+// Validate checks the manifest version and required fields
+func (m *AgentManifest) Validate() error {
+	if m.Version != AgentManifestVersion {
+		return fmt.Errorf("unsupported manifest version %d, expected %d", m.Version, AgentManifestVersion)
+	}
+	if m.Name == "" {
+		return fmt.Errorf("manifest name is required")
+	}
+	if m.Provider == "" || m.Model == "" {
+		return fmt.Errorf("manifest provider and model are required")
+	}
+	if len(m.Tools) > 0 && !json.Valid(m.Tools) {
+		return fmt.Errorf("manifest tools must be valid JSON")
+	}
+	return nil
+}
+
+// CreateRequest turns the manifest into a request to create the agent in a
+// tenant with the given knowledge bases. A referenced knowledge base is mapped
+// to the tenant's knowledge base of the same name, and dropped if there is
+// none. Each drop is reported in the returned warnings.
+func (m *AgentManifest) CreateRequest(knowledgeBases []*models.KnowledgeBase) (*CreateAgentRequest, []string) {
+	byName := make(map[string]uuid.UUID, len(knowledgeBases))
+	for _, kb := range knowledgeBases {
+		byName[kb.Name] = kb.ID
+	}
+
+	kbIDs := []uuid.UUID{}
+	seen := make(map[uuid.UUID]bool)
+	var warnings []string
+	for _, ref := range m.KnowledgeBases {
+		id, ok := byName[ref.Name]
+		if !ok {
+			warnings = append(warnings, fmt.Sprintf("knowledge base %q does not exist in this tenant and was dropped", ref.Name))
+			continue
+		}
+		if !seen[id] {
+			seen[id] = true
+			kbIDs = append(kbIDs, id)
+		}
+	}
+
+	tools := m.Tools
+	if len(tools) == 0 {
+		tools = json.RawMessage("[]")
+	}
+
+	return &CreateAgentRequest{
+		Name:           m.Name,
+		Description:    m.Description,
+		Type:           m.Type,
+		Provider:       m.Provider,
+		Model:          m.Model,
+		SystemPrompt:   m.SystemPrompt,
+		Tools:          tools,
+		KnowledgeBases: kbIDs,
+		Config:         m.Config,
+	}, warnings
+}
+
+// Export returns a manifest of one of the tenant's agents
+func (s *AgentService) Export(ctx context.Context, tenantID, agentID uuid.UUID) (*AgentManifest, error) {
+	agent, err := s.Get(ctx, tenantID, agentID)
+	if err != nil {
+		return nil, err
+	}
+
+	knowledgeBases, err := s.repos.Knowledge.ListBasesByTenant(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list knowledge bases: %w", err)
+	}
+
+	return NewAgentManifest(agent, knowledgeBases), nil
+}
+
+// Import creates an agent in the tenant from a manifest. Knowledge bases the
+// tenant lacks are dropped and reported in the agent's warnings. It fails with
+// ErrAgentExists if the tenant already has an agent of the manifest's name, as
+// when a manifest is imported twice.
+func (s *AgentService) Import(ctx context.Context, tenantID uuid.UUID, manifest *AgentManifest) (*models.Agent, error) {
+	if err := manifest.Validate(); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repos.Agents.ListByTenant(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agents: %w", err)
+	}
+	for _, agent := range existing {
+		if agent.Name == manifest.Name {
+			return nil, ErrAgentExists
+		}
+	}
+
+	knowledgeBases, err := s.repos.Knowledge.ListBasesByTenant(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list knowledge bases: %w", err)
+	}
+
+	req, warnings := manifest.CreateRequest(knowledgeBases)
+	agent, err := s.Create(ctx, tenantID, req)
+	if err != nil {
+		return nil, err
+	}
+	agent.Warnings = append(agent.Warnings, warnings...)
+
+	s.log.Infow("agent imported", "agent_id", agent.ID, "tenant_id", tenantID, "dropped_knowledge_bases", len(warnings))
+
+	return agent, nil
+}
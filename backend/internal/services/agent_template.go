@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/google/uuid"
+)
+
+// ErrTemplateOwnerRequired is returned when someone other than a tenant owner
+// publishes a template or deletes a public one
+var ErrTemplateOwnerRequired = errors.New("only tenant owners can publish or delete public templates")
+
+// CreateTemplateRequest describes a tenant template
+type CreateTemplateRequest struct {
+	Name         string             `json:"name"`
+	Description  string             `json:"description"`
+	Type         models.AgentType   `json:"type"`
+	Category     string             `json:"category"`
+	SystemPrompt string             `json:"system_prompt"`
+	Config       models.AgentConfig `json:"config"`
+	Tools        json.RawMessage    `json:"tools"`
+	IsPublic     bool               `json:"is_public"`
+}
+
+// SaveAsTemplateRequest names a template saved from an existing agent. Empty
+// fields take the agent's values.
+type SaveAsTemplateRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Category    string `json:"category"`
+	IsPublic    bool   `json:"is_public"`
+}
+
+// GetTemplates returns the built-in templates followed by the tenant's own
+// templates and those other tenants have made public
+func (s *AgentService) GetTemplates(ctx context.Context, tenantID uuid.UUID) ([]*models.AgentTemplate, error) {
+	saved, err := s.repos.Templates.ListVisible(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+	return append(builtinTemplates(), saved...), nil
+}
+
+// GetTemplate returns a built-in template or a saved template visible to the
+// tenant
+func (s *AgentService) GetTemplate(ctx context.Context, tenantID, templateID uuid.UUID) (*models.AgentTemplate, error) {
+	for _, template := range builtinTemplates() {
+		if template.ID == templateID {
+			return template, nil
+		}
+	}
+
+	template, err := s.repos.Templates.GetByID(ctx, templateID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get template: %w", err)
+	}
+	// Other tenants' private templates are reported missing, so their IDs
+	// cannot be probed
+	if template == nil || (!template.IsPublic && !ownsTemplate(template, tenantID)) {
+		return nil, fmt.Errorf("template not found")
+	}
+	return template, nil
+}
+
+// CreateTemplate saves a template for the tenant. Only owners may make it
+// public.
+func (s *AgentService) CreateTemplate(ctx context.Context, tenantID, userID uuid.UUID, role models.UserRole, req *CreateTemplateRequest) (*models.AgentTemplate, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if req.Type == "" {
+		return nil, fmt.Errorf("type is required")
+	}
+	if req.IsPublic && role != models.RoleOwner {
+		return nil, ErrTemplateOwnerRequired
+	}
+
+	now := time.Now()
+	template := &models.AgentTemplate{
+		ID:            uuid.New(),
+		TenantID:      &tenantID,
+		CreatedBy:     &userID,
+		Name:          name,
+		Description:   req.Description,
+		Type:          req.Type,
+		SystemPrompt:  req.SystemPrompt,
+		DefaultConfig: req.Config,
+		Tools:         req.Tools,
+		Category:      req.Category,
+		IsPublic:      req.IsPublic,
+		CreatedAt:     &now,
+	}
+
+	if err := s.repos.Templates.Create(ctx, template); err != nil {
+		return nil, fmt.Errorf("failed to create template: %w", err)
+	}
+
+	s.log.Infow("agent template created", "template_id", template.ID, "tenant_id", tenantID, "public", template.IsPublic)
+
+	return template, nil
+}
+
+// SaveAsTemplate saves an agent's type, system prompt, config and tools as a
+// tenant template
+func (s *AgentService) SaveAsTemplate(ctx context.Context, tenantID, userID uuid.UUID, role models.UserRole, agentID uuid.UUID, req *SaveAsTemplateRequest) (*models.AgentTemplate, error) {
+	agent, err := s.Get(ctx, tenantID, agentID)
+	if err != nil {
+		return nil, err
+	}
+
+	create := &CreateTemplateRequest{
+		Name:         req.Name,
+		Description:  req.Description,
+		Type:         agent.Type,
+		Category:     req.Category,
+		SystemPrompt: agent.SystemPrompt,
+		Config:       agent.Config,
+		Tools:        agent.Tools,
+		IsPublic:     req.IsPublic,
+	}
+	if strings.TrimSpace(create.Name) == "" {
+		create.Name = agent.Name
+	}
+	if create.Description == "" {
+		create.Description = agent.Description
+	}
+
+	return s.CreateTemplate(ctx, tenantID, userID, role, create)
+}
+
+// DeleteTemplate deletes one of the tenant's templates. Built-in templates
+// cannot be deleted and public ones only by an owner.
+func (s *AgentService) DeleteTemplate(ctx context.Context, tenantID, templateID uuid.UUID, role models.UserRole) error {
+	template, err := s.repos.Templates.GetByID(ctx, templateID)
+	if err != nil {
+		return fmt.Errorf("failed to get template: %w", err)
+	}
+	if template == nil || !ownsTemplate(template, tenantID) {
+		return fmt.Errorf("template not found")
+	}
+	if template.IsPublic && role != models.RoleOwner {
+		return ErrTemplateOwnerRequired
+	}
+
+	if err := s.repos.Templates.Delete(ctx, templateID); err != nil {
+		return fmt.Errorf("failed to delete template: %w", err)
+	}
+
+	s.log.Infow("agent template deleted", "template_id", templateID, "tenant_id", tenantID)
+	return nil
+}
+
+// ownsTemplate reports whether a saved template belongs to the tenant
+func ownsTemplate(template *models.AgentTemplate, tenantID uuid.UUID) bool {
+	return template.TenantID != nil && *template.TenantID == tenantID
+}
@@ -0,0 +1,341 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/delphi-platform/delphi/backend/internal/execution"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/providers"
+	"github.com/delphi-platform/delphi/backend/internal/security"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxAgentTemperature is the highest sampling temperature providers accept
+	maxAgentTemperature = 2.0
+
+	// minAgentTimeoutSeconds leaves room for a briefing and a provider call
+	minAgentTimeoutSeconds = 10
+
+	// defaultAgentTimeoutSeconds is the run timeout of agents that don't set one
+	defaultAgentTimeoutSeconds = 300
+
+	// defaultAgentMaxTokens is the output budget of agents that don't set one
+	defaultAgentMaxTokens = 4096
+
+	// systemPromptShare is the share of a model's input room a system prompt
+	// may take before it leaves too little for briefings and knowledge
+	systemPromptShare = 0.5
+)
+
+// FieldError is a problem with one field of an agent's config
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists the fields of an agent's config that are out of
+// bounds. Its message joins theirs.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// AgentLimits bounds an agent's config on top of its model's limits
+type AgentLimits struct {
+	// MaxTimeoutSeconds caps timeout_seconds; 0 leaves it uncapped
+	MaxTimeoutSeconds int
+
+	// Strict rejects max_tokens and timeout_seconds outside their limits
+	// instead of bringing them within, with a warning
+	Strict bool
+}
+
+// AgentValidation is the outcome of validating an agent before it is created:
+// the request with defaults applied, and what would be reported as warnings
+type AgentValidation struct {
+	Agent    *CreateAgentRequest `json:"agent"`
+	Warnings []string            `json:"warnings"`
+}
+
+// Validate checks an agent as Create would, without saving it. On top of
+// ValidateAgentConfig it warns when the tenant has no way to call the
+// agent's provider yet.
+func (s *AgentService) Validate(ctx context.Context, tenantID uuid.UUID, req *CreateAgentRequest) (*AgentValidation, error) {
+	normalized := *req
+	warnings, err := ValidateAgentConfig(&normalized, AgentLimits{
+		MaxTimeoutSeconds: s.cfg.MaxRunTimeoutSeconds,
+		Strict:            req.Strict,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	warning, err := s.checkProviderAccess(ctx, tenantID, normalized.Provider)
+	if err != nil {
+		return nil, err
+	}
+	if warning != "" {
+		warnings = append(warnings, warning)
+	}
+
+	return &AgentValidation{Agent: &normalized, Warnings: append([]string{}, warnings...)}, nil
+}
+
+// ValidateAgentConfig checks an agent's provider, model, system prompt and
+// config, filling in defaults. Problems that would stop the agent from
+// running are errors; the rest are returned as warnings. Config values out of
+// bounds are reported together in a *ValidationError.
+func ValidateAgentConfig(req *CreateAgentRequest, limits AgentLimits) ([]string, error) {
+	var warnings []string
+
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if req.Model == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+
+	info, known := providers.DefaultPricing()[req.Model]
+	modelProvider, _ := providers.ModelProvider(req.Model)
+	if req.Provider == "" {
+		if !known {
+			return nil, fmt.Errorf("provider is required")
+		}
+		req.Provider = modelProvider
+	}
+	switch req.Provider {
+	case models.ProviderOpenAI, models.ProviderAnthropic, models.ProviderGoogle:
+		if !known {
+			warnings = append(warnings, fmt.Sprintf("model %s is not a known %s model; its limits and cost can't be checked", req.Model, req.Provider))
+		} else if modelProvider != req.Provider {
+			return nil, fmt.Errorf("model %s is served by %s, not %s", req.Model, modelProvider, req.Provider)
+		}
+	case models.ProviderOllama:
+		// Local models aren't priced, so there is nothing to check them against
+	default:
+		return nil, fmt.Errorf("unsupported provider: %s", req.Provider)
+	}
+
+	modelWarning, err := providers.CheckModel(req.Model)
+	if err != nil {
+		return nil, err
+	}
+	if modelWarning != "" {
+		warnings = append(warnings, modelWarning)
+	}
+	if err := CheckAgentCapabilities(req.Model, req.Tools, req.Config); err != nil {
+		return nil, err
+	}
+
+	if err := security.ValidateGuardrailConfig(req.Config.Guardrails); err != nil {
+		return nil, err
+	}
+	if err := CheckPullRequestConfig(req.Type, req.Config.PullRequests); err != nil {
+		return nil, err
+	}
+
+	// Check the config and fill in defaults for what is not provided
+	boundsWarnings, err := CheckAgentConfigBounds(req.Model, &req.Config, limits)
+	if err != nil {
+		return nil, err
+	}
+	warnings = append(warnings, boundsWarnings...)
+	if req.Config.Temperature == nil {
+		req.Config.Temperature = models.Float64(models.DefaultTemperature)
+	}
+	if req.Config.MaxTokens == 0 {
+		req.Config.MaxTokens = defaultAgentMaxTokens
+		if known && info.MaxOutput > 0 {
+			req.Config.MaxTokens = min(req.Config.MaxTokens, info.MaxOutput)
+		}
+	}
+	if req.Config.TimeoutSeconds == 0 {
+		req.Config.TimeoutSeconds = defaultAgentTimeoutSeconds
+		if limits.MaxTimeoutSeconds > 0 {
+			req.Config.TimeoutSeconds = min(req.Config.TimeoutSeconds, limits.MaxTimeoutSeconds)
+		}
+	}
+	if req.Config.BriefingDepth == "" {
+		req.Config.BriefingDepth = "standard"
+	}
+	req.Config.BriefingRequired = true // Always require briefing
+
+	if known && info.ContextWindow > 0 {
+		room := info.ContextWindow - req.Config.MaxTokens
+		tokens := providers.CountTokens(req.Model, req.SystemPrompt)
+		if tokens > room {
+			return nil, fmt.Errorf("system prompt is %d tokens, over the %d the %s context window has room for", tokens, room, req.Model)
+		}
+		if float64(tokens) > float64(room)*systemPromptShare {
+			warnings = append(warnings, fmt.Sprintf("system prompt takes %d of the %d tokens the %s context window has room for, leaving little for briefings and knowledge", tokens, room, req.Model))
+		}
+	}
+
+	return warnings, nil
+}
+
+// CheckPullRequestConfig checks that only coding agents open pull requests,
+// and that their branch names are valid git refs. nil is valid.
+func CheckPullRequestConfig(agentType models.AgentType, config *models.PullRequestConfig) error {
+	if config == nil {
+		return nil
+	}
+	if agentType != models.AgentTypeCoding {
+		return fmt.Errorf("only coding agents can open pull requests")
+	}
+	if config.RepositoryID == uuid.Nil {
+		return fmt.Errorf("pull_requests.repository_id is required")
+	}
+	prefix := config.BranchPrefix
+	if strings.HasPrefix(prefix, "/") || strings.HasPrefix(prefix, "-") || strings.Contains(prefix, "..") ||
+		strings.Contains(prefix, "//") || strings.ContainsAny(prefix, " ~^:?*[\\") {
+		return fmt.Errorf("pull_requests.branch_prefix %q is not a valid branch name", prefix)
+	}
+	return nil
+}
+
+// CheckAgentConfigBounds checks an agent's temperature, top_p, max_tokens
+// and timeout_seconds; zero values are left for defaults. Temperature and
+// top_p outside their ranges are always rejected. Unless limits are strict,
+// max_tokens over what the model can produce and timeout_seconds outside
+// [minAgentTimeoutSeconds, limits.MaxTimeoutSeconds] are brought within,
+// with a warning.
+func CheckAgentConfigBounds(model string, config *models.AgentConfig, limits AgentLimits) ([]string, error) {
+	var warnings []string
+	var fields []FieldError
+	reject := func(field, format string, args ...interface{}) {
+		fields = append(fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if t := config.Temperature; t != nil && (*t < 0 || *t > maxAgentTemperature) {
+		reject("temperature", "temperature must be between 0 and %g", maxAgentTemperature)
+	}
+	if config.TopP < 0 || config.TopP > 1 {
+		reject("top_p", "top_p must be between 0 and 1")
+	}
+
+	info, known := providers.DefaultPricing()[model]
+	switch {
+	case config.MaxTokens < 0:
+		reject("max_tokens", "max_tokens must not be negative")
+	case known && info.MaxOutput > 0 && config.MaxTokens > info.MaxOutput:
+		if limits.Strict {
+			reject("max_tokens", "max_tokens must be at most %d, the most %s can produce", info.MaxOutput, model)
+			break
+		}
+		warnings = append(warnings, fmt.Sprintf("max_tokens %d is more than %s can produce; lowered to %d", config.MaxTokens, model, info.MaxOutput))
+		config.MaxTokens = info.MaxOutput
+	}
+
+	switch {
+	case config.TimeoutSeconds < 0:
+		reject("timeout_seconds", "timeout_seconds must not be negative")
+	case config.TimeoutSeconds > 0 && config.TimeoutSeconds < minAgentTimeoutSeconds:
+		if limits.Strict {
+			reject("timeout_seconds", "timeout_seconds must be at least %d", minAgentTimeoutSeconds)
+			break
+		}
+		warnings = append(warnings, fmt.Sprintf("timeout_seconds %d is under the minimum; raised to %d", config.TimeoutSeconds, minAgentTimeoutSeconds))
+		config.TimeoutSeconds = minAgentTimeoutSeconds
+	case limits.MaxTimeoutSeconds > 0 && config.TimeoutSeconds > limits.MaxTimeoutSeconds:
+		if limits.Strict {
+			reject("timeout_seconds", "timeout_seconds must be at most %d", limits.MaxTimeoutSeconds)
+			break
+		}
+		warnings = append(warnings, fmt.Sprintf("timeout_seconds %d is over the server's maximum; lowered to %d", config.TimeoutSeconds, limits.MaxTimeoutSeconds))
+		config.TimeoutSeconds = limits.MaxTimeoutSeconds
+	}
+
+	if len(fields) > 0 {
+		return nil, &ValidationError{Fields: fields}
+	}
+	return warnings, nil
+}
+
+// RequiredCapabilities returns the model capabilities an agent needs: those
+// its config asks for, and function_calling when it has tools
+func RequiredCapabilities(tools json.RawMessage, config models.AgentConfig) ([]string, error) {
+	var required []string
+	for _, capability := range config.RequiredCapabilities {
+		if !providers.IsCapability(capability) {
+			return nil, fmt.Errorf("unknown capability: %s", capability)
+		}
+		if !slices.Contains(required, capability) {
+			required = append(required, capability)
+		}
+	}
+
+	names, err := execution.AgentToolNames(tools)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) > 0 && !slices.Contains(required, providers.CapabilityFunctionCalling) {
+		required = append(required, providers.CapabilityFunctionCalling)
+	}
+	return required, nil
+}
+
+// CheckAgentCapabilities checks that the agent's model, and each model of its
+// fallback chain, has the capabilities the agent needs
+func CheckAgentCapabilities(model string, tools json.RawMessage, config models.AgentConfig) error {
+	required, err := RequiredCapabilities(tools, config)
+	if err != nil {
+		return err
+	}
+	if len(required) == 0 {
+		return nil
+	}
+
+	if err := providers.CheckCapabilities(model, required); err != nil {
+		return err
+	}
+	for _, choice := range config.FallbackChain {
+		if err := providers.CheckCapabilities(choice.Model, required); err != nil {
+			return fmt.Errorf("fallback %w", err)
+		}
+	}
+	return nil
+}
+
+// checkProviderAccess returns a warning when neither the platform nor the
+// tenant has configured the provider, so the agent's runs would fail
+func (s *AgentService) checkProviderAccess(ctx context.Context, tenantID uuid.UUID, provider models.AIProvider) (string, error) {
+	platformKey := ""
+	switch provider {
+	case models.ProviderOpenAI:
+		platformKey = s.cfg.OpenAIAPIKey
+	case models.ProviderAnthropic:
+		platformKey = s.cfg.AnthropicAPIKey
+	case models.ProviderGoogle:
+		platformKey = s.cfg.GoogleAIAPIKey
+	case models.ProviderOllama:
+		if s.cfg.OllamaBaseURL == "" {
+			return "ollama is not configured on this platform; runs will fail until OLLAMA_BASE_URL is set", nil
+		}
+		return "", nil
+	}
+	if platformKey != "" {
+		return "", nil
+	}
+
+	key, err := s.repos.APIKeys.GetByTenantAndProvider(ctx, tenantID, provider)
+	if err != nil {
+		return "", fmt.Errorf("failed to get API key: %w", err)
+	}
+	if key == nil {
+		return fmt.Sprintf("no valid %s API key is configured; runs will fail until one is added", provider), nil
+	}
+	return "", nil
+}
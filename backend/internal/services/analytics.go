@@ -0,0 +1,268 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+)
+
+const (
+	apiUsageFlushInterval = 30 * time.Second
+	maxAPIUsageWindow     = 90 * 24 * time.Hour
+)
+
+// apiUsageKey identifies one hourly rollup
+type apiUsageKey struct {
+	tenantID uuid.UUID
+	bucket   time.Time
+	method   string
+	route    string
+	status   int
+}
+
+// APIUsageService records per-tenant API request summaries and reports on them.
+// Requests are aggregated in memory into hourly rollups and flushed periodically,
+// so recording never adds a database round trip to the request path.
+// Usage is per tenant only: requests authenticate with user tokens, and the
+// api_keys table holds provider credentials, not keys tenants call the API with.
+type APIUsageService struct {
+	repos *repository.Repositories
+	log   *logger.Logger
+
+	mu      sync.Mutex
+	pending map[apiUsageKey]*models.APIUsageRollup
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewAPIUsageService creates a new API usage service and starts its flush loop
+func NewAPIUsageService(repos *repository.Repositories, log *logger.Logger) *APIUsageService {
+	s := &APIUsageService{
+		repos:   repos,
+		log:     log,
+		pending: make(map[apiUsageKey]*models.APIUsageRollup),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go s.flushLoop()
+	return s
+}
+
+// RecordRequest adds a completed request to the tenant's usage rollups
+func (s *APIUsageService) RecordRequest(tenantID uuid.UUID, method, route string, status int, duration time.Duration) {
+	key := apiUsageKey{
+		tenantID: tenantID,
+		bucket:   time.Now().UTC().Truncate(time.Hour),
+		method:   method,
+		route:    route,
+		status:   status,
+	}
+	ms := duration.Milliseconds()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rollup, ok := s.pending[key]
+	if !ok {
+		rollup = &models.APIUsageRollup{
+			TenantID: key.tenantID,
+			Bucket:   key.bucket,
+			Method:   key.method,
+			Route:    key.route,
+			Status:   key.status,
+		}
+		s.pending[key] = rollup
+	}
+	rollup.RequestCount++
+	rollup.TotalDurationMs += ms
+	if ms > rollup.MaxDurationMs {
+		rollup.MaxDurationMs = ms
+	}
+}
+
+// Stop flushes pending rollups and stops the flush loop
+func (s *APIUsageService) Stop() {
+	close(s.stop)
+	<-s.done
+}
+
+func (s *APIUsageService) flushLoop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(apiUsageFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.flush(context.Background())
+		case <-s.stop:
+			s.flush(context.Background())
+			return
+		}
+	}
+}
+
+// flush writes pending rollups; on failure they are merged back to retry next time
+func (s *APIUsageService) flush(ctx context.Context) {
+	s.mu.Lock()
+	if len(s.pending) == 0 {
+		s.mu.Unlock()
+		return
+	}
+	batch := s.pending
+	s.pending = make(map[apiUsageKey]*models.APIUsageRollup)
+	s.mu.Unlock()
+
+	rollups := make([]*models.APIUsageRollup, 0, len(batch))
+	for _, rollup := range batch {
+		rollups = append(rollups, rollup)
+	}
+
+	if err := s.repos.APIUsage.UpsertRollups(ctx, rollups); err != nil {
+		s.log.Warnw("failed to flush api usage rollups", "rollups", len(rollups), "error", err)
+
+		s.mu.Lock()
+		for key, rollup := range batch {
+			if current, ok := s.pending[key]; ok {
+				current.RequestCount += rollup.RequestCount
+				current.TotalDurationMs += rollup.TotalDurationMs
+				if rollup.MaxDurationMs > current.MaxDurationMs {
+					current.MaxDurationMs = rollup.MaxDurationMs
+				}
+			} else {
+				s.pending[key] = rollup
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// EndpointUsage summarizes the requests to one endpoint
+type EndpointUsage struct {
+	Method       string  `json:"method"`
+	Route        string  `json:"route"`
+	Requests     int64   `json:"requests"`
+	Errors       int64   `json:"errors"`
+	ErrorRate    float64 `json:"error_rate"`
+	RateLimited  int64   `json:"rate_limited"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	MaxLatencyMs int64   `json:"max_latency_ms"`
+}
+
+// StatusUsage counts the requests that returned one status code
+type StatusUsage struct {
+	Status   int   `json:"status"`
+	Requests int64 `json:"requests"`
+}
+
+// APIUsageReport summarizes a tenant's API usage over a time window
+type APIUsageReport struct {
+	Since        time.Time       `json:"since"`
+	Until        time.Time       `json:"until"`
+	Requests     int64           `json:"requests"`
+	Errors       int64           `json:"errors"`
+	ErrorRate    float64         `json:"error_rate"`
+	RateLimited  int64           `json:"rate_limited"`
+	AvgLatencyMs float64         `json:"avg_latency_ms"`
+	MaxLatencyMs int64           `json:"max_latency_ms"`
+	ByEndpoint   []EndpointUsage `json:"by_endpoint"`
+	ByStatus     []StatusUsage   `json:"by_status"`
+}
+
+// GetUsage reports a tenant's API usage between since and until. Rollups are
+// hourly, so the window is widened to whole hours.
+func (s *APIUsageService) GetUsage(ctx context.Context, tenantID uuid.UUID, since, until time.Time) (*APIUsageReport, error) {
+	since = since.UTC().Truncate(time.Hour)
+	until = until.UTC().Truncate(time.Hour).Add(time.Hour)
+	if !until.After(since) {
+		return nil, fmt.Errorf("end must be after start")
+	}
+	if until.Sub(since) > maxAPIUsageWindow+time.Hour {
+		return nil, fmt.Errorf("window cannot exceed %d days", int(maxAPIUsageWindow.Hours()/24))
+	}
+
+	rollups, err := s.repos.APIUsage.ListRollups(ctx, tenantID, since, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api usage: %w", err)
+	}
+
+	// Include requests not flushed yet
+	s.mu.Lock()
+	for key, rollup := range s.pending {
+		if key.tenantID == tenantID && !key.bucket.Before(since) && key.bucket.Before(until) {
+			copied := *rollup
+			rollups = append(rollups, &copied)
+		}
+	}
+	s.mu.Unlock()
+
+	report := &APIUsageReport{Since: since, Until: until}
+	endpoints := make(map[string]*EndpointUsage)
+	statuses := make(map[int]int64)
+	var totalDuration int64
+
+	for _, rollup := range rollups {
+		key := rollup.Method + " " + rollup.Route
+		endpoint, ok := endpoints[key]
+		if !ok {
+			endpoint = &EndpointUsage{Method: rollup.Method, Route: rollup.Route}
+			endpoints[key] = endpoint
+		}
+
+		endpoint.Requests += rollup.RequestCount
+		endpoint.AvgLatencyMs += float64(rollup.TotalDurationMs) // summed here, averaged below
+		if rollup.MaxDurationMs > endpoint.MaxLatencyMs {
+			endpoint.MaxLatencyMs = rollup.MaxDurationMs
+		}
+		if rollup.Status >= 400 {
+			endpoint.Errors += rollup.RequestCount
+		}
+		if rollup.Status == 429 {
+			endpoint.RateLimited += rollup.RequestCount
+		}
+
+		statuses[rollup.Status] += rollup.RequestCount
+		totalDuration += rollup.TotalDurationMs
+	}
+
+	report.ByEndpoint = make([]EndpointUsage, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if endpoint.Requests > 0 {
+			endpoint.AvgLatencyMs /= float64(endpoint.Requests)
+			endpoint.ErrorRate = float64(endpoint.Errors) / float64(endpoint.Requests)
+		}
+		report.Requests += endpoint.Requests
+		report.Errors += endpoint.Errors
+		report.RateLimited += endpoint.RateLimited
+		if endpoint.MaxLatencyMs > report.MaxLatencyMs {
+			report.MaxLatencyMs = endpoint.MaxLatencyMs
+		}
+		report.ByEndpoint = append(report.ByEndpoint, *endpoint)
+	}
+	sort.Slice(report.ByEndpoint, func(i, j int) bool {
+		return report.ByEndpoint[i].Requests > report.ByEndpoint[j].Requests
+	})
+
+	report.ByStatus = make([]StatusUsage, 0, len(statuses))
+	for status, count := range statuses {
+		report.ByStatus = append(report.ByStatus, StatusUsage{Status: status, Requests: count})
+	}
+	sort.Slice(report.ByStatus, func(i, j int) bool {
+		return report.ByStatus[i].Status < report.ByStatus[j].Status
+	})
+
+	if report.Requests > 0 {
+		report.ErrorRate = float64(report.Errors) / float64(report.Requests)
+		report.AvgLatencyMs = float64(totalDuration) / float64(report.Requests)
+	}
+
+	return report, nil
+}
@@ -8,71 +8,127 @@ import (
 	"github.com/delphi-platform/delphi/backend/internal/models"
 	"github.com/delphi-platform/delphi/backend/internal/providers"
 	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/internal/security"
 	"github.com/delphi-platform/delphi/backend/pkg/crypto"
 	"github.com/delphi-platform/delphi/backend/pkg/logger"
 	"github.com/google/uuid"
 )
 
+const (
+	// apiKeyRevalidationInterval is how often stored keys due for a check are revalidated
+	apiKeyRevalidationInterval = time.Hour
+
+	// apiKeyRevalidationAge is how long a key goes between checks with its provider
+	apiKeyRevalidationAge = 24 * time.Hour
+
+	// maxKeysPerRevalidation bounds the keys checked in one pass
+	maxKeysPerRevalidation = 100
+
+	apiKeyValidationTimeout = 30 * time.Second
+)
+
 // APIKeyService handles API key operations (full implementation)
 type APIKeyServiceImpl struct {
 	repos     *repository.Repositories
 	encryptor *crypto.Encryptor
 	manager   *providers.Manager
+	audit     *AuditService
 	log       *logger.Logger
+
+	stop chan struct{}
+	done chan struct{}
 }
 
-// NewAPIKeyServiceImpl creates a new API key service
-func NewAPIKeyServiceImpl(repos *repository.Repositories, encryptor *crypto.Encryptor, manager *providers.Manager, log *logger.Logger) *APIKeyServiceImpl {
-	return &APIKeyServiceImpl{
+// NewAPIKeyServiceImpl creates a new API key service and starts revalidating
+// stored keys in the background
+func NewAPIKeyServiceImpl(repos *repository.Repositories, encryptor *crypto.Encryptor, manager *providers.Manager, audit *AuditService, log *logger.Logger) *APIKeyServiceImpl {
+	s := &APIKeyServiceImpl{
 		repos:     repos,
 		encryptor: encryptor,
 		manager:   manager,
+		audit:     audit,
 		log:       log,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
 	}
+	go s.revalidationLoop()
+	return s
+}
+
+// Stop stops revalidating keys, waiting for a pass in progress to finish
+func (s *APIKeyServiceImpl) Stop() {
+	close(s.stop)
+	<-s.done
 }
 
-// CreateAPIKeyRequest represents a request to create an API key
+// CreateAPIKeyRequest represents a request to create an API key. Keys are
+// checked with their provider before they are stored; Force stores a key that
+// fails the check, marked invalid.
 type CreateAPIKeyRequest struct {
 	Provider models.AIProvider `json:"provider"`
 	Name     string            `json:"name"`
 	Key      string            `json:"key"`
+	Force    bool              `json:"force"`
 }
 
-// Create creates a new API key
-func (s *APIKeyServiceImpl) Create(ctx context.Context, tenantID uuid.UUID, req *CreateAPIKeyRequest) (*models.APIKey, error) {
-	// Validate the key first
-	if err := s.validateKey(ctx, req.Provider, req.Key); err != nil {
-		return nil, fmt.Errorf("invalid API key: %w", err)
+// Create validates an API key with its provider and stores it encrypted
+func (s *APIKeyServiceImpl) Create(ctx context.Context, tenantID, userID uuid.UUID, req *CreateAPIKeyRequest) (*models.APIKey, error) {
+	if req.Key == "" {
+		return nil, fmt.Errorf("key is required")
+	}
+	if req.Name == "" {
+		req.Name = string(req.Provider)
 	}
 
-	// Encrypt the key
-	var encryptedKey string
-	var err error
-	if s.encryptor != nil {
-		encryptedKey, err = s.encryptor.Encrypt(req.Key)
-		if err != nil {
-			return nil, fmt.Errorf("failed to encrypt key: %w", err)
+	// Validate the key first. Keys for unsupported providers are never stored.
+	provider, err := s.manager.CreateProviderWithKey(req.Provider, req.Key, s.tenantEndpoint(ctx, tenantID, req.Provider))
+	if err != nil {
+		return nil, err
+	}
+	isValid := true
+	if err := provider.ValidateAPIKey(ctx, req.Key); err != nil {
+		if !req.Force {
+			return nil, err
 		}
-	} else {
-		// In development, store as-is (NOT FOR PRODUCTION)
-		encryptedKey = req.Key
+		isValid = false
+		s.log.Warnw("storing API key that failed validation", "tenant_id", tenantID, "provider", req.Provider, "error", err)
 	}
 
+	// Encrypt the key
+	encryptedKey, err := s.encryptor.Encrypt(req.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt key: %w", err)
+	}
+
+	now := time.Now()
 	apiKey := &models.APIKey{
-		ID:           uuid.New(),
-		TenantID:     tenantID,
-		Provider:     req.Provider,
-		Name:         req.Name,
-		EncryptedKey: encryptedKey,
-		IsValid:      true,
-		CreatedAt:    time.Now(),
+		ID:              uuid.New(),
+		TenantID:        tenantID,
+		Provider:        req.Provider,
+		Name:            req.Name,
+		EncryptedKey:    encryptedKey,
+		IsValid:         isValid,
+		LastValidatedAt: &now,
+		CreatedAt:       now,
 	}
 
 	if err := s.repos.APIKeys.Create(ctx, apiKey); err != nil {
 		return nil, fmt.Errorf("failed to create API key: %w", err)
 	}
 
-	s.log.Infow("API key created", "tenant_id", tenantID, "provider", req.Provider)
+	s.log.Infow("API key created", "tenant_id", tenantID, "provider", req.Provider, "is_valid", isValid)
+	s.audit.Log(ctx, &security.AuditEntry{
+		TenantID:     tenantID,
+		UserID:       &userID,
+		Action:       security.AuditActionAPIKeyCreated,
+		Severity:     security.SeverityCritical,
+		ResourceType: "api_key",
+		ResourceID:   &apiKey.ID,
+		Details: map[string]interface{}{
+			"provider": req.Provider,
+			"is_valid": isValid,
+		},
+	})
 
 	// Don't return the encrypted key
 	apiKey.EncryptedKey = ""
@@ -113,7 +169,7 @@ func (s *APIKeyServiceImpl) Delete(ctx context.Context, tenantID, keyID uuid.UUI
 	return nil
 }
 
-// Validate validates an API key
+// Validate checks a stored API key with its provider and records the result
 func (s *APIKeyServiceImpl) Validate(ctx context.Context, tenantID, keyID uuid.UUID) (bool, error) {
 	key, err := s.repos.APIKeys.GetByID(ctx, keyID)
 	if err != nil {
@@ -123,23 +179,98 @@ func (s *APIKeyServiceImpl) Validate(ctx context.Context, tenantID, keyID uuid.U
 		return false, fmt.Errorf("API key not found")
 	}
 
-	// Decrypt the key
-	var plainKey string
-	if s.encryptor != nil {
-		plainKey, err = s.encryptor.Decrypt(key.EncryptedKey)
-		if err != nil {
-			return false, fmt.Errorf("failed to decrypt key: %w", err)
-		}
-	} else {
-		plainKey = key.EncryptedKey
+	return s.revalidate(ctx, key)
+}
+
+// revalidate checks a stored key with its provider, records the result and
+// audits a change in validity
+func (s *APIKeyServiceImpl) revalidate(ctx context.Context, key *models.APIKey) (bool, error) {
+	plainKey, err := s.decrypt(key.EncryptedKey)
+	if err != nil {
+		return false, err
 	}
 
 	// Validate with provider
-	if err := s.validateKey(ctx, key.Provider, plainKey); err != nil {
-		return false, nil
+	err = s.validateKey(ctx, key.TenantID, key.Provider, plainKey)
+	if err != nil && ctx.Err() != nil {
+		return key.IsValid, fmt.Errorf("failed to validate key: %w", ctx.Err())
+	}
+	isValid := err == nil
+
+	if err := s.repos.APIKeys.UpdateValidity(ctx, key.ID, isValid, time.Now()); err != nil {
+		return isValid, fmt.Errorf("failed to update API key: %w", err)
+	}
+
+	if isValid != key.IsValid {
+		details := map[string]interface{}{
+			"provider": key.Provider,
+			"is_valid": isValid,
+		}
+		severity := security.SeverityInfo
+		if !isValid {
+			details["reason"] = err.Error()
+			severity = security.SeverityWarning
+		}
+		s.audit.Log(ctx, &security.AuditEntry{
+			TenantID:     key.TenantID,
+			Action:       security.AuditActionAPIKeyUpdated,
+			Severity:     severity,
+			ResourceType: "api_key",
+			ResourceID:   &key.ID,
+			Details:      details,
+		})
+		s.log.Infow("API key validity changed", "tenant_id", key.TenantID, "key_id", key.ID, "provider", key.Provider, "is_valid", isValid)
+	}
+
+	return isValid, nil
+}
+
+func (s *APIKeyServiceImpl) revalidationLoop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(apiKeyRevalidationInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.revalidateDue(context.Background())
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// revalidateDue checks the keys that have gone longest without a check
+func (s *APIKeyServiceImpl) revalidateDue(ctx context.Context) {
+	keys, err := s.repos.APIKeys.ListDueForValidation(ctx, time.Now().Add(-apiKeyRevalidationAge), maxKeysPerRevalidation)
+	if err != nil {
+		s.log.Errorw("failed to list API keys for revalidation", "error", err)
+		return
 	}
 
-	return true, nil
+	for _, key := range keys {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+
+		keyCtx, cancel := context.WithTimeout(ctx, apiKeyValidationTimeout)
+		_, err := s.revalidate(keyCtx, key)
+		cancel()
+		if err != nil {
+			s.log.Warnw("failed to revalidate API key", "key_id", key.ID, "error", err)
+		}
+	}
+}
+
+func (s *APIKeyServiceImpl) decrypt(encryptedKey string) (string, error) {
+	plainKey, err := s.encryptor.Decrypt(encryptedKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt key: %w", err)
+	}
+	return plainKey, nil
 }
 
 // GetDecryptedKey retrieves and decrypts an API key
@@ -152,15 +283,9 @@ func (s *APIKeyServiceImpl) GetDecryptedKey(ctx context.Context, tenantID uuid.U
 		return "", fmt.Errorf("no API key found for provider: %s", provider)
 	}
 
-	// Decrypt the key
-	var plainKey string
-	if s.encryptor != nil {
-		plainKey, err = s.encryptor.Decrypt(key.EncryptedKey)
-		if err != nil {
-			return "", fmt.Errorf("failed to decrypt key: %w", err)
-		}
-	} else {
-		plainKey = key.EncryptedKey
+	plainKey, err := s.decrypt(key.EncryptedKey)
+	if err != nil {
+		return "", err
 	}
 
 	// Update last used
@@ -171,19 +296,20 @@ func (s *APIKeyServiceImpl) GetDecryptedKey(ctx context.Context, tenantID uuid.U
 	return plainKey, nil
 }
 
-// GetProviderForTenant creates a provider instance for a tenant
-func (s *APIKeyServiceImpl) GetProviderForTenant(ctx context.Context, tenantID uuid.UUID, providerName models.AIProvider, baseURL string) (providers.Provider, error) {
+// GetProviderForTenant creates a provider instance for a tenant, sending
+// requests to the tenant's endpoint for the provider if it has set one
+func (s *APIKeyServiceImpl) GetProviderForTenant(ctx context.Context, tenantID uuid.UUID, providerName models.AIProvider) (providers.Provider, error) {
 	apiKey, err := s.GetDecryptedKey(ctx, tenantID, providerName)
 	if err != nil {
 		return nil, err
 	}
 
-	return s.manager.CreateProviderWithKey(providerName, apiKey, baseURL)
+	return s.manager.CreateProviderWithKey(providerName, apiKey, s.tenantEndpoint(ctx, tenantID, providerName))
 }
 
-// validateKey validates an API key with the provider
-func (s *APIKeyServiceImpl) validateKey(ctx context.Context, providerName models.AIProvider, key string) error {
-	provider, err := s.manager.CreateProviderWithKey(providerName, key, "")
+// validateKey validates a tenant's API key with the provider
+func (s *APIKeyServiceImpl) validateKey(ctx context.Context, tenantID uuid.UUID, providerName models.AIProvider, key string) error {
+	provider, err := s.manager.CreateProviderWithKey(providerName, key, s.tenantEndpoint(ctx, tenantID, providerName))
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/metrics"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/internal/security"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// Audit log page sizes
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 500
+
+	// auditExportPageSize is how many entries an export reads at a time
+	auditExportPageSize = 500
+)
+
+// AuditService handles audit log operations
+type AuditService struct {
+	audit *security.AuditService
+	log   *logger.Logger
+}
+
+// NewAuditService creates an audit service storing entries in Postgres
+func NewAuditService(repos *repository.Repositories, log *logger.Logger) *AuditService {
+	return NewAuditServiceWithStorage(security.NewPostgresAuditStorage(repos.Audit), log)
+}
+
+// NewAuditServiceWithStorage creates an audit service storing entries in storage
+func NewAuditServiceWithStorage(storage security.AuditStorage, log *logger.Logger) *AuditService {
+	audit := security.NewAuditService(log, storage)
+	metrics.RegisterQueue("audit", audit.QueueDepth)
+	metrics.RegisterAuditOverflow(audit.OverflowCounts)
+	return &AuditService{
+		audit: audit,
+		log:   log,
+	}
+}
+
+// Log records an audit entry in the background
+func (s *AuditService) Log(ctx context.Context, entry *security.AuditEntry) {
+	s.audit.Log(ctx, entry)
+}
+
+// SetOverflowFile writes audit entries that can't be stored to a local file
+// rather than dropping them
+func (s *AuditService) SetOverflowFile(path string) error {
+	return s.audit.SetOverflowFile(path)
+}
+
+// Stats returns counts of the audit entries that were retried, spilled to
+// the overflow file or dropped
+func (s *AuditService) Stats() security.AuditStats {
+	return s.audit.Stats()
+}
+
+// Stop writes the audit entries still buffered
+func (s *AuditService) Stop() {
+	s.audit.Stop()
+}
+
+// Query returns a page of the tenant's audit entries, newest first
+func (s *AuditService) Query(ctx context.Context, tenantID uuid.UUID, query security.AuditQuery) ([]*security.AuditEntry, error) {
+	query.TenantID = tenantID
+	if query.Limit <= 0 {
+		query.Limit = defaultAuditLimit
+	}
+	if query.Limit > maxAuditLimit {
+		query.Limit = maxAuditLimit
+	}
+	if query.Offset < 0 {
+		query.Offset = 0
+	}
+
+	entries, err := s.audit.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit logs: %w", err)
+	}
+	if entries == nil {
+		entries = []*security.AuditEntry{}
+	}
+	return entries, nil
+}
+
+// Export passes every tenant audit entry matching the query to fn, newest
+// first, reading a page at a time. Entries recorded after the export starts
+// are left out so paging stays stable.
+func (s *AuditService) Export(ctx context.Context, tenantID uuid.UUID, query security.AuditQuery, fn func(*security.AuditEntry) error) error {
+	query.TenantID = tenantID
+	query.Limit = auditExportPageSize
+	query.Offset = 0
+	if now := time.Now(); query.EndTime == nil || query.EndTime.After(now) {
+		query.EndTime = &now
+	}
+
+	for {
+		entries, err := s.audit.Query(ctx, query)
+		if err != nil {
+			return fmt.Errorf("failed to query audit logs: %w", err)
+		}
+		for _, entry := range entries {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+		if len(entries) < query.Limit {
+			return nil
+		}
+		query.Offset += len(entries)
+	}
+}
@@ -3,7 +3,8 @@ package services
 import (
 	"context"
 	"fmt"
-	"time"
+	"strings"
+	"sync"
 
 	"github.com/delphi-platform/delphi/backend/internal/config"
 	"github.com/delphi-platform/delphi/backend/internal/models"
@@ -11,7 +12,6 @@ import (
 	"github.com/delphi-platform/delphi/backend/pkg/auth"
 	"github.com/delphi-platform/delphi/backend/pkg/crypto"
 	"github.com/delphi-platform/delphi/backend/pkg/logger"
-	"github.com/google/uuid"
 )
 
 // AuthService handles authentication operations
@@ -19,48 +19,91 @@ type AuthService struct {
 	cfg        *config.Config
 	repos      *repository.Repositories
 	jwtManager *auth.JWTManager
+	tenants    *TenantService    // creates the tenants users register
+	encryptor  *crypto.Encryptor // encrypts MFA secrets
+	audit      *AuditService
 	log        *logger.Logger
 }
 
 // NewAuthService creates a new auth service
-func NewAuthService(cfg *config.Config, repos *repository.Repositories, jwtManager *auth.JWTManager, log *logger.Logger) *AuthService {
+func NewAuthService(cfg *config.Config, repos *repository.Repositories, jwtManager *auth.JWTManager, tenants *TenantService, encryptor *crypto.Encryptor, audit *AuditService, log *logger.Logger) *AuthService {
 	return &AuthService{
 		cfg:        cfg,
 		repos:      repos,
 		jwtManager: jwtManager,
+		tenants:    tenants,
+		encryptor:  encryptor,
+		audit:      audit,
 		log:        log,
 	}
 }
 
+var (
+	// dummyHash is compared against when no user matches a login, so failed
+	// logins take as long whether or not the email is registered
+	dummyHash     string
+	dummyHashOnce sync.Once
+)
+
+func loginDummyHash() string {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = crypto.HashPassword("delphi-login-dummy-password")
+	})
+	return dummyHash
+}
+
+// normalizeEmail lowercases and trims an email so lookups match however it
+// was typed
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
 // LoginRequest represents login credentials
 type LoginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+
+	// MFACode is a TOTP or recovery code, required when the user has MFA enabled
+	MFACode string `json:"mfa_code,omitempty"`
 }
 
 // RegisterRequest represents registration data
 type RegisterRequest struct {
-	Email       string `json:"email"`
-	Password    string `json:"password"`
-	Name        string `json:"name"`
-	TenantName  string `json:"tenant_name"`
-	TenantSlug  string `json:"tenant_slug"`
+	Email      string `json:"email"`
+	Password   string `json:"password"`
+	Name       string `json:"name"`
+	TenantName string `json:"tenant_name"`
+	TenantSlug string `json:"tenant_slug"`
 }
 
-// Login authenticates a user and returns tokens
+// Login authenticates a user by email and password and returns tokens
 func (s *AuthService) Login(ctx context.Context, req *LoginRequest) (*auth.TokenPair, *models.User, error) {
-	// Get user by email
-	user, err := s.repos.Users.GetByEmail(ctx, req.Email)
+	user, err := s.repos.Users.GetByEmail(ctx, normalizeEmail(req.Email))
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get user: %w", err)
 	}
-	if user == nil {
+	if user == nil || user.PasswordHash == "" {
+		// Users created before passwords were stored cannot log in until they
+		// reset theirs
+		crypto.CheckPassword(req.Password, loginDummyHash())
+		return nil, nil, fmt.Errorf("invalid credentials")
+	}
+	if !crypto.CheckPassword(req.Password, user.PasswordHash) {
 		return nil, nil, fmt.Errorf("invalid credentials")
 	}
 
-	// In a real implementation, we'd check the password against a stored hash
-	// For Supabase Auth integration, this would be handled by Supabase
-	// This is a simplified version
+	if user.MFAEnabled {
+		if req.MFACode == "" {
+			return nil, nil, ErrMFARequired
+		}
+		ok, err := s.checkMFACode(ctx, user, req.MFACode)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			return nil, nil, ErrInvalidMFACode
+		}
+	}
 
 	// Update last login
 	if err := s.repos.Users.UpdateLastLogin(ctx, user.ID); err != nil {
@@ -76,63 +119,26 @@ func (s *AuthService) Login(ctx context.Context, req *LoginRequest) (*auth.Token
 	return tokens, user, nil
 }
 
-// Register creates a new user and tenant
+// Register creates a new tenant with the user as its owner, through the
+// tenant service so the slug is validated and made unique the same way. The
+// tenant name defaults to the user's name, and the slug to one derived from
+// the tenant name; a requested slug that is taken is rejected with a
+// *SlugTakenError.
 func (s *AuthService) Register(ctx context.Context, req *RegisterRequest) (*auth.TokenPair, *models.User, error) {
-	// Check if email already exists
-	existing, err := s.repos.Users.GetByEmail(ctx, req.Email)
+	tenantName := req.TenantName
+	if strings.TrimSpace(tenantName) == "" {
+		tenantName = req.Name
+	}
+
+	tenant, user, err := s.tenants.Create(ctx, &CreateTenantRequest{
+		Name:          tenantName,
+		Slug:          req.TenantSlug,
+		OwnerEmail:    req.Email,
+		OwnerName:     req.Name,
+		OwnerPassword: req.Password,
+	})
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to check existing user: %w", err)
-	}
-	if existing != nil {
-		return nil, nil, fmt.Errorf("email already registered")
-	}
-
-	// Check if tenant slug is available
-	existingTenant, err := s.repos.Tenants.GetBySlug(ctx, req.TenantSlug)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to check existing tenant: %w", err)
-	}
-	if existingTenant != nil {
-		return nil, nil, fmt.Errorf("tenant slug already taken")
-	}
-
-	// Hash password
-	_, err = crypto.HashPassword(req.Password)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to hash password: %w", err)
-	}
-
-	now := time.Now()
-
-	// Create tenant
-	tenant := &models.Tenant{
-		ID:        uuid.New(),
-		Name:      req.TenantName,
-		Slug:      req.TenantSlug,
-		Plan:      models.PlanFree,
-		Settings:  []byte("{}"),
-		CreatedAt: now,
-		UpdatedAt: now,
-	}
-
-	if err := s.repos.Tenants.Create(ctx, tenant); err != nil {
-		return nil, nil, fmt.Errorf("failed to create tenant: %w", err)
-	}
-
-	// Create user
-	user := &models.User{
-		ID:          uuid.New(),
-		TenantID:    tenant.ID,
-		Email:       req.Email,
-		Name:        req.Name,
-		Role:        models.RoleOwner,
-		Preferences: []byte("{}"),
-		CreatedAt:   now,
-		UpdatedAt:   now,
-	}
-
-	if err := s.repos.Users.Create(ctx, user); err != nil {
-		return nil, nil, fmt.Errorf("failed to create user: %w", err)
+		return nil, nil, err
 	}
 
 	// Generate tokens
@@ -146,9 +152,10 @@ func (s *AuthService) Register(ctx context.Context, req *RegisterRequest) (*auth
 	return tokens, user, nil
 }
 
-// RefreshToken validates a refresh token and returns new tokens
+// RefreshToken validates a refresh token and returns new tokens. Access
+// tokens are rejected.
 func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (*auth.TokenPair, error) {
-	claims, err := s.jwtManager.ValidateToken(refreshToken)
+	claims, err := s.jwtManager.ValidateRefreshToken(refreshToken)
 	if err != nil {
 		return nil, fmt.Errorf("invalid refresh token: %w", err)
 	}
@@ -158,7 +165,7 @@ func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (*a
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
-	if user == nil {
+	if user == nil || user.TenantID != claims.TenantID {
 		return nil, fmt.Errorf("user not found")
 	}
 
@@ -171,9 +178,10 @@ func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (*a
 	return tokens, nil
 }
 
-// ValidateToken validates an access token and returns claims
+// ValidateToken validates an access token and returns claims. Refresh tokens
+// are rejected.
 func (s *AuthService) ValidateToken(token string) (*auth.Claims, error) {
-	return s.jwtManager.ValidateToken(token)
+	return s.jwtManager.ValidateAccessToken(token)
 }
 
 // ForgotPassword initiates password reset (placeholder)
@@ -183,4 +191,3 @@ func (s *AuthService) ForgotPassword(ctx context.Context, email string) error {
 	s.log.Infow("password reset requested", "email", email)
 	return nil
 }
-
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/config"
+	"github.com/delphi-platform/delphi/backend/internal/notifications"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// BudgetAlertService alerts a tenant as its recorded costs approach its cost
+// limits, for runs recorded by any execution path
+type BudgetAlertService struct {
+	cfg          *config.Config
+	repos        *repository.Repositories
+	redis        *repository.RedisClient
+	notification *NotificationService
+	log          *logger.Logger
+}
+
+// NewBudgetAlertService creates a new budget alert service. Without Redis,
+// forecast alerts can't be deduplicated and are not sent.
+func NewBudgetAlertService(cfg *config.Config, repos *repository.Repositories, redis *repository.RedisClient, notification *NotificationService, log *logger.Logger) *BudgetAlertService {
+	return &BudgetAlertService{
+		cfg:          cfg,
+		repos:        repos,
+		redis:        redis,
+		notification: notification,
+		log:          log,
+	}
+}
+
+// Notify sends a budget alert for each of the tenant's cost limits whose alert
+// threshold was crossed by a newly recorded cost, and one when its spend is
+// projected to pass the monthly limit
+func (s *BudgetAlertService) Notify(ctx context.Context, tenantID uuid.UUID, cost float64) {
+	if s.notification == nil || cost <= 0 {
+		return
+	}
+
+	windows, err := s.repos.Costs.BudgetWindows(ctx, tenantID, time.Now())
+	if err != nil {
+		s.log.Warnw("failed to get cost limits", "tenant_id", tenantID, "error", err)
+		return
+	}
+
+	for _, window := range windows {
+		spent, err := s.repos.Costs.GetTotalByTenant(ctx, tenantID, window.Since)
+		if err != nil {
+			s.log.Warnw("failed to get spend", "tenant_id", tenantID, "limit_type", window.Limit.LimitType, "error", err)
+			continue
+		}
+
+		threshold := window.Limit.Amount * window.Limit.AlertAt / 100
+		if spent-cost >= threshold || spent < threshold {
+			continue
+		}
+
+		percentage := spent / window.Limit.Amount * 100
+		if err := s.notification.Send(ctx, notifications.BudgetAlertNotification(tenantID, spent, window.Limit.Amount, percentage)); err != nil {
+			s.log.Warnw("failed to send budget alert", "tenant_id", tenantID, "limit_type", window.Limit.LimitType, "error", err)
+		}
+	}
+
+	s.notifyBudgetForecast(ctx, tenantID, windows)
+}
+
+// notifyBudgetForecast sends a budget alert, once per period, when the
+// tenant's spend is projected to pass its monthly limit before it has. Alerts
+// are deduplicated in Redis, so none are sent without it.
+func (s *BudgetAlertService) notifyBudgetForecast(ctx context.Context, tenantID uuid.UUID, windows []repository.BudgetWindow) {
+	if s.notification == nil || s.redis == nil {
+		return
+	}
+
+	for _, window := range windows {
+		if window.Limit.LimitType != "monthly" {
+			continue
+		}
+
+		forecast, err := forecastSpend(ctx, s.repos, tenantID, window.Limit, s.cfg.CostForecastWindowDays, time.Now())
+		if err != nil {
+			s.log.Warnw("failed to forecast spend", "tenant_id", tenantID, "error", err)
+			return
+		}
+		if !forecast.WillExceed || forecast.Spent >= window.Limit.Amount {
+			return
+		}
+
+		key := fmt.Sprintf("budget_forecast_alert:%s:%s", tenantID, forecast.PeriodStart.Format("2006-01"))
+		first, err := s.redis.SetNX(ctx, key, 1, time.Until(forecast.PeriodEnd))
+		if err != nil {
+			s.log.Warnw("failed to record budget forecast alert", "tenant_id", tenantID, "error", err)
+			return
+		}
+		if !first {
+			return
+		}
+
+		notification := notifications.BudgetForecastNotification(tenantID, forecast.Spent, forecast.Projected, window.Limit.Amount, forecast.PeriodEnd)
+		if err := s.notification.Send(ctx, notification); err != nil {
+			s.log.Warnw("failed to send budget forecast alert", "tenant_id", tenantID, "error", err)
+		}
+		return
+	}
+}
@@ -0,0 +1,395 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// =============================================================================
+// Business Service
+// =============================================================================
+
+// BusinessService manages a tenant's businesses
+type BusinessService struct {
+	repos *repository.Repositories
+	log   *logger.Logger
+}
+
+// NewBusinessService creates a new business service
+func NewBusinessService(repos *repository.Repositories, log *logger.Logger) *BusinessService {
+	return &BusinessService{repos: repos, log: log}
+}
+
+// validBusinessTypes are the types a business may have
+var validBusinessTypes = map[models.BusinessType]bool{
+	models.BusinessTypeGameStudio: true,
+	models.BusinessTypeSaaS:       true,
+	models.BusinessTypeCrashGames: true,
+	models.BusinessTypeGeneral:    true,
+}
+
+// CreateBusinessRequest represents business creation input. Type defaults to
+// general; Settings and Metadata must be JSON objects.
+type CreateBusinessRequest struct {
+	Name     string              `json:"name"`
+	Type     models.BusinessType `json:"type"`
+	Settings json.RawMessage     `json:"settings"`
+	Metadata json.RawMessage     `json:"metadata"`
+}
+
+// UpdateBusinessRequest represents business changes; unset fields are kept
+type UpdateBusinessRequest struct {
+	Name     *string              `json:"name"`
+	Type     *models.BusinessType `json:"type"`
+	Settings json.RawMessage      `json:"settings"`
+	Metadata json.RawMessage      `json:"metadata"`
+}
+
+// getBusiness returns one of the tenant's businesses
+func getBusiness(ctx context.Context, repos *repository.Repositories, tenantID, businessID uuid.UUID) (*models.Business, error) {
+	business, err := repos.Businesses.GetByID(ctx, businessID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get business: %w", err)
+	}
+	if business == nil || business.TenantID != tenantID {
+		return nil, fmt.Errorf("business not found")
+	}
+	return business, nil
+}
+
+// jsonObject returns raw if it is a JSON object, {} if it is empty, and an
+// error naming field otherwise
+func jsonObject(field string, raw json.RawMessage) (json.RawMessage, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return json.RawMessage(`{}`), nil
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, fmt.Errorf("%s must be a JSON object", field)
+	}
+	return raw, nil
+}
+
+// List returns the tenant's businesses
+func (s *BusinessService) List(ctx context.Context, tenantID uuid.UUID) ([]*models.Business, error) {
+	businesses, err := s.repos.Businesses.ListByTenant(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list businesses: %w", err)
+	}
+	if businesses == nil {
+		businesses = []*models.Business{}
+	}
+	return businesses, nil
+}
+
+// Get returns one of the tenant's businesses
+func (s *BusinessService) Get(ctx context.Context, tenantID, businessID uuid.UUID) (*models.Business, error) {
+	return getBusiness(ctx, s.repos, tenantID, businessID)
+}
+
+// Create adds a business to the tenant
+func (s *BusinessService) Create(ctx context.Context, tenantID uuid.UUID, req *CreateBusinessRequest) (*models.Business, error) {
+	now := time.Now()
+	business := &models.Business{
+		ID:        uuid.New(),
+		TenantID:  tenantID,
+		Name:      strings.TrimSpace(req.Name),
+		Type:      req.Type,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if business.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if business.Type == "" {
+		business.Type = models.BusinessTypeGeneral
+	}
+	if !validBusinessTypes[business.Type] {
+		return nil, fmt.Errorf("invalid business type: %s", business.Type)
+	}
+	var err error
+	if business.Settings, err = jsonObject("settings", req.Settings); err != nil {
+		return nil, err
+	}
+	if business.Metadata, err = jsonObject("metadata", req.Metadata); err != nil {
+		return nil, err
+	}
+
+	if err := s.repos.Businesses.Create(ctx, business); err != nil {
+		return nil, fmt.Errorf("failed to create business: %w", err)
+	}
+
+	s.log.Infow("business created", "business_id", business.ID, "tenant_id", tenantID)
+	return business, nil
+}
+
+// Update changes one of the tenant's businesses
+func (s *BusinessService) Update(ctx context.Context, tenantID, businessID uuid.UUID, req *UpdateBusinessRequest) (*models.Business, error) {
+	business, err := getBusiness(ctx, s.repos, tenantID, businessID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		business.Name = strings.TrimSpace(*req.Name)
+		if business.Name == "" {
+			return nil, fmt.Errorf("name is required")
+		}
+	}
+	if req.Type != nil {
+		if !validBusinessTypes[*req.Type] {
+			return nil, fmt.Errorf("invalid business type: %s", *req.Type)
+		}
+		business.Type = *req.Type
+	}
+	if req.Settings != nil {
+		if business.Settings, err = jsonObject("settings", req.Settings); err != nil {
+			return nil, err
+		}
+	}
+	if req.Metadata != nil {
+		if business.Metadata, err = jsonObject("metadata", req.Metadata); err != nil {
+			return nil, err
+		}
+	}
+	business.UpdatedAt = time.Now()
+
+	if err := s.repos.Businesses.Update(ctx, business); err != nil {
+		return nil, fmt.Errorf("failed to update business: %w", err)
+	}
+	return business, nil
+}
+
+// Delete removes one of the tenant's businesses with its financial records.
+// A business with projects is not deleted; its projects must be deleted or
+// moved to another business first.
+func (s *BusinessService) Delete(ctx context.Context, tenantID, businessID uuid.UUID) error {
+	if _, err := getBusiness(ctx, s.repos, tenantID, businessID); err != nil {
+		return err
+	}
+	if err := s.repos.Businesses.Delete(ctx, businessID); err != nil {
+		if errors.Is(err, repository.ErrBusinessHasProjects) {
+			return fmt.Errorf("%w: delete or move its projects first", err)
+		}
+		return fmt.Errorf("failed to delete business: %w", err)
+	}
+
+	s.log.Infow("business deleted", "business_id", businessID, "tenant_id", tenantID)
+	return nil
+}
+
+// =============================================================================
+// Project Service
+// =============================================================================
+
+// ProjectService manages the projects of a tenant's businesses. A project's
+// agents and repositories must belong to the same tenant.
+type ProjectService struct {
+	repos *repository.Repositories
+	log   *logger.Logger
+}
+
+// NewProjectService creates a new project service
+func NewProjectService(repos *repository.Repositories, log *logger.Logger) *ProjectService {
+	return &ProjectService{repos: repos, log: log}
+}
+
+// validProjectStatuses are the statuses a project may have
+var validProjectStatuses = map[string]bool{
+	"active":   true,
+	"paused":   true,
+	"archived": true,
+}
+
+// CreateProjectRequest represents project creation input. Status defaults to
+// active.
+type CreateProjectRequest struct {
+	BusinessID   uuid.UUID   `json:"business_id"`
+	Name         string      `json:"name"`
+	Description  string      `json:"description"`
+	Repositories []uuid.UUID `json:"repositories"`
+	Agents       []uuid.UUID `json:"agents"`
+	Status       string      `json:"status"`
+}
+
+// UpdateProjectRequest represents project changes; unset fields are kept.
+// Setting BusinessID moves the project to another of the tenant's businesses.
+type UpdateProjectRequest struct {
+	BusinessID   *uuid.UUID   `json:"business_id"`
+	Name         *string      `json:"name"`
+	Description  *string      `json:"description"`
+	Repositories *[]uuid.UUID `json:"repositories"`
+	Agents       *[]uuid.UUID `json:"agents"`
+	Status       *string      `json:"status"`
+}
+
+// List returns the projects of the tenant's businesses, or of one of them
+// when businessID is set
+func (s *ProjectService) List(ctx context.Context, tenantID uuid.UUID, businessID *uuid.UUID) ([]*models.Project, error) {
+	projects, err := s.repos.Projects.ListByTenant(ctx, tenantID, businessID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list projects: %w", err)
+	}
+	if projects == nil {
+		projects = []*models.Project{}
+	}
+	return projects, nil
+}
+
+// Get returns one of the tenant's projects
+func (s *ProjectService) Get(ctx context.Context, tenantID, projectID uuid.UUID) (*models.Project, error) {
+	project, owner, err := s.repos.Projects.GetByID(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project: %w", err)
+	}
+	if project == nil || owner != tenantID {
+		return nil, fmt.Errorf("project not found")
+	}
+	return project, nil
+}
+
+// Create adds a project to one of the tenant's businesses
+func (s *ProjectService) Create(ctx context.Context, tenantID uuid.UUID, req *CreateProjectRequest) (*models.Project, error) {
+	now := time.Now()
+	project := &models.Project{
+		ID:           uuid.New(),
+		BusinessID:   req.BusinessID,
+		Name:         strings.TrimSpace(req.Name),
+		Description:  req.Description,
+		Repositories: uniqueIDs(req.Repositories),
+		Agents:       uniqueIDs(req.Agents),
+		Status:       req.Status,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if project.BusinessID == uuid.Nil {
+		return nil, fmt.Errorf("business_id is required")
+	}
+	if project.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if project.Status == "" {
+		project.Status = "active"
+	}
+	if !validProjectStatuses[project.Status] {
+		return nil, fmt.Errorf("status must be active, paused or archived")
+	}
+
+	if _, err := getBusiness(ctx, s.repos, tenantID, project.BusinessID); err != nil {
+		return nil, err
+	}
+	if err := s.checkReferences(ctx, tenantID, project); err != nil {
+		return nil, err
+	}
+	if err := s.repos.Projects.Create(ctx, project); err != nil {
+		return nil, fmt.Errorf("failed to create project: %w", err)
+	}
+
+	s.log.Infow("project created", "project_id", project.ID, "business_id", project.BusinessID, "tenant_id", tenantID)
+	return project, nil
+}
+
+// Update changes one of the tenant's projects
+func (s *ProjectService) Update(ctx context.Context, tenantID, projectID uuid.UUID, req *UpdateProjectRequest) (*models.Project, error) {
+	project, err := s.Get(ctx, tenantID, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.BusinessID != nil && *req.BusinessID != project.BusinessID {
+		if _, err := getBusiness(ctx, s.repos, tenantID, *req.BusinessID); err != nil {
+			return nil, err
+		}
+		project.BusinessID = *req.BusinessID
+	}
+	if req.Name != nil {
+		project.Name = strings.TrimSpace(*req.Name)
+		if project.Name == "" {
+			return nil, fmt.Errorf("name is required")
+		}
+	}
+	if req.Description != nil {
+		project.Description = *req.Description
+	}
+	if req.Repositories != nil {
+		project.Repositories = uniqueIDs(*req.Repositories)
+	}
+	if req.Agents != nil {
+		project.Agents = uniqueIDs(*req.Agents)
+	}
+	if req.Status != nil {
+		if !validProjectStatuses[*req.Status] {
+			return nil, fmt.Errorf("status must be active, paused or archived")
+		}
+		project.Status = *req.Status
+	}
+
+	if err := s.checkReferences(ctx, tenantID, project); err != nil {
+		return nil, err
+	}
+	project.UpdatedAt = time.Now()
+	if err := s.repos.Projects.Update(ctx, project); err != nil {
+		return nil, fmt.Errorf("failed to update project: %w", err)
+	}
+	return project, nil
+}
+
+// Delete removes one of the tenant's projects
+func (s *ProjectService) Delete(ctx context.Context, tenantID, projectID uuid.UUID) error {
+	if _, err := s.Get(ctx, tenantID, projectID); err != nil {
+		return err
+	}
+	if err := s.repos.Projects.Delete(ctx, projectID); err != nil {
+		return fmt.Errorf("failed to delete project: %w", err)
+	}
+
+	s.log.Infow("project deleted", "project_id", projectID, "tenant_id", tenantID)
+	return nil
+}
+
+// checkReferences verifies the project's agents and repositories belong to
+// the tenant. Those of other tenants are reported as not found, so their IDs
+// can't be probed.
+func (s *ProjectService) checkReferences(ctx context.Context, tenantID uuid.UUID, project *models.Project) error {
+	for _, id := range project.Agents {
+		agent, err := s.repos.Agents.GetByID(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to get agent: %w", err)
+		}
+		if agent == nil || agent.TenantID != tenantID {
+			return fmt.Errorf("agent %s not found", id)
+		}
+	}
+	for _, id := range project.Repositories {
+		repo, err := s.repos.Repositories.GetByID(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to get repository: %w", err)
+		}
+		if repo == nil || repo.TenantID != tenantID {
+			return fmt.Errorf("repository %s not found", id)
+		}
+	}
+	return nil
+}
+
+// uniqueIDs returns ids without duplicates, in their first order
+func uniqueIDs(ids []uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	unique := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+)
+
+// CodingResultSchemaVersion is the version of models.CodingResult that coding
+// runs' results are normalized to
+const CodingResultSchemaVersion = 1
+
+var (
+	// jsonFence matches a fenced block in a text response, as models tend to
+	// wrap the JSON they are asked for
+	jsonFence = regexp.MustCompile("(?s)```(?:json)?\\s*(\\{.*\\})\\s*```")
+
+	commitSHA = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+)
+
+// NormalizeCodingResult checks a coding agent's output against the coding
+// result schema and returns it normalized: strings trimmed, file statuses
+// defaulted to modified, duplicate files merged and the schema version set.
+// Output given as a string, such as a text response, is parsed for the JSON
+// object it holds. Problems are reported together in a *ValidationError.
+func NormalizeCodingResult(output json.RawMessage) (json.RawMessage, error) {
+	object, err := codingResultObject(output)
+	if err != nil {
+		return nil, &ValidationError{Fields: []FieldError{{Field: "result", Message: err.Error()}}}
+	}
+
+	var result models.CodingResult
+	if err := json.Unmarshal(object, &result); err != nil {
+		return nil, &ValidationError{Fields: []FieldError{{Field: "result", Message: fmt.Sprintf("result does not match the coding result schema: %v", err)}}}
+	}
+
+	var fields []FieldError
+	reject := func(field, format string, args ...interface{}) {
+		fields = append(fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if result.SchemaVersion > CodingResultSchemaVersion {
+		reject("schema_version", "schema_version %d is newer than %d", result.SchemaVersion, CodingResultSchemaVersion)
+	}
+	result.SchemaVersion = CodingResultSchemaVersion
+
+	result.Summary = strings.TrimSpace(result.Summary)
+	if result.Summary == "" {
+		reject("summary", "summary is required")
+	}
+
+	files := make([]models.CodingFileChange, 0, len(result.Files))
+	seen := make(map[string]int)
+	for i, file := range result.Files {
+		field := fmt.Sprintf("files[%d]", i)
+		file.Path = strings.TrimSpace(file.Path)
+		file.PreviousPath = strings.TrimSpace(file.PreviousPath)
+		if file.Status == "" {
+			file.Status = models.CodingFileModified
+		}
+
+		if err := checkRepoPath(file.Path); err != nil {
+			reject(field+".path", "%s.path %v", field, err)
+			continue
+		}
+		switch file.Status {
+		case models.CodingFileAdded, models.CodingFileModified, models.CodingFileDeleted:
+			file.PreviousPath = ""
+		case models.CodingFileRenamed:
+			if err := checkRepoPath(file.PreviousPath); err != nil {
+				reject(field+".previous_path", "%s.previous_path of a rename %v", field, err)
+				continue
+			}
+		default:
+			reject(field+".status", "%s.status must be added, modified, deleted or renamed", field)
+			continue
+		}
+		if file.Status == models.CodingFileDeleted {
+			file.Content = nil
+		}
+
+		// A file listed twice keeps its last change
+		if at, ok := seen[file.Path]; ok {
+			files[at] = file
+			continue
+		}
+		seen[file.Path] = len(files)
+		files = append(files, file)
+	}
+	result.Files = files
+
+	commits := make([]models.CodingCommit, 0, len(result.Commits))
+	for i, commit := range result.Commits {
+		field := fmt.Sprintf("commits[%d]", i)
+		commit.SHA = strings.ToLower(strings.TrimSpace(commit.SHA))
+		commit.Message = strings.TrimSpace(commit.Message)
+		if commit.Message == "" {
+			reject(field+".message", "%s.message is required", field)
+		}
+		if commit.SHA != "" && !commitSHA.MatchString(commit.SHA) {
+			reject(field+".sha", "%s.sha must be a hex commit SHA", field)
+		}
+		commits = append(commits, commit)
+	}
+	result.Commits = commits
+
+	result.PRURL = strings.TrimSpace(result.PRURL)
+	if result.PRURL != "" {
+		if u, err := url.Parse(result.PRURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			reject("pr_url", "pr_url must be an http(s) URL")
+		}
+	}
+
+	if len(fields) > 0 {
+		return nil, &ValidationError{Fields: fields}
+	}
+	return json.Marshal(result)
+}
+
+// codingResultObject returns the JSON object of a coding agent's output. A
+// JSON string is taken as a text response and searched for the object,
+// fenced or bare.
+func codingResultObject(output json.RawMessage) (json.RawMessage, error) {
+	trimmed := bytes.TrimSpace(output)
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return nil, fmt.Errorf("result is empty")
+	}
+	if trimmed[0] == '{' {
+		return trimmed, nil
+	}
+
+	var text string
+	if err := json.Unmarshal(trimmed, &text); err != nil {
+		return nil, fmt.Errorf("result must be a JSON object")
+	}
+	if match := jsonFence.FindStringSubmatch(text); match != nil {
+		return json.RawMessage(match[1]), nil
+	}
+	start, end := strings.Index(text, "{"), strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("result holds no JSON object")
+	}
+	return json.RawMessage(text[start : end+1]), nil
+}
+
+// checkRepoPath checks that a path names a file within a repository
+func checkRepoPath(p string) error {
+	switch {
+	case p == "":
+		return fmt.Errorf("is required")
+	case strings.HasPrefix(p, "/"):
+		return fmt.Errorf("must be relative to the repository root")
+	case path.Clean(p) != p || p == "." || p == ".." || strings.HasPrefix(p, "../"):
+		return fmt.Errorf("must be a clean path within the repository")
+	}
+	return nil
+}
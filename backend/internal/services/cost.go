@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/config"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// CostService handles cost tracking operations
+type CostService struct {
+	repos              *repository.Repositories
+	redis              *repository.RedisClient
+	forecastWindowDays int
+	log                *logger.Logger
+}
+
+func NewCostService(cfg *config.Config, repos *repository.Repositories, redis *repository.RedisClient, log *logger.Logger) *CostService {
+	return &CostService{repos: repos, redis: redis, forecastWindowDays: cfg.CostForecastWindowDays, log: log}
+}
+
+// AgentCost is an agent's share of a tenant's costs. Costs not tied to an
+// agent, such as knowledge base questions, have no AgentID.
+type AgentCost struct {
+	AgentID      *uuid.UUID `json:"agent_id"`
+	AgentName    string     `json:"agent_name"`
+	Cost         float64    `json:"cost"`
+	InputTokens  int        `json:"input_tokens"`
+	OutputTokens int        `json:"output_tokens"`
+	TotalTokens  int        `json:"total_tokens"`
+	RecordCount  int        `json:"record_count"`
+}
+
+// ProviderCost is an AI provider's share of a tenant's costs
+type ProviderCost struct {
+	Provider     models.AIProvider `json:"provider"`
+	Cost         float64           `json:"cost"`
+	InputTokens  int               `json:"input_tokens"`
+	OutputTokens int               `json:"output_tokens"`
+	TotalTokens  int               `json:"total_tokens"`
+	RecordCount  int               `json:"record_count"`
+}
+
+// ByAgent breaks down the tenant's costs in [since, until) by agent, most
+// expensive first.
+func (s *CostService) ByAgent(ctx context.Context, tenantID uuid.UUID, since, until time.Time) ([]AgentCost, error) {
+	if !since.Before(until) {
+		return nil, fmt.Errorf("since must be before until")
+	}
+
+	totals, err := s.repos.Costs.TotalsByAgent(ctx, tenantID, since, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get costs by agent: %w", err)
+	}
+
+	agents, err := s.repos.Agents.ListByTenant(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agents: %w", err)
+	}
+	names := make(map[uuid.UUID]string, len(agents))
+	for _, agent := range agents {
+		names[agent.ID] = agent.Name
+	}
+
+	costs := make([]AgentCost, 0, len(totals))
+	for _, t := range totals {
+		cost := AgentCost{
+			Cost:         t.Cost,
+			InputTokens:  t.InputTokens,
+			OutputTokens: t.OutputTokens,
+			TotalTokens:  t.InputTokens + t.OutputTokens,
+			RecordCount:  t.Records,
+		}
+		if agentID, err := uuid.Parse(t.Group); err == nil {
+			cost.AgentID = &agentID
+			cost.AgentName = names[agentID]
+		}
+		costs = append(costs, cost)
+	}
+	return costs, nil
+}
+
+// ByProvider breaks down the tenant's costs in [since, until) by AI provider,
+// most expensive first.
+func (s *CostService) ByProvider(ctx context.Context, tenantID uuid.UUID, since, until time.Time) ([]ProviderCost, error) {
+	if !since.Before(until) {
+		return nil, fmt.Errorf("since must be before until")
+	}
+
+	totals, err := s.repos.Costs.TotalsByProvider(ctx, tenantID, since, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get costs by provider: %w", err)
+	}
+
+	costs := make([]ProviderCost, 0, len(totals))
+	for _, t := range totals {
+		costs = append(costs, ProviderCost{
+			Provider:     models.AIProvider(t.Group),
+			Cost:         t.Cost,
+			InputTokens:  t.InputTokens,
+			OutputTokens: t.OutputTokens,
+			TotalTokens:  t.InputTokens + t.OutputTokens,
+			RecordCount:  t.Records,
+		})
+	}
+	return costs, nil
+}
+
+// BudgetStatus is a cost limit with the spend in its current period
+type BudgetStatus struct {
+	LimitID     uuid.UUID  `json:"limit_id"`
+	AgentID     *uuid.UUID `json:"agent_id"`
+	LimitType   string     `json:"limit_type"`
+	Limit       float64    `json:"limit"`
+	AlertAt     float64    `json:"alert_at"`
+	Spent       float64    `json:"spent"`
+	Remaining   float64    `json:"remaining"`
+	PeriodStart time.Time  `json:"period_start"`
+	PeriodEnd   time.Time  `json:"period_end"`
+}
+
+// GetRemaining returns the daily and monthly limits set for the tenant, or for
+// one of its agents when agentID is set, with what has been spent and what
+// remains in each limit's current period
+func (s *CostService) GetRemaining(ctx context.Context, tenantID uuid.UUID, agentID *uuid.UUID) ([]*BudgetStatus, error) {
+	if agentID != nil {
+		agent, err := s.repos.Agents.GetByID(ctx, *agentID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get agent: %w", err)
+		}
+		if agent == nil || agent.TenantID != tenantID {
+			return nil, fmt.Errorf("agent not found")
+		}
+	}
+
+	now := time.Now()
+	statuses := []*BudgetStatus{}
+	for _, limitType := range []string{"daily", "monthly"} {
+		limit, err := s.repos.Costs.GetLimit(ctx, tenantID, agentID, limitType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s limit: %w", limitType, err)
+		}
+		if limit == nil {
+			continue
+		}
+
+		start, end, err := repository.BudgetPeriod(limit, now)
+		if err != nil {
+			return nil, err
+		}
+
+		var spent float64
+		if agentID != nil {
+			spent, err = s.repos.Costs.GetTotalByAgent(ctx, *agentID, start)
+		} else {
+			spent, err = s.repos.Costs.GetTotalByTenant(ctx, tenantID, start)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s spend: %w", limitType, err)
+		}
+
+		statuses = append(statuses, &BudgetStatus{
+			LimitID:     limit.ID,
+			AgentID:     limit.AgentID,
+			LimitType:   limit.LimitType,
+			Limit:       limit.Amount,
+			AlertAt:     limit.AlertAt,
+			Spent:       spent,
+			Remaining:   repository.RemainingBudget(limit, spent),
+			PeriodStart: start,
+			PeriodEnd:   end,
+		})
+	}
+	return statuses, nil
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/notifications"
+	"github.com/google/uuid"
+)
+
+const (
+	// costBaselineSample is how many of an agent's recent runs its cost
+	// baseline is averaged over, and minCostBaselineRuns how many it needs
+	// before runs are compared against it
+	costBaselineSample  = 20
+	minCostBaselineRuns = 5
+
+	// defaultCostAnomalyMultiple is how many times the baseline a run must
+	// cost to be flagged when the tenant hasn't chosen
+	defaultCostAnomalyMultiple = 5.0
+)
+
+// CostAnomalySettings control how a tenant's runs are checked for costing far
+// more than their agent's recent runs. They are stored in the tenant settings
+// under cost_anomalies.
+type CostAnomalySettings struct {
+	Enabled   bool    `json:"enabled"`
+	Multiple  float64 `json:"multiple"`
+	AutoPause bool    `json:"auto_pause"`
+}
+
+// UpdateCostAnomalySettingsRequest represents cost anomaly settings changes;
+// unset fields are kept
+type UpdateCostAnomalySettingsRequest struct {
+	Enabled   *bool    `json:"enabled"`
+	Multiple  *float64 `json:"multiple"`
+	AutoPause *bool    `json:"auto_pause"`
+}
+
+// TenantCostAnomalySettings returns the tenant's cost anomaly settings. Until
+// the tenant changes them, runs costing five times their agent's baseline are
+// flagged and the agent keeps running.
+func TenantCostAnomalySettings(tenant *models.Tenant) CostAnomalySettings {
+	settings := CostAnomalySettings{Enabled: true, Multiple: defaultCostAnomalyMultiple}
+	if tenant == nil || len(tenant.Settings) == 0 {
+		return settings
+	}
+
+	var stored struct {
+		CostAnomalies *CostAnomalySettings `json:"cost_anomalies"`
+	}
+	if err := json.Unmarshal(tenant.Settings, &stored); err != nil || stored.CostAnomalies == nil {
+		return settings
+	}
+	settings = *stored.CostAnomalies
+	if settings.Multiple <= 1 {
+		settings.Multiple = defaultCostAnomalyMultiple
+	}
+	return settings
+}
+
+// IsCostAnomaly reports whether a run costing cost exceeds multiple times
+// the baseline averaged over runs earlier runs. Agents with too few runs, or
+// whose runs have cost nothing, have no baseline to compare against.
+func IsCostAnomaly(cost, baseline float64, runs int, multiple float64) bool {
+	if runs < minCostBaselineRuns || baseline <= 0 {
+		return false
+	}
+	return cost > baseline*multiple
+}
+
+// GetAnomalySettings returns the tenant's cost anomaly settings
+func (s *CostService) GetAnomalySettings(ctx context.Context, tenantID uuid.UUID) (*CostAnomalySettings, error) {
+	tenant, err := s.repos.Tenants.GetByID(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+	if tenant == nil {
+		return nil, fmt.Errorf("tenant not found")
+	}
+
+	settings := TenantCostAnomalySettings(tenant)
+	return &settings, nil
+}
+
+// UpdateAnomalySettings changes the tenant's cost anomaly settings,
+// preserving its other settings
+func (s *CostService) UpdateAnomalySettings(ctx context.Context, tenantID uuid.UUID, req *UpdateCostAnomalySettingsRequest) (*CostAnomalySettings, error) {
+	tenant, err := s.repos.Tenants.GetByID(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+	if tenant == nil {
+		return nil, fmt.Errorf("tenant not found")
+	}
+
+	anomaly := TenantCostAnomalySettings(tenant)
+	if req.Enabled != nil {
+		anomaly.Enabled = *req.Enabled
+	}
+	if req.Multiple != nil {
+		if *req.Multiple <= 1 {
+			return nil, fmt.Errorf("multiple must be greater than 1")
+		}
+		anomaly.Multiple = *req.Multiple
+	}
+	if req.AutoPause != nil {
+		anomaly.AutoPause = *req.AutoPause
+	}
+
+	settings := make(map[string]interface{})
+	if len(tenant.Settings) > 0 {
+		if err := json.Unmarshal(tenant.Settings, &settings); err != nil {
+			return nil, fmt.Errorf("failed to parse tenant settings: %w", err)
+		}
+	}
+	settings["cost_anomalies"] = anomaly
+
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal tenant settings: %w", err)
+	}
+	tenant.Settings = data
+	if err := s.repos.Tenants.Update(ctx, tenant); err != nil {
+		return nil, fmt.Errorf("failed to update tenant: %w", err)
+	}
+
+	s.log.Infow("cost anomaly settings updated",
+		"tenant_id", tenantID,
+		"enabled", anomaly.Enabled,
+		"multiple", anomaly.Multiple,
+		"auto_pause", anomaly.AutoPause,
+	)
+	return &anomaly, nil
+}
+
+// checkCostAnomaly compares a completed run's cost with the agent's baseline,
+// flagging the run and notifying the tenant when it exceeds the tenant's
+// multiple. It reports whether the agent was paused as a result.
+func (s *ExecuteService) checkCostAnomaly(ctx context.Context, agent *models.Agent, run *models.AgentRun, cost float64) bool {
+	tenant, err := s.repos.Tenants.GetByID(ctx, run.TenantID)
+	if err != nil {
+		s.log.Warnw("failed to get tenant for cost anomaly check", "tenant_id", run.TenantID, "error", err)
+		return false
+	}
+	settings := TenantCostAnomalySettings(tenant)
+	if !settings.Enabled {
+		return false
+	}
+
+	baseline, runs, err := s.repos.AgentRuns.CostBaseline(ctx, agent.ID, run.ID, costBaselineSample)
+	if err != nil {
+		s.log.Warnw("failed to get cost baseline", "agent_id", agent.ID, "error", err)
+		return false
+	}
+	if !IsCostAnomaly(cost, baseline, runs, settings.Multiple) {
+		return false
+	}
+
+	if err := s.repos.AgentRuns.FlagCostAnomaly(ctx, run.ID, baseline); err != nil {
+		s.log.Warnw("failed to flag cost anomaly", "run_id", run.ID, "error", err)
+	}
+	s.runLog(ctx, run.ID, models.LogLevelWarn, "run cost anomaly", map[string]interface{}{
+		"cost":     cost,
+		"baseline": baseline,
+		"multiple": settings.Multiple,
+	})
+
+	paused := false
+	if settings.AutoPause {
+		if err := s.repos.Agents.UpdateStatus(ctx, agent.ID, models.AgentStatusPaused); err != nil {
+			s.log.Warnw("failed to pause agent", "agent_id", agent.ID, "error", err)
+		} else {
+			paused = true
+		}
+	}
+	s.log.Warnw("run cost anomaly", "run_id", run.ID, "agent_id", agent.ID, "cost", cost, "baseline", baseline, "paused", paused)
+
+	if s.notification != nil {
+		notification := notifications.CostAnomalyNotification(run.TenantID, agent.Name, run.ID, cost, baseline, paused)
+		if err := s.notification.Send(ctx, notification); err != nil {
+			s.log.Warnw("failed to send cost anomaly alert", "run_id", run.ID, "error", err)
+		}
+	}
+	return paused
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultForecastWindowDays is the trailing window burn rates are measured
+	// over when none is configured
+	defaultForecastWindowDays = 7
+
+	// forecastConfidence is the confidence of a forecast's band, and
+	// forecastZ the normal quantile it spans either side of the projection
+	forecastConfidence = 0.95
+	forecastZ          = 1.96
+)
+
+// SpendProjection projects spend to the end of a period from a burn rate
+type SpendProjection struct {
+	BurnRate      float64 `json:"burn_rate"` // average spend per day over the window
+	Projected     float64 `json:"projected"`
+	ProjectedLow  float64 `json:"projected_low"`
+	ProjectedHigh float64 `json:"projected_high"`
+}
+
+// CostForecast projects the tenant's spend to the end of its monthly budget
+// period and compares it with the monthly limit, when one is set
+type CostForecast struct {
+	SpendProjection
+	LimitType        string    `json:"limit_type"`
+	PeriodStart      time.Time `json:"period_start"`
+	PeriodEnd        time.Time `json:"period_end"`
+	Spent            float64   `json:"spent"`
+	WindowDays       int       `json:"window_days"`
+	Confidence       float64   `json:"confidence"`
+	Limit            *float64  `json:"limit"`
+	ProjectedOverage float64   `json:"projected_overage"`
+	WillExceed       bool      `json:"will_exceed"`
+}
+
+// ProjectSpend projects spent to the end of a period remaining away, at the
+// average of the daily costs over the trailing window. The band widens with
+// the day-to-day variation of those costs and the days left, and never drops
+// below what has already been spent.
+func ProjectSpend(spent float64, daily []float64, remaining time.Duration) SpendProjection {
+	projection := SpendProjection{Projected: spent, ProjectedLow: spent, ProjectedHigh: spent}
+	if len(daily) == 0 || remaining <= 0 {
+		return projection
+	}
+
+	var sum float64
+	for _, cost := range daily {
+		sum += cost
+	}
+	rate := sum / float64(len(daily))
+
+	var variance float64
+	if len(daily) > 1 {
+		for _, cost := range daily {
+			variance += (cost - rate) * (cost - rate)
+		}
+		variance /= float64(len(daily) - 1)
+	}
+
+	days := remaining.Hours() / 24
+	margin := forecastZ * math.Sqrt(variance*days)
+
+	projection.BurnRate = rate
+	projection.Projected = spent + rate*days
+	projection.ProjectedLow = max(projection.Projected-margin, spent)
+	projection.ProjectedHigh = projection.Projected + margin
+	return projection
+}
+
+// Forecast projects the tenant's spend to the end of the current month from
+// its burn rate over the trailing forecast window
+func (s *CostService) Forecast(ctx context.Context, tenantID uuid.UUID) (*CostForecast, error) {
+	limit, err := s.repos.Costs.GetLimit(ctx, tenantID, nil, "monthly")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get monthly limit: %w", err)
+	}
+	return forecastSpend(ctx, s.repos, tenantID, limit, s.forecastWindowDays, time.Now())
+}
+
+// forecastSpend forecasts the tenant's spend over the monthly period containing
+// now. limit may be nil when the tenant has none.
+func forecastSpend(ctx context.Context, repos *repository.Repositories, tenantID uuid.UUID, limit *models.CostLimit, windowDays int, now time.Time) (*CostForecast, error) {
+	if windowDays <= 0 {
+		windowDays = defaultForecastWindowDays
+	}
+
+	period := limit
+	if period == nil {
+		period = &models.CostLimit{LimitType: "monthly"}
+	}
+	start, end, err := repository.BudgetPeriod(period, now)
+	if err != nil {
+		return nil, err
+	}
+
+	spent, err := repos.Costs.GetTotalByTenant(ctx, tenantID, start)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get monthly spend: %w", err)
+	}
+	daily, err := repos.Costs.DailyTotals(ctx, tenantID, now.AddDate(0, 0, -windowDays), now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daily costs: %w", err)
+	}
+
+	forecast := &CostForecast{
+		SpendProjection: ProjectSpend(spent, daily, end.Sub(now)),
+		LimitType:       "monthly",
+		PeriodStart:     start,
+		PeriodEnd:       end,
+		Spent:           spent,
+		WindowDays:      windowDays,
+		Confidence:      forecastConfidence,
+	}
+	if limit != nil && limit.Amount > 0 {
+		forecast.Limit = &limit.Amount
+		forecast.ProjectedOverage = max(forecast.Projected-limit.Amount, 0)
+		forecast.WillExceed = forecast.Projected > limit.Amount
+	}
+	return forecast, nil
+}
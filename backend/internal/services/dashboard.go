@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/execution"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// Recent activity page sizes
+const (
+	defaultActivityLimit = 20
+	maxActivityLimit     = 100
+)
+
+const (
+	// overviewTTL is how long a tenant's overview is cached, so dashboards
+	// polling it don't query the database on every request
+	overviewTTL = 5 * time.Second
+
+	// costTrendDays is how many days of costs the cost trends cover, today included
+	costTrendDays = 30
+)
+
+// DashboardService handles dashboard data
+type DashboardService struct {
+	repos       *repository.Repositories
+	redis       *repository.RedisClient
+	concurrency *execution.ConcurrencyLimiter
+	log         *logger.Logger
+
+	mu        sync.Mutex
+	overviews map[uuid.UUID]cachedOverview
+}
+
+type cachedOverview struct {
+	overview  Overview
+	expiresAt time.Time
+}
+
+func NewDashboardService(repos *repository.Repositories, redis *repository.RedisClient, concurrency *execution.ConcurrencyLimiter, log *logger.Logger) *DashboardService {
+	return &DashboardService{
+		repos:       repos,
+		redis:       redis,
+		concurrency: concurrency,
+		log:         log,
+		overviews:   make(map[uuid.UUID]cachedOverview),
+	}
+}
+
+// Overview summarizes a tenant's agents and today's executions and costs.
+// Days are UTC.
+type Overview struct {
+	ActiveAgents    int     `json:"active_agents"`
+	TotalAgents     int     `json:"total_agents"`
+	ExecutionsToday int     `json:"executions_today"`
+	CostToday       float64 `json:"cost_today"`
+}
+
+// activeAgentStatuses are the statuses of agents that can take or are taking runs
+var activeAgentStatuses = []models.AgentStatus{models.AgentStatusBriefing, models.AgentStatusReady, models.AgentStatusExecuting}
+
+// Overview returns the tenant's overview, cached for overviewTTL
+func (s *DashboardService) Overview(ctx context.Context, tenantID uuid.UUID) (*Overview, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	cached, ok := s.overviews[tenantID]
+	s.mu.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		overview := cached.overview
+		return &overview, nil
+	}
+
+	agents, err := s.repos.Agents.CountByStatus(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count agents: %w", err)
+	}
+	today := startOfDay(now)
+	runs, err := s.repos.AgentRuns.CountByStatus(ctx, tenantID, today, today.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to count runs: %w", err)
+	}
+	cost, err := s.repos.Costs.GetTotalByTenant(ctx, tenantID, today)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get costs: %w", err)
+	}
+
+	var overview Overview
+	for status, count := range agents {
+		overview.TotalAgents += count
+		if slices.Contains(activeAgentStatuses, status) {
+			overview.ActiveAgents += count
+		}
+	}
+	for _, count := range runs {
+		overview.ExecutionsToday += count
+	}
+	overview.CostToday = cost
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Drop expired entries as we go so tenants that stop polling are forgotten
+	for id, entry := range s.overviews {
+		if now.After(entry.expiresAt) {
+			delete(s.overviews, id)
+		}
+	}
+	s.overviews[tenantID] = cachedOverview{overview: overview, expiresAt: now.Add(overviewTTL)}
+	return &overview, nil
+}
+
+// AgentSummary is an agent's current status and its most recent run
+type AgentSummary struct {
+	AgentID uuid.UUID          `json:"agent_id"`
+	Name    string             `json:"name"`
+	Type    models.AgentType   `json:"type"`
+	Status  models.AgentStatus `json:"status"`
+	LastRun *LastRun           `json:"last_run"`
+}
+
+// LastRun is the most recent run of an agent
+type LastRun struct {
+	RunID       uuid.UUID        `json:"run_id"`
+	Status      models.RunStatus `json:"status"`
+	Cost        float64          `json:"cost"`
+	StartedAt   time.Time        `json:"started_at"`
+	CompletedAt *time.Time       `json:"completed_at,omitempty"`
+	Error       string           `json:"error,omitempty"`
+}
+
+// AgentsStatus returns the status of each of the tenant's agents, newest
+// agents first. Agents that have never run have no last run.
+func (s *DashboardService) AgentsStatus(ctx context.Context, tenantID uuid.UUID) ([]AgentSummary, error) {
+	agents, err := s.repos.Agents.ListByTenant(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agents: %w", err)
+	}
+	latest, err := s.repos.AgentRuns.LatestByAgent(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest runs: %w", err)
+	}
+
+	statuses := make([]AgentSummary, len(agents))
+	for i, agent := range agents {
+		statuses[i] = AgentSummary{
+			AgentID: agent.ID,
+			Name:    agent.Name,
+			Type:    agent.Type,
+			Status:  agent.Status,
+		}
+		if run, ok := latest[agent.ID]; ok {
+			statuses[i].LastRun = &LastRun{
+				RunID:       run.ID,
+				Status:      run.Status,
+				Cost:        run.Cost,
+				StartedAt:   run.StartedAt,
+				CompletedAt: run.CompletedAt,
+				Error:       run.Error,
+			}
+		}
+	}
+	return statuses, nil
+}
+
+// DailyCost is a tenant's cost on one UTC day
+type DailyCost struct {
+	Date string  `json:"date"` // YYYY-MM-DD
+	Cost float64 `json:"cost"`
+}
+
+// CostTrends returns the tenant's cost on each of the last costTrendDays
+// days, oldest first and ending today. Days without costs are zero.
+func (s *DashboardService) CostTrends(ctx context.Context, tenantID uuid.UUID) ([]DailyCost, error) {
+	until := startOfDay(time.Now()).AddDate(0, 0, 1)
+	since := until.AddDate(0, 0, -costTrendDays)
+	totals, err := s.repos.Costs.DailyTotals(ctx, tenantID, since, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daily costs: %w", err)
+	}
+	return DailyCosts(since, totals), nil
+}
+
+// DailyCosts labels daily totals, oldest first, with their days from since
+func DailyCosts(since time.Time, totals []float64) []DailyCost {
+	trends := make([]DailyCost, len(totals))
+	for i, total := range totals {
+		trends[i] = DailyCost{Date: since.AddDate(0, 0, i).Format("2006-01-02"), Cost: total}
+	}
+	return trends
+}
+
+// startOfDay returns midnight UTC of t's day
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Concurrency returns how many of the tenant's runs are executing and queued
+// on this instance, against its plan's limit
+func (s *DashboardService) Concurrency(ctx context.Context, tenantID uuid.UUID) execution.ConcurrencyStats {
+	return s.concurrency.Stats(ctx, tenantID)
+}
+
+// Activity is a run shown in the recent activity feed
+type Activity struct {
+	RunID       uuid.UUID        `json:"run_id"`
+	AgentID     uuid.UUID        `json:"agent_id"`
+	AgentName   string           `json:"agent_name"`
+	Status      models.RunStatus `json:"status"`
+	Cost        float64          `json:"cost"`
+	TokensUsed  int              `json:"tokens_used"`
+	StartedAt   time.Time        `json:"started_at"`
+	CompletedAt *time.Time       `json:"completed_at,omitempty"`
+
+	// CostAnomaly flags a run that cost far more than the agent's recent
+	// runs, whose average cost was CostBaseline
+	CostAnomaly  bool     `json:"cost_anomaly,omitempty"`
+	CostBaseline *float64 `json:"cost_baseline,omitempty"`
+}
+
+// RecentActivity returns the tenant's most recent runs across all its agents
+func (s *DashboardService) RecentActivity(ctx context.Context, tenantID uuid.UUID, limit, offset int) ([]Activity, error) {
+	if limit <= 0 {
+		limit = defaultActivityLimit
+	}
+	if limit > maxActivityLimit {
+		limit = maxActivityLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	runs, err := s.repos.AgentRuns.ListByTenant(ctx, tenantID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list runs: %w", err)
+	}
+
+	return s.activity(ctx, tenantID, runs)
+}
+
+// CostAnomalies returns the tenant's most recent runs flagged as costing far
+// more than their agent's recent runs
+func (s *DashboardService) CostAnomalies(ctx context.Context, tenantID uuid.UUID, limit int) ([]Activity, error) {
+	if limit <= 0 {
+		limit = defaultActivityLimit
+	}
+	if limit > maxActivityLimit {
+		limit = maxActivityLimit
+	}
+
+	runs, err := s.repos.AgentRuns.ListCostAnomalies(ctx, tenantID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cost anomalies: %w", err)
+	}
+	return s.activity(ctx, tenantID, runs)
+}
+
+// activity describes the tenant's runs for the dashboard, with their agents' names
+func (s *DashboardService) activity(ctx context.Context, tenantID uuid.UUID, runs []*models.AgentRun) ([]Activity, error) {
+	agents, err := s.repos.Agents.ListByTenant(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agents: %w", err)
+	}
+	names := make(map[uuid.UUID]string, len(agents))
+	for _, agent := range agents {
+		names[agent.ID] = agent.Name
+	}
+
+	activity := make([]Activity, len(runs))
+	for i, run := range runs {
+		activity[i] = Activity{
+			RunID:        run.ID,
+			AgentID:      run.AgentID,
+			AgentName:    names[run.AgentID],
+			Status:       run.Status,
+			Cost:         run.Cost,
+			TokensUsed:   run.TokensUsed,
+			StartedAt:    run.StartedAt,
+			CompletedAt:  run.CompletedAt,
+			CostAnomaly:  run.CostAnomaly,
+			CostBaseline: run.CostBaseline,
+		}
+	}
+	return activity, nil
+}
@@ -0,0 +1,386 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/notifications"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/internal/security"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+)
+
+const (
+	digestCheckInterval = 5 * time.Minute
+	maxDueDigests       = 50
+	digestTopAgents     = 5
+	digestPeriod        = 7 * 24 * time.Hour
+)
+
+// DigestService sends each tenant a weekly summary of its activity and spend
+// on the day and time the tenant chooses. Every instance runs the digest loop;
+// a Postgres advisory lock lets only one of them send at a time.
+type DigestService struct {
+	repos        *repository.Repositories
+	cost         *CostService
+	notification *NotificationService
+	log          *logger.Logger
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewDigestService creates a new digest service and starts its digest loop
+func NewDigestService(repos *repository.Repositories, cost *CostService, notification *NotificationService, log *logger.Logger) *DigestService {
+	s := &DigestService{
+		repos:        repos,
+		cost:         cost,
+		notification: notification,
+		log:          log,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	go s.digestLoop()
+	return s
+}
+
+// Stop stops the digest loop, waiting for digests being sent to finish
+func (s *DigestService) Stop() {
+	close(s.stop)
+	<-s.done
+}
+
+// UpdateDigestScheduleRequest represents digest schedule changes; unset
+// fields are kept. DayOfWeek counts from Sunday (0), TimeOfDay is HH:MM and
+// Timezone is an IANA name.
+type UpdateDigestScheduleRequest struct {
+	Enabled   *bool   `json:"enabled"`
+	DayOfWeek *int    `json:"day_of_week"`
+	TimeOfDay *string `json:"time_of_day"`
+	Timezone  *string `json:"timezone"`
+}
+
+// DigestStats are a tenant's figures for one week
+type DigestStats struct {
+	Executions int     `json:"executions"`
+	FailedRuns int     `json:"failed_runs"`
+	TotalCost  float64 `json:"total_cost"`
+	PRsCreated int     `json:"prs_created"`
+}
+
+// WeeklyDigest summarizes a tenant's week, with the week before it for comparison
+type WeeklyDigest struct {
+	TenantID    uuid.UUID   `json:"tenant_id"`
+	PeriodStart time.Time   `json:"period_start"`
+	PeriodEnd   time.Time   `json:"period_end"`
+	Current     DigestStats `json:"current"`
+	Previous    DigestStats `json:"previous"`
+	TopAgents   []AgentCost `json:"top_agents"`
+}
+
+// defaultDigestSchedule is the schedule of a tenant that hasn't chosen one:
+// Mondays at 09:00 UTC
+func defaultDigestSchedule(tenantID uuid.UUID) *models.DigestSchedule {
+	now := time.Now()
+	return &models.DigestSchedule{
+		TenantID:  tenantID,
+		Enabled:   true,
+		DayOfWeek: int(time.Monday),
+		TimeOfDay: "09:00",
+		Timezone:  "UTC",
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// nextDigestAt returns the first time after the given one that a digest
+// schedule fires
+func nextDigestAt(sched *models.DigestSchedule, after time.Time) (time.Time, error) {
+	minutes, err := parseClock(sched.TimeOfDay)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time of day: %s", sched.TimeOfDay)
+	}
+	loc, err := time.LoadLocation(sched.Timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timezone: %s", sched.Timezone)
+	}
+
+	local := after.In(loc)
+	next := time.Date(local.Year(), local.Month(), local.Day(), minutes/60, minutes%60, 0, 0, loc)
+	next = next.AddDate(0, 0, (sched.DayOfWeek-int(next.Weekday())+7)%7)
+	if !next.After(after) {
+		next = next.AddDate(0, 0, 7)
+	}
+	return next.UTC(), nil
+}
+
+// GetSchedule returns the tenant's digest schedule, or the default one if it
+// hasn't been picked up by the digest loop yet
+func (s *DigestService) GetSchedule(ctx context.Context, tenantID uuid.UUID) (*models.DigestSchedule, error) {
+	sched, err := s.repos.Digests.GetSchedule(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get digest schedule: %w", err)
+	}
+	if sched == nil {
+		sched = defaultDigestSchedule(tenantID)
+	}
+	return sched, nil
+}
+
+// UpdateSchedule changes when the tenant's digest is sent
+func (s *DigestService) UpdateSchedule(ctx context.Context, tenantID uuid.UUID, req *UpdateDigestScheduleRequest) (*models.DigestSchedule, error) {
+	sched, err := s.GetSchedule(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Enabled != nil {
+		sched.Enabled = *req.Enabled
+	}
+	if req.DayOfWeek != nil {
+		if *req.DayOfWeek < 0 || *req.DayOfWeek > 6 {
+			return nil, fmt.Errorf("day_of_week must be between 0 (Sunday) and 6 (Saturday)")
+		}
+		sched.DayOfWeek = *req.DayOfWeek
+	}
+	if req.TimeOfDay != nil {
+		sched.TimeOfDay = *req.TimeOfDay
+	}
+	if req.Timezone != nil {
+		sched.Timezone = *req.Timezone
+		if sched.Timezone == "" {
+			sched.Timezone = "UTC"
+		}
+	}
+
+	next, err := nextDigestAt(sched, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	sched.NextRunAt = nil
+	if sched.Enabled {
+		sched.NextRunAt = &next
+	}
+	sched.UpdatedAt = time.Now()
+
+	if err := s.repos.Digests.SaveSchedule(ctx, sched); err != nil {
+		return nil, fmt.Errorf("failed to save digest schedule: %w", err)
+	}
+
+	s.log.Infow("digest schedule updated",
+		"tenant_id", tenantID,
+		"enabled", sched.Enabled,
+		"day_of_week", sched.DayOfWeek,
+		"time_of_day", sched.TimeOfDay,
+		"timezone", sched.Timezone,
+	)
+	return sched, nil
+}
+
+// Preview builds the digest for the week up to now without sending it
+func (s *DigestService) Preview(ctx context.Context, tenantID uuid.UUID) (*WeeklyDigest, error) {
+	return s.Build(ctx, tenantID, time.Now())
+}
+
+// Build summarizes the tenant's week ending at end and the week before it
+func (s *DigestService) Build(ctx context.Context, tenantID uuid.UUID, end time.Time) (*WeeklyDigest, error) {
+	start := end.Add(-digestPeriod)
+	digest := &WeeklyDigest{
+		TenantID:    tenantID,
+		PeriodStart: start,
+		PeriodEnd:   end,
+		TopAgents:   []AgentCost{},
+	}
+
+	agentCosts, err := s.cost.ByAgent(ctx, tenantID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.stats(ctx, tenantID, start, end, agentCosts, &digest.Current); err != nil {
+		return nil, err
+	}
+	for _, cost := range agentCosts {
+		if cost.AgentID != nil && len(digest.TopAgents) < digestTopAgents {
+			digest.TopAgents = append(digest.TopAgents, cost)
+		}
+	}
+
+	prevStart := start.Add(-digestPeriod)
+	prevCosts, err := s.cost.ByAgent(ctx, tenantID, prevStart, start)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.stats(ctx, tenantID, prevStart, start, prevCosts, &digest.Previous); err != nil {
+		return nil, err
+	}
+
+	return digest, nil
+}
+
+// stats fills in the tenant's figures for [since, until) given its costs by
+// agent over the same period
+func (s *DigestService) stats(ctx context.Context, tenantID uuid.UUID, since, until time.Time, costs []AgentCost, stats *DigestStats) error {
+	for _, cost := range costs {
+		stats.TotalCost += cost.Cost
+	}
+
+	counts, err := s.repos.AgentRuns.CountByStatus(ctx, tenantID, since, until)
+	if err != nil {
+		return fmt.Errorf("failed to count runs: %w", err)
+	}
+	for _, count := range counts {
+		stats.Executions += count
+	}
+	stats.FailedRuns = counts[models.RunStatusFailed]
+
+	prs, err := s.repos.Audit.CountAction(ctx, tenantID, string(security.AuditActionPRCreated), since, until)
+	if err != nil {
+		return fmt.Errorf("failed to count pull requests: %w", err)
+	}
+	stats.PRsCreated = prs
+	return nil
+}
+
+// formatDigest renders a digest as plain text, one figure per line, each with
+// its change from the previous week
+func formatDigest(d *WeeklyDigest) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s to %s\n\n", d.PeriodStart.UTC().Format("Jan 2"), d.PeriodEnd.UTC().Format("Jan 2, 2006"))
+	fmt.Fprintf(&b, "Executions: %d (%s)\n", d.Current.Executions, countDelta(d.Current.Executions, d.Previous.Executions))
+	fmt.Fprintf(&b, "Failed runs: %d (%s)\n", d.Current.FailedRuns, countDelta(d.Current.FailedRuns, d.Previous.FailedRuns))
+	fmt.Fprintf(&b, "Total cost: $%.2f (%s)\n", d.Current.TotalCost, costDelta(d.Current.TotalCost, d.Previous.TotalCost))
+	fmt.Fprintf(&b, "PRs created: %d (%s)\n", d.Current.PRsCreated, countDelta(d.Current.PRsCreated, d.Previous.PRsCreated))
+
+	if len(d.TopAgents) > 0 {
+		b.WriteString("\nTop oracles by spend:\n")
+		for i, agent := range d.TopAgents {
+			name := agent.AgentName
+			if name == "" {
+				name = agent.AgentID.String()
+			}
+			fmt.Fprintf(&b, "%d. %s: $%.2f\n", i+1, name, agent.Cost)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// countDelta describes the change from prev to cur
+func countDelta(cur, prev int) string {
+	switch {
+	case cur > prev:
+		return fmt.Sprintf("+%d from last week", cur-prev)
+	case cur < prev:
+		return fmt.Sprintf("-%d from last week", prev-cur)
+	default:
+		return "same as last week"
+	}
+}
+
+// costDelta describes the change from prev to cur as a percentage, or in
+// dollars when there was no spend the week before
+func costDelta(cur, prev float64) string {
+	diff := cur - prev
+	switch {
+	case math.Abs(diff) < 0.005:
+		return "same as last week"
+	case prev < 0.005:
+		return fmt.Sprintf("+$%.2f from last week", diff)
+	default:
+		return fmt.Sprintf("%+.0f%% from last week", diff/prev*100)
+	}
+}
+
+func (s *DigestService) digestLoop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(digestCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.sendDue(context.Background())
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// sendDue gives new tenants the default schedule and sends every due digest,
+// unless another instance is already doing so
+func (s *DigestService) sendDue(ctx context.Context) {
+	acquired, err := s.repos.Digests.WithDigestLock(ctx, func(ctx context.Context) error {
+		if _, err := s.repos.Digests.SeedSchedules(ctx); err != nil {
+			return fmt.Errorf("failed to seed digest schedules: %w", err)
+		}
+		due, err := s.repos.Digests.ListDue(ctx, time.Now(), maxDueDigests)
+		if err != nil {
+			return fmt.Errorf("failed to list due digests: %w", err)
+		}
+		for _, sched := range due {
+			s.send(ctx, sched)
+		}
+		return nil
+	})
+	if err != nil {
+		s.log.Warnw("failed to send digests", "error", err)
+		return
+	}
+	if !acquired {
+		s.log.Debugw("digest lock held by another instance")
+	}
+}
+
+// send advances a due schedule and sends its digest. A schedule that has never
+// run is only advanced, so a new tenant's first digest waits for its day. The
+// digest covers the week up to the time it was due, and tenants with no
+// activity in either week are skipped.
+func (s *DigestService) send(ctx context.Context, sched *models.DigestSchedule) {
+	next, err := nextDigestAt(sched, time.Now())
+	if err != nil {
+		s.log.Warnw("skipping digest schedule that cannot be evaluated", "tenant_id", sched.TenantID, "error", err)
+		return
+	}
+
+	advanced, err := s.repos.Digests.Advance(ctx, sched.TenantID, sched.NextRunAt, next)
+	if err != nil {
+		s.log.Errorw("failed to advance digest schedule", "tenant_id", sched.TenantID, "error", err)
+		return
+	}
+	if !advanced || sched.NextRunAt == nil {
+		return
+	}
+
+	digest, err := s.Build(ctx, sched.TenantID, *sched.NextRunAt)
+	if err != nil {
+		s.log.Errorw("failed to build weekly digest", "tenant_id", sched.TenantID, "error", err)
+		return
+	}
+	if digest.Current == (DigestStats{}) && digest.Previous == (DigestStats{}) {
+		s.log.Debugw("skipping weekly digest with no activity", "tenant_id", sched.TenantID)
+		return
+	}
+
+	notification := notifications.WeeklyDigestNotification(sched.TenantID, digest.PeriodEnd, formatDigest(digest), map[string]interface{}{
+		"period_start": digest.PeriodStart,
+		"period_end":   digest.PeriodEnd,
+		"executions":   digest.Current.Executions,
+		"failed_runs":  digest.Current.FailedRuns,
+		"total_cost":   digest.Current.TotalCost,
+		"prs_created":  digest.Current.PRsCreated,
+	})
+	if err := s.notification.Send(ctx, notification); err != nil {
+		s.log.Warnw("failed to send weekly digest", "tenant_id", sched.TenantID, "error", err)
+		return
+	}
+
+	if err := s.repos.Digests.MarkSent(ctx, sched.TenantID, time.Now()); err != nil {
+		s.log.Warnw("failed to record weekly digest", "tenant_id", sched.TenantID, "error", err)
+	}
+	s.log.Infow("weekly digest sent", "tenant_id", sched.TenantID, "next_run_at", next)
+}
@@ -3,32 +3,102 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/delphi-platform/delphi/backend/internal/billing"
 	"github.com/delphi-platform/delphi/backend/internal/config"
+	"github.com/delphi-platform/delphi/backend/internal/execution"
+	"github.com/delphi-platform/delphi/backend/internal/metrics"
 	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/payload"
+	"github.com/delphi-platform/delphi/backend/internal/providers"
 	"github.com/delphi-platform/delphi/backend/internal/repository"
 	"github.com/delphi-platform/delphi/backend/pkg/logger"
 	"github.com/google/uuid"
 )
 
+// ErrBudgetExceeded is returned when a tenant has reached one of its cost limits
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// ErrBillingRestricted is returned when a tenant restricted for non-payment
+// asks for work its provider would bill
+var ErrBillingRestricted = errors.New("tenant is restricted for non-payment")
+
+// ErrMaxConcurrentRuns is returned when a tenant's concurrent runs and queue are full
+var ErrMaxConcurrentRuns = execution.ErrMaxConcurrentRuns
+
+// ErrRunNotActive is returned when cancelling a run that is not in progress on
+// this instance
+var ErrRunNotActive = errors.New("run is not active")
+
+// ErrRunFinished is returned when cancelling a run that has already finished
+var ErrRunFinished = errors.New("run has already finished")
+
 // ExecuteService handles agent execution
 type ExecuteService struct {
-	cfg   *config.Config
-	repos *repository.Repositories
-	redis *repository.RedisClient
-	log   *logger.Logger
+	cfg          *config.Config
+	repos        *repository.Repositories
+	redis        *repository.RedisClient
+	runLogs      *WebSocketService
+	notification *NotificationService
+	budgetAlerts *BudgetAlertService
+	webhooks     *WebhookDeliveryService
+	concurrency  *execution.ConcurrencyLimiter
+	payloads     *payload.Limiter
+	providers    *providers.Manager
+	repositories *RepositoryService
+	active       *execution.ActiveRuns
+	runner       *execution.ExecutionRunner
+	log          *logger.Logger
+
+	queueMu sync.Mutex
+	queued  map[uuid.UUID]queuedRun
+}
+
+// queuedRun is a run of this instance waiting for its concurrency slot
+type queuedRun struct {
+	tenantID uuid.UUID
+	slot     *execution.Slot
 }
 
-// NewExecuteService creates a new execute service
-func NewExecuteService(cfg *config.Config, repos *repository.Repositories, redis *repository.RedisClient, log *logger.Logger) *ExecuteService {
-	return &ExecuteService{
-		cfg:   cfg,
-		repos: repos,
-		redis: redis,
-		log:   log,
+// NewExecuteService creates a new execute service. Finished runs are published
+// to the tenant's webhooks. Runs over the tenant's concurrency limit stay
+// pending until a slot frees up. Prompts and results over the payload limits
+// are stored truncated. Cost estimates are priced by the provider manager.
+// Coding agents' pull requests are opened through the repository service.
+// Queued runs' positions are logged to their live logs as the queue drains.
+func NewExecuteService(cfg *config.Config, repos *repository.Repositories, redis *repository.RedisClient, runLogs *WebSocketService, notification *NotificationService, webhooks *WebhookDeliveryService, concurrency *execution.ConcurrencyLimiter, payloads *payload.Limiter, providerManager *providers.Manager, repositories *RepositoryService, log *logger.Logger) *ExecuteService {
+	s := &ExecuteService{
+		cfg:          cfg,
+		repos:        repos,
+		redis:        redis,
+		runLogs:      runLogs,
+		notification: notification,
+		budgetAlerts: NewBudgetAlertService(cfg, repos, redis, notification, log),
+		webhooks:     webhooks,
+		concurrency:  concurrency,
+		payloads:     payloads,
+		providers:    providerManager,
+		repositories: repositories,
+		active:       execution.NewActiveRuns(),
+		log:          log,
+		queued:       make(map[uuid.UUID]queuedRun),
 	}
+	if concurrency != nil {
+		concurrency.SetQueueListener(s.publishQueuePositions)
+	}
+	return s
+}
+
+// SetRunner sets the runner that executes runs: on Fly Machines when they
+// are configured, and otherwise in-process through the agent's provider.
+// Without one, runs fail.
+func (s *ExecuteService) SetRunner(runner *execution.ExecutionRunner) {
+	s.runner = runner
 }
 
 // ExecuteRequest represents an execution request
@@ -36,6 +106,9 @@ type ExecuteRequest struct {
 	AgentID uuid.UUID `json:"agent_id"`
 	Prompt  string    `json:"prompt"`
 	Context map[string]interface{} `json:"context,omitempty"`
+
+	// NoCache runs the agent even when an identical run's result is cached
+	NoCache bool `json:"-"`
 }
 
 // ExecuteResponse represents execution result
@@ -48,28 +121,14 @@ type ExecuteResponse struct {
 
 // Create creates a new execution
 func (s *ExecuteService) Create(ctx context.Context, tenantID uuid.UUID, req *ExecuteRequest) (*models.AgentRun, error) {
-	// Get agent
-	agent, err := s.repos.Agents.GetByID(ctx, req.AgentID)
+	agent, err := s.runnableAgent(ctx, tenantID, req.AgentID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get agent: %w", err)
-	}
-	if agent == nil || agent.TenantID != tenantID {
-		return nil, fmt.Errorf("agent not found")
+		return nil, err
 	}
 
-	// Check agent is ready
-	if agent.Status != models.AgentStatusReady {
-		return nil, fmt.Errorf("agent is not ready, current status: %s", agent.Status)
-	}
-
-	// Check budget limits
-	if agent.Config.BudgetLimit > 0 {
-		spent, err := s.repos.Costs.GetTotalByAgent(ctx, agent.ID, time.Now().AddDate(0, -1, 0))
-		if err != nil {
-			s.log.Warnw("failed to check budget", "agent_id", agent.ID, "error", err)
-		} else if spent >= agent.Config.BudgetLimit {
-			return nil, fmt.Errorf("agent has exceeded its monthly budget limit")
-		}
+	slot, err := s.reserveSlot(ctx, tenantID)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create run record
@@ -82,8 +141,9 @@ func (s *ExecuteService) Create(ctx context.Context, tenantID uuid.UUID, req *Ex
 		StartedAt: time.Now(),
 	}
 
-	if err := s.repos.AgentRuns.Create(ctx, run); err != nil {
-		return nil, fmt.Errorf("failed to create run: %w", err)
+	if err := s.createRun(ctx, run, agent); err != nil {
+		slot.Release()
+		return nil, err
 	}
 
 	// Update agent status to executing
@@ -92,64 +152,439 @@ func (s *ExecuteService) Create(ctx context.Context, tenantID uuid.UUID, req *Ex
 	}
 
 	// Start execution asynchronously
-	go s.executeRun(context.Background(), agent, run)
+	go s.executeRun(s.active.Start(context.Background(), run.ID), agent, run, slot, !req.NoCache)
 
+	s.runLog(ctx, run.ID, models.LogLevelInfo, "run queued", nil)
 	s.log.Infow("execution started", "run_id", run.ID, "agent_id", agent.ID, "tenant_id", tenantID)
 
 	return run, nil
 }
 
-// executeRun performs the actual agent execution
-func (s *ExecuteService) executeRun(ctx context.Context, agent *models.Agent, run *models.AgentRun) {
+// Estimate is the projected size and cost of running an agent on a prompt
+type Estimate struct {
+	AgentID         uuid.UUID `json:"agent_id"`
+	Model           string    `json:"model"`
+	InputTokens     int       `json:"input_tokens"`
+	MaxOutputTokens int       `json:"max_output_tokens"`
+	InputCost       float64   `json:"input_cost"`
+	ProjectedCost   float64   `json:"projected_cost"`
+}
+
+// Estimate counts the input tokens of running an agent on a prompt, its system
+// prompt included, and prices them without running it. The projected cost
+// assumes the whole output budget is used, so it is an upper bound.
+func (s *ExecuteService) Estimate(ctx context.Context, tenantID uuid.UUID, req *ExecuteRequest) (*Estimate, error) {
+	if strings.TrimSpace(req.Prompt) == "" {
+		return nil, fmt.Errorf("prompt is required")
+	}
+
+	agent, err := s.repos.Agents.GetByID(ctx, req.AgentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get agent: %w", err)
+	}
+	if agent == nil || agent.TenantID != tenantID {
+		return nil, fmt.Errorf("agent not found")
+	}
+
+	maxOutput := agent.Config.MaxTokens
+	if info, ok := s.providers.GetModelInfo(agent.Model); ok && maxOutput == 0 {
+		maxOutput = info.MaxOutput
+	}
+
+	input := providers.CountTokens(agent.Model, agent.SystemPrompt) + providers.CountTokens(agent.Model, req.Prompt)
+	inputCost := s.providers.CalculateCost(agent.Model, providers.TokenUsage{PromptTokens: input, TotalTokens: input})
+	return &Estimate{
+		AgentID:         agent.ID,
+		Model:           agent.Model,
+		InputTokens:     input,
+		MaxOutputTokens: maxOutput,
+		InputCost:       inputCost,
+		ProjectedCost: s.providers.CalculateCost(agent.Model, providers.TokenUsage{
+			PromptTokens:     input,
+			CompletionTokens: maxOutput,
+			TotalTokens:      input + maxOutput,
+		}),
+	}, nil
+}
+
+// runnableAgent returns the tenant's agent if it can start a run: it must be
+// ready, on a model that is not retired, and within its budget, and the tenant
+// must not be restricted for non-payment
+func (s *ExecuteService) runnableAgent(ctx context.Context, tenantID, agentID uuid.UUID) (*models.Agent, error) {
+	agent, err := s.repos.Agents.GetByID(ctx, agentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get agent: %w", err)
+	}
+	if agent == nil || agent.TenantID != tenantID {
+		return nil, fmt.Errorf("agent not found")
+	}
+	if err := s.checkBillingRestriction(ctx, tenantID); err != nil {
+		return nil, err
+	}
+
+	// Check agent is ready
+	if agent.Status != models.AgentStatusReady {
+		return nil, fmt.Errorf("agent is not ready, current status: %s", agent.Status)
+	}
+
+	// Refuse retired models, warn about ones being sunset
+	modelWarning, err := providers.CheckModel(agent.Model)
+	if err != nil {
+		return nil, err
+	}
+	if modelWarning != "" {
+		s.log.Warnw("agent uses a deprecated model", "agent_id", agent.ID, "model", agent.Model, "warning", modelWarning)
+	}
+	if err := CheckAgentCapabilities(agent.Model, agent.Tools, agent.Config); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkBudget(ctx, agent); err != nil {
+		return nil, err
+	}
+	return agent, nil
+}
+
+// checkBudget rejects the run if the agent has reached its budget limit for the
+// calendar month, the same period as the tenant's monthly cost limit
+func (s *ExecuteService) checkBudget(ctx context.Context, agent *models.Agent) error {
+	if agent.Config.BudgetLimit <= 0 {
+		return nil
+	}
+	start, _, err := repository.BudgetPeriod(&models.CostLimit{LimitType: "monthly"}, time.Now())
+	if err != nil {
+		return err
+	}
+	spent, err := s.repos.Costs.GetTotalByAgent(ctx, agent.ID, start)
+	if err != nil {
+		s.log.Warnw("failed to check budget", "agent_id", agent.ID, "error", err)
+		return nil
+	}
+	if spent >= agent.Config.BudgetLimit {
+		return fmt.Errorf("agent has exceeded its monthly budget limit")
+	}
+	return nil
+}
+
+// checkBillingRestriction rejects runs of a tenant restricted for non-payment
+func (s *ExecuteService) checkBillingRestriction(ctx context.Context, tenantID uuid.UUID) error {
+	tenant, err := s.repos.Tenants.GetByID(ctx, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to get tenant: %w", err)
+	}
+	if tenant != nil && billing.IsRestricted(tenant) {
+		return ErrBillingRestricted
+	}
+	return nil
+}
+
+// reserveSlot claims one of the tenant's concurrent runs, or a place in its
+// queue. It fails with ErrMaxConcurrentRuns when the queue is full.
+func (s *ExecuteService) reserveSlot(ctx context.Context, tenantID uuid.UUID) (*execution.Slot, error) {
+	slot, err := s.concurrency.Reserve(ctx, tenantID)
+	if err != nil {
+		s.log.Warnw("execution refused over concurrency limit", "tenant_id", tenantID, "error", err)
+		return nil, err
+	}
+	return slot, nil
+}
+
+// createRun records a new run of the agent with the parameters it runs with,
+// refusing it with ErrBudgetExceeded if the tenant has reached its daily or
+// monthly cost limit. A prompt over the payload limit is stored truncated.
+func (s *ExecuteService) createRun(ctx context.Context, run *models.AgentRun, agent *models.Agent) error {
+	params, err := json.Marshal(models.NewRunParameters(agent))
+	if err != nil {
+		return fmt.Errorf("failed to encode run parameters: %w", err)
+	}
+	run.Parameters = params
+
+	prompt, ref, err := s.payloads.LimitPrompt(ctx, runPayloadKey(run.ID), run.Prompt)
+	if err != nil {
+		return fmt.Errorf("failed to limit prompt: %w", err)
+	}
+	run.Prompt, run.PromptRef = prompt, ref
+
+	windows, err := s.repos.Costs.BudgetWindows(ctx, run.TenantID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to get cost limits: %w", err)
+	}
+
+	err = s.repos.AgentRuns.CreateWithinBudget(ctx, run, windows)
+	if err != nil {
+		var exceeded *repository.BudgetExceededError
+		if errors.As(err, &exceeded) {
+			s.log.Warnw("execution refused over budget",
+				"tenant_id", run.TenantID,
+				"agent_id", run.AgentID,
+				"limit_type", exceeded.Limit.LimitType,
+				"limit", exceeded.Limit.Amount,
+				"spent", exceeded.Spent,
+				"reserved", exceeded.Reserved,
+			)
+			return fmt.Errorf("%w: %s", ErrBudgetExceeded, exceeded.Error())
+		}
+		return fmt.Errorf("failed to create run: %w", err)
+	}
+	return nil
+}
+
+// executeRun performs the actual agent execution once the run's concurrency
+// slot is granted. The slot is released however the run ends, including a panic.
+// ctx is the run's context from the active runs; cancelling it stops the run,
+// which Cancel has already recorded. With useCache, a deterministic agent's
+// run reuses the result of an identical earlier run when one is cached.
+func (s *ExecuteService) executeRun(ctx context.Context, agent *models.Agent, run *models.AgentRun, slot *execution.Slot, useCache bool) {
+	defer s.active.Finish(run.ID)
+	defer slot.Release()
+
+	// The run's records are written even once it is cancelled
+	runCtx := ctx
+	ctx = context.WithoutCancel(ctx)
+
+	defer func() {
+		if r := recover(); r != nil {
+			s.log.Errorw("execution panicked", "run_id", run.ID, "agent_id", agent.ID, "panic", r)
+			s.failRun(ctx, agent, run, fmt.Sprintf("execution panicked: %v", r))
+		}
+	}()
+
+	s.setQueued(run, slot)
+	err := slot.Wait(runCtx)
+	s.setQueued(run, nil)
+	if err != nil {
+		if execution.Cancelled(runCtx) {
+			return
+		}
+		s.failRun(ctx, agent, run, "run was not started: "+err.Error())
+		return
+	}
+
 	s.log.Infow("executing agent run", "run_id", run.ID, "agent_id", agent.ID)
 
 	// Update status to running
 	s.repos.AgentRuns.UpdateStatus(ctx, run.ID, models.RunStatusRunning)
+	s.runLog(ctx, run.ID, models.LogLevelInfo, "run started", map[string]interface{}{
+		"provider": agent.Provider,
+		"model":    agent.Model,
+	})
+
+	// Identical runs of a deterministic agent give the same result, so an
+	// earlier one's is reused without calling the provider
+	cacheKey := ""
+	if useCache && s.responseCacheable(agent) {
+		cacheKey = ResponseCacheKey(agent.ID, agent.Model, agent.SystemPrompt, run.Prompt, agent.Config.SamplingTemperature())
+		if execution.Cancelled(runCtx) {
+			return
+		}
+		if s.completeFromCache(ctx, agent, run, cacheKey) {
+			return
+		}
+	}
+
+	// The run fails once it outlives the agent's timeout. The machine, once
+	// there is one, is destroyed with the run.
+	timeout := s.runTimeout(agent)
+	runCtx, cancel := context.WithTimeout(runCtx, timeout)
+	defer cancel()
+
+	if s.runner == nil {
+		s.failRun(ctx, agent, run, "no execution runner is configured")
+		return
+	}
+	executed, err := s.runner.Execute(runCtx, s.executionRequest(ctx, agent, run))
+
+	// Usage is recorded for failed runs too, as the provider bills it. It is
+	// attributed to the model that served the run, which is a fallback model
+	// when the agent's own failed.
+	tokensUsed, cost := executed.TokensUsed, executed.Cost
+	if tokensUsed > 0 || cost > 0 {
+		costRecord := &models.CostRecord{
+			ID:           uuid.New(),
+			TenantID:     run.TenantID,
+			AgentID:      &agent.ID,
+			RunID:        &run.ID,
+			Provider:     executed.Provider,
+			Model:        executed.Model,
+			InputTokens:  executed.InputTokens,
+			OutputTokens: executed.OutputTokens,
+			Cost:         cost,
+			CreatedAt:    time.Now(),
+		}
+		if err := s.repos.Costs.RecordCost(ctx, costRecord); err != nil {
+			s.log.Warnw("failed to record cost", "run_id", run.ID, "error", err)
+		} else {
+			s.budgetAlerts.Notify(ctx, run.TenantID, cost)
+		}
+	}
+
+	if err != nil {
+		switch {
+		case execution.Cancelled(runCtx):
+			s.log.Infow("execution stopped", "run_id", run.ID, "agent_id", agent.ID)
+		case errors.Is(err, execution.ErrRunTimeout) || runCtx.Err() != nil:
+			s.failRun(ctx, agent, run, execution.TimeoutError(timeout).Error())
+		default:
+			s.failRun(ctx, agent, run, err.Error())
+		}
+		return
+	}
+	result := runResult(agent, executed.Response)
+
+	// A run cancelled as it finished keeps its cancelled status
+	if execution.Cancelled(runCtx) {
+		return
+	}
+
+	// A coding agent's result is normalized to the coding result schema. One
+	// that doesn't match is kept as it is, and the run is only partial.
+	invalidResult := ""
+	var codingResult json.RawMessage
+	if agent.Type == models.AgentTypeCoding {
+		normalized, err := NormalizeCodingResult(result)
+		if err != nil {
+			invalidResult = "result does not match the coding result schema: " + err.Error()
+		} else {
+			result = normalized
+			codingResult = normalized
+		}
+	}
+
+	if cacheKey != "" && invalidResult == "" {
+		s.cacheResponse(ctx, run, cacheKey, result)
+	}
 
-	// In production, this would:
-	// 1. Create a Fly.io Machine with the agent container
-	// 2. Pass the prompt and context to the container
-	// 3. Stream execution logs
-	// 4. Collect results and costs
-	// 5. Tear down the machine
-
-	// For now, simulate execution
-	time.Sleep(time.Duration(agent.Config.TimeoutSeconds/10) * time.Second)
-
-	// Simulate successful completion
-	result := json.RawMessage(`{"message": "Task completed successfully", "details": "This is a simulated execution result"}`)
-	tokensUsed := 1500
-	cost := float64(tokensUsed) * 0.00001 // Simplified cost calculation
-
-	// Record cost
-	costRecord := &models.CostRecord{
-		ID:           uuid.New(),
-		TenantID:     run.TenantID,
-		AgentID:      &agent.ID,
-		RunID:        &run.ID,
-		Provider:     agent.Provider,
-		Model:        agent.Model,
-		InputTokens:  1000,
-		OutputTokens: 500,
-		Cost:         cost,
-		CreatedAt:    time.Now(),
-	}
-	if err := s.repos.Costs.RecordCost(ctx, costRecord); err != nil {
-		s.log.Warnw("failed to record cost", "run_id", run.ID, "error", err)
-	}
-
-	// Complete the run
-	if err := s.repos.AgentRuns.Complete(ctx, run.ID, result, tokensUsed, cost); err != nil {
+	// Complete the run. Costs above are for the full result, however much of it is stored.
+	result, err = s.limitResult(ctx, run, result)
+	if err != nil {
+		s.log.Errorw("failed to limit run result", "run_id", run.ID, "error", err)
+		s.failRun(ctx, agent, run, "failed to record run result")
+		return
+	}
+	if invalidResult != "" {
+		err = s.repos.AgentRuns.CompletePartial(ctx, run.ID, result, tokensUsed, cost, invalidResult)
+	} else {
+		err = s.repos.AgentRuns.Complete(ctx, run.ID, result, tokensUsed, cost)
+	}
+	if err != nil {
 		s.log.Errorw("failed to complete run", "run_id", run.ID, "error", err)
+		s.failRun(ctx, agent, run, "failed to record run result")
 		return
 	}
+	completed := map[string]interface{}{
+		"tokens_used": tokensUsed,
+		"cost":        cost,
+		"provider":    executed.Provider,
+		"model":       executed.Model,
+	}
+	if len(executed.Attempts) > 0 {
+		completed["attempts"] = executed.Attempts
+	}
+	if invalidResult != "" {
+		completed["error"] = invalidResult
+		s.runLog(ctx, run.ID, models.LogLevelWarn, "run partially completed", completed)
+	} else {
+		s.runLog(ctx, run.ID, models.LogLevelInfo, "run completed", completed)
+	}
+	if codingResult != nil {
+		s.openPullRequest(ctx, agent, run, codingResult)
+	}
+	s.publishRunEvent(ctx, run.ID, WebhookEventRunCompleted)
+
+	// Return agent to ready status, unless the run's cost paused it
+	if !s.checkCostAnomaly(ctx, agent, run, cost) {
+		if err := s.repos.Agents.UpdateStatus(ctx, agent.ID, models.AgentStatusReady); err != nil {
+			s.log.Warnw("failed to update agent status", "agent_id", agent.ID, "error", err)
+		}
+	}
+
+	s.log.Infow("execution completed", "run_id", run.ID, "agent_id", agent.ID, "tokens", tokensUsed, "cost", cost)
+}
+
+// executionRequest builds the runner's request for a run. Warm machines are
+// used when the tenant has opted into them; a tenant that can't be loaded
+// gets a cold start.
+func (s *ExecuteService) executionRequest(ctx context.Context, agent *models.Agent, run *models.AgentRun) *execution.ExecutionRequest {
+	req := &execution.ExecutionRequest{
+		Agent:  agent,
+		Run:    run,
+		Prompt: run.Prompt,
+		Tier:   metrics.TierUnknown,
+	}
+	tenant, err := s.repos.Tenants.GetByID(ctx, run.TenantID)
+	if err != nil || tenant == nil {
+		s.log.Warnw("failed to get tenant for run", "run_id", run.ID, "tenant_id", run.TenantID, "error", err)
+		return req
+	}
+	req.WarmPool = execution.WarmPoolEnabled(tenant)
+	req.Tier = metrics.Tier(string(tenant.Plan))
+	return req
+}
+
+// runResult is the stored result of a run's response. A coding agent's
+// response is its coding result, normalized before it is stored; other
+// agents' responses are stored as their message.
+func runResult(agent *models.Agent, response string) json.RawMessage {
+	if agent.Type == models.AgentTypeCoding {
+		return json.RawMessage(response)
+	}
+	result, _ := json.Marshal(map[string]string{"message": response})
+	return result
+}
+
+// runTimeout returns how long one of the agent's runs may take, clamped to
+// the server's maximum
+func (s *ExecuteService) runTimeout(agent *models.Agent) time.Duration {
+	return execution.RunTimeout(agent.Config.TimeoutSeconds,
+		time.Duration(s.cfg.DefaultRunTimeoutSeconds)*time.Second,
+		time.Duration(s.cfg.MaxRunTimeoutSeconds)*time.Second)
+}
+
+// failRun marks a run failed, returns its agent to ready and notifies the
+// tenant's webhooks
+func (s *ExecuteService) failRun(ctx context.Context, agent *models.Agent, run *models.AgentRun, reason string) {
+	if err := s.repos.AgentRuns.Fail(ctx, run.ID, reason); err != nil {
+		s.log.Errorw("failed to mark run failed", "run_id", run.ID, "error", err)
+		return
+	}
+	s.runLog(ctx, run.ID, models.LogLevelError, "run failed", map[string]interface{}{
+		"error": reason,
+	})
 
-	// Return agent to ready status
 	if err := s.repos.Agents.UpdateStatus(ctx, agent.ID, models.AgentStatusReady); err != nil {
 		s.log.Warnw("failed to update agent status", "agent_id", agent.ID, "error", err)
 	}
 
-	s.log.Infow("execution completed", "run_id", run.ID, "agent_id", agent.ID, "tokens", tokensUsed, "cost", cost)
+	s.publishRunEvent(ctx, run.ID, WebhookEventRunFailed)
+	s.log.Warnw("execution failed", "run_id", run.ID, "agent_id", agent.ID, "error", reason)
+}
+
+// publishRunEvent sends a finished run, as stored, to the tenant's webhooks.
+// Failures are logged and otherwise ignored so they never affect the run.
+func (s *ExecuteService) publishRunEvent(ctx context.Context, runID uuid.UUID, event string) {
+	if s.webhooks == nil {
+		return
+	}
+
+	run, err := s.repos.AgentRuns.GetByID(ctx, runID)
+	if err != nil || run == nil {
+		s.log.Warnw("failed to load run for webhooks", "run_id", runID, "event", event, "error", err)
+		return
+	}
+	if err := s.webhooks.Publish(ctx, run.TenantID, event, run); err != nil {
+		s.log.Warnw("failed to publish run event", "run_id", runID, "event", event, "error", err)
+	}
+}
+
+// runLog appends an entry to the run's live log. Failures are logged and
+// otherwise ignored so they never fail the run.
+func (s *ExecuteService) runLog(ctx context.Context, runID uuid.UUID, level models.LogLevel, message string, metadata map[string]interface{}) {
+	if s.runLogs == nil {
+		return
+	}
+	if err := s.runLogs.AppendRunLog(ctx, runID, level, message, metadata); err != nil {
+		s.log.Warnw("failed to append run log", "run_id", runID, "error", err)
+	}
 }
 
 // Get retrieves an execution by ID
@@ -164,7 +599,397 @@ func (s *ExecuteService) Get(ctx context.Context, tenantID, runID uuid.UUID) (*m
 	return run, nil
 }
 
-// Cancel cancels a running execution
+// Run payload kinds that can be fetched in full
+const (
+	PayloadPrompt   = "prompt"
+	PayloadResponse = "response"
+)
+
+// runPayloadKey is the storage key under which a run's full payloads are kept
+func runPayloadKey(runID uuid.UUID) string {
+	return "runs/" + runID.String()
+}
+
+// limitResult returns the result to store for a run. A result over the
+// payload limit is kept in full in payload storage and replaced by a preview
+// referencing it.
+func (s *ExecuteService) limitResult(ctx context.Context, run *models.AgentRun, result json.RawMessage) (json.RawMessage, error) {
+	preview, ref, err := s.payloads.LimitResponse(ctx, runPayloadKey(run.ID), string(result))
+	if err != nil || ref == "" {
+		return result, err
+	}
+	if err := s.repos.AgentRuns.SetResponseRef(ctx, run.ID, ref); err != nil {
+		return nil, fmt.Errorf("failed to store response reference: %w", err)
+	}
+	run.ResponseRef = ref
+
+	// A truncated result is no longer valid JSON, so it is stored as a string
+	return json.Marshal(map[string]interface{}{
+		"truncated":    true,
+		"preview":      preview,
+		"response_ref": ref,
+	})
+}
+
+// fullPrompt returns a run's prompt, fetching it from payload storage if it
+// was truncated
+func (s *ExecuteService) fullPrompt(ctx context.Context, run *models.AgentRun) (string, error) {
+	if run.PromptRef == "" {
+		return run.Prompt, nil
+	}
+	prompt, err := s.payloads.Get(ctx, run.PromptRef)
+	if err != nil {
+		return "", fmt.Errorf("failed to get full prompt: %w", err)
+	}
+	return prompt, nil
+}
+
+// Payload returns a run's full prompt or response, including parts that were
+// truncated when the run was stored
+func (s *ExecuteService) Payload(ctx context.Context, tenantID, runID uuid.UUID, kind string) (string, error) {
+	run, err := s.Get(ctx, tenantID, runID)
+	if err != nil {
+		return "", err
+	}
+
+	switch kind {
+	case PayloadPrompt:
+		return s.fullPrompt(ctx, run)
+	case PayloadResponse:
+		if run.ResponseRef == "" {
+			return string(run.Result), nil
+		}
+		response, err := s.payloads.Get(ctx, run.ResponseRef)
+		if err != nil {
+			return "", fmt.Errorf("failed to get full response: %w", err)
+		}
+		return response, nil
+	default:
+		return "", fmt.Errorf("unknown payload %q: use prompt or response", kind)
+	}
+}
+
+// QueueStatus describes where a run sits in its tenant's execution queue
+type QueueStatus struct {
+	RunID                uuid.UUID        `json:"run_id"`
+	Status               models.RunStatus `json:"status"`
+	Position             int              `json:"position"` // 1-based; 0 once the run has left the queue
+	Ahead                int              `json:"ahead"`
+	Active               int              `json:"active"`
+	Concurrency          int              `json:"concurrency"` // 0 when unlimited
+	AverageRunSeconds    float64          `json:"average_run_seconds"`
+	EstimatedWaitSeconds float64          `json:"estimated_wait_seconds"`
+	EstimatedStartAt     *time.Time       `json:"estimated_start_at,omitempty"`
+}
+
+// defaultRunDuration is assumed when a tenant has no completed runs to average
+const defaultRunDuration = 60 * time.Second
+
+// QueueStatus returns the queue position and estimated start time of a run.
+// Positions come from the concurrency limiter's queue, which is held by the
+// instance that started the run; elsewhere a pending run has no position.
+func (s *ExecuteService) QueueStatus(ctx context.Context, tenantID, runID uuid.UUID) (*QueueStatus, error) {
+	run, err := s.Get(ctx, tenantID, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &QueueStatus{RunID: run.ID, Status: run.Status}
+	var slot *execution.Slot
+	if run.Status == models.RunStatusPending {
+		s.queueMu.Lock()
+		slot = s.queued[run.ID].slot
+		s.queueMu.Unlock()
+	}
+	s.fillQueueStatus(ctx, tenantID, slot, status, s.averageRunDuration(ctx, tenantID))
+	return status, nil
+}
+
+// setQueued records run as waiting on slot, or with a nil slot, as no longer
+// waiting
+func (s *ExecuteService) setQueued(run *models.AgentRun, slot *execution.Slot) {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	if slot == nil {
+		delete(s.queued, run.ID)
+		return
+	}
+	s.queued[run.ID] = queuedRun{tenantID: run.TenantID, slot: slot}
+}
+
+// publishQueuePositions logs each of the tenant's queued runs' new position
+// and estimated start to the run's live log, so subscribers see the queue drain
+func (s *ExecuteService) publishQueuePositions(tenantID uuid.UUID) {
+	ctx := context.Background()
+
+	s.queueMu.Lock()
+	slots := make(map[uuid.UUID]*execution.Slot)
+	for runID, queued := range s.queued {
+		if queued.tenantID == tenantID {
+			slots[runID] = queued.slot
+		}
+	}
+	s.queueMu.Unlock()
+	if len(slots) == 0 {
+		return
+	}
+
+	avg := s.averageRunDuration(ctx, tenantID)
+	for runID, slot := range slots {
+		status := &QueueStatus{RunID: runID, Status: models.RunStatusPending}
+		s.fillQueueStatus(ctx, tenantID, slot, status, avg)
+		if status.Position == 0 {
+			continue
+		}
+		s.runLog(ctx, runID, models.LogLevelInfo, "queue position updated", map[string]interface{}{
+			"position":               status.Position,
+			"ahead":                  status.Ahead,
+			"active":                 status.Active,
+			"concurrency":            status.Concurrency,
+			"estimated_wait_seconds": status.EstimatedWaitSeconds,
+			"estimated_start_at":     status.EstimatedStartAt,
+		})
+	}
+}
+
+// fillQueueStatus sets the tenant's running runs and limit on status and, while
+// slot is queued, its position and the wait estimated from avg
+func (s *ExecuteService) fillQueueStatus(ctx context.Context, tenantID uuid.UUID, slot *execution.Slot, status *QueueStatus, avg time.Duration) {
+	stats := s.concurrency.Stats(ctx, tenantID)
+	status.Active = stats.Active
+	status.Concurrency = stats.Limit
+	status.AverageRunSeconds = avg.Seconds()
+
+	if slot == nil {
+		return
+	}
+	position := slot.Position()
+	if position == 0 {
+		return
+	}
+	ahead := position - 1
+
+	// Each full round of busy slots ahead of this run costs one average run duration
+	var wait time.Duration
+	if busy := ahead + stats.Active; stats.Limit > 0 && busy >= stats.Limit {
+		rounds := (busy-stats.Limit)/stats.Limit + 1
+		wait = time.Duration(rounds) * avg
+	}
+	startAt := time.Now().Add(wait)
+
+	status.Position = position
+	status.Ahead = ahead
+	status.EstimatedWaitSeconds = wait.Seconds()
+	status.EstimatedStartAt = &startAt
+}
+
+// averageRunDuration returns the mean duration of the tenant's recent completed
+// runs, or defaultRunDuration without any
+func (s *ExecuteService) averageRunDuration(ctx context.Context, tenantID uuid.UUID) time.Duration {
+	avg, err := s.repos.AgentRuns.AverageDuration(ctx, tenantID, 20)
+	if err != nil {
+		s.log.Warnw("failed to compute average run duration", "tenant_id", tenantID, "error", err)
+	}
+	if avg <= 0 {
+		avg = defaultRunDuration
+	}
+	return avg
+}
+
+// ReplayOverrides are the parameters changed when replaying a run.
+// Fields left unset keep the value the original run used.
+type ReplayOverrides struct {
+	Provider     *models.AIProvider `json:"provider,omitempty"`
+	Model        *string            `json:"model,omitempty"`
+	Temperature  *float64           `json:"temperature,omitempty"`
+	MaxTokens    *int               `json:"max_tokens,omitempty"`
+	SystemPrompt *string            `json:"system_prompt,omitempty"`
+	Prompt       *string            `json:"prompt,omitempty"`
+}
+
+// apply sets the overridden parameters on the agent and returns the prompt to run
+func (o ReplayOverrides) apply(agent *models.Agent, prompt string) string {
+	if o.Provider != nil {
+		agent.Provider = *o.Provider
+	}
+	if o.Model != nil {
+		agent.Model = *o.Model
+	}
+	if o.Temperature != nil {
+		agent.Config.Temperature = o.Temperature
+	}
+	if o.MaxTokens != nil {
+		agent.Config.MaxTokens = *o.MaxTokens
+	}
+	if o.SystemPrompt != nil {
+		agent.SystemPrompt = *o.SystemPrompt
+	}
+	if o.Prompt != nil {
+		prompt = *o.Prompt
+	}
+	return prompt
+}
+
+// ParameterChange is a parameter that differs between a run and its replay
+type ParameterChange struct {
+	Parameter string      `json:"parameter"`
+	Original  interface{} `json:"original"`
+	Replay    interface{} `json:"replay"`
+}
+
+// ReplayResponse is a new replay run alongside the run it re-executes
+type ReplayResponse struct {
+	Run      *models.AgentRun  `json:"run"`
+	Original *models.AgentRun  `json:"original"`
+	Changes  []ParameterChange `json:"changes"`
+}
+
+// Replay re-runs a finished execution with some parameters changed. It starts
+// from the parameters the original run recorded, falling back for runs from
+// before they were kept to the agent's current configuration plus any overrides
+// the run was itself replayed with. The agent is re-briefed as for a new run.
+func (s *ExecuteService) Replay(ctx context.Context, tenantID, runID uuid.UUID, overrides *ReplayOverrides) (*ReplayResponse, error) {
+	if overrides.Temperature != nil && (*overrides.Temperature < 0 || *overrides.Temperature > 2) {
+		return nil, fmt.Errorf("temperature must be between 0 and 2")
+	}
+	if overrides.MaxTokens != nil && *overrides.MaxTokens <= 0 {
+		return nil, fmt.Errorf("max_tokens must be positive")
+	}
+
+	original, err := s.Get(ctx, tenantID, runID)
+	if err != nil {
+		return nil, err
+	}
+	switch original.Status {
+	case models.RunStatusCompleted, models.RunStatusPartial, models.RunStatusFailed, models.RunStatusCancelled:
+	default:
+		return nil, fmt.Errorf("run cannot be replayed in status: %s", original.Status)
+	}
+
+	agent, err := s.repos.Agents.GetByID(ctx, original.AgentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get agent: %w", err)
+	}
+	if agent == nil || agent.TenantID != tenantID {
+		return nil, fmt.Errorf("agent not found")
+	}
+	if agent.Status != models.AgentStatusReady {
+		return nil, fmt.Errorf("agent is not ready, current status: %s", agent.Status)
+	}
+
+	// A truncated prompt is replayed in full
+	prompt, err := s.fullPrompt(ctx, original)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reconstruct the parameters the original run used
+	originalAgent := *agent
+	if len(original.Parameters) > 0 {
+		var params models.RunParameters
+		if err := json.Unmarshal(original.Parameters, &params); err != nil {
+			return nil, fmt.Errorf("failed to read original parameters: %w", err)
+		}
+		params.Apply(&originalAgent)
+	} else if len(original.ReplayOverrides) > 0 {
+		var inherited ReplayOverrides
+		if err := json.Unmarshal(original.ReplayOverrides, &inherited); err != nil {
+			return nil, fmt.Errorf("failed to read original overrides: %w", err)
+		}
+		inherited.apply(&originalAgent, prompt)
+	}
+
+	replayAgent := originalAgent
+	replayPrompt := overrides.apply(&replayAgent, prompt)
+
+	changes := diffRunParameters(&originalAgent, prompt, &replayAgent, replayPrompt)
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("replay must change at least one parameter")
+	}
+
+	if _, err := providers.CheckModel(replayAgent.Model); err != nil {
+		return nil, err
+	}
+	if err := CheckAgentCapabilities(replayAgent.Model, replayAgent.Tools, replayAgent.Config); err != nil {
+		return nil, err
+	}
+	if err := s.checkBillingRestriction(ctx, tenantID); err != nil {
+		return nil, err
+	}
+	if err := s.checkBudget(ctx, agent); err != nil {
+		return nil, err
+	}
+
+	overridesJSON, err := json.Marshal(overrides)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode overrides: %w", err)
+	}
+
+	run := &models.AgentRun{
+		ID:              uuid.New(),
+		AgentID:         agent.ID,
+		TenantID:        tenantID,
+		Prompt:          replayPrompt,
+		Status:          models.RunStatusPending,
+		StartedAt:       time.Now(),
+		ReplayOf:        &original.ID,
+		ReplayOverrides: overridesJSON,
+	}
+
+	slot, err := s.reserveSlot(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.createRun(ctx, run, &replayAgent); err != nil {
+		slot.Release()
+		return nil, err
+	}
+
+	if err := s.repos.Agents.UpdateStatus(ctx, agent.ID, models.AgentStatusExecuting); err != nil {
+		s.log.Warnw("failed to update agent status", "agent_id", agent.ID, "error", err)
+	}
+
+	// Replays exist to run again, so they never reuse a cached result
+	go s.executeRun(s.active.Start(context.Background(), run.ID), &replayAgent, run, slot, false)
+
+	s.log.Infow("execution replay started",
+		"run_id", run.ID,
+		"replay_of", original.ID,
+		"agent_id", agent.ID,
+		"tenant_id", tenantID,
+		"changes", len(changes),
+	)
+
+	return &ReplayResponse{
+		Run:      run,
+		Original: original,
+		Changes:  changes,
+	}, nil
+}
+
+// diffRunParameters lists the parameters that differ between two run configurations
+func diffRunParameters(original *models.Agent, originalPrompt string, replay *models.Agent, replayPrompt string) []ParameterChange {
+	var changes []ParameterChange
+	add := func(name string, a, b interface{}) {
+		if a != b {
+			changes = append(changes, ParameterChange{Parameter: name, Original: a, Replay: b})
+		}
+	}
+
+	add("provider", original.Provider, replay.Provider)
+	add("model", original.Model, replay.Model)
+	add("temperature", original.Config.SamplingTemperature(), replay.Config.SamplingTemperature())
+	add("max_tokens", original.Config.MaxTokens, replay.Config.MaxTokens)
+	add("system_prompt", original.SystemPrompt, replay.SystemPrompt)
+	add("prompt", originalPrompt, replayPrompt)
+
+	return changes
+}
+
+// Cancel stops a run in progress. Its context is cancelled, which stops the
+// provider call and destroys its machine, and it is recorded as cancelled.
+// Only runs started by this instance can be cancelled; others fail with
+// ErrRunNotActive, and finished runs with ErrRunFinished.
 func (s *ExecuteService) Cancel(ctx context.Context, tenantID, runID uuid.UUID) error {
 	run, err := s.Get(ctx, tenantID, runID)
 	if err != nil {
@@ -172,20 +997,26 @@ func (s *ExecuteService) Cancel(ctx context.Context, tenantID, runID uuid.UUID)
 	}
 
 	if run.Status != models.RunStatusPending && run.Status != models.RunStatusRunning && run.Status != models.RunStatusBriefing {
-		return fmt.Errorf("run cannot be cancelled in status: %s", run.Status)
+		return fmt.Errorf("%w: status is %s", ErrRunFinished, run.Status)
+	}
+	if !s.active.Cancel(runID) {
+		return ErrRunNotActive
 	}
 
-	// In production, this would also terminate the Fly.io Machine
-
-	if err := s.repos.AgentRuns.UpdateStatus(ctx, runID, models.RunStatusCancelled); err != nil {
+	cancelled, err := s.repos.AgentRuns.Cancel(ctx, runID)
+	if err != nil {
 		return fmt.Errorf("failed to cancel run: %w", err)
 	}
+	if !cancelled {
+		return ErrRunFinished
+	}
 
 	// Return agent to ready status
 	if err := s.repos.Agents.UpdateStatus(ctx, run.AgentID, models.AgentStatusReady); err != nil {
 		s.log.Warnw("failed to update agent status", "agent_id", run.AgentID, "error", err)
 	}
 
+	s.runLog(ctx, runID, models.LogLevelWarn, "run cancelled", nil)
 	s.log.Infow("execution cancelled", "run_id", runID, "tenant_id", tenantID)
 
 	return nil
@@ -0,0 +1,263 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/execution"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/google/uuid"
+)
+
+// maxBatchItems is the most items one batch execution request may hold
+const maxBatchItems = 100
+
+// Batch statuses, derived from the batch's runs
+const (
+	BatchStatusRunning   = "running"
+	BatchStatusCompleted = "completed"
+	BatchStatusPartial   = "partial"
+	BatchStatusFailed    = "failed"
+)
+
+// BatchRequest runs several prompts, against one agent or several, in one call
+type BatchRequest struct {
+	Items []ExecuteRequest `json:"items"`
+}
+
+// BatchItemResult is what became of one item of a batch: the run started for
+// it, or why it was refused
+type BatchItemResult struct {
+	Index   int              `json:"index"`
+	AgentID uuid.UUID        `json:"agent_id"`
+	RunID   *uuid.UUID       `json:"run_id,omitempty"`
+	Status  models.RunStatus `json:"status,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
+// BatchResponse is returned as soon as a batch's runs are created, before
+// they execute
+type BatchResponse struct {
+	BatchID uuid.UUID         `json:"batch_id"`
+	Items   []BatchItemResult `json:"items"`
+}
+
+// BatchStatus is the aggregate progress and cost of a batch
+type BatchStatus struct {
+	BatchID    uuid.UUID                `json:"batch_id"`
+	Status     string                   `json:"status"`
+	Total      int                      `json:"total"`
+	Rejected   int                      `json:"rejected"` // items refused before a run was created
+	Counts     map[models.RunStatus]int `json:"counts"`
+	TokensUsed int                      `json:"tokens_used"`
+	Cost       float64                  `json:"cost"`
+	CreatedAt  time.Time                `json:"created_at"`
+	Runs       []*models.AgentRun       `json:"runs"`
+}
+
+// batchRun is a run created for a batch, waiting to be dispatched
+type batchRun struct {
+	ctx      context.Context
+	agent    *models.Agent
+	run      *models.AgentRun
+	useCache bool
+}
+
+// CreateBatch creates a run for each item of the batch and returns their IDs
+// straight away. Items that can't start, such as an agent that isn't ready or
+// a tenant over budget, are reported in the response without stopping the
+// rest. The runs execute in the background, at most BatchConcurrency at a
+// time and within the tenant's concurrency limit.
+func (s *ExecuteService) CreateBatch(ctx context.Context, tenantID uuid.UUID, req *BatchRequest) (*BatchResponse, error) {
+	if len(req.Items) == 0 {
+		return nil, fmt.Errorf("items are required")
+	}
+	if len(req.Items) > maxBatchItems {
+		return nil, fmt.Errorf("a batch may hold at most %d items", maxBatchItems)
+	}
+
+	batch := &models.RunBatch{
+		ID:        uuid.New(),
+		TenantID:  tenantID,
+		ItemCount: len(req.Items),
+		CreatedAt: time.Now(),
+	}
+	if err := s.repos.RunBatches.Create(ctx, batch); err != nil {
+		return nil, fmt.Errorf("failed to create batch: %w", err)
+	}
+
+	// Agents are checked once however many items use them; the runs only
+	// mark them executing once they have all been created
+	type checkedAgent struct {
+		agent *models.Agent
+		err   error
+	}
+	agents := make(map[uuid.UUID]checkedAgent)
+
+	resp := &BatchResponse{BatchID: batch.ID, Items: make([]BatchItemResult, len(req.Items))}
+	var queued []batchRun
+	for i, item := range req.Items {
+		result := &resp.Items[i]
+		result.Index = i
+		result.AgentID = item.AgentID
+
+		checked, ok := agents[item.AgentID]
+		if !ok {
+			agent, err := s.runnableAgent(ctx, tenantID, item.AgentID)
+			checked = checkedAgent{agent: agent, err: err}
+			agents[item.AgentID] = checked
+		}
+		if checked.err != nil {
+			result.Error = checked.err.Error()
+			continue
+		}
+
+		run := &models.AgentRun{
+			ID:        uuid.New(),
+			AgentID:   item.AgentID,
+			TenantID:  tenantID,
+			Prompt:    item.Prompt,
+			Status:    models.RunStatusPending,
+			StartedAt: time.Now(),
+			BatchID:   &batch.ID,
+		}
+		if err := s.createRun(ctx, run, checked.agent); err != nil {
+			s.log.Warnw("batch item refused", "batch_id", batch.ID, "index", i, "agent_id", item.AgentID, "error", err)
+			result.Error = err.Error()
+			continue
+		}
+
+		result.RunID = &run.ID
+		result.Status = run.Status
+		queued = append(queued, batchRun{
+			ctx:      s.active.Start(context.Background(), run.ID),
+			agent:    checked.agent,
+			run:      run,
+			useCache: !item.NoCache,
+		})
+		s.runLog(ctx, run.ID, models.LogLevelInfo, "run queued", map[string]interface{}{"batch_id": batch.ID})
+	}
+
+	for _, checked := range agents {
+		if checked.err != nil {
+			continue
+		}
+		if err := s.repos.Agents.UpdateStatus(ctx, checked.agent.ID, models.AgentStatusExecuting); err != nil {
+			s.log.Warnw("failed to update agent status", "agent_id", checked.agent.ID, "error", err)
+		}
+	}
+
+	go s.dispatchBatch(tenantID, batch.ID, queued)
+
+	s.log.Infow("batch execution started", "batch_id", batch.ID, "tenant_id", tenantID,
+		"items", len(req.Items), "runs", len(queued))
+	return resp, nil
+}
+
+// batchConcurrency returns how many of a batch's runs may execute at once:
+// BatchConcurrency, lowered to the tenant's limit. Zero means no limit.
+func (s *ExecuteService) batchConcurrency(ctx context.Context, tenantID uuid.UUID) int {
+	limit := s.cfg.BatchConcurrency
+	if tenant := s.concurrency.Limit(ctx, tenantID); tenant > 0 && (limit <= 0 || tenant < limit) {
+		limit = tenant
+	}
+	return limit
+}
+
+// dispatchBatch starts a batch's runs in order, holding back the rest while
+// the batch's share of the tenant's runs is in use. Each run still takes one
+// of the tenant's slots; a run refused one fails on its own. Runs cancelled
+// before their turn are skipped, as Cancel has already recorded them.
+func (s *ExecuteService) dispatchBatch(tenantID, batchID uuid.UUID, queued []batchRun) {
+	ctx := context.Background()
+
+	limit := s.batchConcurrency(ctx, tenantID)
+	if limit <= 0 {
+		limit = len(queued)
+	}
+	inFlight := make(chan struct{}, limit)
+
+	for _, item := range queued {
+		inFlight <- struct{}{}
+
+		if execution.Cancelled(item.ctx) {
+			s.active.Finish(item.run.ID)
+			<-inFlight
+			continue
+		}
+
+		slot, err := s.reserveSlot(ctx, tenantID)
+		if err != nil {
+			s.failRun(ctx, item.agent, item.run, "run was not started: "+err.Error())
+			s.active.Finish(item.run.ID)
+			<-inFlight
+			continue
+		}
+
+		go func(item batchRun) {
+			defer func() { <-inFlight }()
+			s.executeRun(item.ctx, item.agent, item.run, slot, item.useCache)
+		}(item)
+	}
+
+	s.log.Infow("batch execution dispatched", "batch_id", batchID, "runs", len(queued))
+}
+
+// GetBatch returns a batch's runs and their aggregate status, tokens and cost
+func (s *ExecuteService) GetBatch(ctx context.Context, tenantID, batchID uuid.UUID) (*BatchStatus, error) {
+	batch, err := s.repos.RunBatches.GetByID(ctx, batchID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get batch: %w", err)
+	}
+	if batch == nil || batch.TenantID != tenantID {
+		return nil, fmt.Errorf("batch not found")
+	}
+
+	runs, err := s.repos.AgentRuns.ListByBatch(ctx, batchID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list batch runs: %w", err)
+	}
+	return SummarizeBatch(batch, runs), nil
+}
+
+// SummarizeBatch aggregates a batch's runs. The batch is running while any of
+// its runs is; once they have all finished it is completed if every item
+// completed, failed if none completed even partially, and partial otherwise.
+func SummarizeBatch(batch *models.RunBatch, runs []*models.AgentRun) *BatchStatus {
+	status := &BatchStatus{
+		BatchID:   batch.ID,
+		Total:     batch.ItemCount,
+		Rejected:  max(batch.ItemCount-len(runs), 0),
+		Counts:    make(map[models.RunStatus]int),
+		CreatedAt: batch.CreatedAt,
+		Runs:      runs,
+	}
+	if status.Runs == nil {
+		status.Runs = []*models.AgentRun{}
+	}
+
+	running := false
+	for _, run := range runs {
+		status.Counts[run.Status]++
+		status.TokensUsed += run.TokensUsed
+		status.Cost += run.Cost
+		switch run.Status {
+		case models.RunStatusPending, models.RunStatusBriefing, models.RunStatusRunning:
+			running = true
+		}
+	}
+
+	completed := status.Counts[models.RunStatusCompleted]
+	switch {
+	case running:
+		status.Status = BatchStatusRunning
+	case completed == status.Total:
+		status.Status = BatchStatusCompleted
+	case completed+status.Counts[models.RunStatusPartial] == 0:
+		status.Status = BatchStatusFailed
+	default:
+		status.Status = BatchStatusPartial
+	}
+	return status
+}
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// FinancialService manages a business's accounts, transactions and budgets.
+// Every operation is scoped to a business the tenant owns.
+type FinancialService struct {
+	repos *repository.Repositories
+	log   *logger.Logger
+}
+
+// NewFinancialService creates a new financial service
+func NewFinancialService(repos *repository.Repositories, log *logger.Logger) *FinancialService {
+	return &FinancialService{repos: repos, log: log}
+}
+
+// dateLayout is the format of transaction and budget dates
+const dateLayout = "2006-01-02"
+
+// validBudgetPeriods are the periods a budget may cover
+var validBudgetPeriods = map[string]bool{
+	"monthly":   true,
+	"quarterly": true,
+	"yearly":    true,
+}
+
+// CreateAccountRequest represents account creation input. Currency is an ISO
+// 4217 code and defaults to USD; Balance is the opening balance.
+type CreateAccountRequest struct {
+	Name     string  `json:"name"`
+	Type     string  `json:"type"`
+	Currency string  `json:"currency"`
+	Balance  float64 `json:"balance"`
+}
+
+// CreateTransactionRequest represents transaction input. Amount is positive
+// for money in and negative for money out. Date is YYYY-MM-DD and defaults to
+// today.
+type CreateTransactionRequest struct {
+	AccountID   uuid.UUID              `json:"account_id"`
+	Amount      float64                `json:"amount"`
+	Category    string                 `json:"category"`
+	Description string                 `json:"description"`
+	Date        string                 `json:"date"`
+	Metadata    map[string]interface{} `json:"metadata"`
+}
+
+// TransactionResult is a created transaction with its account's new balance
+type TransactionResult struct {
+	*models.Transaction
+	Balance float64 `json:"balance"`
+}
+
+// CreateBudgetRequest represents budget input. Period defaults to monthly and
+// StartDate (YYYY-MM-DD) to the start of the current month.
+type CreateBudgetRequest struct {
+	Category  string  `json:"category"`
+	Amount    float64 `json:"amount"`
+	Period    string  `json:"period"`
+	StartDate string  `json:"start_date"`
+}
+
+// ParseDate parses a YYYY-MM-DD date
+func ParseDate(value string) (time.Time, error) {
+	date, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q: use YYYY-MM-DD", value)
+	}
+	return date, nil
+}
+
+// checkBusiness verifies the business belongs to the tenant
+func (s *FinancialService) checkBusiness(ctx context.Context, tenantID, businessID uuid.UUID) error {
+	_, err := getBusiness(ctx, s.repos, tenantID, businessID)
+	return err
+}
+
+// ListAccounts returns a business's accounts
+func (s *FinancialService) ListAccounts(ctx context.Context, tenantID, businessID uuid.UUID) ([]*models.FinancialAccount, error) {
+	if err := s.checkBusiness(ctx, tenantID, businessID); err != nil {
+		return nil, err
+	}
+	accounts, err := s.repos.Financial.ListAccounts(ctx, businessID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list accounts: %w", err)
+	}
+	if accounts == nil {
+		accounts = []*models.FinancialAccount{}
+	}
+	return accounts, nil
+}
+
+// CreateAccount adds an account to a business
+func (s *FinancialService) CreateAccount(ctx context.Context, tenantID, businessID uuid.UUID, req *CreateAccountRequest) (*models.FinancialAccount, error) {
+	account := &models.FinancialAccount{
+		ID:         uuid.New(),
+		BusinessID: businessID,
+		Name:       strings.TrimSpace(req.Name),
+		Type:       strings.TrimSpace(req.Type),
+		Currency:   strings.ToUpper(strings.TrimSpace(req.Currency)),
+		Balance:    roundCents(req.Balance),
+		CreatedAt:  time.Now(),
+	}
+	if account.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if account.Type == "" {
+		return nil, fmt.Errorf("type is required")
+	}
+	if account.Currency == "" {
+		account.Currency = "USD"
+	}
+	if !validCurrency(account.Currency) {
+		return nil, fmt.Errorf("currency must be a three-letter ISO 4217 code")
+	}
+
+	if err := s.checkBusiness(ctx, tenantID, businessID); err != nil {
+		return nil, err
+	}
+	if err := s.repos.Financial.CreateAccount(ctx, account); err != nil {
+		return nil, fmt.Errorf("failed to create account: %w", err)
+	}
+
+	s.log.Infow("financial account created", "account_id", account.ID, "business_id", businessID, "tenant_id", tenantID)
+	return account, nil
+}
+
+// ListTransactions returns the transactions on a business's accounts that
+// match the filter, newest first
+func (s *FinancialService) ListTransactions(ctx context.Context, tenantID, businessID uuid.UUID, filter repository.TransactionFilter) ([]*models.Transaction, error) {
+	if filter.From != nil && filter.To != nil && filter.To.Before(*filter.From) {
+		return nil, fmt.Errorf("to must not be before from")
+	}
+	if err := s.checkBusiness(ctx, tenantID, businessID); err != nil {
+		return nil, err
+	}
+	transactions, err := s.repos.Financial.ListTransactions(ctx, businessID, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list transactions: %w", err)
+	}
+	if transactions == nil {
+		transactions = []*models.Transaction{}
+	}
+	return transactions, nil
+}
+
+// CreateTransaction records a transaction on one of a business's accounts,
+// updating the account's balance with it
+func (s *FinancialService) CreateTransaction(ctx context.Context, tenantID, businessID uuid.UUID, req *CreateTransactionRequest) (*TransactionResult, error) {
+	if req.AccountID == uuid.Nil {
+		return nil, fmt.Errorf("account_id is required")
+	}
+	amount := roundCents(req.Amount)
+	if amount == 0 {
+		return nil, fmt.Errorf("amount must be non-zero")
+	}
+
+	now := time.Now()
+	date := now.UTC().Truncate(24 * time.Hour)
+	if req.Date != "" {
+		var err error
+		if date, err = ParseDate(req.Date); err != nil {
+			return nil, err
+		}
+	}
+	metadata := json.RawMessage(`{}`)
+	if req.Metadata != nil {
+		encoded, err := json.Marshal(req.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metadata: %w", err)
+		}
+		metadata = encoded
+	}
+
+	if err := s.checkBusiness(ctx, tenantID, businessID); err != nil {
+		return nil, err
+	}
+	account, err := s.repos.Financial.GetAccount(ctx, req.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account: %w", err)
+	}
+	if account == nil || account.BusinessID != businessID {
+		return nil, fmt.Errorf("account not found")
+	}
+
+	transaction := &models.Transaction{
+		ID:          uuid.New(),
+		AccountID:   account.ID,
+		Amount:      amount,
+		Category:    strings.TrimSpace(req.Category),
+		Description: req.Description,
+		Date:        date,
+		Metadata:    metadata,
+		CreatedAt:   now,
+	}
+	balance, err := s.repos.Financial.CreateTransaction(ctx, transaction)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transaction: %w", err)
+	}
+
+	return &TransactionResult{Transaction: transaction, Balance: balance}, nil
+}
+
+// ListBudgets returns a business's budgets
+func (s *FinancialService) ListBudgets(ctx context.Context, tenantID, businessID uuid.UUID) ([]*models.Budget, error) {
+	if err := s.checkBusiness(ctx, tenantID, businessID); err != nil {
+		return nil, err
+	}
+	budgets, err := s.repos.Financial.ListBudgets(ctx, businessID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list budgets: %w", err)
+	}
+	if budgets == nil {
+		budgets = []*models.Budget{}
+	}
+	return budgets, nil
+}
+
+// CreateBudget adds a budget for a category of a business's spending
+func (s *FinancialService) CreateBudget(ctx context.Context, tenantID, businessID uuid.UUID, req *CreateBudgetRequest) (*models.Budget, error) {
+	now := time.Now()
+	budget := &models.Budget{
+		ID:         uuid.New(),
+		BusinessID: businessID,
+		Category:   strings.TrimSpace(req.Category),
+		Amount:     roundCents(req.Amount),
+		Period:     req.Period,
+		CreatedAt:  now,
+	}
+	if budget.Category == "" {
+		return nil, fmt.Errorf("category is required")
+	}
+	if budget.Amount <= 0 {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+	if budget.Period == "" {
+		budget.Period = "monthly"
+	}
+	if !validBudgetPeriods[budget.Period] {
+		return nil, fmt.Errorf("period must be monthly, quarterly or yearly")
+	}
+	if req.StartDate != "" {
+		start, err := ParseDate(req.StartDate)
+		if err != nil {
+			return nil, err
+		}
+		budget.StartDate = start
+	} else {
+		today := now.UTC()
+		budget.StartDate = time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	if err := s.checkBusiness(ctx, tenantID, businessID); err != nil {
+		return nil, err
+	}
+	if err := s.repos.Financial.CreateBudget(ctx, budget); err != nil {
+		return nil, fmt.Errorf("failed to create budget: %w", err)
+	}
+
+	s.log.Infow("budget created", "budget_id", budget.ID, "business_id", businessID, "category", budget.Category)
+	return budget, nil
+}
+
+// roundCents rounds an amount to the cents the database stores
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// validCurrency reports whether code looks like an ISO 4217 code
+func validCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/google/uuid"
+)
+
+// =============================================================================
+// Financial Reports
+// =============================================================================
+
+// Report periods. A custom report covers the range it is given.
+const (
+	ReportPeriodMonth   = "month"
+	ReportPeriodQuarter = "quarter"
+	ReportPeriodYear    = "year"
+	ReportPeriodCustom  = "custom"
+)
+
+// maxDailyReportDays is the longest range charted by day; longer ranges are
+// charted by month
+const maxDailyReportDays = 62
+
+// budgetPeriodMonths is how many months each budget period covers
+var budgetPeriodMonths = map[string]float64{
+	"monthly":   1,
+	"quarterly": 3,
+	"yearly":    12,
+}
+
+// FinancialReport is a profit and loss report for a business over a range of
+// dates. Expenses are reported as positive amounts. Categories and Series are
+// ordered for charting: categories by total activity, series by date.
+type FinancialReport struct {
+	BusinessID   uuid.UUID        `json:"business_id"`
+	Period       string           `json:"period"`
+	From         string           `json:"from"`
+	To           string           `json:"to"`
+	Revenue      float64          `json:"revenue"`
+	Expenses     float64          `json:"expenses"`
+	NetIncome    float64          `json:"net_income"`
+	Transactions int              `json:"transactions"`
+	Categories   []CategoryTotal  `json:"categories"`
+	Budgets      []BudgetVariance `json:"budgets"`
+	Interval     string           `json:"interval"` // day or month
+	Series       []ReportPoint    `json:"series"`
+}
+
+// CategoryTotal is a category's share of a report. ExpenseShare is the
+// percentage of all expenses spent in the category.
+type CategoryTotal struct {
+	Category     string  `json:"category"`
+	Revenue      float64 `json:"revenue"`
+	Expenses     float64 `json:"expenses"`
+	Net          float64 `json:"net"`
+	Transactions int     `json:"transactions"`
+	ExpenseShare float64 `json:"expense_share"`
+}
+
+// BudgetVariance compares a budget with what was spent in its category.
+// Budgeted is the budget prorated to the part of the report it covers;
+// Variance is Budgeted less Actual, so it is negative when over budget.
+type BudgetVariance struct {
+	BudgetID    uuid.UUID `json:"budget_id"`
+	Category    string    `json:"category"`
+	Period      string    `json:"period"`
+	Budgeted    float64   `json:"budgeted"`
+	Actual      float64   `json:"actual"`
+	Variance    float64   `json:"variance"`
+	PercentUsed float64   `json:"percent_used"`
+	OverBudget  bool      `json:"over_budget"`
+}
+
+// ReportPoint is one interval of a report's series, labelled by its first
+// date (YYYY-MM-DD) or month (YYYY-MM)
+type ReportPoint struct {
+	Label    string  `json:"label"`
+	Revenue  float64 `json:"revenue"`
+	Expenses float64 `json:"expenses"`
+	Net      float64 `json:"net"`
+}
+
+// ReportRange returns the dates of the calendar month, quarter or year
+// containing now
+func ReportRange(period string, now time.Time) (from, to time.Time, err error) {
+	year, month, _ := now.Date()
+	switch period {
+	case ReportPeriodMonth:
+		from = time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+		to = from.AddDate(0, 1, -1)
+	case ReportPeriodQuarter:
+		from = time.Date(year, month-(month-1)%3, 1, 0, 0, 0, 0, time.UTC)
+		to = from.AddDate(0, 3, -1)
+	case ReportPeriodYear:
+		from = time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		to = from.AddDate(1, 0, -1)
+	default:
+		return from, to, fmt.Errorf("period must be month, quarter or year")
+	}
+	return from, to, nil
+}
+
+// Report builds a business's profit and loss report for the dates from to to,
+// inclusive. period names the range in the report.
+func (s *FinancialService) Report(ctx context.Context, tenantID, businessID uuid.UUID, period string, from, to time.Time) (*FinancialReport, error) {
+	if to.Before(from) {
+		return nil, fmt.Errorf("to must not be before from")
+	}
+	if err := s.checkBusiness(ctx, tenantID, businessID); err != nil {
+		return nil, err
+	}
+
+	transactions, err := s.repos.Financial.ListTransactions(ctx, businessID, repository.TransactionFilter{From: &from, To: &to})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list transactions: %w", err)
+	}
+	budgets, err := s.repos.Financial.ListBudgets(ctx, businessID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list budgets: %w", err)
+	}
+
+	report := BuildFinancialReport(transactions, budgets, from, to)
+	report.BusinessID = businessID
+	report.Period = period
+	return report, nil
+}
+
+// cents holds an amount in whole cents, so totals don't drift as float
+// amounts are added up
+type cents int64
+
+func toCents(amount float64) cents {
+	return cents(math.Round(amount * 100))
+}
+
+func (c cents) amount() float64 {
+	return float64(c) / 100
+}
+
+// flows totals the money in and out of a group of transactions
+type flows struct {
+	revenue, expenses cents
+	count             int
+}
+
+func (f *flows) add(amount cents) {
+	if amount >= 0 {
+		f.revenue += amount
+	} else {
+		f.expenses -= amount
+	}
+	f.count++
+}
+
+// BuildFinancialReport aggregates transactions dated from to to, inclusive,
+// into a report, comparing spending with the budgets that cover the range.
+// Transactions outside the range are ignored.
+func BuildFinancialReport(transactions []*models.Transaction, budgets []*models.Budget, from, to time.Time) *FinancialReport {
+	from, to = dateOnly(from), dateOnly(to)
+	daily := to.Sub(from) < maxDailyReportDays*24*time.Hour
+
+	var total flows
+	categories := make(map[string]*flows)
+	buckets := make(map[string]*flows)
+	for _, t := range transactions {
+		date := dateOnly(t.Date)
+		if date.Before(from) || date.After(to) {
+			continue
+		}
+		amount := toCents(t.Amount)
+		total.add(amount)
+
+		category := t.Category
+		if category == "" {
+			category = "uncategorized"
+		}
+		if categories[category] == nil {
+			categories[category] = &flows{}
+		}
+		categories[category].add(amount)
+
+		label := date.Format("2006-01")
+		if daily {
+			label = date.Format(dateLayout)
+		}
+		if buckets[label] == nil {
+			buckets[label] = &flows{}
+		}
+		buckets[label].add(amount)
+	}
+
+	report := &FinancialReport{
+		Period:       ReportPeriodCustom,
+		From:         from.Format(dateLayout),
+		To:           to.Format(dateLayout),
+		Revenue:      total.revenue.amount(),
+		Expenses:     total.expenses.amount(),
+		NetIncome:    (total.revenue - total.expenses).amount(),
+		Transactions: total.count,
+		Categories:   []CategoryTotal{},
+		Budgets:      []BudgetVariance{},
+		Interval:     "month",
+	}
+	if daily {
+		report.Interval = "day"
+	}
+
+	for name, f := range categories {
+		category := CategoryTotal{
+			Category:     name,
+			Revenue:      f.revenue.amount(),
+			Expenses:     f.expenses.amount(),
+			Net:          (f.revenue - f.expenses).amount(),
+			Transactions: f.count,
+		}
+		if total.expenses > 0 {
+			category.ExpenseShare = percent(float64(f.expenses), float64(total.expenses))
+		}
+		report.Categories = append(report.Categories, category)
+	}
+	sort.Slice(report.Categories, func(i, j int) bool {
+		a, b := report.Categories[i], report.Categories[j]
+		if a.Revenue+a.Expenses != b.Revenue+b.Expenses {
+			return a.Revenue+a.Expenses > b.Revenue+b.Expenses
+		}
+		return a.Category < b.Category
+	})
+
+	// Every interval is charted, including those without transactions
+	for day := from; !day.After(to); {
+		label := day.Format("2006-01")
+		next := time.Date(day.Year(), day.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		if daily {
+			label = day.Format(dateLayout)
+			next = day.AddDate(0, 0, 1)
+		}
+		point := ReportPoint{Label: label}
+		if f := buckets[label]; f != nil {
+			point.Revenue = f.revenue.amount()
+			point.Expenses = f.expenses.amount()
+			point.Net = (f.revenue - f.expenses).amount()
+		}
+		report.Series = append(report.Series, point)
+		day = next
+	}
+
+	for _, b := range budgets {
+		budgeted, ok := prorateBudget(b, from, to)
+		if !ok {
+			continue
+		}
+		var actual cents
+		if f := categories[b.Category]; f != nil {
+			actual = f.expenses
+		}
+		variance := BudgetVariance{
+			BudgetID:   b.ID,
+			Category:   b.Category,
+			Period:     b.Period,
+			Budgeted:   budgeted.amount(),
+			Actual:     actual.amount(),
+			Variance:   (budgeted - actual).amount(),
+			OverBudget: actual > budgeted,
+		}
+		if budgeted > 0 {
+			variance.PercentUsed = percent(float64(actual), float64(budgeted))
+		}
+		report.Budgets = append(report.Budgets, variance)
+	}
+	return report
+}
+
+// prorateBudget returns how much of a budget applies to the dates from to to:
+// its amount for each of its periods in the range, with partly covered months
+// counted by their share of days. A budget starting after the range doesn't
+// apply.
+func prorateBudget(b *models.Budget, from, to time.Time) (cents, bool) {
+	periodMonths, ok := budgetPeriodMonths[b.Period]
+	if !ok {
+		return 0, false
+	}
+	start := dateOnly(b.StartDate)
+	if start.After(to) {
+		return 0, false
+	}
+	if start.After(from) {
+		from = start
+	}
+
+	var months float64
+	for day := from; !day.After(to); {
+		monthStart := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+		monthEnd := monthStart.AddDate(0, 1, -1)
+		last := monthEnd
+		if to.Before(last) {
+			last = to
+		}
+		days := last.Sub(day).Hours()/24 + 1
+		months += days / float64(monthEnd.Day())
+		day = monthEnd.AddDate(0, 0, 1)
+	}
+	return toCents(b.Amount * months / periodMonths), true
+}
+
+// dateOnly drops the time of day from a date
+func dateOnly(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// percent returns part as a percentage of whole, to two decimal places
+func percent(part, whole float64) float64 {
+	return math.Round(part/whole*10000) / 100
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+)
+
+// Dependency check timeouts
+const (
+	dependencyCheckTimeout = 2 * time.Second
+	providerCheckTimeout   = 3 * time.Second
+)
+
+// Readiness statuses
+const (
+	ReadinessReady       = "ready"
+	ReadinessUnavailable = "unavailable"
+)
+
+// providerEndpoints are probed for the hosted providers' reachability
+var providerEndpoints = map[string]string{
+	"openai":    "https://api.openai.com/v1/models",
+	"anthropic": "https://api.anthropic.com/v1/models",
+	"google":    "https://generativelanguage.googleapis.com/v1beta/models",
+}
+
+// DependencyStatus is the outcome of checking one dependency
+type DependencyStatus struct {
+	Status    string `json:"status"` // ok or down
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Readiness reports whether the service can take traffic. Checks gate the
+// status; Providers, when requested, are informational only.
+type Readiness struct {
+	Status    string                      `json:"status"`
+	Checks    map[string]DependencyStatus `json:"checks"`
+	Providers map[string]DependencyStatus `json:"providers,omitempty"`
+}
+
+// Ready reports whether every gating check passed
+func (r *Readiness) Ready() bool {
+	return r.Status == ReadinessReady
+}
+
+// HealthService checks the service's dependencies for readiness probes
+type HealthService struct {
+	repos     *repository.Repositories
+	redis     *repository.RedisClient
+	providers map[string]string
+	client    *http.Client
+	log       *logger.Logger
+}
+
+// NewHealthService creates a health service. Dependencies left nil are not
+// configured and not checked.
+func NewHealthService(repos *repository.Repositories, redis *repository.RedisClient, log *logger.Logger) *HealthService {
+	return &HealthService{
+		repos:     repos,
+		redis:     redis,
+		providers: make(map[string]string),
+		client:    &http.Client{Timeout: providerCheckTimeout},
+		log:       log,
+	}
+}
+
+// AddProvider includes a provider in the reachability checks, probing
+// endpoint, or the provider's API when it is empty. Any HTTP response, even
+// an authentication error, counts as reachable.
+func (s *HealthService) AddProvider(name, endpoint string) {
+	if endpoint == "" {
+		endpoint = providerEndpoints[name]
+	}
+	if endpoint != "" {
+		s.providers[name] = endpoint
+	}
+}
+
+// Check pings the database and Redis, and the providers when withProviders
+// is set. The service is ready when the database and Redis are up, whatever
+// the providers' state.
+func (s *HealthService) Check(ctx context.Context, withProviders bool) *Readiness {
+	checks := make(map[string]func(context.Context) error)
+	if s.repos != nil {
+		checks["database"] = s.repos.Ping
+	}
+	if s.redis != nil {
+		checks["redis"] = s.redis.Ping
+	}
+
+	readiness := &Readiness{
+		Status: ReadinessReady,
+		Checks: runChecks(ctx, checks, dependencyCheckTimeout),
+	}
+	for name, status := range readiness.Checks {
+		if status.Status != "ok" {
+			readiness.Status = ReadinessUnavailable
+			s.log.Warnw("readiness check failed", "dependency", name, "error", status.Error)
+		}
+	}
+
+	if withProviders && len(s.providers) > 0 {
+		probes := make(map[string]func(context.Context) error, len(s.providers))
+		for name, endpoint := range s.providers {
+			endpoint := endpoint
+			probes[name] = func(ctx context.Context) error { return s.probe(ctx, endpoint) }
+		}
+		readiness.Providers = runChecks(ctx, probes, providerCheckTimeout)
+	}
+	return readiness
+}
+
+// probe sends a HEAD request to a provider endpoint
+func (s *HealthService) probe(ctx context.Context, endpoint string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// runChecks runs the checks concurrently, each with its own timeout
+func runChecks(ctx context.Context, checks map[string]func(context.Context) error, timeout time.Duration) map[string]DependencyStatus {
+	results := make(map[string]DependencyStatus, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(context.Context) error) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check(checkCtx)
+			status := DependencyStatus{Status: "ok", LatencyMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				status.Status = "down"
+				status.Error = err.Error()
+			}
+
+			mu.Lock()
+			results[name] = status
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+	return results
+}
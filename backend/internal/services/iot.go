@@ -0,0 +1,511 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/config"
+	"github.com/delphi-platform/delphi/backend/internal/iot"
+	"github.com/delphi-platform/delphi/backend/internal/metrics"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/pkg/crypto"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// Telemetry page sizes
+const (
+	defaultTelemetryLimit = 100
+	maxTelemetryLimit     = 1000
+)
+
+// IoTService handles IoT operations
+type IoTService struct {
+	repos     *repository.Repositories
+	encryptor *crypto.Encryptor
+	iot       *iot.Service
+	log       *logger.Logger
+}
+
+func NewIoTService(cfg *config.Config, repos *repository.Repositories, encryptor *crypto.Encryptor, log *logger.Logger) *IoTService {
+	engine := iot.NewService(log)
+	engine.SetTelemetryStore(iot.NewPgTelemetryStore(repos.DB()))
+	engine.SetDeviceStore(&iotDeviceStore{repo: repos.IoT, encryptor: encryptor})
+
+	mqttConfig := iot.DefaultMQTTConfig(cfg.MQTTBrokerURL)
+	mqttConfig.Username = cfg.MQTTUsername
+	mqttConfig.Password = cfg.MQTTPassword
+	engine.RegisterAdapter("mqtt", iot.NewMQTTAdapter(mqttConfig, log))
+
+	// Devices are connected once loaded, so their telemetry is ingested
+	if err := engine.LoadDevices(context.Background()); err != nil {
+		log.Warnw("failed to load IoT devices, cache starts empty", "error", err)
+	}
+
+	engine.SetCommandStore(&iotCommandStore{repo: repos.IoT})
+	engine.SetCommandTimeout(time.Duration(cfg.IoTCommandTimeoutSeconds) * time.Second)
+
+	metrics.RegisterQueue("iot_data", engine.DataQueueDepth)
+	metrics.RegisterQueue("iot_commands", engine.CommandQueueDepth)
+
+	return &IoTService{repos: repos, encryptor: encryptor, iot: engine, log: log}
+}
+
+// Stop processes buffered telemetry and sends queued commands
+func (s *IoTService) Stop() {
+	s.iot.Stop()
+}
+
+// validDeviceTypes are the device types accepted on registration
+var validDeviceTypes = map[iot.DeviceType]bool{
+	iot.DeviceTypeSensor:     true,
+	iot.DeviceTypeActuator:   true,
+	iot.DeviceTypeController: true,
+	iot.DeviceTypeGateway:    true,
+	iot.DeviceTypeCamera:     true,
+	iot.DeviceTypeDisplay:    true,
+}
+
+// RegisterDeviceRequest represents device registration input. Protocol picks
+// the adapter used to reach the device and defaults to mqtt.
+type RegisterDeviceRequest struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Type        iot.DeviceType         `json:"type"`
+	BusinessID  *uuid.UUID             `json:"business_id"`
+	Location    string                 `json:"location"`
+	Protocol    string                 `json:"protocol"`
+	Metadata    map[string]interface{} `json:"metadata"`
+	Config      iot.DeviceConfig       `json:"config"`
+	Credentials *iot.Credentials       `json:"credentials"`
+}
+
+// UpdateDeviceRequest represents device changes; unset fields are kept
+type UpdateDeviceRequest struct {
+	Name        *string                `json:"name"`
+	Description *string                `json:"description"`
+	Location    *string                `json:"location"`
+	Metadata    map[string]interface{} `json:"metadata"`
+	Config      *iot.DeviceConfig      `json:"config"`
+	Credentials *iot.Credentials       `json:"credentials"`
+}
+
+// ListDevices returns the tenant's devices, optionally filtered by "type",
+// "status" and "business_id"
+func (s *IoTService) ListDevices(ctx context.Context, tenantID uuid.UUID, filters map[string]interface{}) ([]*iot.Device, error) {
+	devices, err := s.iot.ListDevices(ctx, tenantID, filters)
+	if err != nil {
+		return nil, err
+	}
+	if devices == nil {
+		devices = []*iot.Device{}
+	}
+	return devices, nil
+}
+
+// RegisterDevice registers a device for the tenant
+func (s *IoTService) RegisterDevice(ctx context.Context, tenantID uuid.UUID, req *RegisterDeviceRequest) (*iot.Device, error) {
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if !validDeviceTypes[req.Type] {
+		return nil, fmt.Errorf("invalid device type: %s", req.Type)
+	}
+
+	metadata := req.Metadata
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	metadata["protocol"] = "mqtt"
+	if req.Protocol != "" {
+		metadata["protocol"] = req.Protocol
+	}
+
+	device := &iot.Device{
+		TenantID:    tenantID,
+		Name:        req.Name,
+		Description: req.Description,
+		Type:        req.Type,
+		Location:    req.Location,
+		Metadata:    metadata,
+		Config:      req.Config,
+	}
+	if req.BusinessID != nil {
+		device.BusinessID = *req.BusinessID
+	}
+	device.Config.Credentials = req.Credentials
+
+	if err := s.iot.RegisterDevice(ctx, device); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// GetDevice returns one of the tenant's devices
+func (s *IoTService) GetDevice(ctx context.Context, tenantID, deviceID uuid.UUID) (*iot.Device, error) {
+	return s.iot.GetDevice(ctx, tenantID, deviceID)
+}
+
+// UpdateDevice changes one of the tenant's devices
+func (s *IoTService) UpdateDevice(ctx context.Context, tenantID, deviceID uuid.UUID, req *UpdateDeviceRequest) (*iot.Device, error) {
+	current, err := s.iot.GetDevice(ctx, tenantID, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Work on a copy; the cached device is replaced once the update is saved
+	device := *current
+	if req.Name != nil {
+		if strings.TrimSpace(*req.Name) == "" {
+			return nil, fmt.Errorf("name is required")
+		}
+		device.Name = *req.Name
+	}
+	if req.Description != nil {
+		device.Description = *req.Description
+	}
+	if req.Location != nil {
+		device.Location = *req.Location
+	}
+	if req.Metadata != nil {
+		if _, ok := req.Metadata["protocol"]; !ok {
+			req.Metadata["protocol"] = current.Metadata["protocol"]
+		}
+		device.Metadata = req.Metadata
+	}
+	if req.Config != nil {
+		credentials := device.Config.Credentials
+		device.Config = *req.Config
+		device.Config.Credentials = credentials
+	}
+	if req.Credentials != nil {
+		device.Config.Credentials = req.Credentials
+	}
+
+	if err := s.iot.UpdateDevice(ctx, &device); err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// DeleteDevice removes one of the tenant's devices
+func (s *IoTService) DeleteDevice(ctx context.Context, tenantID, deviceID uuid.UUID) error {
+	return s.iot.DeleteDevice(ctx, tenantID, deviceID)
+}
+
+// SendCommandRequest represents a command for a device
+type SendCommandRequest struct {
+	Action string                 `json:"action"`
+	Params map[string]interface{} `json:"params"`
+}
+
+// SendCommand queues a command for one of the tenant's devices
+func (s *IoTService) SendCommand(ctx context.Context, tenantID, deviceID uuid.UUID, req *SendCommandRequest) (*iot.Command, error) {
+	if strings.TrimSpace(req.Action) == "" {
+		return nil, fmt.Errorf("action is required")
+	}
+	if _, err := s.iot.GetDevice(ctx, tenantID, deviceID); err != nil {
+		return nil, err
+	}
+
+	cmd := &iot.Command{DeviceID: deviceID, Action: req.Action, Params: req.Params}
+	if err := s.iot.SendCommand(ctx, cmd); err != nil {
+		if strings.HasPrefix(err.Error(), "failed to") {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to send command: %w", err)
+	}
+	return cmd, nil
+}
+
+// GetCommand returns the status of a command sent to one of the tenant's devices
+func (s *IoTService) GetCommand(ctx context.Context, tenantID, deviceID, commandID uuid.UUID) (*iot.Command, error) {
+	if _, err := s.iot.GetDevice(ctx, tenantID, deviceID); err != nil {
+		return nil, err
+	}
+
+	cmd, err := s.iot.GetCommand(ctx, commandID)
+	if err != nil {
+		return nil, err
+	}
+	if cmd.DeviceID != deviceID {
+		return nil, fmt.Errorf("command not found")
+	}
+	return cmd, nil
+}
+
+// GetTelemetry returns a device's data points in [from, to), newest first
+func (s *IoTService) GetTelemetry(ctx context.Context, tenantID, deviceID uuid.UUID, from, to time.Time, limit int) ([]iot.DataPoint, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("from must be before to")
+	}
+	if limit <= 0 {
+		limit = defaultTelemetryLimit
+	}
+	if limit > maxTelemetryLimit {
+		limit = maxTelemetryLimit
+	}
+
+	if _, err := s.iot.GetDevice(ctx, tenantID, deviceID); err != nil {
+		return nil, err
+	}
+
+	points, err := s.iot.QueryTelemetry(ctx, deviceID, from, to, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query telemetry: %w", err)
+	}
+	return points, nil
+}
+
+// iotDeviceStore persists IoT devices through the IoT repository, encrypting
+// their credentials
+type iotDeviceStore struct {
+	repo      *repository.IoTRepository
+	encryptor *crypto.Encryptor
+}
+
+func (st *iotDeviceStore) Create(ctx context.Context, device *iot.Device) error {
+	m, err := st.toModel(device)
+	if err != nil {
+		return err
+	}
+	return st.repo.CreateDevice(ctx, m)
+}
+
+func (st *iotDeviceStore) Get(ctx context.Context, deviceID uuid.UUID) (*iot.Device, error) {
+	m, err := st.repo.GetDevice(ctx, deviceID)
+	if err != nil || m == nil {
+		return nil, err
+	}
+	return st.fromModel(m)
+}
+
+func (st *iotDeviceStore) Update(ctx context.Context, device *iot.Device) error {
+	m, err := st.toModel(device)
+	if err != nil {
+		return err
+	}
+	return st.repo.UpdateDevice(ctx, m)
+}
+
+func (st *iotDeviceStore) UpdateStatus(ctx context.Context, deviceID uuid.UUID, status iot.DeviceStatus, lastSeen time.Time) error {
+	return st.repo.UpdateDeviceStatus(ctx, deviceID, string(status), lastSeen)
+}
+
+func (st *iotDeviceStore) Delete(ctx context.Context, deviceID uuid.UUID) error {
+	return st.repo.DeleteDevice(ctx, deviceID)
+}
+
+func (st *iotDeviceStore) List(ctx context.Context, tenantID uuid.UUID, filter iot.DeviceFilter) ([]*iot.Device, error) {
+	stored, err := st.repo.ListDevices(ctx, tenantID, repository.IoTDeviceFilter{
+		Type:       string(filter.Type),
+		Status:     string(filter.Status),
+		BusinessID: filter.BusinessID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return st.fromModels(stored)
+}
+
+func (st *iotDeviceStore) ListAll(ctx context.Context) ([]*iot.Device, error) {
+	stored, err := st.repo.ListAllDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return st.fromModels(stored)
+}
+
+func (st *iotDeviceStore) fromModels(stored []*models.IoTDevice) ([]*iot.Device, error) {
+	devices := make([]*iot.Device, 0, len(stored))
+	for _, m := range stored {
+		device, err := st.fromModel(m)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+// toModel converts a device to its row. The protocol and endpoint live in the
+// device's metadata and get their own columns.
+func (st *iotDeviceStore) toModel(d *iot.Device) (*models.IoTDevice, error) {
+	m := &models.IoTDevice{
+		ID:          d.ID,
+		TenantID:    d.TenantID,
+		Name:        d.Name,
+		Description: d.Description,
+		Type:        string(d.Type),
+		Protocol:    "mqtt",
+		Status:      string(d.Status),
+		Location:    d.Location,
+		CreatedAt:   d.CreatedAt,
+		UpdatedAt:   d.UpdatedAt,
+	}
+	if d.BusinessID != uuid.Nil {
+		businessID := d.BusinessID
+		m.BusinessID = &businessID
+	}
+	if protocol, ok := d.Metadata["protocol"].(string); ok && protocol != "" {
+		m.Protocol = protocol
+	}
+	if endpoint, ok := d.Metadata["endpoint"].(string); ok {
+		m.Endpoint = endpoint
+	}
+	if !d.LastSeen.IsZero() {
+		lastSeen := d.LastSeen
+		m.LastSeenAt = &lastSeen
+	}
+
+	var err error
+	if m.Config, err = json.Marshal(d.Config); err != nil {
+		return nil, fmt.Errorf("failed to encode device config: %w", err)
+	}
+	metadata := d.Metadata
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	if m.Metadata, err = json.Marshal(metadata); err != nil {
+		return nil, fmt.Errorf("failed to encode device metadata: %w", err)
+	}
+
+	if d.Config.Credentials != nil {
+		data, err := json.Marshal(d.Config.Credentials)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode device credentials: %w", err)
+		}
+		if m.Credentials, err = st.encryptor.Encrypt(string(data)); err != nil {
+			return nil, fmt.Errorf("failed to encrypt device credentials: %w", err)
+		}
+	}
+
+	return m, nil
+}
+
+func (st *iotDeviceStore) fromModel(m *models.IoTDevice) (*iot.Device, error) {
+	d := &iot.Device{
+		ID:          m.ID,
+		TenantID:    m.TenantID,
+		Name:        m.Name,
+		Description: m.Description,
+		Type:        iot.DeviceType(m.Type),
+		Status:      iot.DeviceStatus(m.Status),
+		Location:    m.Location,
+		CreatedAt:   m.CreatedAt,
+		UpdatedAt:   m.UpdatedAt,
+	}
+	if m.BusinessID != nil {
+		d.BusinessID = *m.BusinessID
+	}
+	if m.LastSeenAt != nil {
+		d.LastSeen = *m.LastSeenAt
+	}
+
+	if len(m.Config) > 0 {
+		if err := json.Unmarshal(m.Config, &d.Config); err != nil {
+			return nil, fmt.Errorf("failed to decode config of device %s: %w", m.ID, err)
+		}
+	}
+	if len(m.Metadata) > 0 {
+		if err := json.Unmarshal(m.Metadata, &d.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to decode metadata of device %s: %w", m.ID, err)
+		}
+	}
+	if d.Metadata == nil {
+		d.Metadata = map[string]interface{}{}
+	}
+	d.Metadata["protocol"] = m.Protocol
+	if m.Endpoint != "" {
+		d.Metadata["endpoint"] = m.Endpoint
+	}
+
+	if m.Credentials != "" {
+		plain, err := st.encryptor.Decrypt(m.Credentials)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt credentials of device %s: %w", m.ID, err)
+		}
+		var creds iot.Credentials
+		if err := json.Unmarshal([]byte(plain), &creds); err != nil {
+			return nil, fmt.Errorf("failed to decode credentials of device %s: %w", m.ID, err)
+		}
+		d.Config.Credentials = &creds
+	}
+
+	return d, nil
+}
+
+// iotCommandStore persists device commands through the IoT repository
+type iotCommandStore struct {
+	repo *repository.IoTRepository
+}
+
+func (st *iotCommandStore) Save(ctx context.Context, cmd *iot.Command) error {
+	params := cmd.Params
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to encode command params: %w", err)
+	}
+
+	var resultJSON json.RawMessage
+	if cmd.Result != nil {
+		if resultJSON, err = json.Marshal(cmd.Result); err != nil {
+			return fmt.Errorf("failed to encode command result: %w", err)
+		}
+	}
+
+	return st.repo.SaveCommand(ctx, &models.IoTCommand{
+		ID:             cmd.ID,
+		DeviceID:       cmd.DeviceID,
+		Command:        cmd.Action,
+		Parameters:     paramsJSON,
+		Status:         cmd.Status,
+		Result:         resultJSON,
+		Error:          cmd.Error,
+		SentAt:         cmd.SentAt,
+		AcknowledgedAt: cmd.AcknowledgedAt,
+		CompletedAt:    cmd.CompletedAt,
+		CreatedAt:      cmd.CreatedAt,
+	})
+}
+
+func (st *iotCommandStore) Get(ctx context.Context, commandID uuid.UUID) (*iot.Command, error) {
+	m, err := st.repo.GetCommand(ctx, commandID)
+	if err != nil || m == nil {
+		return nil, err
+	}
+
+	cmd := &iot.Command{
+		ID:             m.ID,
+		DeviceID:       m.DeviceID,
+		Action:         m.Command,
+		Status:         m.Status,
+		Error:          m.Error,
+		CreatedAt:      m.CreatedAt,
+		SentAt:         m.SentAt,
+		AcknowledgedAt: m.AcknowledgedAt,
+		CompletedAt:    m.CompletedAt,
+	}
+	if len(m.Parameters) > 0 {
+		if err := json.Unmarshal(m.Parameters, &cmd.Params); err != nil {
+			return nil, fmt.Errorf("failed to decode params of command %s: %w", m.ID, err)
+		}
+	}
+	if len(m.Result) > 0 {
+		if err := json.Unmarshal(m.Result, &cmd.Result); err != nil {
+			return nil, fmt.Errorf("failed to decode result of command %s: %w", m.ID, err)
+		}
+	}
+	return cmd, nil
+}
+
+func (st *iotCommandStore) FailExpired(ctx context.Context, sentBefore time.Time, reason string) (int64, error) {
+	return st.repo.FailUnacknowledgedCommands(ctx, sentBefore, reason)
+}
@@ -0,0 +1,369 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/billing"
+	"github.com/delphi-platform/delphi/backend/internal/github"
+	"github.com/delphi-platform/delphi/backend/internal/knowledge"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/providers"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+)
+
+const (
+	maxAskQuestionLength = 4000
+	defaultAskTopK       = 5
+	maxAskTopK           = 20
+	defaultAskProvider   = models.ProviderOpenAI
+	defaultAskModel      = "gpt-4o-mini"
+)
+
+// ErrProviderFailed is returned when the model provider fails to answer a
+// request the platform made on the tenant's behalf
+var ErrProviderFailed = errors.New("provider request failed")
+
+// KnowledgeService handles knowledge base operations
+type KnowledgeService struct {
+	repos   *repository.Repositories
+	kb      *knowledge.Service
+	apiKeys *APIKeyServiceImpl
+	manager *providers.Manager
+	log     *logger.Logger
+}
+
+// NewKnowledgeService creates a new knowledge service
+func NewKnowledgeService(repos *repository.Repositories, kb *knowledge.Service, apiKeys *APIKeyServiceImpl, manager *providers.Manager, log *logger.Logger) *KnowledgeService {
+	return &KnowledgeService{
+		repos:   repos,
+		kb:      kb,
+		apiKeys: apiKeys,
+		manager: manager,
+		log:     log,
+	}
+}
+
+// AskRequest represents a one-shot question against a knowledge base
+type AskRequest struct {
+	Question string `json:"question"`
+	TopK     int    `json:"top_k,omitempty"`
+}
+
+// AskSource is a knowledge chunk cited in an answer
+type AskSource struct {
+	Index      int                    `json:"index"`
+	ChunkID    uuid.UUID              `json:"chunk_id"`
+	DocumentID uuid.UUID              `json:"document_id"`
+	Score      float32                `json:"score"`
+	Excerpt    string                 `json:"excerpt"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// AskResponse represents the answer to a knowledge base question
+type AskResponse struct {
+	Answer     string            `json:"answer"`
+	Sources    []AskSource       `json:"sources"`
+	Provider   models.AIProvider `json:"provider"`
+	Model      string            `json:"model"`
+	TokensUsed int               `json:"tokens_used"`
+	Cost       float64           `json:"cost"`
+}
+
+// Ask answers a question using the top matching chunks of a knowledge base
+func (s *KnowledgeService) Ask(ctx context.Context, tenantID, kbID uuid.UUID, req *AskRequest) (*AskResponse, error) {
+	question := strings.TrimSpace(req.Question)
+	if question == "" {
+		return nil, fmt.Errorf("question is required")
+	}
+	if len(question) > maxAskQuestionLength {
+		return nil, fmt.Errorf("question exceeds maximum length of %d characters", maxAskQuestionLength)
+	}
+
+	topK := req.TopK
+	if topK <= 0 {
+		topK = defaultAskTopK
+	}
+	if topK > maxAskTopK {
+		topK = maxAskTopK
+	}
+
+	// Verify knowledge base belongs to tenant
+	base, err := s.repos.Knowledge.GetBaseByID(ctx, kbID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get knowledge base: %w", err)
+	}
+	if base == nil || base.TenantID != tenantID {
+		return nil, fmt.Errorf("knowledge base not found")
+	}
+
+	tenant, err := s.repos.Tenants.GetByID(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+	if tenant == nil {
+		return nil, fmt.Errorf("tenant not found")
+	}
+	if billing.IsRestricted(tenant) {
+		return nil, ErrBillingRestricted
+	}
+
+	if err := s.checkCostLimits(ctx, tenantID); err != nil {
+		return nil, err
+	}
+
+	result, err := s.kb.Query(ctx, &knowledge.QueryRequest{
+		KnowledgeBaseIDs: []uuid.UUID{kbID},
+		Query:            question,
+		Limit:            topK,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query knowledge base: %w", err)
+	}
+
+	providerName, model, err := TenantDefaultModel(tenant)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := s.apiKeys.GetProviderForTenant(ctx, tenantID, providerName)
+	if err != nil {
+		return nil, err
+	}
+	if model == "" {
+		if available := provider.GetModels(); len(available) > 0 {
+			model = available[0].ID
+		}
+	}
+
+	sources := make([]AskSource, len(result.Results))
+	var sourceText strings.Builder
+	for i, r := range result.Results {
+		sources[i] = AskSource{
+			Index:      i + 1,
+			ChunkID:    r.ChunkID,
+			DocumentID: r.DocumentID,
+			Score:      r.Score,
+			Excerpt:    truncateExcerpt(r.Content, 280),
+			Metadata:   r.Metadata,
+		}
+		sourceText.WriteString(fmt.Sprintf("[%d] %s\n\n", i+1, r.Content))
+	}
+
+	systemPrompt := "You answer questions using only the numbered sources provided. " +
+		"Cite the sources you rely on using their numbers in square brackets, e.g. [1]. " +
+		"If the sources do not contain the answer, say so rather than guessing."
+	userPrompt := fmt.Sprintf("Sources:\n\n%s\nQuestion: %s", sourceText.String(), question)
+	if len(result.Results) == 0 {
+		userPrompt = fmt.Sprintf("Sources:\n\n(none found)\n\nQuestion: %s", question)
+	}
+
+	completionReq := providers.NewRequestBuilder(model).
+		WithSystemPrompt(systemPrompt).
+		WithUserMessage(userPrompt).
+		WithTemperature(0.2).
+		WithMaxTokens(1024).
+		Build()
+
+	completionStart := time.Now()
+	resp, err := s.manager.Complete(ctx, provider, completionReq)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrProviderFailed, providerName, err)
+	}
+
+	if s.kb.RequestLogging() {
+		chunkIDs := make([]uuid.UUID, len(sources))
+		for i, src := range sources {
+			chunkIDs[i] = src.ChunkID
+		}
+		log := s.log
+		if reqID := middleware.GetReqID(ctx); reqID != "" {
+			log = log.WithRequestID(reqID)
+		}
+		log.Infow("knowledge completion",
+			"tenant_id", tenantID,
+			"kb_id", kbID,
+			"provider", providerName,
+			"model", model,
+			"source_chunk_ids", chunkIDs,
+			"prompt_chars", len(systemPrompt)+len(userPrompt),
+			"prompt_tokens", resp.Usage.PromptTokens,
+			"completion_tokens", resp.Usage.CompletionTokens,
+			"finish_reason", resp.FinishReason,
+			"provider_request_id", resp.RequestID,
+			"completion_ms", time.Since(completionStart).Milliseconds(),
+		)
+	}
+
+	if resp.ModelWarning != "" {
+		s.log.Warnw("knowledge base answered with a deprecated model", "tenant_id", tenantID, "model", model, "warning", resp.ModelWarning)
+	}
+
+	cost := s.manager.CalculateCost(model, resp.Usage)
+
+	costRecord := &models.CostRecord{
+		ID:           uuid.New(),
+		TenantID:     tenantID,
+		Provider:     providerName,
+		Model:        model,
+		InputTokens:  resp.Usage.PromptTokens,
+		OutputTokens: resp.Usage.CompletionTokens,
+		Cost:         cost,
+		CreatedAt:    time.Now(),
+	}
+	if err := s.repos.Costs.RecordCost(ctx, costRecord); err != nil {
+		s.log.Warnw("failed to record cost", "tenant_id", tenantID, "kb_id", kbID, "error", err)
+	}
+
+	s.log.Infow("knowledge base question answered",
+		"tenant_id", tenantID,
+		"kb_id", kbID,
+		"sources", len(sources),
+		"tokens", resp.Usage.TotalTokens,
+		"cost", cost,
+	)
+
+	return &AskResponse{
+		Answer:     resp.Message.Content,
+		Sources:    sources,
+		Provider:   providerName,
+		Model:      model,
+		TokensUsed: resp.Usage.TotalTokens,
+		Cost:       cost,
+	}, nil
+}
+
+// UploadDocumentRequest is a document to ingest into a knowledge base. Source
+// names the document; uploading the same source again replaces it. Chunking
+// picks how the document is split: char (the default), token or code.
+// MaxTokens and OverlapTokens size token chunks, and Language picks the syntax
+// of code chunks, inferred from the source's extension when empty.
+type UploadDocumentRequest struct {
+	Source        string
+	Content       string
+	Chunking      knowledge.ChunkStrategy
+	MaxTokens     int
+	OverlapTokens int
+	Language      string
+}
+
+// UploadDocumentResponse reports an ingested document
+type UploadDocumentResponse struct {
+	DocumentID uuid.UUID               `json:"document_id"`
+	ChunkCount int                     `json:"chunk_count"`
+	Outcome    knowledge.IngestOutcome `json:"outcome"`
+}
+
+// UploadDocument chunks, embeds and stores a document in a tenant's knowledge base
+func (s *KnowledgeService) UploadDocument(ctx context.Context, tenantID, kbID uuid.UUID, req *UploadDocumentRequest) (*UploadDocumentResponse, error) {
+	if strings.TrimSpace(req.Source) == "" {
+		return nil, fmt.Errorf("document name is required")
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		return nil, fmt.Errorf("document is empty")
+	}
+
+	chunking := knowledge.ChunkOptions{
+		Strategy:      req.Chunking,
+		MaxTokens:     req.MaxTokens,
+		OverlapTokens: req.OverlapTokens,
+		Language:      req.Language,
+	}
+	if chunking.Strategy == knowledge.ChunkByCode && chunking.Language == "" {
+		chunking.Language = github.LanguageForPath(req.Source)
+	}
+	if err := chunking.Validate(); err != nil {
+		return nil, err
+	}
+
+	base, err := s.repos.Knowledge.GetBaseByID(ctx, kbID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get knowledge base: %w", err)
+	}
+	if base == nil || base.TenantID != tenantID {
+		return nil, fmt.Errorf("knowledge base not found")
+	}
+
+	result, err := s.kb.Ingest(ctx, &knowledge.IngestRequest{
+		KnowledgeBaseID: kbID,
+		Source:          req.Source,
+		SourceType:      "upload",
+		Content:         req.Content,
+		Chunking:        chunking,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to ingest document: %w", err)
+	}
+
+	s.log.Infow("document uploaded",
+		"tenant_id", tenantID,
+		"kb_id", kbID,
+		"document_id", result.DocumentID,
+		"chunking", chunking.Strategy,
+		"chunks", result.ChunkCount,
+		"outcome", result.Outcome,
+	)
+
+	return &UploadDocumentResponse{
+		DocumentID: result.DocumentID,
+		ChunkCount: result.ChunkCount,
+		Outcome:    result.Outcome,
+	}, nil
+}
+
+// checkCostLimits rejects the request if the tenant has reached its daily or monthly cost limit
+func (s *KnowledgeService) checkCostLimits(ctx context.Context, tenantID uuid.UUID) error {
+	windows, err := s.repos.Costs.BudgetWindows(ctx, tenantID, time.Now())
+	if err != nil {
+		s.log.Warnw("failed to get cost limits", "tenant_id", tenantID, "error", err)
+		return nil
+	}
+
+	for _, window := range windows {
+		spent, err := s.repos.Costs.GetTotalByTenant(ctx, tenantID, window.Since)
+		if err != nil {
+			s.log.Warnw("failed to check budget", "tenant_id", tenantID, "error", err)
+			continue
+		}
+		if spent >= window.Limit.Amount {
+			return fmt.Errorf("%w: tenant has exceeded its %s cost limit", ErrBudgetExceeded, window.Limit.LimitType)
+		}
+	}
+
+	return nil
+}
+
+// TenantDefaultModel reads the default provider and model from tenant
+// settings. An empty model means the provider's first listed model should be
+// used.
+func TenantDefaultModel(tenant *models.Tenant) (models.AIProvider, string, error) {
+	var settings struct {
+		DefaultProvider models.AIProvider `json:"default_provider"`
+		DefaultModel    string            `json:"default_model"`
+	}
+	if len(tenant.Settings) > 0 {
+		if err := json.Unmarshal(tenant.Settings, &settings); err != nil {
+			return "", "", fmt.Errorf("failed to read tenant settings: %w", err)
+		}
+	}
+
+	if settings.DefaultProvider == "" {
+		return defaultAskProvider, defaultAskModel, nil
+	}
+	return settings.DefaultProvider, settings.DefaultModel, nil
+}
+
+// truncateExcerpt shortens content for display in source citations
+func truncateExcerpt(content string, maxLen int) string {
+	content = strings.TrimSpace(content)
+	if len(content) <= maxLen {
+		return content
+	}
+	return content[:maxLen] + "..."
+}
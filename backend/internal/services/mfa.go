@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/security"
+	"github.com/delphi-platform/delphi/backend/pkg/auth"
+	"github.com/delphi-platform/delphi/backend/pkg/crypto"
+	"github.com/google/uuid"
+)
+
+// mfaIssuer names the account in authenticator apps
+const mfaIssuer = "Delphi"
+
+var (
+	// ErrMFARequired is returned by Login when the user has MFA enabled and no
+	// code was given
+	ErrMFARequired = errors.New("mfa code required")
+
+	// ErrInvalidMFACode is returned when an MFA code or recovery code does not
+	// match, or was already used
+	ErrInvalidMFACode = errors.New("invalid mfa code")
+)
+
+// MFAEnrollment is a new TOTP secret for the user to add to an authenticator
+// app, as the raw secret and as an otpauth URL to render as a QR code
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	URL    string `json:"otpauth_url"`
+}
+
+// EnrollMFA generates a TOTP secret for the user. MFA is not enforced until
+// the user confirms a code with VerifyMFA; enrolling again before then
+// replaces the secret.
+func (s *AuthService) EnrollMFA(ctx context.Context, userID uuid.UUID) (*MFAEnrollment, error) {
+	user, err := s.repos.Users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	if user.MFAEnabled {
+		return nil, fmt.Errorf("mfa already enabled")
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate mfa secret: %w", err)
+	}
+	encrypted, err := s.encryptMFASecret(secret)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := s.repos.Users.SetMFASecret(ctx, userID, encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store mfa secret: %w", err)
+	}
+	if !stored {
+		return nil, fmt.Errorf("mfa already enabled")
+	}
+
+	return &MFAEnrollment{
+		Secret: secret,
+		URL:    auth.TOTPURL(mfaIssuer, user.Email, secret),
+	}, nil
+}
+
+// VerifyMFA confirms enrollment with a code from the authenticator app and
+// enables MFA. It returns the recovery codes, which are shown only this once.
+func (s *AuthService) VerifyMFA(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	user, err := s.repos.Users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	if user.MFAEnabled {
+		return nil, fmt.Errorf("mfa already enabled")
+	}
+	if user.MFASecret == "" {
+		return nil, fmt.Errorf("mfa enrollment not started")
+	}
+
+	ok, err := s.checkTOTP(ctx, user, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(auth.RecoveryCodeCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate recovery codes: %w", err)
+	}
+	hashes := make([]string, len(codes))
+	for i, c := range codes {
+		hashes[i] = auth.HashRecoveryCode(c)
+	}
+	if err := s.repos.Users.EnableMFA(ctx, userID, hashes); err != nil {
+		return nil, fmt.Errorf("failed to enable mfa: %w", err)
+	}
+
+	s.log.Infow("mfa enabled", "user_id", userID, "tenant_id", user.TenantID)
+	s.audit.Log(ctx, &security.AuditEntry{
+		TenantID:     user.TenantID,
+		UserID:       &userID,
+		Action:       security.AuditActionMFAEnabled,
+		Severity:     security.SeverityCritical,
+		ResourceType: "user",
+		ResourceID:   &userID,
+	})
+	return codes, nil
+}
+
+// DisableMFA turns MFA off. The user must give their password and a current
+// code or an unused recovery code.
+func (s *AuthService) DisableMFA(ctx context.Context, userID uuid.UUID, password, code string) error {
+	user, err := s.repos.Users.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return fmt.Errorf("user not found")
+	}
+	if !user.MFAEnabled {
+		return fmt.Errorf("mfa not enabled")
+	}
+	if user.PasswordHash == "" || !crypto.CheckPassword(password, user.PasswordHash) {
+		return auth.ErrIncorrectPassword
+	}
+
+	ok, err := s.checkMFACode(ctx, user, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	if err := s.repos.Users.DisableMFA(ctx, userID); err != nil {
+		return fmt.Errorf("failed to disable mfa: %w", err)
+	}
+
+	s.log.Infow("mfa disabled", "user_id", userID, "tenant_id", user.TenantID)
+	s.audit.Log(ctx, &security.AuditEntry{
+		TenantID:     user.TenantID,
+		UserID:       &userID,
+		Action:       security.AuditActionMFADisabled,
+		Severity:     security.SeverityCritical,
+		ResourceType: "user",
+		ResourceID:   &userID,
+	})
+	return nil
+}
+
+// checkMFACode accepts a TOTP code or, failing that, an unused recovery code,
+// which is used up
+func (s *AuthService) checkMFACode(ctx context.Context, user *models.User, code string) (bool, error) {
+	ok, err := s.checkTOTP(ctx, user, code)
+	if err != nil || ok {
+		return ok, err
+	}
+
+	used, err := s.repos.Users.UseRecoveryCode(ctx, user.ID, auth.HashRecoveryCode(code))
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	if used {
+		s.log.Infow("mfa recovery code used", "user_id", user.ID, "remaining", len(user.MFARecoveryCodes)-1)
+	}
+	return used, nil
+}
+
+// checkTOTP validates a TOTP code against the user's secret and records its
+// time step, so the same code cannot be used again
+func (s *AuthService) checkTOTP(ctx context.Context, user *models.User, code string) (bool, error) {
+	secret, err := s.decryptMFASecret(user.MFASecret)
+	if err != nil {
+		return false, err
+	}
+
+	step, ok := auth.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	fresh, err := s.repos.Users.UseMFAStep(ctx, user.ID, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to record mfa code: %w", err)
+	}
+	return fresh, nil
+}
+
+func (s *AuthService) encryptMFASecret(secret string) (string, error) {
+	encrypted, err := s.encryptor.Encrypt(secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt mfa secret: %w", err)
+	}
+	return encrypted, nil
+}
+
+func (s *AuthService) decryptMFASecret(secret string) (string, error) {
+	plain, err := s.encryptor.Decrypt(secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt mfa secret: %w", err)
+	}
+	return plain, nil
+}
@@ -0,0 +1,558 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/config"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/notifications"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// NotificationService manages tenant notification recipients and delivers notifications
+type NotificationService struct {
+	repos    *repository.Repositories
+	notifier *notifications.Service
+	log      *logger.Logger
+
+	// vapidPublicKey is handed to browsers to subscribe to push; empty when
+	// push is disabled
+	vapidPublicKey string
+}
+
+// NewNotificationService creates a new notification service
+func NewNotificationService(cfg *config.Config, repos *repository.Repositories, log *logger.Logger) *NotificationService {
+	var emailConfig *notifications.EmailConfig
+	if cfg.SMTPHost != "" {
+		emailConfig = &notifications.EmailConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			User:     cfg.SMTPUser,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPUser,
+		}
+	}
+
+	var pushConfig *notifications.PushConfig
+	if cfg.VAPIDPrivateKey != "" {
+		pushConfig = &notifications.PushConfig{
+			VAPIDPublicKey:  cfg.VAPIDPublicKey,
+			VAPIDPrivateKey: cfg.VAPIDPrivateKey,
+			Subscriber:      cfg.VAPIDSubject,
+		}
+	}
+
+	s := &NotificationService{
+		repos:          repos,
+		notifier:       notifications.NewService(emailConfig, nil, nil, pushConfig, log),
+		log:            log,
+		vapidPublicKey: cfg.VAPIDPublicKey,
+	}
+	s.notifier.SetRecipientResolver(s)
+	s.notifier.SetPreferenceResolver(s)
+	s.notifier.SetPushSubscriptionStore(s)
+	s.notifier.SetUserLookup(s)
+
+	return s
+}
+
+// AddRecipientRequest represents a request to add a notification recipient
+type AddRecipientRequest struct {
+	Channel string `json:"channel"`
+	Address string `json:"address"`
+}
+
+// PushSubscriptionKeys are the encryption keys of a browser push subscription
+type PushSubscriptionKeys struct {
+	P256dh string `json:"p256dh"`
+	Auth   string `json:"auth"`
+}
+
+// RegisterPushSubscriptionRequest is a browser PushSubscription as serialized
+// by its toJSON method
+type RegisterPushSubscriptionRequest struct {
+	Endpoint string               `json:"endpoint"`
+	Keys     PushSubscriptionKeys `json:"keys"`
+}
+
+// Send delivers a notification, defaulting to the tenant's recipients when it has no user target
+func (s *NotificationService) Send(ctx context.Context, notification *notifications.Notification) error {
+	return s.notifier.Send(ctx, notification)
+}
+
+// ListRecipients returns the explicitly added notification recipients of a tenant
+func (s *NotificationService) ListRecipients(ctx context.Context, tenantID uuid.UUID) ([]*models.NotificationRecipient, error) {
+	recipients, err := s.repos.Notifications.ListRecipients(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recipients: %w", err)
+	}
+	return recipients, nil
+}
+
+// AddRecipient adds an email address or webhook to the tenant's default audience
+func (s *NotificationService) AddRecipient(ctx context.Context, tenantID uuid.UUID, req *AddRecipientRequest) (*models.NotificationRecipient, error) {
+	switch notifications.NotificationChannel(req.Channel) {
+	case notifications.ChannelEmail:
+		if _, err := mail.ParseAddress(req.Address); err != nil {
+			return nil, fmt.Errorf("invalid email address: %s", req.Address)
+		}
+	case notifications.ChannelSlack, notifications.ChannelDiscord:
+		u, err := url.Parse(req.Address)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return nil, fmt.Errorf("webhook address must be an https URL")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported channel: %s", req.Channel)
+	}
+
+	recipient := &models.NotificationRecipient{
+		ID:        uuid.New(),
+		TenantID:  tenantID,
+		Channel:   req.Channel,
+		Address:   req.Address,
+		CreatedAt: time.Now(),
+	}
+
+	if err := s.repos.Notifications.CreateRecipient(ctx, recipient); err != nil {
+		if errors.Is(err, repository.ErrRecipientExists) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to add recipient: %w", err)
+	}
+
+	s.log.Infow("notification recipient added", "tenant_id", tenantID, "channel", req.Channel)
+	return recipient, nil
+}
+
+// RemoveRecipient removes a recipient from the tenant's default audience
+func (s *NotificationService) RemoveRecipient(ctx context.Context, tenantID, recipientID uuid.UUID) error {
+	recipient, err := s.repos.Notifications.GetRecipientByID(ctx, recipientID)
+	if err != nil {
+		return fmt.Errorf("failed to get recipient: %w", err)
+	}
+	if recipient == nil || recipient.TenantID != tenantID {
+		return fmt.Errorf("recipient not found")
+	}
+
+	if err := s.repos.Notifications.DeleteRecipient(ctx, recipientID); err != nil {
+		return fmt.Errorf("failed to remove recipient: %w", err)
+	}
+
+	s.log.Infow("notification recipient removed", "tenant_id", tenantID, "recipient_id", recipientID)
+	return nil
+}
+
+// ResolveRecipients returns the tenant's explicitly added recipients. The
+// tenant's own users are reached through GetUser and ListTenantUsers.
+func (s *NotificationService) ResolveRecipients(ctx context.Context, tenantID uuid.UUID) (*notifications.Recipients, error) {
+	added, err := s.repos.Notifications.ListRecipients(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recipients: %w", err)
+	}
+
+	recipients := &notifications.Recipients{}
+	seen := make(map[string]bool)
+
+	for _, r := range added {
+		switch notifications.NotificationChannel(r.Channel) {
+		case notifications.ChannelEmail:
+			if !seen[r.Address] {
+				recipients.Emails = append(recipients.Emails, r.Address)
+				seen[r.Address] = true
+			}
+		case notifications.ChannelSlack:
+			recipients.SlackWebhookURLs = append(recipients.SlackWebhookURLs, r.Address)
+		case notifications.ChannelDiscord:
+			recipients.DiscordWebhookURLs = append(recipients.DiscordWebhookURLs, r.Address)
+		}
+	}
+
+	return recipients, nil
+}
+
+// GetUser returns the user a notification is addressed to, or nil if they
+// don't exist
+func (s *NotificationService) GetUser(ctx context.Context, userID uuid.UUID) (*notifications.User, error) {
+	user, err := s.repos.Users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, nil
+	}
+	return &notifications.User{ID: user.ID, Email: user.Email}, nil
+}
+
+// ListTenantUsers returns every user of a tenant as a notification recipient
+func (s *NotificationService) ListTenantUsers(ctx context.Context, tenantID uuid.UUID) ([]*notifications.User, error) {
+	users, err := s.repos.Users.ListByTenant(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	recipients := make([]*notifications.User, 0, len(users))
+	for _, user := range users {
+		recipients = append(recipients, &notifications.User{ID: user.ID, Email: user.Email})
+	}
+	return recipients, nil
+}
+
+// PushPublicKey returns the VAPID public key browsers subscribe with, or an
+// error if push notifications are disabled
+func (s *NotificationService) PushPublicKey() (string, error) {
+	if s.vapidPublicKey == "" {
+		return "", fmt.Errorf("push notifications are not enabled")
+	}
+	return s.vapidPublicKey, nil
+}
+
+// RegisterPushSubscription saves a browser's push subscription for the user
+func (s *NotificationService) RegisterPushSubscription(ctx context.Context, tenantID, userID uuid.UUID, userAgent string, req *RegisterPushSubscriptionRequest) (*models.PushSubscription, error) {
+	if _, err := s.PushPublicKey(); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(req.Endpoint)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("endpoint must be an https URL")
+	}
+	if req.Keys.P256dh == "" || req.Keys.Auth == "" {
+		return nil, fmt.Errorf("keys.p256dh and keys.auth are required")
+	}
+
+	sub := &models.PushSubscription{
+		ID:        uuid.New(),
+		TenantID:  tenantID,
+		UserID:    userID,
+		Endpoint:  req.Endpoint,
+		P256dh:    req.Keys.P256dh,
+		Auth:      req.Keys.Auth,
+		UserAgent: userAgent,
+		CreatedAt: time.Now(),
+	}
+	if err := s.repos.Notifications.SavePushSubscription(ctx, sub); err != nil {
+		return nil, fmt.Errorf("failed to save push subscription: %w", err)
+	}
+
+	s.log.Infow("push subscription registered", "tenant_id", tenantID, "user_id", userID)
+	return sub, nil
+}
+
+// UnregisterPushSubscription removes one of the user's push subscriptions
+func (s *NotificationService) UnregisterPushSubscription(ctx context.Context, userID uuid.UUID, endpoint string) error {
+	removed, err := s.repos.Notifications.DeleteUserPushSubscription(ctx, userID, endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to remove push subscription: %w", err)
+	}
+	if !removed {
+		return fmt.Errorf("push subscription not found")
+	}
+
+	s.log.Infow("push subscription removed", "user_id", userID)
+	return nil
+}
+
+// ListPushSubscriptions returns the push subscriptions a notification is
+// delivered to: the user's, or every subscribed user's in the tenant
+func (s *NotificationService) ListPushSubscriptions(ctx context.Context, tenantID uuid.UUID, userID *uuid.UUID) ([]notifications.PushSubscription, error) {
+	stored, err := s.repos.Notifications.ListPushSubscriptions(ctx, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	subs := make([]notifications.PushSubscription, len(stored))
+	for i, sub := range stored {
+		subs[i] = notifications.PushSubscription{Endpoint: sub.Endpoint, P256dh: sub.P256dh, Auth: sub.Auth}
+	}
+	return subs, nil
+}
+
+// RemovePushSubscription forgets a subscription the push service reports gone
+func (s *NotificationService) RemovePushSubscription(ctx context.Context, endpoint string) error {
+	return s.repos.Notifications.DeletePushSubscription(ctx, endpoint)
+}
+
+// NotificationPreferenceView is the channels a notification type is delivered
+// on and where that setting comes from: user, tenant or default
+type NotificationPreferenceView struct {
+	NotificationType notifications.NotificationType      `json:"notification_type"`
+	Channels         []notifications.NotificationChannel `json:"channels"`
+	Source           string                              `json:"source"`
+}
+
+// SetPreferenceRequest sets the channels of a notification type; an empty
+// list turns the notification type off
+type SetPreferenceRequest struct {
+	Channels []string `json:"channels"`
+}
+
+// SetDoNotDisturbRequest represents a do-not-disturb window. Times are HH:MM
+// in Timezone, which defaults to UTC.
+type SetDoNotDisturbRequest struct {
+	Enabled   *bool  `json:"enabled"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+	Timezone  string `json:"timezone"`
+}
+
+func isNotificationType(t notifications.NotificationType) bool {
+	for _, known := range notifications.NotificationTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveChannels picks the most specific preference for each notification
+// type, falling back to the defaults
+func resolveChannels(prefs []*models.NotificationPreference) []NotificationPreferenceView {
+	tenant := make(map[string]*models.NotificationPreference)
+	user := make(map[string]*models.NotificationPreference)
+	for _, p := range prefs {
+		if p.UserID == nil {
+			tenant[p.NotificationType] = p
+		} else {
+			user[p.NotificationType] = p
+		}
+	}
+
+	views := make([]NotificationPreferenceView, 0, len(notifications.NotificationTypes))
+	for _, t := range notifications.NotificationTypes {
+		view := NotificationPreferenceView{NotificationType: t, Source: "default", Channels: notifications.DefaultChannels(t)}
+		p, ok := user[string(t)]
+		if ok {
+			view.Source = "user"
+		} else if p, ok = tenant[string(t)]; ok {
+			view.Source = "tenant"
+		}
+		if ok {
+			view.Channels = make([]notifications.NotificationChannel, len(p.EnabledChannels))
+			for i, c := range p.EnabledChannels {
+				view.Channels[i] = notifications.NotificationChannel(c)
+			}
+		}
+		views = append(views, view)
+	}
+	return views
+}
+
+// GetPreferences returns the effective channels of every notification type
+// for the tenant (userID nil) or a user
+func (s *NotificationService) GetPreferences(ctx context.Context, tenantID uuid.UUID, userID *uuid.UUID) ([]NotificationPreferenceView, error) {
+	prefs, err := s.repos.Notifications.ListPreferences(ctx, tenantID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list preferences: %w", err)
+	}
+	return resolveChannels(prefs), nil
+}
+
+// SetPreference sets the channels of a notification type for the tenant
+// (userID nil) or a user
+func (s *NotificationService) SetPreference(ctx context.Context, tenantID uuid.UUID, userID *uuid.UUID, notificationType string, req *SetPreferenceRequest) (*models.NotificationPreference, error) {
+	if !isNotificationType(notifications.NotificationType(notificationType)) {
+		return nil, fmt.Errorf("unknown notification type: %s", notificationType)
+	}
+	if req.Channels == nil {
+		return nil, fmt.Errorf("channels is required")
+	}
+
+	seen := make(map[string]bool)
+	channels := []string{}
+	for _, c := range req.Channels {
+		if !notifications.IsValidChannel(notifications.NotificationChannel(c)) {
+			return nil, fmt.Errorf("unsupported channel: %s", c)
+		}
+		if !seen[c] {
+			seen[c] = true
+			channels = append(channels, c)
+		}
+	}
+
+	now := time.Now()
+	pref := &models.NotificationPreference{
+		ID:               uuid.New(),
+		TenantID:         tenantID,
+		UserID:           userID,
+		NotificationType: notificationType,
+		EnabledChannels:  channels,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	if err := s.repos.Notifications.SavePreference(ctx, pref); err != nil {
+		return nil, fmt.Errorf("failed to save preference: %w", err)
+	}
+	return pref, nil
+}
+
+// ResetPreference removes the tenant's (userID nil) or a user's preference
+// for a notification type, so it falls back to the next level
+func (s *NotificationService) ResetPreference(ctx context.Context, tenantID uuid.UUID, userID *uuid.UUID, notificationType string) error {
+	if !isNotificationType(notifications.NotificationType(notificationType)) {
+		return fmt.Errorf("unknown notification type: %s", notificationType)
+	}
+	if err := s.repos.Notifications.DeletePreference(ctx, tenantID, userID, notificationType); err != nil {
+		return fmt.Errorf("failed to reset preference: %w", err)
+	}
+	return nil
+}
+
+// GetDoNotDisturb returns the user's do-not-disturb window, or a disabled
+// window if none is set
+func (s *NotificationService) GetDoNotDisturb(ctx context.Context, tenantID, userID uuid.UUID) (*models.DoNotDisturb, error) {
+	dnd, err := s.repos.Notifications.GetDoNotDisturb(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get do not disturb: %w", err)
+	}
+	if dnd == nil {
+		dnd = &models.DoNotDisturb{UserID: userID, TenantID: tenantID, Timezone: "UTC"}
+	}
+	return dnd, nil
+}
+
+// SetDoNotDisturb sets the user's do-not-disturb window
+func (s *NotificationService) SetDoNotDisturb(ctx context.Context, tenantID, userID uuid.UUID, req *SetDoNotDisturbRequest) (*models.DoNotDisturb, error) {
+	if _, err := parseClock(req.StartTime); err != nil {
+		return nil, fmt.Errorf("invalid start_time: %s", req.StartTime)
+	}
+	if _, err := parseClock(req.EndTime); err != nil {
+		return nil, fmt.Errorf("invalid end_time: %s", req.EndTime)
+	}
+	timezone := req.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return nil, fmt.Errorf("invalid timezone: %s", timezone)
+	}
+
+	dnd := &models.DoNotDisturb{
+		UserID:    userID,
+		TenantID:  tenantID,
+		Enabled:   req.Enabled == nil || *req.Enabled,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+		Timezone:  timezone,
+		UpdatedAt: time.Now(),
+	}
+	if err := s.repos.Notifications.SaveDoNotDisturb(ctx, dnd); err != nil {
+		return nil, fmt.Errorf("failed to save do not disturb: %w", err)
+	}
+	return dnd, nil
+}
+
+// parseClock parses an HH:MM time of day into minutes after midnight
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// inDoNotDisturb reports whether the time falls in the user's window. A
+// window ending before it starts runs past midnight; one that starts and ends
+// at the same time covers the whole day.
+func inDoNotDisturb(dnd *models.DoNotDisturb, now time.Time) bool {
+	if dnd == nil || !dnd.Enabled {
+		return false
+	}
+	start, err := parseClock(dnd.StartTime)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(dnd.EndTime)
+	if err != nil {
+		return false
+	}
+	loc, err := time.LoadLocation(dnd.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	switch {
+	case start == end:
+		return true
+	case start < end:
+		return minute >= start && minute < end
+	default:
+		return minute >= start || minute < end
+	}
+}
+
+// ResolvePreferences returns the channels a notification is delivered on and
+// whether its user is in their do-not-disturb window
+func (s *NotificationService) ResolvePreferences(ctx context.Context, tenantID uuid.UUID, userID *uuid.UUID, notificationType notifications.NotificationType) (*notifications.Preferences, error) {
+	prefs, err := s.repos.Notifications.ListPreferences(ctx, tenantID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list preferences: %w", err)
+	}
+
+	resolved := &notifications.Preferences{}
+	for _, view := range resolveChannels(prefs) {
+		if view.NotificationType == notificationType && view.Source != "default" {
+			resolved.Channels = view.Channels
+		}
+	}
+
+	if userID != nil {
+		dnd, err := s.repos.Notifications.GetDoNotDisturb(ctx, *userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get do not disturb: %w", err)
+		}
+		resolved.DoNotDisturb = inDoNotDisturb(dnd, time.Now())
+	}
+	return resolved, nil
+}
+
+// ResolveUserPreferences resolves ResolvePreferences for each of the users
+// with one query for their channels and one for their do-not-disturb windows
+func (s *NotificationService) ResolveUserPreferences(ctx context.Context, tenantID uuid.UUID, userIDs []uuid.UUID, notificationType notifications.NotificationType) (map[uuid.UUID]*notifications.Preferences, error) {
+	prefs, err := s.repos.Notifications.ListUserPreferences(ctx, tenantID, userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list preferences: %w", err)
+	}
+	windows, err := s.repos.Notifications.ListDoNotDisturb(ctx, userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list do not disturb: %w", err)
+	}
+	return resolveUserPreferences(prefs, windows, userIDs, notificationType, time.Now()), nil
+}
+
+// resolveUserPreferences applies the tenant's preferences, then each user's
+// overrides and do-not-disturb window at now
+func resolveUserPreferences(prefs []*models.NotificationPreference, windows []*models.DoNotDisturb, userIDs []uuid.UUID, notificationType notifications.NotificationType, now time.Time) map[uuid.UUID]*notifications.Preferences {
+	var tenant []*models.NotificationPreference
+	byUser := make(map[uuid.UUID][]*models.NotificationPreference)
+	for _, p := range prefs {
+		if p.UserID == nil {
+			tenant = append(tenant, p)
+		} else {
+			byUser[*p.UserID] = append(byUser[*p.UserID], p)
+		}
+	}
+	dnd := make(map[uuid.UUID]*models.DoNotDisturb, len(windows))
+	for _, d := range windows {
+		dnd[d.UserID] = d
+	}
+
+	resolved := make(map[uuid.UUID]*notifications.Preferences, len(userIDs))
+	for _, userID := range userIDs {
+		p := &notifications.Preferences{DoNotDisturb: inDoNotDisturb(dnd[userID], now)}
+		for _, view := range resolveChannels(append(append([]*models.NotificationPreference{}, tenant...), byUser[userID]...)) {
+			if view.NotificationType == notificationType && view.Source != "default" {
+				p.Channels = view.Channels
+			}
+		}
+		resolved[userID] = p
+	}
+	return resolved
+}
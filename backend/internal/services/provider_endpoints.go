@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/delphi-platform/delphi/backend/internal/config"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/providers"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// ServerProviderEndpoints returns the provider endpoints configured for the
+// server. An endpoint that fails validation is logged and left out, so the
+// provider falls back to its public API.
+func ServerProviderEndpoints(cfg *config.Config, log *logger.Logger) map[models.AIProvider]providers.Endpoint {
+	openAI := providers.Endpoint{
+		BaseURL:    cfg.OpenAIBaseURL,
+		ProxyURL:   cfg.ProviderProxyURL,
+		APIVersion: cfg.OpenAIAPIVersion,
+	}
+	for _, entry := range strings.Split(cfg.OpenAIDeployments, ",") {
+		model, deployment, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			continue
+		}
+		if openAI.Deployments == nil {
+			openAI.Deployments = make(map[string]string)
+		}
+		openAI.Deployments[strings.TrimSpace(model)] = strings.TrimSpace(deployment)
+	}
+
+	candidates := map[models.AIProvider]providers.Endpoint{
+		models.ProviderOpenAI:    openAI,
+		models.ProviderAnthropic: {BaseURL: cfg.AnthropicBaseURL, ProxyURL: cfg.ProviderProxyURL},
+		models.ProviderGoogle:    {BaseURL: cfg.GoogleAIBaseURL, ProxyURL: cfg.ProviderProxyURL},
+	}
+	endpoints := make(map[models.AIProvider]providers.Endpoint)
+	for provider, endpoint := range candidates {
+		if endpoint.IsZero() {
+			continue
+		}
+		if err := endpoint.Validate(); err != nil {
+			log.Warnw("invalid provider endpoint, using the provider's default", "provider", provider, "error", err)
+			continue
+		}
+		endpoints[provider] = endpoint
+	}
+	return endpoints
+}
+
+// TenantProviderEndpoints returns the provider endpoints a tenant has set,
+// stored in the tenant settings under provider_endpoints. Providers without
+// one use the server's.
+func TenantProviderEndpoints(tenant *models.Tenant) map[models.AIProvider]providers.Endpoint {
+	endpoints := make(map[models.AIProvider]providers.Endpoint)
+	if tenant == nil || len(tenant.Settings) == 0 {
+		return endpoints
+	}
+
+	var stored struct {
+		ProviderEndpoints map[models.AIProvider]providers.Endpoint `json:"provider_endpoints"`
+	}
+	if err := json.Unmarshal(tenant.Settings, &stored); err != nil {
+		return endpoints
+	}
+	for provider, endpoint := range stored.ProviderEndpoints {
+		if !endpoint.IsZero() {
+			endpoints[provider] = endpoint
+		}
+	}
+	return endpoints
+}
+
+// tenantEndpoint returns the endpoint the tenant set for a provider, or the
+// zero Endpoint to use the server's. Lookup failures are logged and fall back
+// to the server's. A tenant's endpoint only reaches public addresses.
+func (s *APIKeyServiceImpl) tenantEndpoint(ctx context.Context, tenantID uuid.UUID, provider models.AIProvider) providers.Endpoint {
+	tenant, err := s.repos.Tenants.GetByID(ctx, tenantID)
+	if err != nil {
+		s.log.Warnw("failed to get tenant provider endpoints", "tenant_id", tenantID, "error", err)
+		return providers.Endpoint{}
+	}
+	endpoint, ok := TenantProviderEndpoints(tenant)[provider]
+	if !ok {
+		return providers.Endpoint{}
+	}
+	return endpoint.PublicOnly()
+}
+
+// GetProviderEndpoints returns the provider endpoints the tenant has set
+func (s *APIKeyServiceImpl) GetProviderEndpoints(ctx context.Context, tenantID uuid.UUID) (map[models.AIProvider]providers.Endpoint, error) {
+	tenant, err := s.repos.Tenants.GetByID(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+	if tenant == nil {
+		return nil, fmt.Errorf("tenant not found")
+	}
+	return TenantProviderEndpoints(tenant), nil
+}
+
+// UpdateProviderEndpoint sets the endpoint of one of the tenant's providers,
+// preserving its other settings. The zero Endpoint goes back to the server's.
+func (s *APIKeyServiceImpl) UpdateProviderEndpoint(ctx context.Context, tenantID uuid.UUID, provider models.AIProvider, endpoint providers.Endpoint) (map[models.AIProvider]providers.Endpoint, error) {
+	switch provider {
+	case models.ProviderOpenAI, models.ProviderAnthropic, models.ProviderGoogle, models.ProviderOllama:
+	default:
+		return nil, fmt.Errorf("unsupported provider: %s", provider)
+	}
+	if err := endpoint.Validate(); err != nil {
+		return nil, err
+	}
+	if endpoint.Azure() && provider != models.ProviderOpenAI {
+		return nil, fmt.Errorf("api_version and deployments only apply to Azure OpenAI")
+	}
+	if err := endpoint.ValidatePublic(ctx); err != nil {
+		return nil, err
+	}
+
+	tenant, err := s.repos.Tenants.GetByID(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+	if tenant == nil {
+		return nil, fmt.Errorf("tenant not found")
+	}
+
+	endpoints := TenantProviderEndpoints(tenant)
+	if endpoint.IsZero() {
+		delete(endpoints, provider)
+	} else {
+		endpoints[provider] = endpoint
+	}
+
+	settings := make(map[string]interface{})
+	if len(tenant.Settings) > 0 {
+		if err := json.Unmarshal(tenant.Settings, &settings); err != nil {
+			return nil, fmt.Errorf("failed to parse tenant settings: %w", err)
+		}
+	}
+	settings["provider_endpoints"] = endpoints
+
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal tenant settings: %w", err)
+	}
+	tenant.Settings = data
+	if err := s.repos.Tenants.Update(ctx, tenant); err != nil {
+		return nil, fmt.Errorf("failed to update tenant: %w", err)
+	}
+
+	s.log.Infow("provider endpoint updated",
+		"tenant_id", tenantID,
+		"provider", provider,
+		"base_url", endpoint.BaseURL,
+		"proxy", endpoint.ProxyURL != "",
+	)
+	return endpoints, nil
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/delphi-platform/delphi/backend/internal/github"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/notifications"
+)
+
+// defaultPRBranchPrefix starts the branches of agents that set no prefix
+const defaultPRBranchPrefix = "delphi/run-"
+
+// PullRequestBranch returns the branch a run's changes are committed to
+func PullRequestBranch(config *models.PullRequestConfig, run *models.AgentRun) string {
+	prefix := config.BranchPrefix
+	if prefix == "" {
+		prefix = defaultPRBranchPrefix
+	}
+	return prefix + run.ID.String()
+}
+
+// openPullRequest opens a pull request with a coding run's normalized result,
+// for agents configured to, records it on the run and notifies the tenant.
+// Failures are logged to the run and otherwise leave it as completed.
+func (s *ExecuteService) openPullRequest(ctx context.Context, agent *models.Agent, run *models.AgentRun, result json.RawMessage) {
+	config := agent.Config.PullRequests
+	if config == nil || s.repositories == nil {
+		return
+	}
+
+	var coding models.CodingResult
+	if err := json.Unmarshal(result, &coding); err != nil {
+		s.log.Warnw("failed to read coding result", "run_id", run.ID, "error", err)
+		return
+	}
+
+	changes := github.PullRequestChanges{
+		Branch: PullRequestBranch(config, run),
+		Title:  coding.Summary,
+		Body:   fmt.Sprintf("Opened by Oracle '%s' from run %s.\n\n%s", agent.Name, run.ID, coding.Summary),
+		Files:  coding.Files,
+	}
+	repo, pr, err := s.repositories.OpenPullRequest(ctx, run.TenantID, config.RepositoryID, changes)
+	if errors.Is(err, github.ErrNoChanges) {
+		return
+	}
+	if err != nil {
+		s.log.Warnw("failed to open pull request", "run_id", run.ID, "agent_id", agent.ID, "error", err)
+		s.runLog(ctx, run.ID, models.LogLevelWarn, "pull request not opened", map[string]interface{}{
+			"branch": changes.Branch,
+			"error":  err.Error(),
+		})
+		return
+	}
+
+	if err := s.repos.AgentRuns.SetPRURL(ctx, run.ID, pr.HTMLURL); err != nil {
+		s.log.Warnw("failed to record pull request", "run_id", run.ID, "error", err)
+	}
+	s.runLog(ctx, run.ID, models.LogLevelInfo, "pull request opened", map[string]interface{}{
+		"branch":    changes.Branch,
+		"pr_number": pr.Number,
+		"pr_url":    pr.HTMLURL,
+	})
+
+	if s.notification != nil {
+		notification := notifications.PRCreatedNotification(run.TenantID, agent.Name, repo.FullName, pr.Number, pr.HTMLURL)
+		if err := s.notification.Send(ctx, notification); err != nil {
+			s.log.Warnw("failed to send pull request notification", "run_id", run.ID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/billing"
+	"github.com/delphi-platform/delphi/backend/internal/metrics"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/internal/security"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// tenantPlanTTL is how long a tenant's plan is cached; plan changes take
+// effect on rate and concurrency limits within this time
+const tenantPlanTTL = 5 * time.Minute
+
+// RateLimitService applies plan-based API rate limits and resolves the plan's
+// limit on concurrent runs
+type RateLimitService struct {
+	repos   *repository.Repositories
+	billing *billing.Service
+	limiter *security.RateLimiter
+	log     *logger.Logger
+
+	mu    sync.Mutex
+	plans map[uuid.UUID]cachedPlan
+}
+
+type cachedPlan struct {
+	plan      models.TenantPlan
+	expiresAt time.Time
+}
+
+func NewRateLimitService(repos *repository.Repositories, billingService *billing.Service, log *logger.Logger) *RateLimitService {
+	return &RateLimitService{
+		repos:   repos,
+		billing: billingService,
+		limiter: security.NewRateLimiter(log),
+		log:     log,
+		plans:   make(map[uuid.UUID]cachedPlan),
+	}
+}
+
+// SetRedis shares the limits' buckets between instances. Without it, each
+// instance limits the requests it serves on its own.
+func (s *RateLimitService) SetRedis(redis *repository.RedisClient) {
+	s.limiter.SetRedis(redis.Client())
+}
+
+// CheckTenant counts a request against the tenant's plan limit
+func (s *RateLimitService) CheckTenant(ctx context.Context, tenantID uuid.UUID) security.RateLimitResult {
+	plan := s.tenantPlan(ctx, tenantID)
+	limits := s.billing.LimitsForPlan(plan)
+	return s.check(ctx, "tenant:"+tenantID.String(), string(plan), limits.RequestsPerMinute)
+}
+
+// CheckAnonymous counts an unauthenticated request against the client's IP,
+// which gets the free plan's limit
+func (s *RateLimitService) CheckAnonymous(ctx context.Context, ip string) security.RateLimitResult {
+	limits := s.billing.LimitsForPlan(models.PlanFree)
+	return s.check(ctx, "ip:"+ip, "anonymous", limits.RequestsPerMinute)
+}
+
+// MaxConcurrentRuns returns how many runs the tenant's plan allows at once,
+// -1 when the plan sets no limit
+func (s *RateLimitService) MaxConcurrentRuns(ctx context.Context, tenantID uuid.UUID) int {
+	return s.billing.LimitsForPlan(s.tenantPlan(ctx, tenantID)).MaxConcurrentRuns
+}
+
+// check counts a request against key's limit; rejections are counted in the
+// metrics under tier
+func (s *RateLimitService) check(ctx context.Context, key, tier string, perMinute int) security.RateLimitResult {
+	if perMinute <= 0 {
+		return security.RateLimitResult{Allowed: true, Limit: -1, Remaining: -1}
+	}
+	result := s.limiter.Check(ctx, key, perMinute, time.Minute)
+	if !result.Allowed {
+		metrics.RateLimitRejections.WithLabelValues(tier).Inc()
+	}
+	return result
+}
+
+// tenantPlan returns the tenant's plan, cached for tenantPlanTTL. Tenants
+// whose plan can't be looked up are treated as free.
+func (s *RateLimitService) tenantPlan(ctx context.Context, tenantID uuid.UUID) models.TenantPlan {
+	now := time.Now()
+
+	s.mu.Lock()
+	cached, ok := s.plans[tenantID]
+	s.mu.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.plan
+	}
+
+	plan := models.PlanFree
+	tenant, err := s.repos.Tenants.GetByID(ctx, tenantID)
+	switch {
+	case err != nil:
+		s.log.Warnw("failed to get tenant plan for rate limiting", "tenant_id", tenantID, "error", err)
+	case tenant != nil:
+		plan = tenant.Plan
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Drop expired entries as we go so tenants that stop calling are forgotten
+	for id, entry := range s.plans {
+		if now.After(entry.expiresAt) {
+			delete(s.plans, id)
+		}
+	}
+	s.plans[tenantID] = cachedPlan{plan: plan, expiresAt: now.Add(tenantPlanTTL)}
+	return plan
+}
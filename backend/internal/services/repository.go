@@ -0,0 +1,349 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/config"
+	"github.com/delphi-platform/delphi/backend/internal/github"
+	"github.com/delphi-platform/delphi/backend/internal/knowledge"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// syncTimeout bounds a single repository sync, full or incremental
+const syncTimeout = 30 * time.Minute
+
+// RepositoryService handles GitHub repository operations, including keeping
+// each repository's knowledge base in step with its default branch
+type RepositoryService struct {
+	cfg     *config.Config
+	repos   *repository.Repositories
+	client  *github.Client
+	kb      *knowledge.Service
+	indexer *knowledge.RepositoryIndexer
+	log     *logger.Logger
+
+	// Syncs of the same repository run one at a time, in the order started
+	mu    sync.Mutex
+	locks map[uuid.UUID]*sync.Mutex
+}
+
+// NewRepositoryService creates a new repository service
+func NewRepositoryService(cfg *config.Config, repos *repository.Repositories, kb *knowledge.Service, log *logger.Logger) *RepositoryService {
+	return &RepositoryService{
+		cfg:     cfg,
+		repos:   repos,
+		client:  github.NewClient(log),
+		kb:      kb,
+		indexer: knowledge.NewRepositoryIndexer(kb, log),
+		log:     log,
+		locks:   make(map[uuid.UUID]*sync.Mutex),
+	}
+}
+
+// fileChanges lists the paths a push changed; nil means reindex every file
+type fileChanges struct {
+	changed []string
+	removed []string
+}
+
+// Sync starts a full reindex of a repository into its knowledge base. The
+// returned job is pending; poll GetSyncJob for its progress.
+func (s *RepositoryService) Sync(ctx context.Context, tenantID, repoID uuid.UUID) (*models.RepositorySyncJob, error) {
+	repo, err := s.repos.Repositories.GetByID(ctx, repoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository: %w", err)
+	}
+	if repo == nil || repo.TenantID != tenantID {
+		return nil, fmt.Errorf("repository not found")
+	}
+	if repo.KnowledgeBaseID == nil {
+		return nil, fmt.Errorf("repository has no knowledge base")
+	}
+	if repo.InstallationID == nil {
+		return nil, fmt.Errorf("repository has no GitHub App installation")
+	}
+
+	job, err := s.createSyncJob(ctx, repo, models.SyncTriggerManual, "")
+	if err != nil {
+		return nil, err
+	}
+
+	go s.runSync(context.Background(), job, repo, repo.DefaultBranch, nil)
+
+	return job, nil
+}
+
+// GetSyncJob returns a repository's sync job
+func (s *RepositoryService) GetSyncJob(ctx context.Context, tenantID, repoID, jobID uuid.UUID) (*models.RepositorySyncJob, error) {
+	job, err := s.repos.Repositories.GetSyncJob(ctx, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sync job: %w", err)
+	}
+	if job == nil || job.TenantID != tenantID || job.RepositoryID != repoID {
+		return nil, fmt.Errorf("sync job not found")
+	}
+	return job, nil
+}
+
+// HandlePush reindexes the files a push to a repository's default branch
+// changed, for every tenant that indexes the repository. Syncs run in the
+// background so the webhook is acknowledged promptly.
+func (s *RepositoryService) HandlePush(payload github.WebhookPayload) error {
+	branch := payload.Branch()
+	if branch == "" || payload.Deleted {
+		return nil
+	}
+
+	ctx := context.Background()
+	repos, err := s.repos.Repositories.ListByFullName(ctx, payload.Repository.FullName)
+	if err != nil {
+		return fmt.Errorf("failed to list repositories: %w", err)
+	}
+
+	// A force push can drop commits whose files are no longer listed, and
+	// GitHub omits the file lists when a push has too many commits
+	var changes *fileChanges
+	if !payload.Forced && len(payload.Commits) > 0 {
+		changed, removed := payload.ChangedFiles()
+		changes = &fileChanges{changed: changed, removed: removed}
+	}
+
+	for _, repo := range repos {
+		if repo.KnowledgeBaseID == nil || branch != repo.DefaultBranch {
+			continue
+		}
+
+		if payload.Installation != nil && (repo.InstallationID == nil || *repo.InstallationID != payload.Installation.ID) {
+			if err := s.repos.Repositories.SetInstallation(ctx, repo.ID, payload.Installation.ID); err != nil {
+				return fmt.Errorf("failed to update installation: %w", err)
+			}
+			repo.InstallationID = &payload.Installation.ID
+		}
+		if repo.InstallationID == nil {
+			s.log.Warnw("skipping push for repository without installation", "repository_id", repo.ID, "repo", repo.FullName)
+			continue
+		}
+
+		job, err := s.createSyncJob(ctx, repo, models.SyncTriggerPush, payload.After)
+		if err != nil {
+			return err
+		}
+		go s.runSync(ctx, job, repo, payload.After, changes)
+	}
+	return nil
+}
+
+func (s *RepositoryService) createSyncJob(ctx context.Context, repo *models.Repository, trigger, commitSHA string) (*models.RepositorySyncJob, error) {
+	job := &models.RepositorySyncJob{
+		ID:           uuid.New(),
+		TenantID:     repo.TenantID,
+		RepositoryID: repo.ID,
+		Trigger:      trigger,
+		Status:       models.SyncStatusPending,
+		CommitSHA:    commitSHA,
+		CreatedAt:    time.Now(),
+	}
+	if err := s.repos.Repositories.CreateSyncJob(ctx, job); err != nil {
+		return nil, fmt.Errorf("failed to create sync job: %w", err)
+	}
+	return job, nil
+}
+
+// repoLock returns the lock serializing syncs of a repository
+func (s *RepositoryService) repoLock(repoID uuid.UUID) *sync.Mutex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lock, ok := s.locks[repoID]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.locks[repoID] = lock
+	}
+	return lock
+}
+
+// runSync indexes the repository at ref and records the job's outcome
+func (s *RepositoryService) runSync(ctx context.Context, job *models.RepositorySyncJob, repo *models.Repository, ref string, changes *fileChanges) {
+	lock := s.repoLock(repo.ID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, syncTimeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := s.repos.Repositories.StartSyncJob(ctx, job.ID, start); err != nil {
+		s.log.Warnw("failed to mark sync job running", "job_id", job.ID, "error", err)
+	}
+
+	err := s.syncFiles(ctx, job, repo, ref, changes)
+
+	completedAt := time.Now()
+	job.CompletedAt = &completedAt
+	job.Status = models.SyncStatusCompleted
+	if err != nil {
+		job.Status = models.SyncStatusFailed
+		job.Error = err.Error()
+		s.log.Errorw("repository sync failed",
+			"job_id", job.ID,
+			"repository_id", repo.ID,
+			"repo", repo.FullName,
+			"error", err,
+		)
+	} else {
+		if err := s.repos.Repositories.UpdateLastSync(ctx, repo.ID, completedAt); err != nil {
+			s.log.Warnw("failed to update last sync", "repository_id", repo.ID, "error", err)
+		}
+		s.log.Infow("repository synced",
+			"job_id", job.ID,
+			"repository_id", repo.ID,
+			"repo", repo.FullName,
+			"trigger", job.Trigger,
+			"files_indexed", job.FilesIndexed,
+			"files_deleted", job.FilesDeleted,
+			"duration_ms", completedAt.Sub(start).Milliseconds(),
+		)
+	}
+
+	if err := s.repos.Repositories.FinishSyncJob(context.WithoutCancel(ctx), job); err != nil {
+		s.log.Errorw("failed to record sync job", "job_id", job.ID, "error", err)
+	}
+}
+
+// syncFiles fetches the repository's files at ref and brings the knowledge
+// base in line with them. A full sync removes documents for files no longer
+// in the repository; an incremental one, those the push removed.
+func (s *RepositoryService) syncFiles(ctx context.Context, job *models.RepositorySyncJob, repo *models.Repository, ref string, changes *fileChanges) error {
+	owner, name, ok := strings.Cut(repo.FullName, "/")
+	if !ok {
+		return fmt.Errorf("invalid repository name: %s", repo.FullName)
+	}
+
+	token, err := s.client.GetInstallationToken(ctx, s.cfg.GitHubAppID, []byte(s.cfg.GitHubAppPrivateKey), *repo.InstallationID)
+	if err != nil {
+		return fmt.Errorf("failed to get installation token: %w", err)
+	}
+
+	var files []knowledge.RepositoryFile
+	if changes == nil {
+		files, err = s.client.FetchRepositoryFiles(ctx, token.Token, owner, name, ref, github.WalkOptions{})
+	} else {
+		files, err = s.client.FetchFiles(ctx, token.Token, owner, name, ref, changes.changed, github.WalkOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch files: %w", err)
+	}
+
+	kbID := *repo.KnowledgeBaseID
+	fetched := make(map[string]bool, len(files))
+	for _, file := range files {
+		fetched[file.Path] = true
+		indexed, err := s.indexFile(ctx, kbID, repo, file)
+		if err != nil {
+			return fmt.Errorf("failed to index %s: %w", file.Path, err)
+		}
+		if indexed {
+			job.FilesIndexed++
+		}
+	}
+
+	// Changed files that were not fetched have become ignored, binary or too
+	// large, so their old versions are dropped too
+	var stale []string
+	if changes == nil {
+		prefix := knowledge.RepositorySource(repo, "")
+		docs, err := s.repos.Knowledge.ListDocumentsBySourcePrefix(ctx, kbID, prefix)
+		if err != nil {
+			return fmt.Errorf("failed to list documents: %w", err)
+		}
+		for _, doc := range docs {
+			if path := strings.TrimPrefix(doc.Source, prefix); !fetched[path] {
+				stale = append(stale, path)
+			}
+		}
+	} else {
+		stale = append(stale, changes.removed...)
+		for _, path := range changes.changed {
+			if !fetched[path] {
+				stale = append(stale, path)
+			}
+		}
+	}
+
+	for _, path := range stale {
+		deleted, err := s.deleteFile(ctx, kbID, repo, path)
+		if err != nil {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		if deleted {
+			job.FilesDeleted++
+		}
+	}
+	return nil
+}
+
+// indexFile ingests a file, replacing the document for its previous version.
+// It reports false when the indexed content is already current.
+func (s *RepositoryService) indexFile(ctx context.Context, kbID uuid.UUID, repo *models.Repository, file knowledge.RepositoryFile) (bool, error) {
+	result, err := s.indexer.IndexFile(ctx, kbID, repo, file)
+	if err != nil {
+		return false, err
+	}
+	return result.Outcome != knowledge.IngestUnchanged, nil
+}
+
+// deleteFile removes a file's document. It reports false when the file was
+// not indexed.
+func (s *RepositoryService) deleteFile(ctx context.Context, kbID uuid.UUID, repo *models.Repository, path string) (bool, error) {
+	doc, err := s.repos.Knowledge.GetDocumentBySource(ctx, kbID, knowledge.RepositorySource(repo, path))
+	if err != nil || doc == nil {
+		return false, err
+	}
+	return true, s.removeDocument(ctx, doc.ID)
+}
+
+func (s *RepositoryService) removeDocument(ctx context.Context, documentID uuid.UUID) error {
+	if err := s.kb.DeleteDocument(ctx, documentID); err != nil {
+		return err
+	}
+	return s.repos.Knowledge.DeleteDocument(ctx, documentID)
+}
+
+// OpenPullRequest commits changes to a new branch of a tenant's repository
+// and opens a pull request into its default branch
+func (s *RepositoryService) OpenPullRequest(ctx context.Context, tenantID, repoID uuid.UUID, changes github.PullRequestChanges) (*models.Repository, *github.PullRequest, error) {
+	repo, err := s.repos.Repositories.GetByID(ctx, repoID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get repository: %w", err)
+	}
+	if repo == nil || repo.TenantID != tenantID {
+		return nil, nil, fmt.Errorf("repository not found")
+	}
+	if repo.InstallationID == nil {
+		return nil, nil, fmt.Errorf("repository has no GitHub App installation")
+	}
+	owner, name, ok := strings.Cut(repo.FullName, "/")
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid repository name: %s", repo.FullName)
+	}
+
+	token, err := s.client.GetInstallationToken(ctx, s.cfg.GitHubAppID, []byte(s.cfg.GitHubAppPrivateKey), *repo.InstallationID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get installation token: %w", err)
+	}
+
+	changes.Base = repo.DefaultBranch
+	pr, err := s.client.OpenPullRequest(ctx, token.Token, owner, name, changes)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.log.Infow("pull request opened", "repository_id", repo.ID, "branch", changes.Branch, "pr", pr.Number)
+	return repo, pr, nil
+}
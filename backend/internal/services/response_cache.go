@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/google/uuid"
+)
+
+// defaultResponseCacheTTL is how long a cached response is reused when no TTL
+// is configured
+const defaultResponseCacheTTL = time.Hour
+
+// ResponseCacheKey is the Redis key an agent's response to a prompt is cached
+// under. It hashes everything that shapes the response, so changing the
+// agent's model, temperature or system prompt misses entries cached before.
+func ResponseCacheKey(agentID uuid.UUID, model, systemPrompt, prompt string, temperature float64) string {
+	hash := sha256.New()
+	for _, part := range []string{model, strconv.FormatFloat(temperature, 'g', -1, 64), systemPrompt, prompt} {
+		// Length-prefixed, so parts can't run into each other
+		fmt.Fprintf(hash, "%d:%s", len(part), part)
+	}
+	return fmt.Sprintf("response_cache:%s:%s", agentID, hex.EncodeToString(hash.Sum(nil)))
+}
+
+// responseCacheable reports whether the agent's runs may be served from the
+// response cache: only deterministic agents, whose identical runs give the
+// same result, are
+func (s *ExecuteService) responseCacheable(agent *models.Agent) bool {
+	return s.cfg.ResponseCacheEnabled && s.redis != nil && agent.Config.Deterministic()
+}
+
+func (s *ExecuteService) responseCacheTTL() time.Duration {
+	if s.cfg.ResponseCacheTTLMinutes <= 0 {
+		return defaultResponseCacheTTL
+	}
+	return time.Duration(s.cfg.ResponseCacheTTLMinutes) * time.Minute
+}
+
+// completeFromCache completes the run with a cached response, if there is
+// one, and returns its agent to ready. It reports whether the run was served.
+func (s *ExecuteService) completeFromCache(ctx context.Context, agent *models.Agent, run *models.AgentRun, key string) bool {
+	cached, ok, err := s.redis.Lookup(ctx, key)
+	if err != nil {
+		s.log.Warnw("failed to look up cached response", "run_id", run.ID, "error", err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+
+	result, err := s.limitResult(ctx, run, json.RawMessage(cached))
+	if err != nil {
+		s.log.Warnw("failed to limit cached result", "run_id", run.ID, "error", err)
+		return false
+	}
+	if err := s.repos.AgentRuns.CompleteCached(ctx, run.ID, result); err != nil {
+		s.log.Errorw("failed to complete cached run", "run_id", run.ID, "error", err)
+		return false
+	}
+	s.runLog(ctx, run.ID, models.LogLevelInfo, "run completed from cache", nil)
+	s.publishRunEvent(ctx, run.ID, WebhookEventRunCompleted)
+
+	if err := s.repos.Agents.UpdateStatus(ctx, agent.ID, models.AgentStatusReady); err != nil {
+		s.log.Warnw("failed to update agent status", "agent_id", agent.ID, "error", err)
+	}
+
+	s.log.Infow("execution served from cache", "run_id", run.ID, "agent_id", agent.ID)
+	return true
+}
+
+// cacheResponse stores a run's full result for identical runs to reuse
+func (s *ExecuteService) cacheResponse(ctx context.Context, run *models.AgentRun, key string, result json.RawMessage) {
+	if err := s.redis.Set(ctx, key, []byte(result), s.responseCacheTTL()); err != nil {
+		s.log.Warnw("failed to cache response", "run_id", run.ID, "error", err)
+	}
+}
+
+// invalidateResponseCache drops the agent's cached responses. Entries cached
+// under an older system prompt are already missed, as it is part of the key;
+// dropping them frees their memory before they expire.
+func invalidateResponseCache(ctx context.Context, redis *repository.RedisClient, agentID uuid.UUID) error {
+	if redis == nil {
+		return nil
+	}
+	_, err := redis.DeleteMatching(ctx, fmt.Sprintf("response_cache:%s:*", agentID))
+	return err
+}
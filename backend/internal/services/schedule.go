@@ -0,0 +1,320 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	scheduleCheckInterval = 30 * time.Second
+	maxDueSchedules       = 100
+)
+
+// ScheduleService manages cron schedules for agents and fires them. Every
+// instance runs the scheduler loop; a Postgres advisory lock lets only one of
+// them fire due schedules at a time.
+type ScheduleService struct {
+	repos   *repository.Repositories
+	execute *ExecuteService
+	log     *logger.Logger
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewScheduleService creates a new schedule service and starts its scheduler loop
+func NewScheduleService(repos *repository.Repositories, execute *ExecuteService, log *logger.Logger) *ScheduleService {
+	s := &ScheduleService{
+		repos:   repos,
+		execute: execute,
+		log:     log,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go s.schedulerLoop()
+	return s
+}
+
+// Stop stops the scheduler loop, waiting for schedules being fired to finish
+func (s *ScheduleService) Stop() {
+	close(s.stop)
+	<-s.done
+}
+
+// CreateScheduleRequest represents schedule creation input. CronExpr is a
+// standard five-field expression or a descriptor such as @daily; Timezone is
+// an IANA name and defaults to UTC.
+type CreateScheduleRequest struct {
+	CronExpr string `json:"cron_expr"`
+	Timezone string `json:"timezone"`
+	Prompt   string `json:"prompt"`
+	Enabled  *bool  `json:"enabled"`
+}
+
+// UpdateScheduleRequest represents schedule changes; unset fields are kept
+type UpdateScheduleRequest struct {
+	CronExpr *string `json:"cron_expr"`
+	Timezone *string `json:"timezone"`
+	Prompt   *string `json:"prompt"`
+	Enabled  *bool   `json:"enabled"`
+}
+
+// parseSchedule parses a cron expression evaluated in the named timezone
+func parseSchedule(expr, timezone string) (cron.Schedule, *time.Location, error) {
+	if strings.HasPrefix(expr, "TZ=") || strings.HasPrefix(expr, "CRON_TZ=") {
+		return nil, nil, fmt.Errorf("set the timezone field instead of a TZ prefix")
+	}
+	schedule, err := cron.ParseStandard(expr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid cron expression: %w", err)
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid timezone: %s", timezone)
+	}
+	return schedule, loc, nil
+}
+
+// nextRunAt returns when a schedule next fires after the given time, or nil if
+// it is disabled
+func nextRunAt(sched *models.ScheduledExecution, after time.Time) (*time.Time, error) {
+	schedule, loc, err := parseSchedule(sched.CronExpr, sched.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	if !sched.Enabled {
+		return nil, nil
+	}
+	next := schedule.Next(after.In(loc))
+	if next.IsZero() {
+		return nil, fmt.Errorf("cron expression never fires")
+	}
+	next = next.UTC()
+	return &next, nil
+}
+
+// checkAgent verifies the agent belongs to the tenant
+func (s *ScheduleService) checkAgent(ctx context.Context, tenantID, agentID uuid.UUID) error {
+	agent, err := s.repos.Agents.GetByID(ctx, agentID)
+	if err != nil {
+		return fmt.Errorf("failed to get agent: %w", err)
+	}
+	if agent == nil || agent.TenantID != tenantID {
+		return fmt.Errorf("agent not found")
+	}
+	return nil
+}
+
+// List returns an agent's schedules
+func (s *ScheduleService) List(ctx context.Context, tenantID, agentID uuid.UUID) ([]*models.ScheduledExecution, error) {
+	if err := s.checkAgent(ctx, tenantID, agentID); err != nil {
+		return nil, err
+	}
+	schedules, err := s.repos.Schedules.ListByAgent(ctx, agentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schedules: %w", err)
+	}
+	if schedules == nil {
+		schedules = []*models.ScheduledExecution{}
+	}
+	return schedules, nil
+}
+
+// Get retrieves one of an agent's schedules
+func (s *ScheduleService) Get(ctx context.Context, tenantID, agentID, scheduleID uuid.UUID) (*models.ScheduledExecution, error) {
+	sched, err := s.repos.Schedules.GetByID(ctx, scheduleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schedule: %w", err)
+	}
+	if sched == nil || sched.TenantID != tenantID || sched.AgentID != agentID {
+		return nil, fmt.Errorf("schedule not found")
+	}
+	return sched, nil
+}
+
+// Create adds a schedule to an agent
+func (s *ScheduleService) Create(ctx context.Context, tenantID, agentID uuid.UUID, req *CreateScheduleRequest) (*models.ScheduledExecution, error) {
+	if err := s.checkAgent(ctx, tenantID, agentID); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(req.Prompt) == "" {
+		return nil, fmt.Errorf("prompt is required")
+	}
+
+	now := time.Now()
+	sched := &models.ScheduledExecution{
+		ID:        uuid.New(),
+		TenantID:  tenantID,
+		AgentID:   agentID,
+		CronExpr:  strings.TrimSpace(req.CronExpr),
+		Timezone:  req.Timezone,
+		Prompt:    req.Prompt,
+		Enabled:   req.Enabled == nil || *req.Enabled,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if sched.Timezone == "" {
+		sched.Timezone = "UTC"
+	}
+
+	next, err := nextRunAt(sched, now)
+	if err != nil {
+		return nil, err
+	}
+	sched.NextRunAt = next
+
+	if err := s.repos.Schedules.Create(ctx, sched); err != nil {
+		return nil, fmt.Errorf("failed to create schedule: %w", err)
+	}
+
+	s.log.Infow("schedule created", "schedule_id", sched.ID, "agent_id", agentID, "tenant_id", tenantID, "cron_expr", sched.CronExpr)
+
+	return sched, nil
+}
+
+// Update changes a schedule and recomputes its next run
+func (s *ScheduleService) Update(ctx context.Context, tenantID, agentID, scheduleID uuid.UUID, req *UpdateScheduleRequest) (*models.ScheduledExecution, error) {
+	sched, err := s.Get(ctx, tenantID, agentID, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.CronExpr != nil {
+		sched.CronExpr = strings.TrimSpace(*req.CronExpr)
+	}
+	if req.Timezone != nil {
+		sched.Timezone = *req.Timezone
+		if sched.Timezone == "" {
+			sched.Timezone = "UTC"
+		}
+	}
+	if req.Prompt != nil {
+		if strings.TrimSpace(*req.Prompt) == "" {
+			return nil, fmt.Errorf("prompt is required")
+		}
+		sched.Prompt = *req.Prompt
+	}
+	if req.Enabled != nil {
+		sched.Enabled = *req.Enabled
+	}
+
+	next, err := nextRunAt(sched, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	sched.NextRunAt = next
+
+	if err := s.repos.Schedules.Update(ctx, sched); err != nil {
+		return nil, fmt.Errorf("failed to update schedule: %w", err)
+	}
+	return sched, nil
+}
+
+// Delete removes a schedule
+func (s *ScheduleService) Delete(ctx context.Context, tenantID, agentID, scheduleID uuid.UUID) error {
+	if _, err := s.Get(ctx, tenantID, agentID, scheduleID); err != nil {
+		return err
+	}
+	if err := s.repos.Schedules.Delete(ctx, scheduleID); err != nil {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+	return nil
+}
+
+func (s *ScheduleService) schedulerLoop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(scheduleCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.fireDue(context.Background())
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// fireDue starts a run for every due schedule, unless another instance is
+// already doing so
+func (s *ScheduleService) fireDue(ctx context.Context) {
+	acquired, err := s.repos.Schedules.WithSchedulerLock(ctx, func(ctx context.Context) error {
+		due, err := s.repos.Schedules.ListDue(ctx, time.Now(), maxDueSchedules)
+		if err != nil {
+			return fmt.Errorf("failed to list due schedules: %w", err)
+		}
+		for _, sched := range due {
+			s.fire(ctx, sched)
+		}
+		return nil
+	})
+	if err != nil {
+		s.log.Warnw("failed to fire schedules", "error", err)
+		return
+	}
+	if !acquired {
+		s.log.Debugw("scheduler lock held by another instance")
+	}
+}
+
+// fire advances a due schedule and starts its run. The schedule is advanced
+// first, so a failure afterwards skips one run rather than repeating it. Runs
+// missed while no scheduler was running are not caught up; the schedule
+// resumes from its next time after now.
+func (s *ScheduleService) fire(ctx context.Context, sched *models.ScheduledExecution) {
+	now := time.Now()
+	next, err := nextRunAt(sched, now)
+	if err != nil || next == nil {
+		s.log.Warnw("disabling schedule that cannot be evaluated", "schedule_id", sched.ID, "error", err)
+		sched.Enabled = false
+		sched.NextRunAt = nil
+		if err := s.repos.Schedules.Update(ctx, sched); err != nil {
+			s.log.Errorw("failed to disable schedule", "schedule_id", sched.ID, "error", err)
+		}
+		return
+	}
+
+	advanced, err := s.repos.Schedules.Advance(ctx, sched.ID, *sched.NextRunAt, *next)
+	if err != nil {
+		s.log.Errorw("failed to advance schedule", "schedule_id", sched.ID, "error", err)
+		return
+	}
+	if !advanced {
+		return
+	}
+
+	run, err := s.execute.Create(ctx, sched.TenantID, &ExecuteRequest{
+		AgentID: sched.AgentID,
+		Prompt:  sched.Prompt,
+	})
+	if err != nil {
+		s.log.Warnw("scheduled execution not started",
+			"schedule_id", sched.ID,
+			"agent_id", sched.AgentID,
+			"tenant_id", sched.TenantID,
+			"error", err,
+		)
+		return
+	}
+
+	if err := s.repos.Schedules.SetLastRun(ctx, sched.ID, run.ID); err != nil {
+		s.log.Warnw("failed to record scheduled run", "schedule_id", sched.ID, "run_id", run.ID, "error", err)
+	}
+
+	s.log.Infow("scheduled execution started",
+		"schedule_id", sched.ID,
+		"run_id", run.ID,
+		"agent_id", sched.AgentID,
+		"next_run_at", next,
+	)
+}
@@ -1,8 +1,23 @@
 package services
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/billing"
 	"github.com/delphi-platform/delphi/backend/internal/config"
+	"github.com/delphi-platform/delphi/backend/internal/execution"
+	"github.com/delphi-platform/delphi/backend/internal/knowledge"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/payload"
+	"github.com/delphi-platform/delphi/backend/internal/providers"
 	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/internal/security"
 	"github.com/delphi-platform/delphi/backend/pkg/auth"
 	"github.com/delphi-platform/delphi/backend/pkg/crypto"
 	"github.com/delphi-platform/delphi/backend/pkg/logger"
@@ -10,62 +25,382 @@ import (
 
 // Services contains all service instances
 type Services struct {
-	Auth       *AuthService
-	Tenant     *TenantService
-	User       *UserService
-	APIKey     *APIKeyService
-	Agent      *AgentService
-	Execute    *ExecuteService
-	Knowledge  *KnowledgeService
-	Repository *RepositoryService
-	Business   *BusinessService
-	Project    *ProjectService
-	Financial  *FinancialService
-	Social     *SocialService
-	IoT        *IoTService
-	Cost       *CostService
-	Dashboard  *DashboardService
-	Audit      *AuditService
-	Settings   *SettingsService
-	Webhook    *WebhookService
-	WebSocket  *WebSocketService
+	Auth         *AuthService
+	Tenant       *TenantService
+	User         *UserService
+	APIKey       *APIKeyServiceImpl
+	Agent        *AgentService
+	Execute      *ExecuteService
+	Knowledge    *KnowledgeService
+	Repository   *RepositoryService
+	Business     *BusinessService
+	Project      *ProjectService
+	Financial    *FinancialService
+	Social       *SocialService
+	IoT          *IoTService
+	Cost         *CostService
+	Dashboard    *DashboardService
+	Audit        *AuditService
+	Settings     *SettingsService
+	Webhook      *WebhookService
+	WebSocket    *WebSocketService
+	Notification *NotificationService
+	APIUsage     *APIUsageService
+	Schedule     *ScheduleService
+	RateLimit    *RateLimitService
+
+	// WebhookDelivery manages tenants' outbound webhooks; Webhook receives
+	// inbound ones
+	WebhookDelivery *WebhookDeliveryService
+
+	// Digest sends each tenant's weekly summary
+	Digest *DigestService
+
+	// Health checks the database, Redis and providers for readiness probes
+	Health *HealthService
+
+	// machinePool keeps warm Fly Machines; nil when the pool is disabled
+	machinePool *execution.MachinePool
 }
 
-// NewServices creates all service instances
-func NewServices(cfg *config.Config, repos *repository.Repositories, redis *repository.RedisClient, log *logger.Logger) *Services {
-	// Initialize encryptor for secrets
+// NewServices creates all service instances. It fails when configuration the
+// services can't run safely without, such as the encryption key, is invalid.
+func NewServices(cfg *config.Config, repos *repository.Repositories, redis *repository.RedisClient, log *logger.Logger) (*Services, error) {
+	// Initialize encryptor for secrets: provider API keys, social and IoT
+	// credentials, MFA secrets and webhook signing secrets. Secrets are never
+	// stored in plaintext, so without a key, allowed only in development,
+	// storing one fails.
 	var encryptor *crypto.Encryptor
 	if cfg.EncryptionKey != "" {
 		var err error
-		encryptor, err = crypto.NewEncryptor(cfg.EncryptionKey)
+		encryptor, err = newEncryptor(cfg)
 		if err != nil {
-			log.Warnw("failed to create encryptor, using nil", "error", err)
+			return nil, fmt.Errorf("invalid encryption key: %w", err)
 		}
+	} else if !cfg.IsDevelopment() {
+		return nil, fmt.Errorf("ENCRYPTION_KEY is required outside development")
+	} else {
+		log.Warnw("ENCRYPTION_KEY not set: provider keys and other secrets can't be stored")
 	}
 
 	// Initialize JWT manager
-	jwtManager := auth.NewJWTManager(cfg.SupabaseServiceRoleKey, 60, 7) // 60 min access, 7 day refresh
+	jwtManager := auth.NewJWTManager(cfg.JWTSecret, cfg.JWTAccessTTLMinutes, cfg.JWTRefreshTTLDays)
+
+	// Initialize provider manager and tenant key resolution. Stored keys are
+	// revalidated in the background, with changes recorded in the audit log.
+	// Providers send requests to the configured endpoints, falling back to
+	// their public APIs
+	providerManager := providers.NewManager()
+	endpoints := ServerProviderEndpoints(cfg, log)
+	for name, endpoint := range endpoints {
+		providerManager.SetEndpoint(name, endpoint)
+	}
+	for name, key := range map[models.AIProvider]string{models.ProviderOpenAI: cfg.OpenAIAPIKey, models.ProviderAnthropic: cfg.AnthropicAPIKey, models.ProviderGoogle: cfg.GoogleAIAPIKey} {
+		if key == "" {
+			continue
+		}
+		provider, err := providerManager.CreateProviderWithKey(name, key, providers.Endpoint{})
+		if err != nil {
+			log.Warnw("failed to create provider", "provider", name, "error", err)
+			continue
+		}
+		providerManager.RegisterProvider(provider)
+	}
+	health := NewHealthService(repos, redis, log)
+	for name, key := range map[string]string{"openai": cfg.OpenAIAPIKey, "anthropic": cfg.AnthropicAPIKey, "google": cfg.GoogleAIAPIKey} {
+		if key == "" {
+			continue
+		}
+		probe := ""
+		if endpoint := endpoints[models.AIProvider(name)]; endpoint.BaseURL != "" {
+			probe = strings.TrimSuffix(endpoint.BaseURL, "/") + "/models"
+		}
+		health.AddProvider(name, probe)
+	}
+	if cfg.OllamaBaseURL != "" {
+		health.AddProvider("ollama", cfg.OllamaBaseURL)
+	}
+
+	audit := NewAuditService(repos, log)
+	if cfg.AuditOverflowFile != "" {
+		if err := audit.SetOverflowFile(cfg.AuditOverflowFile); err != nil {
+			log.Warnw("audit overflow file unavailable, unstored entries will be dropped", "error", err)
+		}
+	}
+	apiKeys := NewAPIKeyServiceImpl(repos, encryptor, providerManager, audit, log)
+
+	// Initialize knowledge base engine. A misconfigured embedder or vector
+	// store fails startup rather than silently ingesting with another one.
+	embedder, err := NewKnowledgeEmbedder(context.Background(), cfg)
+	if err != nil {
+		return nil, err
+	}
+	vectorStore, err := newVectorStore(cfg, repos, embedder)
+	if err != nil {
+		return nil, err
+	}
+	knowledgeEngine := knowledge.NewService(vectorStore, embedder, log)
+	knowledgeEngine.SetRequestLogging(cfg.KnowledgeRequestLogging)
+	// Token-based chunking targets the embedding model, which uses OpenAI's tokenizer
+	knowledgeEngine.SetTokenCounter(providers.NewOpenAIProvider(cfg.OpenAIAPIKey).CountTokens)
+	// Re-ingesting a source replaces its document rather than duplicating it
+	knowledgeEngine.SetDocumentStore(repos.Knowledge)
+
+	// Initialize live run logs, shared by execution and the WebSocket endpoint
+	webSocket := NewWebSocketService(repos, redis, log)
+
+	// Initialize notifications and billing, which reports payment problems through them
+	notification := NewNotificationService(cfg, repos, log)
+	billingService := billing.NewService(cfg.StripeSecretKey, cfg.StripePricePro, cfg.StripePriceEnterprise, repos, notification, log)
+
+	// Concurrent runs are limited by the tenant's plan, which the rate limiter
+	// already looks up and caches
+	rateLimit := NewRateLimitService(repos, billingService, log)
+	if redis != nil {
+		rateLimit.SetRedis(redis)
+	}
+
+	// New tenants get a Stripe customer only when Stripe is configured
+	var tenantBilling *billing.Service
+	if cfg.StripeSecretKey != "" {
+		tenantBilling = billingService
+	}
+	concurrency := execution.NewConcurrencyLimiter(rateLimit.MaxConcurrentRuns, execution.ConcurrencyConfig{
+		Max:       cfg.MaxConcurrentRunsPerTenant,
+		MaxQueued: cfg.MaxQueuedRunsPerTenant,
+	})
+	if redis != nil {
+		ttl := execution.SlotTTL(time.Duration(cfg.MaxRunTimeoutSeconds) * time.Second)
+		concurrency.SetStore(execution.NewRedisSlotStore(redis.Client(), ttl), log)
+	}
+
+	// Pushes to connected repositories reindex their knowledge bases, and
+	// coding agents open pull requests on them
+	repositories := NewRepositoryService(cfg, repos, knowledgeEngine, log)
+
+	// Finished runs are published to the tenant's webhooks, and scheduled
+	// executions start runs through the execute service
+	webhookDelivery := NewWebhookDeliveryService(cfg, repos, encryptor, log)
+	execute := NewExecuteService(cfg, repos, redis, webSocket, notification, webhookDelivery, concurrency, newPayloadLimiter(cfg, log), providerManager, repositories, log)
+	runner, machinePool, err := NewExecutionRunner(cfg, knowledgeEngine, webSocket, providerManager, audit.audit, log)
+	if err != nil {
+		return nil, err
+	}
+	execute.SetRunner(runner)
+
+	tenants := NewTenantService(cfg, repos, tenantBilling, knowledgeEngine, audit, log)
+
+	// Weekly digests summarize the tenant's costs and send them as notifications
+	cost := NewCostService(cfg, repos, redis, log)
 
 	return &Services{
-		Auth:       NewAuthService(cfg, repos, jwtManager, log),
-		Tenant:     NewTenantService(repos, log),
-		User:       NewUserService(repos, log),
-		APIKey:     NewAPIKeyService(repos, encryptor, log),
-		Agent:      NewAgentService(cfg, repos, redis, log),
-		Execute:    NewExecuteService(cfg, repos, redis, log),
-		Knowledge:  NewKnowledgeService(repos, log),
-		Repository: NewRepositoryService(cfg, repos, log),
-		Business:   NewBusinessService(repos, log),
-		Project:    NewProjectService(repos, log),
-		Financial:  NewFinancialService(repos, log),
-		Social:     NewSocialService(cfg, repos, log),
-		IoT:        NewIoTService(repos, encryptor, log),
-		Cost:       NewCostService(repos, redis, log),
-		Dashboard:  NewDashboardService(repos, redis, log),
-		Audit:      NewAuditService(repos, log),
-		Settings:   NewSettingsService(repos, log),
-		Webhook:    NewWebhookService(cfg, repos, log),
-		WebSocket:  NewWebSocketService(redis, log),
+		Auth:         NewAuthService(cfg, repos, jwtManager, tenants, encryptor, audit, log),
+		Tenant:       tenants,
+		User:         NewUserService(repos, audit, log),
+		APIKey:       apiKeys,
+		Agent:        NewAgentService(cfg, repos, redis, log),
+		Execute:      execute,
+		Knowledge:    NewKnowledgeService(repos, knowledgeEngine, apiKeys, providerManager, log),
+		Repository:   repositories,
+		Business:     NewBusinessService(repos, log),
+		Project:      NewProjectService(repos, log),
+		Financial:    NewFinancialService(repos, log),
+		Social:       NewSocialService(cfg, repos, encryptor, apiKeys, providerManager, log),
+		IoT:          NewIoTService(cfg, repos, encryptor, log),
+		Cost:         cost,
+		Dashboard:    NewDashboardService(repos, redis, concurrency, log),
+		Audit:        audit,
+		Settings:     NewSettingsService(repos, log),
+		Webhook:      NewWebhookService(cfg, repos, billingService, repositories, log),
+		WebSocket:    webSocket,
+		Notification: notification,
+		APIUsage:     NewAPIUsageService(repos, log),
+		Schedule:     NewScheduleService(repos, execute, log),
+		RateLimit:    rateLimit,
+
+		WebhookDelivery: webhookDelivery,
+		Digest:          NewDigestService(repos, cost, notification, log),
+		Health:          health,
+
+		machinePool: machinePool,
+	}, nil
+}
+
+// Stop stops the background workers once the server has stopped taking
+// requests, letting each flush its in-flight work. Workers that produce work
+// for others stop first, and the audit log last. It returns ctx's error if
+// the workers are still draining when ctx is done.
+func (s *Services) Stop(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var wg sync.WaitGroup
+		for _, stop := range []func(){s.Schedule.Stop, s.Digest.Stop, s.Tenant.Stop, s.Social.Stop, s.APIKey.Stop} {
+			wg.Add(1)
+			go func(stop func()) {
+				defer wg.Done()
+				stop()
+			}(stop)
+		}
+		wg.Wait()
+
+		s.WebhookDelivery.Stop()
+		if s.machinePool != nil {
+			s.machinePool.Stop()
+		}
+		s.IoT.Stop()
+		s.APIUsage.Stop()
+		s.WebSocket.Stop()
+		s.Audit.Stop()
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// newEncryptor creates the secrets encryptor from the current key and any
+// retired keys still needed to decrypt older values
+func newEncryptor(cfg *config.Config) (*crypto.Encryptor, error) {
+	encryptor, err := crypto.NewVersionedEncryptor(cfg.EncryptionKey, byte(cfg.EncryptionKeyVersion))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range strings.Split(cfg.EncryptionPreviousKeys, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		version, key, ok := strings.Cut(entry, ":")
+		n, err := strconv.Atoi(version)
+		if !ok || err != nil || n < 1 || n > 255 {
+			return nil, fmt.Errorf("invalid previous encryption key %q: want version:hexkey", version)
+		}
+		if err := encryptor.AddKey(byte(n), key); err != nil {
+			return nil, fmt.Errorf("invalid previous encryption key %d: %w", n, err)
+		}
+	}
+	return encryptor, nil
+}
+
+// NewKnowledgeEmbedder creates the embedder KNOWLEDGE_EMBEDDER names. It fails
+// for an unknown embedder or one that can't be used, such as openai without
+// OPENAI_API_KEY or ollama when the server can't be reached, as vectors from
+// another embedder would be useless once the configured one works.
+func NewKnowledgeEmbedder(ctx context.Context, cfg *config.Config) (knowledge.Embedder, error) {
+	switch cfg.KnowledgeEmbedder {
+	case "", "mock":
+		return knowledge.NewMockEmbedder(cfg.KnowledgeEmbeddingDimensions), nil
+	case "openai":
+		embedder, err := knowledge.NewOpenAIEmbedder(cfg.OpenAIAPIKey, cfg.KnowledgeEmbeddingModel, cfg.KnowledgeEmbeddingDimensions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OpenAI embedder: %w", err)
+		}
+		return embedder, nil
+	case "ollama":
+		probeCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+		defer cancel()
+		embedder, err := knowledge.NewOllamaEmbedder(probeCtx, cfg.OllamaBaseURL, cfg.KnowledgeOllamaModel)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Ollama embedder: %w", err)
+		}
+		return embedder, nil
 	}
+	return nil, fmt.Errorf("unknown KNOWLEDGE_EMBEDDER %q: want mock, openai or ollama", cfg.KnowledgeEmbedder)
 }
 
+// newVectorStore creates the vector store KNOWLEDGE_VECTOR_STORE names,
+// failing rather than keeping embeddings in memory when pgvector can't be used
+func newVectorStore(cfg *config.Config, repos *repository.Repositories, embedder knowledge.Embedder) (knowledge.VectorStore, error) {
+	switch cfg.KnowledgeVectorStore {
+	case "", "memory":
+		return knowledge.NewMockVectorStore(), nil
+	case "pgvector":
+		store, err := knowledge.NewPgVectorStore(context.Background(), repos.DB(), embedder.Dimension())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create pgvector store: %w", err)
+		}
+		return store, nil
+	}
+	return nil, fmt.Errorf("unknown KNOWLEDGE_VECTOR_STORE %q: want memory or pgvector", cfg.KnowledgeVectorStore)
+}
+
+// NewExecutionRunner creates the runner agent runs execute through. With a
+// Fly API token, runs get their own machine, taken from the warm pool when it
+// is enabled; without one they call the agent's provider in-process, with the
+// knowledge_search tool available. Either way runs of agents with knowledge
+// bases are briefed with the knowledge relevant to their prompt, prompts and
+// responses are checked against the guardrails, with blocked and redacted
+// content recorded in audit, and each run's output is streamed to its log.
+// The pool is nil when it is disabled.
+func NewExecutionRunner(cfg *config.Config, knowledgeEngine *knowledge.Service, runLogs execution.RunLogSink, providerManager *providers.Manager, audit *security.AuditService, log *logger.Logger) (*execution.ExecutionRunner, *execution.MachinePool, error) {
+	guardrail, err := newGuardrail(cfg, log)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	machines := execution.NewFlyMachineManager(cfg.FlyAPIToken, cfg.FlyOrg, cfg.FlyAppName, cfg.FlyRegion, log)
+	briefing := execution.NewBriefingEngine(log)
+	briefing.SetKnowledge(knowledgeEngine, cfg.KnowledgeBriefingTokens)
+	runner := execution.NewExecutionRunner(machines, briefing, log)
+	runner.SetRunTimeouts(time.Duration(cfg.DefaultRunTimeoutSeconds)*time.Second, time.Duration(cfg.MaxRunTimeoutSeconds)*time.Second)
+	runner.SetRunLogSink(runLogs)
+	runner.SetGuardrail(guardrail, audit)
+
+	tools := execution.NewToolRegistry()
+	tools.Register(execution.NewKnowledgeSearchTool(knowledgeEngine))
+	runner.SetToolLoop(execution.NewToolLoop(tools, runLogs, log), providerManager)
+
+	var pool *execution.MachinePool
+	if cfg.FlyAPIToken != "" && cfg.FlyWarmPoolSize > 0 {
+		pool = execution.NewMachinePool(machines, execution.PoolConfig{
+			Size:        cfg.FlyWarmPoolSize,
+			IdleTimeout: time.Duration(cfg.FlyWarmPoolIdleMinutes) * time.Minute,
+		}, log)
+		runner.SetMachinePool(pool)
+	}
+	return runner, pool, nil
+}
+
+// newGuardrail creates the guardrail from GUARDRAIL_BLOCKED_PATTERNS, a JSON
+// array of regular expressions applied to every agent. Agents asking for
+// moderation are checked with the OpenAI moderation API when an OpenAI key
+// is configured.
+func newGuardrail(cfg *config.Config, log *logger.Logger) (*security.DefaultGuardrail, error) {
+	var patterns []string
+	if cfg.GuardrailBlockedPatterns != "" {
+		if err := json.Unmarshal([]byte(cfg.GuardrailBlockedPatterns), &patterns); err != nil {
+			return nil, fmt.Errorf("GUARDRAIL_BLOCKED_PATTERNS must be a JSON array of strings: %w", err)
+		}
+	}
+
+	var moderator security.Moderator
+	if cfg.OpenAIAPIKey != "" {
+		moderator = security.NewOpenAIModerator(cfg.OpenAIAPIKey)
+	}
+	return security.NewGuardrail(patterns, moderator, log)
+}
+
+// newPayloadLimiter bounds stored run prompts and responses, keeping full
+// payloads under the configured directory. If the directory cannot be used,
+// full payloads are kept in memory instead.
+func newPayloadLimiter(cfg *config.Config, log *logger.Logger) *payload.Limiter {
+	limits := payload.Limits{
+		MaxPromptBytes:   cfg.MaxStoredPromptBytes,
+		MaxResponseBytes: cfg.MaxStoredResponseBytes,
+	}
+	var store payload.Store = payload.NewMemoryStore()
+	if cfg.PayloadStoreDir != "" {
+		fileStore, err := payload.NewFileStore(cfg.PayloadStoreDir)
+		if err != nil {
+			log.Warnw("failed to create payload store, keeping full payloads in memory", "dir", cfg.PayloadStoreDir, "error", err)
+		} else {
+			store = fileStore
+		}
+	}
+	return payload.NewLimiter(store, limits)
+}
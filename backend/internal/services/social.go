@@ -0,0 +1,656 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/config"
+	"github.com/delphi-platform/delphi/backend/internal/execution"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/providers"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/internal/social"
+	"github.com/delphi-platform/delphi/backend/pkg/crypto"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	socialPublishInterval = 30 * time.Second
+	maxDuePosts           = 50
+
+	// socialClaimTimeout is how long a post may stay claimed before its worker
+	// is presumed dead. Each publishing pass is bounded well below it.
+	socialClaimTimeout = 15 * time.Minute
+
+	// socialRetryBackoff is the delay before a failed post is retried, doubling
+	// on every attempt
+	socialRetryBackoff = time.Minute
+
+	pipelineRunInterval = time.Minute
+	maxDuePipelines     = 10
+
+	// pipelineClaimTimeout is how long a claimed content pipeline is held
+	// before another worker may run it. Each pass is bounded well below it.
+	pipelineClaimTimeout = 30 * time.Minute
+)
+
+// SocialService handles social media operations. Every instance runs the
+// publishing and content pipeline loops; posts and pipelines are claimed row
+// by row, so instances can work concurrently without doing anything twice.
+type SocialService struct {
+	cfg       *config.Config
+	repos     *repository.Repositories
+	encryptor *crypto.Encryptor
+	social    *social.Service
+	log       *logger.Logger
+
+	stop  chan struct{}
+	loops sync.WaitGroup
+}
+
+// NewSocialService creates a new social service and starts its publishing and
+// content pipeline loops. Content pipelines generate posts by running agents
+// through the tenant's provider keys.
+func NewSocialService(cfg *config.Config, repos *repository.Repositories, encryptor *crypto.Encryptor, apiKeys *APIKeyServiceImpl, manager *providers.Manager, log *logger.Logger) *SocialService {
+	engine := social.NewService(log)
+	batch := social.DefaultBatchConfig()
+	batch.Concurrency = cfg.SocialConcurrency
+	// Failed posts are rescheduled by publishFailed, which is the only retry
+	batch.MaxRetries = 0
+	engine.SetBatchConfig(batch)
+	engine.SetContentGenerator(&agentContentGenerator{
+		repos:    repos,
+		apiKeys:  apiKeys,
+		manager:  manager,
+		briefing: execution.NewBriefingEngine(log),
+		log:      log,
+	})
+
+	s := &SocialService{
+		cfg:       cfg,
+		repos:     repos,
+		encryptor: encryptor,
+		social:    engine,
+		log:       log,
+		stop:      make(chan struct{}),
+	}
+	s.loops.Add(2)
+	go s.publishLoop()
+	go s.pipelineLoop()
+	return s
+}
+
+// Stop stops the publishing and content pipeline loops, waiting for posts
+// being published and pipelines being run to finish
+func (s *SocialService) Stop() {
+	close(s.stop)
+	s.loops.Wait()
+}
+
+// SchedulePostRequest represents a post to publish at a later time
+type SchedulePostRequest struct {
+	AccountID   uuid.UUID `json:"account_id"`
+	Content     string    `json:"content"`
+	MediaURLs   []string  `json:"media_urls"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+}
+
+// SchedulePost saves a post to be published by the publishing loop once its
+// scheduled time has passed
+func (s *SocialService) SchedulePost(ctx context.Context, tenantID uuid.UUID, req *SchedulePostRequest) (*models.SocialPost, error) {
+	if strings.TrimSpace(req.Content) == "" {
+		return nil, fmt.Errorf("content is required")
+	}
+	if req.ScheduledAt.IsZero() {
+		return nil, fmt.Errorf("scheduled_at is required")
+	}
+	if req.ScheduledAt.Before(time.Now().Add(-time.Minute)) {
+		return nil, fmt.Errorf("scheduled_at must be in the future")
+	}
+
+	account, err := s.repos.Social.GetAccount(ctx, req.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account: %w", err)
+	}
+	if account == nil || account.TenantID != tenantID {
+		return nil, fmt.Errorf("account not found")
+	}
+
+	scheduledAt := req.ScheduledAt.UTC()
+	post := &models.SocialPost{
+		ID:          uuid.New(),
+		AccountID:   account.ID,
+		Content:     req.Content,
+		MediaURLs:   req.MediaURLs,
+		Status:      string(social.PostStatusScheduled),
+		ScheduledAt: &scheduledAt,
+		CreatedAt:   time.Now(),
+	}
+	if post.MediaURLs == nil {
+		post.MediaURLs = []string{}
+	}
+
+	if err := s.repos.Social.CreatePost(ctx, post); err != nil {
+		return nil, fmt.Errorf("failed to create post: %w", err)
+	}
+
+	s.log.Infow("post scheduled",
+		"post_id", post.ID,
+		"account_id", account.ID,
+		"platform", account.Platform,
+		"scheduled_at", scheduledAt,
+	)
+
+	return post, nil
+}
+
+// CreatePipelineRequest represents a content pipeline to run on a schedule
+type CreatePipelineRequest struct {
+	BusinessID  *uuid.UUID                    `json:"business_id,omitempty"`
+	Name        string                        `json:"name"`
+	Description string                        `json:"description"`
+	AgentID     uuid.UUID                     `json:"agent_id"`
+	Schedule    string                        `json:"schedule"` // Cron expression
+	Platforms   []social.Platform             `json:"platforms"`
+	Accounts    map[social.Platform]uuid.UUID `json:"accounts"`
+	Config      social.PipelineConfig         `json:"config"`
+}
+
+// CreatePipeline saves a content pipeline, to be run by the pipeline loop
+// whenever its cron schedule comes due
+func (s *SocialService) CreatePipeline(ctx context.Context, tenantID uuid.UUID, req *CreatePipelineRequest) (*models.ContentPipeline, error) {
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if len(req.Platforms) == 0 {
+		return nil, fmt.Errorf("at least one platform is required")
+	}
+	schedule, err := cron.ParseStandard(req.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule: %v", err)
+	}
+
+	agent, err := s.repos.Agents.GetByID(ctx, req.AgentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get agent: %w", err)
+	}
+	if agent == nil || agent.TenantID != tenantID {
+		return nil, fmt.Errorf("agent not found")
+	}
+
+	platforms := make([]string, len(req.Platforms))
+	for i, platform := range req.Platforms {
+		platforms[i] = string(platform)
+	}
+	accounts := make(map[string]uuid.UUID, len(req.Accounts))
+	for platform, accountID := range req.Accounts {
+		account, err := s.repos.Social.GetAccount(ctx, accountID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get account: %w", err)
+		}
+		if account == nil || account.TenantID != tenantID {
+			return nil, fmt.Errorf("account not found")
+		}
+		if account.Platform != string(platform) {
+			return nil, fmt.Errorf("account %s is not a %s account", accountID, platform)
+		}
+		accounts[string(platform)] = accountID
+	}
+
+	config, err := json.Marshal(req.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode pipeline config: %w", err)
+	}
+
+	now := time.Now()
+	next := schedule.Next(now)
+	pipeline := &models.ContentPipeline{
+		ID:          uuid.New(),
+		TenantID:    tenantID,
+		BusinessID:  req.BusinessID,
+		Name:        req.Name,
+		Description: req.Description,
+		AgentID:     agent.ID,
+		Schedule:    req.Schedule,
+		Platforms:   platforms,
+		Accounts:    accounts,
+		Config:      config,
+		IsActive:    true,
+		NextRunAt:   &next,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.repos.Social.CreatePipeline(ctx, pipeline); err != nil {
+		return nil, fmt.Errorf("failed to create pipeline: %w", err)
+	}
+
+	s.log.Infow("content pipeline created",
+		"pipeline_id", pipeline.ID,
+		"tenant_id", tenantID,
+		"agent_id", agent.ID,
+		"next_run_at", next,
+	)
+
+	return pipeline, nil
+}
+
+func (s *SocialService) pipelineLoop() {
+	defer s.loops.Done()
+
+	ticker := time.NewTicker(pipelineRunInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), pipelineClaimTimeout/2)
+			s.runDuePipelines(ctx)
+			cancel()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// runDuePipelines claims the content pipelines that are due and runs them
+func (s *SocialService) runDuePipelines(ctx context.Context) {
+	now := time.Now()
+	pipelines, err := s.repos.Social.ClaimDuePipelines(ctx, now, now.Add(pipelineClaimTimeout), maxDuePipelines)
+	if err != nil {
+		s.log.Warnw("failed to claim due content pipelines", "error", err)
+		return
+	}
+
+	for _, stored := range pipelines {
+		pipeline, err := contentPipeline(stored)
+		if err == nil {
+			_, err = s.RunPipeline(ctx, pipeline)
+		}
+		if err != nil {
+			s.log.Warnw("content pipeline run failed",
+				"pipeline_id", stored.ID,
+				"tenant_id", stored.TenantID,
+				"error", err,
+			)
+		}
+	}
+}
+
+// contentPipeline converts a stored pipeline for the social engine
+func contentPipeline(stored *models.ContentPipeline) (*social.ContentPipeline, error) {
+	pipeline := &social.ContentPipeline{
+		ID:          stored.ID,
+		TenantID:    stored.TenantID,
+		Name:        stored.Name,
+		Description: stored.Description,
+		AgentID:     stored.AgentID,
+		Schedule:    stored.Schedule,
+		Accounts:    make(map[social.Platform]uuid.UUID, len(stored.Accounts)),
+		IsActive:    stored.IsActive,
+		LastRunAt:   stored.LastRunAt,
+		NextRunAt:   stored.NextRunAt,
+		CreatedAt:   stored.CreatedAt,
+		UpdatedAt:   stored.UpdatedAt,
+	}
+	if stored.BusinessID != nil {
+		pipeline.BusinessID = *stored.BusinessID
+	}
+	for _, platform := range stored.Platforms {
+		pipeline.Platforms = append(pipeline.Platforms, social.Platform(platform))
+	}
+	for platform, accountID := range stored.Accounts {
+		pipeline.Accounts[social.Platform(platform)] = accountID
+	}
+	if len(stored.Config) > 0 {
+		if err := json.Unmarshal(stored.Config, &pipeline.Config); err != nil {
+			return nil, fmt.Errorf("failed to parse pipeline config: %w", err)
+		}
+	}
+	return pipeline, nil
+}
+
+// RunPipeline generates a round of posts for a content pipeline. Posts for
+// the tenant's accounts are saved, as drafts when the pipeline requires review
+// and scheduled for publication otherwise, in one transaction with the
+// pipeline's LastRunAt and NextRunAt. Posts without an account are returned
+// but not saved. Platforms that failed are reported in the returned error.
+func (s *SocialService) RunPipeline(ctx context.Context, pipeline *social.ContentPipeline) ([]*social.Post, error) {
+	previousRun := pipeline.LastRunAt
+	posts, genErr := s.social.GenerateContent(ctx, pipeline)
+	if pipeline.LastRunAt == previousRun {
+		// Generation never started, so there is no run to record
+		return nil, genErr
+	}
+
+	errs := []error{genErr}
+	owned := make(map[uuid.UUID]bool)
+	var saved []*models.SocialPost
+	for _, post := range posts {
+		if post.AccountID == uuid.Nil {
+			s.log.Warnw("generated post has no account, not saving", "pipeline_id", pipeline.ID, "platform", post.Platform)
+			continue
+		}
+		ok, checked := owned[post.AccountID]
+		if !checked {
+			account, err := s.repos.Social.GetAccount(ctx, post.AccountID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get account: %w", err)
+			}
+			ok = account != nil && account.TenantID == pipeline.TenantID
+			owned[post.AccountID] = ok
+		}
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s account not found", post.Platform))
+			continue
+		}
+
+		saved = append(saved, &models.SocialPost{
+			ID:          post.ID,
+			AccountID:   post.AccountID,
+			Content:     post.Content,
+			MediaURLs:   []string{},
+			Status:      string(post.Status),
+			ScheduledAt: post.ScheduledAt,
+			CreatedAt:   post.CreatedAt,
+		})
+	}
+
+	if err := s.repos.Social.SavePipelineRun(ctx, pipeline.ID, *pipeline.LastRunAt, pipeline.NextRunAt, saved); err != nil {
+		return nil, fmt.Errorf("failed to save pipeline run: %w", err)
+	}
+
+	s.log.Infow("content pipeline run saved",
+		"pipeline_id", pipeline.ID,
+		"tenant_id", pipeline.TenantID,
+		"posts", len(saved),
+		"next_run_at", pipeline.NextRunAt,
+	)
+
+	return posts, errors.Join(errs...)
+}
+
+// agentContentGenerator generates social content by briefing a tenant's agent
+// and running the prompt against its provider
+type agentContentGenerator struct {
+	repos    *repository.Repositories
+	apiKeys  *APIKeyServiceImpl
+	manager  *providers.Manager
+	briefing *execution.BriefingEngine
+	log      *logger.Logger
+}
+
+func (g *agentContentGenerator) Generate(ctx context.Context, tenantID, agentID uuid.UUID, prompt string) (string, error) {
+	agent, err := g.repos.Agents.GetByID(ctx, agentID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get agent: %w", err)
+	}
+	if agent == nil || agent.TenantID != tenantID {
+		return "", fmt.Errorf("agent not found")
+	}
+
+	briefing, err := g.briefing.Brief(ctx, agent, &execution.BriefingContext{})
+	if err != nil {
+		return "", fmt.Errorf("failed to brief agent: %w", err)
+	}
+
+	provider, err := g.apiKeys.GetProviderForTenant(ctx, tenantID, agent.Provider)
+	if err != nil {
+		return "", fmt.Errorf("failed to get provider: %w", err)
+	}
+
+	resp, err := g.manager.Complete(ctx, provider, g.briefing.BuildCompletionRequest(agent, briefing, prompt, provider))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate content: %w", err)
+	}
+
+	cost := g.manager.CalculateCost(agent.Model, resp.Usage)
+	costRecord := &models.CostRecord{
+		ID:           uuid.New(),
+		TenantID:     tenantID,
+		AgentID:      &agent.ID,
+		Provider:     agent.Provider,
+		Model:        agent.Model,
+		InputTokens:  resp.Usage.PromptTokens,
+		OutputTokens: resp.Usage.CompletionTokens,
+		Cost:         cost,
+		CreatedAt:    time.Now(),
+	}
+	if err := g.repos.Costs.RecordCost(ctx, costRecord); err != nil {
+		g.log.Warnw("failed to record cost", "tenant_id", tenantID, "agent_id", agent.ID, "error", err)
+	}
+
+	return resp.Message.Content, nil
+}
+
+func (s *SocialService) publishLoop() {
+	defer s.loops.Done()
+
+	ticker := time.NewTicker(socialPublishInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), socialClaimTimeout/2)
+			s.publishDue(ctx)
+			cancel()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// publishDue claims the posts that are due and publishes them
+func (s *SocialService) publishDue(ctx context.Context) {
+	stale, err := s.repos.Social.FailStalePosts(ctx, time.Now().Add(-socialClaimTimeout))
+	if err != nil {
+		s.log.Warnw("failed to release stale social posts", "error", err)
+	} else if stale > 0 {
+		s.log.Warnw("social posts abandoned mid-publish marked failed", "count", stale)
+	}
+
+	posts, err := s.repos.Social.ClaimDuePosts(ctx, time.Now(), maxDuePosts)
+	if err != nil {
+		s.log.Warnw("failed to claim due social posts", "error", err)
+		return
+	}
+	if len(posts) == 0 {
+		return
+	}
+
+	// Load each account once and refresh its token up front, so posts sharing
+	// an account don't refresh it concurrently during the batch
+	accounts := make(map[uuid.UUID]*social.Account)
+	accountErrs := make(map[uuid.UUID]error)
+
+	var claimed []*models.SocialPost
+	var items []social.PublishItem
+	for _, post := range posts {
+		account, ok := accounts[post.AccountID]
+		if !ok && accountErrs[post.AccountID] == nil {
+			account, err = s.publishingAccount(ctx, post.AccountID)
+			if err != nil {
+				accountErrs[post.AccountID] = err
+			} else {
+				accounts[post.AccountID] = account
+			}
+		}
+		if err := accountErrs[post.AccountID]; err != nil {
+			s.publishFailed(ctx, post, err)
+			continue
+		}
+
+		claimed = append(claimed, post)
+		items = append(items, social.PublishItem{
+			Account: account,
+			Post: &social.Post{
+				ID:          post.ID,
+				TenantID:    account.TenantID,
+				AccountID:   account.ID,
+				Platform:    account.Platform,
+				Content:     post.Content,
+				MediaURLs:   post.MediaURLs,
+				Status:      social.PostStatusPublishing,
+				ScheduledAt: post.ScheduledAt,
+				CreatedAt:   post.CreatedAt,
+			},
+		})
+	}
+
+	results, _ := s.social.PublishBatch(ctx, items)
+	for i, result := range results {
+		post := claimed[i]
+		if result.Error != "" {
+			s.publishFailed(ctx, post, result.Err)
+			continue
+		}
+
+		publishedAt := time.Now()
+		if p := items[i].Post.PublishedAt; p != nil {
+			publishedAt = *p
+		}
+		if err := s.repos.Social.MarkPublished(ctx, post.ID, result.ExternalID, publishedAt); err != nil {
+			s.log.Errorw("failed to record published post",
+				"post_id", post.ID,
+				"external_id", result.ExternalID,
+				"error", err,
+			)
+		}
+	}
+}
+
+// publishFailed schedules a claimed post for another attempt, or marks it
+// failed once it has used up its retries
+func (s *SocialService) publishFailed(ctx context.Context, post *models.SocialPost, cause error) {
+	if post.Attempts > s.cfg.SocialMaxRetries {
+		s.log.Warnw("social post failed",
+			"post_id", post.ID,
+			"attempts", post.Attempts,
+			"error", cause,
+		)
+		if err := s.repos.Social.FailPost(ctx, post.ID, cause.Error()); err != nil {
+			s.log.Errorw("failed to mark social post failed", "post_id", post.ID, "error", err)
+		}
+		return
+	}
+
+	retryAt := time.Now().Add(socialRetryDelay(post.Attempts, cause))
+	s.log.Warnw("social post publish failed, will retry",
+		"post_id", post.ID,
+		"attempts", post.Attempts,
+		"retry_at", retryAt,
+		"error", cause,
+	)
+	if err := s.repos.Social.RetryPost(ctx, post.ID, cause.Error(), retryAt); err != nil {
+		s.log.Errorw("failed to reschedule social post", "post_id", post.ID, "error", err)
+	}
+}
+
+// socialRetryDelay is how long to wait before another attempt at a post: a
+// backoff doubling with every attempt, or longer if the platform asked for it
+func socialRetryDelay(attempts int, cause error) time.Duration {
+	delay := socialRetryBackoff << (attempts - 1)
+	var rateErr *social.RateLimitError
+	if errors.As(cause, &rateErr) && rateErr.RetryAfter > delay {
+		delay = rateErr.RetryAfter
+	}
+	return delay
+}
+
+// socialCredentials is the decrypted form of SocialAccount.Credentials
+type socialCredentials struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// publishingAccount loads an account with its tokens, refreshing them through
+// the platform provider if they are about to expire. The account stays locked
+// while it is refreshed, so instances don't both refresh it and strand each
+// other with a rotated refresh token.
+func (s *SocialService) publishingAccount(ctx context.Context, id uuid.UUID) (*social.Account, error) {
+	var account *social.Account
+	var refreshed bool
+	stored, err := s.repos.Social.RefreshAccount(ctx, id, func(stored *models.SocialAccount) (bool, error) {
+		var err error
+		if account, err = s.socialAccount(stored); err != nil {
+			return false, err
+		}
+		if refreshed, err = s.social.RefreshIfExpiring(ctx, account); err != nil || !refreshed {
+			return false, err
+		}
+		if stored.Credentials, err = s.encryptCredentials(account); err != nil {
+			return false, err
+		}
+		stored.TokenExpiresAt = nil
+		if !account.TokenExpiry.IsZero() {
+			expiresAt := account.TokenExpiry
+			stored.TokenExpiresAt = &expiresAt
+		}
+		return true, nil
+	})
+	switch {
+	case err != nil && refreshed:
+		// The platform may have rotated the refresh token too, so losing this
+		// write strands the account; the post can still go out now
+		s.log.Errorw("failed to save refreshed social token", "account_id", id, "error", err)
+	case err != nil:
+		return nil, fmt.Errorf("failed to get account: %w", err)
+	case stored == nil:
+		return nil, fmt.Errorf("account not found")
+	}
+	return account, nil
+}
+
+// socialAccount decrypts a stored account's tokens
+func (s *SocialService) socialAccount(stored *models.SocialAccount) (*social.Account, error) {
+	var creds socialCredentials
+	if stored.Credentials != "" {
+		plain, err := s.encryptor.Decrypt(stored.Credentials)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt account credentials: %w", err)
+		}
+		if err := json.Unmarshal([]byte(plain), &creds); err != nil {
+			return nil, fmt.Errorf("failed to parse account credentials: %w", err)
+		}
+	}
+
+	account := &social.Account{
+		ID:           stored.ID,
+		TenantID:     stored.TenantID,
+		Platform:     social.Platform(stored.Platform),
+		Username:     stored.AccountID,
+		DisplayName:  stored.AccountName,
+		AccessToken:  creds.AccessToken,
+		RefreshToken: creds.RefreshToken,
+		IsActive:     true,
+		CreatedAt:    stored.CreatedAt,
+	}
+	if stored.TokenExpiresAt != nil {
+		account.TokenExpiry = *stored.TokenExpiresAt
+	}
+	return account, nil
+}
+
+// encryptCredentials returns an account's current tokens encrypted for storage
+func (s *SocialService) encryptCredentials(account *social.Account) (string, error) {
+	data, err := json.Marshal(socialCredentials{
+		AccessToken:  account.AccessToken,
+		RefreshToken: account.RefreshToken,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	credentials, err := s.encryptor.Encrypt(string(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt account credentials: %w", err)
+	}
+	return credentials, nil
+}
@@ -1,9 +1,7 @@
 package services
 
 import (
-	"github.com/delphi-platform/delphi/backend/internal/config"
 	"github.com/delphi-platform/delphi/backend/internal/repository"
-	"github.com/delphi-platform/delphi/backend/pkg/crypto"
 	"github.com/delphi-platform/delphi/backend/pkg/logger"
 )
 
@@ -11,142 +9,6 @@ import (
 // Service Stubs - To be fully implemented in later phases
 // =============================================================================
 
-// TenantService handles tenant operations
-type TenantService struct {
-	repos *repository.Repositories
-	log   *logger.Logger
-}
-
-func NewTenantService(repos *repository.Repositories, log *logger.Logger) *TenantService {
-	return &TenantService{repos: repos, log: log}
-}
-
-// UserService handles user operations
-type UserService struct {
-	repos *repository.Repositories
-	log   *logger.Logger
-}
-
-func NewUserService(repos *repository.Repositories, log *logger.Logger) *UserService {
-	return &UserService{repos: repos, log: log}
-}
-
-// APIKeyService handles API key operations
-type APIKeyService struct {
-	repos     *repository.Repositories
-	encryptor *crypto.Encryptor
-	log       *logger.Logger
-}
-
-func NewAPIKeyService(repos *repository.Repositories, encryptor *crypto.Encryptor, log *logger.Logger) *APIKeyService {
-	return &APIKeyService{repos: repos, encryptor: encryptor, log: log}
-}
-
-// KnowledgeService handles knowledge base operations
-type KnowledgeService struct {
-	repos *repository.Repositories
-	log   *logger.Logger
-}
-
-func NewKnowledgeService(repos *repository.Repositories, log *logger.Logger) *KnowledgeService {
-	return &KnowledgeService{repos: repos, log: log}
-}
-
-// RepositoryService handles GitHub repository operations
-type RepositoryService struct {
-	cfg   *config.Config
-	repos *repository.Repositories
-	log   *logger.Logger
-}
-
-func NewRepositoryService(cfg *config.Config, repos *repository.Repositories, log *logger.Logger) *RepositoryService {
-	return &RepositoryService{cfg: cfg, repos: repos, log: log}
-}
-
-// BusinessService handles business operations
-type BusinessService struct {
-	repos *repository.Repositories
-	log   *logger.Logger
-}
-
-func NewBusinessService(repos *repository.Repositories, log *logger.Logger) *BusinessService {
-	return &BusinessService{repos: repos, log: log}
-}
-
-// ProjectService handles project operations
-type ProjectService struct {
-	repos *repository.Repositories
-	log   *logger.Logger
-}
-
-func NewProjectService(repos *repository.Repositories, log *logger.Logger) *ProjectService {
-	return &ProjectService{repos: repos, log: log}
-}
-
-// FinancialService handles financial operations
-type FinancialService struct {
-	repos *repository.Repositories
-	log   *logger.Logger
-}
-
-func NewFinancialService(repos *repository.Repositories, log *logger.Logger) *FinancialService {
-	return &FinancialService{repos: repos, log: log}
-}
-
-// SocialService handles social media operations
-type SocialService struct {
-	cfg   *config.Config
-	repos *repository.Repositories
-	log   *logger.Logger
-}
-
-func NewSocialService(cfg *config.Config, repos *repository.Repositories, log *logger.Logger) *SocialService {
-	return &SocialService{cfg: cfg, repos: repos, log: log}
-}
-
-// IoTService handles IoT operations
-type IoTService struct {
-	repos     *repository.Repositories
-	encryptor *crypto.Encryptor
-	log       *logger.Logger
-}
-
-func NewIoTService(repos *repository.Repositories, encryptor *crypto.Encryptor, log *logger.Logger) *IoTService {
-	return &IoTService{repos: repos, encryptor: encryptor, log: log}
-}
-
-// CostService handles cost tracking operations
-type CostService struct {
-	repos *repository.Repositories
-	redis *repository.RedisClient
-	log   *logger.Logger
-}
-
-func NewCostService(repos *repository.Repositories, redis *repository.RedisClient, log *logger.Logger) *CostService {
-	return &CostService{repos: repos, redis: redis, log: log}
-}
-
-// DashboardService handles dashboard data
-type DashboardService struct {
-	repos *repository.Repositories
-	redis *repository.RedisClient
-	log   *logger.Logger
-}
-
-func NewDashboardService(repos *repository.Repositories, redis *repository.RedisClient, log *logger.Logger) *DashboardService {
-	return &DashboardService{repos: repos, redis: redis, log: log}
-}
-
-// AuditService handles audit log operations
-type AuditService struct {
-	repos *repository.Repositories
-	log   *logger.Logger
-}
-
-func NewAuditService(repos *repository.Repositories, log *logger.Logger) *AuditService {
-	return &AuditService{repos: repos, log: log}
-}
-
 // SettingsService handles settings operations
 type SettingsService struct {
 	repos *repository.Repositories
@@ -156,25 +18,3 @@ type SettingsService struct {
 func NewSettingsService(repos *repository.Repositories, log *logger.Logger) *SettingsService {
 	return &SettingsService{repos: repos, log: log}
 }
-
-// WebhookService handles webhook operations
-type WebhookService struct {
-	cfg   *config.Config
-	repos *repository.Repositories
-	log   *logger.Logger
-}
-
-func NewWebhookService(cfg *config.Config, repos *repository.Repositories, log *logger.Logger) *WebhookService {
-	return &WebhookService{cfg: cfg, repos: repos, log: log}
-}
-
-// WebSocketService handles WebSocket connections
-type WebSocketService struct {
-	redis *repository.RedisClient
-	log   *logger.Logger
-}
-
-func NewWebSocketService(redis *repository.RedisClient, log *logger.Logger) *WebSocketService {
-	return &WebSocketService{redis: redis, log: log}
-}
-
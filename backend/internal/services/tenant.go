@@ -0,0 +1,374 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/billing"
+	"github.com/delphi-platform/delphi/backend/internal/config"
+	"github.com/delphi-platform/delphi/backend/internal/knowledge"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/pkg/auth"
+	"github.com/delphi-platform/delphi/backend/pkg/crypto"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+)
+
+const (
+	minSlugLength = 3
+	maxSlugLength = 63
+
+	// slugSuggestionAttempts is how many numbered variants of a taken slug are
+	// tried before falling back to a random suffix
+	slugSuggestionAttempts = 5
+)
+
+var (
+	slugPattern      = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	slugInvalidChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+	// reservedSlugs would collide with routes or be mistaken for the platform
+	reservedSlugs = map[string]bool{
+		"admin": true, "api": true, "app": true, "auth": true, "billing": true,
+		"delphi": true, "help": true, "settings": true, "status": true,
+		"support": true, "www": true,
+	}
+)
+
+// SlugTakenError is returned when a requested tenant slug is in use. Suggestion
+// is a similar slug that was free when checked.
+type SlugTakenError struct {
+	Slug       string
+	Suggestion string
+}
+
+func (e *SlugTakenError) Error() string {
+	return fmt.Sprintf("tenant slug %q already taken", e.Slug)
+}
+
+// TenantService handles tenant operations
+type TenantService struct {
+	repos     *repository.Repositories
+	billing   *billing.Service // nil when Stripe is not configured
+	knowledge *knowledge.Service
+	audit     *AuditService
+	log       *logger.Logger
+
+	// Deleted tenants are kept for deletionGrace; deletion is confirmed with
+	// tokens signed with deletionSecret
+	deletionGrace  time.Duration
+	deletionSecret []byte
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewTenantService creates a tenant service and starts the loop that deletes
+// tenants once their deletion grace period is over. New tenants get a Stripe
+// customer, and deleted ones have their subscriptions cancelled, when billing
+// is non-nil. Deleted tenants' knowledge bases are removed through knowledge.
+func NewTenantService(cfg *config.Config, repos *repository.Repositories, billing *billing.Service, knowledge *knowledge.Service, audit *AuditService, log *logger.Logger) *TenantService {
+	s := &TenantService{
+		repos:          repos,
+		billing:        billing,
+		knowledge:      knowledge,
+		audit:          audit,
+		log:            log,
+		deletionGrace:  time.Duration(cfg.TenantDeletionGraceDays) * 24 * time.Hour,
+		deletionSecret: []byte(cfg.JWTSecret),
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+	go s.deletionLoop()
+	return s
+}
+
+// CreateTenantRequest describes a new tenant and the owner account created
+// with it
+type CreateTenantRequest struct {
+	Name          string `json:"name"`
+	Slug          string `json:"slug"` // derived from the name when empty
+	OwnerEmail    string `json:"owner_email"`
+	OwnerName     string `json:"owner_name"`
+	OwnerPassword string `json:"owner_password"`
+}
+
+// UpdateTenantRequest changes a tenant's name or slug. Empty fields are left
+// unchanged. The plan is changed through billing.
+type UpdateTenantRequest struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// Create creates a tenant on the free plan together with its owner. A slug
+// derived from the name is made unique; a requested slug that is taken is
+// rejected with a *SlugTakenError.
+func (s *TenantService) Create(ctx context.Context, req *CreateTenantRequest) (*models.Tenant, *models.User, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, nil, fmt.Errorf("name is required")
+	}
+	email := normalizeEmail(req.OwnerEmail)
+	if !strings.Contains(email, "@") {
+		return nil, nil, fmt.Errorf("invalid email")
+	}
+	if strings.TrimSpace(req.OwnerName) == "" {
+		return nil, nil, fmt.Errorf("owner name is required")
+	}
+	if err := auth.ValidatePasswordStrength(req.OwnerPassword); err != nil {
+		return nil, nil, err
+	}
+
+	existing, err := s.repos.Users.GetByEmail(ctx, email)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to check existing user: %w", err)
+	}
+	if existing != nil {
+		return nil, nil, fmt.Errorf("email already registered")
+	}
+
+	slug := strings.ToLower(strings.TrimSpace(req.Slug))
+	if slug != "" {
+		if err := ValidateSlug(slug); err != nil {
+			return nil, nil, err
+		}
+		if err := s.checkSlugAvailable(ctx, slug, uuid.Nil); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		slug, err = s.uniqueSlug(ctx, Slugify(name))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	passwordHash, err := crypto.HashPassword(req.OwnerPassword)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	now := time.Now()
+	tenant := &models.Tenant{
+		ID:        uuid.New(),
+		Name:      name,
+		Slug:      slug,
+		Plan:      models.PlanFree,
+		Settings:  []byte("{}"),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	owner := &models.User{
+		ID:           uuid.New(),
+		TenantID:     tenant.ID,
+		Email:        email,
+		Name:         strings.TrimSpace(req.OwnerName),
+		Role:         models.RoleOwner,
+		PasswordHash: passwordHash,
+		Preferences:  []byte("{}"),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	if err := s.repos.Tenants.CreateWithOwner(ctx, tenant, owner); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrSlugTaken):
+			// Taken between the check and the insert
+			return nil, nil, s.slugTaken(ctx, slug)
+		case errors.Is(err, repository.ErrEmailTaken):
+			return nil, nil, fmt.Errorf("email already registered")
+		}
+		return nil, nil, fmt.Errorf("failed to create tenant: %w", err)
+	}
+
+	s.log.Infow("tenant created", "tenant_id", tenant.ID, "slug", tenant.Slug, "owner_id", owner.ID)
+
+	if s.billing != nil {
+		s.createCustomer(ctx, tenant, email)
+	}
+
+	return tenant, owner, nil
+}
+
+// createCustomer creates the tenant's Stripe customer and records its ID in
+// the tenant settings. Failures are logged: the tenant works without one until
+// it upgrades.
+func (s *TenantService) createCustomer(ctx context.Context, tenant *models.Tenant, email string) {
+	customerID, err := s.billing.CreateCustomer(ctx, tenant, email)
+	if err != nil {
+		s.log.Warnw("failed to create billing customer", "tenant_id", tenant.ID, "error", err)
+		return
+	}
+
+	settings := map[string]interface{}{"stripe_customer_id": customerID}
+	data, err := json.Marshal(settings)
+	if err != nil {
+		s.log.Warnw("failed to marshal tenant settings", "tenant_id", tenant.ID, "error", err)
+		return
+	}
+	tenant.Settings = data
+	if err := s.repos.Tenants.Update(ctx, tenant); err != nil {
+		s.log.Warnw("failed to save billing customer", "tenant_id", tenant.ID, "customer_id", customerID, "error", err)
+	}
+}
+
+// Get returns a tenant the user belongs to
+func (s *TenantService) Get(ctx context.Context, userTenantID, tenantID uuid.UUID) (*models.Tenant, error) {
+	// Other tenants are reported missing rather than forbidden, so their IDs
+	// cannot be probed
+	if tenantID != userTenantID {
+		return nil, fmt.Errorf("tenant not found")
+	}
+
+	tenant, err := s.repos.Tenants.GetByID(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+	if tenant == nil {
+		return nil, fmt.Errorf("tenant not found")
+	}
+	return tenant, nil
+}
+
+// List returns the tenants the user belongs to. Each user belongs to one.
+func (s *TenantService) List(ctx context.Context, userTenantID uuid.UUID) ([]*models.Tenant, error) {
+	tenant, err := s.repos.Tenants.GetByID(ctx, userTenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+	if tenant == nil {
+		return []*models.Tenant{}, nil
+	}
+	return []*models.Tenant{tenant}, nil
+}
+
+// Update renames a tenant or changes its slug. Callers check that the user
+// may manage the tenant.
+func (s *TenantService) Update(ctx context.Context, userTenantID, tenantID uuid.UUID, req *UpdateTenantRequest) (*models.Tenant, error) {
+	tenant, err := s.Get(ctx, userTenantID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if name := strings.TrimSpace(req.Name); name != "" {
+		tenant.Name = name
+	}
+	if slug := strings.ToLower(strings.TrimSpace(req.Slug)); slug != "" && slug != tenant.Slug {
+		if err := ValidateSlug(slug); err != nil {
+			return nil, err
+		}
+		if err := s.checkSlugAvailable(ctx, slug, tenant.ID); err != nil {
+			return nil, err
+		}
+		tenant.Slug = slug
+	}
+
+	if err := s.repos.Tenants.Update(ctx, tenant); err != nil {
+		if errors.Is(err, repository.ErrSlugTaken) {
+			return nil, s.slugTaken(ctx, tenant.Slug)
+		}
+		return nil, fmt.Errorf("failed to update tenant: %w", err)
+	}
+
+	s.log.Infow("tenant updated", "tenant_id", tenant.ID, "slug", tenant.Slug)
+	return tenant, nil
+}
+
+// checkSlugAvailable returns a *SlugTakenError if a tenant other than self
+// has the slug
+func (s *TenantService) checkSlugAvailable(ctx context.Context, slug string, self uuid.UUID) error {
+	existing, err := s.repos.Tenants.GetBySlug(ctx, slug)
+	if err != nil {
+		return fmt.Errorf("failed to check existing tenant: %w", err)
+	}
+	if existing != nil && existing.ID != self {
+		return s.slugTaken(ctx, slug)
+	}
+	return nil
+}
+
+// slugTaken builds the error for a taken slug, with a free alternative when
+// one can be found
+func (s *TenantService) slugTaken(ctx context.Context, slug string) error {
+	taken := &SlugTakenError{Slug: slug}
+	suggestion, err := s.uniqueSlug(ctx, slug)
+	if err != nil {
+		s.log.Warnw("failed to suggest tenant slug", "slug", slug, "error", err)
+	} else {
+		taken.Suggestion = suggestion
+	}
+	return taken
+}
+
+// uniqueSlug returns base if it is free, or else the first free of base-2,
+// base-3 and so on, falling back to base with a random suffix
+func (s *TenantService) uniqueSlug(ctx context.Context, base string) (string, error) {
+	candidates := []string{base}
+	if reservedSlugs[base] {
+		candidates = nil
+	}
+	for i := 2; i < 2+slugSuggestionAttempts; i++ {
+		candidates = append(candidates, withSlugSuffix(base, fmt.Sprint(i)))
+	}
+
+	for _, candidate := range candidates {
+		existing, err := s.repos.Tenants.GetBySlug(ctx, candidate)
+		if err != nil {
+			return "", fmt.Errorf("failed to check existing tenant: %w", err)
+		}
+		if existing == nil {
+			return candidate, nil
+		}
+	}
+
+	suffix, err := crypto.GenerateRandomBytes(3)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate tenant slug: %w", err)
+	}
+	return withSlugSuffix(base, fmt.Sprintf("%x", suffix)), nil
+}
+
+// withSlugSuffix appends -suffix to slug, shortening slug to keep the result
+// within the length limit
+func withSlugSuffix(slug, suffix string) string {
+	if max := maxSlugLength - len(suffix) - 1; len(slug) > max {
+		slug = strings.TrimRight(slug[:max], "-")
+	}
+	return slug + "-" + suffix
+}
+
+// ValidateSlug checks that a tenant slug is 3 to 63 lowercase letters, digits
+// and single dashes, neither starting nor ending with a dash, and not reserved
+func ValidateSlug(slug string) error {
+	if len(slug) < minSlugLength || len(slug) > maxSlugLength {
+		return fmt.Errorf("slug must be %d to %d characters", minSlugLength, maxSlugLength)
+	}
+	if !slugPattern.MatchString(slug) {
+		return fmt.Errorf("slug may contain only lowercase letters, digits and single dashes between them")
+	}
+	if reservedSlugs[slug] {
+		return fmt.Errorf("slug %q is reserved", slug)
+	}
+	return nil
+}
+
+// Slugify derives a valid slug from a name. Names with too few usable
+// characters are padded to the minimum length.
+func Slugify(name string) string {
+	slug := strings.Trim(slugInvalidChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(slug) > maxSlugLength {
+		slug = strings.TrimRight(slug[:maxSlugLength], "-")
+	}
+	switch {
+	case slug == "":
+		slug = "team"
+	case len(slug) < minSlugLength:
+		slug += "-team"
+	}
+	return slug
+}
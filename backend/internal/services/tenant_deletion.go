@@ -0,0 +1,273 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/security"
+	"github.com/google/uuid"
+)
+
+const (
+	// deletionTokenTTL is how long an owner has to confirm a tenant's deletion
+	deletionTokenTTL = 15 * time.Minute
+
+	deletionCheckInterval = time.Hour
+	maxDueDeletions       = 20
+)
+
+var (
+	// ErrInvalidDeletionToken is returned when a tenant deletion is confirmed
+	// with a token that wasn't issued to the user for the tenant, or expired
+	ErrInvalidDeletionToken = errors.New("invalid or expired confirmation token")
+
+	// ErrDeletionScheduled is returned when confirming the deletion of a
+	// tenant already scheduled for deletion
+	ErrDeletionScheduled = errors.New("tenant is already scheduled for deletion")
+)
+
+// DeletionConfirmation is issued when an owner asks to delete their tenant.
+// The deletion only goes ahead once the token is sent back.
+type DeletionConfirmation struct {
+	Token       string    `json:"confirmation_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	GracePeriod string    `json:"grace_period"`
+}
+
+// RequestDeletion issues the token an owner confirms their tenant's deletion
+// with. Callers check that the user owns the tenant.
+func (s *TenantService) RequestDeletion(ctx context.Context, userTenantID, userID, tenantID uuid.UUID) (*DeletionConfirmation, error) {
+	if tenantID != userTenantID {
+		return nil, fmt.Errorf("tenant not found")
+	}
+
+	expires := time.Now().Add(deletionTokenTTL).Truncate(time.Second)
+	return &DeletionConfirmation{
+		Token:       s.deletionToken(tenantID, userID, expires),
+		ExpiresAt:   expires,
+		GracePeriod: s.deletionGrace.String(),
+	}, nil
+}
+
+// ConfirmDeletion schedules the tenant's deletion, which happens once the
+// grace period is over. Until then the tenant keeps working and its deletion
+// can be cancelled. Callers check that the user owns the tenant.
+func (s *TenantService) ConfirmDeletion(ctx context.Context, userTenantID, userID, tenantID uuid.UUID, token string) (*models.Tenant, error) {
+	if tenantID != userTenantID {
+		return nil, fmt.Errorf("tenant not found")
+	}
+	if !s.validDeletionToken(tenantID, userID, token, time.Now()) {
+		return nil, ErrInvalidDeletionToken
+	}
+
+	tenant, err := s.Get(ctx, userTenantID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if tenant.DeleteAfter != nil {
+		return nil, ErrDeletionScheduled
+	}
+
+	now := time.Now()
+	deleteAfter := now.Add(s.deletionGrace)
+	ok, err := s.repos.Tenants.ScheduleDeletion(ctx, tenantID, now, deleteAfter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to schedule tenant deletion: %w", err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("tenant not found")
+	}
+	tenant.DeletionRequestedAt = &now
+	tenant.DeleteAfter = &deleteAfter
+
+	s.log.Warnw("tenant deletion scheduled", "tenant_id", tenantID, "user_id", userID, "delete_after", deleteAfter)
+	s.audit.Log(ctx, &security.AuditEntry{
+		TenantID:     tenantID,
+		UserID:       &userID,
+		Action:       security.AuditActionDataDeleted,
+		Severity:     security.SeverityCritical,
+		ResourceType: "tenant",
+		ResourceID:   &tenantID,
+		Details: map[string]interface{}{
+			"stage":        "scheduled",
+			"delete_after": deleteAfter,
+		},
+	})
+	return tenant, nil
+}
+
+// CancelDeletion restores a tenant scheduled for deletion. It can't be
+// restored once the grace period is over. Callers check that the user owns
+// the tenant.
+func (s *TenantService) CancelDeletion(ctx context.Context, userTenantID, userID, tenantID uuid.UUID) (*models.Tenant, error) {
+	tenant, err := s.Get(ctx, userTenantID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := s.repos.Tenants.CancelDeletion(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel tenant deletion: %w", err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("tenant is not scheduled for deletion")
+	}
+	tenant.DeletionRequestedAt = nil
+	tenant.DeleteAfter = nil
+
+	s.log.Infow("tenant deletion cancelled", "tenant_id", tenantID, "user_id", userID)
+	s.audit.Log(ctx, &security.AuditEntry{
+		TenantID:     tenantID,
+		UserID:       &userID,
+		Action:       security.AuditActionSettingsChanged,
+		Severity:     security.SeverityWarning,
+		ResourceType: "tenant",
+		ResourceID:   &tenantID,
+		Details: map[string]interface{}{
+			"setting": "deletion",
+			"stage":   "cancelled",
+		},
+	})
+	return tenant, nil
+}
+
+// deletionToken signs the tenant, the user and the expiry, so the token
+// confirms only this user's deletion of this tenant
+func (s *TenantService) deletionToken(tenantID, userID uuid.UUID, expires time.Time) string {
+	mac := hmac.New(sha256.New, s.deletionSecret)
+	fmt.Fprintf(mac, "tenant-deletion:%s:%s:%d", tenantID, userID, expires.Unix())
+	return strconv.FormatInt(expires.Unix(), 10) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// validDeletionToken reports whether token was issued to the user for the
+// tenant and hasn't expired
+func (s *TenantService) validDeletionToken(tenantID, userID uuid.UUID, token string, now time.Time) bool {
+	expiry, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return false
+	}
+	expires := time.Unix(unix, 0)
+	if !now.Before(expires) {
+		return false
+	}
+	return hmac.Equal([]byte(token), []byte(s.deletionToken(tenantID, userID, expires)))
+}
+
+// Stop stops the deletion loop, waiting for tenants being deleted
+func (s *TenantService) Stop() {
+	close(s.stop)
+	<-s.done
+}
+
+func (s *TenantService) deletionLoop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(deletionCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.deleteDue(context.Background())
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// deleteDue deletes the tenants whose grace period is over, unless another
+// instance is already doing so. A tenant that fails to delete is retried on
+// the next check.
+func (s *TenantService) deleteDue(ctx context.Context) {
+	acquired, err := s.repos.Tenants.WithDeletionLock(ctx, func(ctx context.Context) error {
+		due, err := s.repos.Tenants.ListDueDeletions(ctx, time.Now(), maxDueDeletions)
+		if err != nil {
+			return fmt.Errorf("failed to list tenants due for deletion: %w", err)
+		}
+		for _, tenant := range due {
+			if err := s.purge(ctx, tenant); err != nil {
+				s.log.Errorw("failed to delete tenant", "tenant_id", tenant.ID, "error", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		s.log.Errorw("tenant deletion failed", "error", err)
+	} else if !acquired {
+		s.log.Debugw("tenant deletion running on another instance")
+	}
+}
+
+// purge irreversibly deletes a tenant. It is first marked as being purged,
+// which rechecks that its deletion is due and stops it being cancelled. Its
+// Stripe subscriptions are then cancelled and its knowledge bases removed
+// from the vector store, so a failure there leaves the tenant in place to be
+// retried. Its rows are then deleted in one transaction. The tenant's audit
+// log goes with it, so the deletion is recorded in the server log.
+func (s *TenantService) purge(ctx context.Context, tenant *models.Tenant) error {
+	due, err := s.repos.Tenants.StartPurge(ctx, tenant.ID)
+	if err != nil {
+		return fmt.Errorf("failed to start tenant purge: %w", err)
+	}
+	if !due {
+		s.log.Infow("tenant no longer due for deletion", "tenant_id", tenant.ID)
+		return nil
+	}
+
+	if s.billing != nil {
+		var settings struct {
+			StripeCustomerID string `json:"stripe_customer_id"`
+		}
+		if len(tenant.Settings) > 0 {
+			json.Unmarshal(tenant.Settings, &settings)
+		}
+		if settings.StripeCustomerID != "" {
+			if err := s.billing.CancelCustomerSubscriptions(ctx, settings.StripeCustomerID); err != nil {
+				return err
+			}
+		}
+	}
+
+	if s.knowledge != nil {
+		bases, err := s.repos.Knowledge.ListBasesByTenant(ctx, tenant.ID)
+		if err != nil {
+			return fmt.Errorf("failed to list knowledge bases: %w", err)
+		}
+		for _, kb := range bases {
+			if err := s.knowledge.DeleteKnowledgeBase(ctx, kb.ID); err != nil {
+				return fmt.Errorf("failed to delete knowledge base %s: %w", kb.ID, err)
+			}
+		}
+	}
+
+	counts, deleted, err := s.repos.Tenants.Delete(ctx, tenant.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete tenant data: %w", err)
+	}
+	if !deleted {
+		s.log.Infow("tenant already deleted", "tenant_id", tenant.ID)
+		return nil
+	}
+
+	s.log.Warnw("tenant deleted",
+		"tenant_id", tenant.ID,
+		"slug", tenant.Slug,
+		"action", security.AuditActionDataDeleted,
+		"deletion_requested_at", tenant.DeletionRequestedAt,
+		"deleted_rows", counts,
+	)
+	return nil
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/internal/security"
+	"github.com/delphi-platform/delphi/backend/pkg/auth"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// UserService handles user operations
+type UserService struct {
+	repos *repository.Repositories
+	audit *AuditService
+	log   *logger.Logger
+}
+
+func NewUserService(repos *repository.Repositories, audit *AuditService, log *logger.Logger) *UserService {
+	return &UserService{repos: repos, audit: audit, log: log}
+}
+
+// ChangePasswordRequest is a user's request to change their own password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// ChangePassword replaces a user's password after checking the current one.
+// The new password must meet the password policy and differ from the current
+// one. Existing tokens stay valid until they expire.
+func (s *UserService) ChangePassword(ctx context.Context, userID uuid.UUID, req *ChangePasswordRequest) error {
+	user, err := s.repos.Users.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return fmt.Errorf("user not found")
+	}
+
+	hash, err := auth.ChangePasswordHash(user.PasswordHash, req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repos.Users.UpdatePassword(ctx, userID, hash); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	s.log.Infow("password changed", "user_id", userID, "tenant_id", user.TenantID)
+	s.audit.Log(ctx, &security.AuditEntry{
+		TenantID:     user.TenantID,
+		UserID:       &userID,
+		Action:       security.AuditActionPasswordChange,
+		Severity:     security.SeverityCritical,
+		ResourceType: "user",
+		ResourceID:   &userID,
+	})
+	return nil
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/delphi-platform/delphi/backend/internal/billing"
+	"github.com/delphi-platform/delphi/backend/internal/config"
+	"github.com/delphi-platform/delphi/backend/internal/github"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/stripe/stripe-go/v76/webhook"
+)
+
+// ErrInvalidWebhookSignature is returned for webhook deliveries whose signature
+// does not match their payload
+var ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+
+// WebhookService handles inbound webhooks from third-party services
+type WebhookService struct {
+	cfg     *config.Config
+	repos   *repository.Repositories
+	github  *github.WebhookHandler
+	billing *billing.Service
+	log     *logger.Logger
+}
+
+// NewWebhookService creates a new webhook service. GitHub pushes are passed
+// to the repository service to reindex the pushed files.
+func NewWebhookService(cfg *config.Config, repos *repository.Repositories, billingService *billing.Service, repositories *RepositoryService, log *logger.Logger) *WebhookService {
+	handler := github.NewWebhookHandler(cfg.GitHubWebhookSecret, log)
+	handler.OnPush(repositories.HandlePush)
+
+	return &WebhookService{
+		cfg:     cfg,
+		repos:   repos,
+		github:  handler,
+		billing: billingService,
+		log:     log,
+	}
+}
+
+// HandleGitHub verifies a GitHub delivery against the webhook secret and
+// processes it. It returns ErrInvalidWebhookSignature for forged or unsigned
+// deliveries, before the payload is parsed.
+func (s *WebhookService) HandleGitHub(eventType, signature string, payload []byte) error {
+	if err := s.github.VerifySignature(payload, signature); err != nil {
+		if s.cfg.GitHubWebhookSecret == "" {
+			return fmt.Errorf("%w: GitHub webhook secret not configured", ErrInvalidWebhookSignature)
+		}
+		return ErrInvalidWebhookSignature
+	}
+	return s.github.HandleWebhook(eventType, payload)
+}
+
+// HandleStripe verifies a Stripe delivery's Stripe-Signature header against the
+// signing secret and applies the event. It returns ErrInvalidWebhookSignature
+// for forged, unsigned or stale deliveries.
+func (s *WebhookService) HandleStripe(ctx context.Context, signature string, payload []byte) error {
+	if s.cfg.StripeWebhookSecret == "" {
+		return fmt.Errorf("%w: Stripe webhook secret not configured", ErrInvalidWebhookSignature)
+	}
+
+	event, err := webhook.ConstructEvent(payload, signature, s.cfg.StripeWebhookSecret)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidWebhookSignature, err)
+	}
+
+	if err := s.billing.HandleWebhook(ctx, event); err != nil {
+		return fmt.Errorf("failed to handle %s event: %w", event.Type, err)
+	}
+	return nil
+}
@@ -0,0 +1,561 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/config"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/internal/security"
+	"github.com/delphi-platform/delphi/backend/pkg/crypto"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// Execution events delivered to webhooks
+const (
+	WebhookEventRunCompleted = "run.completed"
+	WebhookEventRunFailed    = "run.failed"
+)
+
+var webhookEvents = map[string]bool{
+	WebhookEventRunCompleted: true,
+	WebhookEventRunFailed:    true,
+}
+
+const (
+	webhookDeliveryInterval = 10 * time.Second
+	maxDueDeliveries        = 100
+	webhookConcurrency      = 8
+	webhookRequestTimeout   = 10 * time.Second
+
+	// webhookClaimTimeout is how long a delivery may stay claimed before its
+	// worker is presumed dead. Each delivery pass is bounded well below it.
+	webhookClaimTimeout = 5 * time.Minute
+
+	// webhookRetryBackoff is the delay before a failed delivery is retried,
+	// doubling on every attempt
+	webhookRetryBackoff = 30 * time.Second
+
+	webhookSecretLength = 32
+
+	// maxWebhookResponseBody is how much of a response is read, so the
+	// connection can be reused. Responses are never stored: the delivery log
+	// is visible to the tenant and must not relay what the receiver returned.
+	maxWebhookResponseBody = 4096
+)
+
+// WebhookDeliveryService manages tenants' outbound webhooks and delivers
+// execution events to them. Every instance runs the delivery loop;
+// deliveries are claimed row by row, so instances can deliver concurrently
+// without attempting a delivery twice.
+type WebhookDeliveryService struct {
+	cfg       *config.Config
+	repos     *repository.Repositories
+	encryptor *crypto.Encryptor
+	client    *http.Client
+	log       *logger.Logger
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewWebhookDeliveryService creates a new webhook delivery service and starts
+// its delivery loop
+func NewWebhookDeliveryService(cfg *config.Config, repos *repository.Repositories, encryptor *crypto.Encryptor, log *logger.Logger) *WebhookDeliveryService {
+	s := &WebhookDeliveryService{
+		cfg:       cfg,
+		repos:     repos,
+		encryptor: encryptor,
+		client: &http.Client{
+			Timeout:   webhookRequestTimeout,
+			Transport: webhookTransport(cfg),
+			// A redirect counts as a failed delivery rather than sending the
+			// signed payload somewhere else
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		log:  log,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go s.deliveryLoop()
+	return s
+}
+
+// webhookTransport sends deliveries directly, never through a proxy, and
+// outside development only to public addresses, so a webhook can't be
+// pointed at the platform's internal services
+func webhookTransport(cfg *config.Config) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	if !cfg.IsDevelopment() {
+		dialer := security.PublicDialer(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second})
+		transport.DialContext = dialer.DialContext
+	}
+	return transport
+}
+
+// Stop stops the delivery loop, waiting for deliveries in flight to finish
+func (s *WebhookDeliveryService) Stop() {
+	close(s.stop)
+	<-s.done
+}
+
+// CreateWebhookRequest represents webhook creation input. A secret is
+// generated when none is given.
+type CreateWebhookRequest struct {
+	URL     string   `json:"url"`
+	Events  []string `json:"events"`
+	Secret  string   `json:"secret"`
+	Enabled *bool    `json:"enabled"`
+}
+
+// UpdateWebhookRequest represents webhook changes; unset fields are kept.
+// Enabling a webhook clears its failure count.
+type UpdateWebhookRequest struct {
+	URL     *string  `json:"url"`
+	Events  []string `json:"events"`
+	Secret  *string  `json:"secret"`
+	Enabled *bool    `json:"enabled"`
+}
+
+// CreatedWebhook is a new webhook with its signing secret, which is only
+// returned when the webhook is created
+type CreatedWebhook struct {
+	*models.Webhook
+	Secret string `json:"secret"`
+}
+
+// webhookPayload is the JSON body POSTed to a webhook
+type webhookPayload struct {
+	ID        uuid.UUID   `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// validateWebhookURL requires an https URL whose host resolves to public
+// addresses. Development also allows http and local receivers.
+func (s *WebhookDeliveryService) validateWebhookURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && !(u.Scheme == "http" && s.cfg.IsDevelopment())) {
+		return fmt.Errorf("webhook url must be an https URL")
+	}
+	if s.cfg.IsDevelopment() {
+		return nil
+	}
+	if err := security.ValidatePublicURL(ctx, u); err != nil {
+		if errors.Is(err, security.ErrNonPublicAddress) {
+			return fmt.Errorf("webhook url must not point to a private or internal address")
+		}
+		return fmt.Errorf("webhook url host can't be resolved")
+	}
+	return nil
+}
+
+// normalizeWebhookEvents checks the events are known and drops duplicates
+func normalizeWebhookEvents(events []string) ([]string, error) {
+	if len(events) == 0 {
+		return nil, fmt.Errorf("at least one event is required")
+	}
+	seen := make(map[string]bool, len(events))
+	normalized := make([]string, 0, len(events))
+	for _, event := range events {
+		if !webhookEvents[event] {
+			return nil, fmt.Errorf("unsupported event: %s", event)
+		}
+		if !seen[event] {
+			seen[event] = true
+			normalized = append(normalized, event)
+		}
+	}
+	return normalized, nil
+}
+
+func (s *WebhookDeliveryService) encryptSecret(secret string) (string, error) {
+	encrypted, err := s.encryptor.Encrypt(secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+	return encrypted, nil
+}
+
+func (s *WebhookDeliveryService) decryptSecret(secret string) (string, error) {
+	plain, err := s.encryptor.Decrypt(secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+	return plain, nil
+}
+
+// List returns the tenant's webhooks
+func (s *WebhookDeliveryService) List(ctx context.Context, tenantID uuid.UUID) ([]*models.Webhook, error) {
+	webhooks, err := s.repos.Webhooks.ListByTenant(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	if webhooks == nil {
+		webhooks = []*models.Webhook{}
+	}
+	return webhooks, nil
+}
+
+// Get retrieves one of the tenant's webhooks
+func (s *WebhookDeliveryService) Get(ctx context.Context, tenantID, webhookID uuid.UUID) (*models.Webhook, error) {
+	webhook, err := s.repos.Webhooks.GetByID(ctx, webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	if webhook == nil || webhook.TenantID != tenantID {
+		return nil, fmt.Errorf("webhook not found")
+	}
+	return webhook, nil
+}
+
+// Create adds a webhook to the tenant
+func (s *WebhookDeliveryService) Create(ctx context.Context, tenantID uuid.UUID, req *CreateWebhookRequest) (*CreatedWebhook, error) {
+	if err := s.validateWebhookURL(ctx, req.URL); err != nil {
+		return nil, err
+	}
+	events, err := normalizeWebhookEvents(req.Events)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		if secret, err = crypto.GenerateRandomString(webhookSecretLength); err != nil {
+			return nil, fmt.Errorf("failed to generate secret: %w", err)
+		}
+	}
+	encrypted, err := s.encryptSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	webhook := &models.Webhook{
+		ID:        uuid.New(),
+		TenantID:  tenantID,
+		URL:       req.URL,
+		Events:    events,
+		Secret:    encrypted,
+		Enabled:   req.Enabled == nil || *req.Enabled,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := s.repos.Webhooks.Create(ctx, webhook); err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	s.log.Infow("webhook created", "webhook_id", webhook.ID, "tenant_id", tenantID, "events", events)
+
+	return &CreatedWebhook{Webhook: webhook, Secret: secret}, nil
+}
+
+// Update changes a webhook
+func (s *WebhookDeliveryService) Update(ctx context.Context, tenantID, webhookID uuid.UUID, req *UpdateWebhookRequest) (*models.Webhook, error) {
+	webhook, err := s.Get(ctx, tenantID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.URL != nil {
+		if err := s.validateWebhookURL(ctx, *req.URL); err != nil {
+			return nil, err
+		}
+		webhook.URL = *req.URL
+	}
+	if req.Events != nil {
+		if webhook.Events, err = normalizeWebhookEvents(req.Events); err != nil {
+			return nil, err
+		}
+	}
+	if req.Secret != nil {
+		if *req.Secret == "" {
+			return nil, fmt.Errorf("secret cannot be empty")
+		}
+		if webhook.Secret, err = s.encryptSecret(*req.Secret); err != nil {
+			return nil, err
+		}
+	}
+	if req.Enabled != nil {
+		if *req.Enabled && !webhook.Enabled {
+			webhook.ConsecutiveFailures = 0
+			webhook.DisabledAt = nil
+		}
+		webhook.Enabled = *req.Enabled
+	}
+
+	if err := s.repos.Webhooks.Update(ctx, webhook); err != nil {
+		return nil, fmt.Errorf("failed to update webhook: %w", err)
+	}
+	return webhook, nil
+}
+
+// Delete removes a webhook and its delivery log
+func (s *WebhookDeliveryService) Delete(ctx context.Context, tenantID, webhookID uuid.UUID) error {
+	if _, err := s.Get(ctx, tenantID, webhookID); err != nil {
+		return err
+	}
+	if err := s.repos.Webhooks.Delete(ctx, webhookID); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	return nil
+}
+
+// ListDeliveries returns a webhook's most recent deliveries, newest first
+func (s *WebhookDeliveryService) ListDeliveries(ctx context.Context, tenantID, webhookID uuid.UUID, limit int) ([]*models.WebhookDelivery, error) {
+	if _, err := s.Get(ctx, tenantID, webhookID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	deliveries, err := s.repos.Webhooks.ListDeliveries(ctx, webhookID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deliveries: %w", err)
+	}
+	if deliveries == nil {
+		deliveries = []*models.WebhookDelivery{}
+	}
+	return deliveries, nil
+}
+
+// Publish queues an event for every enabled webhook of the tenant that
+// subscribes to it. The delivery loop sends it.
+func (s *WebhookDeliveryService) Publish(ctx context.Context, tenantID uuid.UUID, event string, data interface{}) error {
+	webhooks, err := s.repos.Webhooks.ListSubscribed(ctx, tenantID, event)
+	if err != nil {
+		return fmt.Errorf("failed to list subscribed webhooks: %w", err)
+	}
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	deliveries := make([]*models.WebhookDelivery, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		id := uuid.New()
+		payload, err := json.Marshal(webhookPayload{ID: id, Event: event, CreatedAt: now, Data: data})
+		if err != nil {
+			return fmt.Errorf("failed to encode payload: %w", err)
+		}
+		deliveries = append(deliveries, &models.WebhookDelivery{
+			ID:            id,
+			WebhookID:     webhook.ID,
+			Event:         event,
+			Payload:       payload,
+			Status:        "pending",
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+	}
+
+	if err := s.repos.Webhooks.CreateDeliveries(ctx, deliveries); err != nil {
+		return fmt.Errorf("failed to queue deliveries: %w", err)
+	}
+
+	s.log.Debugw("webhook event queued", "tenant_id", tenantID, "event", event, "webhooks", len(deliveries))
+	return nil
+}
+
+func (s *WebhookDeliveryService) deliveryLoop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(webhookDeliveryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), webhookClaimTimeout/2)
+			s.deliverDue(ctx)
+			cancel()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// deliverDue claims the deliveries that are due and attempts them
+func (s *WebhookDeliveryService) deliverDue(ctx context.Context) {
+	stale, err := s.repos.Webhooks.ReleaseStaleDeliveries(ctx, time.Now().Add(-webhookClaimTimeout))
+	if err != nil {
+		s.log.Warnw("failed to release stale webhook deliveries", "error", err)
+	} else if stale > 0 {
+		s.log.Warnw("webhook deliveries abandoned mid-attempt released", "count", stale)
+	}
+
+	deliveries, err := s.repos.Webhooks.ClaimDueDeliveries(ctx, time.Now(), maxDueDeliveries)
+	if err != nil {
+		s.log.Warnw("failed to claim due webhook deliveries", "error", err)
+		return
+	}
+	if len(deliveries) == 0 {
+		return
+	}
+
+	// Load each webhook once for the deliveries that share it
+	webhooks := make(map[uuid.UUID]*models.Webhook)
+	secrets := make(map[uuid.UUID]string)
+
+	sem := make(chan struct{}, webhookConcurrency)
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		webhook, ok := webhooks[delivery.WebhookID]
+		if !ok {
+			webhook, err = s.repos.Webhooks.GetByID(ctx, delivery.WebhookID)
+			if err != nil {
+				s.deliveryFailed(ctx, delivery, fmt.Errorf("failed to get webhook: %w", err))
+				continue
+			}
+			if webhook == nil {
+				// Deleted since the claim, taking its deliveries with it
+				continue
+			}
+			secret, err := s.decryptSecret(webhook.Secret)
+			if err != nil {
+				s.deliveryFailed(ctx, delivery, err)
+				continue
+			}
+			webhooks[webhook.ID] = webhook
+			secrets[webhook.ID] = secret
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(webhook *models.Webhook, secret string, delivery *models.WebhookDelivery) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			s.deliver(ctx, webhook, secret, delivery)
+		}(webhook, secrets[webhook.ID], delivery)
+	}
+	wg.Wait()
+}
+
+// deliver makes one attempt at a claimed delivery and records the outcome
+func (s *WebhookDeliveryService) deliver(ctx context.Context, webhook *models.Webhook, secret string, delivery *models.WebhookDelivery) {
+	status, err := s.post(ctx, webhook.URL, secret, delivery)
+	if status != 0 {
+		delivery.ResponseStatus = &status
+	}
+	if err != nil {
+		s.deliveryFailed(ctx, delivery, err)
+		return
+	}
+
+	deliveredAt := time.Now()
+	delivery.DeliveredAt = &deliveredAt
+	if err := s.repos.Webhooks.MarkDelivered(ctx, delivery); err != nil {
+		s.log.Errorw("failed to record webhook delivery", "delivery_id", delivery.ID, "webhook_id", webhook.ID, "error", err)
+		return
+	}
+
+	s.log.Debugw("webhook delivered",
+		"delivery_id", delivery.ID,
+		"webhook_id", webhook.ID,
+		"event", delivery.Event,
+		"status", status,
+	)
+}
+
+// post sends a delivery's payload, signed with the webhook's secret. It
+// returns the response status, if there was a response, and an error unless
+// the status is 2xx.
+func (s *WebhookDeliveryService) post(ctx context.Context, webhookURL, secret string, delivery *models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Delphi-Webhooks/1.0")
+	req.Header.Set("X-Delphi-Event", delivery.Event)
+	req.Header.Set("X-Delphi-Delivery", delivery.ID.String())
+	req.Header.Set("X-Delphi-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Delphi-Signature", signWebhookPayload(secret, timestamp, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxWebhookResponseBody))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// signWebhookPayload returns the X-Delphi-Signature header value: the hex
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the webhook's secret.
+// Signing the timestamp lets receivers reject replayed deliveries.
+func signWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliveryFailed schedules a claimed delivery for another attempt, or fails
+// it once it has used up its attempts. The failure counts against the
+// webhook, which may disable it.
+func (s *WebhookDeliveryService) deliveryFailed(ctx context.Context, delivery *models.WebhookDelivery, cause error) {
+	delivery.Error = cause.Error()
+
+	var retryAt *time.Time
+	if delivery.Attempts < s.cfg.WebhookMaxAttempts {
+		next := time.Now().Add(webhookRetryBackoff << (delivery.Attempts - 1))
+		retryAt = &next
+	}
+
+	disabled, err := s.repos.Webhooks.RecordFailedAttempt(ctx, delivery, retryAt, s.cfg.WebhookDisableAfterFailures)
+	if err != nil {
+		s.log.Errorw("failed to record failed webhook delivery", "delivery_id", delivery.ID, "error", err)
+		return
+	}
+
+	if retryAt != nil {
+		s.log.Warnw("webhook delivery failed, will retry",
+			"delivery_id", delivery.ID,
+			"webhook_id", delivery.WebhookID,
+			"attempts", delivery.Attempts,
+			"retry_at", retryAt,
+			"error", cause,
+		)
+	} else {
+		s.log.Warnw("webhook delivery failed",
+			"delivery_id", delivery.ID,
+			"webhook_id", delivery.WebhookID,
+			"attempts", delivery.Attempts,
+			"error", cause,
+		)
+	}
+	if disabled {
+		s.log.Warnw("webhook disabled after repeated failures",
+			"webhook_id", delivery.WebhookID,
+			"failures", s.cfg.WebhookDisableAfterFailures,
+		)
+	}
+}
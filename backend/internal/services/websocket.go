@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/repository"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+)
+
+const (
+	// runLogChannel is the Redis channel that carries run logs between API instances
+	runLogChannel = "run_logs"
+
+	// runLogBacklog is how many recent entries a new subscriber receives
+	runLogBacklog = 200
+
+	// runLogBuffer is how many entries a subscriber may fall behind before it is dropped
+	runLogBuffer = 256
+)
+
+// =============================================================================
+// Run Log Hub
+// =============================================================================
+
+// RunLogHub fans out agent run logs to every local subscriber of the same run
+type RunLogHub struct {
+	mu   sync.Mutex
+	runs map[uuid.UUID]map[*RunLogSubscription]struct{}
+}
+
+// NewRunLogHub creates an empty hub
+func NewRunLogHub() *RunLogHub {
+	return &RunLogHub{runs: make(map[uuid.UUID]map[*RunLogSubscription]struct{})}
+}
+
+// RunLogSubscription receives the log entries of one run. C is closed when the
+// subscription is closed, including when the subscriber falls too far behind.
+type RunLogSubscription struct {
+	RunID uuid.UUID
+	C     <-chan *models.AgentLog
+
+	ch   chan *models.AgentLog
+	hub  *RunLogHub
+	once sync.Once
+}
+
+// Subscribe registers a subscriber for a run's logs
+func (h *RunLogHub) Subscribe(runID uuid.UUID) *RunLogSubscription {
+	ch := make(chan *models.AgentLog, runLogBuffer)
+	sub := &RunLogSubscription{RunID: runID, C: ch, ch: ch, hub: h}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	subs, ok := h.runs[runID]
+	if !ok {
+		subs = make(map[*RunLogSubscription]struct{})
+		h.runs[runID] = subs
+	}
+	subs[sub] = struct{}{}
+	return sub
+}
+
+// Publish delivers an entry to the run's subscribers without blocking. A
+// subscriber whose buffer is full is closed rather than silently losing entries;
+// it can reconnect and catch up from the stored backlog.
+func (h *RunLogHub) Publish(entry *models.AgentLog) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.runs[entry.RunID] {
+		select {
+		case sub.ch <- entry:
+		default:
+			h.remove(sub)
+		}
+	}
+}
+
+// Subscribers returns the number of subscribers of a run
+func (h *RunLogHub) Subscribers(runID uuid.UUID) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.runs[runID])
+}
+
+// Close unsubscribes and closes C. It is safe to call more than once.
+func (s *RunLogSubscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// remove drops a subscription. Callers must hold h.mu.
+func (h *RunLogHub) remove(sub *RunLogSubscription) {
+	sub.once.Do(func() {
+		subs := h.runs[sub.RunID]
+		delete(subs, sub)
+		if len(subs) == 0 {
+			delete(h.runs, sub.RunID)
+		}
+		close(sub.ch)
+	})
+}
+
+// =============================================================================
+// WebSocket Service
+// =============================================================================
+
+// WebSocketService streams live agent run logs. Entries are stored in
+// agent_logs and, when Redis is available, relayed through it so subscribers
+// connected to any API instance receive them.
+type WebSocketService struct {
+	repos *repository.Repositories
+	redis *repository.RedisClient
+	hub   *RunLogHub
+	log   *logger.Logger
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewWebSocketService creates a new WebSocket service and starts relaying
+// run logs from Redis
+func NewWebSocketService(repos *repository.Repositories, redis *repository.RedisClient, log *logger.Logger) *WebSocketService {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &WebSocketService{
+		repos:  repos,
+		redis:  redis,
+		hub:    NewRunLogHub(),
+		log:    log,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	if redis != nil {
+		go s.relay(ctx)
+	} else {
+		close(s.done)
+	}
+	return s
+}
+
+// Stop stops relaying run logs from Redis
+func (s *WebSocketService) Stop() {
+	s.cancel()
+	<-s.done
+}
+
+// AppendRunLog stores a log entry for a run and publishes it to live subscribers
+func (s *WebSocketService) AppendRunLog(ctx context.Context, runID uuid.UUID, level models.LogLevel, message string, metadata map[string]interface{}) error {
+	entry := &models.AgentLog{
+		ID:        uuid.New(),
+		RunID:     runID,
+		Level:     level,
+		Message:   message,
+		CreatedAt: time.Now(),
+	}
+	if metadata != nil {
+		data, err := json.Marshal(metadata)
+		if err != nil {
+			return fmt.Errorf("failed to marshal log metadata: %w", err)
+		}
+		entry.Metadata = data
+	}
+
+	if err := s.repos.AgentLogs.Create(ctx, entry); err != nil {
+		return fmt.Errorf("failed to store run log: %w", err)
+	}
+
+	if s.redis == nil {
+		s.hub.Publish(entry)
+		return nil
+	}
+
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal run log: %w", err)
+	}
+	if err := s.redis.Publish(ctx, runLogChannel, payload); err != nil {
+		// The entry is stored; local subscribers can still see it
+		s.log.Warnw("failed to publish run log", "run_id", runID, "error", err)
+		s.hub.Publish(entry)
+	}
+	return nil
+}
+
+// SubscribeRun subscribes to a tenant's run logs. It returns the run's recent
+// entries along with the subscription; entries logged while the backlog was
+// loading may appear in both.
+func (s *WebSocketService) SubscribeRun(ctx context.Context, tenantID, runID uuid.UUID) (*RunLogSubscription, []*models.AgentLog, error) {
+	run, err := s.repos.AgentRuns.GetByID(ctx, runID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get run: %w", err)
+	}
+	if run == nil || run.TenantID != tenantID {
+		return nil, nil, fmt.Errorf("run not found")
+	}
+
+	// Subscribe before reading the backlog so nothing falls between the two
+	sub := s.hub.Subscribe(runID)
+
+	backlog, err := s.repos.AgentLogs.ListByRun(ctx, runID, runLogBacklog)
+	if err != nil {
+		sub.Close()
+		return nil, nil, fmt.Errorf("failed to list run logs: %w", err)
+	}
+
+	return sub, backlog, nil
+}
+
+// relay publishes run logs received from Redis to the local hub
+func (s *WebSocketService) relay(ctx context.Context) {
+	defer close(s.done)
+
+	pubsub := s.redis.Subscribe(ctx, runLogChannel)
+	defer pubsub.Close()
+
+	for {
+		msg, err := pubsub.ReceiveMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			s.log.Warnw("run log relay interrupted", "error", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+				continue
+			}
+		}
+
+		var entry models.AgentLog
+		if err := json.Unmarshal([]byte(msg.Payload), &entry); err != nil {
+			s.log.Warnw("invalid run log message", "error", err)
+			continue
+		}
+		s.hub.Publish(&entry)
+	}
+}
@@ -0,0 +1,345 @@
+package social
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// =============================================================================
+// Batch Operations
+// =============================================================================
+
+// RateLimitError is returned by providers when a platform rejects a request with a 429
+type RateLimitError struct {
+	Platform   Platform
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("%s rate limit exceeded, retry after %s", e.Platform, e.RetryAfter)
+	}
+	return fmt.Sprintf("%s rate limit exceeded", e.Platform)
+}
+
+// BatchConfig controls how batch publishing and metrics fetching fan out
+type BatchConfig struct {
+	// Concurrency is the maximum number of operations in flight across all platforms
+	Concurrency int
+
+	// PlatformConcurrency caps in-flight operations per platform. Platforms
+	// not listed use DefaultPlatformConcurrency.
+	PlatformConcurrency map[Platform]int
+
+	// MaxRetries is how many times a rate-limited operation is retried within
+	// the batch. Callers that retry failed items themselves set it to 0.
+	MaxRetries int
+
+	// InitialBackoff is the first retry delay when the platform gives no Retry-After.
+	// It doubles on every attempt up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultPlatformConcurrency is used for platforms without an explicit limit
+const DefaultPlatformConcurrency = 2
+
+// DefaultBatchConfig returns conservative batch settings
+func DefaultBatchConfig() BatchConfig {
+	return BatchConfig{
+		Concurrency: 8,
+		PlatformConcurrency: map[Platform]int{
+			PlatformTwitter:   2,
+			PlatformLinkedIn:  2,
+			PlatformInstagram: 2,
+			PlatformFacebook:  4,
+			PlatformTikTok:    1,
+			PlatformDiscord:   4,
+			PlatformYouTube:   2,
+		},
+		MaxRetries:     3,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+	}
+}
+
+// SetBatchConfig replaces the settings used by PublishBatch and FetchMetricsBatch
+func (s *Service) SetBatchConfig(cfg BatchConfig) {
+	defaults := DefaultBatchConfig()
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = defaults.Concurrency
+	}
+	if cfg.PlatformConcurrency == nil {
+		cfg.PlatformConcurrency = defaults.PlatformConcurrency
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = defaults.InitialBackoff
+	}
+	if cfg.MaxBackoff < cfg.InitialBackoff {
+		cfg.MaxBackoff = defaults.MaxBackoff
+	}
+
+	s.batchMu.Lock()
+	defer s.batchMu.Unlock()
+	s.batch = cfg
+	s.gates = make(map[Platform]*platformGate)
+}
+
+// PublishItem is a post to publish as part of a batch
+type PublishItem struct {
+	Account *Account
+	Post    *Post
+}
+
+// PublishResult is the outcome of publishing one post in a batch
+type PublishResult struct {
+	PostID     uuid.UUID `json:"post_id"`
+	Platform   Platform  `json:"platform"`
+	ExternalID string    `json:"external_id,omitempty"`
+	Attempts   int       `json:"attempts"`
+	Error      string    `json:"error,omitempty"`
+
+	// Err is the error behind Error, such as a *RateLimitError
+	Err error `json:"-"`
+}
+
+// MetricsResult is the outcome of fetching metrics for one post in a batch
+type MetricsResult struct {
+	PostID   uuid.UUID    `json:"post_id"`
+	Platform Platform     `json:"platform"`
+	Metrics  *PostMetrics `json:"metrics,omitempty"`
+	Attempts int          `json:"attempts"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// BatchSummary counts the outcomes of a batch
+type BatchSummary struct {
+	Total     int `json:"total"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+}
+
+// PublishBatch publishes posts concurrently, bounded globally and per platform.
+// Results are returned in the same order as the items.
+func (s *Service) PublishBatch(ctx context.Context, items []PublishItem) ([]PublishResult, BatchSummary) {
+	results := make([]PublishResult, len(items))
+
+	s.runBatch(ctx, len(items), func(i int) Platform { return items[i].Account.Platform }, func(i int) (int, error) {
+		return s.withRetry(ctx, items[i].Account.Platform, func() error {
+			return s.PublishPost(ctx, items[i].Account, items[i].Post)
+		})
+	}, func(i, attempts int, err error) {
+		results[i] = PublishResult{
+			PostID:     items[i].Post.ID,
+			Platform:   items[i].Account.Platform,
+			ExternalID: items[i].Post.ExternalID,
+			Attempts:   attempts,
+			Err:        err,
+		}
+		if err != nil {
+			results[i].Error = err.Error()
+		}
+	})
+
+	summary := BatchSummary{Total: len(results)}
+	for _, r := range results {
+		if r.Error == "" {
+			summary.Succeeded++
+		} else {
+			summary.Failed++
+		}
+	}
+
+	s.log.Infow("post batch published", "total", summary.Total, "succeeded", summary.Succeeded, "failed", summary.Failed)
+	return results, summary
+}
+
+// FetchMetricsBatch refreshes metrics for many posts concurrently, bounded
+// globally and per platform. Results are returned in the same order as the items.
+func (s *Service) FetchMetricsBatch(ctx context.Context, items []PublishItem) ([]MetricsResult, BatchSummary) {
+	results := make([]MetricsResult, len(items))
+
+	s.runBatch(ctx, len(items), func(i int) Platform { return items[i].Account.Platform }, func(i int) (int, error) {
+		return s.withRetry(ctx, items[i].Account.Platform, func() error {
+			metrics, err := s.GetPostMetrics(ctx, items[i].Account, items[i].Post)
+			if err == nil {
+				results[i].Metrics = metrics
+			}
+			return err
+		})
+	}, func(i, attempts int, err error) {
+		results[i].PostID = items[i].Post.ID
+		results[i].Platform = items[i].Account.Platform
+		results[i].Attempts = attempts
+		if err != nil {
+			results[i].Error = err.Error()
+		}
+	})
+
+	summary := BatchSummary{Total: len(results)}
+	for _, r := range results {
+		if r.Error == "" {
+			summary.Succeeded++
+		} else {
+			summary.Failed++
+		}
+	}
+
+	s.log.Infow("post metrics batch fetched", "total", summary.Total, "succeeded", summary.Succeeded, "failed", summary.Failed)
+	return results, summary
+}
+
+// runBatch runs n operations with the configured global and per-platform concurrency
+func (s *Service) runBatch(ctx context.Context, n int, platformOf func(int) Platform, op func(int) (int, error), done func(i, attempts int, err error)) {
+	cfg := s.batchConfig()
+	sem := make(chan struct{}, cfg.Concurrency)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			done(i, 0, ctx.Err())
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			gate := s.gate(platformOf(i))
+			if err := gate.acquire(ctx); err != nil {
+				done(i, 0, err)
+				return
+			}
+			defer gate.release()
+
+			attempts, err := op(i)
+			done(i, attempts, err)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// withRetry retries fn on platform rate-limit errors with exponential backoff,
+// honoring the platform's Retry-After when given. A rate limit pauses every
+// operation on the platform, whether or not fn is retried.
+func (s *Service) withRetry(ctx context.Context, platform Platform, fn func() error) (int, error) {
+	cfg := s.batchConfig()
+	backoff := cfg.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		if err := s.gate(platform).waitUntilOpen(ctx); err != nil {
+			return attempt - 1, err
+		}
+
+		err := fn()
+		if err == nil {
+			return attempt, nil
+		}
+
+		var rateErr *RateLimitError
+		if !errors.As(err, &rateErr) {
+			return attempt, err
+		}
+
+		wait := rateErr.RetryAfter
+		if wait <= 0 {
+			wait = backoff
+			backoff *= 2
+			if backoff > cfg.MaxBackoff {
+				backoff = cfg.MaxBackoff
+			}
+		}
+
+		// Hold back every operation on this platform, not just this one
+		s.gate(platform).pauseFor(wait)
+		if attempt > cfg.MaxRetries {
+			return attempt, err
+		}
+		s.log.Warnw("social platform rate limited, backing off",
+			"platform", platform,
+			"attempt", attempt,
+			"wait", wait,
+		)
+	}
+}
+
+func (s *Service) batchConfig() BatchConfig {
+	s.batchMu.Lock()
+	defer s.batchMu.Unlock()
+	return s.batch
+}
+
+// gate returns the concurrency gate of a platform, creating it on first use
+func (s *Service) gate(platform Platform) *platformGate {
+	s.batchMu.Lock()
+	defer s.batchMu.Unlock()
+
+	gate, ok := s.gates[platform]
+	if !ok {
+		limit := s.batch.PlatformConcurrency[platform]
+		if limit <= 0 {
+			limit = DefaultPlatformConcurrency
+		}
+		gate = &platformGate{slots: make(chan struct{}, limit)}
+		s.gates[platform] = gate
+	}
+	return gate
+}
+
+// platformGate bounds in-flight operations on a platform and pauses them all
+// after the platform reports a rate limit
+type platformGate struct {
+	slots       chan struct{}
+	mu          sync.Mutex
+	pausedUntil time.Time
+}
+
+func (g *platformGate) acquire(ctx context.Context) error {
+	select {
+	case g.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (g *platformGate) release() {
+	<-g.slots
+}
+
+func (g *platformGate) pauseFor(d time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if until := time.Now().Add(d); until.After(g.pausedUntil) {
+		g.pausedUntil = until
+	}
+}
+
+func (g *platformGate) waitUntilOpen(ctx context.Context) error {
+	g.mu.Lock()
+	wait := time.Until(g.pausedUntil)
+	g.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
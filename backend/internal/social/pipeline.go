@@ -0,0 +1,178 @@
+package social
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+)
+
+// =============================================================================
+// Content Pipeline
+// =============================================================================
+
+// ContentGenerator runs an agent on a prompt and returns its text output
+type ContentGenerator interface {
+	Generate(ctx context.Context, tenantID, agentID uuid.UUID, prompt string) (string, error)
+}
+
+// SetContentGenerator sets the agent runner used by GenerateContent
+func (s *Service) SetContentGenerator(generator ContentGenerator) {
+	s.generator = generator
+}
+
+// platformMaxLength is the longest post each platform accepts, in characters
+var platformMaxLength = map[Platform]int{
+	PlatformTwitter:   280,
+	PlatformLinkedIn:  3000,
+	PlatformInstagram: 2200,
+	PlatformFacebook:  63206,
+	PlatformTikTok:    2200,
+	PlatformDiscord:   2000,
+	PlatformYouTube:   5000,
+}
+
+// maxPostLength returns the length limit for a post on a platform: the
+// pipeline's MaxLength, capped at what the platform accepts
+func maxPostLength(cfg PipelineConfig, platform Platform) int {
+	limit := platformMaxLength[platform]
+	if cfg.MaxLength > 0 && (limit == 0 || cfg.MaxLength < limit) {
+		limit = cfg.MaxLength
+	}
+	return limit
+}
+
+// GenerateContent briefs the pipeline's agent and generates one draft post per
+// platform. Posts are left as drafts when the pipeline requires review and
+// scheduled for immediate publication otherwise. The pipeline's LastRunAt and
+// NextRunAt are updated from its cron schedule. A platform that fails to
+// generate is skipped; its error is returned alongside the other posts.
+func (s *Service) GenerateContent(ctx context.Context, pipeline *ContentPipeline) ([]*Post, error) {
+	if s.generator == nil {
+		return nil, fmt.Errorf("content generator not configured")
+	}
+
+	var schedule cron.Schedule
+	if pipeline.Schedule != "" {
+		var err error
+		schedule, err = cron.ParseStandard(pipeline.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pipeline schedule: %w", err)
+		}
+	}
+
+	s.log.Infow("generating content",
+		"pipeline_id", pipeline.ID,
+		"agent_id", pipeline.AgentID,
+		"platforms", pipeline.Platforms,
+	)
+
+	now := time.Now()
+	var posts []*Post
+	var errs []error
+	for _, platform := range pipeline.Platforms {
+		maxLength := maxPostLength(pipeline.Config, platform)
+
+		content, err := s.generator.Generate(ctx, pipeline.TenantID, pipeline.AgentID, contentPrompt(pipeline.Config, platform, maxLength))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to generate %s post: %w", platform, err))
+			continue
+		}
+		content = fitToLength(strings.TrimSpace(content), maxLength)
+		if content == "" {
+			errs = append(errs, fmt.Errorf("agent returned no content for %s", platform))
+			continue
+		}
+
+		agentID := pipeline.AgentID
+		post := &Post{
+			ID:         uuid.New(),
+			TenantID:   pipeline.TenantID,
+			BusinessID: pipeline.BusinessID,
+			AccountID:  pipeline.Accounts[platform],
+			AgentID:    &agentID,
+			Platform:   platform,
+			Content:    content,
+			Status:     PostStatusDraft,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}
+		if !pipeline.Config.ReviewRequired {
+			if err := s.SchedulePost(ctx, post, now); err != nil {
+				errs = append(errs, fmt.Errorf("failed to schedule %s post: %w", platform, err))
+			}
+		}
+		posts = append(posts, post)
+	}
+
+	pipeline.LastRunAt = &now
+	pipeline.NextRunAt = nil
+	if schedule != nil {
+		if next := schedule.Next(now); !next.IsZero() {
+			pipeline.NextRunAt = &next
+		}
+	}
+
+	s.log.Infow("content generated",
+		"pipeline_id", pipeline.ID,
+		"posts", len(posts),
+		"failed", len(errs),
+		"review_required", pipeline.Config.ReviewRequired,
+	)
+
+	return posts, errors.Join(errs...)
+}
+
+// contentPrompt builds the instructions given to the content agent for one platform
+func contentPrompt(cfg PipelineConfig, platform Platform, maxLength int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Write a single %s post.\n", platform)
+	if len(cfg.Topics) > 0 {
+		fmt.Fprintf(&b, "Topics: %s\n", strings.Join(cfg.Topics, ", "))
+	}
+	if cfg.Tone != "" {
+		fmt.Fprintf(&b, "Tone: %s\n", cfg.Tone)
+	}
+	if cfg.Style != "" {
+		fmt.Fprintf(&b, "Style: %s\n", cfg.Style)
+	}
+	if maxLength > 0 {
+		fmt.Fprintf(&b, "Keep it under %d characters.\n", maxLength)
+	}
+	if cfg.IncludeHashtags {
+		b.WriteString("Include a few relevant hashtags.\n")
+	} else {
+		b.WriteString("Do not use hashtags.\n")
+	}
+	if cfg.IncludeEmojis {
+		b.WriteString("Use emojis where they fit.\n")
+	} else {
+		b.WriteString("Do not use emojis.\n")
+	}
+	b.WriteString("Reply with the post text only, without quotes or commentary.")
+	return b.String()
+}
+
+// fitToLength shortens content to at most maxLength characters, cutting at the
+// last word boundary if that keeps at least half of it
+func fitToLength(content string, maxLength int) string {
+	if maxLength <= 0 || utf8.RuneCountInString(content) <= maxLength {
+		return content
+	}
+
+	runes := []rune(content)[:maxLength]
+	cut := len(runes)
+	for i := len(runes) - 1; i > len(runes)/2; i-- {
+		if unicode.IsSpace(runes[i]) {
+			cut = i
+			break
+		}
+	}
+	return strings.TrimRightFunc(string(runes[:cut]), unicode.IsSpace)
+}
@@ -3,6 +3,7 @@ package social
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/delphi-platform/delphi/backend/pkg/logger"
@@ -30,10 +31,11 @@ const (
 type PostStatus string
 
 const (
-	PostStatusDraft     PostStatus = "draft"
-	PostStatusScheduled PostStatus = "scheduled"
-	PostStatusPublished PostStatus = "published"
-	PostStatusFailed    PostStatus = "failed"
+	PostStatusDraft      PostStatus = "draft"
+	PostStatusScheduled  PostStatus = "scheduled"
+	PostStatusPublishing PostStatus = "publishing" // claimed by the publishing worker
+	PostStatusPublished  PostStatus = "published"
+	PostStatusFailed     PostStatus = "failed"
 )
 
 // Account represents a connected social media account
@@ -86,20 +88,21 @@ type PostMetrics struct {
 
 // ContentPipeline represents a content generation pipeline
 type ContentPipeline struct {
-	ID          uuid.UUID         `json:"id"`
-	TenantID    uuid.UUID         `json:"tenant_id"`
-	BusinessID  uuid.UUID         `json:"business_id"`
-	Name        string            `json:"name"`
-	Description string            `json:"description"`
-	AgentID     uuid.UUID         `json:"agent_id"`
-	Schedule    string            `json:"schedule"` // Cron expression
-	Platforms   []Platform        `json:"platforms"`
-	Config      PipelineConfig    `json:"config"`
-	IsActive    bool              `json:"is_active"`
-	LastRunAt   *time.Time        `json:"last_run_at,omitempty"`
-	NextRunAt   *time.Time        `json:"next_run_at,omitempty"`
-	CreatedAt   time.Time         `json:"created_at"`
-	UpdatedAt   time.Time         `json:"updated_at"`
+	ID          uuid.UUID              `json:"id"`
+	TenantID    uuid.UUID              `json:"tenant_id"`
+	BusinessID  uuid.UUID              `json:"business_id"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	AgentID     uuid.UUID              `json:"agent_id"`
+	Schedule    string                 `json:"schedule"` // Cron expression
+	Platforms   []Platform             `json:"platforms"`
+	Accounts    map[Platform]uuid.UUID `json:"accounts,omitempty"` // Account to post from on each platform
+	Config      PipelineConfig         `json:"config"`
+	IsActive    bool                   `json:"is_active"`
+	LastRunAt   *time.Time             `json:"last_run_at,omitempty"`
+	NextRunAt   *time.Time             `json:"next_run_at,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
 }
 
 // PipelineConfig contains configuration for a content pipeline
@@ -122,6 +125,16 @@ type PipelineConfig struct {
 type Service struct {
 	log       *logger.Logger
 	providers map[Platform]Provider
+	generator ContentGenerator
+
+	// Batch publishing and metrics fetching
+	batch   BatchConfig
+	gates   map[Platform]*platformGate
+	batchMu sync.Mutex
+
+	// refreshing serializes token refreshes per account
+	refreshing map[uuid.UUID]*sync.Mutex
+	refreshMu  sync.Mutex
 }
 
 // NewService creates a new social media service
@@ -129,6 +142,10 @@ func NewService(log *logger.Logger) *Service {
 	return &Service{
 		log:       log,
 		providers: make(map[Platform]Provider),
+		batch:     DefaultBatchConfig(),
+		gates:     make(map[Platform]*platformGate),
+
+		refreshing: make(map[uuid.UUID]*sync.Mutex),
 	}
 }
 
@@ -202,6 +219,46 @@ func (s *Service) HandleOAuthCallback(ctx context.Context, tenantID uuid.UUID, p
 // Content Publishing
 // =============================================================================
 
+// tokenRefreshMargin is how long before expiry an access token is refreshed
+const tokenRefreshMargin = 5 * time.Minute
+
+// RefreshIfExpiring refreshes the account's access token through its provider
+// if it expires soon, reporting whether it did. Tokens without an expiry are
+// never refreshed. Refreshes of the same account take turns, so one that
+// waited finds the token already fresh. Callers that persist accounts should
+// save the new tokens when it returns true.
+func (s *Service) RefreshIfExpiring(ctx context.Context, account *Account) (bool, error) {
+	mu := s.refreshLock(account.ID)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if account.TokenExpiry.IsZero() || time.Now().Before(account.TokenExpiry.Add(-tokenRefreshMargin)) {
+		return false, nil
+	}
+
+	provider, ok := s.providers[account.Platform]
+	if !ok {
+		return false, fmt.Errorf("unsupported platform: %s", account.Platform)
+	}
+	if err := provider.RefreshToken(ctx, account); err != nil {
+		return false, fmt.Errorf("failed to refresh token: %w", err)
+	}
+	return true, nil
+}
+
+// refreshLock returns the lock serializing an account's token refreshes
+func (s *Service) refreshLock(accountID uuid.UUID) *sync.Mutex {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	mu, ok := s.refreshing[accountID]
+	if !ok {
+		mu = &sync.Mutex{}
+		s.refreshing[accountID] = mu
+	}
+	return mu
+}
+
 // PublishPost publishes a post to social media
 func (s *Service) PublishPost(ctx context.Context, account *Account, post *Post) error {
 	provider, ok := s.providers[account.Platform]
@@ -209,11 +266,8 @@ func (s *Service) PublishPost(ctx context.Context, account *Account, post *Post)
 		return fmt.Errorf("unsupported platform: %s", account.Platform)
 	}
 
-	// Check if token needs refresh
-	if time.Now().After(account.TokenExpiry.Add(-5 * time.Minute)) {
-		if err := provider.RefreshToken(ctx, account); err != nil {
-			return fmt.Errorf("failed to refresh token: %w", err)
-		}
+	if _, err := s.RefreshIfExpiring(ctx, account); err != nil {
+		return err
 	}
 
 	// Publish the post
@@ -275,30 +329,6 @@ func (s *Service) DeletePost(ctx context.Context, account *Account, post *Post)
 	return nil
 }
 
-// =============================================================================
-// Content Pipeline
-// =============================================================================
-
-// GenerateContent uses an AI agent to generate social media content
-func (s *Service) GenerateContent(ctx context.Context, pipeline *ContentPipeline) ([]*Post, error) {
-	// This would integrate with the agent execution system
-	// to generate content based on the pipeline configuration
-	
-	s.log.Infow("generating content",
-		"pipeline_id", pipeline.ID,
-		"agent_id", pipeline.AgentID,
-		"platforms", pipeline.Platforms,
-	)
-
-	// Placeholder - actual implementation would:
-	// 1. Brief the content agent with pipeline config
-	// 2. Generate content for each platform
-	// 3. Create draft posts
-	// 4. Return posts for review or auto-publish
-
-	return nil, nil
-}
-
 // =============================================================================
 // Analytics
 // =============================================================================
@@ -8,12 +8,20 @@ import (
 	"github.com/google/uuid"
 )
 
+// Token types. A refresh token cannot be used to authenticate requests, and
+// an access token cannot be exchanged for new tokens.
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
 // Claims represents the JWT claims
 type Claims struct {
-	UserID   uuid.UUID `json:"user_id"`
-	TenantID uuid.UUID `json:"tenant_id"`
-	Email    string    `json:"email"`
-	Role     string    `json:"role"`
+	UserID    uuid.UUID `json:"user_id"`
+	TenantID  uuid.UUID `json:"tenant_id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	TokenType string    `json:"token_type"`
 	jwt.RegisteredClaims
 }
 
@@ -38,10 +46,11 @@ func NewJWTManager(secretKey string, accessMinutes, refreshDays int) *JWTManager
 // GenerateAccessToken creates a new access token
 func (m *JWTManager) GenerateAccessToken(userID, tenantID uuid.UUID, email, role string) (string, error) {
 	claims := &Claims{
-		UserID:   userID,
-		TenantID: tenantID,
-		Email:    email,
-		Role:     role,
+		UserID:    userID,
+		TenantID:  tenantID,
+		Email:     email,
+		Role:      role,
+		TokenType: TokenTypeAccess,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(m.accessDuration)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -58,8 +67,9 @@ func (m *JWTManager) GenerateAccessToken(userID, tenantID uuid.UUID, email, role
 // GenerateRefreshToken creates a new refresh token
 func (m *JWTManager) GenerateRefreshToken(userID, tenantID uuid.UUID) (string, error) {
 	claims := &Claims{
-		UserID:   userID,
-		TenantID: tenantID,
+		UserID:    userID,
+		TenantID:  tenantID,
+		TokenType: TokenTypeRefresh,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(m.refreshDuration)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -80,7 +90,7 @@ func (m *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return m.secretKey, nil
-	})
+	}, jwt.WithIssuer(m.issuer))
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
@@ -94,6 +104,27 @@ func (m *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
 	return claims, nil
 }
 
+// ValidateAccessToken validates a token and checks that it is an access token
+func (m *JWTManager) ValidateAccessToken(tokenString string) (*Claims, error) {
+	return m.validateTokenType(tokenString, TokenTypeAccess)
+}
+
+// ValidateRefreshToken validates a token and checks that it is a refresh token
+func (m *JWTManager) ValidateRefreshToken(tokenString string) (*Claims, error) {
+	return m.validateTokenType(tokenString, TokenTypeRefresh)
+}
+
+func (m *JWTManager) validateTokenType(tokenString, tokenType string) (*Claims, error) {
+	claims, err := m.ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != tokenType {
+		return nil, fmt.Errorf("invalid token: expected %s token", tokenType)
+	}
+	return claims, nil
+}
+
 // TokenPair represents an access/refresh token pair
 type TokenPair struct {
 	AccessToken  string    `json:"access_token"`
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/delphi-platform/delphi/backend/pkg/crypto"
+)
+
+const (
+	// MinPasswordLength is the shortest password accepted
+	MinPasswordLength = 10
+
+	// MaxPasswordLength is bcrypt's input limit; longer passwords would be
+	// silently truncated
+	MaxPasswordLength = 72
+
+	// passphraseLength is the length from which a password needs no mix of
+	// character classes
+	passphraseLength = 16
+)
+
+var (
+	// ErrIncorrectPassword is returned when the current password given for a
+	// change does not match
+	ErrIncorrectPassword = errors.New("current password is incorrect")
+
+	// ErrPasswordReused is returned when a new password matches the current one
+	ErrPasswordReused = errors.New("new password must differ from the current password")
+)
+
+// commonPasswords are rejected whatever their length or mix
+var commonPasswords = map[string]bool{
+	"password123": true, "password1234": true, "passw0rd123": true, "qwerty12345": true,
+	"qwertyuiop1": true, "1234567890a": true, "letmein1234": true, "welcome1234": true,
+	"iloveyou123": true, "administrator": true, "changeme123": true,
+}
+
+// ValidatePasswordStrength checks a password against the password policy: at
+// least MinPasswordLength characters, with at least three of lowercase,
+// uppercase, digits and symbols unless it is a passphrase of 16 or more
+// characters, and not a commonly used password
+func ValidatePasswordStrength(password string) error {
+	if len(password) < MinPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	}
+	if len(password) > MaxPasswordLength {
+		return fmt.Errorf("password must be at most %d bytes", MaxPasswordLength)
+	}
+	if commonPasswords[strings.ToLower(password)] {
+		return fmt.Errorf("password is too common")
+	}
+	if len(password) >= passphraseLength {
+		return nil
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			classes++
+		}
+	}
+	if classes < 3 {
+		return fmt.Errorf("password must mix at least three of lowercase, uppercase, digits and symbols, or be at least %d characters", passphraseLength)
+	}
+	return nil
+}
+
+// ChangePasswordHash checks a password change against the current hash and
+// returns the hash of the new password. The old password must match, the new
+// one must meet the policy and must not be the current password.
+func ChangePasswordHash(currentHash, oldPassword, newPassword string) (string, error) {
+	if currentHash == "" || !crypto.CheckPassword(oldPassword, currentHash) {
+		return "", ErrIncorrectPassword
+	}
+	if crypto.CheckPassword(newPassword, currentHash) {
+		return "", ErrPasswordReused
+	}
+	if err := ValidatePasswordStrength(newPassword); err != nil {
+		return "", err
+	}
+
+	hash, err := crypto.HashPassword(newPassword)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return hash, nil
+}
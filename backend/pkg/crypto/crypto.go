@@ -6,20 +6,75 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
-// Encryptor handles AES-256-GCM encryption/decryption
+// Envelope format. A value is encrypted with a fresh data key, which is in
+// turn encrypted ("wrapped") with a key-encryption key. Encrypted values are
+// base64 of:
+//
+//	format version (1 byte) | key version (1 byte) | wrapped data key | nonce | ciphertext
+//
+// The key version names the key-encryption key, so keys can be rotated while
+// values encrypted under earlier keys remain readable.
+const (
+	formatVersion1 byte = 1
+
+	dataKeySize = 32
+	headerSize  = 2
+)
+
+// ErrNoEncryptionKey is returned by a nil Encryptor, so secrets are never
+// stored or read as plaintext when no key is configured
+var ErrNoEncryptionKey = errors.New("no encryption key is configured")
+
+// Encryptor handles AES-256-GCM envelope encryption/decryption
 type Encryptor struct {
-	gcm cipher.AEAD
+	current byte
+	keys    map[byte]cipher.AEAD // key-encryption keys by version
 }
 
-// NewEncryptor creates a new AES-256-GCM encryptor
+// NewEncryptor creates a new AES-256-GCM encryptor whose key has version 1
 // key must be a 32-byte hex-encoded string (64 hex characters)
 func NewEncryptor(hexKey string) (*Encryptor, error) {
+	return NewVersionedEncryptor(hexKey, 1)
+}
+
+// NewVersionedEncryptor creates an encryptor that encrypts with the given key,
+// recorded in each value as version. When rotating keys, give the new key a
+// higher version and register the old one with AddKey.
+func NewVersionedEncryptor(hexKey string, version byte) (*Encryptor, error) {
+	e := &Encryptor{keys: make(map[byte]cipher.AEAD)}
+	if err := e.AddKey(version, hexKey); err != nil {
+		return nil, err
+	}
+	e.current = version
+	return e, nil
+}
+
+// AddKey registers a retired key so values encrypted under it can still be
+// decrypted. Only the encryptor's own key is used to encrypt.
+func (e *Encryptor) AddKey(version byte, hexKey string) error {
+	if version == 0 {
+		return fmt.Errorf("key version must be between 1 and 255")
+	}
+	if _, ok := e.keys[version]; ok {
+		return fmt.Errorf("duplicate key version %d", version)
+	}
+
+	gcm, err := newGCM(hexKey)
+	if err != nil {
+		return err
+	}
+	e.keys[version] = gcm
+	return nil
+}
+
+func newGCM(hexKey string) (cipher.AEAD, error) {
 	key, err := hex.DecodeString(hexKey)
 	if err != nil {
 		return nil, fmt.Errorf("invalid hex key: %w", err)
@@ -29,6 +84,10 @@ func NewEncryptor(hexKey string) (*Encryptor, error) {
 		return nil, fmt.Errorf("key must be 32 bytes (256 bits), got %d bytes", len(key))
 	}
 
+	return newGCMFromKey(key)
+}
+
+func newGCMFromKey(key []byte) (cipher.AEAD, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create cipher: %w", err)
@@ -38,40 +97,113 @@ func NewEncryptor(hexKey string) (*Encryptor, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create GCM: %w", err)
 	}
+	return gcm, nil
+}
+
+// seal encrypts plaintext with a fresh nonce, returning nonce|ciphertext
+func seal(gcm cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
 
-	return &Encryptor{gcm: gcm}, nil
+// open decrypts nonce|ciphertext as written by seal
+func open(gcm cipher.AEAD, data, additionalData []byte) ([]byte, error) {
+	nonceSize := gcm.NonceSize()
+	if len(data) < nonceSize+gcm.Overhead() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	return gcm.Open(nil, data[:nonceSize], data[nonceSize:], additionalData)
 }
 
 // Encrypt encrypts plaintext and returns base64-encoded ciphertext
 func (e *Encryptor) Encrypt(plaintext string) (string, error) {
-	nonce := make([]byte, e.gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	if e == nil {
+		return "", ErrNoEncryptionKey
+	}
+	dataKey, err := GenerateRandomBytes(dataKeySize)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+	dataGCM, err := newGCMFromKey(dataKey)
+	if err != nil {
+		return "", err
 	}
 
-	ciphertext := e.gcm.Seal(nonce, nonce, []byte(plaintext), nil)
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
+	// The header is authenticated with the data key, so a value cannot be
+	// relabelled with another key version
+	header := []byte{formatVersion1, e.current}
+	wrappedKey, err := seal(e.keys[e.current], dataKey, header)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dataGCM, []byte(plaintext), header)
+	if err != nil {
+		return "", err
+	}
+
+	out := make([]byte, 0, headerSize+len(wrappedKey)+len(ciphertext))
+	out = append(out, header...)
+	out = append(out, wrappedKey...)
+	out = append(out, ciphertext...)
+	return base64.StdEncoding.EncodeToString(out), nil
 }
 
-// Decrypt decrypts base64-encoded ciphertext and returns plaintext
+// Decrypt decrypts base64-encoded ciphertext and returns plaintext. Values
+// written before the envelope format, which have no version header, are
+// decrypted with each known key in turn.
 func (e *Encryptor) Decrypt(ciphertext string) (string, error) {
+	if e == nil {
+		return "", ErrNoEncryptionKey
+	}
 	data, err := base64.StdEncoding.DecodeString(ciphertext)
 	if err != nil {
 		return "", fmt.Errorf("failed to decode ciphertext: %w", err)
 	}
 
-	nonceSize := e.gcm.NonceSize()
-	if len(data) < nonceSize {
-		return "", fmt.Errorf("ciphertext too short")
+	if plaintext, ok := e.openEnvelope(data); ok {
+		return string(plaintext), nil
 	}
 
-	nonce, ciphertextBytes := data[:nonceSize], data[nonceSize:]
-	plaintext, err := e.gcm.Open(nil, nonce, ciphertextBytes, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to decrypt: %w", err)
+	for _, gcm := range e.keys {
+		if plaintext, err := open(gcm, data, nil); err == nil {
+			return string(plaintext), nil
+		}
+	}
+	return "", fmt.Errorf("failed to decrypt: no key could decrypt the value")
+}
+
+// openEnvelope decrypts a value in the envelope format. It reports false if
+// data is not one, or was encrypted under an unknown key.
+func (e *Encryptor) openEnvelope(data []byte) ([]byte, bool) {
+	if len(data) < headerSize || data[0] != formatVersion1 {
+		return nil, false
+	}
+	header := data[:headerSize]
+	kek, ok := e.keys[header[1]]
+	if !ok {
+		return nil, false
 	}
 
-	return string(plaintext), nil
+	wrappedSize := kek.NonceSize() + dataKeySize + kek.Overhead()
+	if len(data) < headerSize+wrappedSize {
+		return nil, false
+	}
+	dataKey, err := open(kek, data[headerSize:headerSize+wrappedSize], header)
+	if err != nil {
+		return nil, false
+	}
+	dataGCM, err := newGCMFromKey(dataKey)
+	if err != nil {
+		return nil, false
+	}
+	plaintext, err := open(dataGCM, data[headerSize+wrappedSize:], header)
+	if err != nil {
+		return nil, false
+	}
+	return plaintext, true
 }
 
 // HashPassword creates a bcrypt hash of the password
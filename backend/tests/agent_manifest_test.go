@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Agent Manifest Tests
+// =============================================================================
+
+func TestAgentManifestRoundTrip(t *testing.T) {
+	docs := &models.KnowledgeBase{ID: uuid.New(), Name: "Docs"}
+	runbooks := &models.KnowledgeBase{ID: uuid.New(), Name: "Runbooks"}
+
+	agent := &models.Agent{
+		ID:             uuid.New(),
+		TenantID:       uuid.New(),
+		Name:           "Release Notes Writer",
+		Description:    "Drafts release notes from merged PRs",
+		Type:           models.AgentTypeCoding,
+		Provider:       models.AIProvider("openai"),
+		Model:          "gpt-4o",
+		SystemPrompt:   "You write concise release notes.",
+		Tools:          json.RawMessage(`[{"name":"github"}]`),
+		KnowledgeBases: []uuid.UUID{docs.ID, runbooks.ID},
+		Config: models.AgentConfig{
+			Temperature:    models.Float64(0.3),
+			MaxTokens:      2048,
+			TimeoutSeconds: 120,
+			BriefingDepth:  "quick",
+		},
+	}
+
+	// Export, serialize and read the manifest back as an import would
+	exported := services.NewAgentManifest(agent, []*models.KnowledgeBase{docs, runbooks})
+	data, err := json.Marshal(exported)
+	require.NoError(t, err)
+	for _, id := range []uuid.UUID{agent.ID, agent.TenantID, docs.ID, runbooks.ID} {
+		assert.NotContains(t, string(data), id.String(), "the manifest carries no source tenant IDs")
+	}
+
+	var manifest services.AgentManifest
+	require.NoError(t, json.Unmarshal(data, &manifest))
+	require.NoError(t, manifest.Validate())
+
+	t.Run("leaves out knowledge bases it can't name", func(t *testing.T) {
+		unnamed := services.NewAgentManifest(agent, []*models.KnowledgeBase{docs})
+		assert.Equal(t, []services.ManifestKnowledgeBase{{Name: "Docs"}}, unnamed.KnowledgeBases)
+	})
+
+	t.Run("recreates the agent in the same tenant", func(t *testing.T) {
+		req, warnings := manifest.CreateRequest([]*models.KnowledgeBase{docs, runbooks})
+		assert.Empty(t, warnings)
+
+		assert.Equal(t, agent.Name, req.Name)
+		assert.Equal(t, agent.Description, req.Description)
+		assert.Equal(t, agent.Type, req.Type)
+		assert.Equal(t, agent.Provider, req.Provider)
+		assert.Equal(t, agent.Model, req.Model)
+		assert.Equal(t, agent.SystemPrompt, req.SystemPrompt)
+		assert.JSONEq(t, string(agent.Tools), string(req.Tools))
+		assert.Equal(t, agent.KnowledgeBases, req.KnowledgeBases)
+		assert.Equal(t, agent.Config, req.Config)
+	})
+
+	t.Run("remaps knowledge bases by name and drops missing ones", func(t *testing.T) {
+		otherDocs := &models.KnowledgeBase{ID: uuid.New(), Name: "Docs"}
+
+		req, warnings := manifest.CreateRequest([]*models.KnowledgeBase{otherDocs})
+		assert.Equal(t, []uuid.UUID{otherDocs.ID}, req.KnowledgeBases)
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], "Runbooks")
+	})
+
+	t.Run("rejects other versions", func(t *testing.T) {
+		future := manifest
+		future.Version = services.AgentManifestVersion + 1
+		assert.ErrorContains(t, future.Validate(), "unsupported manifest version")
+	})
+}
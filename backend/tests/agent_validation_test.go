@@ -0,0 +1,250 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/config"
+	"github.com/delphi-platform/delphi/backend/internal/handlers"
+	"github.com/delphi-platform/delphi/backend/internal/middleware"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Agent Validation Tests
+// =============================================================================
+
+func TestValidateAgentConfigNormalizes(t *testing.T) {
+	req := &services.CreateAgentRequest{Name: "Reviewer", Model: "gpt-4-turbo"}
+
+	warnings, err := services.ValidateAgentConfig(req, services.AgentLimits{})
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, models.ProviderOpenAI, req.Provider, "provider is inferred from a known model")
+	assert.Equal(t, models.Float64(0.7), req.Config.Temperature)
+	assert.Equal(t, 4096, req.Config.MaxTokens)
+	assert.Equal(t, 300, req.Config.TimeoutSeconds)
+	assert.Equal(t, "standard", req.Config.BriefingDepth)
+	assert.True(t, req.Config.BriefingRequired)
+}
+
+func TestValidateAgentConfigWarnings(t *testing.T) {
+	t.Run("max_tokens over the model's output is lowered", func(t *testing.T) {
+		req := &services.CreateAgentRequest{Name: "a", Provider: models.ProviderOpenAI, Model: "gpt-4-turbo"}
+		req.Config.MaxTokens = 8192
+		warnings, err := services.ValidateAgentConfig(req, services.AgentLimits{})
+		require.NoError(t, err)
+		assert.Equal(t, 4096, req.Config.MaxTokens)
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], "lowered to 4096")
+	})
+
+	t.Run("unknown models can't be checked", func(t *testing.T) {
+		req := &services.CreateAgentRequest{Name: "a", Provider: models.ProviderOpenAI, Model: "gpt-9"}
+		warnings, err := services.ValidateAgentConfig(req, services.AgentLimits{})
+		require.NoError(t, err)
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], "not a known openai model")
+	})
+
+	t.Run("local models are not checked", func(t *testing.T) {
+		req := &services.CreateAgentRequest{Name: "a", Provider: models.ProviderOllama, Model: "llama3"}
+		warnings, err := services.ValidateAgentConfig(req, services.AgentLimits{})
+		require.NoError(t, err)
+		assert.Empty(t, warnings)
+	})
+}
+
+func TestValidateAgentConfigRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     services.CreateAgentRequest
+		wantErr string
+	}{
+		{"missing name", services.CreateAgentRequest{Model: "gpt-4o"}, "name is required"},
+		{"missing model", services.CreateAgentRequest{Name: "a"}, "model is required"},
+		{"unknown model without provider", services.CreateAgentRequest{Name: "a", Model: "gpt-9"}, "provider is required"},
+		{"unsupported provider", services.CreateAgentRequest{Name: "a", Provider: "acme", Model: "gpt-4o"}, "unsupported provider: acme"},
+		{"wrong provider", services.CreateAgentRequest{Name: "a", Provider: models.ProviderOpenAI, Model: "claude-sonnet-4-20250514"}, "model claude-sonnet-4-20250514 is served by anthropic, not openai"},
+		{"temperature too high", services.CreateAgentRequest{Name: "a", Model: "gpt-4o", Config: models.AgentConfig{Temperature: models.Float64(2.5)}}, "temperature must be between 0 and 2"},
+		{"negative max_tokens", services.CreateAgentRequest{Name: "a", Model: "gpt-4o", Config: models.AgentConfig{MaxTokens: -1}}, "max_tokens must not be negative"},
+		{"tools on a model without function calling", services.CreateAgentRequest{Name: "a", Model: "o1", Tools: json.RawMessage(`["web_search"]`)}, "model o1 does not support function_calling"},
+		{"capabilities the model lacks", services.CreateAgentRequest{Name: "a", Model: "o1", Tools: json.RawMessage(`[{"name": "web_search"}]`), Config: models.AgentConfig{RequiredCapabilities: []string{"vision"}}}, "model o1 does not support vision, function_calling"},
+		{"unknown capability", services.CreateAgentRequest{Name: "a", Model: "gpt-4o", Config: models.AgentConfig{RequiredCapabilities: []string{"telepathy"}}}, "unknown capability: telepathy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := services.ValidateAgentConfig(&tt.req, services.AgentLimits{})
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+
+	t.Run("system prompt over the context window", func(t *testing.T) {
+		req := &services.CreateAgentRequest{Name: "a", Model: "gpt-4o", SystemPrompt: strings.Repeat("word ", 130000)}
+		_, err := services.ValidateAgentConfig(req, services.AgentLimits{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "context window")
+	})
+}
+
+func TestCheckAgentConfigBounds(t *testing.T) {
+	limits := services.AgentLimits{MaxTimeoutSeconds: 1800}
+	strict := services.AgentLimits{MaxTimeoutSeconds: 1800, Strict: true}
+
+	t.Run("max_tokens is bounded by each model's output", func(t *testing.T) {
+		cases := []struct {
+			model     string
+			maxOutput int
+		}{
+			{"gpt-4-turbo", 4096},
+			{"gpt-4o", 16384},
+			{"claude-sonnet-4-20250514", 64000},
+			{"gemini-2.5-pro", 65536},
+		}
+		for _, c := range cases {
+			config := models.AgentConfig{MaxTokens: c.maxOutput}
+			warnings, err := services.CheckAgentConfigBounds(c.model, &config, strict)
+			require.NoError(t, err, c.model)
+			assert.Empty(t, warnings, "%s's maximum is allowed", c.model)
+
+			config = models.AgentConfig{MaxTokens: c.maxOutput + 1}
+			warnings, err = services.CheckAgentConfigBounds(c.model, &config, limits)
+			require.NoError(t, err, c.model)
+			assert.Equal(t, c.maxOutput, config.MaxTokens, "%s lowers max_tokens", c.model)
+			assert.Len(t, warnings, 1)
+
+			config = models.AgentConfig{MaxTokens: c.maxOutput + 1}
+			_, err = services.CheckAgentConfigBounds(c.model, &config, strict)
+			var invalid *services.ValidationError
+			require.ErrorAs(t, err, &invalid, c.model)
+			assert.Equal(t, []services.FieldError{{Field: "max_tokens", Message: fmt.Sprintf("max_tokens must be at most %d, the most %s can produce", c.maxOutput, c.model)}}, invalid.Fields)
+		}
+	})
+
+	t.Run("max_tokens of unknown models is not bounded", func(t *testing.T) {
+		config := models.AgentConfig{MaxTokens: 1000000}
+		warnings, err := services.CheckAgentConfigBounds("llama3", &config, strict)
+		require.NoError(t, err)
+		assert.Empty(t, warnings)
+	})
+
+	t.Run("timeout_seconds is brought within limits", func(t *testing.T) {
+		config := models.AgentConfig{TimeoutSeconds: 5}
+		warnings, err := services.CheckAgentConfigBounds("gpt-4o", &config, limits)
+		require.NoError(t, err)
+		assert.Equal(t, 10, config.TimeoutSeconds)
+		assert.Len(t, warnings, 1)
+
+		config = models.AgentConfig{TimeoutSeconds: 3600}
+		warnings, err = services.CheckAgentConfigBounds("gpt-4o", &config, limits)
+		require.NoError(t, err)
+		assert.Equal(t, 1800, config.TimeoutSeconds)
+		assert.Len(t, warnings, 1)
+
+		config = models.AgentConfig{TimeoutSeconds: 3600}
+		_, err = services.CheckAgentConfigBounds("gpt-4o", &config, services.AgentLimits{})
+		require.NoError(t, err, "timeouts are uncapped without a maximum")
+		assert.Equal(t, 3600, config.TimeoutSeconds)
+
+		config = models.AgentConfig{TimeoutSeconds: 3600}
+		_, err = services.CheckAgentConfigBounds("gpt-4o", &config, strict)
+		assert.EqualError(t, err, "timeout_seconds must be at most 1800")
+	})
+
+	t.Run("every field out of range is reported", func(t *testing.T) {
+		config := models.AgentConfig{Temperature: models.Float64(5), TopP: 1.5, MaxTokens: -1, TimeoutSeconds: -1}
+		_, err := services.CheckAgentConfigBounds("gpt-4o", &config, limits)
+		var invalid *services.ValidationError
+		require.ErrorAs(t, err, &invalid)
+
+		fields := make([]string, len(invalid.Fields))
+		for i, field := range invalid.Fields {
+			fields[i] = field.Field
+		}
+		assert.Equal(t, []string{"temperature", "top_p", "max_tokens", "timeout_seconds"}, fields)
+		assert.Equal(t, "temperature must be between 0 and 2; top_p must be between 0 and 1; max_tokens must not be negative; timeout_seconds must not be negative", err.Error())
+	})
+
+	t.Run("in-range values are kept", func(t *testing.T) {
+		config := models.AgentConfig{Temperature: models.Float64(2), TopP: 1, MaxTokens: 1000, TimeoutSeconds: 60}
+		warnings, err := services.CheckAgentConfigBounds("claude-sonnet-4-20250514", &config, strict)
+		require.NoError(t, err)
+		assert.Empty(t, warnings)
+		assert.Equal(t, models.AgentConfig{Temperature: models.Float64(2), TopP: 1, MaxTokens: 1000, TimeoutSeconds: 60}, config)
+	})
+}
+
+func TestCheckAgentCapabilities(t *testing.T) {
+	tools := json.RawMessage(`["web_search"]`)
+
+	assert.NoError(t, services.CheckAgentCapabilities("gpt-4o", tools, models.AgentConfig{RequiredCapabilities: []string{"vision"}}))
+	assert.NoError(t, services.CheckAgentCapabilities("o1", json.RawMessage(`[]`), models.AgentConfig{}), "agents without tools don't need function calling")
+	assert.NoError(t, services.CheckAgentCapabilities("llama3", tools, models.AgentConfig{}), "local models are not checked")
+
+	config := models.AgentConfig{FallbackChain: []models.ModelChoice{{Provider: models.ProviderOpenAI, Model: "o1-mini"}}}
+	assert.EqualError(t, services.CheckAgentCapabilities("gpt-4o", tools, config), "fallback model o1-mini does not support function_calling")
+}
+
+// With the provider configured for the platform, a dry run needs no database
+func TestAgentCreateDryRun(t *testing.T) {
+	svc := services.NewAgentService(&config.Config{OpenAIAPIKey: "sk-test"}, nil, nil, logger.New())
+	handler := handlers.NewAgentHandler(svc, logger.New())
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/agents?dry_run=true", strings.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), middleware.TenantIDKey, uuid.New()))
+		w := httptest.NewRecorder()
+		handler.Create(w, req)
+		return w
+	}
+
+	w := post(`{"name": "Reviewer", "model": "gpt-4-turbo", "config": {"max_tokens": 10000}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var validation services.AgentValidation
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &validation))
+	assert.Equal(t, models.ProviderOpenAI, validation.Agent.Provider)
+	assert.Equal(t, 4096, validation.Agent.Config.MaxTokens)
+	assert.Len(t, validation.Warnings, 1)
+
+	w = post(`{"name": "Reviewer", "model": "gpt-4o", "config": {"temperature": 3}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "temperature must be between 0 and 2")
+}
+
+func TestAgentCreateStrict(t *testing.T) {
+	svc := services.NewAgentService(&config.Config{OpenAIAPIKey: "sk-test"}, nil, nil, logger.New())
+	handler := handlers.NewAgentHandler(svc, logger.New())
+
+	req := httptest.NewRequest(http.MethodPost, "/agents?dry_run=true&strict=true",
+		strings.NewReader(`{"name": "Reviewer", "model": "gpt-4-turbo", "config": {"max_tokens": 10000, "top_p": 2}}`))
+	req = req.WithContext(context.WithValue(req.Context(), middleware.TenantIDKey, uuid.New()))
+	w := httptest.NewRecorder()
+	handler.Create(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	var body struct {
+		Error struct {
+			Code    string `json:"code"`
+			Details struct {
+				Fields []services.FieldError `json:"fields"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "VALIDATION_ERROR", body.Error.Code)
+	assert.Equal(t, []services.FieldError{
+		{Field: "top_p", Message: "top_p must be between 0 and 1"},
+		{Field: "max_tokens", Message: "max_tokens must be at most 4096, the most gpt-4-turbo can produce"},
+	}, body.Error.Details.Fields)
+}
@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/apierror"
+	"github.com/delphi-platform/delphi/backend/internal/middleware"
+	"github.com/delphi-platform/delphi/backend/internal/security"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// API Error Tests
+// =============================================================================
+
+func TestErrorCodes(t *testing.T) {
+	cases := []struct {
+		status  int
+		message string
+		code    apierror.Code
+	}{
+		{http.StatusNotFound, "agent not found", apierror.CodeAgentNotFound},
+		{http.StatusNotFound, "Agent not found", apierror.CodeAgentNotFound},
+		{http.StatusNotFound, "run not found", apierror.CodeExecutionNotFound},
+		{http.StatusNotFound, "execution not found", apierror.CodeExecutionNotFound},
+		{http.StatusNotFound, "repository not found", apierror.CodeNotFound},
+		{http.StatusPaymentRequired, "budget exceeded: daily cost limit of $10.00 reached", apierror.CodeBudgetExceeded},
+		{http.StatusPaymentRequired, "tenant is restricted for non-payment", apierror.CodeBillingRestricted},
+		{http.StatusBadRequest, "Provider 'openai' not configured. Please set OPENAI_API_KEY environment variable.", apierror.CodeProviderUnconfigured},
+		{http.StatusNotFound, "OLLAMA_BASE_URL not set", apierror.CodeProviderUnconfigured},
+		{http.StatusBadRequest, "prompt blocked by guardrails: pii", apierror.CodeGuardrailBlocked},
+		{http.StatusTooManyRequests, "max concurrent runs reached: 2 running, limit 2", apierror.CodeConcurrencyLimited},
+		{http.StatusTooManyRequests, "rate limit exceeded", apierror.CodeRateLimited},
+		{http.StatusBadRequest, "invalid request body", apierror.CodeBadRequest},
+		{http.StatusGatewayTimeout, "AI execution failed: timeout", apierror.CodeTimeout},
+		{http.StatusInternalServerError, "failed to create agent", apierror.CodeInternal},
+		{http.StatusHTTPVersionNotSupported, "unsupported", apierror.CodeInternal},
+		{http.StatusTeapot, "teapot", apierror.CodeBadRequest},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.code, apierror.CodeFor(c.status, c.message), "%d %q", c.status, c.message)
+	}
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	rec := httptest.NewRecorder()
+	apierror.Write(rec, http.StatusConflict, apierror.WithCode(apierror.CodeSlugTaken, "slug already taken",
+		map[string]string{"suggested_slug": "acme-2"}))
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var body struct {
+		Error struct {
+			Code    string            `json:"code"`
+			Message string            `json:"message"`
+			Details map[string]string `json:"details"`
+		} `json:"error"`
+		Message string `json:"message"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "SLUG_TAKEN", body.Error.Code)
+	assert.Equal(t, "slug already taken", body.Error.Message)
+	assert.Equal(t, "acme-2", body.Error.Details["suggested_slug"])
+	assert.Equal(t, body.Error.Message, body.Message, "the message is repeated at the top level")
+
+	t.Run("omits empty details", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		apierror.Write(rec, http.StatusNotFound, apierror.New(http.StatusNotFound, "agent not found"))
+		assert.JSONEq(t, `{"error": {"code": "AGENT_NOT_FOUND", "message": "agent not found"}, "message": "agent not found"}`, rec.Body.String())
+	})
+}
+
+func TestMiddlewareErrorEnvelope(t *testing.T) {
+	rbac := security.NewRBAC(logger.New())
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	rec := serve(withRole(string(security.RoleViewer), middleware.RequirePermission(rbac, security.PermAgentDelete)(ok)), http.MethodDelete, "/")
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var body apierror.Response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, apierror.CodeForbidden, body.Error.Code)
+	assert.Contains(t, body.Message, string(security.PermAgentDelete))
+}
@@ -0,0 +1,180 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/handlers"
+	"github.com/delphi-platform/delphi/backend/internal/middleware"
+	"github.com/delphi-platform/delphi/backend/internal/security"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Audit Export Tests
+// =============================================================================
+
+// pagedAuditStorage serves its entries a page at a time, recording the
+// queries it is sent
+type pagedAuditStorage struct {
+	mu      sync.Mutex
+	entries []*security.AuditEntry
+	queries []security.AuditQuery
+}
+
+func (s *pagedAuditStorage) Store(ctx context.Context, entry *security.AuditEntry) error {
+	return nil
+}
+
+func (s *pagedAuditStorage) Query(ctx context.Context, query security.AuditQuery) ([]*security.AuditEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queries = append(s.queries, query)
+	if query.Offset >= len(s.entries) {
+		return nil, nil
+	}
+	return s.entries[query.Offset:min(query.Offset+query.Limit, len(s.entries))], nil
+}
+
+func (s *pagedAuditStorage) GetByID(ctx context.Context, id uuid.UUID) (*security.AuditEntry, error) {
+	return nil, nil
+}
+
+// exportAudit requests an audit export with the query string for tenantID
+func exportAudit(t *testing.T, storage *pagedAuditStorage, tenantID uuid.UUID, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	svc := services.NewAuditServiceWithStorage(storage, logger.New())
+	t.Cleanup(svc.Stop)
+	handler := handlers.NewAuditHandler(svc, logger.New())
+
+	req := httptest.NewRequest(http.MethodGet, "/audit/export?"+query, nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.TenantIDKey, tenantID))
+	rec := httptest.NewRecorder()
+	handler.Export(rec, req)
+	return rec
+}
+
+func TestAuditExportEscapesCSV(t *testing.T) {
+	userID := uuid.New()
+	entry := &security.AuditEntry{
+		ID:           uuid.New(),
+		UserID:       &userID,
+		Action:       security.AuditActionSettingsChanged,
+		Severity:     security.SeverityInfo,
+		ResourceType: "tenant",
+		UserAgent:    "Mozilla/5.0 (X11, Linux), \"quoted\"\nsecond line",
+		Details:      map[string]interface{}{"field": "name", "old": "Acme, Inc.", "new": "Acme \"West\""},
+		Timestamp:    time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+	storage := &pagedAuditStorage{entries: []*security.AuditEntry{entry}}
+
+	rec := exportAudit(t, storage, uuid.New(), "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/csv", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), "audit_logs.csv")
+
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	require.NoError(t, err, "the export is valid CSV")
+	require.Len(t, rows, 2, "a header and one row; the newline stays inside its field")
+	header, row := rows[0], rows[1]
+	require.Len(t, row, len(header))
+	column := func(name string) string {
+		for i, h := range header {
+			if h == name {
+				return row[i]
+			}
+		}
+		t.Fatalf("no %s column", name)
+		return ""
+	}
+
+	assert.Equal(t, entry.ID.String(), column("id"))
+	assert.Equal(t, "2026-03-01T12:00:00Z", column("timestamp"))
+	assert.Equal(t, userID.String(), column("user_id"))
+	assert.Empty(t, column("agent_id"))
+	assert.Equal(t, entry.UserAgent, column("user_agent"), "commas, quotes and newlines round-trip")
+
+	var details map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(column("details")), &details), "details are encoded as JSON")
+	assert.Equal(t, entry.Details, details)
+}
+
+func TestAuditExportWritesNDJSON(t *testing.T) {
+	// More than one page of entries
+	storage := &pagedAuditStorage{}
+	for i := 0; i < 501; i++ {
+		storage.entries = append(storage.entries, &security.AuditEntry{
+			ID:        uuid.New(),
+			Action:    security.AuditActionLogin,
+			Severity:  security.SeverityInfo,
+			UserAgent: "line one\nline two",
+		})
+	}
+
+	rec := exportAudit(t, storage, uuid.New(), "format=json")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+
+	scanner := bufio.NewScanner(rec.Body)
+	var ids []uuid.UUID
+	for scanner.Scan() {
+		var entry security.AuditEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry), "each line is one JSON entry")
+		assert.Equal(t, "line one\nline two", entry.UserAgent)
+		ids = append(ids, entry.ID)
+	}
+	require.Len(t, ids, len(storage.entries), "one line per entry")
+	assert.Equal(t, storage.entries[500].ID, ids[500])
+	assert.Len(t, storage.queries, 2, "entries are read a page at a time")
+}
+
+func TestAuditExportPassesFiltersThrough(t *testing.T) {
+	storage := &pagedAuditStorage{}
+	tenantID, userID, resourceID := uuid.New(), uuid.New(), uuid.New()
+
+	rec := exportAudit(t, storage, tenantID, strings.Join([]string{
+		"action=auth.login,auth.logout",
+		"severity=warning",
+		"resource_type=user",
+		"resource_id=" + resourceID.String(),
+		"user_id=" + userID.String(),
+		"from=2026-01-01T00:00:00Z",
+		"to=2026-02-01T00:00:00Z",
+	}, "&"))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	require.Len(t, storage.queries, 1)
+	query := storage.queries[0]
+	assert.Equal(t, tenantID, query.TenantID, "exports are scoped to the caller's tenant")
+	assert.Equal(t, []security.AuditAction{security.AuditActionLogin, security.AuditActionLogout}, query.Actions)
+	require.NotNil(t, query.Severity)
+	assert.Equal(t, security.SeverityWarning, *query.Severity)
+	assert.Equal(t, "user", query.ResourceType)
+	assert.Equal(t, &resourceID, query.ResourceID)
+	assert.Equal(t, &userID, query.UserID)
+	require.NotNil(t, query.StartTime)
+	assert.True(t, query.StartTime.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)))
+	require.NotNil(t, query.EndTime)
+	assert.True(t, query.EndTime.Equal(time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestAuditExportRejectsBadRequests(t *testing.T) {
+	for _, query := range []string{"format=xml", "severity=loud", "from=yesterday"} {
+		storage := &pagedAuditStorage{}
+		rec := exportAudit(t, storage, uuid.New(), query)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+		assert.Empty(t, storage.queries, query)
+	}
+}
@@ -0,0 +1,168 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/pkg/auth"
+	"github.com/delphi-platform/delphi/backend/pkg/crypto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// JWT Tests
+// =============================================================================
+
+func TestJWTTokenPairClaims(t *testing.T) {
+	manager := auth.NewJWTManager("test-secret", 60, 7)
+	userID, tenantID := uuid.New(), uuid.New()
+
+	tokens, err := manager.GenerateTokenPair(userID, tenantID, "user@example.com", "owner")
+	require.NoError(t, err)
+
+	claims, err := manager.ValidateAccessToken(tokens.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, userID, claims.UserID)
+	assert.Equal(t, tenantID, claims.TenantID)
+	assert.Equal(t, "owner", claims.Role)
+
+	refresh, err := manager.ValidateRefreshToken(tokens.RefreshToken)
+	require.NoError(t, err)
+	assert.Equal(t, userID, refresh.UserID)
+	assert.Equal(t, tenantID, refresh.TenantID)
+}
+
+func TestJWTTokenTypesAreNotInterchangeable(t *testing.T) {
+	manager := auth.NewJWTManager("test-secret", 60, 7)
+	tokens, err := manager.GenerateTokenPair(uuid.New(), uuid.New(), "user@example.com", "owner")
+	require.NoError(t, err)
+
+	_, err = manager.ValidateAccessToken(tokens.RefreshToken)
+	assert.Error(t, err, "a refresh token must not authenticate requests")
+
+	_, err = manager.ValidateRefreshToken(tokens.AccessToken)
+	assert.Error(t, err, "an access token must not be exchanged for new tokens")
+}
+
+func TestJWTRejectsOtherSecret(t *testing.T) {
+	tokens, err := auth.NewJWTManager("test-secret", 60, 7).
+		GenerateTokenPair(uuid.New(), uuid.New(), "user@example.com", "owner")
+	require.NoError(t, err)
+
+	_, err = auth.NewJWTManager("other-secret", 60, 7).ValidateAccessToken(tokens.AccessToken)
+	assert.Error(t, err)
+}
+
+// =============================================================================
+// Password Tests
+// =============================================================================
+
+func TestValidatePasswordStrength(t *testing.T) {
+	assert.NoError(t, auth.ValidatePasswordStrength("Tr1cky-Horse"))
+	assert.NoError(t, auth.ValidatePasswordStrength("correct horse battery staple"), "long passphrases need no character mix")
+
+	assert.Error(t, auth.ValidatePasswordStrength("Sh0rt!"), "too short")
+	assert.Error(t, auth.ValidatePasswordStrength("alllowercase"), "one character class")
+	assert.Error(t, auth.ValidatePasswordStrength("Password123"), "too common")
+}
+
+func TestChangePasswordHash(t *testing.T) {
+	current, err := crypto.HashPassword("Old-Passw0rd")
+	require.NoError(t, err)
+
+	hash, err := auth.ChangePasswordHash(current, "Old-Passw0rd", "New-Passw0rd!")
+	require.NoError(t, err)
+	assert.True(t, crypto.CheckPassword("New-Passw0rd!", hash))
+	assert.False(t, crypto.CheckPassword("Old-Passw0rd", hash))
+}
+
+func TestChangePasswordHashWrongOldPassword(t *testing.T) {
+	current, err := crypto.HashPassword("Old-Passw0rd")
+	require.NoError(t, err)
+
+	_, err = auth.ChangePasswordHash(current, "not-the-password", "New-Passw0rd!")
+	assert.ErrorIs(t, err, auth.ErrIncorrectPassword)
+
+	_, err = auth.ChangePasswordHash("", "", "New-Passw0rd!")
+	assert.ErrorIs(t, err, auth.ErrIncorrectPassword, "users without a password cannot change it")
+}
+
+func TestChangePasswordHashWeakNewPassword(t *testing.T) {
+	current, err := crypto.HashPassword("Old-Passw0rd")
+	require.NoError(t, err)
+
+	_, err = auth.ChangePasswordHash(current, "Old-Passw0rd", "weak")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, auth.ErrIncorrectPassword)
+}
+
+func TestChangePasswordHashRejectsReuse(t *testing.T) {
+	current, err := crypto.HashPassword("Old-Passw0rd")
+	require.NoError(t, err)
+
+	_, err = auth.ChangePasswordHash(current, "Old-Passw0rd", "Old-Passw0rd")
+	assert.ErrorIs(t, err, auth.ErrPasswordReused)
+}
+
+// =============================================================================
+// MFA Tests
+// =============================================================================
+
+// rfcSecret is the RFC 6238 test key "12345678901234567890" in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeMatchesRFCVectors(t *testing.T) {
+	for unix, want := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+	} {
+		code, err := auth.TOTPCode(rfcSecret, time.Unix(unix, 0))
+		require.NoError(t, err)
+		assert.Equal(t, want, code, "time %d", unix)
+	}
+}
+
+func TestValidateTOTPToleratesOneStepOfDrift(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	code, err := auth.TOTPCode(rfcSecret, now)
+	require.NoError(t, err)
+
+	step, ok := auth.ValidateTOTP(rfcSecret, code, now)
+	assert.True(t, ok)
+
+	driftedStep, ok := auth.ValidateTOTP(rfcSecret, code, now.Add(30*time.Second))
+	assert.True(t, ok, "a code from the previous step is accepted")
+	assert.Equal(t, step, driftedStep, "the matched step identifies the code")
+
+	_, ok = auth.ValidateTOTP(rfcSecret, code, now.Add(2*time.Minute))
+	assert.False(t, ok, "codes outside the window are rejected")
+
+	_, ok = auth.ValidateTOTP(rfcSecret, "000000", now)
+	assert.False(t, ok)
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := auth.GenerateRecoveryCodes(auth.RecoveryCodeCount)
+	require.NoError(t, err)
+	require.Len(t, codes, auth.RecoveryCodeCount)
+	assert.Regexp(t, `^[0-9a-f]{5}-[0-9a-f]{5}$`, codes[0])
+
+	hash := auth.HashRecoveryCode(codes[0])
+	assert.Equal(t, hash, auth.HashRecoveryCode(strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))),
+		"codes match without the dash and in any case")
+	assert.NotEqual(t, hash, auth.HashRecoveryCode(codes[1]))
+}
+
+func TestTOTPURL(t *testing.T) {
+	secret, err := auth.GenerateTOTPSecret()
+	require.NoError(t, err)
+
+	u := auth.TOTPURL("Delphi", "user@example.com", secret)
+	assert.True(t, strings.HasPrefix(u, "otpauth://totp/Delphi:user@example.com?"))
+	assert.Contains(t, u, "secret="+secret)
+	assert.Contains(t, u, "issuer=Delphi")
+}
@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/config"
+	"github.com/delphi-platform/delphi/backend/internal/handlers"
+	"github.com/delphi-platform/delphi/backend/internal/middleware"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// Batch Execution Tests
+// =============================================================================
+
+func batchRuns(statuses ...models.RunStatus) []*models.AgentRun {
+	runs := make([]*models.AgentRun, len(statuses))
+	for i, status := range statuses {
+		runs[i] = &models.AgentRun{ID: uuid.New(), Status: status}
+		if status == models.RunStatusCompleted {
+			runs[i].TokensUsed = 1500
+			runs[i].Cost = 0.015
+		}
+	}
+	return runs
+}
+
+func TestSummarizeBatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		items    int
+		runs     []*models.AgentRun
+		want     string
+		rejected int
+	}{
+		{"running while any run is", 3, batchRuns(models.RunStatusCompleted, models.RunStatusRunning, models.RunStatusPending), services.BatchStatusRunning, 0},
+		{"completed when every item is", 2, batchRuns(models.RunStatusCompleted, models.RunStatusCompleted), services.BatchStatusCompleted, 0},
+		{"partial when some runs failed", 2, batchRuns(models.RunStatusCompleted, models.RunStatusFailed), services.BatchStatusPartial, 0},
+		{"partial when items were refused", 3, batchRuns(models.RunStatusCompleted, models.RunStatusCompleted), services.BatchStatusPartial, 1},
+		{"failed when nothing completed", 2, batchRuns(models.RunStatusFailed, models.RunStatusCancelled), services.BatchStatusFailed, 0},
+		{"failed when every item was refused", 2, nil, services.BatchStatusFailed, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch := &models.RunBatch{ID: uuid.New(), ItemCount: tt.items, CreatedAt: time.Now()}
+			status := services.SummarizeBatch(batch, tt.runs)
+			assert.Equal(t, tt.want, status.Status)
+			assert.Equal(t, tt.items, status.Total)
+			assert.Equal(t, tt.rejected, status.Rejected)
+			assert.NotNil(t, status.Runs)
+		})
+	}
+
+	status := services.SummarizeBatch(&models.RunBatch{ItemCount: 3},
+		batchRuns(models.RunStatusCompleted, models.RunStatusCompleted, models.RunStatusFailed))
+	assert.Equal(t, 3000, status.TokensUsed)
+	assert.InDelta(t, 0.03, status.Cost, 1e-9)
+	assert.Equal(t, map[models.RunStatus]int{models.RunStatusCompleted: 2, models.RunStatusFailed: 1}, status.Counts)
+}
+
+// Batches are checked for size before anything is stored, so these need no database
+func TestCreateBatchRejectsBadRequests(t *testing.T) {
+	svc := services.NewExecuteService(&config.Config{}, nil, nil, nil, nil, nil, nil, nil, nil, nil, logger.New())
+	handler := handlers.NewExecuteHandler(svc, logger.New())
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/execute/batch", strings.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), middleware.TenantIDKey, uuid.New()))
+		w := httptest.NewRecorder()
+		handler.CreateBatch(w, req)
+		return w
+	}
+
+	w := post(`{"items": []}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "items are required")
+
+	items := make([]string, 101)
+	for i := range items {
+		items[i] = fmt.Sprintf(`{"agent_id": %q, "prompt": "item %d"}`, uuid.New(), i)
+	}
+	w = post(`{"items": [` + strings.Join(items, ",") + `]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "at most 100 items")
+}
@@ -0,0 +1,217 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/billing"
+	"github.com/delphi-platform/delphi/backend/internal/config"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/notifications"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stripe/stripe-go/v76"
+	"github.com/stripe/stripe-go/v76/webhook"
+)
+
+// =============================================================================
+// Billing Tests
+// =============================================================================
+
+const testStripeSecret = "whsec_test"
+
+// memoryBilling is an in-memory billing.TenantStore and billing.EventStore
+// that also records the notifications sent
+type memoryBilling struct {
+	tenants       map[uuid.UUID]*models.Tenant
+	processed     map[string]bool
+	failUpdate    bool
+	notifications []*notifications.Notification
+}
+
+func (m *memoryBilling) GetByID(ctx context.Context, id uuid.UUID) (*models.Tenant, error) {
+	return m.tenants[id], nil
+}
+
+func (m *memoryBilling) Update(ctx context.Context, tenant *models.Tenant) error {
+	if m.failUpdate {
+		return assert.AnError
+	}
+	m.tenants[tenant.ID] = tenant
+	return nil
+}
+
+func (m *memoryBilling) MarkProcessed(ctx context.Context, eventID, eventType string) (bool, error) {
+	if m.processed[eventID] {
+		return false, nil
+	}
+	m.processed[eventID] = true
+	return true, nil
+}
+
+func (m *memoryBilling) Unmark(ctx context.Context, eventID string) error {
+	delete(m.processed, eventID)
+	return nil
+}
+
+func (m *memoryBilling) Send(ctx context.Context, notification *notifications.Notification) error {
+	m.notifications = append(m.notifications, notification)
+	return nil
+}
+
+func newStripeWebhooks(t *testing.T, secret string) (*services.WebhookService, *memoryBilling, *models.Tenant) {
+	t.Helper()
+	tenant := &models.Tenant{ID: uuid.New(), Plan: models.PlanFree, Settings: json.RawMessage(`{"timezone":"UTC"}`)}
+	store := &memoryBilling{
+		tenants:   map[uuid.UUID]*models.Tenant{tenant.ID: tenant},
+		processed: make(map[string]bool),
+	}
+	billingService := billing.NewService("", "price_pro", "price_enterprise", nil, store, logger.New())
+	billingService.SetStores(store, store)
+	cfg := &config.Config{StripeWebhookSecret: secret}
+	return services.NewWebhookService(cfg, nil, billingService, nil, logger.New()), store, tenant
+}
+
+// stripeEvent returns the payload of a Stripe event of the given type about object
+func stripeEvent(t *testing.T, id, eventType string, object map[string]interface{}) []byte {
+	t.Helper()
+	payload, err := json.Marshal(map[string]interface{}{
+		"id":          id,
+		"object":      "event",
+		"type":        eventType,
+		"api_version": stripe.APIVersion,
+		"data":        map[string]interface{}{"object": object},
+	})
+	require.NoError(t, err)
+	return payload
+}
+
+func signStripe(payload []byte, secret string, at time.Time) string {
+	return webhook.GenerateTestSignedPayload(&webhook.UnsignedPayload{Payload: payload, Secret: secret, Timestamp: at}).Header
+}
+
+func stripeSubscription(tenantID uuid.UUID, status, priceID string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":       "sub_1",
+		"object":   "subscription",
+		"status":   status,
+		"customer": "cus_1",
+		"metadata": map[string]string{"tenant_id": tenantID.String()},
+		"items": map[string]interface{}{
+			"object": "list",
+			"data":   []interface{}{map[string]interface{}{"id": "si_1", "price": map[string]string{"id": priceID}}},
+		},
+	}
+}
+
+func stripeInvoice(tenantID uuid.UUID, nextPaymentAttempt interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                   "in_1",
+		"object":               "invoice",
+		"amount_due":           4900,
+		"currency":             "usd",
+		"next_payment_attempt": nextPaymentAttempt,
+		"customer": map[string]interface{}{
+			"id":       "cus_1",
+			"object":   "customer",
+			"metadata": map[string]string{"tenant_id": tenantID.String()},
+		},
+	}
+}
+
+func TestStripeWebhookSignatureIsVerified(t *testing.T) {
+	ctx := context.Background()
+	webhooks, store, tenant := newStripeWebhooks(t, testStripeSecret)
+	payload := stripeEvent(t, "evt_1", "customer.subscription.updated", stripeSubscription(tenant.ID, "active", "price_pro"))
+
+	cases := map[string]string{
+		"unsigned":      "",
+		"wrong secret":  signStripe(payload, "whsec_other", time.Now()),
+		"stale":         signStripe(payload, testStripeSecret, time.Now().Add(-10*time.Minute)),
+		"other payload": signStripe([]byte(`{}`), testStripeSecret, time.Now()),
+		"malformed":     "t=abc,v1=xyz",
+	}
+	for name, signature := range cases {
+		err := webhooks.HandleStripe(ctx, signature, payload)
+		assert.ErrorIs(t, err, services.ErrInvalidWebhookSignature, name)
+	}
+	assert.Equal(t, models.PlanFree, store.tenants[tenant.ID].Plan, "forged events change nothing")
+	assert.Empty(t, store.processed)
+
+	unconfigured, _, _ := newStripeWebhooks(t, "")
+	err := unconfigured.HandleStripe(ctx, signStripe(payload, "", time.Now()), payload)
+	assert.ErrorIs(t, err, services.ErrInvalidWebhookSignature, "no secret accepts nothing")
+
+	require.NoError(t, webhooks.HandleStripe(ctx, signStripe(payload, testStripeSecret, time.Now()), payload))
+	assert.Equal(t, models.PlanPro, store.tenants[tenant.ID].Plan)
+}
+
+func TestStripeSubscriptionsSyncTenantPlan(t *testing.T) {
+	ctx := context.Background()
+	webhooks, store, tenant := newStripeWebhooks(t, testStripeSecret)
+	deliver := func(id, eventType string, object map[string]interface{}) error {
+		payload := stripeEvent(t, id, eventType, object)
+		return webhooks.HandleStripe(ctx, signStripe(payload, testStripeSecret, time.Now()), payload)
+	}
+	plan := func() models.TenantPlan { return store.tenants[tenant.ID].Plan }
+
+	require.NoError(t, deliver("evt_1", "customer.subscription.created", stripeSubscription(tenant.ID, "incomplete", "price_pro")))
+	assert.Equal(t, models.PlanFree, plan(), "an unpaid subscription grants nothing")
+
+	require.NoError(t, deliver("evt_2", "customer.subscription.updated", stripeSubscription(tenant.ID, "active", "price_enterprise")))
+	assert.Equal(t, models.PlanEnterprise, plan())
+
+	require.NoError(t, deliver("evt_3", "customer.subscription.updated", stripeSubscription(tenant.ID, "active", "price_unknown")))
+	assert.Equal(t, models.PlanEnterprise, plan(), "an unknown price leaves the plan alone")
+
+	require.NoError(t, deliver("evt_4", "customer.subscription.updated", stripeSubscription(tenant.ID, "past_due", "price_pro")))
+	assert.Equal(t, models.PlanPro, plan(), "a past due subscription still grants its plan")
+
+	require.NoError(t, deliver("evt_5", "customer.subscription.deleted", stripeSubscription(tenant.ID, "canceled", "price_pro")))
+	assert.Equal(t, models.PlanFree, plan())
+
+	// A redelivered event is applied once
+	require.NoError(t, deliver("evt_2", "customer.subscription.updated", stripeSubscription(tenant.ID, "active", "price_enterprise")))
+	assert.Equal(t, models.PlanFree, plan())
+
+	// An event that fails to apply is forgotten so Stripe's retry applies it
+	store.failUpdate = true
+	require.Error(t, deliver("evt_6", "customer.subscription.updated", stripeSubscription(tenant.ID, "active", "price_pro")))
+	assert.False(t, store.processed["evt_6"])
+	store.failUpdate = false
+	require.NoError(t, deliver("evt_6", "customer.subscription.updated", stripeSubscription(tenant.ID, "active", "price_pro")))
+	assert.Equal(t, models.PlanPro, plan())
+}
+
+func TestStripeInvoicesRestrictTenant(t *testing.T) {
+	ctx := context.Background()
+	webhooks, store, tenant := newStripeWebhooks(t, testStripeSecret)
+	deliver := func(id, eventType string, object map[string]interface{}) {
+		t.Helper()
+		payload := stripeEvent(t, id, eventType, object)
+		require.NoError(t, webhooks.HandleStripe(ctx, signStripe(payload, testStripeSecret, time.Now()), payload))
+	}
+	restricted := func() bool { return billing.IsRestricted(store.tenants[tenant.ID]) }
+
+	// Stripe will retry the payment, so the tenant is only told
+	deliver("evt_1", "invoice.payment_failed", stripeInvoice(tenant.ID, time.Now().Add(24*time.Hour).Unix()))
+	assert.False(t, restricted())
+	require.Len(t, store.notifications, 1)
+	assert.Equal(t, false, store.notifications[0].Data["restricted"])
+
+	// Once Stripe stops retrying, the tenant is restricted
+	deliver("evt_2", "invoice.payment_failed", stripeInvoice(tenant.ID, nil))
+	assert.True(t, restricted())
+	require.Len(t, store.notifications, 2)
+	assert.Equal(t, true, store.notifications[1].Data["restricted"])
+	assert.Contains(t, string(store.tenants[tenant.ID].Settings), `"timezone":"UTC"`, "other settings are kept")
+
+	deliver("evt_3", "invoice.paid", stripeInvoice(tenant.ID, nil))
+	assert.False(t, restricted())
+	assert.JSONEq(t, `{"timezone":"UTC"}`, string(store.tenants[tenant.ID].Settings))
+}
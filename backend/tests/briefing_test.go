@@ -0,0 +1,143 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/execution"
+	"github.com/delphi-platform/delphi/backend/internal/knowledge"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/providers"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Briefing Retrieval Tests
+// =============================================================================
+
+func TestBriefingRetrievesWithinBudget(t *testing.T) {
+	ctx := context.Background()
+	store := knowledge.NewMockVectorStore()
+	kb := uuid.New()
+
+	high := scoredChunk("high "+strings.Repeat("setup ", 20), 0.9)
+	high.Metadata = map[string]interface{}{"path": "docs/setup.md"}
+	mid := scoredChunk("mid "+strings.Repeat("install ", 20), 0.7)
+	require.NoError(t, store.StoreChunks(ctx, kb, []knowledge.Chunk{
+		scoredChunk("low "+strings.Repeat("deploy ", 20), 0.3),
+		high,
+		mid,
+	}))
+
+	// The budget fits the two best chunks but not the third
+	budget := providers.CountTokens("test-model", high.Content) + providers.CountTokens("test-model", mid.Content) + 1
+	engine := execution.NewBriefingEngine(logger.New())
+	engine.SetKnowledge(knowledge.NewService(store, unitEmbedder{}, logger.New()), budget)
+
+	agent := &models.Agent{
+		ID:             uuid.New(),
+		Model:          "test-model",
+		SystemPrompt:   "You are helpful.",
+		KnowledgeBases: []uuid.UUID{kb},
+		Config:         models.AgentConfig{BriefingDepth: "standard"},
+	}
+
+	briefingContext, err := engine.Retrieve(ctx, agent, "how do I set up?", nil)
+	require.NoError(t, err)
+	docs := briefingContext.KnowledgeContext.RelevantDocuments
+	require.Len(t, docs, 2)
+	assert.Equal(t, "docs/setup.md", docs[0].Title)
+	assert.True(t, strings.HasPrefix(docs[1].Summary, "mid"))
+
+	result, err := engine.Brief(ctx, agent, briefingContext)
+	require.NoError(t, err)
+	assert.Contains(t, result.EnhancedPrompt, "### Relevant Knowledge")
+	assert.NotContains(t, result.EnhancedPrompt, "low ")
+
+	t.Run("quick briefings skip retrieval", func(t *testing.T) {
+		quick := *agent
+		quick.Config.BriefingDepth = "quick"
+		briefingContext, err := engine.Retrieve(ctx, &quick, "how do I set up?", nil)
+		require.NoError(t, err)
+		assert.Nil(t, briefingContext.KnowledgeContext)
+	})
+}
+
+// sectionCounter counts tokens by the briefing sections a text contains, so a
+// test can overflow a real model's context window with a small prompt
+type sectionCounter struct{}
+
+func (sectionCounter) CountTokens(text string) (int, error) {
+	tokens := 1000
+	if strings.Contains(text, "### Recent Activity") {
+		tokens += 100000
+	}
+	tokens += 60000 * strings.Count(text, "**doc-")
+	return tokens, nil
+}
+
+func TestBuildCompletionRequestFitsContextWindow(t *testing.T) {
+	engine := execution.NewBriefingEngine(logger.New())
+	agent := &models.Agent{
+		ID:     uuid.New(),
+		Model:  "claude-3-haiku-20240307", // 200k window
+		Config: models.AgentConfig{BriefingDepth: "standard", MaxTokens: 4096},
+	}
+	briefingContext := &execution.BriefingContext{
+		ProjectContext: &execution.ProjectBriefing{ProjectName: "Atlas", Description: "Billing rewrite"},
+		RecentActivity: &execution.ActivityBriefing{RecentRuns: []execution.RunSummary{{AgentName: "coder", Prompt: "fix", Status: "completed"}}},
+		KnowledgeContext: &execution.KnowledgeBriefing{RelevantDocuments: []execution.DocumentSummary{
+			{Title: "doc-1"}, {Title: "doc-2"}, {Title: "doc-3"}, {Title: "doc-4"},
+		}},
+	}
+
+	briefing, err := engine.Brief(context.Background(), agent, briefingContext)
+	require.NoError(t, err)
+	req := engine.BuildCompletionRequest(agent, briefing, "hello", sectionCounter{})
+
+	system := req.Messages[0].Content
+	assert.NotContains(t, system, "### Recent Activity", "recent activity is trimmed first")
+	assert.NotContains(t, system, "doc-4", "then the lowest-scoring document")
+	assert.Contains(t, system, "doc-3")
+	assert.Contains(t, system, "Billing rewrite", "project detail is kept once it fits")
+	require.Len(t, briefing.Warnings, 1)
+	assert.Contains(t, briefing.Warnings[0], "recent activity")
+	assert.Len(t, briefingContext.KnowledgeContext.RelevantDocuments, 4, "the caller's context is left alone")
+}
+
+func TestExecutionRunnerBriefsWithKnowledge(t *testing.T) {
+	ctx := context.Background()
+	store := knowledge.NewMockVectorStore()
+	kb := uuid.New()
+	require.NoError(t, store.StoreChunks(ctx, kb, []knowledge.Chunk{scoredChunk("Run make setup before the first build.", 0.9)}))
+
+	briefing := execution.NewBriefingEngine(logger.New())
+	briefing.SetKnowledge(knowledge.NewService(store, unitEmbedder{}, logger.New()), 0)
+	provider := &scriptedProvider{responses: []*providers.CompletionResponse{
+		{Message: providers.Message{Role: "assistant", Content: "Run make setup."}, FinishReason: "stop"},
+	}}
+	manager := providers.NewManager()
+	manager.RegisterProvider(provider)
+	runner := execution.NewExecutionRunner(nil, briefing, logger.New())
+	runner.SetToolLoop(execution.NewToolLoop(execution.NewToolRegistry(), nil, logger.New()), manager)
+
+	agent := &models.Agent{
+		ID:             uuid.New(),
+		Provider:       "scripted",
+		Model:          "gpt-4o",
+		KnowledgeBases: []uuid.UUID{kb},
+		Config:         models.AgentConfig{BriefingDepth: "standard"},
+	}
+	_, err := runner.Execute(ctx, &execution.ExecutionRequest{
+		Agent:  agent,
+		Run:    &models.AgentRun{ID: uuid.New(), TenantID: uuid.New()},
+		Prompt: "how do I set up?",
+	})
+	require.NoError(t, err)
+	require.Len(t, provider.requests, 1)
+	assert.Contains(t, provider.requests[0].Messages[0].Content, "make setup", "the run is briefed with the retrieved knowledge")
+}
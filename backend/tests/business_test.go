@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/handlers"
+	"github.com/delphi-platform/delphi/backend/internal/middleware"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// Business & Project Tests
+// =============================================================================
+
+// Invalid input is rejected before anything is looked up, so these run
+// without a database
+func TestBusinessServiceValidatesInput(t *testing.T) {
+	svc := services.NewBusinessService(nil, logger.New())
+	ctx := context.Background()
+
+	_, err := svc.Create(ctx, uuid.New(), &services.CreateBusinessRequest{Name: "  "})
+	assert.EqualError(t, err, "name is required")
+	_, err = svc.Create(ctx, uuid.New(), &services.CreateBusinessRequest{Name: "Studio", Type: "casino"})
+	assert.EqualError(t, err, "invalid business type: casino")
+	_, err = svc.Create(ctx, uuid.New(), &services.CreateBusinessRequest{Name: "Studio", Settings: json.RawMessage(`[1]`)})
+	assert.EqualError(t, err, "settings must be a JSON object")
+}
+
+func TestProjectServiceValidatesInput(t *testing.T) {
+	svc := services.NewProjectService(nil, logger.New())
+	ctx := context.Background()
+
+	_, err := svc.Create(ctx, uuid.New(), &services.CreateProjectRequest{Name: "Launch"})
+	assert.EqualError(t, err, "business_id is required")
+	_, err = svc.Create(ctx, uuid.New(), &services.CreateProjectRequest{BusinessID: uuid.New()})
+	assert.EqualError(t, err, "name is required")
+	_, err = svc.Create(ctx, uuid.New(), &services.CreateProjectRequest{BusinessID: uuid.New(), Name: "Launch", Status: "done"})
+	assert.EqualError(t, err, "status must be active, paused or archived")
+}
+
+func TestBusinessHandlersRejectBadRequests(t *testing.T) {
+	business := handlers.NewBusinessHandler(services.NewBusinessService(nil, logger.New()), logger.New())
+	project := handlers.NewProjectHandler(services.NewProjectService(nil, logger.New()), logger.New())
+
+	r := chi.NewRouter()
+	r.Get("/businesses/{businessID}", business.Get)
+	r.Delete("/businesses/{businessID}", business.Delete)
+	r.Get("/projects", project.List)
+
+	withTenant := func(req *http.Request) *http.Request {
+		return req.WithContext(context.WithValue(req.Context(), middleware.TenantIDKey, uuid.New()))
+	}
+
+	for _, tc := range []struct {
+		req    *http.Request
+		status int
+	}{
+		{httptest.NewRequest("GET", "/businesses/"+uuid.NewString(), nil), http.StatusUnauthorized},
+		{withTenant(httptest.NewRequest("GET", "/businesses/not-a-uuid", nil)), http.StatusBadRequest},
+		{withTenant(httptest.NewRequest("DELETE", "/businesses/not-a-uuid", nil)), http.StatusBadRequest},
+		{withTenant(httptest.NewRequest("GET", "/projects?business_id=nope", nil)), http.StatusBadRequest},
+	} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, tc.req)
+		assert.Equal(t, tc.status, rec.Code, tc.req.Method+" "+tc.req.URL.String())
+	}
+}
@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/execution"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/providers"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Run Cancellation Tests
+// =============================================================================
+
+func TestActiveRunsCancel(t *testing.T) {
+	active := execution.NewActiveRuns()
+	runID := uuid.New()
+
+	assert.False(t, active.Cancel(runID), "unknown runs are not active")
+
+	ctx := active.Start(context.Background(), runID)
+	timeoutCtx, cancel := context.WithTimeout(ctx, time.Hour)
+	defer cancel()
+
+	require.True(t, active.Cancel(runID))
+	<-timeoutCtx.Done()
+	assert.True(t, execution.Cancelled(timeoutCtx), "contexts derived from the run's see why it ended")
+
+	active.Finish(runID)
+	assert.False(t, active.Cancel(runID), "finished runs are no longer active")
+}
+
+func TestActiveRunsFinishIsNotCancellation(t *testing.T) {
+	active := execution.NewActiveRuns()
+	runID := uuid.New()
+
+	ctx := active.Start(context.Background(), runID)
+	active.Finish(runID)
+	assert.Error(t, ctx.Err())
+	assert.False(t, execution.Cancelled(ctx))
+}
+
+func TestExecutionRunnerStopsCancelledRun(t *testing.T) {
+	manager := providers.NewManager()
+	manager.RegisterProvider(&blockingProvider{})
+
+	runner := execution.NewExecutionRunner(nil, execution.NewBriefingEngine(logger.New()), logger.New())
+	runner.SetToolLoop(execution.NewToolLoop(execution.NewToolRegistry(), nil, logger.New()), manager)
+
+	agent := &models.Agent{ID: uuid.New(), Provider: "blocking", Model: "gpt-4o"}
+	agent.Config.BriefingDepth = "quick"
+	run := &models.AgentRun{ID: uuid.New(), TenantID: uuid.New()}
+
+	active := execution.NewActiveRuns()
+	ctx := active.Start(context.Background(), run.ID)
+	defer active.Finish(run.ID)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := runner.Execute(ctx, &execution.ExecutionRequest{Agent: agent, Run: run, Prompt: "never finishes"})
+		done <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	require.True(t, active.Cancel(run.ID))
+
+	select {
+	case err := <-done:
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, execution.ErrRunTimeout)
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled run did not stop")
+	}
+}
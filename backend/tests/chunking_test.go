@@ -0,0 +1,286 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/handlers"
+	"github.com/delphi-platform/delphi/backend/internal/knowledge"
+	"github.com/delphi-platform/delphi/backend/internal/middleware"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Chunking Tests
+// =============================================================================
+
+// chunkRecorder keeps the chunks of the last document stored
+type chunkRecorder struct {
+	*knowledge.MockVectorStore
+	chunks []knowledge.Chunk
+}
+
+func (s *chunkRecorder) StoreChunks(ctx context.Context, kbID uuid.UUID, chunks []knowledge.Chunk) error {
+	s.chunks = chunks
+	return s.MockVectorStore.StoreChunks(ctx, kbID, chunks)
+}
+
+// wordTokens counts a token per word, so chunk sizes are easy to follow
+func wordTokens(text string) (int, error) {
+	return len(strings.Fields(text)), nil
+}
+
+// ingestChunks ingests content with the given chunking and returns its chunks
+func ingestChunks(t *testing.T, content string, opts knowledge.ChunkOptions) []knowledge.Chunk {
+	t.Helper()
+	store := &chunkRecorder{MockVectorStore: knowledge.NewMockVectorStore()}
+	svc := knowledge.NewService(store, unitEmbedder{}, logger.New())
+	svc.SetTokenCounter(wordTokens)
+
+	_, err := svc.Ingest(context.Background(), &knowledge.IngestRequest{
+		KnowledgeBaseID: uuid.New(),
+		Source:          "doc",
+		Content:         content,
+		Chunking:        opts,
+	})
+	require.NoError(t, err)
+	for i, chunk := range store.chunks {
+		require.Equal(t, i, chunk.Index)
+	}
+	return store.chunks
+}
+
+func chunkContents(chunks []knowledge.Chunk) []string {
+	contents := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		contents[i] = chunk.Content
+	}
+	return contents
+}
+
+// words returns numbered words from prefix+from to prefix+to, e.g. a1 a2 a3
+func words(prefix string, from, to int) string {
+	var out []string
+	for i := from; i <= to; i++ {
+		out = append(out, fmt.Sprintf("%s%d", prefix, i))
+	}
+	return strings.Join(out, " ")
+}
+
+func TestTokenChunkingPacksParagraphs(t *testing.T) {
+	content := strings.Join([]string{words("a", 1, 4), words("b", 1, 4), words("c", 1, 4), words("d", 1, 4), words("e", 1, 4)}, "\n\n")
+	chunks := ingestChunks(t, content, knowledge.ChunkOptions{Strategy: knowledge.ChunkByTokens, MaxTokens: 10, OverlapTokens: -1})
+
+	assert.Equal(t, []string{
+		words("a", 1, 4) + "\n\n" + words("b", 1, 4),
+		words("c", 1, 4) + "\n\n" + words("d", 1, 4),
+		words("e", 1, 4),
+	}, chunkContents(chunks))
+}
+
+func TestTokenChunkingOverlapsChunks(t *testing.T) {
+	content := strings.Join([]string{words("a", 1, 6), words("b", 1, 6), words("c", 1, 6)}, "\n\n")
+	chunks := ingestChunks(t, content, knowledge.ChunkOptions{Strategy: knowledge.ChunkByTokens, MaxTokens: 10, OverlapTokens: 3})
+
+	assert.Equal(t, []string{
+		words("a", 1, 6),
+		words("a", 4, 6) + "\n\n" + words("b", 1, 6),
+		words("b", 4, 6) + "\n\n" + words("c", 1, 6),
+	}, chunkContents(chunks))
+}
+
+func TestTokenChunkingSplitsLongParagraphsBetweenWords(t *testing.T) {
+	chunks := ingestChunks(t, words("w", 1, 25), knowledge.ChunkOptions{Strategy: knowledge.ChunkByTokens, MaxTokens: 10, OverlapTokens: 2})
+
+	// Runs leave room for the overlap, so every chunk fits
+	assert.Equal(t, []string{
+		words("w", 1, 8),
+		words("w", 7, 8) + "\n\n" + words("w", 9, 16),
+		words("w", 15, 16) + "\n\n" + words("w", 17, 24),
+		words("w", 23, 24) + "\n\n" + "w25",
+	}, chunkContents(chunks))
+	for _, chunk := range chunks {
+		tokens, _ := wordTokens(chunk.Content)
+		assert.LessOrEqual(t, tokens, 10)
+	}
+}
+
+func TestTokenChunkingOptions(t *testing.T) {
+	// The defaults fit a short document in one chunk
+	chunks := ingestChunks(t, "one short paragraph", knowledge.ChunkOptions{Strategy: knowledge.ChunkByTokens})
+	assert.Equal(t, []string{"one short paragraph"}, chunkContents(chunks))
+
+	assert.NoError(t, knowledge.ChunkOptions{Strategy: knowledge.ChunkByTokens, MaxTokens: 4}.Validate(), "the default overlap shrinks with the chunk size")
+	assert.NoError(t, knowledge.ChunkOptions{}.Validate())
+	assert.EqualError(t, knowledge.ChunkOptions{Strategy: "sentence"}.Validate(), "unknown chunking strategy: sentence")
+	assert.EqualError(t, knowledge.ChunkOptions{Strategy: knowledge.ChunkByTokens, MaxTokens: 4, OverlapTokens: 4}.Validate(),
+		"overlap of 4 tokens must be less than the 4 token chunk size")
+
+	svc := knowledge.NewService(knowledge.NewMockVectorStore(), unitEmbedder{}, logger.New())
+	_, err := svc.Ingest(context.Background(), &knowledge.IngestRequest{
+		KnowledgeBaseID: uuid.New(),
+		Content:         "text",
+		Chunking:        knowledge.ChunkOptions{Strategy: knowledge.ChunkByTokens, OverlapTokens: 600},
+	})
+	assert.ErrorContains(t, err, "overlap of 600 tokens must be less than the 512 token chunk size")
+}
+
+// Uploads are checked before the knowledge base is loaded, so bad ones need no
+// database
+func TestKnowledgeUploadRejectsBadChunking(t *testing.T) {
+	handler := handlers.NewKnowledgeHandler(services.NewKnowledgeService(nil, nil, nil, nil, logger.New()), logger.New())
+
+	upload := func(file string, fields map[string]string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		if file != "" {
+			part, err := form.CreateFormFile("file", "notes.md")
+			require.NoError(t, err)
+			_, err = part.Write([]byte(file))
+			require.NoError(t, err)
+		}
+		for name, value := range fields {
+			require.NoError(t, form.WriteField(name, value))
+		}
+		require.NoError(t, form.Close())
+
+		kbID := uuid.NewString()
+		req := httptest.NewRequest(http.MethodPost, "/knowledge-bases/"+kbID+"/documents", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		routeCtx := chi.NewRouteContext()
+		routeCtx.URLParams.Add("kbID", kbID)
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx)
+		ctx = context.WithValue(ctx, middleware.TenantIDKey, uuid.New())
+		w := httptest.NewRecorder()
+		handler.UploadDocument(w, req.WithContext(ctx))
+		return w
+	}
+
+	w := upload("", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "file is required")
+
+	w = upload("  ", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "document is empty")
+
+	w = upload("# Notes", map[string]string{"chunking": "sentence"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "unknown chunking strategy: sentence")
+
+	w = upload("# Notes", map[string]string{"chunking": "token", "max_tokens": "many"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid max_tokens")
+
+	w = upload("# Notes", map[string]string{"chunking": "token", "max_tokens": "100", "overlap_tokens": "100"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "overlap of 100 tokens must be less than the 100 token chunk size")
+}
+
+// codeChunk is the part of a code chunk the tests check
+type codeChunk struct {
+	symbol     interface{}
+	start, end interface{}
+}
+
+func codeChunks(chunks []knowledge.Chunk) []codeChunk {
+	out := make([]codeChunk, len(chunks))
+	for i, chunk := range chunks {
+		out[i] = codeChunk{chunk.Metadata["symbol"], chunk.Metadata["start_line"], chunk.Metadata["end_line"]}
+	}
+	return out
+}
+
+const goSource = `package store
+
+import "errors"
+
+// ErrMissing is returned for unknown keys
+var ErrMissing = errors.New("missing")
+
+// Store holds values
+type Store struct {
+	values map[string]string
+}
+
+// Get returns a value
+func (s *Store) Get(key string) (string, error) {
+	v, ok := s.values[key]
+	if !ok {
+		return "", ErrMissing
+	}
+	return v, nil
+}
+
+func New() *Store {
+	return &Store{values: map[string]string{}}
+}
+`
+
+func TestCodeChunkingSplitsGoDeclarations(t *testing.T) {
+	chunks := ingestChunks(t, goSource, knowledge.ChunkOptions{Strategy: knowledge.ChunkByCode, Language: "Go"})
+
+	assert.Equal(t, []codeChunk{
+		{nil, 1, 3},
+		{"ErrMissing", 5, 6},
+		{"Store", 8, 11},
+		{"Store.Get", 13, 20},
+		{"New", 22, 24},
+	}, codeChunks(chunks))
+	assert.True(t, strings.HasPrefix(chunks[3].Content, "// Get returns a value\nfunc (s *Store) Get"), "doc comments stay with their declaration")
+	assert.True(t, strings.HasSuffix(chunks[3].Content, "return v, nil\n}"))
+}
+
+func TestCodeChunkingKeepsDecorators(t *testing.T) {
+	source := "import os\n\n@cache\ndef load(path):\n    return open(path).read()\n\n\nclass Loader:\n    def run(self):\n        pass\n"
+	chunks := ingestChunks(t, source, knowledge.ChunkOptions{Strategy: knowledge.ChunkByCode, Language: "python"})
+
+	assert.Equal(t, []codeChunk{
+		{nil, 1, 1},
+		{"load", 3, 5},
+		{"Loader", 8, 10},
+	}, codeChunks(chunks), "methods stay inside their class")
+
+	source = "// Greets a user\nexport async function greet(name: string) {\n  return `hi ${name}`\n}\n\n@Injectable()\nexport class Greeter {}\n"
+	chunks = ingestChunks(t, source, knowledge.ChunkOptions{Strategy: knowledge.ChunkByCode, Language: "ts"})
+	assert.Equal(t, []codeChunk{
+		{"greet", 1, 4},
+		{"Greeter", 6, 7},
+	}, codeChunks(chunks))
+}
+
+func TestCodeChunkingSplitsLongDeclarations(t *testing.T) {
+	source := "func Big() {\n" + strings.Repeat("\tstep()\n", 248) + "}"
+	chunks := ingestChunks(t, source, knowledge.ChunkOptions{Strategy: knowledge.ChunkByCode, Language: "go"})
+
+	assert.Equal(t, []codeChunk{
+		{"Big", 1, 200},
+		{"Big", 201, 250},
+	}, codeChunks(chunks))
+}
+
+func TestCodeChunkingFallsBackToProse(t *testing.T) {
+	source := "def greet\n  puts 'hi'\nend\n"
+	for _, language := range []string{"ruby", ""} {
+		chunks := ingestChunks(t, source, knowledge.ChunkOptions{Strategy: knowledge.ChunkByCode, Language: language})
+		require.Len(t, chunks, 1)
+		assert.Nil(t, chunks[0].Metadata["symbol"])
+		assert.Equal(t, strings.TrimSpace(source), chunks[0].Content)
+	}
+
+	// Go without declarations, such as a file of comments, is prose too
+	chunks := ingestChunks(t, "// Nothing to see here", knowledge.ChunkOptions{Strategy: knowledge.ChunkByCode, Language: "go"})
+	require.Len(t, chunks, 1)
+	assert.Nil(t, chunks[0].Metadata["start_line"])
+}
@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Coding Result Tests
+// =============================================================================
+
+func normalizeCodingResult(t *testing.T, output string) models.CodingResult {
+	t.Helper()
+	normalized, err := services.NormalizeCodingResult(json.RawMessage(output))
+	require.NoError(t, err)
+
+	var result models.CodingResult
+	require.NoError(t, json.Unmarshal(normalized, &result))
+	return result
+}
+
+func TestNormalizeCodingResult(t *testing.T) {
+	result := normalizeCodingResult(t, `{
+		"summary": "  Fix the login redirect  ",
+		"files": [
+			{"path": "web/login.go", "content": "package web"},
+			{"path": "web/old.go", "status": "deleted", "content": "gone"},
+			{"path": "web/new.go", "status": "renamed", "previous_path": "web/legacy.go"},
+			{"path": "web/login.go", "status": "modified", "content": "package web // v2"}
+		],
+		"commits": [{"sha": "3F9C2A1", "message": "Fix redirect"}],
+		"pr_url": "https://github.com/acme/app/pull/42",
+		"notes": "ignored"
+	}`)
+
+	assert.Equal(t, services.CodingResultSchemaVersion, result.SchemaVersion)
+	assert.Equal(t, "Fix the login redirect", result.Summary)
+	require.Len(t, result.Files, 3, "a file listed twice is merged")
+	assert.Equal(t, models.CodingFileModified, result.Files[0].Status, "status defaults to modified")
+	assert.Equal(t, "package web // v2", *result.Files[0].Content, "the last change wins")
+	assert.Nil(t, result.Files[1].Content, "deleted files have no content")
+	assert.Equal(t, "web/legacy.go", result.Files[2].PreviousPath)
+	assert.Equal(t, "3f9c2a1", result.Commits[0].SHA)
+	assert.Equal(t, "https://github.com/acme/app/pull/42", result.PRURL)
+
+	t.Run("empty lists are kept as lists", func(t *testing.T) {
+		normalized, err := services.NormalizeCodingResult(json.RawMessage(`{"summary": "Nothing to change"}`))
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"schema_version": 1, "summary": "Nothing to change", "files": [], "commits": []}`, string(normalized))
+	})
+
+	t.Run("text responses are searched for the result", func(t *testing.T) {
+		fenced, _ := json.Marshal("Here is the result:\n```json\n{\"summary\": \"Done\"}\n```\nLet me know!")
+		assert.Equal(t, "Done", normalizeCodingResult(t, string(fenced)).Summary)
+
+		bare, _ := json.Marshal(`Result: {"summary": "Done bare"}`)
+		assert.Equal(t, "Done bare", normalizeCodingResult(t, string(bare)).Summary)
+	})
+}
+
+func TestNormalizeCodingResultRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		wantErr string
+	}{
+		{"empty", ``, "result is empty"},
+		{"not an object", `[1, 2]`, "result must be a JSON object"},
+		{"text without a result", `"I could not finish the task"`, "result holds no JSON object"},
+		{"wrong types", `{"summary": 42}`, "result does not match the coding result schema"},
+		{"missing summary", `{"files": []}`, "summary is required"},
+		{"newer schema", `{"schema_version": 2, "summary": "a"}`, "schema_version 2 is newer than 1"},
+		{"absolute path", `{"summary": "a", "files": [{"path": "/etc/passwd"}]}`, "files[0].path must be relative to the repository root"},
+		{"path outside the repository", `{"summary": "a", "files": [{"path": "../secrets"}]}`, "files[0].path must be a clean path within the repository"},
+		{"rename without previous path", `{"summary": "a", "files": [{"path": "b.go", "status": "renamed"}]}`, "files[0].previous_path of a rename is required"},
+		{"unknown status", `{"summary": "a", "files": [{"path": "b.go", "status": "touched"}]}`, "files[0].status must be added, modified, deleted or renamed"},
+		{"commit without message", `{"summary": "a", "commits": [{"sha": "abcdef1"}]}`, "commits[0].message is required"},
+		{"bad commit sha", `{"summary": "a", "commits": [{"sha": "HEAD", "message": "m"}]}`, "commits[0].sha must be a hex commit SHA"},
+		{"bad PR URL", `{"summary": "a", "pr_url": "github.com/acme/app/pull/1"}`, "pr_url must be an http(s) URL"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := services.NormalizeCodingResult(json.RawMessage(tt.output))
+			var invalid *services.ValidationError
+			require.ErrorAs(t, err, &invalid)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+
+	t.Run("every problem is reported", func(t *testing.T) {
+		_, err := services.NormalizeCodingResult(json.RawMessage(`{"files": [{"path": ""}], "pr_url": "ftp://x"}`))
+		var invalid *services.ValidationError
+		require.ErrorAs(t, err, &invalid)
+		fields := make([]string, len(invalid.Fields))
+		for i, field := range invalid.Fields {
+			fields[i] = field.Field
+		}
+		assert.Equal(t, []string{"summary", "files[0].path", "pr_url"}, fields)
+	})
+}
+
+func TestSummarizeBatchCountsPartialRuns(t *testing.T) {
+	batch := &models.RunBatch{ItemCount: 2}
+	runs := []*models.AgentRun{{Status: models.RunStatusPartial}, {Status: models.RunStatusFailed}}
+	assert.Equal(t, services.BatchStatusPartial, services.SummarizeBatch(batch, runs).Status)
+}
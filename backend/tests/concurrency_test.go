@@ -0,0 +1,224 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/execution"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Concurrency Limiter Tests
+// =============================================================================
+
+func planLimit(limit int) execution.LimitFunc {
+	return func(ctx context.Context, tenantID uuid.UUID) int { return limit }
+}
+
+func TestConcurrencyLimiterQueuesAndRejects(t *testing.T) {
+	ctx := context.Background()
+	limiter := execution.NewConcurrencyLimiter(planLimit(2), execution.ConcurrencyConfig{MaxQueued: 1})
+	tenant := uuid.New()
+
+	first, err := limiter.Reserve(ctx, tenant)
+	require.NoError(t, err)
+	second, err := limiter.Reserve(ctx, tenant)
+	require.NoError(t, err)
+	queued, err := limiter.Reserve(ctx, tenant)
+	require.NoError(t, err)
+
+	_, err = limiter.Reserve(ctx, tenant)
+	assert.ErrorIs(t, err, execution.ErrMaxConcurrentRuns)
+	assert.Equal(t, execution.ConcurrencyStats{Active: 2, Queued: 1, Limit: 2}, limiter.Stats(ctx, tenant))
+
+	// Other tenants are unaffected
+	other, err := limiter.Reserve(ctx, uuid.New())
+	require.NoError(t, err)
+	require.NoError(t, other.Wait(ctx))
+	other.Release()
+
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assert.Error(t, queued.Wait(waitCtx))
+
+	first.Release()
+	first.Release() // releasing twice frees one slot
+	require.NoError(t, queued.Wait(ctx))
+	assert.Equal(t, execution.ConcurrencyStats{Active: 2, Queued: 0, Limit: 2}, limiter.Stats(ctx, tenant))
+
+	second.Release()
+	queued.Release()
+	assert.Equal(t, execution.ConcurrencyStats{Limit: 2}, limiter.Stats(ctx, tenant))
+}
+
+func TestConcurrencyLimiterCancelledWaiterLeavesQueue(t *testing.T) {
+	ctx := context.Background()
+	limiter := execution.NewConcurrencyLimiter(planLimit(1), execution.ConcurrencyConfig{MaxQueued: 1})
+	tenant := uuid.New()
+
+	running, err := limiter.Acquire(ctx, tenant)
+	require.NoError(t, err)
+
+	waitCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = limiter.Acquire(waitCtx, tenant)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 0, limiter.Stats(ctx, tenant).Queued)
+
+	running.Release()
+	_, err = limiter.Acquire(ctx, tenant)
+	assert.NoError(t, err)
+}
+
+func TestConcurrencyLimiterCapsPlanLimit(t *testing.T) {
+	ctx := context.Background()
+	tenant := uuid.New()
+
+	capped := execution.NewConcurrencyLimiter(planLimit(10), execution.ConcurrencyConfig{Max: 3})
+	assert.Equal(t, 3, capped.Limit(ctx, tenant))
+
+	unlimitedPlan := execution.NewConcurrencyLimiter(planLimit(-1), execution.ConcurrencyConfig{Max: 3})
+	assert.Equal(t, 3, unlimitedPlan.Limit(ctx, tenant))
+
+	uncapped := execution.NewConcurrencyLimiter(planLimit(-1), execution.ConcurrencyConfig{})
+	assert.Equal(t, 0, uncapped.Limit(ctx, tenant))
+}
+
+// memorySlotStore is a SlotStore shared by limiters standing in for instances
+type memorySlotStore struct {
+	mu    sync.Mutex
+	slots map[uuid.UUID]map[string]bool
+	err   error
+}
+
+func newMemorySlotStore() *memorySlotStore {
+	return &memorySlotStore{slots: make(map[uuid.UUID]map[string]bool)}
+}
+
+func (s *memorySlotStore) Acquire(ctx context.Context, tenantID uuid.UUID, id string, limit int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return false, s.err
+	}
+	if len(s.slots[tenantID]) >= limit {
+		return false, nil
+	}
+	if s.slots[tenantID] == nil {
+		s.slots[tenantID] = make(map[string]bool)
+	}
+	s.slots[tenantID][id] = true
+	return true, nil
+}
+
+func (s *memorySlotStore) Release(ctx context.Context, tenantID uuid.UUID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.slots[tenantID], id)
+	return nil
+}
+
+func (s *memorySlotStore) Active(ctx context.Context, tenantID uuid.UUID) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.slots[tenantID]), s.err
+}
+
+func TestConcurrencyLimiterSharesSlotsThroughStore(t *testing.T) {
+	ctx := context.Background()
+	store := newMemorySlotStore()
+	cfg := execution.ConcurrencyConfig{MaxQueued: 1, PollInterval: 10 * time.Millisecond}
+	first := execution.NewConcurrencyLimiter(planLimit(1), cfg)
+	first.SetStore(store, logger.New())
+	second := execution.NewConcurrencyLimiter(planLimit(1), cfg)
+	second.SetStore(store, logger.New())
+	tenant := uuid.New()
+
+	running, err := first.Acquire(ctx, tenant)
+	require.NoError(t, err)
+
+	// The other instance queues behind the first one's run
+	queued, err := second.Reserve(ctx, tenant)
+	require.NoError(t, err)
+	_, err = second.Reserve(ctx, tenant)
+	assert.ErrorIs(t, err, execution.ErrMaxConcurrentRuns)
+	assert.Equal(t, execution.ConcurrencyStats{Active: 1, Queued: 1, Limit: 1}, second.Stats(ctx, tenant))
+
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, queued.Wait(waitCtx), context.DeadlineExceeded)
+
+	// The queued run picks up the slot once the first instance frees it
+	running.Release()
+	waitCtx, cancel = context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	require.NoError(t, queued.Wait(waitCtx))
+	assert.Equal(t, 1, first.Stats(ctx, tenant).Active)
+
+	queued.Release()
+	assert.Equal(t, 0, first.Stats(ctx, tenant).Active)
+}
+
+func TestConcurrencyLimiterFallsBackWhenStoreFails(t *testing.T) {
+	ctx := context.Background()
+	store := newMemorySlotStore()
+	store.err = errors.New("connection refused")
+	limiter := execution.NewConcurrencyLimiter(planLimit(1), execution.ConcurrencyConfig{})
+	limiter.SetStore(store, logger.New())
+	tenant := uuid.New()
+
+	running, err := limiter.Reserve(ctx, tenant)
+	require.NoError(t, err)
+	_, err = limiter.Reserve(ctx, tenant)
+	assert.ErrorIs(t, err, execution.ErrMaxConcurrentRuns, "this instance's runs are still limited")
+	assert.Equal(t, 1, limiter.Stats(ctx, tenant).Active)
+	running.Release()
+}
+
+func TestConcurrencyLimiterReportsQueuePositions(t *testing.T) {
+	ctx := context.Background()
+	limiter := execution.NewConcurrencyLimiter(planLimit(1), execution.ConcurrencyConfig{MaxQueued: 3})
+	moved := make(chan uuid.UUID, 10)
+	limiter.SetQueueListener(func(tenantID uuid.UUID) { moved <- tenantID })
+	tenant := uuid.New()
+
+	running, err := limiter.Acquire(ctx, tenant)
+	require.NoError(t, err)
+	first, err := limiter.Reserve(ctx, tenant)
+	require.NoError(t, err)
+	second, err := limiter.Reserve(ctx, tenant)
+	require.NoError(t, err)
+	third, err := limiter.Reserve(ctx, tenant)
+	require.NoError(t, err)
+
+	assert.Equal(t, 0, running.Position())
+	assert.Equal(t, []int{1, 2, 3}, []int{first.Position(), second.Position(), third.Position()})
+
+	// A run leaving the queue moves the ones behind it up
+	second.Release()
+	assert.Equal(t, tenant, <-moved)
+	assert.Equal(t, []int{1, 0, 2}, []int{first.Position(), second.Position(), third.Position()})
+
+	// So does the head of the queue being granted a slot
+	running.Release()
+	assert.Equal(t, tenant, <-moved)
+	require.NoError(t, first.Wait(ctx))
+	assert.Equal(t, []int{0, 1}, []int{first.Position(), third.Position()})
+
+	// Granting the last queued run leaves no one to tell
+	first.Release()
+	require.NoError(t, third.Wait(ctx))
+	third.Release()
+	select {
+	case <-moved:
+		t.Fatal("queue listener called with nobody queued")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// Cost Anomaly Tests
+// =============================================================================
+
+func TestIsCostAnomaly(t *testing.T) {
+	tests := []struct {
+		name     string
+		cost     float64
+		baseline float64
+		runs     int
+		multiple float64
+		want     bool
+	}{
+		{"above the multiple", 0.6, 0.1, 10, 5, true},
+		{"at the multiple", 0.5, 0.1, 10, 5, false},
+		{"below the multiple", 0.2, 0.1, 10, 5, false},
+		{"too few runs for a baseline", 5, 0.1, 4, 5, false},
+		{"free runs have no baseline", 5, 0, 10, 5, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, services.IsCostAnomaly(tt.cost, tt.baseline, tt.runs, tt.multiple))
+		})
+	}
+}
+
+func TestTenantCostAnomalySettings(t *testing.T) {
+	defaults := services.CostAnomalySettings{Enabled: true, Multiple: 5}
+
+	t.Run("defaults without settings", func(t *testing.T) {
+		assert.Equal(t, defaults, services.TenantCostAnomalySettings(&models.Tenant{}))
+		assert.Equal(t, defaults, services.TenantCostAnomalySettings(&models.Tenant{Settings: []byte(`{"billing_restricted": true}`)}))
+	})
+
+	t.Run("reads the tenant's settings", func(t *testing.T) {
+		tenant := &models.Tenant{Settings: []byte(`{"cost_anomalies": {"enabled": true, "multiple": 3, "auto_pause": true}}`)}
+		assert.Equal(t, services.CostAnomalySettings{Enabled: true, Multiple: 3, AutoPause: true},
+			services.TenantCostAnomalySettings(tenant))
+	})
+
+	t.Run("a missing multiple falls back to the default", func(t *testing.T) {
+		tenant := &models.Tenant{Settings: []byte(`{"cost_anomalies": {"enabled": false}}`)}
+		assert.Equal(t, services.CostAnomalySettings{Multiple: 5}, services.TenantCostAnomalySettings(tenant))
+	})
+}
@@ -0,0 +1,45 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// Cost Forecast Tests
+// =============================================================================
+
+func TestProjectSpend(t *testing.T) {
+	day := 24 * time.Hour
+
+	t.Run("steady spend projects without a band", func(t *testing.T) {
+		p := services.ProjectSpend(20, []float64{2, 2, 2, 2, 2, 2, 2}, 10*day)
+		assert.InDelta(t, 2, p.BurnRate, 1e-9)
+		assert.InDelta(t, 40, p.Projected, 1e-9)
+		assert.InDelta(t, 40, p.ProjectedLow, 1e-9)
+		assert.InDelta(t, 40, p.ProjectedHigh, 1e-9)
+	})
+
+	t.Run("variable spend widens the band", func(t *testing.T) {
+		p := services.ProjectSpend(20, []float64{0, 4, 0, 4}, 9*day)
+		assert.InDelta(t, 2, p.BurnRate, 1e-9)
+		assert.InDelta(t, 38, p.Projected, 1e-9)
+		assert.Less(t, p.ProjectedLow, p.Projected)
+		assert.Greater(t, p.ProjectedHigh, p.Projected)
+		assert.InDelta(t, p.Projected-p.ProjectedLow, p.ProjectedHigh-p.Projected, 1e-9)
+	})
+
+	t.Run("the band never drops below what was spent", func(t *testing.T) {
+		p := services.ProjectSpend(5, []float64{0, 0, 0, 0, 0, 0, 40}, 3*day)
+		assert.Equal(t, 5.0, p.ProjectedLow)
+	})
+
+	t.Run("no recent spend or no time left projects what was spent", func(t *testing.T) {
+		assert.Equal(t, services.SpendProjection{Projected: 12, ProjectedLow: 12, ProjectedHigh: 12},
+			services.ProjectSpend(12, nil, 5*day))
+		assert.Equal(t, 12.0, services.ProjectSpend(12, []float64{3, 3}, 0).Projected)
+	})
+}
@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/pkg/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Encryption Tests
+// =============================================================================
+
+func TestEncryptorRoundTrip(t *testing.T) {
+	key, err := crypto.GenerateEncryptionKey()
+	require.NoError(t, err)
+	encryptor, err := crypto.NewEncryptor(key)
+	require.NoError(t, err)
+
+	first, err := encryptor.Encrypt("sk-secret")
+	require.NoError(t, err)
+	second, err := encryptor.Encrypt("sk-secret")
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second, "each value gets its own data key and nonce")
+	assert.NotContains(t, first, "sk-secret")
+
+	data, err := base64.StdEncoding.DecodeString(first)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 1}, data[:2], "format and key version header")
+
+	plain, err := encryptor.Decrypt(first)
+	require.NoError(t, err)
+	assert.Equal(t, "sk-secret", plain)
+
+	// Relabelling the value with another key version breaks authentication
+	data[1] = 2
+	_, err = encryptor.Decrypt(base64.StdEncoding.EncodeToString(data))
+	assert.Error(t, err)
+}
+
+func TestEncryptorKeyRotation(t *testing.T) {
+	oldKey, err := crypto.GenerateEncryptionKey()
+	require.NoError(t, err)
+	newKey, err := crypto.GenerateEncryptionKey()
+	require.NoError(t, err)
+
+	before, err := crypto.NewEncryptor(oldKey)
+	require.NoError(t, err)
+	encrypted, err := before.Encrypt("credentials")
+	require.NoError(t, err)
+
+	after, err := crypto.NewVersionedEncryptor(newKey, 2)
+	require.NoError(t, err)
+	_, err = after.Decrypt(encrypted)
+	assert.Error(t, err, "the retired key is not registered yet")
+
+	require.NoError(t, after.AddKey(1, oldKey))
+	plain, err := after.Decrypt(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "credentials", plain)
+
+	reencrypted, err := after.Encrypt(plain)
+	require.NoError(t, err)
+	_, err = before.Decrypt(reencrypted)
+	assert.Error(t, err, "new values use the current key")
+
+	assert.Error(t, after.AddKey(2, oldKey), "versions are unique")
+}
+
+func TestEncryptorDecryptsLegacyValues(t *testing.T) {
+	key, err := crypto.GenerateEncryptionKey()
+	require.NoError(t, err)
+	raw, err := hex.DecodeString(key)
+	require.NoError(t, err)
+
+	// Values written before the envelope format are nonce|ciphertext under the key itself
+	block, err := aes.NewCipher(raw)
+	require.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	nonce := make([]byte, gcm.NonceSize())
+	legacy := base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte("legacy secret"), nil))
+
+	encryptor, err := crypto.NewEncryptor(key)
+	require.NoError(t, err)
+	plain, err := encryptor.Decrypt(legacy)
+	require.NoError(t, err)
+	assert.Equal(t, "legacy secret", plain)
+}
+
+func TestNilEncryptorRefusesSecrets(t *testing.T) {
+	var encryptor *crypto.Encryptor
+
+	_, err := encryptor.Encrypt("sk-secret")
+	assert.ErrorIs(t, err, crypto.ErrNoEncryptionKey, "secrets are never stored as plaintext")
+	_, err = encryptor.Decrypt("sk-secret")
+	assert.ErrorIs(t, err, crypto.ErrNoEncryptionKey)
+}
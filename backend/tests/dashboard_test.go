@@ -0,0 +1,25 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// Dashboard Tests
+// =============================================================================
+
+func TestDailyCosts(t *testing.T) {
+	since := time.Date(2025, 1, 30, 0, 0, 0, 0, time.UTC)
+	trends := services.DailyCosts(since, []float64{1.5, 0, 2.25})
+
+	assert.Equal(t, []services.DailyCost{
+		{Date: "2025-01-30", Cost: 1.5},
+		{Date: "2025-01-31", Cost: 0},
+		{Date: "2025-02-01", Cost: 2.25},
+	}, trends)
+	assert.Empty(t, services.DailyCosts(since, nil))
+}
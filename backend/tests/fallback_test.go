@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/execution"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/providers"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Fallback Chain Tests
+// =============================================================================
+
+// namedProvider answers as the provider name, failing every completion with
+// err when it is set
+type namedProvider struct {
+	name   string
+	err    error
+	models []string
+}
+
+func (p *namedProvider) Name() string { return p.name }
+
+func (p *namedProvider) Complete(ctx context.Context, req *providers.CompletionRequest) (*providers.CompletionResponse, error) {
+	p.models = append(p.models, req.Model)
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &providers.CompletionResponse{
+		Message:      providers.Message{Role: "assistant", Content: "answered by " + req.Model},
+		Usage:        providers.TokenUsage{PromptTokens: 1000, CompletionTokens: 1000, TotalTokens: 2000},
+		FinishReason: "stop",
+	}, nil
+}
+
+func (p *namedProvider) Stream(ctx context.Context, req *providers.CompletionRequest) (<-chan providers.StreamChunk, error) {
+	return nil, nil
+}
+func (p *namedProvider) CountTokens(text string) (int, error)                 { return len(text) / 4, nil }
+func (p *namedProvider) GetModels() []providers.ModelInfo                     { return nil }
+func (p *namedProvider) ValidateAPIKey(ctx context.Context, key string) error { return nil }
+
+// fallbackRunner runs agents in-process with the given providers
+func fallbackRunner(provs ...providers.Provider) *execution.ExecutionRunner {
+	manager := providers.NewManager()
+	for _, p := range provs {
+		manager.RegisterProvider(p)
+	}
+	runner := execution.NewExecutionRunner(nil, execution.NewBriefingEngine(logger.New()), logger.New())
+	runner.SetToolLoop(execution.NewToolLoop(execution.NewToolRegistry(), nil, logger.New()), manager)
+	return runner
+}
+
+// fallbackAgent is an OpenAI agent falling back to Anthropic, then Google
+func fallbackAgent() *models.Agent {
+	agent := &models.Agent{ID: uuid.New(), Provider: models.ProviderOpenAI, Model: "gpt-4o"}
+	agent.Config.FallbackChain = []models.ModelChoice{
+		{Provider: models.ProviderAnthropic, Model: "claude-3-5-sonnet-20241022"},
+		{Provider: models.ProviderGoogle, Model: "gemini-1.5-pro"},
+	}
+	return agent
+}
+
+func runFallback(t *testing.T, runner *execution.ExecutionRunner, agent *models.Agent) (*execution.ExecutionResult, error) {
+	t.Helper()
+	return runner.Execute(context.Background(), &execution.ExecutionRequest{
+		Agent:  agent,
+		Run:    &models.AgentRun{ID: uuid.New(), TenantID: uuid.New()},
+		Prompt: "Summarise the quarterly report",
+	})
+}
+
+func TestRetryableProviderErrors(t *testing.T) {
+	assert.True(t, providers.Retryable(&providers.APIError{Provider: "anthropic", StatusCode: http.StatusTooManyRequests}))
+	assert.True(t, providers.Retryable(&providers.APIError{Provider: "anthropic", StatusCode: 529}), "overloaded")
+	assert.True(t, providers.Retryable(&providers.APIError{Provider: "google", StatusCode: http.StatusBadGateway}))
+	assert.False(t, providers.Retryable(&providers.APIError{Provider: "google", StatusCode: http.StatusBadRequest}))
+	assert.False(t, providers.Retryable(&providers.APIError{Provider: "openai", StatusCode: http.StatusUnauthorized}))
+	assert.False(t, providers.Retryable(errors.New("invalid request")))
+}
+
+func TestRunsFallBackOnRetryableFailures(t *testing.T) {
+	openai := &namedProvider{name: "openai", err: &providers.APIError{Provider: "openai", StatusCode: http.StatusTooManyRequests}}
+	anthropic := &namedProvider{name: "anthropic"}
+	google := &namedProvider{name: "google"}
+	calculator := providers.NewCostCalculator()
+	for model, info := range providers.DefaultPricing() {
+		calculator.SetPricing(model, info)
+	}
+
+	result, err := runFallback(t, fallbackRunner(openai, anthropic, google), fallbackAgent())
+	require.NoError(t, err)
+	assert.Equal(t, "answered by claude-3-5-sonnet-20241022", result.Response)
+	assert.Equal(t, models.ProviderAnthropic, result.Provider, "the serving model is reported")
+	assert.Equal(t, "claude-3-5-sonnet-20241022", result.Model)
+	assert.Empty(t, google.models, "the chain stops at the first model that answers")
+
+	require.Len(t, result.Attempts, 2)
+	assert.Equal(t, models.ProviderOpenAI, result.Attempts[0].Provider)
+	assert.Contains(t, result.Attempts[0].Error, "429")
+	assert.Empty(t, result.Attempts[1].Error)
+
+	usage := providers.TokenUsage{PromptTokens: 1000, CompletionTokens: 1000, TotalTokens: 2000}
+	assert.InDelta(t, calculator.Calculate("claude-3-5-sonnet-20241022", usage), result.Cost, 1e-9, "priced at the serving model's rates")
+}
+
+func TestRunsSkipUnconfiguredFallbackProviders(t *testing.T) {
+	openai := &namedProvider{name: "openai", err: &providers.APIError{Provider: "openai", StatusCode: http.StatusServiceUnavailable}}
+	google := &namedProvider{name: "google"}
+
+	result, err := runFallback(t, fallbackRunner(openai, google), fallbackAgent())
+	require.NoError(t, err)
+	assert.Equal(t, models.ProviderGoogle, result.Provider)
+	assert.Equal(t, "gemini-1.5-pro", result.Model)
+	require.Len(t, result.Attempts, 3)
+	assert.NotEmpty(t, result.Attempts[1].Error, "anthropic is not configured")
+}
+
+func TestRunsDoNotFallBackOnRequestErrors(t *testing.T) {
+	openai := &namedProvider{name: "openai", err: &providers.APIError{Provider: "openai", StatusCode: http.StatusBadRequest}}
+	anthropic := &namedProvider{name: "anthropic"}
+
+	result, err := runFallback(t, fallbackRunner(openai, anthropic), fallbackAgent())
+	var apiErr *providers.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Empty(t, anthropic.models)
+	assert.Equal(t, models.ProviderOpenAI, result.Provider)
+	assert.Len(t, result.Attempts, 1)
+}
+
+func TestRunsFailWhenTheWholeChainFails(t *testing.T) {
+	overloaded := &providers.APIError{Provider: "test", StatusCode: http.StatusServiceUnavailable}
+	openai := &namedProvider{name: "openai", err: overloaded}
+	anthropic := &namedProvider{name: "anthropic", err: overloaded}
+	google := &namedProvider{name: "google", err: overloaded}
+
+	result, err := runFallback(t, fallbackRunner(openai, anthropic, google), fallbackAgent())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "all 3 models in the fallback chain failed")
+	assert.False(t, result.Success)
+	assert.Len(t, result.Attempts, 3)
+	assert.Equal(t, []string{"gpt-4o"}, openai.models)
+}
+
+func TestRunsWithoutAFallbackChainReportTheAgentsModel(t *testing.T) {
+	openai := &namedProvider{name: "openai"}
+	agent := &models.Agent{ID: uuid.New(), Provider: models.ProviderOpenAI, Model: "gpt-4o"}
+
+	result, err := runFallback(t, fallbackRunner(openai), agent)
+	require.NoError(t, err)
+	assert.Equal(t, models.ProviderOpenAI, result.Provider)
+	assert.Equal(t, "gpt-4o", result.Model)
+	assert.Empty(t, result.Attempts)
+}
@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Financial Service Tests
+// =============================================================================
+
+// Invalid input is rejected before the business or account is looked up, so
+// these run without a database
+func TestFinancialServiceValidatesInput(t *testing.T) {
+	svc := services.NewFinancialService(nil, logger.New())
+	ctx := context.Background()
+	tenantID, businessID := uuid.New(), uuid.New()
+
+	_, err := svc.CreateAccount(ctx, tenantID, businessID, &services.CreateAccountRequest{Type: "checking"})
+	assert.EqualError(t, err, "name is required")
+	_, err = svc.CreateAccount(ctx, tenantID, businessID, &services.CreateAccountRequest{Name: "Ops", Type: "checking", Currency: "dollars"})
+	assert.EqualError(t, err, "currency must be a three-letter ISO 4217 code")
+
+	_, err = svc.CreateTransaction(ctx, tenantID, businessID, &services.CreateTransactionRequest{Amount: 10})
+	assert.EqualError(t, err, "account_id is required")
+	_, err = svc.CreateTransaction(ctx, tenantID, businessID, &services.CreateTransactionRequest{AccountID: uuid.New(), Amount: 0.001})
+	assert.EqualError(t, err, "amount must be non-zero", "amounts are stored in cents")
+	_, err = svc.CreateTransaction(ctx, tenantID, businessID, &services.CreateTransactionRequest{AccountID: uuid.New(), Amount: 10, Date: "01/02/2024"})
+	assert.ErrorContains(t, err, "use YYYY-MM-DD")
+
+	_, err = svc.CreateBudget(ctx, tenantID, businessID, &services.CreateBudgetRequest{Category: "marketing", Amount: -5})
+	assert.EqualError(t, err, "amount must be positive")
+	_, err = svc.CreateBudget(ctx, tenantID, businessID, &services.CreateBudgetRequest{Category: "marketing", Amount: 500, Period: "weekly"})
+	assert.EqualError(t, err, "period must be monthly, quarterly or yearly")
+}
+
+func TestParseDate(t *testing.T) {
+	date, err := services.ParseDate("2024-02-29")
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-02-29", date.Format("2006-01-02"))
+
+	_, err = services.ParseDate("2023-02-29")
+	assert.Error(t, err)
+}
+
+func date(t *testing.T, value string) time.Time {
+	d, err := services.ParseDate(value)
+	require.NoError(t, err)
+	return d
+}
+
+func TestReportRange(t *testing.T) {
+	now := date(t, "2024-05-17")
+	for period, want := range map[string][2]string{
+		"month":   {"2024-05-01", "2024-05-31"},
+		"quarter": {"2024-04-01", "2024-06-30"},
+		"year":    {"2024-01-01", "2024-12-31"},
+	} {
+		from, to, err := services.ReportRange(period, now)
+		require.NoError(t, err)
+		assert.Equal(t, want[0], from.Format("2006-01-02"), period)
+		assert.Equal(t, want[1], to.Format("2006-01-02"), period)
+	}
+
+	_, _, err := services.ReportRange("week", now)
+	assert.Error(t, err)
+}
+
+func TestBuildFinancialReport(t *testing.T) {
+	tx := func(day string, amount float64, category string) *models.Transaction {
+		return &models.Transaction{Date: date(t, day), Amount: amount, Category: category}
+	}
+	transactions := []*models.Transaction{
+		tx("2024-02-01", 1000, "sales"),
+		tx("2024-02-03", -0.1, "fees"),
+		tx("2024-02-03", -0.2, "fees"),
+		tx("2024-02-10", -300, "hosting"),
+		tx("2024-02-20", 50, "hosting"), // refund
+		tx("2024-03-01", -999, "hosting"),
+	}
+	budgets := []*models.Budget{
+		{Category: "hosting", Amount: 200, Period: "monthly", StartDate: date(t, "2024-01-01")},
+		{Category: "fees", Amount: 3, Period: "quarterly", StartDate: date(t, "2024-01-01")},
+		{Category: "travel", Amount: 100, Period: "monthly", StartDate: date(t, "2024-03-01")},
+	}
+
+	report := services.BuildFinancialReport(transactions, budgets, date(t, "2024-02-01"), date(t, "2024-02-29"))
+	assert.Equal(t, 1050.0, report.Revenue)
+	assert.Equal(t, 300.3, report.Expenses, "amounts are summed in cents")
+	assert.Equal(t, 749.7, report.NetIncome)
+	assert.Equal(t, 5, report.Transactions, "transactions outside the range are left out")
+
+	require.Len(t, report.Categories, 3)
+	assert.Equal(t, "sales", report.Categories[0].Category)
+	hosting := report.Categories[1]
+	assert.Equal(t, "hosting", hosting.Category)
+	assert.Equal(t, 50.0, hosting.Revenue)
+	assert.Equal(t, 300.0, hosting.Expenses)
+	assert.Equal(t, 99.9, hosting.ExpenseShare)
+
+	assert.Equal(t, "day", report.Interval)
+	require.Len(t, report.Series, 29)
+	assert.Equal(t, "2024-02-03", report.Series[2].Label)
+	assert.Equal(t, 0.3, report.Series[2].Expenses)
+
+	require.Len(t, report.Budgets, 2, "budgets starting after the range don't apply")
+	assert.Equal(t, 200.0, report.Budgets[0].Budgeted)
+	assert.Equal(t, 300.0, report.Budgets[0].Actual)
+	assert.Equal(t, -100.0, report.Budgets[0].Variance)
+	assert.True(t, report.Budgets[0].OverBudget)
+	assert.Equal(t, 150.0, report.Budgets[0].PercentUsed)
+	assert.Equal(t, 1.0, report.Budgets[1].Budgeted, "a quarterly budget is prorated to one month")
+	assert.Equal(t, 30.0, report.Budgets[1].PercentUsed)
+}
+
+func TestBuildFinancialReportChartsLongRangesByMonth(t *testing.T) {
+	report := services.BuildFinancialReport([]*models.Transaction{
+		{Date: date(t, "2024-03-15"), Amount: -10.05},
+		{Date: date(t, "2024-03-16"), Amount: -10.05},
+	}, nil, date(t, "2024-01-01"), date(t, "2024-12-31"))
+
+	assert.Equal(t, "month", report.Interval)
+	require.Len(t, report.Series, 12)
+	assert.Equal(t, "2024-03", report.Series[2].Label)
+	assert.Equal(t, 20.1, report.Series[2].Expenses)
+	assert.Equal(t, "uncategorized", report.Categories[0].Category)
+	assert.Empty(t, report.Budgets)
+}
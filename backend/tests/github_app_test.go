@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/github"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// GitHub App Authentication Tests
+// =============================================================================
+
+// fakeTokenEndpoint issues installation tokens expiring after expiresIn,
+// recording the claims of each app JWT it is sent
+type fakeTokenEndpoint struct {
+	key       *rsa.PublicKey
+	expiresIn time.Duration
+
+	mu      sync.Mutex
+	claims  []*jwt.RegisteredClaims
+	methods []string
+}
+
+func (f *fakeTokenEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/app/installations/42/access_tokens" {
+		http.NotFound(w, r)
+		return
+	}
+
+	claims := &jwt.RegisteredClaims{}
+	token, err := jwt.ParseWithClaims(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), claims,
+		func(token *jwt.Token) (interface{}, error) { return f.key, nil })
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	f.mu.Lock()
+	f.claims = append(f.claims, claims)
+	f.methods = append(f.methods, token.Method.Alg())
+	n := len(f.claims)
+	f.mu.Unlock()
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"token":      fmt.Sprintf("ghs_token%d", n),
+		"expires_at": time.Now().Add(f.expiresIn).UTC().Format(time.RFC3339),
+	})
+}
+
+func (f *fakeTokenEndpoint) requests() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.claims)
+}
+
+// newTokenEndpoint starts a fake token endpoint and returns a client pointed
+// at it with the PEM private key it accepts
+func newTokenEndpoint(t *testing.T, expiresIn time.Duration) (*fakeTokenEndpoint, *github.Client, []byte) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	endpoint := &fakeTokenEndpoint{key: &key.PublicKey, expiresIn: expiresIn}
+	server := httptest.NewServer(endpoint)
+	t.Cleanup(server.Close)
+
+	client := github.NewClient(logger.New())
+	client.SetBaseURL(server.URL)
+	return endpoint, client, privateKey
+}
+
+func TestInstallationTokenAppJWT(t *testing.T) {
+	endpoint, client, privateKey := newTokenEndpoint(t, time.Hour)
+
+	before := time.Now()
+	token, err := client.GetInstallationToken(context.Background(), "12345", privateKey, 42)
+	require.NoError(t, err)
+	assert.Equal(t, "ghs_token1", token.Token)
+
+	require.Len(t, endpoint.claims, 1)
+	assert.Equal(t, "RS256", endpoint.methods[0])
+	claims := endpoint.claims[0]
+	assert.Equal(t, "12345", claims.Issuer)
+	assert.WithinDuration(t, before.Add(-60*time.Second), claims.IssuedAt.Time, 2*time.Second, "iat is backdated for clock skew")
+	assert.WithinDuration(t, before.Add(9*time.Minute), claims.ExpiresAt.Time, 2*time.Second)
+	assert.LessOrEqual(t, claims.ExpiresAt.Sub(claims.IssuedAt.Time), 10*time.Minute, "GitHub rejects app JWTs valid for over 10 minutes")
+}
+
+func TestInstallationTokensAreCachedUntilNearExpiry(t *testing.T) {
+	endpoint, client, privateKey := newTokenEndpoint(t, time.Hour)
+	ctx := context.Background()
+
+	first, err := client.GetInstallationToken(ctx, "12345", privateKey, 42)
+	require.NoError(t, err)
+	second, err := client.GetInstallationToken(ctx, "12345", privateKey, 42)
+	require.NoError(t, err)
+	assert.Equal(t, first.Token, second.Token)
+	assert.Equal(t, 1, endpoint.requests(), "a token inside its expiry window is served from the cache")
+
+	_, err = client.GetInstallationToken(ctx, "67890", privateKey, 42)
+	require.NoError(t, err)
+	assert.Equal(t, 2, endpoint.requests(), "tokens are cached per app")
+}
+
+func TestNearExpiryInstallationTokensAreRefreshed(t *testing.T) {
+	endpoint, client, privateKey := newTokenEndpoint(t, 2*time.Minute)
+	ctx := context.Background()
+
+	first, err := client.GetInstallationToken(ctx, "12345", privateKey, 42)
+	require.NoError(t, err)
+	second, err := client.GetInstallationToken(ctx, "12345", privateKey, 42)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.Token, second.Token)
+	assert.Equal(t, 2, endpoint.requests(), "a token about to expire is replaced")
+}
+
+func TestInstallationTokenRejectsInvalidKeys(t *testing.T) {
+	endpoint, client, _ := newTokenEndpoint(t, time.Hour)
+
+	_, err := client.GetInstallationToken(context.Background(), "12345", []byte("not a key"), 42)
+	assert.ErrorContains(t, err, "invalid GitHub App private key")
+	assert.Zero(t, endpoint.requests())
+}
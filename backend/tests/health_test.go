@@ -0,0 +1,40 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// Health Tests
+// =============================================================================
+
+func TestReadinessProvidersDoNotGate(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer up.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	health := services.NewHealthService(nil, nil, logger.New())
+	health.AddProvider("up", up.URL)
+	health.AddProvider("down", down.URL)
+
+	readiness := health.Check(context.Background(), false)
+	assert.True(t, readiness.Ready())
+	assert.Empty(t, readiness.Checks, "unconfigured dependencies are not checked")
+	assert.Nil(t, readiness.Providers, "providers are only checked on request")
+
+	readiness = health.Check(context.Background(), true)
+	assert.True(t, readiness.Ready(), "unreachable providers don't fail readiness")
+	assert.Equal(t, "ok", readiness.Providers["up"].Status, "any HTTP response is reachable")
+	assert.Equal(t, "down", readiness.Providers["down"].Status)
+	assert.NotEmpty(t, readiness.Providers["down"].Error)
+}
@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/knowledge"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Ingest Deduplication Tests
+// =============================================================================
+
+// memoryDocuments is an in-memory knowledge.DocumentStore
+type memoryDocuments struct {
+	docs map[uuid.UUID]*models.KnowledgeDocument
+}
+
+func (m *memoryDocuments) GetDocumentBySource(ctx context.Context, kbID uuid.UUID, source string) (*models.KnowledgeDocument, error) {
+	for _, doc := range m.docs {
+		if doc.KnowledgeBaseID == kbID && doc.Source == source {
+			return doc, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryDocuments) CreateDocument(ctx context.Context, doc *models.KnowledgeDocument) error {
+	m.docs[doc.ID] = doc
+	return nil
+}
+
+func (m *memoryDocuments) DeleteDocument(ctx context.Context, id uuid.UUID) error {
+	delete(m.docs, id)
+	return nil
+}
+
+func TestIngestDeduplicatesByContentHash(t *testing.T) {
+	ctx := context.Background()
+	store := knowledge.NewMockVectorStore()
+	documents := &memoryDocuments{docs: make(map[uuid.UUID]*models.KnowledgeDocument)}
+	svc := knowledge.NewService(store, unitEmbedder{}, logger.New())
+	svc.SetDocumentStore(documents)
+
+	kbID := uuid.New()
+	ingest := func(source, content string) *knowledge.IngestResult {
+		t.Helper()
+		result, err := svc.Ingest(ctx, &knowledge.IngestRequest{
+			KnowledgeBaseID: kbID,
+			Source:          source,
+			SourceType:      "text",
+			Content:         content,
+		})
+		require.NoError(t, err)
+		return result
+	}
+	stored := func() []string {
+		t.Helper()
+		results, err := store.Search(ctx, kbID, []float32{1, 0}, 100)
+		require.NoError(t, err)
+		contents := make([]string, len(results))
+		for i, result := range results {
+			contents[i] = result.Content
+		}
+		return contents
+	}
+
+	created := ingest("guide.md", "first version")
+	assert.Equal(t, knowledge.IngestCreated, created.Outcome)
+
+	unchanged := ingest("guide.md", "first version")
+	assert.Equal(t, knowledge.IngestUnchanged, unchanged.Outcome)
+	assert.Equal(t, created.DocumentID, unchanged.DocumentID)
+	assert.Equal(t, []string{"first version"}, stored(), "re-ingesting the same content stores nothing")
+
+	updated := ingest("guide.md", "second version")
+	assert.Equal(t, knowledge.IngestUpdated, updated.Outcome)
+	assert.NotEqual(t, created.DocumentID, updated.DocumentID)
+	assert.Equal(t, []string{"second version"}, stored(), "the previous version's chunks are removed")
+	assert.Len(t, documents.docs, 1)
+
+	other := ingest("faq.md", "second version")
+	assert.Equal(t, knowledge.IngestCreated, other.Outcome, "the same content from another source is its own document")
+	assert.Len(t, documents.docs, 2)
+}
+
+// documentCheckingStore fails to store chunks whose document isn't recorded,
+// as the knowledge_vectors foreign key does, or every chunk if fail is set
+type documentCheckingStore struct {
+	*knowledge.MockVectorStore
+	documents *memoryDocuments
+	fail      bool
+}
+
+func (s *documentCheckingStore) StoreChunks(ctx context.Context, kbID uuid.UUID, chunks []knowledge.Chunk) error {
+	if s.fail {
+		return assert.AnError
+	}
+	for _, chunk := range chunks {
+		if _, ok := s.documents.docs[chunk.DocumentID]; !ok {
+			return assert.AnError
+		}
+	}
+	return s.MockVectorStore.StoreChunks(ctx, kbID, chunks)
+}
+
+func TestIngestRecordsDocumentBeforeChunks(t *testing.T) {
+	ctx := context.Background()
+	documents := &memoryDocuments{docs: make(map[uuid.UUID]*models.KnowledgeDocument)}
+	store := &documentCheckingStore{MockVectorStore: knowledge.NewMockVectorStore(), documents: documents}
+	svc := knowledge.NewService(store, unitEmbedder{}, logger.New())
+	svc.SetDocumentStore(documents)
+
+	req := &knowledge.IngestRequest{
+		KnowledgeBaseID: uuid.New(),
+		Source:          "guide.md",
+		SourceType:      "text",
+		Content:         "first version",
+	}
+	first, err := svc.Ingest(ctx, req)
+	require.NoError(t, err)
+	assert.Len(t, documents.docs, 1)
+
+	// A document whose chunks can't be stored isn't left recorded, so the
+	// next ingest of its source isn't skipped as unchanged
+	store.fail = true
+	req.Content = "second version"
+	_, err = svc.Ingest(ctx, req)
+	require.Error(t, err)
+	assert.Len(t, documents.docs, 1)
+	assert.Contains(t, documents.docs, first.DocumentID)
+
+	store.fail = false
+	result, err := svc.Ingest(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, knowledge.IngestUpdated, result.Outcome)
+}
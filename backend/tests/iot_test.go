@@ -0,0 +1,370 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/iot"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// IoT Tests
+// =============================================================================
+
+// memoryCommandStore is an in-memory iot.CommandStore
+type memoryCommandStore struct {
+	mu       sync.Mutex
+	commands map[uuid.UUID]iot.Command
+}
+
+func newMemoryCommandStore() *memoryCommandStore {
+	return &memoryCommandStore{commands: make(map[uuid.UUID]iot.Command)}
+}
+
+var commandRank = map[string]int{
+	iot.CommandStatusPending:      0,
+	iot.CommandStatusSent:         1,
+	iot.CommandStatusAcknowledged: 2,
+	iot.CommandStatusCompleted:    3,
+	iot.CommandStatusFailed:       3,
+}
+
+func (s *memoryCommandStore) Save(ctx context.Context, cmd *iot.Command) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stored, ok := s.commands[cmd.ID]; ok && commandRank[cmd.Status] < commandRank[stored.Status] {
+		return nil
+	}
+	s.commands[cmd.ID] = *cmd
+	return nil
+}
+
+func (s *memoryCommandStore) Get(ctx context.Context, commandID uuid.UUID) (*iot.Command, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cmd, ok := s.commands[commandID]
+	if !ok {
+		return nil, nil
+	}
+	return &cmd, nil
+}
+
+func (s *memoryCommandStore) FailExpired(ctx context.Context, sentBefore time.Time, reason string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var failed int64
+	for id, cmd := range s.commands {
+		if cmd.Status == iot.CommandStatusSent && cmd.SentAt != nil && cmd.SentAt.Before(sentBefore) {
+			cmd.Status = iot.CommandStatusFailed
+			cmd.Error = reason
+			s.commands[id] = cmd
+			failed++
+		}
+	}
+	return failed, nil
+}
+
+func (s *memoryCommandStore) status(commandID uuid.UUID) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commands[commandID].Status
+}
+
+func ackTopic(deviceID uuid.UUID) string {
+	return "devices/" + deviceID.String() + "/commands/ack"
+}
+
+// sentCommand registers a device and sends it a command, waiting until the
+// command is sent
+func sentCommand(t *testing.T, svc *iot.Service, broker *fakeBroker) (*iot.Device, *iot.Command) {
+	t.Helper()
+	device := &iot.Device{TenantID: uuid.New(), Name: "valve", Type: iot.DeviceTypeActuator}
+	require.NoError(t, svc.RegisterDevice(context.Background(), device))
+
+	cmd := &iot.Command{DeviceID: device.ID, Action: "open"}
+	require.NoError(t, svc.SendCommand(context.Background(), cmd))
+	require.Eventually(t, func() bool {
+		sent, err := svc.GetCommand(context.Background(), cmd.ID)
+		return err == nil && sent.Status == iot.CommandStatusSent
+	}, time.Second, 10*time.Millisecond)
+	return device, cmd
+}
+
+func TestCommandAcksMoveCommandsForward(t *testing.T) {
+	broker := newFakeBroker()
+	store := newMemoryCommandStore()
+	svc := newMQTTService(broker)
+	svc.SetCommandStore(store)
+	defer svc.Stop()
+
+	device, cmd := sentCommand(t, svc, broker)
+	assert.Equal(t, iot.CommandStatusSent, store.status(cmd.ID))
+
+	// Devices report through their ack topic
+	ack := `{"id": "` + cmd.ID.String() + `", "status": "acknowledged"}`
+	require.True(t, broker.deliver(ackTopic(device.ID), []byte(ack)))
+	got, err := svc.GetCommand(context.Background(), cmd.ID)
+	require.NoError(t, err)
+	assert.Equal(t, iot.CommandStatusAcknowledged, got.Status)
+	require.NotNil(t, got.AcknowledgedAt)
+	assert.Nil(t, got.CompletedAt)
+	acknowledgedAt := *got.AcknowledgedAt
+
+	// Repeating an acknowledgement is a no-op
+	require.NoError(t, svc.IngestCommandAck(context.Background(), cmd.ID, iot.CommandResult{Status: iot.CommandStatusAcknowledged}))
+
+	// An empty status completes the command
+	output := map[string]interface{}{"position": "open"}
+	require.NoError(t, svc.IngestCommandAck(context.Background(), cmd.ID, iot.CommandResult{Output: output}))
+	got, err = svc.GetCommand(context.Background(), cmd.ID)
+	require.NoError(t, err)
+	assert.Equal(t, iot.CommandStatusCompleted, got.Status)
+	assert.Equal(t, output, got.Result)
+	assert.Equal(t, acknowledgedAt, *got.AcknowledgedAt)
+	require.NotNil(t, got.CompletedAt)
+	assert.Equal(t, iot.CommandStatusCompleted, store.status(cmd.ID))
+
+	err = svc.IngestCommandAck(context.Background(), cmd.ID, iot.CommandResult{Status: iot.CommandStatusFailed})
+	assert.EqualError(t, err, "command already completed")
+	err = svc.IngestCommandAck(context.Background(), cmd.ID, iot.CommandResult{Status: iot.CommandStatusSent})
+	assert.EqualError(t, err, "invalid command status: sent")
+	_, err = svc.GetCommand(context.Background(), uuid.New())
+	assert.EqualError(t, err, "command not found")
+}
+
+func TestCommandAcksFromAnotherDeviceAreIgnored(t *testing.T) {
+	broker := newFakeBroker()
+	svc := newMQTTService(broker)
+	defer svc.Stop()
+
+	_, cmd := sentCommand(t, svc, broker)
+	other, _ := sentCommand(t, svc, broker)
+
+	ack := `{"id": "` + cmd.ID.String() + `", "status": "completed"}`
+	require.True(t, broker.deliver(ackTopic(other.ID), []byte(ack)))
+	got, err := svc.GetCommand(context.Background(), cmd.ID)
+	require.NoError(t, err)
+	assert.Equal(t, iot.CommandStatusSent, got.Status)
+}
+
+func TestUnacknowledgedCommandsTimeOut(t *testing.T) {
+	broker := newFakeBroker()
+	store := newMemoryCommandStore()
+	svc := newMQTTService(broker)
+	svc.SetCommandStore(store)
+	svc.SetCommandTimeout(time.Minute)
+	defer svc.Stop()
+
+	_, expiring := sentCommand(t, svc, broker)
+	_, acknowledged := sentCommand(t, svc, broker)
+	require.NoError(t, svc.IngestCommandAck(context.Background(), acknowledged.ID, iot.CommandResult{Status: iot.CommandStatusAcknowledged}))
+
+	// Nothing times out before the timeout passes
+	svc.ExpireCommands(time.Now())
+	got, err := svc.GetCommand(context.Background(), expiring.ID)
+	require.NoError(t, err)
+	assert.Equal(t, iot.CommandStatusSent, got.Status)
+
+	svc.ExpireCommands(time.Now().Add(2 * time.Minute))
+	got, err = svc.GetCommand(context.Background(), expiring.ID)
+	require.NoError(t, err)
+	assert.Equal(t, iot.CommandStatusFailed, got.Status)
+	assert.Equal(t, "no acknowledgement from device within 1m0s", got.Error)
+	require.NotNil(t, got.CompletedAt)
+	assert.Equal(t, iot.CommandStatusFailed, store.status(expiring.ID))
+
+	got, err = svc.GetCommand(context.Background(), acknowledged.ID)
+	require.NoError(t, err)
+	assert.Equal(t, iot.CommandStatusAcknowledged, got.Status, "acknowledged commands don't time out")
+
+	err = svc.IngestCommandAck(context.Background(), expiring.ID, iot.CommandResult{})
+	assert.EqualError(t, err, "command already failed", "a late result doesn't revive a timed out command")
+
+	// Finished commands are dropped from memory after an hour but are still
+	// found in the store
+	svc.ExpireCommands(time.Now().Add(3 * time.Hour))
+	got, err = svc.GetCommand(context.Background(), expiring.ID)
+	require.NoError(t, err)
+	assert.Equal(t, iot.CommandStatusFailed, got.Status)
+}
+
+// batchTelemetryStore records the size of each write
+type batchTelemetryStore struct {
+	mu      sync.Mutex
+	batches []int
+	fail    bool
+}
+
+func (s *batchTelemetryStore) Write(ctx context.Context, points ...iot.DataPoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return context.DeadlineExceeded
+	}
+	s.batches = append(s.batches, len(points))
+	return nil
+}
+
+func (s *batchTelemetryStore) Query(ctx context.Context, deviceID uuid.UUID, from, to time.Time, limit int) ([]iot.DataPoint, error) {
+	return []iot.DataPoint{{DeviceID: deviceID, Timestamp: from}}, nil
+}
+
+func (s *batchTelemetryStore) written() (batches []int, points int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, n := range s.batches {
+		points += n
+	}
+	return append([]int{}, s.batches...), points
+}
+
+func ingestPoints(t *testing.T, svc *iot.Service, n int) {
+	t.Helper()
+	device := uuid.New()
+	for i := 0; i < n; i++ {
+		require.NoError(t, svc.IngestData(context.Background(), iot.DataPoint{
+			DeviceID:  device,
+			Timestamp: time.Now(),
+			Data:      map[string]interface{}{"reading": float64(i)},
+		}))
+	}
+}
+
+func TestTelemetryIsWrittenInBatches(t *testing.T) {
+	store := &batchTelemetryStore{}
+	svc := iot.NewService(logger.New())
+	svc.SetTelemetryStore(store)
+	defer svc.Stop()
+
+	// A full batch is written straight away, the rest once the interval passes
+	ingestPoints(t, svc, 1200)
+	require.Eventually(t, func() bool {
+		_, points := store.written()
+		return points == 1200
+	}, 3*time.Second, 10*time.Millisecond)
+
+	batches, _ := store.written()
+	assert.Contains(t, batches, 500)
+	for _, n := range batches {
+		assert.LessOrEqual(t, n, 500)
+	}
+}
+
+func TestTelemetryWriteFailuresDropTheBatch(t *testing.T) {
+	store := &batchTelemetryStore{fail: true}
+	svc := iot.NewService(logger.New())
+	svc.SetTelemetryStore(store)
+
+	ingestPoints(t, svc, 10)
+	require.Eventually(t, func() bool { return svc.DataQueueDepth() == 0 }, time.Second, 10*time.Millisecond)
+	time.Sleep(1500 * time.Millisecond)
+
+	store.mu.Lock()
+	store.fail = false
+	store.mu.Unlock()
+	ingestPoints(t, svc, 5)
+	svc.Stop()
+
+	batches, _ := store.written()
+	assert.Equal(t, []int{5}, batches, "failed points aren't retried with later ones")
+}
+
+func TestQueryTelemetryNeedsAStore(t *testing.T) {
+	svc := iot.NewService(logger.New())
+	defer svc.Stop()
+
+	device := uuid.New()
+	_, err := svc.QueryTelemetry(context.Background(), device, time.Now().Add(-time.Hour), time.Now(), 10)
+	assert.EqualError(t, err, "telemetry store not configured")
+
+	svc.SetTelemetryStore(&batchTelemetryStore{})
+	points, err := svc.QueryTelemetry(context.Background(), device, time.Now().Add(-time.Hour), time.Now(), 10)
+	require.NoError(t, err)
+	require.Len(t, points, 1)
+	assert.Equal(t, device, points[0].DeviceID)
+}
+
+func TestDeviceChangesFromOtherInstancesAreSeen(t *testing.T) {
+	broker := newFakeBroker()
+	store := &memoryDeviceStore{devices: make(map[uuid.UUID]*iot.Device)}
+	svc := newMQTTService(broker)
+	svc.SetDeviceStore(store)
+	defer svc.Stop()
+	other := iot.NewService(logger.New())
+	other.SetDeviceStore(store)
+	defer other.Stop()
+
+	tenant := uuid.New()
+	device := &iot.Device{TenantID: tenant, Name: "valve", Type: iot.DeviceTypeActuator}
+	require.NoError(t, svc.RegisterDevice(context.Background(), device))
+	require.Eventually(t, func() bool { return broker.subscribed(telemetryTopic(device.ID)) }, time.Second, 10*time.Millisecond)
+
+	// Another instance moves the device's commands to a new topic
+	changed := *device
+	changed.Name = "main valve"
+	changed.Config.MQTT = &iot.MQTTSettings{CommandTopic: "plant/{device_id}/commands"}
+	require.NoError(t, other.UpdateDevice(context.Background(), &changed))
+
+	got, err := svc.GetDevice(context.Background(), tenant, device.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "main valve", got.Name)
+	_, err = svc.GetDevice(context.Background(), uuid.New(), device.ID)
+	assert.EqualError(t, err, "device not found", "devices belong to their tenant")
+
+	require.NoError(t, svc.SendCommand(context.Background(), &iot.Command{DeviceID: device.ID, Action: "open"}))
+	require.Eventually(t, func() bool {
+		return broker.message("plant/"+device.ID.String()+"/commands") != nil
+	}, time.Second, 10*time.Millisecond, "the device is reconnected with its new settings")
+
+	// Once deleted elsewhere, the device is gone and disconnected here too
+	require.NoError(t, other.DeleteDevice(context.Background(), tenant, device.ID))
+	_, err = svc.GetDevice(context.Background(), tenant, device.ID)
+	assert.EqualError(t, err, "device not found")
+	assert.Eventually(t, func() bool { return !broker.connected() }, time.Second, 10*time.Millisecond)
+}
+
+func TestListedDevicesKeepTheirLiveState(t *testing.T) {
+	broker := newFakeBroker()
+	store := &memoryDeviceStore{devices: make(map[uuid.UUID]*iot.Device)}
+	svc := newMQTTService(broker)
+	svc.SetDeviceStore(store)
+	defer svc.Stop()
+	other := iot.NewService(logger.New())
+	other.SetDeviceStore(store)
+	defer other.Stop()
+
+	tenant := uuid.New()
+	local := &iot.Device{TenantID: tenant, Name: "thermostat", Type: iot.DeviceTypeSensor}
+	require.NoError(t, svc.RegisterDevice(context.Background(), local))
+	require.Eventually(t, func() bool { return broker.subscribed(telemetryTopic(local.ID)) }, time.Second, 10*time.Millisecond)
+	require.True(t, broker.deliver(telemetryTopic(local.ID), []byte(`{"temperature": 21.5}`)))
+
+	// A device registered on another instance is listed, and connected here
+	remote := &iot.Device{TenantID: tenant, Name: "camera", Type: iot.DeviceTypeCamera}
+	require.NoError(t, other.RegisterDevice(context.Background(), remote))
+	require.NoError(t, other.RegisterDevice(context.Background(), &iot.Device{TenantID: uuid.New(), Type: iot.DeviceTypeSensor}))
+
+	devices, err := svc.ListDevices(context.Background(), tenant, nil)
+	require.NoError(t, err)
+	require.Len(t, devices, 2)
+	for _, device := range devices {
+		if device.ID == local.ID {
+			assert.Equal(t, iot.DeviceStatusOnline, device.Status, "the store lags behind the device's status")
+			assert.Equal(t, 21.5, device.LastData["temperature"])
+		} else {
+			assert.Equal(t, remote.ID, device.ID)
+		}
+	}
+	assert.Eventually(t, func() bool { return broker.subscribed(telemetryTopic(remote.ID)) }, time.Second, 10*time.Millisecond)
+
+	sensors, err := svc.ListDevices(context.Background(), tenant, map[string]interface{}{"type": iot.DeviceTypeSensor})
+	require.NoError(t, err)
+	require.Len(t, sensors, 1)
+	assert.Equal(t, local.ID, sensors[0].ID)
+}
@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/handlers"
+	"github.com/delphi-platform/delphi/backend/internal/knowledge"
+	"github.com/delphi-platform/delphi/backend/internal/middleware"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Knowledge Query Tests
+// =============================================================================
+
+func TestKnowledgeQueryRanking(t *testing.T) {
+	ctx := context.Background()
+	store := knowledge.NewMockVectorStore()
+	svc := knowledge.NewService(store, unitEmbedder{}, logger.New())
+
+	// Scores are interleaved between the two knowledge bases
+	first, second := uuid.New(), uuid.New()
+	require.NoError(t, store.StoreChunks(ctx, first, []knowledge.Chunk{
+		scoredChunk("first-high", 0.9),
+		scoredChunk("first-low", 0.5),
+		scoredChunk("first-lowest", 0.1),
+	}))
+	require.NoError(t, store.StoreChunks(ctx, second, []knowledge.Chunk{
+		scoredChunk("second-highest", 0.95),
+		scoredChunk("second-mid", 0.7),
+		scoredChunk("second-low", 0.3),
+	}))
+
+	t.Run("returns the global top k", func(t *testing.T) {
+		result, err := svc.Query(ctx, &knowledge.QueryRequest{
+			KnowledgeBaseIDs: []uuid.UUID{first, second},
+			Query:            "anything",
+			Limit:            3,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"second-highest", "first-high", "second-mid"}, resultContents(result))
+		assert.InDelta(t, 0.95, result.Results[0].Score, 1e-5)
+	})
+
+	t.Run("applies min score before the limit", func(t *testing.T) {
+		result, err := svc.Query(ctx, &knowledge.QueryRequest{
+			KnowledgeBaseIDs: []uuid.UUID{first, second},
+			Query:            "anything",
+			Limit:            10,
+			MinScore:         0.6,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"second-highest", "first-high", "second-mid"}, resultContents(result))
+	})
+}
+
+// unitEmbedder embeds every text as the unit vector along the first axis
+type unitEmbedder struct{}
+
+func (unitEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	return []float32{1, 0}, nil
+}
+
+func (e unitEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	for i := range texts {
+		embeddings[i], _ = e.Embed(ctx, texts[i])
+	}
+	return embeddings, nil
+}
+
+func (unitEmbedder) Dimension() int {
+	return 2
+}
+
+// scoredChunk returns a chunk whose cosine similarity to unitEmbedder's vector is score
+func scoredChunk(content string, score float64) knowledge.Chunk {
+	return knowledge.Chunk{
+		ID:         uuid.New(),
+		DocumentID: uuid.New(),
+		Content:    content,
+		Embedding:  []float32{float32(score), float32(math.Sqrt(1 - score*score))},
+	}
+}
+
+func resultContents(result *knowledge.QueryResult) []string {
+	contents := make([]string, len(result.Results))
+	for i, r := range result.Results {
+		contents[i] = r.Content
+	}
+	return contents
+}
+
+func TestKnowledgeHybridQuery(t *testing.T) {
+	ctx := context.Background()
+	store := knowledge.NewMockVectorStore()
+	svc := knowledge.NewService(store, unitEmbedder{}, logger.New())
+
+	kbID := uuid.New()
+	require.NoError(t, store.StoreChunks(ctx, kbID, []knowledge.Chunk{
+		scoredChunk("retries are configured per provider", 0.9),
+		scoredChunk("the dashboard shows daily costs", 0.6),
+		scoredChunk("parse_config returns ERR_4012 for unknown keys", 0.5),
+		scoredChunk("webhooks are signed", 0.1),
+	}))
+
+	query := func(hybrid bool) *knowledge.QueryResult {
+		t.Helper()
+		result, err := svc.Query(ctx, &knowledge.QueryRequest{
+			KnowledgeBaseIDs: []uuid.UUID{kbID},
+			Query:            "why does parse_config fail with ERR_4012",
+			Limit:            3,
+			HybridSearch:     hybrid,
+		})
+		require.NoError(t, err)
+		return result
+	}
+
+	vector := query(false)
+	assert.Equal(t, "parse_config returns ERR_4012 for unknown keys", vector.Results[2].Content, "vector search ranks the exact terms last")
+	assert.Nil(t, vector.Results[0].Signals)
+
+	hybrid := query(true)
+	assert.Equal(t, []string{
+		"parse_config returns ERR_4012 for unknown keys",
+		"retries are configured per provider",
+		"the dashboard shows daily costs",
+	}, resultContents(hybrid))
+
+	signals := hybrid.Results[0].Signals
+	require.NotNil(t, signals)
+	assert.Equal(t, 3, signals.VectorRank)
+	assert.InDelta(t, 0.5, signals.Vector, 1e-5)
+	assert.Equal(t, 1, signals.KeywordRank)
+	assert.InDelta(t, 2.0/6, signals.Keyword, 1e-5, "two of the query's six terms match")
+	assert.InDelta(t, 1.0/63+1.0/61, hybrid.Results[0].Score, 1e-6, "scores are fused by reciprocal rank")
+	assert.Equal(t, 0, hybrid.Results[1].Signals.KeywordRank, "chunks without keyword matches keep their vector rank")
+}
+
+func TestTenantDefaultModel(t *testing.T) {
+	provider, model, err := services.TenantDefaultModel(&models.Tenant{})
+	require.NoError(t, err)
+	assert.Equal(t, models.ProviderOpenAI, provider)
+	assert.Equal(t, "gpt-4o-mini", model)
+
+	provider, model, err = services.TenantDefaultModel(&models.Tenant{Settings: json.RawMessage(`{"default_provider": "anthropic"}`)})
+	require.NoError(t, err)
+	assert.Equal(t, models.ProviderAnthropic, provider)
+	assert.Empty(t, model, "the provider's first model is used")
+
+	_, _, err = services.TenantDefaultModel(&models.Tenant{Settings: json.RawMessage(`{"default_provider": 7}`)})
+	assert.ErrorContains(t, err, "failed to read tenant settings")
+}
+
+// Questions are checked before the knowledge base is loaded, so bad ones need
+// no database
+func TestKnowledgeAskRejectsBadQuestions(t *testing.T) {
+	handler := handlers.NewKnowledgeHandler(services.NewKnowledgeService(nil, nil, nil, nil, logger.New()), logger.New())
+
+	ask := func(kbID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/knowledge-bases/"+kbID+"/ask", strings.NewReader(body))
+		routeCtx := chi.NewRouteContext()
+		routeCtx.URLParams.Add("kbID", kbID)
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx)
+		ctx = context.WithValue(ctx, middleware.TenantIDKey, uuid.New())
+		w := httptest.NewRecorder()
+		handler.Ask(w, req.WithContext(ctx))
+		return w
+	}
+
+	assert.Equal(t, http.StatusBadRequest, ask("not-a-uuid", `{"question": "why?"}`).Code)
+	w := ask(uuid.NewString(), `{"question": "  "}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "question is required")
+	w = ask(uuid.NewString(), `{"question": "`+strings.Repeat("x", 4001)+`"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "maximum length")
+}
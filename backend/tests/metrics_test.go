@@ -0,0 +1,41 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/metrics"
+	"github.com/delphi-platform/delphi/backend/internal/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Metrics Tests
+// =============================================================================
+
+func scrapeMetrics(t *testing.T) string {
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestMetricsRecordProviderRequestsAndExecutions(t *testing.T) {
+	provider := providers.Instrument(&scriptedProvider{responses: []*providers.CompletionResponse{{}}})
+	_, err := provider.Complete(context.Background(), &providers.CompletionRequest{})
+	require.NoError(t, err)
+
+	metrics.RecordExecution("completed", "metrics-test", "pro", 100, 20, 0.5)
+	metrics.RegisterQueue("metrics_test", func() int { return 7 })
+
+	body := scrapeMetrics(t)
+	assert.Contains(t, body, `delphi_provider_request_duration_seconds_count{operation="complete",outcome="success",provider="scripted"} 1`)
+	assert.Contains(t, body, `delphi_executions_total{provider="metrics-test",status="completed",tier="pro"} 1`)
+	assert.Contains(t, body, `delphi_tokens_total{direction="input",provider="metrics-test",tier="pro"} 100`)
+	assert.Contains(t, body, `delphi_tokens_total{direction="output",provider="metrics-test",tier="pro"} 20`)
+	assert.Contains(t, body, `delphi_queue_depth{queue="metrics_test"} 7`)
+}
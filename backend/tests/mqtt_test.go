@@ -0,0 +1,352 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/iot"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// MQTT Tests
+// =============================================================================
+
+// fakeBroker routes messages between the fake clients created from it
+type fakeBroker struct {
+	mu        sync.Mutex
+	clients   []*fakeMQTTClient
+	subs      map[string]mqtt.MessageHandler
+	published map[string][]byte
+}
+
+func newFakeBroker() *fakeBroker {
+	return &fakeBroker{subs: make(map[string]mqtt.MessageHandler), published: make(map[string][]byte)}
+}
+
+func (b *fakeBroker) newClient(opts *mqtt.ClientOptions) mqtt.Client {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	client := &fakeMQTTClient{broker: b, opts: opts}
+	b.clients = append(b.clients, client)
+	return client
+}
+
+// deliver sends a message to the topic's subscriber, reporting whether there was one
+func (b *fakeBroker) deliver(topic string, payload []byte) bool {
+	b.mu.Lock()
+	handler, ok := b.subs[topic]
+	b.mu.Unlock()
+	if ok {
+		handler(nil, &fakeMessage{topic: topic, payload: payload})
+	}
+	return ok
+}
+
+func (b *fakeBroker) client(i int) *fakeMQTTClient {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.clients[i]
+}
+
+// connected reports whether any client is still connected
+func (b *fakeBroker) connected() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, client := range b.clients {
+		if client.IsConnected() {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *fakeBroker) clientCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.clients)
+}
+
+func (b *fakeBroker) subscribed(topic string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.subs[topic]
+	return ok
+}
+
+func (b *fakeBroker) message(topic string) []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.published[topic]
+}
+
+// fakeMQTTClient is an mqtt.Client connected to a fakeBroker
+type fakeMQTTClient struct {
+	broker *fakeBroker
+	opts   *mqtt.ClientOptions
+
+	mu        sync.Mutex
+	connected bool
+}
+
+func (c *fakeMQTTClient) IsConnected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connected
+}
+
+func (c *fakeMQTTClient) IsConnectionOpen() bool { return c.IsConnected() }
+
+// Connect connects and runs the on-connect handler, as a reconnect does too
+func (c *fakeMQTTClient) Connect() mqtt.Token {
+	c.mu.Lock()
+	c.connected = true
+	c.mu.Unlock()
+	if c.opts.OnConnect != nil {
+		c.opts.OnConnect(c)
+	}
+	return doneToken{}
+}
+
+func (c *fakeMQTTClient) Disconnect(quiesce uint) {
+	c.mu.Lock()
+	c.connected = false
+	c.mu.Unlock()
+}
+
+func (c *fakeMQTTClient) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+	c.broker.published[topic] = payload.([]byte)
+	return doneToken{}
+}
+
+func (c *fakeMQTTClient) Subscribe(topic string, qos byte, callback mqtt.MessageHandler) mqtt.Token {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+	c.broker.subs[topic] = callback
+	return doneToken{}
+}
+
+func (c *fakeMQTTClient) SubscribeMultiple(filters map[string]byte, callback mqtt.MessageHandler) mqtt.Token {
+	for topic, qos := range filters {
+		c.Subscribe(topic, qos, callback)
+	}
+	return doneToken{}
+}
+
+func (c *fakeMQTTClient) Unsubscribe(topics ...string) mqtt.Token {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+	for _, topic := range topics {
+		delete(c.broker.subs, topic)
+	}
+	return doneToken{}
+}
+
+func (c *fakeMQTTClient) AddRoute(topic string, callback mqtt.MessageHandler) {}
+
+func (c *fakeMQTTClient) OptionsReader() mqtt.ClientOptionsReader {
+	return mqtt.NewOptionsReader(c.opts)
+}
+
+// doneToken is an MQTT token for an operation that has already succeeded
+type doneToken struct{}
+
+func (doneToken) Wait() bool                     { return true }
+func (doneToken) WaitTimeout(time.Duration) bool { return true }
+func (doneToken) Error() error                   { return nil }
+func (doneToken) Done() <-chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+
+// fakeMessage is a message delivered by a fakeBroker
+type fakeMessage struct {
+	topic   string
+	payload []byte
+}
+
+func (m *fakeMessage) Duplicate() bool   { return false }
+func (m *fakeMessage) Qos() byte         { return 1 }
+func (m *fakeMessage) Retained() bool    { return false }
+func (m *fakeMessage) Topic() string     { return m.topic }
+func (m *fakeMessage) MessageID() uint16 { return 0 }
+func (m *fakeMessage) Payload() []byte   { return m.payload }
+func (m *fakeMessage) Ack()              {}
+
+// memoryDeviceStore is an in-memory iot.DeviceStore. Like a database, it
+// hands out copies of the devices it holds.
+type memoryDeviceStore struct {
+	mu      sync.Mutex
+	devices map[uuid.UUID]*iot.Device
+}
+
+func (s *memoryDeviceStore) Create(ctx context.Context, device *iot.Device) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := *device
+	s.devices[device.ID] = &stored
+	return nil
+}
+
+func (s *memoryDeviceStore) Get(ctx context.Context, deviceID uuid.UUID) (*iot.Device, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	device, ok := s.devices[deviceID]
+	if !ok {
+		return nil, nil
+	}
+	stored := *device
+	return &stored, nil
+}
+
+func (s *memoryDeviceStore) Update(ctx context.Context, device *iot.Device) error {
+	return s.Create(ctx, device)
+}
+
+func (s *memoryDeviceStore) UpdateStatus(ctx context.Context, deviceID uuid.UUID, status iot.DeviceStatus, lastSeen time.Time) error {
+	return nil
+}
+
+func (s *memoryDeviceStore) Delete(ctx context.Context, deviceID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.devices, deviceID)
+	return nil
+}
+
+func (s *memoryDeviceStore) List(ctx context.Context, tenantID uuid.UUID, filter iot.DeviceFilter) ([]*iot.Device, error) {
+	all, _ := s.ListAll(ctx)
+	var devices []*iot.Device
+	for _, device := range all {
+		if device.TenantID == tenantID && (filter.Type == "" || device.Type == filter.Type) {
+			devices = append(devices, device)
+		}
+	}
+	return devices, nil
+}
+
+func (s *memoryDeviceStore) ListAll(ctx context.Context) ([]*iot.Device, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var devices []*iot.Device
+	for _, device := range s.devices {
+		stored := *device
+		devices = append(devices, &stored)
+	}
+	return devices, nil
+}
+
+// newMQTTService returns an IoT service whose MQTT adapter connects to broker
+func newMQTTService(broker *fakeBroker) *iot.Service {
+	adapter := iot.NewMQTTAdapter(iot.DefaultMQTTConfig("tcp://broker.test:1883"), logger.New())
+	adapter.SetClientFactory(broker.newClient)
+	svc := iot.NewService(logger.New())
+	svc.RegisterAdapter("mqtt", adapter)
+	return svc
+}
+
+func telemetryTopic(deviceID uuid.UUID) string {
+	return "devices/" + deviceID.String() + "/telemetry"
+}
+
+func commandTopic(deviceID uuid.UUID) string {
+	return "devices/" + deviceID.String() + "/commands"
+}
+
+func TestRegisteredDeviceTelemetryIsIngested(t *testing.T) {
+	broker := newFakeBroker()
+	telemetry := &memoryTelemetryStore{}
+	svc := newMQTTService(broker)
+	svc.SetTelemetryStore(telemetry)
+
+	tenant := uuid.New()
+	device := &iot.Device{TenantID: tenant, Name: "thermostat", Type: iot.DeviceTypeSensor}
+	require.NoError(t, svc.RegisterDevice(context.Background(), device))
+
+	// Sending a command waits for the device's connection
+	topic := telemetryTopic(device.ID)
+	require.NoError(t, svc.SendCommand(context.Background(), &iot.Command{DeviceID: device.ID, Action: "ping"}))
+	require.Eventually(t, func() bool { return broker.message(commandTopic(device.ID)) != nil }, time.Second, 10*time.Millisecond)
+	require.True(t, broker.deliver(topic, []byte(`{"temperature": 21.5}`)))
+	assert.True(t, broker.deliver(topic, []byte(`not json`)), "malformed telemetry is ignored")
+
+	svc.Stop()
+	telemetry.mu.Lock()
+	defer telemetry.mu.Unlock()
+	require.Len(t, telemetry.points, 1)
+	assert.Equal(t, device.ID, telemetry.points[0].DeviceID)
+	assert.Equal(t, 21.5, telemetry.points[0].Data["temperature"])
+
+	got, err := svc.GetDevice(context.Background(), tenant, device.ID)
+	require.NoError(t, err)
+	assert.Equal(t, iot.DeviceStatusOnline, got.Status)
+	assert.False(t, broker.connected(), "stopping disconnects the device")
+}
+
+func TestLoadedDevicesAreConnected(t *testing.T) {
+	broker := newFakeBroker()
+	telemetry := &memoryTelemetryStore{}
+	device := &iot.Device{ID: uuid.New(), TenantID: uuid.New(), Type: iot.DeviceTypeSensor}
+	store := &memoryDeviceStore{devices: map[uuid.UUID]*iot.Device{device.ID: device}}
+
+	svc := newMQTTService(broker)
+	svc.SetTelemetryStore(telemetry)
+	svc.SetDeviceStore(store)
+	require.NoError(t, svc.LoadDevices(context.Background()))
+
+	topic := telemetryTopic(device.ID)
+	require.Eventually(t, func() bool { return broker.subscribed(topic) }, time.Second, 10*time.Millisecond)
+
+	// Sending a command reuses the connection, and a reconnect renews the
+	// subscriptions, without adding another telemetry handler
+	require.NoError(t, svc.SendCommand(context.Background(), &iot.Command{DeviceID: device.ID, Action: "ping"}))
+	require.Eventually(t, func() bool { return broker.message(commandTopic(device.ID)) != nil }, time.Second, 10*time.Millisecond)
+	broker.client(0).Connect()
+	require.True(t, broker.deliver(topic, []byte(`{"humidity": 40}`)))
+
+	svc.Stop()
+	telemetry.mu.Lock()
+	defer telemetry.mu.Unlock()
+	assert.Len(t, telemetry.points, 1)
+	assert.Equal(t, 1, broker.clientCount(), "the device keeps one client")
+}
+
+func TestCommandsConnectAndPublish(t *testing.T) {
+	broker := newFakeBroker()
+	svc := newMQTTService(broker)
+	defer svc.Stop()
+
+	device := &iot.Device{TenantID: uuid.New(), Name: "valve", Type: iot.DeviceTypeActuator}
+	require.NoError(t, svc.RegisterDevice(context.Background(), device))
+	require.Eventually(t, func() bool { return broker.subscribed(telemetryTopic(device.ID)) }, time.Second, 10*time.Millisecond)
+
+	cmd := &iot.Command{DeviceID: device.ID, Action: "open"}
+	require.NoError(t, svc.SendCommand(context.Background(), cmd))
+
+	require.Eventually(t, func() bool { return broker.message(commandTopic(device.ID)) != nil }, time.Second, 10*time.Millisecond)
+	var published struct {
+		ID     uuid.UUID `json:"id"`
+		Action string    `json:"action"`
+	}
+	require.NoError(t, json.Unmarshal(broker.message(commandTopic(device.ID)), &published))
+	assert.Equal(t, cmd.ID, published.ID)
+	assert.Equal(t, "open", published.Action)
+
+	require.Eventually(t, func() bool {
+		sent, err := svc.GetCommand(context.Background(), cmd.ID)
+		return err == nil && sent.Status == iot.CommandStatusSent
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, svc.DeleteDevice(context.Background(), device.TenantID, device.ID))
+	assert.False(t, broker.connected(), "deleting a device disconnects it")
+}
@@ -0,0 +1,228 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/notifications"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Notification Tests
+// =============================================================================
+
+// smtpMessage is a message received by a fakeSMTPServer
+type smtpMessage struct {
+	rcpts []string
+	data  string
+}
+
+// fakeSMTPServer accepts any credentials and records the messages it gets
+type fakeSMTPServer struct {
+	listener net.Listener
+	reject   map[string]bool // recipients refused with a 550
+
+	mu       sync.Mutex
+	messages []smtpMessage
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &fakeSMTPServer{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 fake ESMTP")
+	var msg smtpMessage
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"):
+			reply("250-fake")
+			reply("250 AUTH PLAIN")
+		case strings.HasPrefix(command, "AUTH"):
+			reply("235 accepted")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			rcpt := strings.Trim(strings.TrimSpace(line)[len("RCPT TO:"):], "<>")
+			if s.reject[rcpt] {
+				reply("550 no such user")
+				continue
+			}
+			msg.rcpts = append(msg.rcpts, rcpt)
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			msg.data = data.String()
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			msg = smtpMessage{}
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func (s *fakeSMTPServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTPServer) received() []smtpMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]smtpMessage(nil), s.messages...)
+}
+
+// fakeNotificationUsers serves a tenant's users, added recipients and the
+// users' preferences, counting preference lookups
+type fakeNotificationUsers struct {
+	users       []*notifications.User
+	added       []string
+	emailOff    map[uuid.UUID]bool
+	singles     int
+	bulks       int
+	bulkUserIDs []uuid.UUID
+}
+
+func (f *fakeNotificationUsers) GetUser(ctx context.Context, userID uuid.UUID) (*notifications.User, error) {
+	for _, user := range f.users {
+		if user.ID == userID {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeNotificationUsers) ListTenantUsers(ctx context.Context, tenantID uuid.UUID) ([]*notifications.User, error) {
+	return f.users, nil
+}
+
+func (f *fakeNotificationUsers) ResolveRecipients(ctx context.Context, tenantID uuid.UUID) (*notifications.Recipients, error) {
+	return &notifications.Recipients{Emails: f.added}, nil
+}
+
+func (f *fakeNotificationUsers) ResolvePreferences(ctx context.Context, tenantID uuid.UUID, userID *uuid.UUID, t notifications.NotificationType) (*notifications.Preferences, error) {
+	f.singles++
+	return &notifications.Preferences{}, nil
+}
+
+func (f *fakeNotificationUsers) ResolveUserPreferences(ctx context.Context, tenantID uuid.UUID, userIDs []uuid.UUID, t notifications.NotificationType) (map[uuid.UUID]*notifications.Preferences, error) {
+	f.bulks++
+	f.bulkUserIDs = userIDs
+	prefs := make(map[uuid.UUID]*notifications.Preferences, len(userIDs))
+	for _, userID := range userIDs {
+		prefs[userID] = &notifications.Preferences{}
+		if f.emailOff[userID] {
+			prefs[userID].Channels = []notifications.NotificationChannel{notifications.ChannelSlack}
+		}
+	}
+	return prefs, nil
+}
+
+func newEmailNotifier(t *testing.T, server *fakeSMTPServer, users *fakeNotificationUsers) *notifications.Service {
+	svc := notifications.NewService(&notifications.EmailConfig{
+		Host: "127.0.0.1",
+		Port: server.port(),
+		From: "alerts@delphi.test",
+	}, nil, nil, nil, logger.New())
+	svc.SetRecipientResolver(users)
+	svc.SetPreferenceResolver(users)
+	svc.SetUserLookup(users)
+	return svc
+}
+
+func TestTenantEmailsAreSentToEachRecipientSeparately(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	alice := &notifications.User{ID: uuid.New(), Email: "alice@example.com"}
+	bob := &notifications.User{ID: uuid.New(), Email: "bob@example.com"}
+	carol := &notifications.User{ID: uuid.New(), Email: "carol@example.com"}
+	users := &fakeNotificationUsers{
+		users:    []*notifications.User{alice, bob, carol},
+		added:    []string{"finance@example.com", "ALICE@example.com"},
+		emailOff: map[uuid.UUID]bool{carol.ID: true},
+	}
+	svc := newEmailNotifier(t, server, users)
+
+	notification := notifications.BudgetAlertNotification(uuid.New(), 80, 100, 80)
+	notification.Channels = []notifications.NotificationChannel{notifications.ChannelEmail}
+	require.NoError(t, svc.Send(context.Background(), notification))
+
+	messages := server.received()
+	require.Len(t, messages, 3)
+	var to []string
+	for _, msg := range messages {
+		require.Len(t, msg.rcpts, 1, "one recipient per message")
+		to = append(to, msg.rcpts[0])
+		assert.Contains(t, msg.data, "To: "+msg.rcpts[0]+"\r\n")
+		for _, other := range []string{"alice@", "bob@", "finance@"} {
+			if !strings.HasPrefix(msg.rcpts[0], other) {
+				assert.NotContains(t, msg.data, other, "recipients don't see each other's addresses")
+			}
+		}
+	}
+	assert.ElementsMatch(t, []string{"alice@example.com", "bob@example.com", "finance@example.com"}, to)
+
+	// The users' preferences are resolved together rather than one by one
+	assert.Equal(t, 1, users.bulks)
+	assert.ElementsMatch(t, []uuid.UUID{alice.ID, bob.ID, carol.ID}, users.bulkUserIDs)
+	assert.Equal(t, 1, users.singles, "only the tenant's own preferences are resolved alone")
+}
+
+func TestEmailToRejectedRecipientStillReachesOthers(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	server.reject = map[string]bool{"gone@example.com": true}
+	users := &fakeNotificationUsers{added: []string{"gone@example.com", "finance@example.com"}}
+	svc := newEmailNotifier(t, server, users)
+
+	notification := notifications.BudgetAlertNotification(uuid.New(), 80, 100, 80)
+	notification.Channels = []notifications.NotificationChannel{notifications.ChannelEmail}
+	assert.Error(t, svc.Send(context.Background(), notification))
+
+	messages := server.received()
+	require.Len(t, messages, 1)
+	assert.Equal(t, []string{"finance@example.com"}, messages[0].rcpts)
+}
@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/config"
+	"github.com/delphi-platform/delphi/backend/internal/knowledge"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Ollama Embedder Tests
+// =============================================================================
+
+// fakeOllamaEmbeddings embeds each prompt as a 3-dimensional vector whose
+// first component is the prompt's length
+func fakeOllamaEmbeddings(t *testing.T, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var body struct {
+			Model  string `json:"model"`
+			Prompt string `json:"prompt"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.URL.Path != "/api/embeddings" || body.Model != "nomic-embed-text" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string][]float32{"embedding": {float32(len(body.Prompt)), 0, 1}})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOllamaEmbedder(t *testing.T) {
+	var requests atomic.Int32
+	server := fakeOllamaEmbeddings(t, &requests)
+
+	embedder, err := knowledge.NewOllamaEmbedder(context.Background(), server.URL+"/", "")
+	require.NoError(t, err)
+	assert.Equal(t, 3, embedder.Dimension(), "the dimension is probed from a sample embedding")
+
+	embedding, err := embedder.Embed(context.Background(), "hello")
+	require.NoError(t, err)
+	assert.Equal(t, []float32{5, 0, 1}, embedding)
+
+	texts := []string{"a", "bb", "ccc", "dddd", "eeeee", "ffffff"}
+	requests.Store(0)
+	embeddings, err := embedder.EmbedBatch(context.Background(), texts)
+	require.NoError(t, err)
+	require.Len(t, embeddings, len(texts))
+	for i, text := range texts {
+		assert.Equal(t, float32(len(text)), embeddings[i][0], "embeddings keep the order of their texts")
+	}
+	assert.Equal(t, int32(len(texts)), requests.Load())
+
+	t.Run("missing model", func(t *testing.T) {
+		_, err := knowledge.NewOllamaEmbedder(context.Background(), server.URL, "mxbai-embed-large")
+		assert.ErrorContains(t, err, "ollama pull mxbai-embed-large")
+	})
+
+	t.Run("server unreachable", func(t *testing.T) {
+		down := httptest.NewServer(http.NotFoundHandler())
+		down.Close()
+		_, err := knowledge.NewOllamaEmbedder(context.Background(), down.URL, "")
+		assert.ErrorIs(t, err, knowledge.ErrEmbedderUnreachable)
+	})
+}
+
+func TestNewKnowledgeEmbedderFailsInsteadOfFallingBack(t *testing.T) {
+	ctx := context.Background()
+
+	var requests atomic.Int32
+	server := fakeOllamaEmbeddings(t, &requests)
+	embedder, err := services.NewKnowledgeEmbedder(ctx, &config.Config{KnowledgeEmbedder: "ollama", OllamaBaseURL: server.URL})
+	require.NoError(t, err)
+	assert.Equal(t, 3, embedder.Dimension())
+
+	embedder, err = services.NewKnowledgeEmbedder(ctx, &config.Config{KnowledgeEmbedder: "mock", KnowledgeEmbeddingDimensions: 8})
+	require.NoError(t, err)
+	assert.Equal(t, 8, embedder.Dimension())
+
+	// Nothing listens once the server is closed
+	server.Close()
+	_, err = services.NewKnowledgeEmbedder(ctx, &config.Config{KnowledgeEmbedder: "ollama", OllamaBaseURL: server.URL})
+	assert.ErrorContains(t, err, "failed to create Ollama embedder")
+
+	_, err = services.NewKnowledgeEmbedder(ctx, &config.Config{KnowledgeEmbedder: "openai"})
+	assert.ErrorContains(t, err, "OpenAI API key is required")
+
+	_, err = services.NewKnowledgeEmbedder(ctx, &config.Config{KnowledgeEmbedder: "opneai", OpenAIAPIKey: "sk-test"})
+	assert.EqualError(t, err, `unknown KNOWLEDGE_EMBEDDER "opneai": want mock, openai or ollama`)
+}
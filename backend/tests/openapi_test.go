@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/openapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// OpenAPI Tests
+// =============================================================================
+
+func TestOpenAPISpecIsValid(t *testing.T) {
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(openapi.Spec(), &doc))
+	assert.True(t, strings.HasPrefix(doc["openapi"].(string), "3."))
+
+	paths := doc["paths"].(map[string]interface{})
+	for _, path := range []string{"/api/v1/agents", "/api/v1/execute", "/api/v1/executions", "/api/v1/knowledge", "/api/v1/costs/summary"} {
+		assert.Contains(t, paths, path)
+	}
+
+	components := doc["components"].(map[string]interface{})
+	assert.Contains(t, components["securitySchemes"], "bearerAuth")
+
+	// Every reference resolves
+	var check func(node interface{})
+	check = func(node interface{}) {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			if ref, ok := n["$ref"].(string); ok {
+				parts := strings.Split(strings.TrimPrefix(ref, "#/components/"), "/")
+				require.Len(t, parts, 2, ref)
+				section, _ := components[parts[0]].(map[string]interface{})
+				assert.Contains(t, section, parts[1], "unresolved %s", ref)
+			}
+			for _, v := range n {
+				check(v)
+			}
+		case []interface{}:
+			for _, v := range n {
+				check(v)
+			}
+		}
+	}
+	check(doc)
+}
@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/payload"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Payload Limit Tests
+// =============================================================================
+
+func TestPayloadLimiter(t *testing.T) {
+	ctx := context.Background()
+	store, err := payload.NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	limiter := payload.NewLimiter(store, payload.Limits{MaxPromptBytes: 16, MaxResponseBytes: 0})
+
+	prompt, ref, err := limiter.LimitPrompt(ctx, "runs/1", "short prompt")
+	require.NoError(t, err)
+	assert.Equal(t, "short prompt", prompt)
+	assert.Empty(t, ref)
+
+	long := strings.Repeat("a", 100)
+	prompt, ref, err = limiter.LimitPrompt(ctx, "runs/2", long)
+	require.NoError(t, err)
+	assert.Equal(t, "runs/2/prompt", ref)
+	assert.True(t, strings.HasPrefix(prompt, strings.Repeat("a", 16)+"\n\n[truncated: 16 of 100 bytes"))
+
+	full, err := limiter.Get(ctx, ref)
+	require.NoError(t, err)
+	assert.Equal(t, long, full, "the full payload is kept")
+
+	response, ref, err := limiter.LimitResponse(ctx, "runs/2", long)
+	require.NoError(t, err)
+	assert.Equal(t, long, response, "a zero limit stores payloads whole")
+	assert.Empty(t, ref)
+
+	_, err = limiter.Get(ctx, "runs/3/prompt")
+	assert.ErrorIs(t, err, payload.ErrNotFound)
+	_, err = store.Get(ctx, "../outside")
+	assert.Error(t, err)
+}
+
+func TestPayloadTruncateKeepsCharacters(t *testing.T) {
+	// "é" is two bytes; cutting at 3 would split the second one
+	truncated := payload.Truncate("éééé", 3, "ref")
+	assert.True(t, strings.HasPrefix(truncated, "é\n\n[truncated: 2 of 8 bytes"))
+}
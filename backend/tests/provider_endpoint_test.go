@@ -0,0 +1,194 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/config"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/providers"
+	"github.com/delphi-platform/delphi/backend/internal/security"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Provider Endpoint Tests
+// =============================================================================
+
+func TestEndpointValidate(t *testing.T) {
+	assert.NoError(t, providers.Endpoint{}.Validate())
+	assert.NoError(t, providers.Endpoint{BaseURL: "https://gateway.example.com/v1", ProxyURL: "http://proxy:3128"}.Validate())
+	assert.NoError(t, providers.Endpoint{
+		BaseURL:     "https://acme.openai.azure.com",
+		Deployments: map[string]string{"gpt-4o": "acme-gpt4o"},
+	}.Validate())
+
+	tests := []struct {
+		name     string
+		endpoint providers.Endpoint
+		wantErr  string
+	}{
+		{"relative base URL", providers.Endpoint{BaseURL: "gateway/v1"}, "base_url must be an absolute http(s) URL"},
+		{"unsupported proxy scheme", providers.Endpoint{ProxyURL: "socks5://proxy:1080"}, "proxy_url must be an absolute http(s) URL"},
+		{"api version without base URL", providers.Endpoint{APIVersion: "2024-06-01"}, "api_version needs"},
+		{"deployments outside Azure", providers.Endpoint{BaseURL: "https://gateway.example.com", Deployments: map[string]string{"gpt-4o": "x"}}, "deployments need"},
+		{"empty deployment", providers.Endpoint{BaseURL: "https://acme.openai.azure.com", Deployments: map[string]string{"gpt-4o": " "}}, "deployments need a model and a deployment name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, tt.endpoint.Validate(), tt.wantErr)
+		})
+	}
+}
+
+func TestServerProviderEndpoints(t *testing.T) {
+	endpoints := services.ServerProviderEndpoints(&config.Config{
+		OpenAIBaseURL:     "https://acme.openai.azure.com",
+		OpenAIDeployments: "gpt-4o=acme-gpt4o, gpt-4o-mini = acme-mini,bogus",
+		AnthropicBaseURL:  "not a url",
+		ProviderProxyURL:  "http://proxy:3128",
+	}, logger.New())
+
+	assert.Equal(t, map[string]string{"gpt-4o": "acme-gpt4o", "gpt-4o-mini": "acme-mini"}, endpoints[models.ProviderOpenAI].Deployments)
+	assert.True(t, endpoints[models.ProviderOpenAI].Azure())
+	assert.NotContains(t, endpoints, models.ProviderAnthropic, "invalid endpoints fall back to the public API")
+	assert.Equal(t, "http://proxy:3128", endpoints[models.ProviderGoogle].ProxyURL, "the proxy applies to every provider")
+
+	assert.Empty(t, services.ServerProviderEndpoints(&config.Config{}, logger.New()))
+}
+
+func TestTenantProviderEndpoints(t *testing.T) {
+	tenant := &models.Tenant{Settings: json.RawMessage(`{
+		"cost_anomalies": {"enabled": true},
+		"provider_endpoints": {"anthropic": {"base_url": "https://gateway.example.com/anthropic"}, "google": {}}
+	}`)}
+
+	endpoints := services.TenantProviderEndpoints(tenant)
+	assert.Equal(t, "https://gateway.example.com/anthropic", endpoints[models.ProviderAnthropic].BaseURL)
+	assert.NotContains(t, endpoints, models.ProviderGoogle, "empty endpoints are left out")
+	assert.Empty(t, services.TenantProviderEndpoints(&models.Tenant{}))
+}
+
+func TestAnthropicProviderEndpoint(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":      "msg_1",
+			"model":   "claude-3-5-sonnet-20241022",
+			"content": []map[string]string{{"type": "text", "text": "hi"}},
+			"usage":   map[string]int{"input_tokens": 1, "output_tokens": 1},
+		})
+	}))
+	defer server.Close()
+
+	provider, err := providers.NewAnthropicProviderWithEndpoint("key", providers.Endpoint{BaseURL: server.URL + "/anthropic/v1/"})
+	require.NoError(t, err)
+	_, err = provider.Complete(context.Background(), &providers.CompletionRequest{
+		Model:    "claude-3-5-sonnet-20241022",
+		Messages: []providers.Message{{Role: "user", Content: "hello"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/anthropic/v1/messages"}, paths)
+
+	_, err = providers.NewAnthropicProviderWithEndpoint("key", providers.Endpoint{BaseURL: "https://acme.openai.azure.com"})
+	assert.Error(t, err, "Azure settings only apply to OpenAI")
+}
+
+func TestValidateAPIKeyUsesEndpoint(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.URL.Path+" "+r.Header.Get("Authorization")+r.Header.Get("x-api-key"))
+		mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{"object": "list", "data": []interface{}{}})
+	}))
+	defer server.Close()
+
+	openAI, err := providers.NewOpenAIProviderWithEndpoint("server-key", providers.Endpoint{BaseURL: server.URL + "/openai/v1"})
+	require.NoError(t, err)
+	require.NoError(t, openAI.ValidateAPIKey(context.Background(), "tenant-key"))
+
+	anthropic, err := providers.NewAnthropicProviderWithEndpoint("server-key", providers.Endpoint{BaseURL: server.URL + "/anthropic/v1"})
+	require.NoError(t, err)
+	require.NoError(t, anthropic.ValidateAPIKey(context.Background(), "tenant-key"))
+
+	assert.Equal(t, []string{"/openai/v1/models Bearer tenant-key", "/anthropic/v1/models tenant-key"}, requests,
+		"keys are checked at the provider's endpoint with the key being validated, by listing models rather than a billed completion")
+}
+
+func TestValidateAPIKeyClassifiesRejections(t *testing.T) {
+	status := http.StatusUnauthorized
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]string{"message": "no"}})
+	}))
+	defer server.Close()
+
+	openAI, err := providers.NewOpenAIProviderWithEndpoint("key", providers.Endpoint{BaseURL: server.URL + "/v1"})
+	require.NoError(t, err)
+	anthropic, err := providers.NewAnthropicProviderWithEndpoint("key", providers.Endpoint{BaseURL: server.URL + "/v1"})
+	require.NoError(t, err)
+	google, err := providers.NewGoogleProviderWithEndpoint("key", providers.Endpoint{BaseURL: server.URL + "/v1beta"})
+	require.NoError(t, err)
+	all := map[string]providers.Provider{"openai": openAI, "anthropic": anthropic, "google": google}
+
+	for _, rejected := range []int{http.StatusUnauthorized, http.StatusForbidden} {
+		status = rejected
+		for name, provider := range all {
+			assert.ErrorIs(t, provider.ValidateAPIKey(context.Background(), "key"), providers.ErrInvalidAPIKey, "%s %d", name, rejected)
+		}
+	}
+
+	for _, failed := range []int{http.StatusTooManyRequests, http.StatusInternalServerError, 529} {
+		status = failed
+		for name, provider := range all {
+			err := provider.ValidateAPIKey(context.Background(), "key")
+			require.Error(t, err, "%s %d", name, failed)
+			assert.NotErrorIs(t, err, providers.ErrInvalidAPIKey, "%s %d says nothing about the key", name, failed)
+		}
+	}
+}
+
+func TestOpenAIChatURL(t *testing.T) {
+	assert.Equal(t, "https://api.openai.com/v1/chat/completions", providers.Endpoint{}.OpenAIChatURL("gpt-4o"))
+	assert.Equal(t, "https://gateway.example.com/v1/chat/completions", providers.Endpoint{BaseURL: "https://gateway.example.com/v1/"}.OpenAIChatURL("gpt-4o"))
+
+	azure := providers.Endpoint{BaseURL: "https://acme.openai.azure.com", Deployments: map[string]string{"gpt-4o": "acme-gpt4o"}}
+	assert.Equal(t, "https://acme.openai.azure.com/openai/deployments/acme-gpt4o/chat/completions?api-version=2024-06-01", azure.OpenAIChatURL("gpt-4o"))
+	azure.APIVersion = "2024-10-21"
+	assert.Equal(t, "https://acme.openai.azure.com/openai/deployments/gpt-4o-mini/chat/completions?api-version=2024-10-21", azure.OpenAIChatURL("gpt-4o-mini"))
+}
+
+func TestPublicOnlyEndpoint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"models": []interface{}{}})
+	}))
+	defer server.Close()
+
+	endpoint := providers.Endpoint{BaseURL: server.URL}
+	assert.ErrorContains(t, endpoint.ValidatePublic(context.Background()), "base_url must not point to a private or internal address")
+	assert.ErrorContains(t, providers.Endpoint{ProxyURL: "http://10.0.0.1:3128"}.ValidatePublic(context.Background()), "proxy_url must not point")
+	assert.NoError(t, providers.Endpoint{BaseURL: "https://93.184.216.34/v1"}.ValidatePublic(context.Background()))
+
+	// The server endpoint may be internal, a tenant's can't
+	_, err := endpoint.HTTPClient(0).Get(server.URL)
+	assert.NoError(t, err)
+	_, err = endpoint.PublicOnly().HTTPClient(0).Get(server.URL)
+	assert.ErrorIs(t, err, security.ErrNonPublicAddress)
+
+	google, err := providers.NewGoogleProviderWithEndpoint("key", endpoint.PublicOnly())
+	require.NoError(t, err)
+	assert.ErrorIs(t, google.ValidateAPIKey(context.Background(), "key"), security.ErrNonPublicAddress)
+}
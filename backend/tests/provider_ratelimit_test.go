@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Provider Rate Limit Tests
+// =============================================================================
+
+func TestParseRateLimitHeaders(t *testing.T) {
+	openai := http.Header{}
+	openai.Set("x-ratelimit-limit-requests", "500")
+	openai.Set("x-ratelimit-remaining-requests", "499")
+	openai.Set("x-ratelimit-limit-tokens", "30000")
+	openai.Set("x-ratelimit-remaining-tokens", "29000")
+	openai.Set("x-ratelimit-reset-tokens", "6m0s")
+
+	info := providers.ParseRateLimitHeaders(openai)
+	require.NotNil(t, info)
+	assert.Equal(t, 499, info.RemainingRequests)
+	assert.Equal(t, 29000, info.RemainingTokens)
+	assert.WithinDuration(t, time.Now().Add(6*time.Minute), info.TokensReset, time.Second)
+
+	reset := time.Now().Add(time.Minute).UTC().Truncate(time.Second)
+	anthropic := http.Header{}
+	anthropic.Set("anthropic-ratelimit-requests-limit", "50")
+	anthropic.Set("anthropic-ratelimit-requests-remaining", "0")
+	anthropic.Set("anthropic-ratelimit-requests-reset", reset.Format(time.RFC3339))
+
+	info = providers.ParseRateLimitHeaders(anthropic)
+	require.NotNil(t, info)
+	assert.Equal(t, 50, info.LimitRequests)
+	assert.Equal(t, 0, info.RemainingRequests)
+	assert.True(t, reset.Equal(info.RequestsReset))
+
+	assert.Nil(t, providers.ParseRateLimitHeaders(http.Header{}))
+}
+
+func TestRateLimitTrackerThrottlesExhaustedProvider(t *testing.T) {
+	tracker := providers.NewRateLimitTracker(time.Second)
+	ctx := context.Background()
+
+	assert.NoError(t, tracker.Wait(ctx, "openai", 100), "nothing known about the provider yet")
+
+	tracker.Update("openai", &providers.RateLimitInfo{
+		LimitRequests:     50,
+		RemainingRequests: 0,
+		RequestsReset:     time.Now().Add(50 * time.Millisecond),
+	})
+	start := time.Now()
+	require.NoError(t, tracker.Wait(ctx, "openai", 100))
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond, "waits for the quota to reset")
+
+	tracker.Update("openai", &providers.RateLimitInfo{
+		LimitTokens:     30000,
+		RemainingTokens: 10,
+		TokensReset:     time.Now().Add(time.Hour),
+	})
+	assert.Error(t, tracker.Wait(ctx, "openai", 100), "a reset further off than the wait allows fails at once")
+	assert.NoError(t, tracker.Wait(ctx, "openai", 5), "enough tokens are left for a smaller request")
+}
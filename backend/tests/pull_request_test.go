@@ -0,0 +1,212 @@
+package tests
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/github"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Pull Request Tests
+// =============================================================================
+
+// fakeGitHub serves the parts of the GitHub API used to open pull requests.
+// files holds each file's blob SHA on the new branch.
+type fakeGitHub struct {
+	mu       sync.Mutex
+	files    map[string]string
+	requests []string
+	written  map[string]string // path -> decoded content
+	title    string            // title of the last pull request
+
+	// failWrites and failPulls make file writes and pull requests fail
+	failWrites bool
+	failPulls  bool
+}
+
+func (f *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+
+	path, isContent := strings.CutPrefix(r.URL.Path, "/repos/acme/app/contents/")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/repos/acme/app":
+		json.NewEncoder(w).Encode(map[string]string{"default_branch": "main"})
+	case r.Method == http.MethodGet && r.URL.Path == "/repos/acme/app/branches/main":
+		json.NewEncoder(w).Encode(map[string]interface{}{"name": "main", "commit": map[string]string{"sha": "base-sha"}})
+	case r.Method == http.MethodPost && r.URL.Path == "/repos/acme/app/git/refs":
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/repos/acme/app/git/refs/heads/"):
+		w.WriteHeader(http.StatusNoContent)
+	case isContent && r.Method == http.MethodGet:
+		sha, ok := f.files[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"path": path, "sha": sha, "content": base64.StdEncoding.EncodeToString([]byte("old " + path))})
+	case isContent && r.Method == http.MethodPut:
+		var body struct {
+			Content string  `json:"content"`
+			Branch  string  `json:"branch"`
+			SHA     *string `json:"sha"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if f.failWrites {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if _, exists := f.files[path]; exists && body.SHA == nil {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		content, _ := base64.StdEncoding.DecodeString(body.Content)
+		f.written[path] = string(content)
+		f.files[path] = "new-sha"
+		w.WriteHeader(http.StatusCreated)
+	case isContent && r.Method == http.MethodDelete:
+		delete(f.files, path)
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPost && r.URL.Path == "/repos/acme/app/pulls":
+		var body struct {
+			Title string `json:"title"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		f.title = body.Title
+		if f.failPulls || body.Title == "" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{"number": 7, "html_url": "https://github.com/acme/app/pull/7"})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newFakeGitHub(t *testing.T, files map[string]string) (*fakeGitHub, *github.Client) {
+	t.Helper()
+	fake := &fakeGitHub{files: files, written: make(map[string]string)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client := github.NewClient(logger.New())
+	client.SetBaseURL(server.URL)
+	return fake, client
+}
+
+func TestOpenPullRequest(t *testing.T) {
+	fake, client := newFakeGitHub(t, map[string]string{
+		"web/login.go":  "login-sha",
+		"web/old.go":    "old-sha",
+		"web/legacy.go": "legacy-sha",
+	})
+
+	content := func(s string) *string { return &s }
+	pr, err := client.OpenPullRequest(context.Background(), "token", "acme", "app", github.PullRequestChanges{
+		Branch: "delphi/run-1",
+		Title:  "Fix the login redirect",
+		Files: []models.CodingFileChange{
+			{Path: "web/login.go", Status: models.CodingFileModified, Content: content("package web // v2")},
+			{Path: "web/new.go", Status: models.CodingFileAdded, Content: content("package web")},
+			{Path: "web/old.go", Status: models.CodingFileDeleted},
+			{Path: "web/renamed.go", Status: models.CodingFileRenamed, PreviousPath: "web/legacy.go"},
+			{Path: "web/untouched.go", Status: models.CodingFileModified},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 7, pr.Number)
+	assert.Equal(t, "https://github.com/acme/app/pull/7", pr.HTMLURL)
+
+	assert.Equal(t, "package web // v2", fake.written["web/login.go"], "existing files are updated with their SHA")
+	assert.Equal(t, "package web", fake.written["web/new.go"])
+	assert.Equal(t, "old web/legacy.go", fake.written["web/renamed.go"], "a rename keeps the previous content")
+	assert.NotContains(t, fake.written, "web/untouched.go", "files without content are left out")
+	assert.NotContains(t, fake.files, "web/old.go")
+	assert.NotContains(t, fake.files, "web/legacy.go")
+
+	assert.Equal(t, "POST /repos/acme/app/pulls", fake.requests[len(fake.requests)-1])
+
+	t.Run("never commits to the default branch", func(t *testing.T) {
+		for _, branch := range []string{"", "main", "master"} {
+			fake, client := newFakeGitHub(t, map[string]string{})
+			_, err := client.OpenPullRequest(context.Background(), "token", "acme", "app", github.PullRequestChanges{
+				Branch: branch,
+				Title:  "t",
+				Files:  []models.CodingFileChange{{Path: "a.go", Status: models.CodingFileAdded, Content: content("a")}},
+			})
+			assert.ErrorIs(t, err, github.ErrProtectedBranch, "branch %q", branch)
+			assert.Empty(t, fake.written)
+		}
+	})
+
+	t.Run("nothing to commit", func(t *testing.T) {
+		fake, client := newFakeGitHub(t, map[string]string{})
+		_, err := client.OpenPullRequest(context.Background(), "token", "acme", "app", github.PullRequestChanges{
+			Branch: "delphi/run-2",
+			Base:   "main",
+			Files:  []models.CodingFileChange{{Path: "a.go", Status: models.CodingFileModified}},
+		})
+		assert.ErrorIs(t, err, github.ErrNoChanges)
+		assert.Empty(t, fake.requests, "no branch is created")
+	})
+
+	t.Run("untitled changes get a default title", func(t *testing.T) {
+		fake, client := newFakeGitHub(t, map[string]string{})
+		_, err := client.OpenPullRequest(context.Background(), "token", "acme", "app", github.PullRequestChanges{
+			Branch: "delphi/run-3",
+			Base:   "main",
+			Title:  " ",
+			Files:  []models.CodingFileChange{{Path: "a.go", Status: models.CodingFileAdded, Content: content("a")}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "Changes from delphi/run-3", fake.title)
+	})
+
+	t.Run("failures delete the branch", func(t *testing.T) {
+		for name, fail := range map[string]func(*fakeGitHub){
+			"commit":       func(f *fakeGitHub) { f.failWrites = true },
+			"pull request": func(f *fakeGitHub) { f.failPulls = true },
+		} {
+			fake, client := newFakeGitHub(t, map[string]string{})
+			fail(fake)
+			_, err := client.OpenPullRequest(context.Background(), "token", "acme", "app", github.PullRequestChanges{
+				Branch: "delphi/run-4",
+				Base:   "main",
+				Title:  "t",
+				Files:  []models.CodingFileChange{{Path: "a.go", Status: models.CodingFileAdded, Content: content("a")}},
+			})
+			assert.Error(t, err, name)
+			assert.Equal(t, "DELETE /repos/acme/app/git/refs/heads/delphi/run-4", fake.requests[len(fake.requests)-1], name)
+		}
+	})
+}
+
+func TestCheckPullRequestConfig(t *testing.T) {
+	repoID := uuid.New()
+	assert.NoError(t, services.CheckPullRequestConfig(models.AgentTypeAssistant, nil))
+	assert.NoError(t, services.CheckPullRequestConfig(models.AgentTypeCoding, &models.PullRequestConfig{RepositoryID: repoID, BranchPrefix: "oracle/fix-"}))
+
+	assert.ErrorContains(t, services.CheckPullRequestConfig(models.AgentTypeAssistant, &models.PullRequestConfig{RepositoryID: repoID}), "only coding agents")
+	assert.ErrorContains(t, services.CheckPullRequestConfig(models.AgentTypeCoding, &models.PullRequestConfig{}), "repository_id is required")
+	for _, prefix := range []string{"-x", "/x", "a..b", "a b", "fix:"} {
+		assert.Error(t, services.CheckPullRequestConfig(models.AgentTypeCoding, &models.PullRequestConfig{RepositoryID: repoID, BranchPrefix: prefix}), prefix)
+	}
+
+	run := &models.AgentRun{ID: uuid.New()}
+	assert.Equal(t, "delphi/run-"+run.ID.String(), services.PullRequestBranch(&models.PullRequestConfig{}, run))
+}
@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/security"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Rate Limiter Tests
+// =============================================================================
+
+// fakeClock is a clock tests move by hand
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestRateLimiter() (*security.RateLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	limiter := security.NewRateLimiter(logger.New())
+	limiter.SetClock(clock.Now)
+	return limiter, clock
+}
+
+func TestRateLimiterRefillsOverWindow(t *testing.T) {
+	ctx := context.Background()
+	limiter, clock := newTestRateLimiter()
+
+	first := limiter.Check(ctx, "tenant", 2, time.Minute)
+	assert.True(t, first.Allowed)
+	assert.Equal(t, 2, first.Limit)
+	assert.Equal(t, 1, first.Remaining)
+	assert.True(t, limiter.Check(ctx, "tenant", 2, time.Minute).Allowed)
+
+	rejected := limiter.Check(ctx, "tenant", 2, time.Minute)
+	assert.False(t, rejected.Allowed)
+	assert.Equal(t, 0, rejected.Remaining)
+	assert.Equal(t, 30*time.Second, rejected.RetryAfter, "a token refills every half minute")
+
+	clock.Advance(15 * time.Second)
+	rejected = limiter.Check(ctx, "tenant", 2, time.Minute)
+	assert.False(t, rejected.Allowed)
+	assert.Equal(t, 15*time.Second, rejected.RetryAfter)
+
+	clock.Advance(15 * time.Second)
+	assert.True(t, limiter.Check(ctx, "tenant", 2, time.Minute).Allowed)
+	assert.True(t, limiter.Check(ctx, "other", 2, time.Minute).Allowed, "keys have their own buckets")
+
+	// An idle bucket refills to its maximum and no further
+	clock.Advance(10 * time.Minute)
+	assert.Equal(t, 1, limiter.Check(ctx, "tenant", 2, time.Minute).Remaining)
+}
+
+func TestRateLimiterKeepsTokensWhenLimitChanges(t *testing.T) {
+	ctx := context.Background()
+	limiter, _ := newTestRateLimiter()
+
+	for i := 0; i < 10; i++ {
+		require.True(t, limiter.Check(ctx, "tenant", 10, time.Minute).Allowed)
+	}
+	assert.False(t, limiter.Check(ctx, "tenant", 10, time.Minute).Allowed)
+
+	// An upgrade raises the limit without handing out a fresh bucket
+	upgraded := limiter.Check(ctx, "tenant", 100, time.Minute)
+	assert.False(t, upgraded.Allowed)
+	assert.Equal(t, 100, upgraded.Limit)
+}
+
+func TestRateLimiterSweepsIdleBuckets(t *testing.T) {
+	ctx := context.Background()
+	limiter, clock := newTestRateLimiter()
+
+	limiter.Check(ctx, "idle", 5, time.Minute)
+	limiter.Check(ctx, "busy", 5, 2*time.Minute)
+	assert.Equal(t, 2, limiter.Size())
+
+	// Sweeps run at most once a minute, while checking
+	clock.Advance(30 * time.Second)
+	limiter.Check(ctx, "busy", 5, 2*time.Minute)
+	assert.Equal(t, 2, limiter.Size())
+
+	clock.Advance(45 * time.Second)
+	limiter.Check(ctx, "new", 5, time.Minute)
+	assert.Equal(t, 2, limiter.Size(), "the bucket idle for its whole window is dropped")
+
+	clock.Advance(2 * time.Minute)
+	limiter.Check(ctx, "new", 5, time.Minute)
+	assert.Equal(t, 1, limiter.Size())
+}
+
+func TestRateLimiterFallsBackWhenRedisFails(t *testing.T) {
+	ctx := context.Background()
+	limiter, _ := newTestRateLimiter()
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", DialTimeout: 100 * time.Millisecond, MaxRetries: -1})
+	defer client.Close()
+	limiter.SetRedis(client)
+
+	assert.True(t, limiter.Check(ctx, "tenant", 1, time.Minute).Allowed)
+	assert.False(t, limiter.Check(ctx, "tenant", 1, time.Minute).Allowed, "the instance's bucket still limits")
+	assert.Equal(t, 1, limiter.Size())
+}
@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/handlers"
+	"github.com/delphi-platform/delphi/backend/internal/middleware"
+	"github.com/delphi-platform/delphi/backend/internal/security"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// RBAC Tests
+// =============================================================================
+
+var testRoles = []security.Role{
+	security.RoleOwner,
+	security.RoleAdmin,
+	security.RoleMember,
+	security.RoleViewer,
+}
+
+func TestRequirePermission(t *testing.T) {
+	rbac := security.NewRBAC(logger.New())
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	permissions := []security.Permission{
+		security.PermAgentRead, security.PermAgentCreate, security.PermAgentUpdate,
+		security.PermAgentDelete, security.PermAgentExecute,
+		security.PermAPIKeyRead, security.PermAPIKeyCreate, security.PermAPIKeyRevoke,
+		security.PermSettingsRead, security.PermSettingsUpdate,
+	}
+
+	for _, role := range testRoles {
+		for _, perm := range permissions {
+			handler := withRole(string(role), middleware.RequirePermission(rbac, perm)(ok))
+			rec := serve(handler, http.MethodGet, "/")
+
+			if rbac.HasPermission(role, perm) {
+				assert.Equal(t, http.StatusNoContent, rec.Code, "%s should have %s", role, perm)
+			} else {
+				assert.Equal(t, http.StatusForbidden, rec.Code, "%s should not have %s", role, perm)
+				assert.Contains(t, rec.Body.String(), string(perm))
+			}
+		}
+	}
+
+	t.Run("rejects requests without a role", func(t *testing.T) {
+		handler := withRole("", middleware.RequirePermission(rbac, security.PermAgentRead)(ok))
+		assert.Equal(t, http.StatusUnauthorized, serve(handler, http.MethodGet, "/").Code)
+	})
+
+	t.Run("rejects unknown roles", func(t *testing.T) {
+		handler := withRole("superuser", middleware.RequirePermission(rbac, security.PermAgentRead)(ok))
+		assert.Equal(t, http.StatusForbidden, serve(handler, http.MethodGet, "/").Code)
+	})
+}
+
+func TestAgentRoutePermissions(t *testing.T) {
+	rbac := security.NewRBAC(logger.New())
+	routes := handlers.NewAgentHandler(nil, logger.New()).Routes(rbac)
+
+	// Allowed requests carry no tenant, so they stop at the handler's tenant
+	// check instead of reaching the (nil) service
+	cases := []struct {
+		method, path string
+		allowed      []security.Role
+	}{
+		{http.MethodGet, "/", []security.Role{security.RoleOwner, security.RoleAdmin, security.RoleMember, security.RoleViewer}},
+		{http.MethodPost, "/", []security.Role{security.RoleOwner, security.RoleAdmin}},
+		{http.MethodPost, "/from-template/template-1", []security.Role{security.RoleOwner, security.RoleAdmin}},
+		{http.MethodPatch, "/agent-1", []security.Role{security.RoleOwner, security.RoleAdmin}},
+		{http.MethodDelete, "/agent-1", []security.Role{security.RoleOwner, security.RoleAdmin}},
+		{http.MethodPost, "/agent-1/launch", []security.Role{security.RoleOwner, security.RoleAdmin, security.RoleMember}},
+		{http.MethodPost, "/agent-1/pause", []security.Role{security.RoleOwner, security.RoleAdmin, security.RoleMember}},
+		{http.MethodPost, "/agent-1/terminate", []security.Role{security.RoleOwner, security.RoleAdmin, security.RoleMember}},
+	}
+
+	for _, tc := range cases {
+		assertRoutePermissions(t, routes, tc.method, tc.path, tc.allowed)
+	}
+}
+
+func TestAgentTemplateRoutePermissions(t *testing.T) {
+	rbac := security.NewRBAC(logger.New())
+	routes := handlers.NewAgentHandler(nil, logger.New()).TemplateRoutes(rbac)
+
+	managers := []security.Role{security.RoleOwner, security.RoleAdmin}
+	assertRoutePermissions(t, routes, http.MethodGet, "/", testRoles)
+	assertRoutePermissions(t, routes, http.MethodPost, "/", managers)
+	assertRoutePermissions(t, routes, http.MethodDelete, "/template-1", managers)
+}
+
+func TestAPIKeyRoutePermissions(t *testing.T) {
+	rbac := security.NewRBAC(logger.New())
+	routes := handlers.NewAPIKeyHandler(nil, logger.New()).Routes(rbac)
+
+	managers := []security.Role{security.RoleOwner, security.RoleAdmin}
+	assertRoutePermissions(t, routes, http.MethodGet, "/", managers)
+	assertRoutePermissions(t, routes, http.MethodPost, "/", managers)
+	assertRoutePermissions(t, routes, http.MethodPost, "/validate", managers)
+	assertRoutePermissions(t, routes, http.MethodDelete, "/key-1", managers)
+}
+
+func TestSettingsRoutePermissions(t *testing.T) {
+	rbac := security.NewRBAC(logger.New())
+	routes := handlers.NewSettingsHandler(nil, logger.New()).Routes(rbac)
+
+	readers := []security.Role{security.RoleOwner, security.RoleAdmin, security.RoleMember}
+	managers := []security.Role{security.RoleOwner, security.RoleAdmin}
+	assertRoutePermissions(t, routes, http.MethodGet, "/", readers)
+	assertRoutePermissions(t, routes, http.MethodPatch, "/", managers)
+	assertRoutePermissions(t, routes, http.MethodGet, "/integrations", readers)
+	assertRoutePermissions(t, routes, http.MethodPut, "/integrations/slack", managers)
+}
+
+// assertRoutePermissions checks that only the allowed roles get past a route's
+// permission check
+func assertRoutePermissions(t *testing.T, routes http.Handler, method, path string, allowed []security.Role) {
+	t.Helper()
+
+	for _, role := range testRoles {
+		rec := serve(withRole(string(role), routes), method, path)
+		if containsRole(allowed, role) {
+			assert.NotEqual(t, http.StatusForbidden, rec.Code, "%s %s should be allowed for %s", method, path, role)
+		} else {
+			assert.Equal(t, http.StatusForbidden, rec.Code, "%s %s should be forbidden for %s", method, path, role)
+		}
+	}
+}
+
+// withRole puts the user's role in the request context, as Authenticate does
+func withRole(role string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if role != "" {
+			r = r.WithContext(context.WithValue(r.Context(), middleware.UserRoleKey, role))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func serve(handler http.Handler, method, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	return rec
+}
+
+func containsRole(roles []security.Role, role security.Role) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Replay Tests
+// =============================================================================
+
+func TestRunParametersRestoreWhatRan(t *testing.T) {
+	agent := &models.Agent{
+		Provider:     models.ProviderOpenAI,
+		Model:        "gpt-4o",
+		SystemPrompt: "You are terse.",
+		Config:       models.AgentConfig{MaxTokens: 1024},
+	}
+	recorded, err := json.Marshal(models.NewRunParameters(agent))
+	require.NoError(t, err)
+
+	// The agent is reconfigured after the run
+	temperature := 1.5
+	agent.Model = "gpt-4o-mini"
+	agent.SystemPrompt = "You are chatty."
+	agent.Config.Temperature = &temperature
+	agent.Config.MaxTokens = 4096
+
+	var params models.RunParameters
+	require.NoError(t, json.Unmarshal(recorded, &params))
+	replayed := *agent
+	params.Apply(&replayed)
+
+	assert.Equal(t, models.ProviderOpenAI, replayed.Provider)
+	assert.Equal(t, "gpt-4o", replayed.Model)
+	assert.Equal(t, "You are terse.", replayed.SystemPrompt)
+	assert.Equal(t, 1024, replayed.Config.MaxTokens)
+	assert.Equal(t, models.DefaultTemperature, replayed.Config.SamplingTemperature(), "the effective temperature is kept")
+	assert.Equal(t, 1.5, *agent.Config.Temperature, "the agent itself is left alone")
+}
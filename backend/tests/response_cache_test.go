@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Response Cache Tests
+// =============================================================================
+
+func TestResponseCacheKey(t *testing.T) {
+	agentID := uuid.New()
+	key := services.ResponseCacheKey(agentID, "gpt-4o", "You are terse.", "Summarize the release notes", 0)
+
+	t.Run("identical runs share a key", func(t *testing.T) {
+		assert.Equal(t, key, services.ResponseCacheKey(agentID, "gpt-4o", "You are terse.", "Summarize the release notes", 0))
+		assert.True(t, strings.HasPrefix(key, "response_cache:"+agentID.String()+":"))
+	})
+
+	t.Run("anything shaping the response changes the key", func(t *testing.T) {
+		for name, other := range map[string]string{
+			"agent":         services.ResponseCacheKey(uuid.New(), "gpt-4o", "You are terse.", "Summarize the release notes", 0),
+			"model":         services.ResponseCacheKey(agentID, "gpt-4o-mini", "You are terse.", "Summarize the release notes", 0),
+			"system prompt": services.ResponseCacheKey(agentID, "gpt-4o", "You are verbose.", "Summarize the release notes", 0),
+			"prompt":        services.ResponseCacheKey(agentID, "gpt-4o", "You are terse.", "Summarize the changelog", 0),
+			"temperature":   services.ResponseCacheKey(agentID, "gpt-4o", "You are terse.", "Summarize the release notes", 0.2),
+		} {
+			assert.NotEqual(t, key, other, name)
+		}
+	})
+
+	t.Run("parts can't run into each other", func(t *testing.T) {
+		assert.NotEqual(t,
+			services.ResponseCacheKey(agentID, "gpt-4o", "ab", "c", 0),
+			services.ResponseCacheKey(agentID, "gpt-4o", "a", "bc", 0))
+	})
+}
+
+func TestZeroTemperatureAgentsAreCacheable(t *testing.T) {
+	validate := func(body string) models.AgentConfig {
+		var req services.CreateAgentRequest
+		require.NoError(t, json.Unmarshal([]byte(body), &req))
+		_, err := services.ValidateAgentConfig(&req, services.AgentLimits{})
+		require.NoError(t, err)
+
+		// The config is stored as JSON and read back for each run
+		data, err := json.Marshal(req.Config)
+		require.NoError(t, err)
+		var stored models.AgentConfig
+		require.NoError(t, json.Unmarshal(data, &stored))
+		return stored
+	}
+
+	deterministic := validate(`{"name": "Classifier", "model": "gpt-4o", "config": {"temperature": 0}}`)
+	assert.Equal(t, 0.0, deterministic.SamplingTemperature(), "an explicit 0 is kept")
+	assert.True(t, deterministic.Deterministic(), "its runs are served from the cache")
+
+	defaulted := validate(`{"name": "Writer", "model": "gpt-4o", "config": {}}`)
+	assert.Equal(t, models.DefaultTemperature, defaulted.SamplingTemperature())
+	assert.False(t, defaulted.Deterministic())
+}
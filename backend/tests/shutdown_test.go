@@ -0,0 +1,203 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/iot"
+	"github.com/delphi-platform/delphi/backend/internal/metrics"
+	"github.com/delphi-platform/delphi/backend/internal/security"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Shutdown Tests
+// =============================================================================
+
+// memoryAuditStorage keeps audit entries in memory
+type memoryAuditStorage struct {
+	mu      sync.Mutex
+	entries []*security.AuditEntry
+}
+
+func (s *memoryAuditStorage) Store(ctx context.Context, entry *security.AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func (s *memoryAuditStorage) Query(ctx context.Context, query security.AuditQuery) ([]*security.AuditEntry, error) {
+	return nil, nil
+}
+
+func (s *memoryAuditStorage) GetByID(ctx context.Context, id uuid.UUID) (*security.AuditEntry, error) {
+	return nil, nil
+}
+
+func (s *memoryAuditStorage) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+func TestAuditStopFlushesBufferedEntries(t *testing.T) {
+	storage := &memoryAuditStorage{}
+	audit := security.NewAuditService(logger.New(), storage)
+
+	// More than a batch, stopped before the flush interval passes
+	for i := 0; i < 150; i++ {
+		audit.Log(context.Background(), &security.AuditEntry{Action: security.AuditActionLogin})
+	}
+	audit.Stop()
+	assert.Equal(t, 150, storage.count())
+
+	audit.Log(context.Background(), &security.AuditEntry{Action: security.AuditActionLogin})
+	assert.Equal(t, 151, storage.count(), "entries logged after stopping are written directly")
+}
+
+// memoryTelemetryStore keeps data points in memory
+type memoryTelemetryStore struct {
+	mu     sync.Mutex
+	points []iot.DataPoint
+}
+
+func (s *memoryTelemetryStore) Write(ctx context.Context, points ...iot.DataPoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.points = append(s.points, points...)
+	return nil
+}
+
+func (s *memoryTelemetryStore) Query(ctx context.Context, deviceID uuid.UUID, from, to time.Time, limit int) ([]iot.DataPoint, error) {
+	return nil, nil
+}
+
+func TestIoTStopFlushesTelemetry(t *testing.T) {
+	store := &memoryTelemetryStore{}
+	svc := iot.NewService(logger.New())
+	svc.SetTelemetryStore(store)
+
+	device := uuid.New()
+	for i := 0; i < 10; i++ {
+		require.NoError(t, svc.IngestData(context.Background(), iot.DataPoint{
+			DeviceID:  device,
+			Timestamp: time.Now(),
+			Data:      map[string]interface{}{"temperature": float64(20 + i)},
+		}))
+	}
+	svc.Stop()
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	assert.Len(t, store.points, 10)
+}
+
+// flakyAuditStorage fails the first failures stores, or every store when
+// failures is negative
+type flakyAuditStorage struct {
+	memoryAuditStorage
+	failures int
+}
+
+func (s *flakyAuditStorage) Store(ctx context.Context, entry *security.AuditEntry) error {
+	s.mu.Lock()
+	if s.failures != 0 {
+		s.failures--
+		s.mu.Unlock()
+		return errors.New("storage unavailable")
+	}
+	s.mu.Unlock()
+	return s.memoryAuditStorage.Store(ctx, entry)
+}
+
+func TestAuditRetriesFailedStores(t *testing.T) {
+	storage := &flakyAuditStorage{failures: 1}
+	audit := security.NewAuditService(logger.New(), storage)
+	defer audit.Stop()
+
+	audit.Log(context.Background(), &security.AuditEntry{Action: security.AuditActionAPIKeyCreated, Severity: security.SeverityCritical})
+	require.Eventually(t, func() bool { return storage.count() == 1 }, 5*time.Second, 50*time.Millisecond)
+
+	stats := audit.Stats()
+	assert.Equal(t, int64(1), stats.DeadLettered)
+	assert.Equal(t, int64(1), stats.Recovered)
+	assert.Zero(t, stats.Dropped)
+}
+
+func TestAuditSpillsUnstorableEntriesOnStop(t *testing.T) {
+	storage := &flakyAuditStorage{failures: -1}
+	audit := security.NewAuditService(logger.New(), storage)
+	path := filepath.Join(t.TempDir(), "audit-overflow.jsonl")
+	require.NoError(t, audit.SetOverflowFile(path))
+
+	for i := 0; i < 3; i++ {
+		audit.Log(context.Background(), &security.AuditEntry{Action: security.AuditActionLogin})
+	}
+	audit.Stop()
+
+	stats := audit.Stats()
+	assert.Equal(t, int64(3), stats.Spilled)
+	assert.Zero(t, stats.Dropped)
+	assert.Zero(t, stats.Pending)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 3)
+	var entry security.AuditEntry
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, security.AuditActionLogin, entry.Action)
+}
+
+func TestAuditKeepsRetryingWhenDeadLetterQueueIsFull(t *testing.T) {
+	storage := &flakyAuditStorage{failures: -1}
+	audit := security.NewAuditService(logger.New(), storage)
+
+	// One more critical entry than the dead-letter queue holds, so failed
+	// retries find it full
+	for i := 0; i < 1001; i++ {
+		audit.Log(context.Background(), &security.AuditEntry{Action: security.AuditActionAPIKeyCreated, Severity: security.SeverityCritical})
+	}
+
+	// Requeueing a failed retry must not wait on the queue the retry loop
+	// drains, so every held entry is retried once its first backoff expires
+	require.Eventually(t, func() bool {
+		stats := audit.Stats()
+		return stats.Retried >= 1000 && stats.Pending == 1001
+	}, 5*time.Second, 50*time.Millisecond)
+	stats := audit.Stats()
+	assert.Zero(t, stats.Spilled)
+	assert.Zero(t, stats.Dropped)
+	assert.Equal(t, 1001, audit.QueueDepth())
+
+	audit.Stop()
+	assert.Equal(t, int64(1001), audit.Stats().Dropped)
+	assert.Zero(t, audit.Stats().Pending)
+}
+
+func TestAuditOverflowCountsAreExported(t *testing.T) {
+	storage := &flakyAuditStorage{failures: 1}
+	audit := security.NewAuditService(logger.New(), storage)
+	metrics.RegisterAuditOverflow(audit.OverflowCounts)
+
+	audit.Log(context.Background(), &security.AuditEntry{Action: security.AuditActionLogin})
+	require.Eventually(t, func() bool { return storage.count() == 1 }, 5*time.Second, 50*time.Millisecond)
+	audit.Stop()
+	assert.Equal(t, int64(1), audit.OverflowCounts()["recovered"])
+
+	body := scrapeMetrics(t)
+	assert.Contains(t, body, `delphi_audit_overflow_total{outcome="dead_lettered"} 1`)
+	assert.Contains(t, body, `delphi_audit_overflow_total{outcome="recovered"} 1`)
+	assert.Contains(t, body, `delphi_audit_overflow_total{outcome="dropped"} 0`)
+}
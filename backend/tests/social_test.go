@@ -0,0 +1,200 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/social"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Social Tests
+// =============================================================================
+
+// fakeSocialProvider rate limits every post and counts token refreshes
+type fakeSocialProvider struct {
+	posts     atomic.Int32
+	refreshes atomic.Int32
+}
+
+func (p *fakeSocialProvider) Connect(ctx context.Context, tenantID uuid.UUID) (string, error) {
+	return "", nil
+}
+
+func (p *fakeSocialProvider) HandleCallback(ctx context.Context, tenantID uuid.UUID, code string) (*social.Account, error) {
+	return nil, nil
+}
+
+func (p *fakeSocialProvider) RefreshToken(ctx context.Context, account *social.Account) error {
+	p.refreshes.Add(1)
+	time.Sleep(10 * time.Millisecond)
+	account.AccessToken = "fresh"
+	account.TokenExpiry = time.Now().Add(time.Hour)
+	return nil
+}
+
+func (p *fakeSocialProvider) Post(ctx context.Context, account *social.Account, post *social.Post) (string, error) {
+	p.posts.Add(1)
+	return "", &social.RateLimitError{Platform: account.Platform, RetryAfter: time.Millisecond}
+}
+
+func (p *fakeSocialProvider) Delete(ctx context.Context, account *social.Account, externalID string) error {
+	return nil
+}
+
+func (p *fakeSocialProvider) GetMetrics(ctx context.Context, account *social.Account, externalID string) (*social.PostMetrics, error) {
+	return nil, nil
+}
+
+func newFakeSocial(maxRetries int) (*social.Service, *fakeSocialProvider) {
+	provider := &fakeSocialProvider{}
+	svc := social.NewService(logger.New())
+	svc.RegisterProvider(social.PlatformTwitter, provider)
+	cfg := social.DefaultBatchConfig()
+	cfg.MaxRetries = maxRetries
+	cfg.InitialBackoff = time.Millisecond
+	svc.SetBatchConfig(cfg)
+	return svc, provider
+}
+
+func TestSocialBatchRetriesRateLimitsOnlyWhenAsked(t *testing.T) {
+	account := &social.Account{ID: uuid.New(), Platform: social.PlatformTwitter}
+	items := []social.PublishItem{{Account: account, Post: &social.Post{ID: uuid.New()}}}
+
+	// A caller that reschedules failed posts itself gets one attempt, and the
+	// rate limit back to schedule around
+	svc, provider := newFakeSocial(0)
+	results, summary := svc.PublishBatch(context.Background(), items)
+	assert.Equal(t, 1, summary.Failed)
+	assert.Equal(t, 1, results[0].Attempts)
+	assert.Equal(t, int32(1), provider.posts.Load())
+	var rateErr *social.RateLimitError
+	assert.ErrorAs(t, results[0].Err, &rateErr)
+
+	svc, provider = newFakeSocial(2)
+	results, _ = svc.PublishBatch(context.Background(), items)
+	assert.Equal(t, 3, results[0].Attempts)
+	assert.Equal(t, int32(3), provider.posts.Load())
+}
+
+func TestSocialTokenRefreshIsSerializedPerAccount(t *testing.T) {
+	svc, provider := newFakeSocial(0)
+	expiring := time.Now().Add(time.Minute)
+	shared := &social.Account{ID: uuid.New(), Platform: social.PlatformTwitter, TokenExpiry: expiring}
+	other := &social.Account{ID: uuid.New(), Platform: social.PlatformTwitter, TokenExpiry: expiring}
+
+	var wg sync.WaitGroup
+	var refreshed atomic.Int32
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := svc.RefreshIfExpiring(context.Background(), shared)
+			assert.NoError(t, err)
+			if ok {
+				refreshed.Add(1)
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, err := svc.RefreshIfExpiring(context.Background(), other)
+		assert.NoError(t, err)
+	}()
+	wg.Wait()
+
+	assert.Equal(t, int32(1), refreshed.Load(), "callers that waited find the token fresh")
+	assert.Equal(t, int32(2), provider.refreshes.Load(), "each account is refreshed once")
+	assert.Equal(t, "fresh", shared.AccessToken)
+}
+
+// fakeContentGenerator records the prompts it is given and replies with content
+type fakeContentGenerator struct {
+	content string
+	prompts map[string]string
+}
+
+func (g *fakeContentGenerator) Generate(ctx context.Context, tenantID, agentID uuid.UUID, prompt string) (string, error) {
+	platform := strings.TrimSuffix(strings.TrimPrefix(strings.SplitN(prompt, "\n", 2)[0], "Write a single "), " post.")
+	g.prompts[platform] = prompt
+	return g.content, nil
+}
+
+func TestContentPipelineBuildsPromptsAndFitsPosts(t *testing.T) {
+	svc := social.NewService(logger.New())
+	generator := &fakeContentGenerator{
+		content: "  " + strings.Repeat("word ", 100) + "  ",
+		prompts: make(map[string]string),
+	}
+	svc.SetContentGenerator(generator)
+
+	pipeline := &social.ContentPipeline{
+		ID:        uuid.New(),
+		AgentID:   uuid.New(),
+		Schedule:  "0 9 * * *",
+		Platforms: []social.Platform{social.PlatformTwitter, social.PlatformLinkedIn},
+		Config: social.PipelineConfig{
+			Topics:          []string{"launches", "hiring"},
+			Tone:            "upbeat",
+			MaxLength:       100,
+			IncludeHashtags: true,
+			ReviewRequired:  true,
+		},
+	}
+	posts, err := svc.GenerateContent(context.Background(), pipeline)
+	require.NoError(t, err)
+	require.Len(t, posts, 2)
+
+	twitter := generator.prompts["twitter"]
+	assert.Contains(t, twitter, "Topics: launches, hiring\n")
+	assert.Contains(t, twitter, "Tone: upbeat\n")
+	assert.NotContains(t, twitter, "Style:", "unset settings are left out")
+	assert.Contains(t, twitter, "Keep it under 100 characters.")
+	assert.Contains(t, twitter, "Include a few relevant hashtags.")
+	assert.Contains(t, twitter, "Do not use emojis.")
+
+	for _, post := range posts {
+		assert.LessOrEqual(t, len(post.Content), 100)
+		assert.True(t, strings.HasPrefix(post.Content, "word word"))
+		assert.True(t, strings.HasSuffix(post.Content, "word"), "posts are cut at a word boundary")
+		assert.Equal(t, social.PostStatusDraft, post.Status, "review leaves posts as drafts")
+	}
+
+	require.NotNil(t, pipeline.LastRunAt)
+	require.NotNil(t, pipeline.NextRunAt)
+	assert.True(t, pipeline.NextRunAt.After(*pipeline.LastRunAt))
+	assert.Equal(t, 9, pipeline.NextRunAt.Hour())
+}
+
+func TestContentPipelineCapsLengthAtThePlatformLimit(t *testing.T) {
+	svc := social.NewService(logger.New())
+	generator := &fakeContentGenerator{
+		content: strings.Repeat("x", 400),
+		prompts: make(map[string]string),
+	}
+	svc.SetContentGenerator(generator)
+
+	pipeline := &social.ContentPipeline{
+		ID:        uuid.New(),
+		Platforms: []social.Platform{social.PlatformTwitter},
+		Config:    social.PipelineConfig{MaxLength: 1000, IncludeEmojis: true},
+	}
+	posts, err := svc.GenerateContent(context.Background(), pipeline)
+	require.NoError(t, err)
+	require.Len(t, posts, 1)
+
+	assert.Contains(t, generator.prompts["twitter"], "Keep it under 280 characters.")
+	assert.Contains(t, generator.prompts["twitter"], "Use emojis where they fit.")
+	assert.Len(t, posts[0].Content, 280, "content without spaces is cut at the limit")
+	assert.Equal(t, social.PostStatusScheduled, posts[0].Status)
+	assert.Nil(t, pipeline.NextRunAt, "a pipeline without a schedule has no next run")
+}
@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/config"
+	"github.com/delphi-platform/delphi/backend/internal/security"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Outbound Request Guard Tests
+// =============================================================================
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip     string
+		public bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"224.0.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"64:ff9b::a00:1", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.public, security.IsPublicIP(net.ParseIP(tt.ip)), tt.ip)
+	}
+}
+
+func TestValidatePublicURL(t *testing.T) {
+	ctx := context.Background()
+	for _, raw := range []string{
+		"https://localhost/hook",
+		"https://127.0.0.1/hook",
+		"https://10.0.0.5/hook",
+		"https://[::1]/hook",
+		"http://169.254.169.254/latest/meta-data",
+	} {
+		u, err := url.Parse(raw)
+		require.NoError(t, err)
+		assert.ErrorIs(t, security.ValidatePublicURL(ctx, u), security.ErrNonPublicAddress, raw)
+	}
+
+	u, _ := url.Parse("https://93.184.216.34/hook")
+	assert.NoError(t, security.ValidatePublicURL(ctx, u))
+}
+
+func TestPublicDialerRefusesInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	dialer := security.PublicDialer(&net.Dialer{Timeout: time.Second})
+	client := &http.Client{Transport: &http.Transport{DialContext: dialer.DialContext}}
+	_, err := client.Get(server.URL)
+	assert.ErrorIs(t, err, security.ErrNonPublicAddress)
+}
+
+func TestWebhookCreateRejectsInternalURLs(t *testing.T) {
+	// The URL is checked before anything is stored, so no repositories are needed
+	svc := services.NewWebhookDeliveryService(&config.Config{Environment: "production"}, nil, nil, logger.New())
+	defer svc.Stop()
+
+	for _, raw := range []string{
+		"http://93.184.216.34/hook",
+		"https://localhost:8080/hook",
+		"https://10.0.0.5/hook",
+		"https://169.254.169.254/latest/meta-data",
+	} {
+		_, err := svc.Create(context.Background(), uuid.New(), &services.CreateWebhookRequest{
+			URL:    raw,
+			Events: []string{services.WebhookEventRunCompleted},
+		})
+		assert.Error(t, err, raw)
+	}
+
+	_, err := svc.Create(context.Background(), uuid.New(), &services.CreateWebhookRequest{
+		URL:    "https://127.0.0.1/hook",
+		Events: []string{services.WebhookEventRunCompleted},
+	})
+	assert.EqualError(t, err, "webhook url must not point to a private or internal address")
+}
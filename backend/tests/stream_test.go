@@ -0,0 +1,208 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Streaming Tests
+// =============================================================================
+
+// sseServer answers every request with the server-sent events in body
+func sseServer(t *testing.T, body string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// collect reads every chunk of a stream
+func collect(t *testing.T, chunks <-chan providers.StreamChunk) []providers.StreamChunk {
+	t.Helper()
+	var all []providers.StreamChunk
+	for chunk := range chunks {
+		all = append(all, chunk)
+	}
+	return all
+}
+
+func streamText(chunks []providers.StreamChunk) string {
+	var text strings.Builder
+	for _, chunk := range chunks {
+		text.WriteString(chunk.Delta)
+	}
+	return text.String()
+}
+
+const anthropicStreamFixture = `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":10}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: ping
+data: {"type":"ping"}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" world"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":12}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+func anthropicStream(t *testing.T, body string) []providers.StreamChunk {
+	t.Helper()
+	server := sseServer(t, body)
+	provider, err := providers.NewAnthropicProviderWithEndpoint("key", providers.Endpoint{BaseURL: server.URL + "/v1"})
+	require.NoError(t, err)
+
+	chunks, err := provider.Stream(context.Background(), &providers.CompletionRequest{
+		Model:    "claude-3-5-haiku-20241022",
+		Messages: []providers.Message{{Role: "user", Content: "hi"}},
+	})
+	require.NoError(t, err)
+	return collect(t, chunks)
+}
+
+func TestAnthropicStream(t *testing.T) {
+	chunks := anthropicStream(t, anthropicStreamFixture)
+	require.Len(t, chunks, 3)
+	assert.Equal(t, "Hello world", streamText(chunks))
+	for _, chunk := range chunks {
+		assert.Equal(t, "msg_1", chunk.ID)
+		assert.NoError(t, chunk.Error)
+	}
+
+	last := chunks[len(chunks)-1]
+	assert.Equal(t, "end_turn", last.FinishReason)
+	assert.Equal(t, &providers.TokenUsage{PromptTokens: 10, CompletionTokens: 12, TotalTokens: 22}, last.Usage)
+}
+
+func TestAnthropicStreamErrors(t *testing.T) {
+	t.Run("error event", func(t *testing.T) {
+		chunks := anthropicStream(t, `event: message_start
+data: {"type":"message_start","message":{"id":"msg_2","usage":{"input_tokens":3}}}
+
+event: error
+data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}
+
+`)
+		require.Len(t, chunks, 1)
+		assert.EqualError(t, chunks[0].Error, "anthropic stream error: overloaded_error - Overloaded")
+	})
+
+	t.Run("stream cut short", func(t *testing.T) {
+		chunks := anthropicStream(t, strings.Split(anthropicStreamFixture, "event: message_delta")[0])
+		require.NotEmpty(t, chunks)
+		last := chunks[len(chunks)-1]
+		assert.ErrorContains(t, last.Error, "anthropic stream ended early")
+		assert.Equal(t, "Hello world", streamText(chunks), "text already received is kept")
+	})
+
+	t.Run("malformed event", func(t *testing.T) {
+		chunks := anthropicStream(t, "data: {not json\n\n")
+		require.Len(t, chunks, 1)
+		assert.ErrorContains(t, chunks[0].Error, "failed to decode stream event")
+	})
+}
+
+func TestOpenAIStreamAccumulatesToolCalls(t *testing.T) {
+	server := sseServer(t, `data: {"id":"chatcmpl-1","choices":[{"index":0,"delta":{"role":"assistant","content":"Checking"}}]}
+
+data: {"id":"chatcmpl-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_a","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}
+
+data: {"id":"chatcmpl-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}}]}
+
+data: {"id":"chatcmpl-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_b","type":"function","function":{"name":"get_time","arguments":"{}"}}]}}]}
+
+data: {"id":"chatcmpl-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]}}]}
+
+data: {"id":"chatcmpl-1","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}
+
+data: {"id":"chatcmpl-1","choices":[],"usage":{"prompt_tokens":20,"completion_tokens":15,"total_tokens":35}}
+
+data: [DONE]
+
+`)
+	provider, err := providers.NewOpenAIProviderWithEndpoint("key", providers.Endpoint{BaseURL: server.URL + "/v1"})
+	require.NoError(t, err)
+
+	stream, err := provider.Stream(context.Background(), &providers.CompletionRequest{
+		Model:    "gpt-4o",
+		Messages: []providers.Message{{Role: "user", Content: "Weather and time in Paris?"}},
+	})
+	require.NoError(t, err)
+	chunks := collect(t, stream)
+	assert.Equal(t, "Checking", streamText(chunks))
+
+	var finished, usage *providers.StreamChunk
+	for i := range chunks {
+		require.NoError(t, chunks[i].Error)
+		if chunks[i].FinishReason != "" {
+			finished = &chunks[i]
+		}
+		if chunks[i].Usage != nil {
+			usage = &chunks[i]
+		}
+	}
+	require.NotNil(t, finished)
+	assert.Equal(t, "tool_calls", finished.FinishReason)
+	assert.Equal(t, []providers.ToolCall{
+		{ID: "call_a", Type: "function", Function: providers.FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+		{ID: "call_b", Type: "function", Function: providers.FunctionCall{Name: "get_time", Arguments: "{}"}},
+	}, finished.ToolCalls)
+
+	require.NotNil(t, usage)
+	assert.Equal(t, 35, usage.Usage.TotalTokens)
+}
+
+func TestOpenAIStreamStopsWhenContextDone(t *testing.T) {
+	var events strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&events, "data: {\"id\":\"chatcmpl-2\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"%d \"}}]}\n\n", i)
+	}
+	events.WriteString("data: [DONE]\n\n")
+	server := sseServer(t, events.String())
+	provider, err := providers.NewOpenAIProviderWithEndpoint("key", providers.Endpoint{BaseURL: server.URL + "/v1"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := provider.Stream(ctx, &providers.CompletionRequest{
+		Model:    "gpt-4o",
+		Messages: []providers.Message{{Role: "user", Content: "Count"}},
+	})
+	require.NoError(t, err)
+	first := <-stream
+	assert.Equal(t, "0 ", first.Delta)
+
+	// A consumer that gives up stops the stream rather than leaving it
+	// blocked on a send
+	cancel()
+	received := 0
+	for range stream {
+		received++
+	}
+	assert.Less(t, received, 99)
+}
@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/config"
+	"github.com/delphi-platform/delphi/backend/internal/handlers"
+	"github.com/delphi-platform/delphi/backend/internal/middleware"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Tenant Deletion Tests
+// =============================================================================
+
+func newDeletionTenantService(t *testing.T) *services.TenantService {
+	svc := services.NewTenantService(&config.Config{JWTSecret: "test-secret", TenantDeletionGraceDays: 30}, nil, nil, nil, nil, logger.New())
+	t.Cleanup(svc.Stop)
+	return svc
+}
+
+// Tokens are checked before the tenant is loaded, so bad ones need no database
+func TestConfirmDeletionRejectsBadTokens(t *testing.T) {
+	svc := newDeletionTenantService(t)
+	ctx := context.Background()
+	tenantID, owner := uuid.New(), uuid.New()
+
+	confirmation, err := svc.RequestDeletion(ctx, tenantID, owner, tenantID)
+	require.NoError(t, err)
+	assert.NotEmpty(t, confirmation.Token)
+	assert.Equal(t, "720h0m0s", confirmation.GracePeriod)
+
+	_, err = svc.RequestDeletion(ctx, tenantID, owner, uuid.New())
+	assert.EqualError(t, err, "tenant not found", "other tenants can't be deleted")
+
+	tests := []struct {
+		name   string
+		userID uuid.UUID
+		token  string
+	}{
+		{"missing", owner, ""},
+		{"malformed", owner, "not-a-token"},
+		{"tampered", owner, confirmation.Token + "x"},
+		{"issued to another user", uuid.New(), confirmation.Token},
+		{"expired", owner, "1." + strings.SplitN(confirmation.Token, ".", 2)[1]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.ConfirmDeletion(ctx, tenantID, tt.userID, tenantID, tt.token)
+			assert.ErrorIs(t, err, services.ErrInvalidDeletionToken)
+		})
+	}
+}
+
+func TestTenantDeletionIsOwnerOnly(t *testing.T) {
+	handler := handlers.NewTenantHandler(newDeletionTenantService(t), logger.New())
+	tenantID := uuid.New()
+
+	request := func(role models.UserRole) *httptest.ResponseRecorder {
+		routeCtx := chi.NewRouteContext()
+		routeCtx.URLParams.Add("tenantID", tenantID.String())
+		ctx := context.WithValue(context.Background(), chi.RouteCtxKey, routeCtx)
+		ctx = context.WithValue(ctx, middleware.TenantIDKey, tenantID)
+		ctx = context.WithValue(ctx, middleware.UserIDKey, uuid.New())
+		ctx = context.WithValue(ctx, middleware.UserRoleKey, string(role))
+
+		req := httptest.NewRequest(http.MethodPost, "/tenants/"+tenantID.String()+"/deletion-token", nil).WithContext(ctx)
+		w := httptest.NewRecorder()
+		handler.RequestDeletion(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusForbidden, request(models.RoleAdmin).Code)
+	w := request(models.RoleOwner)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "confirmation_token")
+}
@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/config"
+	"github.com/delphi-platform/delphi/backend/internal/services"
+	"github.com/delphi-platform/delphi/backend/pkg/auth"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Tenant Slug Tests
+// =============================================================================
+
+func TestValidateSlug(t *testing.T) {
+	assert.NoError(t, services.ValidateSlug("acme"))
+	assert.NoError(t, services.ValidateSlug("acme-labs-2"))
+
+	assert.Error(t, services.ValidateSlug("ab"), "too short")
+	assert.Error(t, services.ValidateSlug(strings.Repeat("a", 64)), "too long")
+	assert.Error(t, services.ValidateSlug("Acme"), "uppercase")
+	assert.Error(t, services.ValidateSlug("-acme"), "leading dash")
+	assert.Error(t, services.ValidateSlug("acme--labs"), "double dash")
+	assert.Error(t, services.ValidateSlug("admin"), "reserved")
+}
+
+func TestSlugify(t *testing.T) {
+	assert.Equal(t, "acme-labs-inc", services.Slugify("  Acme Labs, Inc. "))
+	assert.Equal(t, "x-team", services.Slugify("X"))
+	assert.Equal(t, "team", services.Slugify("!!!"))
+
+	long := services.Slugify(strings.Repeat("ab ", 40))
+	assert.NoError(t, services.ValidateSlug(long))
+}
+
+func TestRegisterValidatesLikeTenantCreation(t *testing.T) {
+	tenants := services.NewTenantService(&config.Config{}, nil, nil, nil, nil, logger.New())
+	defer tenants.Stop()
+	jwt := auth.NewJWTManager("register-test-secret", 60, 7)
+	svc := services.NewAuthService(&config.Config{}, nil, jwt, tenants, nil, nil, logger.New())
+
+	// Each request is rejected before the database is reached
+	tests := []struct {
+		name string
+		req  services.RegisterRequest
+		want string
+	}{
+		{"invalid email", services.RegisterRequest{Email: "ada", Password: "Str0ng-passw0rd!", Name: "Ada"}, "invalid email"},
+		{"weak password", services.RegisterRequest{Email: "ada@example.com", Password: "short", Name: "Ada"}, "password"},
+		{"no name", services.RegisterRequest{Email: "ada@example.com", Password: "Str0ng-passw0rd!", Name: " "}, "name is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := svc.Register(context.Background(), &tt.req)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/delphi-platform/delphi/backend/internal/execution"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/providers"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Run Timeout Tests
+// =============================================================================
+
+func TestRunTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		seconds  int
+		def, max time.Duration
+		want     time.Duration
+	}{
+		{"agent timeout", 60, 5 * time.Minute, 30 * time.Minute, time.Minute},
+		{"default when unset", 0, 5 * time.Minute, 30 * time.Minute, 5 * time.Minute},
+		{"clamped to max", 7200, 5 * time.Minute, 30 * time.Minute, 30 * time.Minute},
+		{"uncapped", 7200, 5 * time.Minute, 0, 2 * time.Hour},
+		{"package default", 0, 0, 0, 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, execution.RunTimeout(tt.seconds, tt.def, tt.max))
+		})
+	}
+}
+
+// blockingProvider never answers; its completions end with their context
+type blockingProvider struct {
+	scriptedProvider
+}
+
+func (p *blockingProvider) Name() string { return "blocking" }
+
+func (p *blockingProvider) Complete(ctx context.Context, req *providers.CompletionRequest) (*providers.CompletionResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestExecutionRunnerTimesOut(t *testing.T) {
+	manager := providers.NewManager()
+	manager.RegisterProvider(&blockingProvider{})
+
+	runner := execution.NewExecutionRunner(nil, execution.NewBriefingEngine(logger.New()), logger.New())
+	runner.SetToolLoop(execution.NewToolLoop(execution.NewToolRegistry(), nil, logger.New()), manager)
+	runner.SetRunTimeouts(time.Hour, 50*time.Millisecond)
+
+	agent := &models.Agent{ID: uuid.New(), Provider: "blocking", Model: "gpt-4o"}
+	agent.Config.TimeoutSeconds = 3600
+	agent.Config.BriefingDepth = "quick"
+
+	start := time.Now()
+	result, err := runner.Execute(context.Background(), &execution.ExecutionRequest{
+		Agent:  agent,
+		Run:    &models.AgentRun{ID: uuid.New(), TenantID: uuid.New()},
+		Prompt: "never finishes",
+	})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, execution.ErrRunTimeout)
+	assert.Less(t, time.Since(start), 5*time.Second, "the agent's timeout is clamped to the server's maximum")
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Error, "timeout")
+}
@@ -0,0 +1,29 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/providers"
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// Token Counting Tests
+// =============================================================================
+
+func TestCountTokens(t *testing.T) {
+	assert.Equal(t, 0, providers.CountTokens("gpt-4o", ""))
+	assert.Equal(t, 2, providers.CountTokens("gpt-4o", "hello world"))
+	assert.Equal(t, 2, providers.CountTokens("gpt-4", "hello world"))
+
+	// Non-English text takes far more tokens than a characters-per-token
+	// ratio suggests
+	japanese := strings.Repeat("こんにちは世界", 20)
+	assert.Greater(t, providers.CountTokens("gpt-4", japanese), len([]rune(japanese))/2)
+
+	// Claude is estimated from cl100k_base, scaled for its tokenizer
+	text := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 20)
+	assert.Greater(t, providers.CountTokens("claude-3-5-sonnet-20241022", text), providers.CountTokens("gpt-4", text))
+	assert.Equal(t, providers.CountTokens("gpt-4", text), providers.CountTokens("llama3", text))
+}
@@ -0,0 +1,196 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/execution"
+	"github.com/delphi-platform/delphi/backend/internal/knowledge"
+	"github.com/delphi-platform/delphi/backend/internal/models"
+	"github.com/delphi-platform/delphi/backend/internal/providers"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Tool Loop Tests
+// =============================================================================
+
+// scriptedProvider returns its responses in order and records the requests
+type scriptedProvider struct {
+	responses []*providers.CompletionResponse
+	requests  []*providers.CompletionRequest
+}
+
+func (p *scriptedProvider) Name() string { return "scripted" }
+
+func (p *scriptedProvider) Complete(ctx context.Context, req *providers.CompletionRequest) (*providers.CompletionResponse, error) {
+	copied := *req
+	copied.Messages = append([]providers.Message(nil), req.Messages...)
+	p.requests = append(p.requests, &copied)
+	resp := p.responses[0]
+	if len(p.responses) > 1 {
+		p.responses = p.responses[1:]
+	}
+	return resp, nil
+}
+
+func (p *scriptedProvider) Stream(ctx context.Context, req *providers.CompletionRequest) (<-chan providers.StreamChunk, error) {
+	return nil, nil
+}
+func (p *scriptedProvider) CountTokens(text string) (int, error)                 { return len(text) / 4, nil }
+func (p *scriptedProvider) GetModels() []providers.ModelInfo                     { return nil }
+func (p *scriptedProvider) ValidateAPIKey(ctx context.Context, key string) error { return nil }
+
+// echoTool returns its "text" argument
+type echoTool struct{}
+
+func (echoTool) Definition() providers.Tool {
+	return providers.Tool{Type: "function", Function: providers.ToolFunction{Name: "echo"}}
+}
+
+func (echoTool) Call(ctx context.Context, inv *execution.ToolInvocation) (string, error) {
+	var args struct {
+		Text string `json:"text"`
+	}
+	json.Unmarshal(inv.Arguments, &args)
+	return "echo: " + args.Text, nil
+}
+
+func toolCallResponse(name, args string) *providers.CompletionResponse {
+	return &providers.CompletionResponse{
+		Message: providers.Message{
+			Role:      "assistant",
+			ToolCalls: []providers.ToolCall{{ID: "call-1", Type: "function", Function: providers.FunctionCall{Name: name, Arguments: args}}},
+		},
+		Usage: providers.TokenUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+	}
+}
+
+func TestToolLoopExecutesToolCalls(t *testing.T) {
+	registry := execution.NewToolRegistry()
+	registry.Register(echoTool{})
+	loop := execution.NewToolLoop(registry, nil, logger.New())
+
+	provider := &scriptedProvider{responses: []*providers.CompletionResponse{
+		toolCallResponse("echo", `{"text":"hello"}`),
+		{
+			Message:      providers.Message{Role: "assistant", Content: "done"},
+			FinishReason: "stop",
+			Usage:        providers.TokenUsage{PromptTokens: 20, CompletionTokens: 3, TotalTokens: 23},
+		},
+	}}
+	agent := &models.Agent{ID: uuid.New(), Tools: json.RawMessage(`["echo", "github"]`)}
+	run := &models.AgentRun{ID: uuid.New()}
+	req := &providers.CompletionRequest{Messages: []providers.Message{{Role: "user", Content: "say hello"}}}
+
+	result, err := loop.Run(context.Background(), provider, req, agent, run)
+	require.NoError(t, err)
+	assert.Equal(t, "done", result.Response.Message.Content)
+	assert.Equal(t, 2, result.Iterations)
+	assert.Equal(t, 1, result.ToolCalls)
+	assert.Equal(t, 38, result.Usage.TotalTokens, "usage covers every completion")
+
+	require.Len(t, provider.requests, 2)
+	assert.Len(t, provider.requests[0].Tools, 1, "only registered tools are offered")
+	second := provider.requests[1].Messages
+	require.Len(t, second, 3)
+	assert.Equal(t, "tool", second[2].Role)
+	assert.Equal(t, "call-1", second[2].ToolCallID)
+	assert.Equal(t, "echo: hello", second[2].Content)
+}
+
+func TestToolLoopStopsAtIterationCap(t *testing.T) {
+	registry := execution.NewToolRegistry()
+	registry.Register(echoTool{})
+	loop := execution.NewToolLoop(registry, nil, logger.New())
+	loop.SetMaxIterations(3)
+
+	provider := &scriptedProvider{responses: []*providers.CompletionResponse{toolCallResponse("echo", `{}`)}}
+	agent := &models.Agent{ID: uuid.New(), Tools: json.RawMessage(`[{"name": "echo"}]`)}
+	req := &providers.CompletionRequest{Messages: []providers.Message{{Role: "user", Content: "loop"}}}
+
+	result, err := loop.Run(context.Background(), provider, req, agent, &models.AgentRun{ID: uuid.New()})
+	assert.ErrorIs(t, err, execution.ErrToolIterationsExceeded)
+	assert.Equal(t, 3, result.Iterations)
+	assert.Equal(t, 45, result.Usage.TotalTokens)
+}
+
+func TestToolLoopRefusesToolsTheAgentLacks(t *testing.T) {
+	registry := execution.NewToolRegistry()
+	registry.Register(echoTool{})
+	loop := execution.NewToolLoop(registry, nil, logger.New())
+
+	provider := &scriptedProvider{responses: []*providers.CompletionResponse{
+		toolCallResponse("echo", `{"text":"hi"}`),
+		{Message: providers.Message{Role: "assistant", Content: "ok"}},
+	}}
+	agent := &models.Agent{ID: uuid.New()}
+	req := &providers.CompletionRequest{Messages: []providers.Message{{Role: "user", Content: "hi"}}}
+
+	_, err := loop.Run(context.Background(), provider, req, agent, &models.AgentRun{ID: uuid.New()})
+	require.NoError(t, err)
+	assert.Contains(t, provider.requests[1].Messages[2].Content, "not available")
+}
+
+// recordingLogSink keeps the run log entries it is given
+type recordingLogSink struct {
+	messages []string
+}
+
+func (s *recordingLogSink) AppendRunLog(ctx context.Context, runID uuid.UUID, level models.LogLevel, message string, metadata map[string]interface{}) error {
+	s.messages = append(s.messages, message)
+	return nil
+}
+
+func TestExecutionRunnerSearchesKnowledgeInProcess(t *testing.T) {
+	ctx := context.Background()
+	svc := knowledge.NewService(knowledge.NewMockVectorStore(), unitEmbedder{}, logger.New())
+	kbID := uuid.New()
+	_, err := svc.Ingest(ctx, &knowledge.IngestRequest{
+		KnowledgeBaseID: kbID,
+		Source:          "setup.md",
+		SourceType:      "text",
+		Content:         "Run make setup before the first build.",
+	})
+	require.NoError(t, err)
+
+	provider := &scriptedProvider{responses: []*providers.CompletionResponse{
+		toolCallResponse(execution.KnowledgeSearchToolName, `{"query":"setup"}`),
+		{Message: providers.Message{Role: "assistant", Content: "Run make setup."}, FinishReason: "stop"},
+	}}
+	manager := providers.NewManager()
+	manager.RegisterProvider(provider)
+
+	registry := execution.NewToolRegistry()
+	registry.Register(execution.NewKnowledgeSearchTool(svc))
+	logs := &recordingLogSink{}
+	runner := execution.NewExecutionRunner(nil, execution.NewBriefingEngine(logger.New()), logger.New())
+	runner.SetRunLogSink(logs)
+	runner.SetToolLoop(execution.NewToolLoop(registry, logs, logger.New()), manager)
+
+	agent := &models.Agent{
+		ID:             uuid.New(),
+		Provider:       "scripted",
+		Model:          "gpt-4o",
+		KnowledgeBases: []uuid.UUID{kbID},
+		Tools:          json.RawMessage(`["knowledge_search"]`),
+	}
+	agent.Config.BriefingDepth = "quick"
+	result, err := runner.Execute(ctx, &execution.ExecutionRequest{
+		Agent:  agent,
+		Run:    &models.AgentRun{ID: uuid.New(), TenantID: uuid.New()},
+		Prompt: "how do I set up?",
+	})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, "Run make setup.", result.Response)
+	assert.Empty(t, result.MachineID, "without a Fly token the run executes in-process")
+
+	require.Len(t, provider.requests, 2)
+	assert.Contains(t, provider.requests[1].Messages[len(provider.requests[1].Messages)-1].Content, "make setup")
+	assert.NotEmpty(t, logs.messages, "tool calls are written to the run's log")
+}
@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/providers"
+	"github.com/delphi-platform/delphi/backend/internal/tracing"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// =============================================================================
+// Tracing Tests
+// =============================================================================
+
+// recordSpans records the spans ended during a test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	_, err := tracing.Init(context.Background(), "delphi-test")
+	require.NoError(t, err)
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func spanNamed(t *testing.T, recorder *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	for _, span := range recorder.Ended() {
+		if span.Name() == name {
+			return span
+		}
+	}
+	require.Failf(t, "span not recorded", "no span named %q", name)
+	return nil
+}
+
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+// failingProvider fails every completion
+type failingProvider struct {
+	scriptedProvider
+}
+
+func (p *failingProvider) Complete(ctx context.Context, req *providers.CompletionRequest) (*providers.CompletionResponse, error) {
+	return nil, errors.New("provider unavailable")
+}
+
+func TestTracingProviderSpanIsChildOfRequestSpan(t *testing.T) {
+	recorder := recordSpans(t)
+	provider := providers.Instrument(&scriptedProvider{responses: []*providers.CompletionResponse{
+		{Usage: providers.TokenUsage{PromptTokens: 1000, CompletionTokens: 200, TotalTokens: 1200}},
+	}})
+
+	r := chi.NewRouter()
+	r.Use(tracing.Middleware)
+	r.Get("/agents/{id}/run", func(w http.ResponseWriter, r *http.Request) {
+		_, err := provider.Complete(r.Context(), &providers.CompletionRequest{Model: "gpt-4o"})
+		require.NoError(t, err)
+		w.WriteHeader(http.StatusOK)
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/agents/42/run", nil))
+
+	root := spanNamed(t, recorder, "GET /agents/{id}/run")
+	complete := spanNamed(t, recorder, "provider.complete")
+	assert.Equal(t, root.SpanContext().SpanID(), complete.Parent().SpanID())
+	assert.Equal(t, root.SpanContext().TraceID(), complete.SpanContext().TraceID())
+
+	assert.Equal(t, int64(1000), spanAttribute(complete, "delphi.tokens.input").AsInt64())
+	assert.Equal(t, int64(200), spanAttribute(complete, "delphi.tokens.output").AsInt64())
+	assert.InDelta(t, 0.008, spanAttribute(complete, "delphi.cost_usd").AsFloat64(), 1e-9)
+	assert.Equal(t, "scripted", spanAttribute(complete, "delphi.provider").AsString())
+}
+
+func TestTracingEndsFailedSpansWithErrorStatus(t *testing.T) {
+	recorder := recordSpans(t)
+	provider := providers.Instrument(&failingProvider{})
+
+	r := chi.NewRouter()
+	r.Use(tracing.Middleware)
+	r.Post("/execute", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := provider.Complete(r.Context(), &providers.CompletionRequest{}); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/execute", nil))
+
+	complete := spanNamed(t, recorder, "provider.complete")
+	assert.Equal(t, codes.Error, complete.Status().Code)
+	assert.Equal(t, "provider unavailable", complete.Status().Description)
+
+	root := spanNamed(t, recorder, "POST /execute")
+	assert.Equal(t, codes.Error, root.Status().Code)
+	assert.Equal(t, int64(http.StatusBadGateway), spanAttribute(root, "http.response.status_code").AsInt64())
+}
+
+func TestTracingPropagatesTraceHeaders(t *testing.T) {
+	recorder := recordSpans(t)
+
+	var traceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer upstream.Close()
+	client := &http.Client{Transport: tracing.Transport(nil)}
+
+	r := chi.NewRouter()
+	r.Use(tracing.Middleware)
+	r.Get("/proxy", func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequestWithContext(r.Context(), "GET", upstream.URL, nil)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+	})
+
+	// The caller's trace is continued through the server to the upstream
+	incoming := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest("GET", "/proxy", nil)
+	req.Header.Set("traceparent", incoming)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	root := spanNamed(t, recorder, "GET /proxy")
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", root.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", root.Parent().SpanID().String())
+
+	require.NotEmpty(t, traceparent)
+	assert.Contains(t, traceparent, "4bf92f3577b34da6a3ce929d0e0e4736")
+	assert.NotContains(t, traceparent, "00f067aa0ba902b7", "the upstream sees the client span, not the caller's")
+}
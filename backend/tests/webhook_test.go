@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/delphi-platform/delphi/backend/internal/github"
+	"github.com/delphi-platform/delphi/backend/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// GitHub Webhook Tests
+// =============================================================================
+
+func TestGitHubWebhookSignature(t *testing.T) {
+	const secret = "It's a Secret to Everybody"
+	payload := []byte(`{"action":"opened","repository":{"full_name":"delphi/app"}}`)
+	handler := github.NewWebhookHandler(secret, logger.New())
+
+	t.Run("accepts valid signature", func(t *testing.T) {
+		err := handler.VerifySignature(payload, signPayload(secret, payload))
+		assert.NoError(t, err)
+	})
+
+	t.Run("rejects tampered payload", func(t *testing.T) {
+		tampered := []byte(`{"action":"closed","repository":{"full_name":"delphi/app"}}`)
+		err := handler.VerifySignature(tampered, signPayload(secret, payload))
+		assert.ErrorIs(t, err, github.ErrInvalidSignature)
+	})
+
+	t.Run("rejects wrong secret", func(t *testing.T) {
+		err := handler.VerifySignature(payload, signPayload("wrong secret", payload))
+		assert.ErrorIs(t, err, github.ErrInvalidSignature)
+	})
+
+	t.Run("rejects missing or malformed header", func(t *testing.T) {
+		for _, signature := range []string{"", "sha256=", "sha256=not-hex", "sha1=abc123"} {
+			err := handler.VerifySignature(payload, signature)
+			assert.ErrorIs(t, err, github.ErrInvalidSignature, signature)
+		}
+	})
+
+	t.Run("rejects all deliveries without a secret", func(t *testing.T) {
+		unconfigured := github.NewWebhookHandler("", logger.New())
+		err := unconfigured.VerifySignature(payload, signPayload("", payload))
+		assert.ErrorIs(t, err, github.ErrInvalidSignature)
+	})
+}
+
+func signPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestGitHubPushChangedFiles(t *testing.T) {
+	payload := []byte(`{
+		"ref": "refs/heads/main",
+		"repository": {"full_name": "delphi/app"},
+		"commits": [
+			{"id": "a1", "added": ["new.go", "tmp.txt"], "removed": ["old.go"], "modified": ["main.go"]},
+			{"id": "b2", "added": ["old.go"], "removed": ["tmp.txt"], "modified": ["new.go"]}
+		]
+	}`)
+
+	var pushed github.WebhookPayload
+	handler := github.NewWebhookHandler("secret", logger.New())
+	handler.OnPush(func(p github.WebhookPayload) error {
+		pushed = p
+		return nil
+	})
+	assert.NoError(t, handler.HandleWebhook("push", payload))
+
+	assert.Equal(t, "main", pushed.Branch())
+	changed, removed := pushed.ChangedFiles()
+	assert.Equal(t, []string{"main.go", "new.go", "old.go"}, changed)
+	assert.Equal(t, []string{"tmp.txt"}, removed)
+
+	pushed.Ref = "refs/tags/v1.0.0"
+	assert.Empty(t, pushed.Branch())
+}
@@ -32,25 +32,26 @@ api.interceptors.response.use(
   (error) => {
     if (error.response) {
       const { status, data } = error.response
-      let errorMessage = data.error || data.message || 'An unexpected error occurred.'
+      const message = data.error?.message || data.message
+      let errorMessage = message || 'An unexpected error occurred.'
 
       switch (status) {
         case 400:
-          errorMessage = data.error || 'Bad Request.'
+          errorMessage = message || 'Bad Request.'
           break
         case 401:
-          errorMessage = data.error || 'Unauthorized. Please log in again.'
+          errorMessage = message || 'Unauthorized. Please log in again.'
           localStorage.removeItem('token')
           localStorage.removeItem('user')
           break
         case 403:
-          errorMessage = data.error || 'Forbidden.'
+          errorMessage = message || 'Forbidden.'
           break
         case 404:
-          errorMessage = data.error || 'Resource not found.'
+          errorMessage = message || 'Resource not found.'
           break
         case 500:
-          errorMessage = data.error || 'Internal Server Error.'
+          errorMessage = message || 'Internal Server Error.'
           break
         default:
           break
@@ -89,7 +90,7 @@ export const agentsAPI = {
 export const executeAPI = {
   run: (agentId: string, prompt: string) => 
     api.post('/execute', { agent_id: agentId, prompt }),
-  list: () => api.get('/executions'),
+  list: (cursor?: string) => api.get('/executions', { params: cursor ? { cursor } : undefined }),
   get: (id: string) => api.get(`/executions/${id}`),
 }
 
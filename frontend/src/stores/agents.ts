@@ -19,9 +19,18 @@ export interface Execution {
   error_message?: string
 }
 
+// ExecutionPage is a page of GET /executions, newest first
+interface ExecutionPage {
+  items: Execution[]
+  next_cursor?: string
+  total: number
+}
+
 interface AgentsState {
   agents: Agent[]
   executions: Execution[]
+  executionsCursor: string | null
+  executionsTotal: number
   loading: boolean
   executing: boolean
   error: string | null
@@ -34,11 +43,14 @@ interface AgentsState {
   terminateAgent: (id: string) => Promise<void>
   executeAgent: (agentId: string, prompt: string) => Promise<Execution>
   fetchExecutions: () => Promise<void>
+  fetchMoreExecutions: () => Promise<void>
 }
 
-const useAgentsStore = create<AgentsState>((set) => ({
+const useAgentsStore = create<AgentsState>((set, get) => ({
   agents: [],
   executions: [],
+  executionsCursor: null,
+  executionsTotal: 0,
   loading: false,
   executing: false,
   error: null,
@@ -152,6 +164,7 @@ const useAgentsStore = create<AgentsState>((set) => ({
       
       set((state) => ({
         executions: [execution, ...state.executions],
+        executionsTotal: state.executionsTotal + 1,
         executing: false,
       }))
       
@@ -170,7 +183,28 @@ const useAgentsStore = create<AgentsState>((set) => ({
   fetchExecutions: async () => {
     try {
       const response = await executeAPI.list()
-      set({ executions: response.data })
+      const page = response.data as ExecutionPage
+      set({
+        executions: page.items,
+        executionsCursor: page.next_cursor ?? null,
+        executionsTotal: page.total,
+      })
+    } catch (error: unknown) {
+      console.error('Failed to fetch executions:', error)
+    }
+  },
+
+  fetchMoreExecutions: async () => {
+    const cursor = get().executionsCursor
+    if (!cursor) return
+    try {
+      const response = await executeAPI.list(cursor)
+      const page = response.data as ExecutionPage
+      set((state) => ({
+        executions: [...state.executions, ...page.items],
+        executionsCursor: page.next_cursor ?? null,
+        executionsTotal: page.total,
+      }))
     } catch (error: unknown) {
       console.error('Failed to fetch executions:', error)
     }
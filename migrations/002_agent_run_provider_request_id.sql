@@ -0,0 +1,4 @@
+-- Delphi Agent Run Provider Request IDs
+-- Stores the AI provider's request ID on each run for support escalation
+
+ALTER TABLE agent_runs ADD COLUMN provider_request_id VARCHAR(255);
@@ -0,0 +1,15 @@
+-- Delphi Notification Recipients
+-- Tenant-level default audience for tenant-wide notifications such as budget alerts
+
+CREATE TABLE notification_recipients (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    channel VARCHAR(50) NOT NULL,
+    address VARCHAR(1000) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (tenant_id, channel, address)
+);
+
+CREATE INDEX idx_notification_recipients_tenant ON notification_recipients(tenant_id);
+
+ALTER TABLE notification_recipients ENABLE ROW LEVEL SECURITY;
@@ -0,0 +1,7 @@
+-- Delphi Agent Run Replays
+-- Links a replayed run to the original run and records the parameter overrides applied
+
+ALTER TABLE agent_runs ADD COLUMN replay_of UUID REFERENCES agent_runs(id) ON DELETE SET NULL;
+ALTER TABLE agent_runs ADD COLUMN replay_overrides JSONB;
+
+CREATE INDEX idx_agent_runs_replay_of ON agent_runs(replay_of);
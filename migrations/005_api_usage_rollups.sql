@@ -0,0 +1,18 @@
+-- Delphi API Usage Rollups
+-- Hourly per-tenant request summaries by route and status, backing the API usage analytics endpoint
+
+CREATE TABLE api_usage_rollups (
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    bucket TIMESTAMPTZ NOT NULL,
+    method VARCHAR(10) NOT NULL,
+    route VARCHAR(500) NOT NULL,
+    status INTEGER NOT NULL,
+    request_count BIGINT NOT NULL DEFAULT 0,
+    total_duration_ms BIGINT NOT NULL DEFAULT 0,
+    max_duration_ms BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (tenant_id, bucket, method, route, status)
+);
+
+CREATE INDEX idx_api_usage_rollups_tenant_bucket ON api_usage_rollups(tenant_id, bucket DESC);
+
+ALTER TABLE api_usage_rollups ENABLE ROW LEVEL SECURITY;
@@ -0,0 +1,10 @@
+-- Delphi Billing Events
+-- Stripe webhook events that have been processed, so redelivered events are not applied twice
+
+CREATE TABLE billing_events (
+    id VARCHAR(255) PRIMARY KEY,
+    type VARCHAR(255) NOT NULL,
+    processed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_billing_events_processed_at ON billing_events(processed_at);
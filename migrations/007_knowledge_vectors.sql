@@ -0,0 +1,20 @@
+-- Delphi Knowledge Vectors
+-- Chunk embeddings for the pgvector knowledge store. The embedding column has no
+-- fixed dimension so it can match whichever embedder is configured; the store
+-- creates a per-dimension IVFFlat index on startup.
+
+CREATE TABLE knowledge_vectors (
+    id UUID PRIMARY KEY,
+    knowledge_base_id UUID NOT NULL REFERENCES knowledge_bases(id) ON DELETE CASCADE,
+    document_id UUID NOT NULL,
+    chunk_index INTEGER NOT NULL DEFAULT 0,
+    content TEXT NOT NULL,
+    metadata JSONB NOT NULL DEFAULT '{}',
+    embedding vector NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_knowledge_vectors_kb ON knowledge_vectors(knowledge_base_id);
+CREATE INDEX idx_knowledge_vectors_document ON knowledge_vectors(document_id);
+
+ALTER TABLE knowledge_vectors ENABLE ROW LEVEL SECURITY;
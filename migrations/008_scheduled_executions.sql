@@ -0,0 +1,25 @@
+-- Delphi Scheduled Executions
+-- Cron schedules that start agent runs; next_run_at is advanced each time a schedule fires
+
+CREATE TABLE scheduled_executions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    agent_id UUID NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    cron_expr VARCHAR(255) NOT NULL,
+    timezone VARCHAR(100) NOT NULL DEFAULT 'UTC',
+    prompt TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at TIMESTAMPTZ,
+    last_run_at TIMESTAMPTZ,
+    last_run_id UUID REFERENCES agent_runs(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_scheduled_executions_agent ON scheduled_executions(agent_id);
+CREATE INDEX idx_scheduled_executions_due ON scheduled_executions(next_run_at) WHERE enabled;
+
+ALTER TABLE scheduled_executions ENABLE ROW LEVEL SECURITY;
+
+CREATE TRIGGER update_scheduled_executions_updated_at BEFORE UPDATE ON scheduled_executions
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
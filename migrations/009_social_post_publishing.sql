@@ -0,0 +1,13 @@
+-- Delphi Social Post Publishing
+-- Scheduled posts are claimed by the publishing worker, retried on failure and linked to the platform post
+
+ALTER TABLE social_accounts ADD COLUMN token_expires_at TIMESTAMPTZ;
+
+ALTER TABLE social_posts
+    ADD COLUMN external_id VARCHAR(255),
+    ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN last_error TEXT,
+    ADD COLUMN claimed_at TIMESTAMPTZ;
+
+CREATE INDEX idx_social_posts_due ON social_posts(scheduled_at) WHERE status = 'scheduled';
+CREATE INDEX idx_social_posts_claimed ON social_posts(claimed_at) WHERE status = 'publishing';
@@ -0,0 +1,5 @@
+-- Delphi IoT Telemetry Range Queries
+-- Telemetry is read per device over a time range, newest first
+
+CREATE INDEX idx_iot_telemetry_device_received ON iot_telemetry(device_id, received_at DESC);
+DROP INDEX IF EXISTS idx_iot_telemetry_device;
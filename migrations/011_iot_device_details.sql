@@ -0,0 +1,16 @@
+-- Delphi IoT Device Details
+-- Devices are persisted with their full definition so the device cache can be rebuilt on startup
+
+ALTER TABLE iot_devices
+    ADD COLUMN business_id UUID REFERENCES businesses(id) ON DELETE SET NULL,
+    ADD COLUMN description TEXT,
+    ADD COLUMN location VARCHAR(255),
+    ADD COLUMN config JSONB NOT NULL DEFAULT '{}',
+    ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+CREATE INDEX idx_iot_devices_tenant_type ON iot_devices(tenant_id, type);
+CREATE INDEX idx_iot_devices_tenant_status ON iot_devices(tenant_id, status);
+CREATE INDEX idx_iot_devices_business ON iot_devices(business_id);
+
+CREATE TRIGGER update_iot_devices_updated_at BEFORE UPDATE ON iot_devices
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
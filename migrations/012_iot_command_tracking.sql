@@ -0,0 +1,12 @@
+-- Delphi IoT Command Tracking
+-- Commands are tracked from delivery through the device's acknowledgement;
+-- commands left unacknowledged past the timeout are failed
+
+ALTER TABLE iot_commands
+    ADD COLUMN sent_at TIMESTAMPTZ,
+    ADD COLUMN acknowledged_at TIMESTAMPTZ,
+    ADD COLUMN error TEXT;
+
+ALTER TABLE iot_commands RENAME COLUMN executed_at TO completed_at;
+
+CREATE INDEX idx_iot_commands_sent ON iot_commands(sent_at) WHERE status = 'sent';
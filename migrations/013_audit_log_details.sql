@@ -0,0 +1,10 @@
+-- Delphi Audit Log Details
+-- Audit entries carry a severity, session and free-form details, and are queried per tenant over time
+
+ALTER TABLE audit_logs
+    ADD COLUMN severity VARCHAR(20) NOT NULL DEFAULT 'info',
+    ADD COLUMN session_id VARCHAR(255),
+    ADD COLUMN details JSONB;
+
+CREATE INDEX idx_audit_logs_tenant_created ON audit_logs(tenant_id, created_at DESC);
+DROP INDEX IF EXISTS idx_audit_logs_tenant;
@@ -0,0 +1,35 @@
+-- Delphi Notification Preferences
+-- Channels each notification type is delivered on, set per tenant or per user,
+-- and each user's do-not-disturb window
+
+CREATE TABLE notification_preferences (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE, -- NULL for the tenant default
+    notification_type VARCHAR(50) NOT NULL,
+    enabled_channels TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_notification_preferences_tenant ON notification_preferences(tenant_id, notification_type)
+    WHERE user_id IS NULL;
+CREATE UNIQUE INDEX idx_notification_preferences_user ON notification_preferences(user_id, notification_type)
+    WHERE user_id IS NOT NULL;
+
+ALTER TABLE notification_preferences ENABLE ROW LEVEL SECURITY;
+
+CREATE TRIGGER update_notification_preferences_updated_at BEFORE UPDATE ON notification_preferences
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE notification_do_not_disturb (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    start_time TIME NOT NULL,
+    end_time TIME NOT NULL,
+    timezone VARCHAR(100) NOT NULL DEFAULT 'UTC',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE notification_do_not_disturb ENABLE ROW LEVEL SECURITY;
@@ -0,0 +1,24 @@
+-- Delphi Weekly Digests
+-- When each tenant's weekly summary is sent; day_of_week counts from Sunday (0) and time_of_day is local to timezone
+
+CREATE TABLE digest_schedules (
+    tenant_id UUID PRIMARY KEY REFERENCES tenants(id) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    day_of_week SMALLINT NOT NULL DEFAULT 1 CHECK (day_of_week BETWEEN 0 AND 6),
+    time_of_day TIME NOT NULL DEFAULT '09:00',
+    timezone VARCHAR(100) NOT NULL DEFAULT 'UTC',
+    next_run_at TIMESTAMPTZ,
+    last_sent_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_digest_schedules_due ON digest_schedules(next_run_at) WHERE enabled;
+
+ALTER TABLE digest_schedules ENABLE ROW LEVEL SECURITY;
+
+CREATE TRIGGER update_digest_schedules_updated_at BEFORE UPDATE ON digest_schedules
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Audit queries for PRs opened in a period filter on action and time
+CREATE INDEX idx_audit_logs_tenant_action ON audit_logs(tenant_id, action, created_at);
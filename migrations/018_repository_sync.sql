@@ -0,0 +1,30 @@
+-- Delphi Repository Sync
+-- Links connected repositories to the knowledge base they are indexed into, and
+-- tracks reindex jobs started manually or by GitHub push webhooks
+
+ALTER TABLE repositories
+    ADD COLUMN knowledge_base_id UUID REFERENCES knowledge_bases(id) ON DELETE SET NULL,
+    ADD COLUMN installation_id BIGINT; -- GitHub App installation used to read the repository
+
+CREATE INDEX idx_repositories_full_name ON repositories(lower(full_name));
+
+CREATE INDEX idx_knowledge_documents_source ON knowledge_documents(knowledge_base_id, source);
+
+CREATE TABLE repository_sync_jobs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    repository_id UUID NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    trigger VARCHAR(20) NOT NULL, -- manual, push
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, running, completed, failed
+    commit_sha VARCHAR(40),
+    files_indexed INTEGER NOT NULL DEFAULT 0,
+    files_deleted INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    started_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_repository_sync_jobs_repository ON repository_sync_jobs(repository_id, created_at DESC);
+
+ALTER TABLE repository_sync_jobs ENABLE ROW LEVEL SECURITY;
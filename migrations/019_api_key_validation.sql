@@ -0,0 +1,6 @@
+-- Delphi API Key Validation
+-- When each provider key was last checked with its provider, so stored keys can be revalidated oldest first
+
+ALTER TABLE api_keys ADD COLUMN last_validated_at TIMESTAMPTZ;
+
+CREATE INDEX idx_api_keys_last_validated ON api_keys(last_validated_at NULLS FIRST);
@@ -0,0 +1,4 @@
+-- Delphi User Passwords
+-- bcrypt hashes for users who sign in with a password; NULL for users who cannot
+
+ALTER TABLE users ADD COLUMN password_hash VARCHAR(255);
@@ -0,0 +1,11 @@
+-- Delphi User MFA
+-- TOTP multi-factor authentication. The secret is encrypted by the application
+-- and set at enrollment; MFA is only enforced once mfa_enabled is set, after
+-- the user proves they can generate codes.
+
+ALTER TABLE users
+    ADD COLUMN mfa_enabled BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN mfa_secret TEXT,
+    ADD COLUMN mfa_recovery_codes TEXT[] NOT NULL DEFAULT '{}', -- SHA-256 hashes of unused recovery codes
+    ADD COLUMN mfa_last_step BIGINT, -- last TOTP time step accepted, so a code cannot be replayed
+    ADD COLUMN mfa_enabled_at TIMESTAMPTZ;
@@ -0,0 +1,11 @@
+-- Delphi Tenant Agent Templates
+-- Templates saved by a tenant. Private templates are visible only within the
+-- tenant; public ones are shared with every tenant. Built-in templates are
+-- defined by the application and not stored.
+
+ALTER TABLE agent_templates
+    ADD COLUMN tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    ADD COLUMN created_by UUID REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_agent_templates_tenant ON agent_templates(tenant_id);
+CREATE INDEX idx_agent_templates_public ON agent_templates(is_public) WHERE is_public;
@@ -0,0 +1,7 @@
+-- Delphi Agent Run Payload References
+-- Prompts and responses over the configured size limits are stored truncated;
+-- these reference the full payloads kept in object storage
+
+ALTER TABLE agent_runs
+    ADD COLUMN prompt_ref TEXT,
+    ADD COLUMN response_ref TEXT;
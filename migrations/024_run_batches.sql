@@ -0,0 +1,16 @@
+-- Delphi Run Batches
+-- Runs started together by one batch execution request. Items refused before
+-- a run was created count towards item_count but have no run.
+
+CREATE TABLE run_batches (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    item_count INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_run_batches_tenant ON run_batches(tenant_id);
+
+ALTER TABLE agent_runs ADD COLUMN batch_id UUID REFERENCES run_batches(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_agent_runs_batch ON agent_runs(batch_id);
@@ -0,0 +1,10 @@
+-- Delphi Tenant Deletion
+-- A tenant whose owner confirmed its deletion keeps working until delete_after,
+-- when it and everything it owns is deleted. Clearing delete_after before then
+-- restores the tenant.
+
+ALTER TABLE tenants
+    ADD COLUMN deletion_requested_at TIMESTAMPTZ,
+    ADD COLUMN delete_after TIMESTAMPTZ;
+
+CREATE INDEX idx_tenants_delete_after ON tenants(delete_after) WHERE delete_after IS NOT NULL;
@@ -0,0 +1,8 @@
+-- Delphi Run Cost Anomalies
+-- Runs that cost far more than their agent's recent runs are flagged, with the
+-- average cost they were compared against.
+
+ALTER TABLE agent_runs ADD COLUMN cost_anomaly BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE agent_runs ADD COLUMN cost_baseline DECIMAL(10, 6);
+
+CREATE INDEX idx_agent_runs_cost_anomalies ON agent_runs(tenant_id, started_at DESC) WHERE cost_anomaly;
@@ -0,0 +1,5 @@
+-- Delphi Cached Runs
+-- Runs served from the response cache reuse an identical earlier run's result
+-- without calling the provider, and cost nothing.
+
+ALTER TABLE agent_runs ADD COLUMN cached BOOLEAN NOT NULL DEFAULT FALSE;
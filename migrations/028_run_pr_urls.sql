@@ -0,0 +1,5 @@
+-- Delphi Run Pull Requests
+-- Coding agents configured to open pull requests record the one each run
+-- opened with its changes.
+
+ALTER TABLE agent_runs ADD COLUMN pr_url TEXT;
@@ -0,0 +1,9 @@
+-- Delphi Knowledge Keyword Search
+-- Hybrid knowledge queries rank chunks by full-text match as well as by
+-- embedding. The simple configuration doesn't stem, so identifiers and error
+-- codes match exactly.
+
+ALTER TABLE knowledge_vectors
+    ADD COLUMN content_tsv tsvector GENERATED ALWAYS AS (to_tsvector('simple', content)) STORED;
+
+CREATE INDEX idx_knowledge_vectors_content_tsv ON knowledge_vectors USING GIN (content_tsv);
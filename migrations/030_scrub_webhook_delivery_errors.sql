@@ -0,0 +1,7 @@
+-- Delphi Webhook Delivery Errors
+-- Failed deliveries used to record the start of the receiver's response body,
+-- which the delivery log shows to the tenant. Only the status is kept now.
+
+UPDATE webhook_deliveries
+SET error = substring(error FROM '^unexpected status [0-9]+')
+WHERE error ~ '^unexpected status [0-9]+: ';
@@ -0,0 +1,6 @@
+-- Delphi Tenant Purge
+-- A tenant is marked once its deletion starts removing data held outside the
+-- database, such as its Stripe subscriptions and knowledge vectors. From then
+-- on its deletion can't be cancelled, so it can't be restored half-deleted.
+
+ALTER TABLE tenants ADD COLUMN purge_started_at TIMESTAMPTZ;
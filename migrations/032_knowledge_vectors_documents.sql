@@ -0,0 +1,26 @@
+-- Delphi Knowledge Vector Documents
+-- knowledge_vectors is the only chunk table; knowledge_chunks from the initial
+-- schema was never written to. Chunks now belong to a recorded document and go
+-- with it. The per-dimension IVFFlat indexes built on an empty table are
+-- replaced by HNSW ones, which the store creates on startup.
+
+DROP TABLE knowledge_chunks;
+
+DELETE FROM knowledge_vectors v
+WHERE NOT EXISTS (SELECT 1 FROM knowledge_documents d WHERE d.id = v.document_id);
+
+ALTER TABLE knowledge_vectors
+    ADD CONSTRAINT knowledge_vectors_document_id_fkey
+    FOREIGN KEY (document_id) REFERENCES knowledge_documents(id) ON DELETE CASCADE;
+
+DO $$
+DECLARE
+    idx TEXT;
+BEGIN
+    FOR idx IN
+        SELECT indexname FROM pg_indexes
+        WHERE tablename = 'knowledge_vectors' AND indexname LIKE 'idx_knowledge_vectors_embedding_%'
+    LOOP
+        EXECUTE format('DROP INDEX %I', idx);
+    END LOOP;
+END $$;
@@ -0,0 +1,5 @@
+-- Delphi Agent Run Parameters
+-- Records the parameters each run executed with, so a replay starts from what
+-- actually ran rather than the agent's configuration at replay time
+
+ALTER TABLE agent_runs ADD COLUMN parameters JSONB;
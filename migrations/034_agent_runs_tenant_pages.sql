@@ -0,0 +1,4 @@
+-- Delphi Agent Run Tenant Pages
+-- Serves a tenant's runs newest first, as the executions list pages through them
+
+CREATE INDEX idx_agent_runs_tenant_started ON agent_runs(tenant_id, started_at DESC, id DESC);
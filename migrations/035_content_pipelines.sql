@@ -0,0 +1,30 @@
+-- Delphi Content Pipelines
+-- Pipelines generate social posts with an agent on a cron schedule. A due
+-- pipeline is claimed by pushing next_run_at past the run, and its posts are
+-- saved together with its new last_run_at and next_run_at.
+
+CREATE TABLE content_pipelines (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    business_id UUID REFERENCES businesses(id) ON DELETE SET NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    agent_id UUID NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    schedule VARCHAR(100) NOT NULL,
+    platforms JSONB NOT NULL DEFAULT '[]',
+    accounts JSONB NOT NULL DEFAULT '{}',
+    config JSONB NOT NULL DEFAULT '{}',
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    last_run_at TIMESTAMPTZ,
+    next_run_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_content_pipelines_tenant ON content_pipelines(tenant_id);
+CREATE INDEX idx_content_pipelines_due ON content_pipelines(next_run_at) WHERE is_active;
+
+ALTER TABLE content_pipelines ENABLE ROW LEVEL SECURITY;
+
+CREATE TRIGGER update_content_pipelines_updated_at BEFORE UPDATE ON content_pipelines
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();